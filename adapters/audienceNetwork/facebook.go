@@ -11,9 +11,9 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/pbs"
 	"golang.org/x/net/context/ctxhttp"
 )
@@ -229,6 +229,7 @@ func (a *FacebookAdapter) Call(ctx context.Context, req *pbs.PBSRequest, bidder
 
 	for i, _ := range requests {
 		go func(bidder *pbs.PBSBidder, reqJSON bytes.Buffer) {
+			defer adapters.RecoverCallOne(ch)
 			result, err := a.callOne(ctx, reqJSON)
 			result.Error = err
 			if result.Bid != nil {
@@ -280,7 +281,7 @@ func (a *FacebookAdapter) Call(ctx context.Context, req *pbs.PBSRequest, bidder
 
 func NewAdapterFromFacebook(config *adapters.HTTPAdapterConfig, partnerID string) adapters.Adapter {
 	if partnerID == "" {
-		glog.Errorf("No facebook partnerID specified. Calls to the Audience Network will fail. Did you set adapters.facebook.platform_id in the app config?")
+		logger.Errorf("No facebook partnerID specified. Calls to the Audience Network will fail. Did you set adapters.facebook.platform_id in the app config?")
 		return &adapters.MisconfiguredAdapter{
 			TheName: "audienceNetwork",
 			Err:     errors.New("Audience Network is not configured properly on this Prebid Server deploy. If you believe this should work, contact the company hosting the service and tell them to check their configuration."),
@@ -289,6 +290,28 @@ func NewAdapterFromFacebook(config *adapters.HTTPAdapterConfig, partnerID string
 	return NewFacebookAdapter(config, partnerID)
 }
 
+// facebookExtraInfo is the structure of the adapters.facebook.extra_info config.
+type facebookExtraInfo struct {
+	PlatformID string `json:"platform_id"`
+}
+
+// PlatformID returns the platform_id from the adapter's extra_info config. If extraInfo doesn't set one,
+// it returns defaultID, which comes from the older adapters.facebook.platform_id config.
+func PlatformID(extraInfo string, defaultID string) string {
+	if extraInfo == "" {
+		return defaultID
+	}
+	var info facebookExtraInfo
+	if err := json.Unmarshal([]byte(extraInfo), &info); err != nil {
+		logger.Errorf("Invalid adapters.facebook.extra_info config: %v", err)
+		return defaultID
+	}
+	if info.PlatformID == "" {
+		return defaultID
+	}
+	return info.PlatformID
+}
+
 func NewFacebookAdapter(config *adapters.HTTPAdapterConfig, partnerID string) *FacebookAdapter {
 	a := adapters.NewHTTPAdapter(config)
 
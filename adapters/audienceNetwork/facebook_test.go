@@ -547,3 +547,15 @@ func TestGenerateRequestsForFacebook(t *testing.T) {
 	}
 
 }
+
+func TestPlatformIDFromExtraInfo(t *testing.T) {
+	if id := PlatformID(`{"platform_id":"5678"}`, "1234"); id != "5678" {
+		t.Errorf("The extra_info platform_id should be used. Got %s", id)
+	}
+	if id := PlatformID("", "1234"); id != "1234" {
+		t.Errorf("The platform_id config should be used without extra_info. Got %s", id)
+	}
+	if id := PlatformID(`{"app_secret":"abc"}`, "1234"); id != "1234" {
+		t.Errorf("The platform_id config should be used if extra_info doesn't set one. Got %s", id)
+	}
+}
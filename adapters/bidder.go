@@ -32,6 +32,32 @@ type Bidder interface {
 	MakeBids(internalRequest *openrtb.BidRequest, externalRequest *RequestData, response *ResponseData) (*BidderResponse, []error)
 }
 
+// RequestSplit says how Prebid Server should divide a request between calls to a Bidder's MakeRequests.
+type RequestSplit int
+
+const (
+	// SplitNone passes the whole request to MakeRequests at once.
+	SplitNone RequestSplit = iota
+	// SplitPerImp calls MakeRequests once for each Imp.
+	SplitPerImp
+	// SplitPerMediaType calls MakeRequests once for each media type which the Imps use. Imps with several media types
+	// are copied into each of those calls, with only that call's media type set.
+	SplitPerMediaType
+)
+
+// RequestSplitter can be implemented by Bidders whose servers only accept requests with one Imp, or one media type.
+// Prebid Server then splits the requests before calling MakeRequests, makes the HTTP calls for all the pieces in
+// parallel, and combines the bids. MakeBids still gets the whole request as the internalRequest.
+type RequestSplitter interface {
+	RequestSplit() RequestSplit
+}
+
+// OpenRTBVersioner can be implemented by Bidders whose servers expect OpenRTB 2.6. Bidders which don't implement it
+// are sent OpenRTB 2.5. See UpgradeRequestTo26 for what changes.
+type OpenRTBVersioner interface {
+	OpenRTBVersion() OpenRTBVersion
+}
+
 func BadInput(msg string) *BadInputError {
 	return &BadInputError{
 		Message: msg,
@@ -67,14 +93,48 @@ func (err *BadServerResponseError) Error() string {
 	return err.Message
 }
 
+// ResponseTooLargeError is returned when the external server's response was larger than the
+// adapters.{bidder}.max_response_bytes config. The response is dropped without being read any further.
+type ResponseTooLargeError struct {
+	Message string
+}
+
+func (err *ResponseTooLargeError) Error() string {
+	return err.Message
+}
+
+// Warning returns a WarningError with the given message.
+func Warning(msg string) *WarningError {
+	return &WarningError{
+		Message: msg,
+	}
+}
+
+// WarningError should be used for problems which didn't stop the bidder from bidding, but which the publisher
+// should still fix. For example, a media type which the bidder doesn't support was removed from an imp.
+//
+// WarningErrors are sent in response.ext.warnings.{bidder} rather than response.ext.errors, and don't count
+// towards the adapter error metrics.
+type WarningError struct {
+	Message string
+}
+
+func (err *WarningError) Error() string {
+	return err.Message
+}
+
 // BidderResponse wraps the server's response with the list of bids and the currency used by the bidder.
 //
 // Currency declaration is not mandatory but helps to detect an eventual currency mismatch issue.
 // From the bid response, the bidder accepts a list of valid currencies for the bid.
 // The currency is the same accross all bids.
+//
+// IGI holds any Protected Audience interest group signals from the server's response ext. These will
+// be passed to the page in the final OpenRTB response, even if there are no bids.
 type BidderResponse struct {
 	Currency string
 	Bids     []*TypedBid
+	IGI      []*openrtb_ext.ExtIGI
 }
 
 // NewBidderResponseWithBidsCapacity create a new BidderResponse initialising the bids array capacity and the default currency value
@@ -103,9 +163,18 @@ func NewBidderResponse() *BidderResponse {
 //
 // TypedBid.Bid.Ext will become "response.seatbid[i].bid.ext.bidder" in the final OpenRTB response.
 // TypedBid.BidType will become "response.seatbid[i].bid.ext.prebid.type" in the final OpenRTB response.
+// TypedBid.BidVideo and TypedBid.DealPriority are optional. They will become "response.seatbid[i].bid.ext.prebid.video"
+// and "response.seatbid[i].bid.ext.prebid.dealpriority", and are used to build the hb_pb_cat_dur targeting key.
+// TypedBid.MType is optional. It's the bid's OpenRTB 2.6 mtype, which the host can use to correct BidType (see InferMediaType).
+// TypedBid.BidMeta is optional. It will become "response.seatbid[i].bid.ext.prebid.meta", e.g. to name the renderer
+// which the mobile SDKs need for the bid. If it doesn't name one, the account's renderer is used.
 type TypedBid struct {
-	Bid     *openrtb.Bid
-	BidType openrtb_ext.BidType
+	Bid          *openrtb.Bid
+	BidType      openrtb_ext.BidType
+	BidVideo     *openrtb_ext.ExtBidPrebidVideo
+	BidMeta      *openrtb_ext.ExtBidPrebidMeta
+	DealPriority int
+	MType        int
 }
 
 // RequestData and ResponseData exist so that prebid-server core code can implement its "debug" functionality
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 
-	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -63,7 +63,7 @@ func (i *InfoAwareBidder) MakeRequests(request *openrtb.BidRequest) ([]*RequestD
 }
 
 // pruneImps trims invalid media types from each imp, and returns true if any of the
-// Imps have _no_ valid Media Types left.
+// Imps have _no_ valid Media Types left. Each trimmed media type gets a warning.
 func (i *InfoAwareBidder) pruneImps(imps []openrtb.Imp, allowedTypes []openrtb_ext.BidType) (int, []error) {
 	allowBanner, allowVideo, allowAudio, allowNative := parseAllowedTypes(allowedTypes)
 	numToFilter := 0
@@ -71,19 +71,19 @@ func (i *InfoAwareBidder) pruneImps(imps []openrtb.Imp, allowedTypes []openrtb_e
 	for i := 0; i < len(imps); i++ {
 		if !allowBanner && imps[i].Banner != nil {
 			imps[i].Banner = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses banner, but this bidder doesn't support it", i)))
+			errs = append(errs, Warning(fmt.Sprintf("request.imp[%d] uses banner, but this bidder doesn't support it", i)))
 		}
 		if !allowVideo && imps[i].Video != nil {
 			imps[i].Video = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses video, but this bidder doesn't support it", i)))
+			errs = append(errs, Warning(fmt.Sprintf("request.imp[%d] uses video, but this bidder doesn't support it", i)))
 		}
 		if !allowAudio && imps[i].Audio != nil {
 			imps[i].Audio = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses audio, but this bidder doesn't support it", i)))
+			errs = append(errs, Warning(fmt.Sprintf("request.imp[%d] uses audio, but this bidder doesn't support it", i)))
 		}
 		if !allowNative && imps[i].Native != nil {
 			imps[i].Native = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses native, but this bidder doesn't support it", i)))
+			errs = append(errs, Warning(fmt.Sprintf("request.imp[%d] uses native, but this bidder doesn't support it", i)))
 		}
 		if !hasAnyTypes(&imps[i]) {
 			numToFilter = numToFilter + 1
@@ -134,14 +134,16 @@ func ParseBidderInfos(infoDir string, bidders []openrtb_ext.BidderName) BidderIn
 	bidderInfos := make(map[string]BidderInfo, len(bidders))
 	for _, bidderName := range bidders {
 		bidderString := string(bidderName)
-		fileData, err := ioutil.ReadFile(infoDir + "/" + bidderString + ".yaml")
+		// The host's aliases share their core bidder's info file.
+		fileName := infoDir + "/" + string(openrtb_ext.CoreBidderName(bidderName)) + ".yaml"
+		fileData, err := ioutil.ReadFile(fileName)
 		if err != nil {
-			glog.Fatalf("error reading from file %s: %v", infoDir+"/"+bidderString+".yaml", err)
+			logger.Fatalf("error reading from file %s: %v", fileName, err)
 		}
 
 		var parsedInfo BidderInfo
 		if err := yaml.Unmarshal(fileData, &parsedInfo); err != nil {
-			glog.Fatalf("error parsing yaml in file %s: %v", infoDir+"/"+bidderString+".yaml", err)
+			logger.Fatalf("error parsing yaml in file %s: %v", fileName, err)
 		}
 		bidderInfos[bidderString] = parsedInfo
 	}
@@ -164,9 +166,35 @@ func (infos BidderInfos) SupportsWebMediaType(bidder openrtb_ext.BidderName, med
 	return containsMediaType(infos[string(bidder)].Capabilities.Site.MediaTypes, mediaType)
 }
 
+// Deprecation returns the bidder's deprecation info, or nil if it isn't deprecated.
+func (infos BidderInfos) Deprecation(bidder openrtb_ext.BidderName) *DeprecationInfo {
+	return infos[string(bidder)].Deprecation
+}
+
 type BidderInfo struct {
 	Maintainer   *MaintainerInfo   `yaml:"maintainer" json:"maintainer"`
 	Capabilities *CapabilitiesInfo `yaml:"capabilities" json:"capabilities"`
+	Deprecation  *DeprecationInfo  `yaml:"deprecation" json:"deprecation,omitempty"`
+}
+
+// DeprecationInfo marks a bidder which is being phased out. Requests for a deprecated bidder still run, but their
+// responses warn that it's deprecated. Requests for a removed bidder are rejected.
+type DeprecationInfo struct {
+	Removed bool `yaml:"removed" json:"removed"`
+	// Replacement is the bidder which publishers should move to, if there is one.
+	Replacement string `yaml:"replacement" json:"replacement,omitempty"`
+}
+
+// Message explains the bidder's deprecation to publishers.
+func (info *DeprecationInfo) Message(bidder openrtb_ext.BidderName) string {
+	status := "is deprecated, and will be removed from Prebid Server soon"
+	if info.Removed {
+		status = "has been removed from Prebid Server"
+	}
+	if info.Replacement != "" {
+		return fmt.Sprintf("Bidder %s %s. Use %s instead.", bidder, status, info.Replacement)
+	}
+	return fmt.Sprintf("Bidder %s %s.", bidder, status)
 }
 
 type MaintainerInfo struct {
@@ -95,9 +95,9 @@ func TestImpFiltering(t *testing.T) {
 	assert.EqualError(t, errs[3], "request.imp[1] has no supported MediaTypes. It will be ignored")
 	assert.EqualError(t, errs[4], "request.imp[3] has no supported MediaTypes. It will be ignored")
 	assert.EqualError(t, errs[5], "mock MakeRequests error")
-	assert.IsType(t, &adapters.BadInputError{}, errs[0])
-	assert.IsType(t, &adapters.BadInputError{}, errs[1])
-	assert.IsType(t, &adapters.BadInputError{}, errs[2])
+	assert.IsType(t, &adapters.WarningError{}, errs[0])
+	assert.IsType(t, &adapters.WarningError{}, errs[1])
+	assert.IsType(t, &adapters.WarningError{}, errs[2])
 	assert.IsType(t, &adapters.BadInputError{}, errs[3])
 	assert.IsType(t, &adapters.BadInputError{}, errs[4])
 
@@ -142,3 +142,18 @@ func TestParsing(t *testing.T) {
 	assert.Equal(t, false, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeAudio))
 	assert.Equal(t, true, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeNative))
 }
+
+func TestDeprecation(t *testing.T) {
+	currentBidder := openrtb_ext.BidderName("someBidder")
+	oldBidder := openrtb_ext.BidderName("oldBidder")
+	infos := adapters.ParseBidderInfos("./adapterstest/bidder-info", []openrtb_ext.BidderName{currentBidder, oldBidder})
+
+	assert.Nil(t, infos.Deprecation(currentBidder))
+	if deprecation := infos.Deprecation(oldBidder); assert.NotNil(t, deprecation) {
+		assert.Equal(t, true, deprecation.Removed)
+		assert.Equal(t, "Bidder oldBidder has been removed from Prebid Server. Use someBidder instead.", deprecation.Message(oldBidder))
+	}
+
+	deprecated := &adapters.DeprecationInfo{}
+	assert.Equal(t, "Bidder oldBidder is deprecated, and will be removed from Prebid Server soon.", deprecated.Message(oldBidder))
+}
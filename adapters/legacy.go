@@ -3,9 +3,13 @@ package adapters
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net/http"
+	"runtime/debug"
 	"time"
 
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/pbs"
 	"github.com/prebid/prebid-server/ssl"
 )
@@ -80,6 +84,33 @@ type CallOneResult struct {
 	Error        error
 }
 
+// errCallPanicked is the error for a call which panicked. The details are only logged,
+// since the stack trace doesn't mean anything to the publisher.
+var errCallPanicked = errors.New("The adapter failed unexpectedly, so its bids were dropped.")
+
+// RecoverCallOne recovers from a panic in one of an adapter's calls, and sends errCallPanicked on ch as the call's
+// result. Adapters which make their calls in their own goroutines must defer it in each one, since a panic there
+// can't be recovered by the code which called the adapter, and would crash the server.
+func RecoverCallOne(ch chan<- CallOneResult) {
+	if r := recover(); r != nil {
+		logger.Errorf("Recovered from a panic in an adapter call: %v\n%s", r, debug.Stack())
+		ch <- CallOneResult{Error: errCallPanicked}
+	}
+}
+
+// SafeCall calls the adapter, and returns any panic from it as an error, so that one broken adapter can't
+// crash the server. Panics in goroutines which the adapter starts itself aren't covered: see RecoverCallOne.
+func SafeCall(ctx context.Context, adapter Adapter, req *pbs.PBSRequest, bidder *pbs.PBSBidder) (bids pbs.PBSBidSlice, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Recovered from a panic in adapter %s: %v\n%s", adapter.Name(), r, debug.Stack())
+			bids = nil
+			err = fmt.Errorf("The %s adapter failed unexpectedly, so its bids were dropped.", adapter.Name())
+		}
+	}()
+	return adapter.Call(ctx, req, bidder)
+}
+
 type MisconfiguredAdapter struct {
 	TheName string
 	Err     error
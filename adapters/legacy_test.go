@@ -0,0 +1,49 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prebid/prebid-server/pbs"
+)
+
+func TestRecoverCallOne(t *testing.T) {
+	ch := make(chan CallOneResult)
+	go func() {
+		defer RecoverCallOne(ch)
+		var bid *pbs.PBSBid
+		bid.AdUnitCode = "this-panics"
+		ch <- CallOneResult{Bid: bid}
+	}()
+	if result := <-ch; result.Error != errCallPanicked || result.Bid != nil {
+		t.Errorf("A call which panics should get errCallPanicked. Got %v", result)
+	}
+}
+
+func TestSafeCall(t *testing.T) {
+	bids, err := SafeCall(context.Background(), &panickingAdapter{}, &pbs.PBSRequest{}, &pbs.PBSBidder{})
+	if err == nil || bids != nil {
+		t.Errorf("A panic should be returned as an error, without bids. Got %v, %v", bids, err)
+	}
+
+	expected := errors.New("some error")
+	_, err = SafeCall(context.Background(), &MisconfiguredAdapter{TheName: "broken", Err: expected}, &pbs.PBSRequest{}, &pbs.PBSBidder{})
+	if err != expected {
+		t.Errorf("The adapter's own errors should be returned unchanged. Got %v", err)
+	}
+}
+
+type panickingAdapter struct{}
+
+func (a *panickingAdapter) Name() string {
+	return "panicking"
+}
+
+func (a *panickingAdapter) SkipNoCookies() bool {
+	return false
+}
+
+func (a *panickingAdapter) Call(ctx context.Context, req *pbs.PBSRequest, bidder *pbs.PBSBidder) (pbs.PBSBidSlice, error) {
+	panic("some bug")
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"strings"
 
@@ -18,6 +17,8 @@ import (
 type LifestreetAdapter struct {
 	http *adapters.HTTPAdapter
 	URI  string
+	// MaxResponseBytes is the largest response which will be read from Lifestreet's server, or 0 if there's no limit.
+	MaxResponseBytes int
 }
 
 // used for cookies and such
@@ -46,7 +47,11 @@ func (a *LifestreetAdapter) callOne(ctx context.Context, req *pbs.PBSRequest, re
 	}
 
 	defer lsmResp.Body.Close()
-	body, _ := ioutil.ReadAll(lsmResp.Body)
+	body, e := adapters.ReadResponseBody(lsmResp, a.MaxResponseBytes)
+	if e != nil {
+		err = e
+		return
+	}
 	result.ResponseBody = string(body)
 
 	result.StatusCode = lsmResp.StatusCode
@@ -151,6 +156,7 @@ func (a *LifestreetAdapter) Call(ctx context.Context, req *pbs.PBSRequest, bidde
 	ch := make(chan adapters.CallOneResult)
 	for i, _ := range bidder.AdUnits {
 		go func(bidder *pbs.PBSBidder, reqJSON bytes.Buffer) {
+			defer adapters.RecoverCallOne(ch)
 			result, err := a.callOne(ctx, req, reqJSON)
 			result.Error = err
 			if result.Bid != nil {
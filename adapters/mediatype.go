@@ -0,0 +1,165 @@
+package adapters
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// These are the rules which InferMediaType can use to work out a bid's media type.
+const (
+	// MediaTypeFromMType uses the bid's OpenRTB 2.6 mtype, if the Bidder set TypedBid.MType.
+	MediaTypeFromMType = "mtype"
+	// MediaTypeFromImp uses the imp's media type, if the imp only offered one.
+	MediaTypeFromImp = "imp"
+	// MediaTypeFromAdm looks at the bid's markup: VAST for video (or audio, for audio-only imps),
+	// a JSON native response for native, and HTML for banner.
+	MediaTypeFromAdm = "adm"
+)
+
+// InferMediaType tries each of the rules in order, and returns the media type from the first one which can tell.
+// It returns false if none of them could. imp may be nil if the bid's imp isn't known.
+func InferMediaType(bid *TypedBid, imp *openrtb.Imp, rules []string) (openrtb_ext.BidType, bool) {
+	if bid == nil || bid.Bid == nil {
+		return "", false
+	}
+	for _, rule := range rules {
+		var bidType openrtb_ext.BidType
+		switch rule {
+		case MediaTypeFromMType:
+			bidType = mediaTypeFromMType(bid.MType)
+		case MediaTypeFromImp:
+			bidType = mediaTypeFromImp(imp)
+		case MediaTypeFromAdm:
+			bidType = mediaTypeFromAdm(bid.Bid.AdM, imp)
+		}
+		if bidType != "" {
+			return bidType, true
+		}
+	}
+	return "", false
+}
+
+// ReadMTypes returns the mtype of each bid in an OpenRTB 2.6 response body, by bid ID. Bidders can use this to
+// fill in TypedBid.MType, since the OpenRTB structs don't have the field yet. Bids without an mtype are left out.
+func ReadMTypes(body []byte) map[string]int {
+	var response struct {
+		SeatBid []struct {
+			Bid []struct {
+				ID    string `json:"id"`
+				MType int    `json:"mtype"`
+			} `json:"bid"`
+		} `json:"seatbid"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+	mtypes := make(map[string]int)
+	for _, seatBid := range response.SeatBid {
+		for _, bid := range seatBid.Bid {
+			if bid.MType != 0 {
+				mtypes[bid.ID] = bid.MType
+			}
+		}
+	}
+	return mtypes
+}
+
+func mediaTypeFromMType(mtype int) openrtb_ext.BidType {
+	switch mtype {
+	case 1:
+		return openrtb_ext.BidTypeBanner
+	case 2:
+		return openrtb_ext.BidTypeVideo
+	case 3:
+		return openrtb_ext.BidTypeAudio
+	case 4:
+		return openrtb_ext.BidTypeNative
+	}
+	return ""
+}
+
+func mediaTypeFromImp(imp *openrtb.Imp) openrtb_ext.BidType {
+	if imp == nil {
+		return ""
+	}
+	var bidType openrtb_ext.BidType
+	offered := 0
+	if imp.Banner != nil {
+		bidType = openrtb_ext.BidTypeBanner
+		offered++
+	}
+	if imp.Video != nil {
+		bidType = openrtb_ext.BidTypeVideo
+		offered++
+	}
+	if imp.Audio != nil {
+		bidType = openrtb_ext.BidTypeAudio
+		offered++
+	}
+	if imp.Native != nil {
+		bidType = openrtb_ext.BidTypeNative
+		offered++
+	}
+	if offered != 1 {
+		return ""
+	}
+	return bidType
+}
+
+func mediaTypeFromAdm(adm string, imp *openrtb.Imp) openrtb_ext.BidType {
+	adm = strings.TrimSpace(adm)
+	switch {
+	case adm == "":
+		return ""
+	case isVAST(adm):
+		if imp != nil && imp.Audio != nil && imp.Video == nil {
+			return openrtb_ext.BidTypeAudio
+		}
+		return openrtb_ext.BidTypeVideo
+	case adm[0] == '{':
+		if isNativeResponse(adm) {
+			return openrtb_ext.BidTypeNative
+		}
+		return ""
+	case adm[0] == '<':
+		return openrtb_ext.BidTypeBanner
+	}
+	return ""
+}
+
+// isVAST returns true if the markup's root element is VAST. An XML declaration and comments may come first.
+func isVAST(adm string) bool {
+	for strings.HasPrefix(adm, "<?") || strings.HasPrefix(adm, "<!--") {
+		end := "?>"
+		if strings.HasPrefix(adm, "<!--") {
+			end = "-->"
+		}
+		i := strings.Index(adm, end)
+		if i < 0 {
+			return false
+		}
+		adm = strings.TrimSpace(adm[i+len(end):])
+	}
+	return strings.HasPrefix(adm, "<VAST")
+}
+
+// isNativeResponse returns true if the markup is a native response object, either on its own (native 1.2)
+// or wrapped in a "native" object (native 1.0 and 1.1).
+func isNativeResponse(adm string) bool {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(adm), &response); err != nil {
+		return false
+	}
+	if native, ok := response["native"]; ok {
+		response = nil
+		if err := json.Unmarshal(native, &response); err != nil {
+			return false
+		}
+	}
+	_, hasAssets := response["assets"]
+	_, hasLink := response["link"]
+	return hasAssets || hasLink
+}
@@ -0,0 +1,117 @@
+package adapters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestInferMediaType(t *testing.T) {
+	bannerImp := &openrtb.Imp{ID: "imp", Banner: &openrtb.Banner{}}
+	multiFormatImp := &openrtb.Imp{ID: "imp", Banner: &openrtb.Banner{}, Video: &openrtb.Video{}}
+	audioImp := &openrtb.Imp{ID: "imp", Audio: &openrtb.Audio{}}
+	allRules := []string{MediaTypeFromMType, MediaTypeFromImp, MediaTypeFromAdm}
+
+	testCases := []struct {
+		description string
+		bid         TypedBid
+		imp         *openrtb.Imp
+		rules       []string
+		expected    openrtb_ext.BidType
+		expectOK    bool
+	}{
+		{
+			description: "mtype comes first",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: "<div></div>"}, MType: 2},
+			imp:         bannerImp,
+			rules:       allRules,
+			expected:    openrtb_ext.BidTypeVideo,
+			expectOK:    true,
+		},
+		{
+			description: "single format imp",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: "<VAST></VAST>"}},
+			imp:         bannerImp,
+			rules:       allRules,
+			expected:    openrtb_ext.BidTypeBanner,
+			expectOK:    true,
+		},
+		{
+			description: "VAST in a multi-format imp",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: `<?xml version="1.0"?><!-- wrapper --><VAST version="3.0"></VAST>`}},
+			imp:         multiFormatImp,
+			rules:       allRules,
+			expected:    openrtb_ext.BidTypeVideo,
+			expectOK:    true,
+		},
+		{
+			description: "VAST in an audio imp",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: "<VAST></VAST>"}},
+			imp:         audioImp,
+			rules:       []string{MediaTypeFromAdm},
+			expected:    openrtb_ext.BidTypeAudio,
+			expectOK:    true,
+		},
+		{
+			description: "HTML in a multi-format imp",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: "  <script src=\"ad.js\"></script>"}},
+			imp:         multiFormatImp,
+			rules:       allRules,
+			expected:    openrtb_ext.BidTypeBanner,
+			expectOK:    true,
+		},
+		{
+			description: "native 1.1 response",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: `{"native":{"assets":[],"link":{"url":"http://example.com"}}}`}},
+			rules:       []string{MediaTypeFromAdm},
+			expected:    openrtb_ext.BidTypeNative,
+			expectOK:    true,
+		},
+		{
+			description: "native 1.2 response",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: `{"assets":[{"id":1}]}`}},
+			rules:       []string{MediaTypeFromAdm},
+			expected:    openrtb_ext.BidTypeNative,
+			expectOK:    true,
+		},
+		{
+			description: "JSON which isn't native",
+			bid:         TypedBid{Bid: &openrtb.Bid{AdM: `{"url":"http://example.com"}`}},
+			rules:       []string{MediaTypeFromAdm},
+			expectOK:    false,
+		},
+		{
+			description: "no rule can tell",
+			bid:         TypedBid{Bid: &openrtb.Bid{}},
+			imp:         multiFormatImp,
+			rules:       allRules,
+			expectOK:    false,
+		},
+		{
+			description: "no rules",
+			bid:         TypedBid{Bid: &openrtb.Bid{}, MType: 1},
+			imp:         bannerImp,
+			expectOK:    false,
+		},
+	}
+
+	for _, test := range testCases {
+		bidType, ok := InferMediaType(&test.bid, test.imp, test.rules)
+		if ok != test.expectOK || bidType != test.expected {
+			t.Errorf("%s: expected (%q, %t). Got (%q, %t)", test.description, test.expected, test.expectOK, bidType, ok)
+		}
+	}
+}
+
+func TestReadMTypes(t *testing.T) {
+	body := []byte(`{"id":"resp","seatbid":[{"bid":[{"id":"a","mtype":2},{"id":"b"}]},{"bid":[{"id":"c","mtype":4}]}]}`)
+	expected := map[string]int{"a": 2, "c": 4}
+	if actual := ReadMTypes(body); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v. Got %v", expected, actual)
+	}
+	if actual := ReadMTypes([]byte("not json")); actual != nil {
+		t.Errorf("Malformed bodies should have no mtypes. Got %v", actual)
+	}
+}
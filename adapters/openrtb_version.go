@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// OpenRTBVersion is a version of OpenRTB which a Bidder's server may expect.
+type OpenRTBVersion string
+
+const (
+	OpenRTB25 OpenRTBVersion = "2.5"
+	OpenRTB26 OpenRTBVersion = "2.6"
+)
+
+// movedFields are the OpenRTB 2.6 fields which were in ext in 2.5, by the object which has them. The OpenRTB structs
+// don't have them yet, so Prebid Server keeps them in ext until they're sent to a 2.6 Bidder.
+var movedFields = []struct {
+	object string
+	fields []string
+}{
+	{"regs", []string{"gdpr", "us_privacy", "gpp", "gpp_sid"}},
+	{"user", []string{"consent", "eids"}},
+	{"source", []string{"schain"}},
+	{"device", []string{"sua"}},
+}
+
+// DowngradeRequestTo25 moves the OpenRTB 2.6 fields in a request body into their objects' ext, where 2.5 kept
+// them. If a field is in both places, the 2.6 one wins. The bool is false, and the body is returned as-is,
+// if there was nothing to move.
+func DowngradeRequestTo25(body []byte) ([]byte, bool, error) {
+	return moveFields(body, func(object map[string]json.RawMessage, ext map[string]json.RawMessage, field string) bool {
+		value, ok := object[field]
+		if !ok {
+			return false
+		}
+		ext[field] = value
+		delete(object, field)
+		return true
+	})
+}
+
+// UpgradeRequestTo26 moves the fields which OpenRTB 2.5 kept in ext to where OpenRTB 2.6 has them: the regs.ext
+// privacy signals go to regs, user.ext.consent and eids to user, source.ext.schain to source and device.ext.sua
+// to device. If a field is in both places, the 2.6 one wins. The bool is false, and the body is returned as-is,
+// if there was nothing to move.
+func UpgradeRequestTo26(body []byte) ([]byte, bool, error) {
+	return moveFields(body, func(object map[string]json.RawMessage, ext map[string]json.RawMessage, field string) bool {
+		value, ok := ext[field]
+		if !ok {
+			return false
+		}
+		if _, ok := object[field]; !ok {
+			object[field] = value
+		}
+		delete(ext, field)
+		return true
+	})
+}
+
+// moveFields calls move for each of the movedFields, and rewrites the objects which it changed. Bodies which
+// aren't JSON objects, like the ones some Bidders send in GET requests, are left alone.
+func moveFields(body []byte, move func(object map[string]json.RawMessage, ext map[string]json.RawMessage, field string) bool) ([]byte, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return body, false, nil
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, false, err
+	}
+	requestChanged := false
+	for _, moved := range movedFields {
+		objectJSON, ok := request[moved.object]
+		if !ok || isNull(objectJSON) {
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(objectJSON, &object); err != nil {
+			return body, false, err
+		}
+		ext := make(map[string]json.RawMessage)
+		if extJSON, ok := object["ext"]; ok && !isNull(extJSON) {
+			if err := json.Unmarshal(extJSON, &ext); err != nil {
+				return body, false, err
+			}
+		}
+		changed := false
+		for _, field := range moved.fields {
+			if move(object, ext, field) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		if len(ext) > 0 {
+			extJSON, err := json.Marshal(ext)
+			if err != nil {
+				return body, false, err
+			}
+			object["ext"] = extJSON
+		} else {
+			delete(object, "ext")
+		}
+		newObjectJSON, err := json.Marshal(object)
+		if err != nil {
+			return body, false, err
+		}
+		request[moved.object] = newObjectJSON
+		requestChanged = true
+	}
+	if !requestChanged {
+		return body, false, nil
+	}
+	newBody, err := json.Marshal(request)
+	if err != nil {
+		return body, false, err
+	}
+	return newBody, true, nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+}
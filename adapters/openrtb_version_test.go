@@ -0,0 +1,61 @@
+package adapters
+
+import (
+	"testing"
+)
+
+func TestUpgradeRequestTo26(t *testing.T) {
+	testCases := []struct {
+		description string
+		body        string
+		expected    string
+		changed     bool
+	}{
+		{
+			description: "fields move out of ext",
+			body:        `{"id":"req-1","regs":{"coppa":1,"ext":{"gdpr":1,"us_privacy":"1YNN","gpp_sid":[7]}},"user":{"id":"user-1","ext":{"consent":"BOEFEAyOEFEAyAHABDENAI4AAAB9vABAASA","eids":[{"source":"id5-sync.com"}],"prebid":{"buyeruids":{"appnexus":"123"}}}},"source":{"ext":{"schain":{"ver":"1.0"}}},"device":{"ua":"Mozilla","ext":{"sua":{"source":1}}}}`,
+			expected:    `{"device":{"sua":{"source":1},"ua":"Mozilla"},"id":"req-1","regs":{"coppa":1,"gdpr":1,"gpp_sid":[7],"us_privacy":"1YNN"},"source":{"schain":{"ver":"1.0"}},"user":{"consent":"BOEFEAyOEFEAyAHABDENAI4AAAB9vABAASA","eids":[{"source":"id5-sync.com"}],"ext":{"prebid":{"buyeruids":{"appnexus":"123"}}},"id":"user-1"}}`,
+			changed:     true,
+		},
+		{
+			description: "2.6 fields win",
+			body:        `{"regs":{"gdpr":0,"ext":{"gdpr":1}}}`,
+			expected:    `{"regs":{"gdpr":0}}`,
+			changed:     true,
+		},
+		{
+			description: "nothing to move",
+			body:        `{"id":"req-1", "user":{"ext":{"prebid":{}}}, "regs":null}`,
+			expected:    `{"id":"req-1", "user":{"ext":{"prebid":{}}}, "regs":null}`,
+		},
+		{
+			description: "not JSON",
+			body:        `id=req-1`,
+			expected:    `id=req-1`,
+		},
+	}
+	for _, test := range testCases {
+		body, changed, err := UpgradeRequestTo26([]byte(test.body))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.description, err)
+		}
+		if string(body) != test.expected || changed != test.changed {
+			t.Errorf("%s: expected %s (changed: %t). Got %s (changed: %t)", test.description, test.expected, test.changed, body, changed)
+		}
+	}
+}
+
+func TestDowngradeRequestTo25(t *testing.T) {
+	body, changed, err := DowngradeRequestTo25([]byte(`{"regs":{"gdpr":1,"gpp_sid":[7],"ext":{"gdpr":0}},"user":{"consent":"abc"},"device":{"sua":{"source":1}}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"device":{"ext":{"sua":{"source":1}}},"regs":{"ext":{"gdpr":1,"gpp_sid":[7]}},"user":{"ext":{"consent":"abc"}}}`
+	if !changed || string(body) != expected {
+		t.Errorf("Expected %s. Got %s (changed: %t)", expected, body, changed)
+	}
+
+	if _, _, err := DowngradeRequestTo25([]byte(`{"regs":{"gdpr":1,"ext":"bad"}}`)); err == nil {
+		t.Errorf("An ext which isn't an object should be an error.")
+	}
+}
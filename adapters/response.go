@@ -0,0 +1,35 @@
+package adapters
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ReadResponseBody reads the body of the external server's response. If maxBytes is positive, responses larger than
+// that are dropped with a ResponseTooLargeError, and no more than maxBytes+1 bytes of them are read.
+//
+// The caller is still responsible for closing the body.
+func ReadResponseBody(resp *http.Response, maxBytes int) ([]byte, error) {
+	if maxBytes <= 0 {
+		return ioutil.ReadAll(resp.Body)
+	}
+	if resp.ContentLength > int64(maxBytes) {
+		return nil, responseTooLarge(maxBytes)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBytes {
+		return nil, responseTooLarge(maxBytes)
+	}
+	return body, nil
+}
+
+func responseTooLarge(maxBytes int) error {
+	return &ResponseTooLargeError{
+		Message: fmt.Sprintf("The response was larger than the limit of %d bytes, so it was dropped.", maxBytes),
+	}
+}
@@ -0,0 +1,54 @@
+package adapters
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadResponseBody(t *testing.T) {
+	testCases := []struct {
+		description   string
+		body          string
+		contentLength int64
+		maxBytes      int
+		tooLarge      bool
+	}{
+		{description: "no limit", body: "0123456789", contentLength: -1},
+		{description: "under the limit", body: "0123456789", contentLength: -1, maxBytes: 20},
+		{description: "at the limit", body: "0123456789", contentLength: 10, maxBytes: 10},
+		{description: "over the limit", body: "0123456789", contentLength: -1, maxBytes: 9, tooLarge: true},
+		{description: "declared over the limit", body: "", contentLength: 100, maxBytes: 10, tooLarge: true},
+	}
+	for _, test := range testCases {
+		resp := &http.Response{
+			Body:          ioutil.NopCloser(strings.NewReader(test.body)),
+			ContentLength: test.contentLength,
+		}
+		body, err := ReadResponseBody(resp, test.maxBytes)
+		if test.tooLarge {
+			if _, ok := err.(*ResponseTooLargeError); !ok {
+				t.Errorf("%s: expected a ResponseTooLargeError. Got %v", test.description, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.description, err)
+		}
+		if string(body) != test.body {
+			t.Errorf("%s: expected body %q. Got %q", test.description, test.body, string(body))
+		}
+	}
+}
+
+func TestReadResponseBodyStopsAtLimit(t *testing.T) {
+	reader := strings.NewReader(strings.Repeat("x", 1000))
+	resp := &http.Response{Body: ioutil.NopCloser(reader), ContentLength: -1}
+	if _, err := ReadResponseBody(resp, 10); err == nil {
+		t.Errorf("The oversized response should be rejected.")
+	}
+	if remaining := reader.Len(); remaining != 1000-11 {
+		t.Errorf("Only one byte past the limit should be read. %d bytes were left, expected %d", remaining, 1000-11)
+	}
+}
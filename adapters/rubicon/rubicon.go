@@ -9,7 +9,7 @@ import (
 	"net/http"
 	"net/url"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 
 	"github.com/prebid/prebid-server/pbs"
 
@@ -443,6 +443,7 @@ func (a *RubiconAdapter) Call(ctx context.Context, req *pbs.PBSRequest, bidder *
 	ch := make(chan adapters.CallOneResult)
 	for _, obj := range callOneObjects {
 		go func(bidder *pbs.PBSBidder, reqJSON bytes.Buffer, mediaType pbs.MediaType) {
+			defer adapters.RecoverCallOne(ch)
 			result, err := a.callOne(ctx, reqJSON)
 			result.Error = err
 			if result.Bid != nil {
@@ -485,9 +486,7 @@ func (a *RubiconAdapter) Call(ctx context.Context, req *pbs.PBSRequest, bidder *
 			bidder.Debug = append(bidder.Debug, debug)
 		}
 		if result.Error != nil {
-			if glog.V(2) {
-				glog.Infof("Error from rubicon adapter: %v", result.Error)
-			}
+			logger.Debugf("Error from rubicon adapter: %v", result.Error)
 			err = result.Error
 		}
 	}
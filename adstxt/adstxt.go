@@ -0,0 +1,213 @@
+// Package adstxt checks that bidders are authorized to sell a publisher's inventory, using the publisher's ads.txt
+// and the bidders' sellers.json files. Buyers increasingly refuse to pay for unauthorized inventory, so these checks
+// let hosts find, or stop, the bidders which would be spending the auction's time for nothing.
+package adstxt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// maxFileBytes limits how much of each file is read, since the publisher domains come from the requests.
+const maxFileBytes = 10 << 20
+
+// Validator decides which bidders are authorized to sell a publisher's inventory.
+//
+// Checks never hold up an auction. The ads.txt files which aren't cached are fetched in the background,
+// so the bidders in the first requests for each domain are always authorized.
+//
+// All functions on this struct are nil-safe. A nil Validator authorizes every bidder.
+type Validator struct {
+	client  *http.Client
+	timeout time.Duration
+	refresh time.Duration
+	block   bool
+	bidders map[string]config.AdsTxtBidder
+	cache   *recordsCache
+
+	lock     sync.Mutex
+	fetching map[string]bool
+	// fetches tracks the background fetches, so that the tests can wait for them.
+	fetches sync.WaitGroup
+
+	sellersLock sync.RWMutex
+	// sellers are the seller IDs in each bidder's sellers.json, once it's been fetched.
+	sellers map[string]map[string]bool
+}
+
+// NewValidator returns a Validator which checks the bidders in cfg, or nil if the checks are off.
+// The bidders' sellers.json files are fetched in the background, and again every cfg.RefreshHours.
+func NewValidator(cfg *config.AdsTxt, client *http.Client) *Validator {
+	if !cfg.Enabled {
+		return nil
+	}
+	v := newValidator(cfg, client)
+	go v.refreshSellers(time.Tick(v.refresh))
+	return v
+}
+
+func newValidator(cfg *config.AdsTxt, client *http.Client) *Validator {
+	bidders := make(map[string]config.AdsTxtBidder, len(cfg.Bidders))
+	for name, bidder := range cfg.Bidders {
+		bidder.Domain = strings.ToLower(bidder.Domain)
+		bidders[name] = bidder
+	}
+	refresh := time.Duration(cfg.RefreshHours) * time.Hour
+	return &Validator{
+		client:   client,
+		timeout:  time.Duration(cfg.TimeoutMillis) * time.Millisecond,
+		refresh:  refresh,
+		block:    cfg.Action == config.AdsTxtBlock,
+		bidders:  bidders,
+		cache:    newRecordsCache(cfg.MaxDomains, refresh),
+		fetching: make(map[string]bool),
+		sellers:  make(map[string]map[string]bool, len(bidders)),
+	}
+}
+
+// Blocks returns true if the bidders which aren't authorized shouldn't be called.
+func (v *Validator) Blocks() bool {
+	return v != nil && v.block
+}
+
+// Authorized returns false if the domain's ads.txt doesn't list the bidder, or only lists it with seller IDs
+// which aren't in the bidder's sellers.json. Bidders are authorized if there isn't enough data to say otherwise.
+func (v *Validator) Authorized(domain string, bidder string) bool {
+	if v == nil || domain == "" {
+		return true
+	}
+	bidderCfg, ok := v.bidders[bidder]
+	if !ok {
+		return true
+	}
+	domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+	published, ok, stale := v.cache.get(domain)
+	if !ok || stale {
+		v.fetchInBackground(domain)
+	}
+	if published == nil {
+		return true
+	}
+	sellerIDs := published[bidderCfg.Domain]
+	if len(sellerIDs) == 0 {
+		return false
+	}
+
+	v.sellersLock.RLock()
+	known, ok := v.sellers[bidder]
+	v.sellersLock.RUnlock()
+	if !ok {
+		return true
+	}
+	for sellerID := range sellerIDs {
+		if known[sellerID] {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchInBackground fetches the domain's ads.txt and caches it, unless it's already being fetched.
+func (v *Validator) fetchInBackground(domain string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.fetching[domain] {
+		return
+	}
+	v.fetching[domain] = true
+	v.fetches.Add(1)
+	go func() {
+		defer v.fetches.Done()
+		published, err := v.fetchAdsTxt(domain)
+		if err != nil {
+			// Failed fetches aren't cached, so that they're tried again by the next request for the domain.
+			logger.With("domain", domain).Warningf("Failed to fetch the ads.txt: %v", err)
+		} else {
+			v.cache.put(domain, published)
+		}
+		v.lock.Lock()
+		delete(v.fetching, domain)
+		v.lock.Unlock()
+	}()
+}
+
+// fetchAdsTxt returns the records in the domain's ads.txt. They're nil if the domain doesn't have one,
+// or if it doesn't have any records, since some sites answer every path with an HTML page.
+func (v *Validator) fetchAdsTxt(domain string) (records, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	resp, err := ctxhttp.Get(ctx, v.client, "https://"+domain+"/ads.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		published, err := parseAdsTxt(io.LimitReader(resp.Body, maxFileBytes))
+		if err != nil {
+			return nil, err
+		}
+		if len(published) == 0 {
+			return nil, nil
+		}
+		return published, nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("the site responded with status %d", resp.StatusCode)
+	}
+}
+
+// refreshSellers updates the bidders' sellers.json files now, and then on every tick.
+func (v *Validator) refreshSellers(ticker <-chan time.Time) {
+	v.updateSellers()
+	for range ticker {
+		v.updateSellers()
+	}
+}
+
+// updateSellers fetches each bidder's sellers.json. If a fetch fails, the bidder's previous seller IDs are kept.
+func (v *Validator) updateSellers() {
+	for bidder, bidderCfg := range v.bidders {
+		if bidderCfg.SellersJSON == "" {
+			continue
+		}
+		sellerIDs, err := v.fetchSellers(bidderCfg.SellersJSON)
+		if err != nil {
+			logger.With("bidder", bidder).Warningf("Failed to fetch the sellers.json: %v", err)
+			continue
+		}
+		v.sellersLock.Lock()
+		v.sellers[bidder] = sellerIDs
+		v.sellersLock.Unlock()
+	}
+}
+
+func (v *Validator) fetchSellers(url string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	resp, err := ctxhttp.Get(ctx, v.client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the server responded with status %d", resp.StatusCode)
+	}
+	sellerIDs, err := parseSellersJSON(io.LimitReader(resp.Body, maxFileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("the response wasn't a valid sellers.json: %v", err)
+	}
+	return sellerIDs, nil
+}
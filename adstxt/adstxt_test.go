@@ -0,0 +1,211 @@
+package adstxt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestParseAdsTxt(t *testing.T) {
+	file := `# ads.txt for example.com
+contact=ads@example.com
+AppNexus.com, 1001, DIRECT, f5ab79cb980f11d1 # our main account
+appnexus.com,2002,RESELLER
+rubiconproject.com, 3003
+, 4004, DIRECT
+pubmatic.com, , DIRECT
+openx.com, 5005, DIRECT, 6a698e2ec38604c6; extension=1
+`
+	published, err := parseAdsTxt(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the ads.txt: %v", err)
+	}
+	expected := records{
+		"appnexus.com": {"1001": true, "2002": true},
+		"openx.com":    {"5005": true},
+	}
+	if len(published) != len(expected) {
+		t.Fatalf("Expected the records %v. Got %v", expected, published)
+	}
+	for domain, sellerIDs := range expected {
+		for sellerID := range sellerIDs {
+			if !published[domain][sellerID] {
+				t.Errorf("Expected a record for %s, %s. Got %v", domain, sellerID, published[domain])
+			}
+		}
+	}
+}
+
+func TestParseSellersJSON(t *testing.T) {
+	sellerIDs, err := parseSellersJSON(strings.NewReader(`{"version":"1.0","sellers":[{"seller_id":"1001","seller_type":"PUBLISHER","domain":"example.com"},{"seller_id":""}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the sellers.json: %v", err)
+	}
+	if len(sellerIDs) != 1 || !sellerIDs["1001"] {
+		t.Errorf("Expected the seller IDs [1001]. Got %v", sellerIDs)
+	}
+	if _, err := parseSellersJSON(strings.NewReader(`<html></html>`)); err == nil {
+		t.Errorf("Files which aren't JSON should be rejected.")
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	server := newFileServer()
+	defer server.Close()
+	validator := newTestValidator(server)
+	validator.updateSellers()
+
+	for _, bidder := range []string{"appnexus", "rubicon"} {
+		if !validator.Authorized("example.com", bidder) {
+			t.Errorf("%s should be authorized until the publisher's ads.txt has been fetched.", bidder)
+		}
+	}
+	for _, domain := range []string{"reseller.com", "nofile.com", "htmlpage.com"} {
+		validator.Authorized(domain, "appnexus")
+	}
+	validator.fetches.Wait()
+
+	tests := []struct {
+		domain     string
+		bidder     string
+		authorized bool
+	}{
+		{"example.com", "appnexus", true},
+		{"WWW.Example.com", "appnexus", true},
+		{"example.com", "rubicon", false},
+		{"example.com", "openx", true},
+		{"example.com", "pubmatic", true},
+		{"reseller.com", "appnexus", false},
+		{"reseller.com", "rubicon", false},
+		{"nofile.com", "rubicon", true},
+		{"htmlpage.com", "rubicon", true},
+		{"", "rubicon", true},
+	}
+	for _, test := range tests {
+		if actual := validator.Authorized(test.domain, test.bidder); actual != test.authorized {
+			t.Errorf("%s on %s: expected authorized to be %t. Got %t", test.bidder, test.domain, test.authorized, actual)
+		}
+	}
+	if count := server.fetchCount("example.com/ads.txt"); count != 1 {
+		t.Errorf("The ads.txt should be cached. Got %d fetches", count)
+	}
+	if count := server.fetchCount("nofile.com/ads.txt"); count != 1 {
+		t.Errorf("Domains without an ads.txt should be cached too. Got %d fetches", count)
+	}
+}
+
+func TestAuthorizedRefreshesStaleFiles(t *testing.T) {
+	server := newFileServer()
+	defer server.Close()
+	validator := newTestValidator(server)
+	now := time.Now()
+	validator.cache.now = func() time.Time { return now }
+
+	validator.Authorized("example.com", "rubicon")
+	validator.fetches.Wait()
+	server.setFile("example.com/ads.txt", "rubiconproject.com, 3003, DIRECT\n")
+
+	now = now.Add(24 * time.Hour)
+	if validator.Authorized("example.com", "rubicon") {
+		t.Errorf("The stale ads.txt should be used until it's been fetched again.")
+	}
+	validator.fetches.Wait()
+	if !validator.Authorized("example.com", "rubicon") {
+		t.Errorf("The ads.txt should be fetched again once it's stale.")
+	}
+}
+
+func TestNilValidator(t *testing.T) {
+	validator := NewValidator(&config.AdsTxt{Action: config.AdsTxtBlock}, http.DefaultClient)
+	if validator != nil {
+		t.Fatalf("A disabled Validator should be nil.")
+	}
+	if !validator.Authorized("example.com", "appnexus") || validator.Blocks() {
+		t.Errorf("A nil Validator should authorize every bidder, and never block them.")
+	}
+}
+
+func newTestValidator(server *fileServer) *Validator {
+	cfg := &config.AdsTxt{
+		Enabled:       true,
+		Action:        config.AdsTxtAnnotate,
+		RefreshHours:  24,
+		TimeoutMillis: 1000,
+		MaxDomains:    100,
+		Bidders: map[string]config.AdsTxtBidder{
+			"appnexus": {Domain: "AppNexus.com", SellersJSON: "https://appnexus.com/sellers.json"},
+			"rubicon":  {Domain: "rubiconproject.com"},
+			"pubmatic": {Domain: "pubmatic.com", SellersJSON: "https://pubmatic.com/broken.json"},
+		},
+	}
+	return newValidator(cfg, server.client())
+}
+
+// fileServer serves the files for every domain, keyed by the domain and path. Requests for other files get a 404.
+type fileServer struct {
+	*httptest.Server
+	lock    sync.Mutex
+	files   map[string]string
+	fetches map[string]int
+}
+
+func newFileServer() *fileServer {
+	server := &fileServer{
+		files: map[string]string{
+			"example.com/ads.txt":       "appnexus.com, 1001, DIRECT\npubmatic.com, 5005, DIRECT\n",
+			"reseller.com/ads.txt":      "appnexus.com, 9999, RESELLER\n",
+			"htmlpage.com/ads.txt":      "<html><body>Welcome!</body></html>",
+			"appnexus.com/sellers.json": `{"sellers":[{"seller_id":"1001"},{"seller_id":"2002"}]}`,
+			"pubmatic.com/broken.json":  `{"sellers":`,
+		},
+		fetches: make(map[string]int),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Host + r.URL.Path
+		server.lock.Lock()
+		server.fetches[key]++
+		file, ok := server.files[key]
+		server.lock.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(file))
+	}))
+	return server
+}
+
+func (s *fileServer) setFile(key string, file string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.files[key] = file
+}
+
+func (s *fileServer) fetchCount(key string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.fetches[key]
+}
+
+// client sends every request to the server, with the original domain in the Host header.
+func (s *fileServer) client() *http.Client {
+	serverURL, _ := url.Parse(s.URL)
+	return &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		redirected := *r
+		redirected.URL = &url.URL{Scheme: serverURL.Scheme, Host: serverURL.Host, Path: r.URL.Path}
+		redirected.Host = r.URL.Host
+		return http.DefaultTransport.RoundTrip(&redirected)
+	})}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
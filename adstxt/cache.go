@@ -0,0 +1,74 @@
+package adstxt
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// recordsCache is a least-recently-used cache of the publishers' ads.txt records, keyed by domain.
+// Entries don't expire. Instead, they're reported as stale once they're older than the refresh interval,
+// so that they can still be used while they're fetched again.
+type recordsCache struct {
+	mutex   sync.Mutex
+	size    int
+	refresh time.Duration
+	entries map[string]*list.Element
+	// recent orders the entries from most to least recently used.
+	recent *list.List
+	now    func() time.Time
+}
+
+type recordsCacheEntry struct {
+	domain  string
+	records records
+	fetched time.Time
+}
+
+// newRecordsCache makes a cache which holds up to size domains, whose records are stale after refresh.
+func newRecordsCache(size int, refresh time.Duration) *recordsCache {
+	return &recordsCache{
+		size:    size,
+		refresh: refresh,
+		entries: make(map[string]*list.Element, size),
+		recent:  list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the domain's records, which are nil if the domain doesn't have an ads.txt.
+// The first bool is false if the domain isn't cached, and the second is true if its records should be fetched again.
+func (c *recordsCache) get(domain string) (records, bool, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[domain]
+	if !ok {
+		return nil, false, false
+	}
+	c.recent.MoveToFront(element)
+	entry := element.Value.(*recordsCacheEntry)
+	return entry.records, true, !c.now().Before(entry.fetched.Add(c.refresh))
+}
+
+func (c *recordsCache) put(domain string, records records) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &recordsCacheEntry{
+		domain:  domain,
+		records: records,
+		fetched: c.now(),
+	}
+	if element, ok := c.entries[domain]; ok {
+		element.Value = entry
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.entries[domain] = c.recent.PushFront(entry)
+	for c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*recordsCacheEntry).domain)
+	}
+}
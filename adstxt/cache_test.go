@@ -0,0 +1,44 @@
+package adstxt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordsCacheRefresh(t *testing.T) {
+	now := time.Now()
+	cache := newRecordsCache(10, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	cache.put("example.com", records{"appnexus.com": {"1001": true}})
+	cache.put("unlisted.com", nil)
+	if published, ok, stale := cache.get("example.com"); !ok || stale || !published["appnexus.com"]["1001"] {
+		t.Errorf("The records should be cached. Got %v, %t, %t", published, ok, stale)
+	}
+	if published, ok, _ := cache.get("unlisted.com"); !ok || published != nil {
+		t.Errorf("Domains without an ads.txt should be cached without records. Got %v, %t", published, ok)
+	}
+
+	now = now.Add(time.Hour)
+	if published, ok, stale := cache.get("example.com"); !ok || !stale || published == nil {
+		t.Errorf("The records should still be used, but be stale after the refresh interval. Got %v, %t, %t", published, ok, stale)
+	}
+}
+
+func TestRecordsCacheEviction(t *testing.T) {
+	cache := newRecordsCache(2, time.Hour)
+	cache.put("first.com", records{})
+	cache.put("second.com", records{})
+	cache.get("first.com")
+	cache.put("third.com", records{})
+
+	if _, ok, _ := cache.get("second.com"); ok {
+		t.Errorf("The least recently used domain should be dropped.")
+	}
+	if _, ok, _ := cache.get("first.com"); !ok {
+		t.Errorf("Recently used domains should be kept.")
+	}
+	if _, ok, _ := cache.get("third.com"); !ok {
+		t.Errorf("New domains should be kept.")
+	}
+}
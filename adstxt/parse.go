@@ -0,0 +1,62 @@
+package adstxt
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// records are the sellers which a publisher's ads.txt authorizes. They're keyed by the advertising system's domain,
+// and then by the publisher's seller ID within that system.
+type records map[string]map[string]bool
+
+// parseAdsTxt reads the records from an ads.txt file, as described in https://iabtechlab.com/ads-txt/.
+// Comments, variables such as "contact=" and malformed lines are skipped. Domains are compared without case.
+func parseAdsTxt(r io.Reader) (records, error) {
+	parsed := make(records)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		// Records have at least the domain, the seller ID and the relationship. Variables don't have any commas.
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		domain := strings.ToLower(strings.TrimSpace(fields[0]))
+		sellerID := strings.TrimSpace(fields[1])
+		if domain == "" || sellerID == "" {
+			continue
+		}
+		if parsed[domain] == nil {
+			parsed[domain] = make(map[string]bool)
+		}
+		parsed[domain][sellerID] = true
+	}
+	return parsed, scanner.Err()
+}
+
+// sellersFile is the part of a sellers.json file which is used. See https://iabtechlab.com/sellers-json/.
+type sellersFile struct {
+	Sellers []struct {
+		SellerID string `json:"seller_id"`
+	} `json:"sellers"`
+}
+
+// parseSellersJSON returns the seller IDs in a sellers.json file.
+func parseSellersJSON(r io.Reader) (map[string]bool, error) {
+	var file sellersFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	sellerIDs := make(map[string]bool, len(file.Sellers))
+	for _, seller := range file.Sellers {
+		if seller.SellerID != "" {
+			sellerIDs[seller.SellerID] = true
+		}
+	}
+	return sellerIDs, nil
+}
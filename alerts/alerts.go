@@ -0,0 +1,214 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// webhookTimeout is the longest we'll wait for the webhook to accept an alert.
+const webhookTimeout = 10 * time.Second
+
+// rateSource is the part of the currencies.RateConverter which the Monitor watches.
+type rateSource interface {
+	Active() bool
+	LastUpdated() time.Time
+}
+
+// Monitor checks the host's operational thresholds every so often, and posts an alert to the webhook when one is crossed.
+//
+// It's a MetricsEngine, so that it can count each bidder's requests and errors.
+// It only implements RecordAdapterRequest. The rest of the metrics are ignored.
+type Monitor struct {
+	metricsConf.DummyMetricsEngine
+
+	cfg                config.Alerts
+	client             *http.Client
+	rates              rateSource
+	vendorListFailures func() int
+	now                func() time.Time
+	started            time.Time
+
+	lock     sync.Mutex
+	bidders  map[openrtb_ext.BidderName]*bidderCounts
+	lastSent map[string]time.Time
+}
+
+// bidderCounts are a bidder's requests since the last check.
+type bidderCounts struct {
+	requests int
+	errors   int
+}
+
+// NewMonitor returns a Monitor which checks the thresholds every cfg.CheckIntervalSeconds.
+// vendorListFailures should return the number of GDPR vendor list fetches in a row which have failed.
+func NewMonitor(cfg config.Alerts, client *http.Client, rates *currencies.RateConverter, vendorListFailures func() int) *Monitor {
+	m := newMonitor(cfg, client, rates, vendorListFailures)
+	go m.watch(time.Tick(time.Duration(cfg.CheckIntervalSeconds) * time.Second))
+	return m
+}
+
+func newMonitor(cfg config.Alerts, client *http.Client, rates rateSource, vendorListFailures func() int) *Monitor {
+	return &Monitor{
+		cfg:                cfg,
+		client:             client,
+		rates:              rates,
+		vendorListFailures: vendorListFailures,
+		now:                time.Now,
+		started:            time.Now(),
+		bidders:            make(map[openrtb_ext.BidderName]*bidderCounts),
+		lastSent:           make(map[string]time.Time),
+	}
+}
+
+// RecordAdapterRequest implements a part of the MetricsEngine interface. It counts the bidder's requests and errors.
+func (m *Monitor) RecordAdapterRequest(labels pbsmetrics.AdapterLabels) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	counts, ok := m.bidders[labels.Adapter]
+	if !ok {
+		counts = &bidderCounts{}
+		m.bidders[labels.Adapter] = counts
+	}
+	counts.requests++
+	if len(labels.AdapterErrors) > 0 {
+		counts.errors++
+	}
+}
+
+func (m *Monitor) watch(ticker <-chan time.Time) {
+	for range ticker {
+		m.check()
+	}
+}
+
+// check posts an alert for each threshold which has been crossed, unless it was posted within the cooldown.
+func (m *Monitor) check() {
+	for _, a := range m.alerts() {
+		if m.shouldSend(a.key) {
+			m.send(a.text)
+		}
+	}
+}
+
+type alert struct {
+	// key identifies the alert for the cooldown. Alerts about different bidders have different keys.
+	key  string
+	text string
+}
+
+// alerts returns the thresholds which have been crossed. The bidder counts are reset, so each check covers one interval.
+func (m *Monitor) alerts() []alert {
+	var alerts []alert
+	alerts = append(alerts, m.bidderAlerts()...)
+
+	if hours := m.cfg.StaleCurrencyHours; hours > 0 && m.rates != nil && m.rates.Active() {
+		lastUpdated := m.rates.LastUpdated()
+		since := lastUpdated
+		if since.IsZero() {
+			since = m.started
+		}
+		if m.now().Sub(since) > time.Duration(hours)*time.Hour {
+			text := fmt.Sprintf("The currency conversion rates haven't been updated in over %d hours. They were last updated at %s.", hours, lastUpdated.Format(time.RFC3339))
+			if lastUpdated.IsZero() {
+				text = fmt.Sprintf("The currency conversion rates haven't been fetched in the %d hours since Prebid Server started.", hours)
+			}
+			alerts = append(alerts, alert{key: "stale_currency", text: text})
+		}
+	}
+
+	if threshold := m.cfg.VendorListFailures; threshold > 0 && m.vendorListFailures != nil {
+		if failures := m.vendorListFailures(); failures >= threshold {
+			alerts = append(alerts, alert{
+				key:  "vendor_list",
+				text: fmt.Sprintf("The last %d GDPR vendor list fetches have failed. Cookie syncs and GDPR enforcement may be affected.", failures),
+			})
+		}
+	}
+	return alerts
+}
+
+func (m *Monitor) bidderAlerts() []alert {
+	m.lock.Lock()
+	bidders := m.bidders
+	m.bidders = make(map[openrtb_ext.BidderName]*bidderCounts, len(bidders))
+	m.lock.Unlock()
+
+	threshold := m.cfg.BidderErrorRate.ThresholdPercent
+	if threshold <= 0 {
+		return nil
+	}
+	var alerts []alert
+	for bidder, counts := range bidders {
+		if counts.requests == 0 || counts.requests < m.cfg.BidderErrorRate.MinRequests {
+			continue
+		}
+		rate := 100 * float64(counts.errors) / float64(counts.requests)
+		if rate > threshold {
+			alerts = append(alerts, alert{
+				key:  "bidder_error_rate." + string(bidder),
+				text: fmt.Sprintf("%.0f%% of the requests to %s failed in the last %d seconds (%d of %d). The alert threshold is %g%%.", rate, bidder, m.cfg.CheckIntervalSeconds, counts.errors, counts.requests, threshold),
+			})
+		}
+	}
+	// Map order is random, so sort the alerts to keep them readable.
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].key < alerts[j].key
+	})
+	return alerts
+}
+
+// shouldSend returns true if the alert hasn't been sent within the cooldown, and records that it's being sent now.
+func (m *Monitor) shouldSend(key string) bool {
+	now := m.now()
+	if lastSent, ok := m.lastSent[key]; ok && now.Sub(lastSent) < time.Duration(m.cfg.CooldownSeconds)*time.Second {
+		return false
+	}
+	m.lastSent[key] = now
+	return true
+}
+
+// send posts the alert to the webhook. The body works with Slack's incoming webhooks, and is simple JSON for anything else.
+func (m *Monitor) send(text string) {
+	logger.Warningf("Alert: %s", text)
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{
+		Text: "Prebid Server: " + text,
+	})
+	if err != nil {
+		logger.Errorf("Failed to marshal alert: %v", err)
+		return
+	}
+	req, err := http.NewRequest("POST", m.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		logger.Errorf("Failed to build the alert webhook request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	resp, err := ctxhttp.Do(ctx, m.client, req)
+	if err != nil {
+		logger.Errorf("Error posting an alert to the webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Errorf("The alert webhook returned %d", resp.StatusCode)
+	}
+}
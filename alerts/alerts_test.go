@@ -0,0 +1,148 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+func TestBidderErrorRate(t *testing.T) {
+	var posts []string
+	m, server := newTestMonitor(t, config.Alerts{
+		CheckIntervalSeconds: 60,
+		BidderErrorRate:      config.BidderErrorRateAlert{ThresholdPercent: 50, MinRequests: 4},
+	}, &posts)
+	defer server.Close()
+	recordRequests(m, openrtb_ext.BidderAppnexus, 4, 3)
+	recordRequests(m, openrtb_ext.BidderRubicon, 4, 1)
+	recordRequests(m, openrtb_ext.BidderOpenx, 2, 2)
+
+	m.check()
+	if len(posts) != 1 || !strings.Contains(posts[0], "appnexus") {
+		t.Fatalf("Only the bidder over the threshold, with enough requests, should raise an alert. Got %v", posts)
+	}
+	if !strings.Contains(posts[0], "75%") {
+		t.Errorf("The alert should include the error rate. Got %s", posts[0])
+	}
+
+	recordRequests(m, openrtb_ext.BidderRubicon, 4, 1)
+	m.check()
+	if len(posts) != 1 {
+		t.Errorf("The counts should start again after each check. Got %v", posts)
+	}
+}
+
+func TestCurrencyStaleness(t *testing.T) {
+	var posts []string
+	m, server := newTestMonitor(t, config.Alerts{StaleCurrencyHours: 6}, &posts)
+	defer server.Close()
+	rates := &mockRates{active: true, lastUpdated: m.now().Add(-5 * time.Hour)}
+	m.rates = rates
+
+	m.check()
+	if len(posts) != 0 {
+		t.Errorf("Rates within the threshold shouldn't raise an alert. Got %v", posts)
+	}
+	rates.lastUpdated = m.now().Add(-7 * time.Hour)
+	m.check()
+	if len(posts) != 1 || !strings.Contains(posts[0], "currency") {
+		t.Errorf("Stale rates should raise an alert. Got %v", posts)
+	}
+
+	rates.active = false
+	m.lastSent = make(map[string]time.Time)
+	m.check()
+	if len(posts) != 1 {
+		t.Errorf("Inactive rate converters shouldn't raise alerts. Got %v", posts)
+	}
+}
+
+func TestVendorListFailures(t *testing.T) {
+	var posts []string
+	m, server := newTestMonitor(t, config.Alerts{VendorListFailures: 3}, &posts)
+	defer server.Close()
+	failures := 2
+	m.vendorListFailures = func() int {
+		return failures
+	}
+
+	m.check()
+	if len(posts) != 0 {
+		t.Errorf("Failures under the threshold shouldn't raise an alert. Got %v", posts)
+	}
+	failures = 3
+	m.check()
+	if len(posts) != 1 || !strings.Contains(posts[0], "vendor list") {
+		t.Errorf("Failures at the threshold should raise an alert. Got %v", posts)
+	}
+}
+
+func TestAlertCooldown(t *testing.T) {
+	var posts []string
+	m, server := newTestMonitor(t, config.Alerts{VendorListFailures: 1, CooldownSeconds: 3600}, &posts)
+	defer server.Close()
+	now := time.Now()
+	m.now = func() time.Time {
+		return now
+	}
+	m.vendorListFailures = func() int {
+		return 1
+	}
+
+	m.check()
+	now = now.Add(59 * time.Minute)
+	m.check()
+	if len(posts) != 1 {
+		t.Errorf("The same alert shouldn't be posted again within the cooldown. Got %v", posts)
+	}
+	now = now.Add(time.Minute)
+	m.check()
+	if len(posts) != 2 {
+		t.Errorf("The alert should be posted again after the cooldown. Got %v", posts)
+	}
+}
+
+// newTestMonitor returns a Monitor which posts to a test server. The texts of the alerts are appended to posts.
+func newTestMonitor(t *testing.T, cfg config.Alerts, posts *[]string) (*Monitor, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("The alert body should be JSON. Got error %v", err)
+		}
+		*posts = append(*posts, body.Text)
+	}))
+	cfg.WebhookURL = server.URL
+	return newMonitor(cfg, server.Client(), nil, nil), server
+}
+
+func recordRequests(m *Monitor, bidder openrtb_ext.BidderName, requests int, errors int) {
+	for i := 0; i < requests; i++ {
+		labels := pbsmetrics.AdapterLabels{Adapter: bidder}
+		if i < errors {
+			labels.AdapterErrors = map[pbsmetrics.AdapterError]struct{}{pbsmetrics.AdapterErrorBadServerResponse: {}}
+		}
+		m.RecordAdapterRequest(labels)
+	}
+}
+
+type mockRates struct {
+	active      bool
+	lastUpdated time.Time
+}
+
+func (r *mockRates) Active() bool {
+	return r.active
+}
+
+func (r *mockRates) LastUpdated() time.Time {
+	return r.lastUpdated
+}
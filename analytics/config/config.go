@@ -1,20 +1,49 @@
 package config
 
 import (
-	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/analytics/filesystem"
+	"github.com/prebid/prebid-server/analytics/firehose"
+	"github.com/prebid/prebid-server/analytics/kafka"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/logger"
 )
 
 //Modules that need to be logged to need to be initialized here
-func NewPBSAnalytics(analytics *config.Analytics) analytics.PBSAnalyticsModule {
+func NewPBSAnalytics(analytics *config.Analytics, permissions gdpr.Permissions) analytics.PBSAnalyticsModule {
 	modules := make(enabledAnalytics, 0)
 	if len(analytics.File.Filename) > 0 {
 		if mod, err := filesystem.NewFileLogger(analytics.File.Filename); err == nil {
 			modules = append(modules, mod)
 		} else {
-			glog.Fatalf("Could not initialize FileLogger for file %v :%v", analytics.File.Filename, err)
+			logger.Fatalf("Could not initialize FileLogger for file %v :%v", analytics.File.Filename, err)
+		}
+	}
+	if len(analytics.Kafka.Brokers) > 0 {
+		if mod, err := kafka.NewKafkaLogger(&analytics.Kafka); err == nil {
+			modules = append(modules, mod)
+		} else {
+			logger.Fatalf("Could not initialize KafkaLogger for brokers %v :%v", analytics.Kafka.Brokers, err)
+		}
+	}
+	if len(analytics.Firehose.StreamName) > 0 {
+		if mod, err := firehose.NewFirehoseLogger(&analytics.Firehose); err == nil {
+			modules = append(modules, mod)
+		} else {
+			logger.Fatalf("Could not initialize FirehoseLogger for stream %v :%v", analytics.Firehose.StreamName, err)
+		}
+	}
+	// Every module shares the same sampler, so they all log the same auctions.
+	// Personal data is scrubbed from the auctions before any module sees them.
+	s := newSampler(&analytics.Sampling)
+	for i, mod := range modules {
+		modules[i] = &sampledModule{
+			module: &scrubbedModule{
+				module:      mod,
+				permissions: permissions,
+			},
+			sampler: s,
 		}
 	}
 	return modules
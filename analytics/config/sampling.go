@@ -0,0 +1,146 @@
+package config
+
+import (
+	"hash/fnv"
+	"math"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+)
+
+const (
+	channelWeb   = "web"
+	channelApp   = "app"
+	channelAmp   = "amp"
+	channelVideo = "video"
+
+	defaultChannel = "default"
+)
+
+// sampler decides which auctions get logged.
+//
+// The decision is made by hashing the auction ID, so the same auction is either logged by every module or by none of them,
+// and any events which are joined on the auction ID later on are sampled together.
+type sampler struct {
+	rates    config.SamplingRates
+	accounts map[string]config.SamplingRates
+}
+
+func newSampler(cfg *config.AnalyticsSampling) *sampler {
+	accounts := make(map[string]config.SamplingRates, len(cfg.Accounts))
+	for _, account := range cfg.Accounts {
+		accounts[account.ID] = account.Rates
+	}
+	return &sampler{
+		rates:    cfg.Rates,
+		accounts: accounts,
+	}
+}
+
+// rate returns the fraction of auctions which should be logged for this account and channel.
+func (s *sampler) rate(account string, channel string) float64 {
+	if rates, ok := s.accounts[account]; ok {
+		if rate, ok := lookupRate(rates, channel); ok {
+			return rate
+		}
+	}
+	if rate, ok := lookupRate(s.rates, channel); ok {
+		return rate
+	}
+	return 1
+}
+
+func lookupRate(rates config.SamplingRates, channel string) (float64, bool) {
+	if rate, ok := rates[channel]; ok {
+		return rate, true
+	}
+	rate, ok := rates[defaultChannel]
+	return rate, ok
+}
+
+// sampled returns true if the auction with this ID falls within the sample.
+func sampled(auctionID string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(auctionID))
+	return float64(h.Sum64())/math.MaxUint64 < rate
+}
+
+// sampledModule only passes on the auctions which fall within the sample, after recording the sample rate on them.
+// Cookie syncs and setuid calls have no auction to sample, so they're all passed through.
+type sampledModule struct {
+	module  analytics.PBSAnalyticsModule
+	sampler *sampler
+}
+
+func (m *sampledModule) LogAuctionObject(ao *analytics.AuctionObject) {
+	if ao != nil {
+		rate := m.sampler.rate(accountID(ao.Request), auctionChannel(ao.Request))
+		if !sampled(auctionID(ao.Request), rate) {
+			return
+		}
+		ao.SampleRate = rate
+	}
+	m.module.LogAuctionObject(ao)
+}
+
+func (m *sampledModule) LogAmpObject(ao *analytics.AmpObject) {
+	if ao != nil {
+		rate := m.sampler.rate(accountID(ao.Request), channelAmp)
+		if !sampled(auctionID(ao.Request), rate) {
+			return
+		}
+		ao.SampleRate = rate
+	}
+	m.module.LogAmpObject(ao)
+}
+
+func (m *sampledModule) LogCookieSyncObject(cso *analytics.CookieSyncObject) {
+	m.module.LogCookieSyncObject(cso)
+}
+
+func (m *sampledModule) LogSetUIDObject(so *analytics.SetUIDObject) {
+	m.module.LogSetUIDObject(so)
+}
+
+// auctionChannel returns "video" if any of the Imps ask for video, and otherwise "app" or "web" depending on where the ad will show.
+func auctionChannel(request *openrtb.BidRequest) string {
+	if request == nil {
+		return channelWeb
+	}
+	for i := range request.Imp {
+		if request.Imp[i].Video != nil {
+			return channelVideo
+		}
+	}
+	if request.App != nil {
+		return channelApp
+	}
+	return channelWeb
+}
+
+func auctionID(request *openrtb.BidRequest) string {
+	if request == nil {
+		return ""
+	}
+	return request.ID
+}
+
+func accountID(request *openrtb.BidRequest) string {
+	if request == nil {
+		return ""
+	}
+	if request.Site != nil && request.Site.Publisher != nil {
+		return request.Site.Publisher.ID
+	}
+	if request.App != nil && request.App.Publisher != nil {
+		return request.App.Publisher.ID
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestSamplingRateLookup(t *testing.T) {
+	s := newSampler(&config.AnalyticsSampling{
+		Rates: config.SamplingRates{"default": 0.5, "web": 0.05},
+		Accounts: []config.AccountSampling{
+			{ID: "video-only", Rates: config.SamplingRates{"video": 1}},
+			{ID: "everything", Rates: config.SamplingRates{"default": 0.2}},
+		},
+	})
+
+	assertRate(t, s, "unknown", "web", 0.05)
+	assertRate(t, s, "unknown", "app", 0.5)
+	assertRate(t, s, "video-only", "video", 1)
+	assertRate(t, s, "video-only", "web", 0.05)
+	assertRate(t, s, "everything", "web", 0.2)
+
+	if rate := newSampler(&config.AnalyticsSampling{}).rate("unknown", "web"); rate != 1 {
+		t.Errorf("Everything should be logged if no rates are configured. Got %f", rate)
+	}
+}
+
+func TestSamplingIsConsistent(t *testing.T) {
+	sampledCount := 0
+	for i := 0; i < 10000; i++ {
+		id := strconv.Itoa(i)
+		first := sampled(id, 0.1)
+		if first != sampled(id, 0.1) {
+			t.Fatalf("Auction %s was sampled inconsistently", id)
+		}
+		if first {
+			sampledCount++
+		}
+	}
+	if sampledCount < 800 || sampledCount > 1200 {
+		t.Errorf("Expected roughly 10%% of the auctions to be sampled. Got %d of 10000", sampledCount)
+	}
+	if !sampled("any", 1) || sampled("any", 0) {
+		t.Errorf("Rates of 1 and 0 should log everything and nothing.")
+	}
+}
+
+func TestSampledModule(t *testing.T) {
+	var count int
+	mod := &sampledModule{
+		module: &sampleModule{&count},
+		sampler: newSampler(&config.AnalyticsSampling{
+			Rates: config.SamplingRates{"web": 0, "video": 1, "amp": 0.25},
+		}),
+	}
+
+	mod.LogAuctionObject(&analytics.AuctionObject{Request: &openrtb.BidRequest{ID: "web-auction"}})
+	if count != 0 {
+		t.Errorf("Web auctions should not be logged at a rate of 0.")
+	}
+
+	video := &analytics.AuctionObject{Request: &openrtb.BidRequest{ID: "video-auction", Imp: []openrtb.Imp{{Video: &openrtb.Video{}}}}}
+	mod.LogAuctionObject(video)
+	if count != 1 || video.SampleRate != 1 {
+		t.Errorf("Video auctions should be logged with a sample rate of 1. Got count %d, rate %f", count, video.SampleRate)
+	}
+
+	for i := 0; i < 100; i++ {
+		amp := &analytics.AmpObject{Request: &openrtb.BidRequest{ID: strconv.Itoa(i)}}
+		before := count
+		mod.LogAmpObject(amp)
+		if count != before && amp.SampleRate != 0.25 {
+			t.Errorf("Sampled AMP auctions should record the sample rate. Got %f", amp.SampleRate)
+		}
+	}
+
+	before := count
+	mod.LogCookieSyncObject(&analytics.CookieSyncObject{})
+	mod.LogSetUIDObject(&analytics.SetUIDObject{})
+	if count != before+2 {
+		t.Errorf("Cookie syncs and setuid calls should not be sampled.")
+	}
+}
+
+func assertRate(t *testing.T, s *sampler, account string, channel string, expected float64) {
+	t.Helper()
+	if rate := s.rate(account, channel); rate != expected {
+		t.Errorf("Expected a rate of %f for account %s on channel %s. Got %f", expected, account, channel, rate)
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"context"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+// scrubbedModule removes personal data from the auctions before the module logs them, unless the request's
+// consent signals allow the host to keep it. These are the same signals which decide whether the host can set cookies.
+//
+// IPs are masked, the IFA is hashed, and the IDs which other platforms know the user by are removed.
+// The objects are copied first, so the other modules and the endpoints still see the original request.
+type scrubbedModule struct {
+	module      analytics.PBSAnalyticsModule
+	permissions gdpr.Permissions
+}
+
+func (m *scrubbedModule) LogAuctionObject(ao *analytics.AuctionObject) {
+	if ao != nil && m.mustScrub(ao.Request) {
+		clone := *ao
+		clone.Request = scrubRequest(ao.Request)
+		ao = &clone
+	}
+	m.module.LogAuctionObject(ao)
+}
+
+func (m *scrubbedModule) LogAmpObject(ao *analytics.AmpObject) {
+	if ao != nil && m.mustScrub(ao.Request) {
+		clone := *ao
+		clone.Request = scrubRequest(ao.Request)
+		ao = &clone
+	}
+	m.module.LogAmpObject(ao)
+}
+
+func (m *scrubbedModule) LogCookieSyncObject(cso *analytics.CookieSyncObject) {
+	m.module.LogCookieSyncObject(cso)
+}
+
+func (m *scrubbedModule) LogSetUIDObject(so *analytics.SetUIDObject) {
+	m.module.LogSetUIDObject(so)
+}
+
+// mustScrub returns false if GDPR doesn't apply to the request, or if the consent string allows the host to use personal data.
+func (m *scrubbedModule) mustScrub(request *openrtb.BidRequest) bool {
+	if request == nil {
+		return false
+	}
+	signal, consent := gdpr.RequestSignals(request)
+	switch signal {
+	case "0":
+		return false
+	case "1":
+		if consent == "" {
+			return true
+		}
+	}
+	allowed, err := m.permissions.HostCookiesAllowed(context.Background(), consent)
+	return err != nil || !allowed
+}
+
+func scrubRequest(request *openrtb.BidRequest) *openrtb.BidRequest {
+	clone := *request
+	clone.Device = privacy.ScrubDeviceForAnalytics(request.Device)
+	clone.User = privacy.ScrubUserIDs(request.User)
+	return &clone
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestScrubWithoutConsent(t *testing.T) {
+	logged := &lastAuctionModule{}
+	mod := &scrubbedModule{
+		module:      logged,
+		permissions: &fakePermissions{allowHost: false},
+	}
+
+	original := &analytics.AuctionObject{Request: gdprRequest(`{"gdpr":1}`, `{"consent":"some-consent","eids":[{"source":"x.com"}]}`)}
+	mod.LogAuctionObject(original)
+
+	if logged.auction == original {
+		t.Fatalf("The auction object should be copied before it's scrubbed.")
+	}
+	request := logged.auction.Request
+	if request.Device.IP != "1.2.3.0" || request.Device.IFA == "some-ifa" {
+		t.Errorf("The device should be scrubbed. Got %#v", request.Device)
+	}
+	if request.User.BuyerUID != "" || string(request.User.Ext) != `{"consent":"some-consent"}` {
+		t.Errorf("The user IDs should be scrubbed. Got %#v", request.User)
+	}
+	if original.Request.Device.IP != "1.2.3.4" || original.Request.User.BuyerUID != "buyer-id" {
+		t.Errorf("The original request should not be changed.")
+	}
+}
+
+func TestScrubWhenConsentIsMissing(t *testing.T) {
+	logged := &lastAuctionModule{}
+	mod := &scrubbedModule{
+		module:      logged,
+		permissions: &fakePermissions{allowHost: true},
+	}
+
+	mod.LogAmpObject(&analytics.AmpObject{Request: gdprRequest(`{"gdpr":1}`, `{}`)})
+	if logged.amp.Request.Device.IP != "1.2.3.0" {
+		t.Errorf("Requests with gdpr=1 and no consent string should be scrubbed.")
+	}
+}
+
+func TestNoScrubbing(t *testing.T) {
+	logged := &lastAuctionModule{}
+	mod := &scrubbedModule{
+		module:      logged,
+		permissions: &fakePermissions{allowHost: false},
+	}
+	mod.LogAuctionObject(&analytics.AuctionObject{Request: gdprRequest(`{"gdpr":0}`, `{}`)})
+	if logged.auction.Request.Device.IP != "1.2.3.4" {
+		t.Errorf("Requests which GDPR doesn't apply to should not be scrubbed.")
+	}
+
+	mod.permissions = &fakePermissions{allowHost: true}
+	mod.LogAuctionObject(&analytics.AuctionObject{Request: gdprRequest(`{"gdpr":1}`, `{"consent":"some-consent"}`)})
+	if logged.auction.Request.Device.IP != "1.2.3.4" {
+		t.Errorf("Requests which consent to the host should not be scrubbed.")
+	}
+}
+
+func gdprRequest(regsExt string, userExt string) *openrtb.BidRequest {
+	return &openrtb.BidRequest{
+		ID:     "some-request",
+		Regs:   &openrtb.Regs{Ext: openrtb.RawJSON(regsExt)},
+		Device: &openrtb.Device{IP: "1.2.3.4", IFA: "some-ifa"},
+		User:   &openrtb.User{BuyerUID: "buyer-id", Ext: openrtb.RawJSON(userExt)},
+	}
+}
+
+type lastAuctionModule struct {
+	auction *analytics.AuctionObject
+	amp     *analytics.AmpObject
+}
+
+func (m *lastAuctionModule) LogAuctionObject(ao *analytics.AuctionObject) { m.auction = ao }
+
+func (m *lastAuctionModule) LogCookieSyncObject(cso *analytics.CookieSyncObject) {}
+
+func (m *lastAuctionModule) LogSetUIDObject(so *analytics.SetUIDObject) {}
+
+func (m *lastAuctionModule) LogAmpObject(ao *analytics.AmpObject) { m.amp = ao }
+
+type fakePermissions struct {
+	allowHost bool
+}
+
+func (p *fakePermissions) HostCookiesAllowed(ctx context.Context, consent string) (bool, error) {
+	return p.allowHost, nil
+}
+
+func (p *fakePermissions) BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
+	return true, nil
+}
+
+func (p *fakePermissions) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (gdpr.AuctionPermissions, error) {
+	return gdpr.AllowAll, nil
+}
@@ -26,6 +26,11 @@ type AuctionObject struct {
 	Errors   []error
 	Request  *openrtb.BidRequest
 	Response *openrtb.BidResponse
+	// SampleRate is the fraction of similar auctions which are being logged. Modules should record it
+	// so that downstream aggregations can scale the counts back up.
+	SampleRate float64
+	// InvalidTraffic is why the request looks like bot traffic, or empty if it doesn't.
+	InvalidTraffic string
 }
 
 //Loggable object of a transaction at /openrtb2/amp endpoint
@@ -36,6 +41,10 @@ type AmpObject struct {
 	AuctionResponse    *openrtb.BidResponse
 	AmpTargetingValues map[string]string
 	Origin             string
+	// SampleRate is the fraction of similar auctions which are being logged.
+	SampleRate float64
+	// InvalidTraffic is why the request looks like bot traffic, or empty if it doesn't.
+	InvalidTraffic string
 }
 
 //Loggable object of a transaction at /setuid
@@ -0,0 +1,280 @@
+package firehose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+)
+
+type eventType string
+
+const (
+	AUCTION eventType = "auction"
+	AMP     eventType = "amp"
+)
+
+// recordBatchPutter is the part of the Firehose API which we use.
+type recordBatchPutter interface {
+	PutRecordBatch(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error)
+}
+
+// FirehoseLogger is an analytics module which sends auction events to a Kinesis Firehose delivery stream.
+//
+// Only auctions from opted-in accounts are logged. Events are batched up by a background goroutine,
+// and records which Firehose rejects are retried with exponential backoff. If the batcher falls behind
+// and its buffer fills up, new events are dropped so that the auctions aren't slowed down.
+type FirehoseLogger struct {
+	client        recordBatchPutter
+	streamName    string
+	accounts      map[string]struct{}
+	gzip          bool
+	batchSize     int
+	batchBytes    int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+	sleep         func(time.Duration)
+
+	records chan []byte
+	done    chan struct{}
+	dropped uint64
+}
+
+type event struct {
+	Type           eventType            `json:"type"`
+	Timestamp      int64                `json:"timestamp"`
+	Account        string               `json:"account"`
+	Status         int                  `json:"status"`
+	Errors         []string             `json:"errors,omitempty"`
+	SampleRate     float64              `json:"sample_rate"`
+	InvalidTraffic string               `json:"invalid_traffic,omitempty"`
+	Request        *openrtb.BidRequest  `json:"request"`
+	Response       *openrtb.BidResponse `json:"response"`
+}
+
+// NewFirehoseLogger makes a client for the delivery stream, and starts the goroutine which sends the batches.
+func NewFirehoseLogger(cfg *config.FirehoseLogs) (analytics.PBSAnalyticsModule, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.Region),
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger := newFirehoseLogger(firehose.New(sess), cfg)
+	go logger.run()
+	return logger, nil
+}
+
+func newFirehoseLogger(client recordBatchPutter, cfg *config.FirehoseLogs) *FirehoseLogger {
+	accounts := make(map[string]struct{}, len(cfg.Accounts))
+	for _, account := range cfg.Accounts {
+		accounts[account] = struct{}{}
+	}
+	return &FirehoseLogger{
+		client:        client,
+		streamName:    cfg.StreamName,
+		accounts:      accounts,
+		gzip:          cfg.Gzip,
+		batchSize:     cfg.BatchSize,
+		batchBytes:    cfg.BatchBytes,
+		flushInterval: time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+		maxRetries:    cfg.MaxRetries,
+		retryBackoff:  time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+		sleep:         time.Sleep,
+		records:       make(chan []byte, cfg.BufferSize),
+		done:          make(chan struct{}),
+	}
+}
+
+// Sends the AuctionObject to Firehose, if the publisher has opted in
+func (f *FirehoseLogger) LogAuctionObject(ao *analytics.AuctionObject) {
+	if ao == nil {
+		return
+	}
+	f.logAuction(AUCTION, ao.Status, ao.Errors, ao.SampleRate, ao.InvalidTraffic, ao.Request, ao.Response)
+}
+
+// Sends the AmpObject to Firehose, if the publisher has opted in
+func (f *FirehoseLogger) LogAmpObject(ao *analytics.AmpObject) {
+	if ao == nil {
+		return
+	}
+	f.logAuction(AMP, ao.Status, ao.Errors, ao.SampleRate, ao.InvalidTraffic, ao.Request, ao.AuctionResponse)
+}
+
+// Only auction events are sent to Firehose
+func (f *FirehoseLogger) LogCookieSyncObject(cso *analytics.CookieSyncObject) {
+}
+
+// Only auction events are sent to Firehose
+func (f *FirehoseLogger) LogSetUIDObject(so *analytics.SetUIDObject) {
+}
+
+func (f *FirehoseLogger) logAuction(typ eventType, status int, errs []error, sampleRate float64, invalidTraffic string, request *openrtb.BidRequest, response *openrtb.BidResponse) {
+	account := accountID(request)
+	if _, ok := f.accounts[account]; !ok {
+		return
+	}
+	record, err := f.makeRecord(&event{
+		Type:           typ,
+		Timestamp:      time.Now().UnixNano() / int64(time.Millisecond),
+		Account:        account,
+		Status:         status,
+		Errors:         errsToStrings(errs),
+		SampleRate:     sampleRate,
+		InvalidTraffic: invalidTraffic,
+		Request:        request,
+		Response:       response,
+	})
+	if err != nil {
+		logger.Errorf("Failed to build the %s analytics record: %v", typ, err)
+		return
+	}
+
+	select {
+	case f.records <- record:
+	default:
+		// Log the first drop, and then every thousandth, so that a slow stream doesn't flood the logs too.
+		if dropped := atomic.AddUint64(&f.dropped, 1); dropped%1000 == 1 {
+			logger.Warningf("The Firehose analytics buffer is full. %d events have been dropped so far.", dropped)
+		}
+	}
+}
+
+// makeRecord writes the event as a line of JSON, so that the records can be concatenated by the delivery stream.
+// Each record is compressed on its own, since concatenated gzip members are still a valid gzip file.
+func (f *FirehoseLogger) makeRecord(e *event) ([]byte, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	if !f.gzip {
+		return b, nil
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// run collects records into batches until the records channel is closed.
+// A batch is sent when it is full, or when the flush interval passes.
+func (f *FirehoseLogger) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, f.batchSize)
+	batchBytes := 0
+	for {
+		select {
+		case record, ok := <-f.records:
+			if !ok {
+				f.send(batch)
+				return
+			}
+			if len(batch) > 0 && batchBytes+len(record) > f.batchBytes {
+				f.send(batch)
+				batch = make([][]byte, 0, f.batchSize)
+				batchBytes = 0
+			}
+			batch = append(batch, record)
+			batchBytes += len(record)
+			if len(batch) >= f.batchSize {
+				f.send(batch)
+				batch = make([][]byte, 0, f.batchSize)
+				batchBytes = 0
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				f.send(batch)
+				batch = make([][]byte, 0, f.batchSize)
+				batchBytes = 0
+			}
+		}
+	}
+}
+
+// send puts the records to the delivery stream, retrying any which fail with exponential backoff.
+func (f *FirehoseLogger) send(records [][]byte) {
+	for attempt := 0; len(records) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > f.maxRetries {
+				logger.Errorf("Dropping %d analytics records after %d retries to Firehose stream %s", len(records), f.maxRetries, f.streamName)
+				return
+			}
+			f.sleep(f.retryBackoff << uint(attempt-1))
+		}
+		records = f.putBatch(records)
+	}
+}
+
+// putBatch makes a single PutRecordBatch call, and returns the records which need to be retried.
+func (f *FirehoseLogger) putBatch(records [][]byte) [][]byte {
+	input := &firehose.PutRecordBatchInput{
+		DeliveryStreamName: aws.String(f.streamName),
+		Records:            make([]*firehose.Record, len(records)),
+	}
+	for i, record := range records {
+		input.Records[i] = &firehose.Record{Data: record}
+	}
+
+	output, err := f.client.PutRecordBatch(input)
+	if err != nil {
+		logger.Warningf("Failed to put %d analytics records to Firehose stream %s: %v", len(records), f.streamName, err)
+		return records
+	}
+	if aws.Int64Value(output.FailedPutCount) == 0 {
+		return nil
+	}
+
+	// The responses line up with the records in the request.
+	failed := make([][]byte, 0, aws.Int64Value(output.FailedPutCount))
+	for i, response := range output.RequestResponses {
+		if response != nil && response.ErrorCode != nil && i < len(records) {
+			failed = append(failed, records[i])
+		}
+	}
+	return failed
+}
+
+func accountID(request *openrtb.BidRequest) string {
+	if request == nil {
+		return ""
+	}
+	if request.Site != nil && request.Site.Publisher != nil {
+		return request.Site.Publisher.ID
+	}
+	if request.App != nil && request.App.Publisher != nil {
+		return request.App.Publisher.ID
+	}
+	return ""
+}
+
+func errsToStrings(errs []error) []string {
+	if len(errs) == 0 {
+		return nil
+	}
+	strs := make([]string, len(errs))
+	for i, err := range errs {
+		strs[i] = err.Error()
+	}
+	return strs
+}
@@ -0,0 +1,274 @@
+package firehose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/firehose"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestOnlyOptedInAccountsAreLogged(t *testing.T) {
+	logger := newFirehoseLogger(&fakeClient{}, testConfig(false))
+
+	logger.LogAuctionObject(&analytics.AuctionObject{
+		Status:     http.StatusOK,
+		Request:    &openrtb.BidRequest{ID: "req-1", Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "opted-in"}}},
+		SampleRate: 0.1,
+	})
+	logger.LogAuctionObject(&analytics.AuctionObject{
+		Request: &openrtb.BidRequest{ID: "req-2", Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "someone-else"}}},
+	})
+	logger.LogAmpObject(&analytics.AmpObject{
+		Request: &openrtb.BidRequest{ID: "req-3", App: &openrtb.App{Publisher: &openrtb.Publisher{ID: "opted-in"}}},
+	})
+	logger.LogAuctionObject(&analytics.AuctionObject{Request: &openrtb.BidRequest{ID: "req-4"}})
+	logger.LogCookieSyncObject(&analytics.CookieSyncObject{})
+	logger.LogSetUIDObject(&analytics.SetUIDObject{})
+
+	records := drain(logger)
+	if len(records) != 2 {
+		t.Fatalf("Only the opted-in account should be logged. Got %d records", len(records))
+	}
+	auction := decodeEvent(t, records[0], false)
+	if auction.Type != AUCTION || auction.Account != "opted-in" || auction.Request.ID != "req-1" || auction.SampleRate != 0.1 {
+		t.Errorf("Bad auction event: %#v", auction)
+	}
+	if amp := decodeEvent(t, records[1], false); amp.Type != AMP || amp.Request.ID != "req-3" {
+		t.Errorf("Bad amp event: %#v", amp)
+	}
+}
+
+func TestGzipRecords(t *testing.T) {
+	logger := newFirehoseLogger(&fakeClient{}, testConfig(true))
+	logger.LogAuctionObject(&analytics.AuctionObject{
+		Errors:  []error{errors.New("some error")},
+		Request: &openrtb.BidRequest{ID: "req-1", Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "opted-in"}}},
+	})
+
+	records := drain(logger)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record. Got %d", len(records))
+	}
+	e := decodeEvent(t, records[0], true)
+	if len(e.Errors) != 1 || e.Errors[0] != "some error" {
+		t.Errorf("The auction errors should be logged as strings. Got %v", e.Errors)
+	}
+}
+
+func TestFullBufferDropsEvents(t *testing.T) {
+	cfg := testConfig(false)
+	cfg.BufferSize = 1
+	logger := newFirehoseLogger(&fakeClient{}, cfg)
+
+	for i := 0; i < 3; i++ {
+		logger.LogAuctionObject(&analytics.AuctionObject{
+			Request: &openrtb.BidRequest{Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "opted-in"}}},
+		})
+	}
+	if records := drain(logger); len(records) != 1 {
+		t.Errorf("Events which don't fit in the buffer should be dropped. Got %d records", len(records))
+	}
+	if logger.dropped != 2 {
+		t.Errorf("Expected 2 dropped events. Got %d", logger.dropped)
+	}
+}
+
+func TestBatching(t *testing.T) {
+	client := &fakeClient{}
+	cfg := testConfig(false)
+	cfg.BatchSize = 2
+	cfg.BatchBytes = 9
+	logger := newFirehoseLogger(client, cfg)
+
+	logger.records <- []byte("aaaa")
+	logger.records <- []byte("bbbb")
+	logger.records <- []byte("cccccccc")
+	logger.records <- []byte("dd")
+	logger.records <- []byte("ee")
+	close(logger.records)
+	logger.run()
+
+	// The first batch is full at two records, and the fourth record would push the second one over the byte limit.
+	assertBatches(t, client.batches(), [][]string{
+		{"aaaa", "bbbb"},
+		{"cccccccc"},
+		{"dd", "ee"},
+	})
+}
+
+func TestRetryFailedRecords(t *testing.T) {
+	client := &fakeClient{
+		responses: []fakeResponse{
+			{err: errors.New("throttled")},
+			{failed: []bool{true, false}},
+			{},
+		},
+	}
+	logger := newFirehoseLogger(client, testConfig(false))
+	var sleeps []time.Duration
+	logger.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+
+	logger.send([][]byte{[]byte("a"), []byte("b")})
+
+	assertBatches(t, client.batches(), [][]string{
+		{"a", "b"},
+		{"a", "b"},
+		{"a"},
+	})
+	if len(sleeps) != 2 || sleeps[0] != 100*time.Millisecond || sleeps[1] != 200*time.Millisecond {
+		t.Errorf("The retries should back off exponentially. Got %v", sleeps)
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	client := &fakeClient{
+		responses: []fakeResponse{
+			{err: errors.New("throttled")},
+			{err: errors.New("throttled")},
+			{err: errors.New("throttled")},
+			{err: errors.New("throttled")},
+			{err: errors.New("throttled")},
+		},
+	}
+	logger := newFirehoseLogger(client, testConfig(false))
+	logger.sleep = func(time.Duration) {}
+
+	logger.send([][]byte{[]byte("a")})
+
+	if calls := len(client.batches()); calls != 4 {
+		t.Errorf("Expected the first attempt and 3 retries. Got %d calls", calls)
+	}
+}
+
+func testConfig(gzip bool) *config.FirehoseLogs {
+	return &config.FirehoseLogs{
+		StreamName:      "auctions",
+		Region:          "us-east-1",
+		Accounts:        []string{"opted-in"},
+		BatchSize:       500,
+		BatchBytes:      4 * 1024 * 1024,
+		FlushIntervalMs: 60000,
+		Gzip:            gzip,
+		MaxRetries:      3,
+		RetryBackoffMs:  100,
+		BufferSize:      10,
+	}
+}
+
+func drain(logger *FirehoseLogger) [][]byte {
+	var records [][]byte
+	for {
+		select {
+		case record := <-logger.records:
+			records = append(records, record)
+		default:
+			return records
+		}
+	}
+}
+
+func decodeEvent(t *testing.T, record []byte, gzipped bool) *event {
+	t.Helper()
+	if gzipped {
+		r, err := gzip.NewReader(bytes.NewReader(record))
+		if err != nil {
+			t.Fatalf("The record was not gzipped: %v", err)
+		}
+		if record, err = ioutil.ReadAll(r); err != nil {
+			t.Fatalf("Failed to gunzip the record: %v", err)
+		}
+	}
+	if record[len(record)-1] != '\n' {
+		t.Errorf("Records should end with a newline.")
+	}
+	var e event
+	if err := json.Unmarshal(record, &e); err != nil {
+		t.Fatalf("Failed to unmarshal the record: %v", err)
+	}
+	return &e
+}
+
+func assertBatches(t *testing.T, actual [][]string, expected [][]string) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d batches. Got %d: %v", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if len(actual[i]) != len(expected[i]) {
+			t.Errorf("Batch %d: expected %v. Got %v", i, expected[i], actual[i])
+			continue
+		}
+		for j := range expected[i] {
+			if actual[i][j] != expected[i][j] {
+				t.Errorf("Batch %d: expected %v. Got %v", i, expected[i], actual[i])
+				break
+			}
+		}
+	}
+}
+
+type fakeResponse struct {
+	err    error
+	failed []bool
+}
+
+// fakeClient records every batch it's given, and replies with the queued responses in order.
+// Once they run out, every record succeeds.
+type fakeClient struct {
+	mu        sync.Mutex
+	responses []fakeResponse
+	calls     [][]string
+}
+
+func (c *fakeClient) PutRecordBatch(input *firehose.PutRecordBatchInput) (*firehose.PutRecordBatchOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batch := make([]string, len(input.Records))
+	for i, record := range input.Records {
+		batch[i] = string(record.Data)
+	}
+	c.calls = append(c.calls, batch)
+
+	var response fakeResponse
+	if len(c.responses) > 0 {
+		response, c.responses = c.responses[0], c.responses[1:]
+	}
+	if response.err != nil {
+		return nil, response.err
+	}
+
+	output := &firehose.PutRecordBatchOutput{
+		RequestResponses: make([]*firehose.PutRecordBatchResponseEntry, len(input.Records)),
+	}
+	var failedCount int64
+	for i := range input.Records {
+		entry := &firehose.PutRecordBatchResponseEntry{RecordId: aws.String("id")}
+		if i < len(response.failed) && response.failed[i] {
+			entry = &firehose.PutRecordBatchResponseEntry{ErrorCode: aws.String("ServiceUnavailableException")}
+			failedCount++
+		}
+		output.RequestResponses[i] = entry
+	}
+	output.FailedPutCount = aws.Int64(failedCount)
+	return output, nil
+}
+
+func (c *fakeClient) batches() [][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
@@ -0,0 +1,230 @@
+package kafka
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/usersync"
+)
+
+type objectType string
+
+const (
+	AUCTION     objectType = "auction"
+	BID         objectType = "bid"
+	AMP         objectType = "amp"
+	COOKIE_SYNC objectType = "cookie_sync"
+	SETUID      objectType = "setuid"
+)
+
+// How long we wait on the schema registry at startup.
+const schemaRegistryTimeout = 10 * time.Second
+
+// KafkaLogger is an analytics module which publishes each object to a Kafka topic.
+//
+// Messages are handed to an async producer, which batches them up. If the producer falls behind
+// and its buffer fills up, new messages are dropped so that the auctions aren't slowed down.
+type KafkaLogger struct {
+	producer   sarama.AsyncProducer
+	serializer serializer
+	topics     config.KafkaTopics
+	dropped    uint64
+}
+
+type auctionPayload struct {
+	Request        *openrtb.BidRequest  `json:"request"`
+	Response       *openrtb.BidResponse `json:"response"`
+	InvalidTraffic string               `json:"invalid_traffic,omitempty"`
+}
+
+type bidPayload struct {
+	RequestID string       `json:"requestid"`
+	Seat      string       `json:"seat"`
+	Bid       *openrtb.Bid `json:"bid"`
+}
+
+type ampPayload struct {
+	Request            *openrtb.BidRequest  `json:"request"`
+	AuctionResponse    *openrtb.BidResponse `json:"response"`
+	AmpTargetingValues map[string]string    `json:"targeting"`
+	Origin             string               `json:"origin"`
+	InvalidTraffic     string               `json:"invalid_traffic,omitempty"`
+}
+
+type cookieSyncPayload struct {
+	BidderStatus []*usersync.CookieSyncBidders `json:"bidder_status"`
+}
+
+type setUIDPayload struct {
+	Bidder  string `json:"bidder"`
+	UID     string `json:"uid"`
+	Success bool   `json:"success"`
+}
+
+// NewKafkaLogger connects to the Kafka brokers, and registers the message schema if Avro is in use.
+func NewKafkaLogger(cfg *config.KafkaLogs) (analytics.PBSAnalyticsModule, error) {
+	var s serializer = jsonSerializer{}
+	if cfg.Serialization == "avro" {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaRegistryTimeout)
+		defer cancel()
+		avro, err := newAvroSerializer(ctx, http.DefaultClient, cfg.SchemaRegistryURL, configuredTopics(&cfg.Topics))
+		if err != nil {
+			return nil, err
+		}
+		s = avro
+	}
+
+	producer, err := sarama.NewAsyncProducer(cfg.Brokers, newProducerConfig(cfg))
+	if err != nil {
+		return nil, err
+	}
+	return newKafkaLogger(producer, s, cfg.Topics), nil
+}
+
+func newProducerConfig(cfg *config.KafkaLogs) *sarama.Config {
+	producerCfg := sarama.NewConfig()
+	producerCfg.ClientID = "prebid-server"
+	producerCfg.ChannelBufferSize = cfg.BufferSize
+	producerCfg.Producer.RequiredAcks = sarama.WaitForLocal
+	producerCfg.Producer.Return.Successes = false
+	producerCfg.Producer.Return.Errors = true
+	producerCfg.Producer.Flush.Messages = cfg.BatchSize
+	producerCfg.Producer.Flush.Frequency = time.Duration(cfg.FlushIntervalMs) * time.Millisecond
+	return producerCfg
+}
+
+func newKafkaLogger(producer sarama.AsyncProducer, s serializer, topics config.KafkaTopics) *KafkaLogger {
+	kafkaLogger := &KafkaLogger{
+		producer:   producer,
+		serializer: s,
+		topics:     topics,
+	}
+	// The producer blocks if nobody reads its errors.
+	go func() {
+		for err := range producer.Errors() {
+			logger.Errorf("Failed to publish analytics to Kafka topic %s: %v", err.Msg.Topic, err.Err)
+		}
+	}()
+	return kafkaLogger
+}
+
+func configuredTopics(topics *config.KafkaTopics) []string {
+	all := []string{topics.Auction, topics.Bid, topics.Amp, topics.CookieSync, topics.SetUID}
+	configured := make([]string, 0, len(all))
+	for _, topic := range all {
+		if topic != "" {
+			configured = append(configured, topic)
+		}
+	}
+	return configured
+}
+
+// Publishes the AuctionObject, and each of the bids in its response
+func (k *KafkaLogger) LogAuctionObject(ao *analytics.AuctionObject) {
+	if ao == nil {
+		return
+	}
+	requestID := ""
+	if ao.Request != nil {
+		requestID = ao.Request.ID
+	}
+	k.publish(k.topics.Auction, requestID, AUCTION, ao.Status, ao.Errors, ao.SampleRate, &auctionPayload{
+		Request:        ao.Request,
+		Response:       ao.Response,
+		InvalidTraffic: ao.InvalidTraffic,
+	})
+
+	if k.topics.Bid == "" || ao.Response == nil {
+		return
+	}
+	for _, seatBid := range ao.Response.SeatBid {
+		for i := range seatBid.Bid {
+			k.publish(k.topics.Bid, requestID, BID, ao.Status, nil, ao.SampleRate, &bidPayload{
+				RequestID: requestID,
+				Seat:      seatBid.Seat,
+				Bid:       &seatBid.Bid[i],
+			})
+		}
+	}
+}
+
+// Publishes the AmpObject
+func (k *KafkaLogger) LogAmpObject(ao *analytics.AmpObject) {
+	if ao == nil {
+		return
+	}
+	requestID := ""
+	if ao.Request != nil {
+		requestID = ao.Request.ID
+	}
+	k.publish(k.topics.Amp, requestID, AMP, ao.Status, ao.Errors, ao.SampleRate, &ampPayload{
+		Request:            ao.Request,
+		AuctionResponse:    ao.AuctionResponse,
+		AmpTargetingValues: ao.AmpTargetingValues,
+		Origin:             ao.Origin,
+		InvalidTraffic:     ao.InvalidTraffic,
+	})
+}
+
+// Publishes the CookieSyncObject. Cookie syncs aren't sampled, so they're all published.
+func (k *KafkaLogger) LogCookieSyncObject(cso *analytics.CookieSyncObject) {
+	if cso == nil {
+		return
+	}
+	k.publish(k.topics.CookieSync, "", COOKIE_SYNC, cso.Status, cso.Errors, 1, &cookieSyncPayload{
+		BidderStatus: cso.BidderStatus,
+	})
+}
+
+// Publishes the SetUIDObject
+func (k *KafkaLogger) LogSetUIDObject(so *analytics.SetUIDObject) {
+	if so == nil {
+		return
+	}
+	k.publish(k.topics.SetUID, so.Bidder, SETUID, so.Status, so.Errors, 1, &setUIDPayload{
+		Bidder:  so.Bidder,
+		UID:     so.UID,
+		Success: so.Success,
+	})
+}
+
+// publish hands the message to the producer without blocking. If the producer's buffer is full, the message is dropped.
+func (k *KafkaLogger) publish(topic string, key string, typ objectType, status int, errs []error, sampleRate float64, payload interface{}) {
+	if topic == "" {
+		return
+	}
+	msg, err := newMessage(typ, status, errs, sampleRate, payload)
+	if err != nil {
+		logger.Errorf("Failed to build the %s analytics message: %v", typ, err)
+		return
+	}
+	value, err := k.serializer.serialize(topic, msg)
+	if err != nil {
+		logger.Errorf("Failed to serialize the %s analytics message: %v", typ, err)
+		return
+	}
+
+	producerMsg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+	}
+	if key != "" {
+		producerMsg.Key = sarama.StringEncoder(key)
+	}
+
+	select {
+	case k.producer.Input() <- producerMsg:
+	default:
+		// Log the first drop, and then every thousandth, so that a slow cluster doesn't flood the logs too.
+		if dropped := atomic.AddUint64(&k.dropped, 1); dropped%1000 == 1 {
+			logger.Warningf("The Kafka analytics buffer is full. %d messages have been dropped so far.", dropped)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestAuctionAndBids(t *testing.T) {
+	producer := newFakeProducer(10)
+	logger := newKafkaLogger(producer, jsonSerializer{}, config.KafkaTopics{
+		Auction: "auctions",
+		Bid:     "bids",
+	})
+
+	logger.LogAuctionObject(&analytics.AuctionObject{
+		Status:         http.StatusOK,
+		Errors:         []error{errors.New("some error")},
+		Request:        &openrtb.BidRequest{ID: "req-id"},
+		SampleRate:     0.5,
+		InvalidTraffic: "The IP is in the datacenter range 203.0.113.0/24.",
+		Response: &openrtb.BidResponse{
+			ID: "req-id",
+			SeatBid: []openrtb.SeatBid{{
+				Seat: "appnexus",
+				Bid: []openrtb.Bid{
+					{ID: "bid-1", Price: 0.5},
+					{ID: "bid-2", Price: 1.5},
+				},
+			}},
+		},
+	})
+
+	msgs := producer.drain()
+	if len(msgs) != 3 {
+		t.Fatalf("Expected one auction and two bid messages. Got %d messages", len(msgs))
+	}
+	assertTopicAndKey(t, msgs[0], "auctions", "req-id")
+	auction := decodeMessage(t, msgs[0])
+	if auction.Type != AUCTION || auction.Status != http.StatusOK {
+		t.Errorf("Bad auction message envelope: %#v", auction)
+	}
+	if len(auction.Errors) != 1 || auction.Errors[0] != "some error" {
+		t.Errorf("The auction errors should be published as strings. Got %v", auction.Errors)
+	}
+	if auction.SampleRate != 0.5 {
+		t.Errorf("The auction's sample rate should be published. Got %f", auction.SampleRate)
+	}
+	var auctionData auctionPayload
+	if err := json.Unmarshal(auction.Payload, &auctionData); err != nil {
+		t.Fatalf("Failed to unmarshal the auction payload: %v", err)
+	}
+	if auctionData.InvalidTraffic == "" {
+		t.Errorf("The auction's invalid traffic flag should be published.")
+	}
+
+	assertTopicAndKey(t, msgs[1], "bids", "req-id")
+	var bid bidPayload
+	if err := json.Unmarshal(decodeMessage(t, msgs[2]).Payload, &bid); err != nil {
+		t.Fatalf("Failed to unmarshal the bid payload: %v", err)
+	}
+	if bid.Seat != "appnexus" || bid.Bid.ID != "bid-2" {
+		t.Errorf("Bad bid payload: %#v", bid)
+	}
+}
+
+func TestUnconfiguredTopicsAreSkipped(t *testing.T) {
+	producer := newFakeProducer(10)
+	logger := newKafkaLogger(producer, jsonSerializer{}, config.KafkaTopics{
+		SetUID: "setuids",
+	})
+
+	logger.LogAuctionObject(&analytics.AuctionObject{Request: &openrtb.BidRequest{ID: "req-id"}})
+	logger.LogAmpObject(&analytics.AmpObject{})
+	logger.LogCookieSyncObject(&analytics.CookieSyncObject{})
+	logger.LogSetUIDObject(&analytics.SetUIDObject{Bidder: "adnxs", UID: "123", Success: true})
+
+	msgs := producer.drain()
+	if len(msgs) != 1 {
+		t.Fatalf("Only the setuid topic is configured. Got %d messages", len(msgs))
+	}
+	assertTopicAndKey(t, msgs[0], "setuids", "adnxs")
+}
+
+func TestFullBufferDropsMessages(t *testing.T) {
+	producer := newFakeProducer(1)
+	logger := newKafkaLogger(producer, jsonSerializer{}, config.KafkaTopics{
+		CookieSync: "cookiesyncs",
+	})
+
+	logger.LogCookieSyncObject(&analytics.CookieSyncObject{})
+	logger.LogCookieSyncObject(&analytics.CookieSyncObject{})
+	logger.LogCookieSyncObject(&analytics.CookieSyncObject{})
+
+	if msgs := producer.drain(); len(msgs) != 1 {
+		t.Errorf("Messages which don't fit in the buffer should be dropped. Got %d messages", len(msgs))
+	}
+	if logger.dropped != 2 {
+		t.Errorf("Expected 2 dropped messages. Got %d", logger.dropped)
+	}
+}
+
+func assertTopicAndKey(t *testing.T, msg *sarama.ProducerMessage, topic string, key string) {
+	t.Helper()
+	if msg.Topic != topic {
+		t.Errorf("Expected topic %s. Got %s", topic, msg.Topic)
+	}
+	if msg.Key == nil {
+		t.Errorf("Expected key %s. Got none", key)
+		return
+	}
+	if gotKey, _ := msg.Key.Encode(); string(gotKey) != key {
+		t.Errorf("Expected key %s. Got %s", key, string(gotKey))
+	}
+}
+
+func decodeMessage(t *testing.T, msg *sarama.ProducerMessage) *message {
+	t.Helper()
+	value, err := msg.Value.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode message value: %v", err)
+	}
+	var decoded message
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	return &decoded
+}
+
+// fakeProducer is an AsyncProducer which never sends anything, so the tests can look at what was queued.
+type fakeProducer struct {
+	input  chan *sarama.ProducerMessage
+	errors chan *sarama.ProducerError
+}
+
+func newFakeProducer(bufferSize int) *fakeProducer {
+	return &fakeProducer{
+		input:  make(chan *sarama.ProducerMessage, bufferSize),
+		errors: make(chan *sarama.ProducerError),
+	}
+}
+
+func (p *fakeProducer) drain() []*sarama.ProducerMessage {
+	var msgs []*sarama.ProducerMessage
+	for {
+		select {
+		case msg := <-p.input:
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func (p *fakeProducer) AsyncClose() {
+	close(p.errors)
+}
+
+func (p *fakeProducer) Close() error {
+	p.AsyncClose()
+	return nil
+}
+
+func (p *fakeProducer) Input() chan<- *sarama.ProducerMessage {
+	return p.input
+}
+
+func (p *fakeProducer) Successes() <-chan *sarama.ProducerMessage {
+	return nil
+}
+
+func (p *fakeProducer) Errors() <-chan *sarama.ProducerError {
+	return p.errors
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// message is the envelope around every object published to Kafka.
+type message struct {
+	Type       objectType      `json:"type"`
+	Timestamp  int64           `json:"timestamp"`
+	Status     int             `json:"status"`
+	Errors     []string        `json:"errors,omitempty"`
+	SampleRate float64         `json:"sample_rate"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// serializer turns messages into the bytes which get written to a topic.
+type serializer interface {
+	serialize(topic string, msg *message) ([]byte, error)
+}
+
+type jsonSerializer struct{}
+
+func (s jsonSerializer) serialize(topic string, msg *message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+// messageSchema is the Avro schema for the message envelope. The payload is kept as a JSON string, since
+// the OpenRTB objects are far too loosely typed to be worth mapping into Avro.
+const messageSchema = `{
+  "type": "record",
+  "name": "AnalyticsMessage",
+  "namespace": "org.prebid.server.analytics",
+  "fields": [
+    {"name": "type", "type": "string"},
+    {"name": "timestamp", "type": "long"},
+    {"name": "status", "type": "int"},
+    {"name": "errors", "type": {"type": "array", "items": "string"}},
+    {"name": "sample_rate", "type": "double", "default": 1.0},
+    {"name": "payload", "type": "string"}
+  ]
+}`
+
+// avroSerializer writes messages in the Confluent wire format: a zero byte, the 4-byte schema ID
+// from the schema registry, and then the Avro-encoded record.
+type avroSerializer struct {
+	codec     *goavro.Codec
+	schemaIDs map[string]uint32
+}
+
+// newAvroSerializer registers the message schema for each topic with the schema registry.
+// The schema IDs are only looked up here, so that publishing never has to wait on the registry.
+func newAvroSerializer(ctx context.Context, client *http.Client, registryURL string, topics []string) (*avroSerializer, error) {
+	codec, err := goavro.NewCodec(messageSchema)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile the analytics Avro schema: %v", err)
+	}
+	s := &avroSerializer{
+		codec:     codec,
+		schemaIDs: make(map[string]uint32, len(topics)),
+	}
+	for _, topic := range topics {
+		id, err := registerSchema(ctx, client, registryURL, topic+"-value", messageSchema)
+		if err != nil {
+			return nil, err
+		}
+		s.schemaIDs[topic] = id
+	}
+	return s, nil
+}
+
+func (s *avroSerializer) serialize(topic string, msg *message) ([]byte, error) {
+	id, ok := s.schemaIDs[topic]
+	if !ok {
+		return nil, fmt.Errorf("No Avro schema has been registered for topic %s", topic)
+	}
+	errs := make([]interface{}, len(msg.Errors))
+	for i, err := range msg.Errors {
+		errs[i] = err
+	}
+	native := map[string]interface{}{
+		"type":        string(msg.Type),
+		"timestamp":   msg.Timestamp,
+		"status":      int32(msg.Status),
+		"errors":      errs,
+		"sample_rate": msg.SampleRate,
+		"payload":     string(msg.Payload),
+	}
+
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], id)
+	return s.codec.BinaryFromNative(header, native)
+}
+
+type registerSchemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+type registerSchemaResponse struct {
+	ID uint32 `json:"id"`
+}
+
+// registerSchema adds the schema to the registry under the given subject, and returns its ID.
+// The registry returns the existing ID if the schema has been registered before.
+func registerSchema(ctx context.Context, client *http.Client, registryURL string, subject string, schema string) (uint32, error) {
+	body, err := json.Marshal(registerSchemaRequest{Schema: schema})
+	if err != nil {
+		return 0, err
+	}
+	url := fmt.Sprintf("%s/subjects/%s/versions", strings.TrimSuffix(registryURL, "/"), subject)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to register the analytics schema for %s: %v", subject, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read the schema registry response for %s: %v", subject, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Schema registry returned status %d for %s: %s", resp.StatusCode, subject, string(respBody))
+	}
+
+	var parsed registerSchemaResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return 0, fmt.Errorf("Bad schema registry response for %s: %v", subject, err)
+	}
+	return parsed.ID, nil
+}
+
+func newMessage(typ objectType, status int, errs []error, sampleRate float64, payload interface{}) (*message, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &message{
+		Type:       typ,
+		Timestamp:  time.Now().UnixNano() / int64(time.Millisecond),
+		Status:     status,
+		Errors:     errsToStrings(errs),
+		SampleRate: sampleRate,
+		Payload:    b,
+	}, nil
+}
+
+func errsToStrings(errs []error) []string {
+	if len(errs) == 0 {
+		return nil
+	}
+	strs := make([]string, len(errs))
+	for i, err := range errs {
+		strs[i] = err.Error()
+	}
+	return strs
+}
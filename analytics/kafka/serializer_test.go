@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/linkedin/goavro"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	msg, err := newMessage(SETUID, http.StatusOK, []error{errors.New("bad uid")}, 1, &setUIDPayload{Bidder: "adnxs"})
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	b, err := jsonSerializer{}.serialize("setuids", msg)
+	if err != nil {
+		t.Fatalf("Unexpected error serializing the message: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("The serialized message was not JSON: %v", err)
+	}
+	if decoded["type"] != "setuid" {
+		t.Errorf(`Expected type "setuid". Got %v`, decoded["type"])
+	}
+	if payload, ok := decoded["payload"].(map[string]interface{}); !ok || payload["bidder"] != "adnxs" {
+		t.Errorf("The payload should be embedded as a JSON object. Got %v", decoded["payload"])
+	}
+}
+
+func TestAvroSerializer(t *testing.T) {
+	var gotPath string
+	var gotSchema registerSchemaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &gotSchema)
+		w.Write([]byte(`{"id":42}`))
+	}))
+	defer server.Close()
+
+	s, err := newAvroSerializer(context.Background(), server.Client(), server.URL+"/", []string{"auctions"})
+	if err != nil {
+		t.Fatalf("Unexpected error registering the schema: %v", err)
+	}
+	if gotPath != "/subjects/auctions-value/versions" {
+		t.Errorf("The schema was registered at the wrong path: %s", gotPath)
+	}
+	if gotSchema.Schema != messageSchema {
+		t.Errorf("The wrong schema was registered: %s", gotSchema.Schema)
+	}
+
+	msg, _ := newMessage(AUCTION, http.StatusOK, nil, 0.25, &auctionPayload{})
+	b, err := s.serialize("auctions", msg)
+	if err != nil {
+		t.Fatalf("Unexpected error serializing the message: %v", err)
+	}
+	if b[0] != 0 || binary.BigEndian.Uint32(b[1:5]) != 42 {
+		t.Errorf("The message should start with the Confluent magic byte and schema ID. Got %v", b[:5])
+	}
+
+	codec, _ := goavro.NewCodec(messageSchema)
+	native, _, err := codec.NativeFromBinary(b[5:])
+	if err != nil {
+		t.Fatalf("Failed to decode the Avro record: %v", err)
+	}
+	record := native.(map[string]interface{})
+	if record["type"] != "auction" {
+		t.Errorf(`Expected type "auction". Got %v`, record["type"])
+	}
+	if record["sample_rate"] != 0.25 {
+		t.Errorf("Expected sample_rate 0.25. Got %v", record["sample_rate"])
+	}
+
+	if _, err := s.serialize("unregistered", msg); err == nil {
+		t.Errorf("Topics without a registered schema should return an error.")
+	}
+}
+
+func TestSchemaRegistryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer server.Close()
+
+	if _, err := newAvroSerializer(context.Background(), server.Client(), server.URL, []string{"auctions"}); err == nil {
+		t.Errorf("A failed schema registration should return an error.")
+	}
+}
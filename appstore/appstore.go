@@ -0,0 +1,143 @@
+// Package appstore fills in the app details which SDK requests leave out, using metadata from an app store
+// metadata service. Bidders often won't bid on apps they can't identify, so this improves bid rates for SDK traffic.
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Metadata is what the service knows about an app. It's the body of the service's responses.
+type Metadata struct {
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	StoreURL  string `json:"storeurl"`
+}
+
+// Enricher fills in the missing app details on bid requests, from the metadata for the app's bundle ID.
+//
+// Lookups never hold up an auction. Metadata which isn't cached is fetched in the background,
+// so the first requests for each app go out without it.
+//
+// All functions on this struct are nil-safe. A nil Enricher leaves every request alone.
+type Enricher struct {
+	client   *http.Client
+	endpoint string
+	timeout  time.Duration
+	cache    *metadataCache
+
+	lock     sync.Mutex
+	fetching map[string]bool
+	// fetches tracks the background fetches, so that the tests can wait for them.
+	fetches sync.WaitGroup
+}
+
+// NewEnricher returns an Enricher which looks up metadata according to cfg, or nil if enrichment is off.
+func NewEnricher(cfg *config.AppEnrichment, client *http.Client) *Enricher {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Enricher{
+		client:   client,
+		endpoint: cfg.Endpoint,
+		timeout:  time.Duration(cfg.TimeoutMillis) * time.Millisecond,
+		cache:    newMetadataCache(cfg.CacheSize, time.Duration(cfg.CacheTTLSeconds)*time.Second),
+		fetching: make(map[string]bool),
+	}
+}
+
+// Enrich sets app.name, app.storeurl and app.publisher.name from the metadata for app.bundle, if they're empty.
+// The values which the request does have are never changed.
+func (e *Enricher) Enrich(app *openrtb.App) {
+	if e == nil || app == nil || app.Bundle == "" {
+		return
+	}
+	metadata, ok := e.cache.get(app.Bundle)
+	if !ok {
+		e.fetchInBackground(app.Bundle)
+		return
+	}
+	if metadata == nil {
+		return
+	}
+	if app.Name == "" {
+		app.Name = metadata.Name
+	}
+	if app.StoreURL == "" {
+		app.StoreURL = metadata.StoreURL
+	}
+	if metadata.Publisher != "" {
+		if app.Publisher == nil {
+			app.Publisher = &openrtb.Publisher{}
+		}
+		if app.Publisher.Name == "" {
+			app.Publisher.Name = metadata.Publisher
+		}
+	}
+}
+
+// fetchInBackground looks up the bundle's metadata and caches it, unless it's already being looked up.
+func (e *Enricher) fetchInBackground(bundle string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.fetching[bundle] {
+		return
+	}
+	e.fetching[bundle] = true
+	e.fetches.Add(1)
+	go func() {
+		defer e.fetches.Done()
+		metadata, err := e.fetch(bundle)
+		if err != nil {
+			// Failed lookups aren't cached, so that they're tried again by the next request for the app.
+			logger.With("bundle", bundle).Warningf("Failed to fetch the app store metadata: %v", err)
+		} else {
+			e.cache.put(bundle, metadata)
+		}
+		e.lock.Lock()
+		delete(e.fetching, bundle)
+		e.lock.Unlock()
+	}()
+}
+
+// fetch asks the service for the bundle's metadata. It returns nil metadata if the service doesn't know the bundle.
+func (e *Enricher) fetch(bundle string) (*Metadata, error) {
+	lookupURL, err := url.Parse(e.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := lookupURL.Query()
+	query.Set("bundle", bundle)
+	lookupURL.RawQuery = query.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	resp, err := ctxhttp.Get(ctx, e.client, lookupURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var metadata Metadata
+		if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+			return nil, fmt.Errorf("the response wasn't valid metadata: %v", err)
+		}
+		return &metadata, nil
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("the service responded with status %d", resp.StatusCode)
+	}
+}
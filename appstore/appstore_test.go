@@ -0,0 +1,116 @@
+package appstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestEnrich(t *testing.T) {
+	server, lookups := newMetadataServer()
+	defer server.Close()
+	enricher := newTestEnricher(server.URL)
+
+	app := &openrtb.App{Bundle: "com.example.game"}
+	enricher.Enrich(app)
+	if app.Name != "" || app.Publisher != nil {
+		t.Errorf("Apps shouldn't be enriched until their metadata has been fetched. Got %v", app)
+	}
+	enricher.fetches.Wait()
+
+	enricher.Enrich(app)
+	assertStringValue(t, "app.name", "Example Game", app.Name)
+	assertStringValue(t, "app.storeurl", "https://play.google.com/store/apps/details?id=com.example.game", app.StoreURL)
+	if app.Publisher == nil {
+		t.Fatalf("app.publisher should be added.")
+	}
+	assertStringValue(t, "app.publisher.name", "Example Studios", app.Publisher.Name)
+	assertStringValue(t, "app.publisher.id", "", app.Publisher.ID)
+
+	sparse := &openrtb.App{Bundle: "com.example.game", Name: "My Game", Publisher: &openrtb.Publisher{ID: "1001"}}
+	enricher.Enrich(sparse)
+	assertStringValue(t, "app.name", "My Game", sparse.Name)
+	assertStringValue(t, "app.publisher.id", "1001", sparse.Publisher.ID)
+	assertStringValue(t, "app.publisher.name", "Example Studios", sparse.Publisher.Name)
+	if count := atomic.LoadInt32(lookups); count != 1 {
+		t.Errorf("The metadata should be cached. Got %d lookups", count)
+	}
+}
+
+func TestEnrichUnknownApps(t *testing.T) {
+	server, lookups := newMetadataServer()
+	defer server.Close()
+	enricher := newTestEnricher(server.URL)
+
+	for i := 0; i < 3; i++ {
+		app := &openrtb.App{Bundle: "com.example.unknown"}
+		enricher.Enrich(app)
+		enricher.fetches.Wait()
+		if app.Name != "" || app.Publisher != nil {
+			t.Errorf("Apps which the service doesn't know should be left alone. Got %v", app)
+		}
+	}
+	if count := atomic.LoadInt32(lookups); count != 1 {
+		t.Errorf("Unknown apps should be cached too. Got %d lookups", count)
+	}
+
+	for i := 0; i < 2; i++ {
+		enricher.Enrich(&openrtb.App{Bundle: "com.example.broken"})
+		enricher.fetches.Wait()
+	}
+	if count := atomic.LoadInt32(lookups); count != 3 {
+		t.Errorf("Failed lookups should be retried. Got %d lookups", count)
+	}
+}
+
+func TestNilEnricher(t *testing.T) {
+	enricher := NewEnricher(&config.AppEnrichment{}, http.DefaultClient)
+	if enricher != nil {
+		t.Fatalf("Disabled enrichment should return a nil Enricher.")
+	}
+	app := &openrtb.App{Bundle: "com.example.game"}
+	enricher.Enrich(app)
+	enricher.Enrich(nil)
+	if app.Name != "" {
+		t.Errorf("A nil Enricher shouldn't change the app. Got %v", app)
+	}
+}
+
+// newMetadataServer returns a metadata service which knows com.example.game, fails on com.example.broken,
+// and counts its lookups.
+func newMetadataServer() (*httptest.Server, *int32) {
+	var lookups int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		switch r.URL.Query().Get("bundle") {
+		case "com.example.game":
+			w.Write([]byte(`{"name":"Example Game","publisher":"Example Studios","storeurl":"https://play.google.com/store/apps/details?id=com.example.game"}`))
+		case "com.example.broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &lookups
+}
+
+func newTestEnricher(endpoint string) *Enricher {
+	return NewEnricher(&config.AppEnrichment{
+		Enabled:         true,
+		Endpoint:        endpoint + "/metadata?source=test",
+		TimeoutMillis:   1000,
+		CacheSize:       10,
+		CacheTTLSeconds: 3600,
+	}, http.DefaultClient)
+}
+
+func assertStringValue(t *testing.T, field string, expected string, actual string) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("Wrong %s. Expected \"%s\", got \"%s\"", field, expected, actual)
+	}
+}
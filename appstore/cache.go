@@ -0,0 +1,78 @@
+package appstore
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// metadataCache is a least-recently-used cache of app metadata, keyed by bundle ID, whose entries expire after a TTL.
+// Bundles which the service doesn't know are cached too, so that they aren't looked up again on every request.
+type metadataCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	// recent orders the entries from most to least recently used.
+	recent *list.List
+	now    func() time.Time
+}
+
+type metadataCacheEntry struct {
+	bundle   string
+	metadata *Metadata
+	expires  time.Time
+}
+
+// newMetadataCache makes a cache which holds up to size bundles, for up to ttl each. If ttl is 0, the entries don't expire.
+func newMetadataCache(size int, ttl time.Duration) *metadataCache {
+	return &metadataCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		recent:  list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the bundle's metadata, which is nil if the service doesn't know the bundle.
+// The bool is false if the bundle isn't cached.
+func (c *metadataCache) get(bundle string) (*Metadata, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[bundle]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*metadataCacheEntry)
+	if c.ttl > 0 && !c.now().Before(entry.expires) {
+		c.recent.Remove(element)
+		delete(c.entries, bundle)
+		return nil, false
+	}
+	c.recent.MoveToFront(element)
+	return entry.metadata, true
+}
+
+func (c *metadataCache) put(bundle string, metadata *Metadata) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &metadataCacheEntry{
+		bundle:   bundle,
+		metadata: metadata,
+		expires:  c.now().Add(c.ttl),
+	}
+	if element, ok := c.entries[bundle]; ok {
+		element.Value = entry
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.entries[bundle] = c.recent.PushFront(entry)
+	for c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*metadataCacheEntry).bundle)
+	}
+}
@@ -0,0 +1,44 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetadataCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newMetadataCache(10, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	cache.put("com.example.game", &Metadata{Name: "Example Game"})
+	cache.put("com.example.unknown", nil)
+	if metadata, ok := cache.get("com.example.game"); !ok || metadata.Name != "Example Game" {
+		t.Errorf("The metadata should be cached. Got %v, %t", metadata, ok)
+	}
+	if metadata, ok := cache.get("com.example.unknown"); !ok || metadata != nil {
+		t.Errorf("Unknown apps should be cached without metadata. Got %v, %t", metadata, ok)
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := cache.get("com.example.game"); ok {
+		t.Errorf("The metadata should expire after the TTL.")
+	}
+}
+
+func TestMetadataCacheEviction(t *testing.T) {
+	cache := newMetadataCache(2, 0)
+	cache.put("first", &Metadata{})
+	cache.put("second", &Metadata{})
+	cache.get("first")
+	cache.put("third", &Metadata{})
+
+	if _, ok := cache.get("second"); ok {
+		t.Errorf("The least recently used app should be dropped.")
+	}
+	if _, ok := cache.get("first"); !ok {
+		t.Errorf("Recently used apps should be kept.")
+	}
+	if _, ok := cache.get("third"); !ok {
+		t.Errorf("New apps should be kept.")
+	}
+}
@@ -0,0 +1,240 @@
+// Package blocklist rejects the requests from the publishers, domains and app bundles which the host has banned.
+// It's for cutting off sources of fraudulent or policy-violating traffic quickly, without a deploy or a restart.
+package blocklist
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+)
+
+// queryTimeout limits how long each run of the query may take.
+const queryTimeout = 10 * time.Second
+
+// Entries are the banned traffic sources. It's also the format of the blocklist file.
+type Entries struct {
+	Publishers []string `json:"publishers"`
+	// Domains are banned along with their subdomains.
+	Domains []string `json:"domains"`
+	Bundles []string `json:"bundles"`
+}
+
+// List decides which requests come from banned sources.
+//
+// All functions on this struct are nil-safe. A nil List doesn't block anything.
+type List struct {
+	loaders []loader
+	// banned holds the latest *bannedSet. It's replaced whenever the list is loaded.
+	banned atomic.Value
+}
+
+// loader returns the entries from one of the list's sources.
+type loader func() (*Entries, error)
+
+type bannedSet struct {
+	publishers map[string]bool
+	domains    map[string]bool
+	bundles    map[string]bool
+}
+
+// NewList loads the list from the sources in cfg, and then again every cfg.RefreshSeconds. It returns nil if the
+// blocklist is off, or an error if the first load fails. The db is only used if cfg has a query.
+func NewList(cfg *config.Blocklist, db *sql.DB) (*List, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	l := &List{}
+	if cfg.File != "" {
+		l.loaders = append(l.loaders, fileLoader(cfg.File))
+	}
+	if cfg.Query != "" {
+		if db == nil {
+			return nil, fmt.Errorf("blocklist.query can't run without a database connection")
+		}
+		l.loaders = append(l.loaders, queryLoader(db, cfg.Query))
+	}
+	if err := l.update(); err != nil {
+		return nil, err
+	}
+	if cfg.RefreshSeconds > 0 {
+		go l.refresh(time.Tick(time.Duration(cfg.RefreshSeconds) * time.Second))
+	}
+	return l, nil
+}
+
+func (l *List) refresh(ticker <-chan time.Time) {
+	for range ticker {
+		if err := l.update(); err != nil {
+			logger.Errorf("Failed to reload the blocklist. The previous one is still in use: %v", err)
+		}
+	}
+}
+
+// update loads the entries from every source. If any of them fail, the previous list is kept, so that a broken
+// source can't unban anything.
+func (l *List) update() error {
+	banned := &bannedSet{
+		publishers: make(map[string]bool),
+		domains:    make(map[string]bool),
+		bundles:    make(map[string]bool),
+	}
+	for _, load := range l.loaders {
+		entries, err := load()
+		if err != nil {
+			return err
+		}
+		for _, publisher := range entries.Publishers {
+			banned.publishers[publisher] = true
+		}
+		for _, domain := range entries.Domains {
+			banned.domains[normalizeDomain(domain)] = true
+		}
+		for _, bundle := range entries.Bundles {
+			banned.bundles[strings.ToLower(bundle)] = true
+		}
+	}
+	l.banned.Store(banned)
+	return nil
+}
+
+// Blocked returns the source which is banned from making the request, like "domain example.com", or an empty
+// string if the request may be served.
+func (l *List) Blocked(req *openrtb.BidRequest) string {
+	if l == nil {
+		return ""
+	}
+	banned, ok := l.banned.Load().(*bannedSet)
+	if !ok {
+		return ""
+	}
+	if site := req.Site; site != nil {
+		if site.Publisher != nil {
+			if blocked := banned.blockedPublisher(site.Publisher.ID); blocked != "" {
+				return blocked
+			}
+			if blocked := banned.blockedDomain(site.Publisher.Domain); blocked != "" {
+				return blocked
+			}
+		}
+		if blocked := banned.blockedDomain(site.Domain); blocked != "" {
+			return blocked
+		}
+		if page, err := url.Parse(site.Page); err == nil {
+			if blocked := banned.blockedDomain(page.Hostname()); blocked != "" {
+				return blocked
+			}
+		}
+	}
+	if app := req.App; app != nil {
+		if app.Publisher != nil {
+			if blocked := banned.blockedPublisher(app.Publisher.ID); blocked != "" {
+				return blocked
+			}
+			if blocked := banned.blockedDomain(app.Publisher.Domain); blocked != "" {
+				return blocked
+			}
+		}
+		if blocked := banned.blockedDomain(app.Domain); blocked != "" {
+			return blocked
+		}
+		if app.Bundle != "" && banned.bundles[strings.ToLower(app.Bundle)] {
+			return "app bundle " + app.Bundle
+		}
+	}
+	return ""
+}
+
+func (banned *bannedSet) blockedPublisher(id string) string {
+	if id != "" && banned.publishers[id] {
+		return "publisher " + id
+	}
+	return ""
+}
+
+// blockedDomain checks the domain, and each of its parents, so that banning a domain bans its subdomains too.
+func (banned *bannedSet) blockedDomain(domain string) string {
+	for parent := normalizeDomain(domain); parent != ""; {
+		if banned.domains[parent] {
+			return "domain " + parent
+		}
+		dot := strings.IndexByte(parent, '.')
+		if dot == -1 {
+			break
+		}
+		parent = parent[dot+1:]
+	}
+	return ""
+}
+
+// normalizeDomain lowercases the domain, and drops the "www." which pages often have.
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+}
+
+// fileLoader reads the entries from a JSON file.
+func fileLoader(path string) loader {
+	return func() (*Entries, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var entries Entries
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("%s isn't a valid blocklist: %v", path, err)
+		}
+		return &entries, nil
+	}
+}
+
+// queryLoader runs the query for (type, value) rows, like:
+//
+//   SELECT type, value FROM blocklist
+//
+// The type must be "publisher", "domain" or "bundle".
+func queryLoader(db *sql.DB, query string) loader {
+	return func() (*Entries, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		defer cancel()
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := rows.Close(); err != nil {
+				logger.Errorf("error closing DB connection: %v", err)
+			}
+		}()
+
+		var entries Entries
+		for rows.Next() {
+			var entryType, value string
+			if err := rows.Scan(&entryType, &value); err != nil {
+				return nil, err
+			}
+			switch entryType {
+			case "publisher":
+				entries.Publishers = append(entries.Publishers, value)
+			case "domain":
+				entries.Domains = append(entries.Domains, value)
+			case "bundle":
+				entries.Bundles = append(entries.Bundles, value)
+			default:
+				return nil, fmt.Errorf("the blocklist query returned an unknown type %q", entryType)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return &entries, nil
+	}
+}
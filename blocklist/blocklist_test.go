@@ -0,0 +1,141 @@
+package blocklist
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+const blocklistQuery = "SELECT type, value FROM blocklist"
+
+func TestBlocked(t *testing.T) {
+	l := &List{loaders: []loader{entriesLoader(&Entries{
+		Publishers: []string{"1001"},
+		Domains:    []string{"www.Fraud.com"},
+		Bundles:    []string{"com.fraud.App"},
+	})}}
+	if err := l.update(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := map[string]struct {
+		req  *openrtb.BidRequest
+		want string
+	}{
+		"site publisher":     {&openrtb.BidRequest{Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "1001"}}}, "publisher 1001"},
+		"app publisher":      {&openrtb.BidRequest{App: &openrtb.App{Publisher: &openrtb.Publisher{ID: "1001"}}}, "publisher 1001"},
+		"site domain":        {&openrtb.BidRequest{Site: &openrtb.Site{Domain: "FRAUD.com"}}, "domain fraud.com"},
+		"subdomain":          {&openrtb.BidRequest{Site: &openrtb.Site{Domain: "news.fraud.com"}}, "domain fraud.com"},
+		"page":               {&openrtb.BidRequest{Site: &openrtb.Site{Page: "https://www.fraud.com/article"}}, "domain fraud.com"},
+		"publisher domain":   {&openrtb.BidRequest{Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "1002", Domain: "fraud.com"}}}, "domain fraud.com"},
+		"app domain":         {&openrtb.BidRequest{App: &openrtb.App{Domain: "fraud.com"}}, "domain fraud.com"},
+		"bundle":             {&openrtb.BidRequest{App: &openrtb.App{Bundle: "com.fraud.app"}}, "app bundle com.fraud.app"},
+		"allowed site":       {&openrtb.BidRequest{Site: &openrtb.Site{Domain: "notfraud.com", Page: "https://notfraud.com", Publisher: &openrtb.Publisher{ID: "1002"}}}, ""},
+		"allowed app":        {&openrtb.BidRequest{App: &openrtb.App{Bundle: "com.fraud.app2", Publisher: &openrtb.Publisher{ID: "1002"}}}, ""},
+		"no site and no app": {&openrtb.BidRequest{}, ""},
+	}
+	for name, test := range testCases {
+		if blocked := l.Blocked(test.req); blocked != test.want {
+			t.Errorf("%s: expected %q, got %q", name, test.want, blocked)
+		}
+	}
+
+	var nilList *List
+	if blocked := nilList.Blocked(testCases["site publisher"].req); blocked != "" {
+		t.Errorf("A nil List shouldn't block anything. Got %q", blocked)
+	}
+}
+
+func TestUpdateFailure(t *testing.T) {
+	failing := false
+	l := &List{loaders: []loader{
+		entriesLoader(&Entries{Publishers: []string{"1001"}}),
+		func() (*Entries, error) {
+			if failing {
+				return nil, errors.New("Connection lost")
+			}
+			return &Entries{Publishers: []string{"1002"}}, nil
+		},
+	}}
+	if err := l.update(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	failing = true
+	if err := l.update(); err == nil {
+		t.Errorf("Failed loads should return an error.")
+	}
+	req := &openrtb.BidRequest{Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "1002"}}}
+	if blocked := l.Blocked(req); blocked != "publisher 1002" {
+		t.Errorf("The previous list should be kept when a source fails. Got %q", blocked)
+	}
+}
+
+func TestNewList(t *testing.T) {
+	file, err := ioutil.TempFile("", "blocklist")
+	if err != nil {
+		t.Fatalf("Failed to make the blocklist file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"publishers":["1001"],"domains":["fraud.com"]}`)
+	file.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("^" + regexp.QuoteMeta(blocklistQuery) + "$").WillReturnRows(sqlmock.NewRows([]string{"type", "value"}).
+		AddRow("publisher", "1002").
+		AddRow("bundle", "com.fraud.app"))
+
+	l, err := NewList(&config.Blocklist{Enabled: true, File: file.Name(), Query: blocklistQuery}, db)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, id := range []string{"1001", "1002"} {
+		if blocked := l.Blocked(&openrtb.BidRequest{Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: id}}}); blocked == "" {
+			t.Errorf("Publisher %s should be blocked by one of the sources.", id)
+		}
+	}
+	if blocked := l.Blocked(&openrtb.BidRequest{App: &openrtb.App{Bundle: "com.fraud.app"}}); blocked == "" {
+		t.Errorf("The bundle from the query should be blocked.")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("The query should have run: %v", err)
+	}
+
+	if l, err := NewList(&config.Blocklist{File: file.Name()}, nil); l != nil || err != nil {
+		t.Errorf("A disabled blocklist should be nil. Got %v, %v", l, err)
+	}
+	if _, err := NewList(&config.Blocklist{Enabled: true, File: file.Name() + ".missing"}, nil); err == nil {
+		t.Errorf("A blocklist which can't be loaded should return an error.")
+	}
+	if _, err := NewList(&config.Blocklist{Enabled: true, Query: blocklistQuery}, nil); err == nil {
+		t.Errorf("A blocklist query without a database should return an error.")
+	}
+}
+
+func TestQueryLoaderUnknownType(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("^" + regexp.QuoteMeta(blocklistQuery) + "$").WillReturnRows(sqlmock.NewRows([]string{"type", "value"}).
+		AddRow("country", "XX"))
+	if _, err := queryLoader(db, blocklistQuery)(); err == nil {
+		t.Errorf("Rows with unknown types should return an error.")
+	}
+}
+
+func entriesLoader(entries *Entries) loader {
+	return func() (*Entries, error) {
+		return entries, nil
+	}
+}
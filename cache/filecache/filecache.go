@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 
-	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/cache"
+	"github.com/prebid/prebid-server/logger"
 	"gopkg.in/yaml.v2"
 )
 
@@ -33,27 +33,21 @@ type fileCacheFile struct {
 
 // New will load the file into memory
 func New(filename string) (*Cache, error) {
-	if glog.V(2) {
-		glog.Infof("Reading inventory urls from %s", filename)
-	}
+	logger.Debugf("Reading inventory urls from %s", filename)
 
 	b, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	if glog.V(2) {
-		glog.Infof("Parsing filecache YAML")
-	}
+	logger.Debugf("Parsing filecache YAML")
 
 	var u fileCacheFile
 	if err = yaml.Unmarshal(b, &u); err != nil {
 		return nil, err
 	}
 
-	if glog.V(2) {
-		glog.Infof("Building URL map")
-	}
+	logger.Debugf("Building URL map")
 
 	s := &shared{}
 
@@ -61,13 +55,13 @@ func New(filename string) (*Cache, error) {
 	for _, config := range u.Configs {
 		s.Configs[config.ID] = config.Config
 	}
-	glog.Infof("Loaded %d configs", len(u.Configs))
+	logger.Infof("Loaded %d configs", len(u.Configs))
 
 	s.Accounts = make(map[string]bool, len(u.Accounts))
 	for _, Account := range u.Accounts {
 		s.Accounts[Account] = true
 	}
-	glog.Infof("Loaded %d accounts", len(u.Accounts))
+	logger.Infof("Loaded %d accounts", len(u.Accounts))
 
 	return &Cache{
 		shared:   s,
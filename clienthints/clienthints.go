@@ -0,0 +1,140 @@
+// Package clienthints builds OpenRTB 2.6's structured user agent (device.sua) from the browser's User-Agent Client
+// Hints, or from the User-Agent string. Browsers are freezing their User-Agent strings, so the client hints are
+// becoming the only reliable source of the browser, platform and model versions which bidders target.
+package clienthints
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// ComponentName is the name which the activity controls know the client hints enrichment by.
+const ComponentName = "client_hints"
+
+// highEntropyHeaders are only sent to sites which ask for them. Any of them makes the hints high entropy.
+var highEntropyHeaders = []string{
+	"Sec-CH-UA-Full-Version-List",
+	"Sec-CH-UA-Platform-Version",
+	"Sec-CH-UA-Arch",
+	"Sec-CH-UA-Bitness",
+	"Sec-CH-UA-Model",
+}
+
+// Parse builds the structured user agent from the request's Sec-CH-UA headers. It returns nil if the browser
+// didn't send any.
+func Parse(header http.Header) *openrtb_ext.UserAgent {
+	sua := &openrtb_ext.UserAgent{Source: openrtb_ext.UserAgentSourceLowEntropy}
+	for _, name := range highEntropyHeaders {
+		if header.Get(name) != "" {
+			sua.Source = openrtb_ext.UserAgentSourceHighEntropy
+			break
+		}
+	}
+
+	// The full version list has the same brands as Sec-CH-UA, but with their full versions.
+	brands := header.Get("Sec-CH-UA-Full-Version-List")
+	if brands == "" {
+		brands = header.Get("Sec-CH-UA")
+	}
+	sua.Browsers = parseBrandList(brands)
+	if platform, ok := parseString(header.Get("Sec-CH-UA-Platform")); ok && platform != "" {
+		sua.Platform = &openrtb_ext.BrandVersion{Brand: platform}
+		if version, ok := parseString(header.Get("Sec-CH-UA-Platform-Version")); ok {
+			sua.Platform.Version = splitVersion(version)
+		}
+	}
+	switch header.Get("Sec-CH-UA-Mobile") {
+	case "?0":
+		sua.Mobile = int8Ptr(0)
+	case "?1":
+		sua.Mobile = int8Ptr(1)
+	}
+	sua.Architecture, _ = parseString(header.Get("Sec-CH-UA-Arch"))
+	sua.Bitness, _ = parseString(header.Get("Sec-CH-UA-Bitness"))
+	sua.Model, _ = parseString(header.Get("Sec-CH-UA-Model"))
+
+	if len(sua.Browsers) == 0 && sua.Platform == nil {
+		return nil
+	}
+	return sua
+}
+
+// parseBrandList reads a structured header list of brands, like `"Chromium";v="124", "Google Chrome";v="124"`.
+// Brands which can't be parsed are skipped. The brands are kept in the browser's order, including the "GREASE"
+// brands which browsers add so that servers don't depend on it.
+func parseBrandList(value string) []openrtb_ext.BrandVersion {
+	var brands []openrtb_ext.BrandVersion
+	for _, item := range splitOutsideQuotes(value, ',') {
+		params := splitOutsideQuotes(item, ';')
+		brand, ok := parseString(params[0])
+		if !ok || brand == "" {
+			continue
+		}
+		brandVersion := openrtb_ext.BrandVersion{Brand: brand}
+		for _, param := range params[1:] {
+			keyValue := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(keyValue) == 2 && keyValue[0] == "v" {
+				if version, ok := parseString(keyValue[1]); ok {
+					brandVersion.Version = splitVersion(version)
+				}
+			}
+		}
+		brands = append(brands, brandVersion)
+	}
+	return brands
+}
+
+// splitOutsideQuotes splits the value at each separator which isn't inside a quoted string.
+func splitOutsideQuotes(value string, separator byte) []string {
+	var parts []string
+	inQuotes, escaped := false, false
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && value[i] == '\\':
+			escaped = true
+		case value[i] == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && value[i] == separator:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// parseString reads a structured header string, like `"Windows"`. The bool is false if it isn't one.
+func parseString(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", false
+	}
+	var unquoted bytes.Buffer
+	escaped := false
+	for i := 1; i < len(value)-1; i++ {
+		if !escaped && value[i] == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		unquoted.WriteByte(value[i])
+	}
+	return unquoted.String(), true
+}
+
+// splitVersion splits a version like "124.0.6367.60" into its components. Empty versions have none.
+func splitVersion(version string) []string {
+	if version == "" {
+		return nil
+	}
+	return strings.Split(version, ".")
+}
+
+func int8Ptr(value int8) *int8 {
+	return &value
+}
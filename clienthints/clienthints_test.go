@@ -0,0 +1,85 @@
+package clienthints
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestParseLowEntropy(t *testing.T) {
+	header := http.Header{}
+	header.Set("Sec-CH-UA", `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`)
+	header.Set("Sec-CH-UA-Mobile", "?0")
+	header.Set("Sec-CH-UA-Platform", `"Windows"`)
+
+	assertUserAgent(t, Parse(header), `{
+		"browsers": [
+			{"brand": "Chromium", "version": ["124"]},
+			{"brand": "Google Chrome", "version": ["124"]},
+			{"brand": "Not-A.Brand", "version": ["99"]}
+		],
+		"platform": {"brand": "Windows"},
+		"mobile": 0,
+		"source": 1
+	}`)
+}
+
+func TestParseHighEntropy(t *testing.T) {
+	header := http.Header{}
+	header.Set("Sec-CH-UA", `"Chromium";v="124", "Google Chrome";v="124"`)
+	header.Set("Sec-CH-UA-Full-Version-List", `"Chromium";v="124.0.6367.60", "Google Chrome";v="124.0.6367.60"`)
+	header.Set("Sec-CH-UA-Mobile", "?1")
+	header.Set("Sec-CH-UA-Platform", `"Android"`)
+	header.Set("Sec-CH-UA-Platform-Version", `"14.0.0"`)
+	header.Set("Sec-CH-UA-Arch", `""`)
+	header.Set("Sec-CH-UA-Bitness", `"64"`)
+	header.Set("Sec-CH-UA-Model", `"Pixel \"7\""`)
+
+	assertUserAgent(t, Parse(header), `{
+		"browsers": [
+			{"brand": "Chromium", "version": ["124", "0", "6367", "60"]},
+			{"brand": "Google Chrome", "version": ["124", "0", "6367", "60"]}
+		],
+		"platform": {"brand": "Android", "version": ["14", "0", "0"]},
+		"mobile": 1,
+		"bitness": "64",
+		"model": "Pixel \"7\"",
+		"source": 2
+	}`)
+}
+
+func TestParseMalformed(t *testing.T) {
+	header := http.Header{}
+	header.Set("Sec-CH-UA", `Chromium;v=124, "Brand, with a comma";v="1";q=2, "";v="3"`)
+	header.Set("Sec-CH-UA-Mobile", "yes")
+	header.Set("Sec-CH-UA-Platform", "Windows")
+
+	assertUserAgent(t, Parse(header), `{
+		"browsers": [{"brand": "Brand, with a comma", "version": ["1"]}],
+		"source": 1
+	}`)
+}
+
+func TestParseNoHints(t *testing.T) {
+	header := http.Header{}
+	header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Gecko/20100101 Firefox/125.0")
+	header.Set("Sec-CH-UA-Mobile", "?0")
+	if sua := Parse(header); sua != nil {
+		t.Errorf("Requests without brands or a platform shouldn't get a structured user agent. Got %v", sua)
+	}
+}
+
+func assertUserAgent(t *testing.T, actual *openrtb_ext.UserAgent, expected string) {
+	t.Helper()
+	var expectedUA openrtb_ext.UserAgent
+	if err := json.Unmarshal([]byte(expected), &expectedUA); err != nil {
+		t.Fatalf("Bad expected user agent: %v", err)
+	}
+	expectedJSON, _ := json.Marshal(expectedUA)
+	actualJSON, _ := json.Marshal(actual)
+	if string(actualJSON) != string(expectedJSON) {
+		t.Errorf("Expected the user agent %s. Got %s", expectedJSON, actualJSON)
+	}
+}
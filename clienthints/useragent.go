@@ -0,0 +1,69 @@
+package clienthints
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mssola/user_agent"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// browserBrands are the names which the client hints use for browsers that the parser names differently.
+var browserBrands = map[string]string{
+	"Chrome": "Google Chrome",
+	"Edge":   "Microsoft Edge",
+}
+
+// platformPatterns find the platform and its version in a User-Agent string. They're checked in order, since iOS
+// User-Agents mention Mac OS X too.
+var platformPatterns = []struct {
+	brand   string
+	pattern *regexp.Regexp
+}{
+	{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS (\d+(?:_\d+)*)`)},
+	{"Android", regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)},
+	{"Chrome OS", regexp.MustCompile(`CrOS`)},
+	{"macOS", regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)?`)},
+	{"Windows", regexp.MustCompile(`Windows`)},
+	{"Linux", regexp.MustCompile(`Linux`)},
+}
+
+// FromUserAgent builds the structured user agent from a User-Agent string, for browsers which don't send client
+// hints. It returns nil for bots, and for User-Agents which it can't make sense of.
+//
+// Browsers are freezing their User-Agent strings, so the versions may be out of date. That's why the result's
+// source says that it was parsed, and why the client hints should be used whenever there are any.
+func FromUserAgent(userAgent string) *openrtb_ext.UserAgent {
+	if userAgent == "" {
+		return nil
+	}
+	ua := user_agent.New(userAgent)
+	if ua.Bot() {
+		return nil
+	}
+	sua := &openrtb_ext.UserAgent{Source: openrtb_ext.UserAgentSourceParsed}
+	if name, version := ua.Browser(); name != "" {
+		if brand, ok := browserBrands[name]; ok {
+			name = brand
+		}
+		sua.Browsers = []openrtb_ext.BrandVersion{{Brand: name, Version: splitVersion(version)}}
+	}
+	for _, platform := range platformPatterns {
+		if match := platform.pattern.FindStringSubmatch(userAgent); match != nil {
+			sua.Platform = &openrtb_ext.BrandVersion{Brand: platform.brand}
+			if len(match) > 1 {
+				sua.Platform.Version = splitVersion(strings.Replace(match[1], "_", ".", -1))
+			}
+			break
+		}
+	}
+	if len(sua.Browsers) == 0 && sua.Platform == nil {
+		return nil
+	}
+	if ua.Mobile() {
+		sua.Mobile = int8Ptr(1)
+	} else {
+		sua.Mobile = int8Ptr(0)
+	}
+	return sua
+}
@@ -0,0 +1,45 @@
+package clienthints
+
+import (
+	"testing"
+)
+
+func TestFromUserAgent(t *testing.T) {
+	tests := []struct {
+		description string
+		userAgent   string
+		expected    string
+	}{
+		{
+			description: "chrome on windows",
+			userAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			expected:    `{"browsers":[{"brand":"Google Chrome","version":["124","0","0","0"]}],"platform":{"brand":"Windows"},"mobile":0,"source":3}`,
+		},
+		{
+			description: "safari on iphone",
+			userAgent:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+			expected:    `{"browsers":[{"brand":"Safari","version":["17","4","1"]}],"platform":{"brand":"iOS","version":["17","4","1"]},"mobile":1,"source":3}`,
+		},
+		{
+			description: "firefox on mac",
+			userAgent:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:125.0) Gecko/20100101 Firefox/125.0",
+			expected:    `{"browsers":[{"brand":"Firefox","version":["125","0"]}],"platform":{"brand":"macOS","version":["10","15"]},"mobile":0,"source":3}`,
+		},
+		{
+			description: "chrome on android",
+			userAgent:   "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			expected:    `{"browsers":[{"brand":"Google Chrome","version":["124","0","0","0"]}],"platform":{"brand":"Android","version":["10"]},"mobile":1,"source":3}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assertUserAgent(t, FromUserAgent(test.userAgent), test.expected)
+		})
+	}
+
+	for _, userAgent := range []string{"", "Googlebot/2.1 (+http://www.google.com/bot.html)"} {
+		if sua := FromUserAgent(userAgent); sua != nil {
+			t.Errorf("%q shouldn't get a structured user agent. Got %v", userAgent, sua)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Activities configures which components are allowed to perform activities which involve the user's data.
+//
+// Host is the host-wide config. Each entry in Accounts overrides it for a single account, one activity at a time:
+// if an account doesn't configure an activity, the host-wide rules for it are used.
+type Activities struct {
+	Host     ActivityControls    `mapstructure:"host"`
+	Accounts []AccountActivities `mapstructure:"accounts"`
+}
+
+// AccountActivities are the activity controls for a single account.
+// These are a list rather than a map because viper lowercases map keys, and account IDs are case sensitive.
+type AccountActivities struct {
+	ID         string           `mapstructure:"id"`
+	Activities ActivityControls `mapstructure:"activities"`
+}
+
+// ActivityControls has the rules for each activity.
+type ActivityControls struct {
+	// SyncUser controls which bidders can be returned from /cookie_sync.
+	SyncUser Activity `mapstructure:"sync_user"`
+	// FetchBids controls which bidders are called in an auction.
+	FetchBids Activity `mapstructure:"fetch_bids"`
+	// EnrichUFPD controls which components can add user first party data to a request.
+	EnrichUFPD Activity `mapstructure:"enrich_ufpd"`
+	// TransmitUFPD controls which bidders get the user's IDs and demographics.
+	TransmitUFPD Activity `mapstructure:"transmit_ufpd"`
+	// TransmitPreciseGeo controls which bidders get precise geolocation, and full IP addresses.
+	TransmitPreciseGeo Activity `mapstructure:"transmit_precise_geo"`
+	// TransmitTIDs controls which bidders get the transaction IDs.
+	TransmitTIDs Activity `mapstructure:"transmit_tids"`
+	// RandomizeTIDs makes bidders which can't get the transaction IDs get random ones instead, so that
+	// they can't be used to match up the requests sent to different bidders.
+	// If nil, they're removed.
+	RandomizeTIDs *bool `mapstructure:"randomize_tids"`
+}
+
+// Activity is a list of rules which are checked in order. The first rule which matches decides whether the
+// activity is allowed. If none of them match, Default is used. If Default is nil, the activity is allowed.
+type Activity struct {
+	Default *bool          `mapstructure:"default"`
+	Rules   []ActivityRule `mapstructure:"rules"`
+}
+
+// Configured returns true if any rules or a default are set for this activity.
+func (a *Activity) Configured() bool {
+	return a.Default != nil || len(a.Rules) > 0
+}
+
+// ActivityRule allows or denies an activity for the components which match its condition.
+type ActivityRule struct {
+	Allow     bool              `mapstructure:"allow"`
+	Condition ActivityCondition `mapstructure:"condition"`
+}
+
+// ActivityCondition matches components by bidder name, component name and/or GDPR vendor ID.
+// Bidders are matched by BidderNames, and the other components, like the enrichers, by ComponentNames.
+// Every non-empty field must match. A condition with no fields matches everything.
+type ActivityCondition struct {
+	BidderNames    []string `mapstructure:"bidder_names"`
+	ComponentNames []string `mapstructure:"component_names"`
+	GVLIDs         []int    `mapstructure:"gvl_ids"`
+}
+
+func (cfg *Activities) validate(errs configErrors) configErrors {
+	errs = cfg.Host.validate("activities.host", errs)
+	seen := make(map[string]bool, len(cfg.Accounts))
+	for i, account := range cfg.Accounts {
+		if account.ID == "" {
+			errs = append(errs, fmt.Errorf("activities.accounts[%d].id must not be empty", i))
+		} else if seen[account.ID] {
+			errs = append(errs, fmt.Errorf("activities.accounts has more than one entry for account %s", account.ID))
+		}
+		seen[account.ID] = true
+		errs = account.Activities.validate(fmt.Sprintf("activities.accounts[%d].activities", i), errs)
+	}
+	return errs
+}
+
+func (cfg *ActivityControls) validate(prefix string, errs configErrors) configErrors {
+	errs = cfg.SyncUser.validate(prefix+".sync_user", errs)
+	errs = cfg.FetchBids.validate(prefix+".fetch_bids", errs)
+	errs = cfg.EnrichUFPD.validate(prefix+".enrich_ufpd", errs)
+	errs = cfg.TransmitUFPD.validate(prefix+".transmit_ufpd", errs)
+	errs = cfg.TransmitPreciseGeo.validate(prefix+".transmit_precise_geo", errs)
+	return cfg.TransmitTIDs.validate(prefix+".transmit_tids", errs)
+}
+
+func (cfg *Activity) validate(prefix string, errs configErrors) configErrors {
+	for i, rule := range cfg.Rules {
+		for j, id := range rule.Condition.GVLIDs {
+			if id < 0 || id > 0xffff {
+				errs = append(errs, fmt.Errorf("%s.rules[%d].condition.gvl_ids[%d] must be in the range [0, %d]. Got %d", prefix, i, j, 0xffff, id))
+			}
+		}
+	}
+	return errs
+}
+
+// ForAccount returns the rules which apply to the given account.
+func (cfg *Activities) ForAccount(account string) ActivityControls {
+	controls := cfg.Host
+	for _, override := range cfg.Accounts {
+		if override.ID != account {
+			continue
+		}
+		for _, activity := range []struct{ host, account *Activity }{
+			{&controls.SyncUser, &override.Activities.SyncUser},
+			{&controls.FetchBids, &override.Activities.FetchBids},
+			{&controls.EnrichUFPD, &override.Activities.EnrichUFPD},
+			{&controls.TransmitUFPD, &override.Activities.TransmitUFPD},
+			{&controls.TransmitPreciseGeo, &override.Activities.TransmitPreciseGeo},
+			{&controls.TransmitTIDs, &override.Activities.TransmitTIDs},
+		} {
+			if activity.account.Configured() {
+				*activity.host = *activity.account
+			}
+		}
+		if override.Activities.RandomizeTIDs != nil {
+			controls.RandomizeTIDs = override.Activities.RandomizeTIDs
+		}
+		break
+	}
+	return controls
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+var activitiesConfig = []byte(`
+activities:
+  host:
+    fetch_bids:
+      default: true
+      rules:
+        - allow: false
+          condition:
+            bidder_names: ["appnexus"]
+    transmit_tids:
+      default: false
+  accounts:
+    - id: some-account
+      activities:
+        fetch_bids:
+          default: false
+        randomize_tids: true
+`)
+
+func TestActivitiesConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v)
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(activitiesConfig))
+	cfg, err := New(v)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	host := cfg.Activities.ForAccount("other-account")
+	if host.FetchBids.Default == nil || !*host.FetchBids.Default || len(host.FetchBids.Rules) != 1 {
+		t.Errorf("Host-wide fetch_bids rules should be used for unknown accounts. Got %#v", host.FetchBids)
+	}
+	cmpStrings(t, "activities.host.fetch_bids.rules[0].condition.bidder_names[0]", host.FetchBids.Rules[0].Condition.BidderNames[0], "appnexus")
+
+	account := cfg.Activities.ForAccount("some-account")
+	if account.FetchBids.Default == nil || *account.FetchBids.Default || len(account.FetchBids.Rules) != 0 {
+		t.Errorf("The account's fetch_bids rules should replace the host-wide ones. Got %#v", account.FetchBids)
+	}
+	if account.TransmitTIDs.Default == nil || *account.TransmitTIDs.Default {
+		t.Errorf("Activities which the account doesn't configure should use the host-wide rules. Got %#v", account.TransmitTIDs)
+	}
+	if host.RandomizeTIDs != nil || account.RandomizeTIDs == nil || !*account.RandomizeTIDs {
+		t.Errorf("randomize_tids should only be set for the account which configures it. Got %v, %v", host.RandomizeTIDs, account.RandomizeTIDs)
+	}
+	if account.SyncUser.Configured() {
+		t.Errorf("Activities which nobody configured should stay unconfigured. Got %#v", account.SyncUser)
+	}
+}
+
+func TestActivitiesBadGVLID(t *testing.T) {
+	cfg := Configuration{
+		Activities: Activities{
+			Host: ActivityControls{
+				SyncUser: Activity{
+					Rules: []ActivityRule{{Condition: ActivityCondition{GVLIDs: []int{-1}}}},
+				},
+			},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.activities rules should prevent negative GVL IDs, but they don't")
+	}
+}
+
+func TestActivitiesDuplicateAccounts(t *testing.T) {
+	cfg := Configuration{
+		Activities: Activities{
+			Accounts: []AccountActivities{{ID: "some-account"}, {ID: "some-account"}},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.activities.accounts should prevent duplicate accounts, but it doesn't")
+	}
+}
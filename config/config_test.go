@@ -2,6 +2,8 @@ package config
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -20,21 +22,135 @@ func TestDefaults(t *testing.T) {
 	cmpInts(t, "admin_port", cfg.AdminPort, 6060)
 	cmpInts(t, "auction_timeouts_ms.max", int(cfg.AuctionTimeouts.Max), 0)
 	cmpInts(t, "max_request_size", int(cfg.MaxRequestSize), 1024*256)
+	cmpInts(t, "setuid.max_redirect_length", cfg.SetUID.MaxRedirectLength, 2048)
+	cmpInts(t, "setuid.max_redirect_chain", cfg.SetUID.MaxRedirectChain, 3)
+	cmpInts(t, "cache.ttl.banner", cfg.CacheURL.TTL.Banner, 300)
+	cmpInts(t, "cache.ttl.video", cfg.CacheURL.TTL.Video, 3600)
+	cmpInts(t, "cache.ttl.max_seconds", cfg.CacheURL.TTL.MaxSeconds, 3600)
 	cmpInts(t, "host_cookie.ttl_days", int(cfg.HostCookie.TTL), 90)
 	cmpStrings(t, "datacache.type", cfg.DataCache.Type, "dummy")
 	cmpStrings(t, "adapters.pubmatic.endpoint", cfg.Adapters["pubmatic"].Endpoint, "http://hbopenbid.pubmatic.com/translator?source=prebid-server")
+	cmpStrings(t, "analytics.kafka.serialization", cfg.Analytics.Kafka.Serialization, "json")
+	cmpInts(t, "analytics.kafka.buffer_size", cfg.Analytics.Kafka.BufferSize, 10000)
+	cmpInts(t, "metrics.accounts.max_accounts", cfg.Metrics.Accounts.MaxAccounts, -1)
+	cmpBools(t, "metrics.accounts limited", cfg.Metrics.Accounts.Limited(), false)
+	cmpBools(t, "tracing.enabled", cfg.Tracing.Enabled, false)
+	cmpStrings(t, "tracing.service_name", cfg.Tracing.ServiceName, "prebid-server")
+	cmpBools(t, "profiling.enabled", cfg.Profiling.Enabled, true)
+	cmpStrings(t, "profiling.token", cfg.Profiling.Token, "")
+	cmpStrings(t, "admin.token", cfg.Admin.Token, "")
+	cmpBools(t, "partial_responses.enabled", cfg.PartialResponses.Enabled, false)
+	cmpStrings(t, "tie_breaking.strategy", cfg.TieBreaking.Strategy, "random")
+	cmpBools(t, "debug.redact", cfg.Debug.Redact, false)
+	cmpStrings(t, "currency_converter.fetch_url", cfg.CurrencyConverter.FetchURL, "")
+	cmpInts(t, "no_bids.min_bids_to_respond", cfg.NoBids.MinBidsToRespond, 0)
+	cmpBools(t, "response_capture.enabled", cfg.ResponseCapture.Enabled, false)
+	cmpInts(t, "response_capture.size", cfg.ResponseCapture.Size, 10)
+	cmpStrings(t, "alerts.webhook_url", cfg.Alerts.WebhookURL, "")
+	cmpInts(t, "alerts.check_interval_seconds", cfg.Alerts.CheckIntervalSeconds, 60)
+	cmpInts(t, "alerts.bidder_error_rate.min_requests", cfg.Alerts.BidderErrorRate.MinRequests, 100)
+	cmpInts(t, "alerts.stale_currency_hours", cfg.Alerts.StaleCurrencyHours, 24)
+	cmpInts(t, "alerts.vendor_list_failures", cfg.Alerts.VendorListFailures, 3)
+	cmpInts(t, "stored_requests.category_refresh_rate_seconds", cfg.StoredRequests.CategoryRefreshRate, 300)
+	if cfg.PriceCeilings.MaxCPM != 0 {
+		t.Errorf("price_ceilings.max_cpm: expected 0. Got %f", cfg.PriceCeilings.MaxCPM)
+	}
+	cmpInts(t, "failover.min_remaining_ms", cfg.Failover.MinRemainingMillis, 50)
+	if cfg.RevenueShare.Percent != 0 {
+		t.Errorf("revenue_share.percent: expected 0. Got %f", cfg.RevenueShare.Percent)
+	}
+	cmpStrings(t, "bid_validation.vast", cfg.BidValidation.VAST, "skip")
+	cmpBools(t, "accounts.reject_unknown", cfg.Accounts.RejectUnknown, false)
+	cmpStrings(t, "accounts.debug", cfg.Accounts.Debug, "allow")
+	cmpStrings(t, "accounts.debug_token", cfg.Accounts.DebugToken, "")
+	cmpBools(t, "blocklist.enabled", cfg.Blocklist.Enabled, false)
+	cmpStrings(t, "blocklist.file", cfg.Blocklist.File, "")
+	cmpStrings(t, "blocklist.query", cfg.Blocklist.Query, "")
+	cmpInts(t, "blocklist.refresh_seconds", cfg.Blocklist.RefreshSeconds, 300)
+	cmpBools(t, "invalid_traffic.enabled", cfg.InvalidTraffic.Enabled, false)
+	cmpStrings(t, "invalid_traffic.action", cfg.InvalidTraffic.Action, "flag")
+	cmpInts(t, "invalid_traffic.user_agents", len(cfg.InvalidTraffic.UserAgents), 0)
+	cmpInts(t, "invalid_traffic.datacenter_ranges", len(cfg.InvalidTraffic.DatacenterRanges), 0)
+	cmpStrings(t, "invalid_traffic.datacenter_ranges_file", cfg.InvalidTraffic.DatacenterRangesFile, "")
+	cmpInts(t, "invalid_traffic.max_requests_per_ip", cfg.InvalidTraffic.MaxRequestsPerIP, 0)
+	cmpInts(t, "invalid_traffic.window_seconds", cfg.InvalidTraffic.WindowSeconds, 60)
+	cmpInts(t, "invalid_traffic.max_tracked_ips", cfg.InvalidTraffic.MaxTrackedIPs, 100000)
+	cmpInts(t, "response_size.max_bytes", cfg.ResponseSize.MaxBytes, 0)
+	cmpInts(t, "rtd.max_timeout_ms", cfg.RTD.MaxTimeoutMillis, 50)
+	cmpBools(t, "ads_txt.enabled", cfg.AdsTxt.Enabled, false)
+	cmpStrings(t, "ads_txt.action", cfg.AdsTxt.Action, "annotate")
+	cmpInts(t, "ads_txt.refresh_hours", cfg.AdsTxt.RefreshHours, 24)
+	cmpInts(t, "ads_txt.timeout_ms", cfg.AdsTxt.TimeoutMillis, 2000)
+	cmpInts(t, "ads_txt.max_domains", cfg.AdsTxt.MaxDomains, 100000)
+	cmpStrings(t, "creative_scanning.endpoint", cfg.CreativeScanning.Endpoint, "")
+	cmpInts(t, "creative_scanning.timeout_ms", cfg.CreativeScanning.TimeoutMillis, 20)
+	cmpBools(t, "creative_scanning.fail_closed", cfg.CreativeScanning.FailClosed, false)
+	cmpInts(t, "imp_limits.max", cfg.ImpLimits.Max, 0)
+	cmpStrings(t, "imp_limits.action", cfg.ImpLimits.Action, "reject")
+	cmpStrings(t, "request_validation.mode", cfg.RequestValidation.Mode, "lenient")
+	cmpStrings(t, "adapter_tls.min_version", cfg.AdapterTLS.MinVersion, "1.2")
+	cmpBools(t, "adapter_tls.verify_certificates", cfg.AdapterTLS.VerifyCertificates, true)
+	cmpStrings(t, "adapter_proxy.url", cfg.AdapterProxy.URL, "")
+	cmpBools(t, "adapter_dedup.enabled", cfg.AdapterDedup.Enabled, false)
+	cmpBools(t, "request_trimming.enabled", cfg.RequestTrimming.Enabled, false)
+	cmpBools(t, "auction_dedup.enabled", cfg.AuctionDedup.Enabled, false)
+	cmpInts(t, "auction_dedup.window_ms", cfg.AuctionDedup.WindowMillis, 2000)
+	cmpInts(t, "auction_dedup.max_entries", cfg.AuctionDedup.MaxEntries, 10000)
+	cmpBools(t, "snapshots.enabled", cfg.Snapshots.Enabled, false)
+	cmpStrings(t, "snapshots.dir", cfg.Snapshots.Dir, "")
+	cmpInts(t, "snapshots.max_files", cfg.Snapshots.MaxFiles, 1000)
+	if cfg.Snapshots.SampleRate != 0.001 {
+		t.Errorf("snapshots.sample_rate: expected 0.001, got %f", cfg.Snapshots.SampleRate)
+	}
+	cmpBools(t, "app_enrichment.enabled", cfg.AppEnrichment.Enabled, false)
+	cmpInts(t, "app_enrichment.timeout_ms", cfg.AppEnrichment.TimeoutMillis, 1000)
+	cmpInts(t, "app_enrichment.cache_size", cfg.AppEnrichment.CacheSize, 100000)
+	cmpInts(t, "app_enrichment.cache_ttl_seconds", cfg.AppEnrichment.CacheTTLSeconds, 86400)
+	cmpBools(t, "geo_enrichment.enabled", cfg.GeoEnrichment.Enabled, false)
+	cmpBools(t, "client_hints.from_user_agent", cfg.ClientHints.FromUserAgent, false)
+	cmpStrings(t, "privacy_defaults.gdpr", cfg.PrivacyDefaults.GDPR, "")
+	cmpInts(t, "gdpr.consent_cache.size", cfg.GDPR.ConsentCache.Size, 1000)
+	cmpInts(t, "gdpr.consent_cache.ttl_seconds", cfg.GDPR.ConsentCache.TTLSeconds, 300)
+	cmpBools(t, "privacy_defaults.ccpa_opt_out", cfg.PrivacyDefaults.CCPAOptOut, false)
+	cmpStrings(t, "logging.level", cfg.Logging.Level, "info")
+	cmpStrings(t, "logging.format", cfg.Logging.Format, "console")
+	cmpStrings(t, "logging.output", cfg.Logging.Output, "stderr")
+	cmpInts(t, "logging.file.max_size_mb", cfg.Logging.File.MaxSizeMB, 100)
 }
 
 var fullConfig = []byte(`
 gdpr:
   host_vendor_id: 15
   usersync_if_ambiguous: true
+  vendorlist_cache_dir: /var/cache/prebid-server
+  purpose2:
+    enforce: basic
+  purpose4:
+    enforce: full
+    vendor_exceptions: ["appnexus"]
+  special_feature1:
+    enforce: true
+buyeruids:
+  drop_expired: true
+  skip_unmatched_bidders: true
+  skip_exceptions: ["appnexus"]
+currency_converter:
+  fetch_url: https://currency.example.com/rates.json
+  fetch_interval_seconds: 600
+  intermediate_currency: EUR
+cookie_deprecation:
+  enabled: true
+  ttl_sec: 3600
 host_cookie:
   cookie_name: userid
   family: prebid
   domain: cookies.prebid.org
   opt_out_url: http://prebid.org/optout
   opt_in_url: http://prebid.org/optin
+  attributes:
+    same_site: None
+    secure: true
+    partitioned: true
 external_url: http://prebid-server.prebid.org/
 host: prebid-server.prebid.org
 port: 1234
@@ -46,6 +162,12 @@ cache:
   scheme: http
   host: prebidcache.net
   query: uuid=%PBS_CACHE_UUID%
+  backend: redis
+  max_batch_size: 10
+  redis:
+    addr: redis.prebidcache.net:6379
+    ttl_seconds: 600
+    key_prefix: "pbs:"
 recaptcha_secret: asdfasdfasdfasdf
 metrics:
   influxdb:
@@ -53,6 +175,30 @@ metrics:
     database: metricsdb
     username: admin
     password: admin1324
+  accounts:
+    allowlist: ["1001"]
+    max_accounts: 50
+tracing:
+  enabled: true
+  otlp_endpoint: otel-collector:4318
+  insecure: true
+  sample_rate: 0.25
+  service_name: prebid-server-east
+profiling:
+  enabled: false
+  token: s3cret
+admin:
+  token: adm1n
+logging:
+  level: debug
+  format: json
+  output: file
+  file:
+    path: /var/log/prebid-server.log
+    max_size_mb: 50
+    max_backups: 3
+    max_age_days: 14
+    compress: true
 datacache:
   type: postgres
   filename: /usr/db/db.db
@@ -73,9 +219,24 @@ adapters:
     endpoint: http://facebook.com/pbs
     usersync_url: http://facebook.com/ortb/prebid-s2s
     platform_id: abcdefgh1234
+    extra_info: "{\"platform_id\":\"ijkl5678\"}"
   brightroll:
     usersync_url: http://east-bid.ybp.yahoo.com/sync/appnexuspbs?gdpr={{gdpr}}&euconsent={{gdpr_consent}}&url=%s
     endpoint: http://east-bid.ybp.yahoo.com/bid/appnexuspbs
+    generate_vast_wrapper: true
+    headers:
+      x-openrtb-version: "2.5"
+    forward_headers: ["User-Agent"]
+    retry_connection_errors: true
+    max_imps_per_request: 10
+    max_response_bytes: 65536
+    client_certificate:
+      cert_file: /etc/prebid/brightroll.crt
+      key_file: /etc/prebid/brightroll.key
+    proxy_url: http://proxy.example.com:3128
+  districtm:
+    alias_of: appnexus
+    usersync_url: http://districtm.example.com/sync
 `)
 
 func cmpStrings(t *testing.T, key string, a string, b string) {
@@ -113,6 +274,10 @@ func TestFullConfig(t *testing.T) {
 	cmpStrings(t, "cookie family", cfg.HostCookie.Family, "prebid")
 	cmpStrings(t, "opt out", cfg.HostCookie.OptOutURL, "http://prebid.org/optout")
 	cmpStrings(t, "opt in", cfg.HostCookie.OptInURL, "http://prebid.org/optin")
+	cmpStrings(t, "cookie same_site", cfg.HostCookie.Attributes.SameSite, "None")
+	cmpBools(t, "cookie secure", cfg.HostCookie.Attributes.Secure, true)
+	cmpBools(t, "cookie partitioned", cfg.HostCookie.Attributes.Partitioned, true)
+	cmpBools(t, "cookie max_age", cfg.HostCookie.Attributes.MaxAge, false)
 	cmpStrings(t, "external url", cfg.ExternalURL, "http://prebid-server.prebid.org/")
 	cmpStrings(t, "host", cfg.Host, "prebid-server.prebid.org")
 	cmpInts(t, "port", cfg.Port, 1234)
@@ -122,13 +287,55 @@ func TestFullConfig(t *testing.T) {
 	cmpStrings(t, "cache.scheme", cfg.CacheURL.Scheme, "http")
 	cmpStrings(t, "cache.host", cfg.CacheURL.Host, "prebidcache.net")
 	cmpStrings(t, "cache.query", cfg.CacheURL.Query, "uuid=%PBS_CACHE_UUID%")
+	cmpStrings(t, "cache.backend", cfg.CacheURL.Backend, "redis")
+	cmpInts(t, "cache.max_batch_size", cfg.CacheURL.MaxBatchSize, 10)
+	cmpInts(t, "cache.async_timeout_ms", cfg.CacheURL.AsyncTimeoutMillis, 1000)
+	cmpBools(t, "cache.allow_async", cfg.CacheURL.AllowAsync, false)
+	cmpStrings(t, "cache.redis.addr", cfg.CacheURL.Redis.Addr, "redis.prebidcache.net:6379")
+	cmpInts(t, "cache.redis.ttl_seconds", cfg.CacheURL.Redis.TTLSeconds, 600)
+	cmpStrings(t, "cache.redis.key_prefix", cfg.CacheURL.Redis.KeyPrefix, "pbs:")
 	cmpInts(t, "gdpr.host_vendor_id", cfg.GDPR.HostVendorID, 15)
 	cmpBools(t, "gdpr.usersync_if_ambiguous", cfg.GDPR.UsersyncIfAmbiguous, true)
+	cmpStrings(t, "gdpr.vendorlist_cache_dir", cfg.GDPR.VendorListCacheDir, "/var/cache/prebid-server")
+	cmpStrings(t, "gdpr.purpose2.enforce", cfg.GDPR.Purpose2.Enforce, "basic")
+	cmpStrings(t, "gdpr.purpose3.enforce", cfg.GDPR.Purpose3.Enforce, "off")
+	cmpStrings(t, "gdpr.purpose4.enforce", cfg.GDPR.Purpose4.Enforce, "full")
+	cmpInts(t, "gdpr.purpose4.vendor_exceptions", len(cfg.GDPR.Purpose4.VendorExceptions), 1)
+	cmpBools(t, "gdpr.special_feature1.enforce", cfg.GDPR.SpecialFeature1.Enforce, true)
+	cmpBools(t, "lmt.enforce", cfg.LMT.Enforce, true)
+	cmpBools(t, "cookie_deprecation.enabled", cfg.CookieDeprecation.Enabled, true)
+	cmpBools(t, "buyeruids.drop_expired", cfg.BuyerUIDs.DropExpired, true)
+	cmpBools(t, "buyeruids.skip_unmatched_bidders", cfg.BuyerUIDs.SkipUnmatchedBidders, true)
+	cmpInts(t, "buyeruids.skip_exceptions", len(cfg.BuyerUIDs.SkipExceptions), 1)
+	cmpStrings(t, "currency_converter.fetch_url", cfg.CurrencyConverter.FetchURL, "https://currency.example.com/rates.json")
+	cmpInts(t, "currency_converter.fetch_interval_seconds", cfg.CurrencyConverter.FetchIntervalSeconds, 600)
+	cmpStrings(t, "currency_converter.intermediate_currency", cfg.CurrencyConverter.IntermediateCurrency, "EUR")
+	cmpInts(t, "cookie_deprecation.ttl_sec", cfg.CookieDeprecation.TTLSec, 3600)
 	cmpStrings(t, "recaptcha_secret", cfg.RecaptchaSecret, "asdfasdfasdfasdf")
 	cmpStrings(t, "metrics.influxdb.host", cfg.Metrics.Influxdb.Host, "upstream:8232")
 	cmpStrings(t, "metrics.influxdb.database", cfg.Metrics.Influxdb.Database, "metricsdb")
 	cmpStrings(t, "metrics.influxdb.username", cfg.Metrics.Influxdb.Username, "admin")
 	cmpStrings(t, "metrics.influxdb.password", cfg.Metrics.Influxdb.Password, "admin1324")
+	cmpInts(t, "metrics.accounts.allowlist", len(cfg.Metrics.Accounts.Allowlist), 1)
+	cmpInts(t, "metrics.accounts.max_accounts", cfg.Metrics.Accounts.MaxAccounts, 50)
+	cmpBools(t, "tracing.enabled", cfg.Tracing.Enabled, true)
+	cmpStrings(t, "tracing.otlp_endpoint", cfg.Tracing.Endpoint, "otel-collector:4318")
+	cmpBools(t, "tracing.insecure", cfg.Tracing.Insecure, true)
+	cmpStrings(t, "tracing.service_name", cfg.Tracing.ServiceName, "prebid-server-east")
+	cmpBools(t, "profiling.enabled", cfg.Profiling.Enabled, false)
+	cmpStrings(t, "profiling.token", cfg.Profiling.Token, "s3cret")
+	cmpStrings(t, "admin.token", cfg.Admin.Token, "adm1n")
+	cmpStrings(t, "logging.level", cfg.Logging.Level, "debug")
+	cmpStrings(t, "logging.format", cfg.Logging.Format, "json")
+	cmpStrings(t, "logging.output", cfg.Logging.Output, "file")
+	cmpStrings(t, "logging.file.path", cfg.Logging.File.Path, "/var/log/prebid-server.log")
+	cmpInts(t, "logging.file.max_size_mb", cfg.Logging.File.MaxSizeMB, 50)
+	cmpInts(t, "logging.file.max_backups", cfg.Logging.File.MaxBackups, 3)
+	cmpInts(t, "logging.file.max_age_days", cfg.Logging.File.MaxAgeDays, 14)
+	cmpBools(t, "logging.file.compress", cfg.Logging.File.Compress, true)
+	if cfg.Tracing.SampleRate != 0.25 {
+		t.Errorf("tracing.sample_rate: expected 0.25, got %f", cfg.Tracing.SampleRate)
+	}
 	cmpStrings(t, "datacache.type", cfg.DataCache.Type, "postgres")
 	cmpStrings(t, "datacache.filename", cfg.DataCache.Filename, "/usr/db/db.db")
 	cmpInts(t, "datacache.cache_size", cfg.DataCache.CacheSize, 10000000)
@@ -144,8 +351,23 @@ func TestFullConfig(t *testing.T) {
 	cmpStrings(t, "adapters.facebook.endpoint", cfg.Adapters["facebook"].Endpoint, "http://facebook.com/pbs")
 	cmpStrings(t, "adapters.facebook.usersync_url", cfg.Adapters["facebook"].UserSyncURL, "http://facebook.com/ortb/prebid-s2s")
 	cmpStrings(t, "adapters.facebook.platform_id", cfg.Adapters["facebook"].PlatformID, "abcdefgh1234")
+	cmpStrings(t, "adapters.facebook.extra_info", cfg.Adapters["facebook"].ExtraInfo, `{"platform_id":"ijkl5678"}`)
 	cmpStrings(t, "adapters.brightroll.endpoint", cfg.Adapters["brightroll"].Endpoint, "http://east-bid.ybp.yahoo.com/bid/appnexuspbs")
 	cmpStrings(t, "adapters.brightroll.usersync_url", cfg.Adapters["brightroll"].UserSyncURL, "http://east-bid.ybp.yahoo.com/sync/appnexuspbs?gdpr={{gdpr}}&euconsent={{gdpr_consent}}&url=%s")
+	cmpBools(t, "adapters.brightroll.generate_vast_wrapper", cfg.Adapters["brightroll"].GenerateVASTWrapper, true)
+	cmpBools(t, "adapters.appnexus.generate_vast_wrapper", cfg.Adapters["appnexus"].GenerateVASTWrapper, false)
+	cmpStrings(t, "adapters.brightroll.headers", cfg.Adapters["brightroll"].Headers["x-openrtb-version"], "2.5")
+	cmpInts(t, "adapters.brightroll.forward_headers", len(cfg.Adapters["brightroll"].ForwardHeaders), 1)
+	cmpBools(t, "adapters.brightroll.retry_connection_errors", cfg.Adapters["brightroll"].RetryConnectionErrors, true)
+	cmpInts(t, "adapters.brightroll.max_imps_per_request", cfg.Adapters["brightroll"].MaxImpsPerRequest, 10)
+	cmpInts(t, "adapters.brightroll.max_response_bytes", cfg.Adapters["brightroll"].MaxResponseBytes, 65536)
+	cmpStrings(t, "adapters.brightroll.client_certificate.cert_file", cfg.Adapters["brightroll"].ClientCertificate.CertFile, "/etc/prebid/brightroll.crt")
+	cmpStrings(t, "adapters.brightroll.client_certificate.key_file", cfg.Adapters["brightroll"].ClientCertificate.KeyFile, "/etc/prebid/brightroll.key")
+	cmpStrings(t, "adapters.brightroll.proxy_url", cfg.Adapters["brightroll"].ProxyURL, "http://proxy.example.com:3128")
+	cmpStrings(t, "adapters.districtm.alias_of", cfg.Adapters["districtm"].AliasOf, "appnexus")
+	cmpStrings(t, "adapters.districtm.endpoint", cfg.Adapters["districtm"].Endpoint, "http://ib.adnxs.com/some/endpoint")
+	cmpStrings(t, "adapters.districtm.usersync_url", cfg.Adapters["districtm"].UserSyncURL, "http://districtm.example.com/sync")
+	cmpStrings(t, "districtm alias", cfg.AdapterAliases()["districtm"], "appnexus")
 }
 
 func TestValidConfig(t *testing.T) {
@@ -173,6 +395,72 @@ func TestNegativeRequestSize(t *testing.T) {
 	}
 }
 
+func TestCacheBackend(t *testing.T) {
+	cfg := Configuration{
+		StoredRequests: StoredRequests{
+			InMemoryCache: InMemoryCache{
+				Type: "none",
+			},
+		},
+		CacheURL: Cache{
+			Backend: "memcached",
+		},
+	}
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.cache.backend should only allow known backends, but it doesn't")
+	}
+
+	cfg.CacheURL.Backend = CacheBackendRedis
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.cache.redis.addr should be required for the redis backend, but it isn't")
+	}
+
+	cfg.CacheURL.Redis = CacheRedis{
+		Addr:       "localhost:6379",
+		TTLSeconds: 300,
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("The redis backend should be valid with an address and TTL. %v", err)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cfg := CacheTTL{
+		Banner:     300,
+		Video:      3600,
+		MaxSeconds: 1800,
+		Accounts:   []AccountCacheTTL{{ID: "1001", Banner: 60}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.cache.ttl: %v", errs)
+	}
+	cmpInts(t, "banner TTL", int(cfg.SecondsFor("1002", "banner", 0)), 300)
+	cmpInts(t, "banner TTL for 1001", int(cfg.SecondsFor("1001", "banner", 0)), 60)
+	cmpInts(t, "video TTL for 1001", int(cfg.SecondsFor("1001", "video", 0)), 1800)
+	cmpInts(t, "audio TTL", int(cfg.SecondsFor("1002", "audio", 0)), 0)
+	cmpInts(t, "bid.exp", int(cfg.SecondsFor("1001", "banner", 900)), 900)
+	cmpInts(t, "capped bid.exp", int(cfg.SecondsFor("1001", "banner", 7200)), 1800)
+
+	cfg.Native = -1
+	cfg.MaxSeconds = -1
+	cfg.Accounts = []AccountCacheTTL{{ID: "1001", Video: -1}, {ID: "1001"}, {}}
+	if errs := cfg.validate(nil); len(errs) != 5 {
+		t.Errorf("cfg.cache.ttl should reject negative TTLs, and duplicate and empty account IDs. Got %v", errs)
+	}
+}
+
+func TestNegativeCurrencyFetchInterval(t *testing.T) {
+	cfg := Configuration{
+		CurrencyConverter: CurrencyConverter{
+			FetchIntervalSeconds: -1,
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.currency_converter.fetch_interval_seconds should prevent negative values, but it doesn't")
+	}
+}
+
 func TestNegativeVendorID(t *testing.T) {
 	cfg := Configuration{
 		GDPR: GDPR{
@@ -197,6 +485,1165 @@ func TestOverflowedVendorID(t *testing.T) {
 	}
 }
 
+func TestBadPurposeEnforcement(t *testing.T) {
+	cfg := Configuration{
+		GDPR: GDPR{
+			Purpose3: TCFPurpose{
+				Enforce: "strict",
+			},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.gdpr.purpose3.enforce should only allow full, basic and off, but it doesn't")
+	}
+}
+
+func TestNegativeConsentCache(t *testing.T) {
+	cfg := GDPR{
+		ConsentCache: GDPRConsentCache{
+			Size:       -1,
+			TTLSeconds: -1,
+		},
+	}
+
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.gdpr.consent_cache should prevent negative values. Got %v", errs)
+	}
+}
+
+func TestLMTAccountOverrides(t *testing.T) {
+	cfg := LMT{
+		Enforce: true,
+		Accounts: []AccountLMT{
+			{ID: "opted-out", Enforce: false},
+		},
+	}
+	cmpBools(t, "lmt for overridden account", cfg.Enforced("opted-out"), false)
+	cmpBools(t, "lmt for other accounts", cfg.Enforced("other"), true)
+
+	cfg.Accounts = append(cfg.Accounts, AccountLMT{ID: "opted-out", Enforce: true})
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("cfg.lmt.accounts should prevent duplicate accounts, but it doesn't")
+	}
+}
+
+func TestPartialResponsesAccountOverrides(t *testing.T) {
+	cfg := PartialResponses{
+		Accounts: []AccountPartialResponses{
+			{ID: "fast-pub", Enabled: true},
+		},
+	}
+	cmpBools(t, "partial_responses for overridden account", cfg.EnabledFor("fast-pub"), true)
+	cmpBools(t, "partial_responses for other accounts", cfg.EnabledFor("other"), false)
+
+	cfg.Accounts = append(cfg.Accounts, AccountPartialResponses{ID: ""})
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("cfg.partial_responses.accounts should require an id, but it doesn't")
+	}
+}
+
+func TestImpLimitsValidation(t *testing.T) {
+	cfg := ImpLimits{Max: -1, Action: "drop"}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.imp_limits should reject negative maximums and unknown actions. Got %v", errs)
+	}
+	cfg = ImpLimits{Max: 10, Action: ImpLimitTruncate}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.imp_limits: %v", errs)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	cfg := RequestValidation{
+		Mode:     ValidationLenient,
+		Accounts: []AccountRequestValidation{{ID: "strict-account", Mode: ValidationStrict}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.request_validation: %v", errs)
+	}
+	cmpBools(t, "request_validation for strict-account", cfg.StrictFor("strict-account"), true)
+	cmpBools(t, "request_validation for other accounts", cfg.StrictFor("other-account"), false)
+
+	cfg.Mode = "loose"
+	cfg.Accounts = append(cfg.Accounts, AccountRequestValidation{ID: "strict-account", Mode: ValidationLenient})
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.request_validation should reject unknown modes and duplicate accounts. Got %v", errs)
+	}
+}
+
+func TestTieBreaking(t *testing.T) {
+	cfg := TieBreaking{Strategy: TieBreakBidderPriority, BidderPriority: []string{"appnexus", "rubicon"}}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.tie_breaking: %v", errs)
+	}
+
+	cfg.BidderPriority = nil
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.tie_breaking should require a bidder_priority list for the bidder_priority strategy. Got %v", errs)
+	}
+
+	cfg = TieBreaking{Strategy: "alphabetical", BidderPriority: []string{"appnexus", "", "appnexus"}}
+	if errs := cfg.validate(nil); len(errs) != 3 {
+		t.Errorf("cfg.tie_breaking should reject unknown strategies, empty bidders and duplicate bidders. Got %v", errs)
+	}
+}
+
+func TestDebugRedaction(t *testing.T) {
+	redact := false
+	cfg := Debug{Redact: true}
+	accounts := Accounts{Known: []Account{{ID: "own-site", RedactDebug: &redact}, {ID: "publisher"}}}
+	cmpBools(t, "debug.redact for own-site", cfg.RedactFor(accounts.Lookup("own-site")), false)
+	cmpBools(t, "debug.redact for accounts without a setting", cfg.RedactFor(accounts.Lookup("publisher")), true)
+	cmpBools(t, "debug.redact for unknown accounts", cfg.RedactFor(accounts.Lookup("other")), true)
+}
+
+func TestPriceCeilings(t *testing.T) {
+	cfg := PriceCeilings{
+		MaxCPM:  100,
+		Bidders: map[string]float64{"appnexus": 50},
+		Accounts: []AccountPriceCeilings{{
+			ID:      "premium",
+			MaxCPM:  500,
+			Bidders: map[string]float64{"rubicon": 0},
+		}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.price_ceilings: %v", errs)
+	}
+	testCases := []struct {
+		account  string
+		bidder   string
+		expected float64
+	}{
+		{"other", "appnexus", 50},
+		{"other", "rubicon", 100},
+		{"premium", "appnexus", 500},
+		{"premium", "rubicon", 0},
+	}
+	for _, test := range testCases {
+		if actual := cfg.CeilingFor(test.account, test.bidder); actual != test.expected {
+			t.Errorf("price_ceilings for %s on %s: expected %f. Got %f", test.bidder, test.account, test.expected, actual)
+		}
+	}
+
+	cfg.MaxCPM = -1
+	cfg.Accounts = append(cfg.Accounts, AccountPriceCeilings{Bidders: map[string]float64{"appnexus": -5}}, AccountPriceCeilings{ID: "premium"})
+	if errs := cfg.validate(nil); len(errs) != 4 {
+		t.Errorf("cfg.price_ceilings should reject negative ceilings, empty accounts and duplicate accounts. Got %v", errs)
+	}
+}
+
+func TestBlocking(t *testing.T) {
+	cfg := Blocking{
+		Blocked: BlockedLists{BAdv: []string{"competitor.com"}, BCat: []string{"IAB25"}},
+		Bidders: map[string]BlockedLists{
+			"appnexus": {BAttr: []int{1, 2}},
+			"rubicon":  {},
+		},
+	}
+	if errs := cfg.validate(nil, 0); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].blocking: %v", errs)
+	}
+	if blocked := cfg.BlockedFor("openx"); blocked == nil || len(blocked.BAdv) != 1 || len(blocked.BCat) != 1 {
+		t.Errorf("Bidders without their own lists should get the account's. Got %v", blocked)
+	}
+	if blocked := cfg.BlockedFor("appnexus"); blocked == nil || len(blocked.BAdv) != 0 || len(blocked.BAttr) != 2 {
+		t.Errorf("A bidder's own lists should replace the account's. Got %v", blocked)
+	}
+	if blocked := cfg.BlockedFor("rubicon"); blocked != nil {
+		t.Errorf("A bidder with empty lists shouldn't have any blocks. Got %v", blocked)
+	}
+	if blocked := (&Blocking{}).BlockedFor("openx"); blocked != nil {
+		t.Errorf("Accounts without blocking shouldn't have any blocks. Got %v", blocked)
+	}
+
+	cfg.Blocked.BAttr = []int{18}
+	cfg.Bidders["appnexus"] = BlockedLists{BAdv: []string{""}}
+	if errs := cfg.validate(nil, 0); len(errs) != 2 {
+		t.Errorf("cfg.accounts.known[].blocking should reject unknown attributes and empty domains. Got %v", errs)
+	}
+	accounts := Accounts{Known: []Account{{ID: "publisher", Blocking: cfg}}}
+	if errs := accounts.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.accounts should validate each account's blocking. Got %v", errs)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	cfg := Failover{MinRemainingMillis: 50}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.failover: %v", errs)
+	}
+	cfg.MinRemainingMillis = -1
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.failover should reject a negative min_remaining_ms. Got %v", errs)
+	}
+
+	accounts := Accounts{Known: []Account{{
+		ID:       "low-fill",
+		Failover: []FailoverGroup{{Primary: "appnexus", Fallback: "rubicon"}, {Primary: "pubmatic", Fallback: "openx"}},
+	}}}
+	if errs := accounts.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].failover: %v", errs)
+	}
+	accounts.Known[0].Failover = []FailoverGroup{
+		{Primary: "appnexus"},
+		{Primary: "appnexus", Fallback: "appnexus"},
+		{Primary: "appnexus", Fallback: "rubicon"},
+		{Primary: "pubmatic", Fallback: "rubicon"},
+		{Primary: "openx", Fallback: "pubmatic"},
+	}
+	if errs := accounts.validate(nil); len(errs) != 4 {
+		t.Errorf("cfg.accounts.known[].failover should reject groups without both bidders, bidders which are their own fallback, and chained or shared fallbacks. Got %v", errs)
+	}
+}
+
+func TestPinnedDeals(t *testing.T) {
+	cfg := Accounts{Known: []Account{{ID: "publisher", PinnedDeals: PinnedDeals{DealIDs: []string{"pg-1", "pg-2"}, EarlyTermination: true}}}}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].pinned_deals: %v", errs)
+	}
+
+	cfg.Known[0].PinnedDeals.DealIDs = append(cfg.Known[0].PinnedDeals.DealIDs, "")
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.accounts.known[].pinned_deals should reject empty deal IDs. Got %v", errs)
+	}
+}
+
+func TestAppEnrichmentValidation(t *testing.T) {
+	cfg := AppEnrichment{Enabled: true, Endpoint: "http://apps.example.com/metadata", TimeoutMillis: 500, CacheSize: 1000}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.app_enrichment: %v", errs)
+	}
+	cfg = AppEnrichment{Enabled: true, Endpoint: "apps.example.com", CacheTTLSeconds: -1}
+	if errs := cfg.validate(nil); len(errs) != 4 {
+		t.Errorf("cfg.app_enrichment should reject bad endpoints, missing timeouts and cache sizes, and negative TTLs. Got %v", errs)
+	}
+	cfg.Enabled = false
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.app_enrichment shouldn't be validated if it's disabled. Got %v", errs)
+	}
+}
+
+func TestGeoEnrichmentValidation(t *testing.T) {
+	cfg := GeoEnrichment{Enabled: true, DatabaseFile: "/usr/share/GeoIP/GeoLite2-City.mmdb"}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.geo_enrichment: %v", errs)
+	}
+	cfg.DatabaseFile = ""
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.geo_enrichment should require a database_file. Got %v", errs)
+	}
+	cfg.Enabled = false
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.geo_enrichment shouldn't be validated if it's disabled. Got %v", errs)
+	}
+}
+
+func TestRevenueShare(t *testing.T) {
+	cfg := RevenueShare{
+		Percent:  10,
+		Accounts: []AccountRevenueShare{{ID: "1001", Percent: 15.5}, {ID: "1002"}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.revenue_share: %v", errs)
+	}
+	testCases := map[string]float64{"1001": 15.5, "1002": 0, "1003": 10}
+	for account, expected := range testCases {
+		if actual := cfg.PercentFor(account); actual != expected {
+			t.Errorf("revenue_share for %s: expected %f. Got %f", account, expected, actual)
+		}
+	}
+
+	cfg = RevenueShare{
+		Percent:  100,
+		Accounts: []AccountRevenueShare{{ID: "1001", Percent: -1}, {ID: "1001"}, {}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 4 {
+		t.Errorf("cfg.revenue_share should reject percentages outside [0, 100), and duplicate and empty account IDs. Got %v", errs)
+	}
+}
+
+func TestBidValidation(t *testing.T) {
+	cfg := BidValidation{VAST: BidValidationWarn}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.bid_validation: %v", errs)
+	}
+	accounts := Accounts{Known: []Account{
+		{ID: "1001", BidValidation: AccountBidValidation{VAST: BidValidationEnforce}},
+		{ID: "1002", BidValidation: AccountBidValidation{VAST: BidValidationSkip}},
+		{ID: "1003"},
+	}}
+	if errs := accounts.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].bid_validation: %v", errs)
+	}
+	cmpStrings(t, "bid_validation.vast for 1001", cfg.VASTFor(accounts.Lookup("1001")), BidValidationEnforce)
+	cmpStrings(t, "bid_validation.vast for 1002", cfg.VASTFor(accounts.Lookup("1002")), BidValidationSkip)
+	cmpStrings(t, "bid_validation.vast for 1003", cfg.VASTFor(accounts.Lookup("1003")), BidValidationWarn)
+	cmpStrings(t, "bid_validation.vast for unknown accounts", cfg.VASTFor(accounts.Lookup("1004")), BidValidationWarn)
+
+	cfg = BidValidation{VAST: "reject"}
+	accounts.Known[0].BidValidation.VAST = "strict"
+	if errs := append(cfg.validate(nil), accounts.validate(nil)...); len(errs) != 2 {
+		t.Errorf("cfg.bid_validation and cfg.accounts.known[].bid_validation should reject unknown levels. Got %v", errs)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	accounts := Accounts{Known: []Account{{ID: "1001", Renderers: AccountRenderers{Video: Renderer{Name: "outstream", Version: "1.2"}}}}}
+	if errs := accounts.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].renderers: %v", errs)
+	}
+	renderers := accounts.Lookup("1001").Renderers
+	cmpStrings(t, "renderers video name for 1001", renderers.RendererFor("video").Name, "outstream")
+	cmpStrings(t, "renderers video version for 1001", renderers.RendererFor("video").Version, "1.2")
+	cmpStrings(t, "renderers banner name for 1001", renderers.RendererFor("banner").Name, "")
+
+	accounts.Known[0].Renderers.Native = Renderer{Version: "1.0"}
+	if errs := accounts.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.accounts.known[].renderers should reject versions without names. Got %v", errs)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	cfg := Accounts{
+		RejectUnknown: true,
+		Known:         []Account{{ID: "1001", Bidders: []string{"appnexus"}}, {ID: "1002", Disabled: true}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts: %v", errs)
+	}
+	if account := cfg.Lookup("1002"); account == nil || !account.Disabled {
+		t.Errorf("Account 1002 should be found. Got %v", account)
+	}
+	if account := cfg.Lookup("1003"); account != nil {
+		t.Errorf("Account 1003 isn't known. Got %v", account)
+	}
+	if account := cfg.Lookup("1001"); !account.AllowsBidder("appnexus") || account.AllowsBidder("rubicon") {
+		t.Errorf("Account 1001 should only allow appnexus.")
+	}
+	if account := cfg.Lookup("1002"); !account.AllowsBidder("rubicon") {
+		t.Errorf("Accounts without a bidders list should allow every bidder.")
+	}
+
+	cfg.Debug = DebugDeny
+	cfg.Known = append(cfg.Known, Account{ID: "1003", Debug: DebugAllow})
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.debug: %v", errs)
+	}
+	cmpBools(t, "accounts.debug for 1001", cfg.DebugAllowedFor("1001"), false)
+	cmpBools(t, "accounts.debug for 1003", cfg.DebugAllowedFor("1003"), true)
+	cmpBools(t, "accounts.debug for unknown accounts", cfg.DebugAllowedFor("1004"), false)
+	cfg.Debug = ""
+	cmpBools(t, "accounts.debug by default", cfg.DebugAllowedFor("1004"), true)
+
+	cfg.DebugToken = "host-token"
+	cfg.Known[2].DebugToken = "own-token"
+	cmpStrings(t, "accounts.debug_token for 1001", cfg.DebugTokenFor("1001"), "host-token")
+	cmpStrings(t, "accounts.debug_token for 1003", cfg.DebugTokenFor("1003"), "own-token")
+	cmpStrings(t, "accounts.debug_token for unknown accounts", cfg.DebugTokenFor("1004"), "host-token")
+
+	cfg.Known = append(cfg.Known, Account{ID: "1004", Currency: "EUR", ForceCurrency: true})
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].currency: %v", errs)
+	}
+
+	cfg.Known = append(cfg.Known, Account{ID: "1005", SeatFloors: map[string]float64{"appnexus": 2.5}})
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.accounts.known[].seat_floors: %v", errs)
+	}
+
+	cfg = Accounts{
+		Debug: "sometimes",
+		Known: []Account{{ID: "1001"}, {ID: "1001"}, {}, {ID: "10 01"}, {ID: "1004", Bidders: []string{""}}, {ID: "1005", Debug: "never"},
+			{ID: "1006", Currency: "eur"}, {ID: "1007", Currency: "EURO"}, {ID: "1008", ForceCurrency: true},
+			{ID: "1009", SeatFloors: map[string]float64{"appnexus": -1}}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 10 {
+		t.Errorf("cfg.accounts should reject duplicate, empty and malformed account IDs, empty bidders, unknown debug settings, malformed currencies, forced currencies without one and negative seat floors. Got %v", errs)
+	}
+}
+
+func TestBlocklistValidation(t *testing.T) {
+	postgres := &PostgresConnection{Database: "prebid"}
+	cfg := Blocklist{Enabled: true, File: "blocklist.json", Query: "SELECT type, value FROM blocklist", RefreshSeconds: 60}
+	if errs := cfg.validate(nil, postgres); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.blocklist: %v", errs)
+	}
+	if errs := cfg.validate(nil, &PostgresConnection{}); len(errs) != 1 {
+		t.Errorf("cfg.blocklist.query should need a database. Got %v", errs)
+	}
+	cfg = Blocklist{Enabled: true, RefreshSeconds: -1}
+	if errs := cfg.validate(nil, postgres); len(errs) != 2 {
+		t.Errorf("cfg.blocklist should need a file or a query, and a refresh rate >= 0. Got %v", errs)
+	}
+	cfg = Blocklist{RefreshSeconds: -1}
+	if errs := cfg.validate(nil, postgres); len(errs) != 0 {
+		t.Errorf("cfg.blocklist shouldn't be validated when it's disabled. Got %v", errs)
+	}
+}
+
+func TestInvalidTrafficValidation(t *testing.T) {
+	cfg := InvalidTraffic{
+		Enabled:          true,
+		Action:           InvalidTrafficDrop,
+		UserAgents:       []string{"bot", "HeadlessChrome/[0-9]+"},
+		DatacenterRanges: []string{"192.0.2.0/24", "2001:db8::/32"},
+		MaxRequestsPerIP: 100,
+		WindowSeconds:    60,
+		MaxTrackedIPs:    1000,
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.invalid_traffic: %v", errs)
+	}
+	cfg = InvalidTraffic{
+		Enabled:          true,
+		Action:           "block",
+		UserAgents:       []string{"bot("},
+		DatacenterRanges: []string{"192.0.2.1"},
+		MaxRequestsPerIP: 100,
+	}
+	if errs := cfg.validate(nil); len(errs) != 5 {
+		t.Errorf("cfg.invalid_traffic should reject unknown actions, malformed patterns and ranges, and velocity checks without a window or a cap. Got %v", errs)
+	}
+	cfg = InvalidTraffic{Action: "block"}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.invalid_traffic shouldn't be validated when it's disabled. Got %v", errs)
+	}
+}
+
+func TestValidAccountID(t *testing.T) {
+	testCases := map[string]bool{
+		"1001":      true,
+		"pub-ABC_1": true,
+		"":          false,
+		" 1001":     false,
+		"10\n01":    false,
+		"10\x0001":  false,
+	}
+	for id, expected := range testCases {
+		if actual := ValidAccountID(id); actual != expected {
+			t.Errorf("ValidAccountID(%q): expected %t. Got %t", id, expected, actual)
+		}
+	}
+}
+
+func TestResponseSizeValidation(t *testing.T) {
+	if errs := (&ResponseSize{MaxBytes: 10000}).validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.response_size: %v", errs)
+	}
+	if errs := (&ResponseSize{MaxBytes: -1}).validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.response_size.max_bytes should reject negative sizes. Got %v", errs)
+	}
+}
+
+func TestRTD(t *testing.T) {
+	cfg := RTD{
+		MaxTimeoutMillis: 50,
+		Modules: []RTDModule{
+			{Name: "weather", Endpoint: "http://weather.example.com/segments", TimeoutMillis: 20},
+			{Name: "contextual", Endpoint: "https://contextual.example.com/classify", TimeoutMillis: 100},
+			{Name: "geo", Endpoint: "http://geo.example.com/segments"},
+		},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.rtd: %v", errs)
+	}
+	expected := []time.Duration{20 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, timeout := range expected {
+		if actual := cfg.TimeoutFor(&cfg.Modules[i]); actual != timeout {
+			t.Errorf("rtd.modules[%d] should time out after %v. Got %v", i, timeout, actual)
+		}
+	}
+
+	cfg = RTD{
+		Modules: []RTDModule{
+			{Name: "weather", Endpoint: "weather.example.com"},
+			{Name: "weather", Endpoint: "http://weather.example.com/segments", TimeoutMillis: -1},
+			{Endpoint: "http://geo.example.com/segments"},
+		},
+	}
+	if errs := cfg.validate(nil); len(errs) != 5 {
+		t.Errorf("cfg.rtd should reject a missing max_timeout_ms, bad endpoints and timeouts, and duplicate and empty names. Got %v", errs)
+	}
+}
+
+func TestAdsTxt(t *testing.T) {
+	cfg := AdsTxt{
+		Enabled:       true,
+		Action:        AdsTxtBlock,
+		RefreshHours:  24,
+		TimeoutMillis: 2000,
+		MaxDomains:    1000,
+		Bidders: map[string]AdsTxtBidder{
+			"appnexus": {Domain: "appnexus.com", SellersJSON: "https://www.appnexus.com/sellers.json"},
+			"rubicon":  {Domain: "rubiconproject.com"},
+		},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.ads_txt: %v", errs)
+	}
+
+	cfg = AdsTxt{
+		Enabled: true,
+		Action:  "drop",
+		Bidders: map[string]AdsTxtBidder{
+			"appnexus": {SellersJSON: "www.appnexus.com/sellers.json"},
+		},
+	}
+	if errs := cfg.validate(nil); len(errs) != 6 {
+		t.Errorf("cfg.ads_txt should reject a bad action, refresh_hours, timeout_ms, max_domains, bidder domain and sellers_json. Got %v", errs)
+	}
+
+	cfg.Enabled = false
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.ads_txt shouldn't be validated when it's disabled. Got %v", errs)
+	}
+}
+
+func TestCreativeScanningValidation(t *testing.T) {
+	cfg := CreativeScanning{Endpoint: "https://scan.example.com/creatives", TimeoutMillis: 20}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.creative_scanning: %v", errs)
+	}
+
+	cfg = CreativeScanning{Endpoint: "scan.example.com"}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.creative_scanning should reject a bad endpoint and timeout_ms. Got %v", errs)
+	}
+
+	cfg.Endpoint = ""
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.creative_scanning shouldn't be validated when it's off. Got %v", errs)
+	}
+}
+
+func TestAlertsValidation(t *testing.T) {
+	cfg := Alerts{
+		WebhookURL:           "https://hooks.slack.com/services/T000/B000/XXXX",
+		CheckIntervalSeconds: 60,
+		BidderErrorRate:      BidderErrorRateAlert{ThresholdPercent: 25, MinRequests: 10},
+		StaleCurrencyHours:   6,
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.alerts: %v", errs)
+	}
+	cfg = Alerts{
+		WebhookURL:      "hooks.slack.com",
+		CooldownSeconds: -1,
+		BidderErrorRate: BidderErrorRateAlert{ThresholdPercent: 150},
+	}
+	if errs := cfg.validate(nil); len(errs) != 4 {
+		t.Errorf("cfg.alerts should reject bad webhook URLs, missing check intervals, negative cooldowns and rates over 100%%. Got %v", errs)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	cfg := Events{
+		Enabled:  true,
+		Accounts: []AccountEvents{{ID: "1001", Enabled: false}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.events: %v", errs)
+	}
+	cmpBools(t, "events for 1001", cfg.EnabledFor("1001"), false)
+	cmpBools(t, "events for 1002", cfg.EnabledFor("1002"), true)
+
+	cfg.Accounts = []AccountEvents{{ID: "1001"}, {ID: "1001"}, {}}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.events should reject duplicate and empty account IDs. Got %v", errs)
+	}
+}
+
+func TestNoBids(t *testing.T) {
+	cfg := NoBids{
+		MinBidsToRespond: 1,
+		Accounts:         []AccountNoBids{{ID: "1001", MinBidsToRespond: 3}, {ID: "1002"}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.no_bids: %v", errs)
+	}
+	cmpInts(t, "min bids for 1001", cfg.MinBidsFor("1001"), 3)
+	cmpInts(t, "min bids for 1002", cfg.MinBidsFor("1002"), 0)
+	cmpInts(t, "min bids for 1003", cfg.MinBidsFor("1003"), 1)
+
+	cfg = NoBids{
+		MinBidsToRespond: -1,
+		Accounts:         []AccountNoBids{{ID: "1001", MinBidsToRespond: -1}, {ID: "1001"}, {}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 4 {
+		t.Errorf("cfg.no_bids should reject negative minimums, and duplicate and empty account IDs. Got %v", errs)
+	}
+}
+
+func TestResponseCaptureValidation(t *testing.T) {
+	cfg := ResponseCapture{Enabled: true, Size: 5}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.response_capture: %v", errs)
+	}
+	cfg = ResponseCapture{Enabled: true}
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.response_capture should reject a size of 0 when it's enabled. Got %v", errs)
+	}
+	cfg = ResponseCapture{}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.response_capture.size shouldn't matter when it's disabled. Got %v", errs)
+	}
+}
+
+func TestSnapshotsValidation(t *testing.T) {
+	cfg := Snapshots{Enabled: true, Dir: "/var/lib/prebid/snapshots", SampleRate: 0.01, MaxFiles: 100}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.snapshots: %v", errs)
+	}
+	cfg = Snapshots{Enabled: true, SampleRate: 1.5}
+	if errs := cfg.validate(nil); len(errs) != 3 {
+		t.Errorf("cfg.snapshots should need a dir, a sample rate up to 1 and max files when it's enabled. Got %v", errs)
+	}
+	cfg = Snapshots{SampleRate: -1}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.snapshots shouldn't be validated when it's disabled. Got %v", errs)
+	}
+}
+
+func TestAuctionDedupValidation(t *testing.T) {
+	cfg := AuctionDedup{Enabled: true, WindowMillis: 2000, MaxEntries: 100}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.auction_dedup: %v", errs)
+	}
+	cfg = AuctionDedup{Enabled: true}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.auction_dedup should need a window and max entries when it's enabled. Got %v", errs)
+	}
+	cfg = AuctionDedup{}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.auction_dedup shouldn't be validated when it's disabled. Got %v", errs)
+	}
+}
+
+func TestBidderParamsValidation(t *testing.T) {
+	cfg := BidderParams{
+		Accounts: []AccountBidderParams{{
+			ID:      "managed",
+			Schemas: map[string]string{"appnexus": `{"properties":{"placementId":{"enum":[10433394]}}}`},
+		}},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.bidder_params: %v", errs)
+	}
+	if schemas := cfg.AccountSchemas(); len(schemas["managed"]) != 1 {
+		t.Errorf("The account's schemas should be returned by its ID. Got %v", schemas)
+	}
+
+	cfg.Accounts = append(cfg.Accounts, AccountBidderParams{Schemas: map[string]string{"appnexus": "not json"}}, AccountBidderParams{ID: "managed"})
+	if errs := cfg.validate(nil); len(errs) != 3 {
+		t.Errorf("cfg.bidder_params should reject empty accounts, duplicate accounts and malformed schemas. Got %v", errs)
+	}
+}
+
+func TestCookieAttributesValidation(t *testing.T) {
+	cfg := CookieAttributes{SameSite: SameSiteNone, Secure: true, Partitioned: true}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for valid cfg.host_cookie.attributes: %v", errs)
+	}
+	cfg = CookieAttributes{SameSite: SameSiteNone, Partitioned: true}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.host_cookie.attributes should require secure for SameSite=None and partitioned cookies. Got %v", errs)
+	}
+	cfg = CookieAttributes{SameSite: "sometimes"}
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.host_cookie.attributes should reject unknown same_site values. Got %v", errs)
+	}
+}
+
+func TestSetUIDValidation(t *testing.T) {
+	cfg := SetUID{RedirectDomains: []string{"sync.com"}, MaxRedirectLength: 2048, MaxRedirectChain: 3}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.setuid: %v", errs)
+	}
+	cmpBools(t, "redirect to sync.com", cfg.AllowsRedirectTo("sync.com"), true)
+	cmpBools(t, "redirect to a subdomain", cfg.AllowsRedirectTo("eu.Sync.com"), true)
+	cmpBools(t, "redirect to another domain", cfg.AllowsRedirectTo("evilsync.com"), false)
+
+	cfg = SetUID{RedirectDomains: []string{"https://sync.com", "Sync.com", ""}, MaxRedirectLength: -1, MaxRedirectChain: -1}
+	if errs := cfg.validate(nil); len(errs) != 5 {
+		t.Errorf("cfg.setuid should reject bad domains and negative limits. Got %v", errs)
+	}
+}
+
+func TestAdapterTLSValidation(t *testing.T) {
+	cfg := AdapterTLS{MinVersion: "1.4"}
+	if errs := cfg.validate(nil); len(errs) != 1 {
+		t.Errorf("cfg.adapter_tls.min_version should reject unknown TLS versions. Got %v", errs)
+	}
+	errs := validateAdapters(map[string]Adapter{
+		"appnexus": {ClientCertificate: ClientCertificate{CertFile: "appnexus.crt"}},
+	}, nil)
+	if len(errs) != 1 {
+		t.Errorf("cfg.adapters.appnexus.client_certificate should require a key_file. Got %v", errs)
+	}
+}
+
+func TestAdapterProxyValidation(t *testing.T) {
+	cfg := AdapterProxy{URL: "socks5://proxy.example.com:1080", ConnectTimeoutMillis: -1}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("cfg.adapter_proxy should reject non-HTTP proxies and negative timeouts. Got %v", errs)
+	}
+	cfg = AdapterProxy{URL: "http://proxy.example.com:3128", ConnectTimeoutMillis: 50}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for a valid cfg.adapter_proxy: %v", errs)
+	}
+}
+
+func TestPrivacyDefaults(t *testing.T) {
+	cfg := PrivacyDefaults{
+		GDPR: "1",
+		Channels: map[string]ChannelPrivacyDefaults{
+			ChannelApp: {GDPR: "0", CCPAOptOut: true},
+		},
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("Unexpected errors for valid privacy_defaults: %v", errs)
+	}
+	cmpStrings(t, "privacy_defaults for web", cfg.ForChannel(ChannelWeb).GDPR, "1")
+	cmpStrings(t, "privacy_defaults for app", cfg.ForChannel(ChannelApp).GDPR, "0")
+	cmpBools(t, "privacy_defaults for app", cfg.ForChannel(ChannelApp).CCPAOptOut, true)
+
+	cfg.GDPR = "yes"
+	cfg.Channels["tv"] = ChannelPrivacyDefaults{}
+	if errs := cfg.validate(nil); len(errs) != 2 {
+		t.Errorf("privacy_defaults should reject bad gdpr values and unknown channels. Got %v", errs)
+	}
+}
+
+func TestGeoPrivacyRules(t *testing.T) {
+	cfg := Configuration{
+		StoredRequests: StoredRequests{
+			InMemoryCache: InMemoryCache{
+				Type: "none",
+			},
+		},
+		GeoPrivacy: GeoPrivacy{
+			Rules: defaultGeoPrivacyRules,
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("The default geo_privacy.rules should be valid. %v", err)
+	}
+
+	cfg.GeoPrivacy.Rules = []GeoPrivacyRule{{Countries: []string{"USA"}, Regime: RegimeUSState}}
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.geo_privacy.rules should require gpp_sid for us_state rules, but it doesn't")
+	}
+
+	cfg.GeoPrivacy.Rules = []GeoPrivacyRule{{Countries: []string{"USA"}, Regime: "ccpa"}}
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.geo_privacy.rules should reject unknown regimes, but it doesn't")
+	}
+}
+
+func TestCookieDeprecationAccountOverrides(t *testing.T) {
+	cfg := CookieDeprecation{
+		Accounts: []AccountCookieDeprecation{
+			{ID: "opted-in", Enabled: true},
+		},
+	}
+	cmpBools(t, "cookie_deprecation for overridden account", cfg.EnabledFor("opted-in"), true)
+	cmpBools(t, "cookie_deprecation for other accounts", cfg.EnabledFor("other"), false)
+
+	cfg.TTLSec = -1
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("cfg.cookie_deprecation.ttl_sec should prevent negative values, but it doesn't")
+	}
+}
+
+func TestAdapterAliasValidation(t *testing.T) {
+	adapters := map[string]Adapter{
+		"appnexus":  {Endpoint: "http://ib.adnxs.com/openrtb2"},
+		"districtm": {AliasOf: "appnexus"},
+	}
+	if errs := validateAdapters(adapters, nil); len(errs) != 0 {
+		t.Errorf("adapters should be valid. Got %v", errs)
+	}
+
+	adapters["other"] = Adapter{AliasOf: "districtm"}
+	if errs := validateAdapters(adapters, nil); len(errs) == 0 {
+		t.Error("adapters should reject aliases of aliases, but they don't")
+	}
+
+	delete(adapters, "other")
+	adapters["self"] = Adapter{AliasOf: "self"}
+	if errs := validateAdapters(adapters, nil); len(errs) == 0 {
+		t.Error("adapters should reject aliases of themselves, but they don't")
+	}
+}
+
+func TestRegionalEndpoints(t *testing.T) {
+	regionalConfig := []byte(`
+adapters:
+  appnexus:
+    endpoint: http://ib.adnxs.com/openrtb2
+    regional_endpoints:
+      EU: http://ib-eu.adnxs.com/openrtb2
+  rubicon:
+    endpoint: http://exapi-us-east.rubiconproject.com/a/api/exchange.json
+  districtm:
+    alias_of: appnexus
+`)
+	testCases := []struct {
+		description string
+		region      string
+		expected    string
+	}{
+		{description: "regional endpoint", region: "region:\n  name: eu\n", expected: "http://ib-eu.adnxs.com/openrtb2"},
+		{description: "region from an env var", region: "region:\n  env_var: PBS_TEST_REGION\n", expected: "http://ib-eu.adnxs.com/openrtb2"},
+		{description: "no endpoint for the region", region: "region:\n  name: apac\n", expected: "http://ib.adnxs.com/openrtb2"},
+		{description: "no region", expected: "http://ib.adnxs.com/openrtb2"},
+	}
+	os.Setenv("PBS_TEST_REGION", "EU")
+	defer os.Unsetenv("PBS_TEST_REGION")
+
+	for _, test := range testCases {
+		v := viper.New()
+		SetupViper(v)
+		v.SetConfigType("yaml")
+		v.ReadConfig(bytes.NewBuffer(append([]byte(test.region), regionalConfig...)))
+		cfg, err := New(v)
+		if err != nil {
+			t.Fatalf("%s: %v", test.description, err)
+		}
+		cmpStrings(t, test.description+": appnexus", cfg.Adapters["appnexus"].Endpoint, test.expected)
+		cmpStrings(t, test.description+": districtm", cfg.Adapters["districtm"].Endpoint, test.expected)
+		cmpStrings(t, test.description+": rubicon", cfg.Adapters["rubicon"].Endpoint, "http://exapi-us-east.rubiconproject.com/a/api/exchange.json")
+	}
+}
+
+func TestReload(t *testing.T) {
+	file, err := ioutil.TempFile("", "pbs-reload")
+	if err != nil {
+		t.Fatalf("Failed to create the config file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	writeConfig := func(contents string) {
+		if err := ioutil.WriteFile(file.Name(), []byte(contents), 0644); err != nil {
+			t.Fatalf("Failed to write the config file: %v", err)
+		}
+	}
+
+	writeConfig(`
+port: 1234
+adapters:
+  appnexus:
+    endpoint: http://ib.adnxs.com/openrtb2
+tie_breaking:
+  strategy: random
+`)
+	v := viper.New()
+	SetupViper(v)
+	v.SetConfigFile(file.Name())
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("Failed to read the config file: %v", err)
+	}
+	cfg, err := New(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	writeConfig(`
+port: 5678
+adapters:
+  appnexus:
+    endpoint: http://ib-eu.adnxs.com/openrtb2
+  beachfront:
+    disabled: true
+tie_breaking:
+  strategy: deal_priority
+response_size:
+  max_bytes: 10000
+accounts:
+  known:
+    - id: publisher
+      renderers:
+        video:
+          name: outstream
+      debug_token: publisher-token
+      redact_debug: false
+      seat_floors:
+        appnexus: 1.5
+      blocking:
+        blocked:
+          badv: [competitor.com]
+        bidders:
+          appnexus:
+            battr: [1, 2]
+`)
+	reloaded, err := Reload(v, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cmpStrings(t, "adapters.appnexus.endpoint", reloaded.Adapters["appnexus"].Endpoint, "http://ib-eu.adnxs.com/openrtb2")
+	cmpBools(t, "adapters.beachfront.disabled", reloaded.Adapters["beachfront"].Disabled, true)
+	cmpStrings(t, "tie_breaking.strategy", reloaded.TieBreaking.Strategy, "deal_priority")
+	cmpInts(t, "response_size.max_bytes", reloaded.ResponseSize.MaxBytes, 10000)
+	account := reloaded.Accounts.Lookup("publisher")
+	if account == nil || account.SeatFloors["appnexus"] != 1.5 {
+		t.Fatalf("accounts.known should be reloaded. Got %v", account)
+	}
+	cmpStrings(t, "accounts.known[0].renderers.video.name", account.Renderers.RendererFor("video").Name, "outstream")
+	cmpStrings(t, "accounts.known[0].debug_token", account.DebugToken, "publisher-token")
+	if account.RedactDebug == nil || *account.RedactDebug {
+		t.Errorf("accounts.known[0].redact_debug should be false. Got %v", account.RedactDebug)
+	}
+	cmpStrings(t, "accounts.known[0].blocking.blocked.badv", account.Blocking.BlockedFor("rubicon").BAdv[0], "competitor.com")
+	cmpInts(t, "accounts.known[0].blocking.bidders.appnexus.battr", len(account.Blocking.BlockedFor("appnexus").BAttr), 2)
+	cmpInts(t, "port", reloaded.Port, 1234)
+	cmpStrings(t, "original adapters.appnexus.endpoint", cfg.Adapters["appnexus"].Endpoint, "http://ib.adnxs.com/openrtb2")
+
+	writeConfig(`
+tie_breaking:
+  strategy: coin_toss
+`)
+	if _, err := Reload(v, reloaded); err == nil {
+		t.Error("Configs which don't pass validation shouldn't be reloaded.")
+	}
+}
+
+func TestRegionValidation(t *testing.T) {
+	if errs := (&Region{Name: "eu", EnvVar: "AWS_REGION"}).validate(nil); len(errs) == 0 {
+		t.Error("region should reject both a name and an env_var, but it doesn't")
+	}
+	adapters := map[string]Adapter{
+		"appnexus": {RegionalEndpoints: map[string]string{"eu": ""}},
+	}
+	if errs := validateAdapters(adapters, nil); len(errs) == 0 {
+		t.Error("adapters should reject empty regional endpoints, but they don't")
+	}
+}
+
+func TestAdapterForwardHeadersValidation(t *testing.T) {
+	adapters := map[string]Adapter{
+		"appnexus": {ForwardHeaders: []string{"User-Agent", "accept-language"}},
+	}
+	if errs := validateAdapters(adapters, nil); len(errs) != 0 {
+		t.Errorf("adapters.appnexus.forward_headers should be valid. Got %v", errs)
+	}
+
+	adapters["appnexus"] = Adapter{ForwardHeaders: []string{"Cookie"}}
+	if errs := validateAdapters(adapters, nil); len(errs) == 0 {
+		t.Error("adapters.appnexus.forward_headers should reject unsupported headers, but it doesn't")
+	}
+}
+
+func TestAdapterMediaTypeInferenceValidation(t *testing.T) {
+	adapters := map[string]Adapter{
+		"appnexus": {MediaTypeInference: []string{"mtype", "adm", "imp"}},
+	}
+	if errs := validateAdapters(adapters, nil); len(errs) != 0 {
+		t.Errorf("adapters.appnexus.media_type_inference should be valid. Got %v", errs)
+	}
+
+	adapters["appnexus"] = Adapter{MediaTypeInference: []string{"mtype", "guess"}}
+	if errs := validateAdapters(adapters, nil); len(errs) != 1 {
+		t.Errorf("adapters.appnexus.media_type_inference should reject unknown rules. Got %v", errs)
+	}
+}
+
+func TestAdapterMaxResponseBytesValidation(t *testing.T) {
+	adapters := map[string]Adapter{
+		"appnexus": {MaxResponseBytes: 65536},
+	}
+	if errs := validateAdapters(adapters, nil); len(errs) != 0 {
+		t.Errorf("adapters.appnexus.max_response_bytes should be valid. Got %v", errs)
+	}
+
+	adapters["appnexus"] = Adapter{MaxResponseBytes: -1}
+	if errs := validateAdapters(adapters, nil); len(errs) != 1 {
+		t.Errorf("adapters.appnexus.max_response_bytes should reject negative limits. Got %v", errs)
+	}
+}
+
+func TestAdapterExtraInfoValidation(t *testing.T) {
+	adapters := map[string]Adapter{
+		"facebook": {ExtraInfo: `{"platform_id":"1234"}`},
+	}
+	if errs := validateAdapters(adapters, nil); len(errs) != 0 {
+		t.Errorf("adapters.facebook.extra_info should be valid. Got %v", errs)
+	}
+
+	adapters["facebook"] = Adapter{ExtraInfo: `{"platform_id":`}
+	if errs := validateAdapters(adapters, nil); len(errs) == 0 {
+		t.Error("adapters.facebook.extra_info should reject invalid JSON, but it doesn't")
+	}
+}
+
+func TestAccountMetricsAllowlist(t *testing.T) {
+	cfg := AccountMetrics{Allowlist: []string{"1001", "other"}, MaxAccounts: -1}
+	cmpBools(t, "metrics.accounts limited", cfg.Limited(), true)
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("metrics.accounts.allowlist should reject the reserved account \"other\", but it doesn't")
+	}
+}
+
+func TestLoggingValidation(t *testing.T) {
+	cfg := Logging{Level: "warning", Format: "json", Output: "syslog"}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("logging config should be valid. Got %v", errs)
+	}
+
+	cfg = Logging{Level: "verbose", Format: "xml", Output: "kafka"}
+	if errs := cfg.validate(nil); len(errs) != 3 {
+		t.Errorf("logging.level, format and output should reject unknown values. Got %v", errs)
+	}
+
+	cfg = Logging{Output: "file"}
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("logging.file.path should be required when logging to a file, but it isn't")
+	}
+}
+
+func TestTracingValidation(t *testing.T) {
+	cfg := Tracing{Enabled: true, Endpoint: "otel-collector:4318", SampleRate: 1}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("tracing config should be valid. Got %v", errs)
+	}
+
+	cfg.SampleRate = 1.5
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("tracing.sample_rate should reject values above 1, but it doesn't")
+	}
+
+	cfg = Tracing{Enabled: true, SampleRate: 0.5}
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("tracing.otlp_endpoint should be required when tracing is enabled, but it isn't")
+	}
+}
+
+func TestTargetingStaticKeys(t *testing.T) {
+	cfg := Targeting{
+		Accounts: []AccountTargeting{
+			{ID: "with-keys", StaticKeys: []StaticKey{{Key: "siteSection", Value: "sports"}}},
+		},
+	}
+	cmpStrings(t, "targeting static key for account", cfg.StaticKeysFor("with-keys")[0].Value, "sports")
+	if keys := cfg.StaticKeysFor("other"); keys != nil {
+		t.Errorf("accounts without static keys shouldn't get any. Got %v", keys)
+	}
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.targeting should be valid. Got %v", errs)
+	}
+
+	cfg.Accounts[0].StaticKeys = append(cfg.Accounts[0].StaticKeys, StaticKey{Key: "siteSection", Value: "news"})
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("cfg.targeting should reject duplicate static keys, but it doesn't")
+	}
+
+	cfg.Accounts[0].StaticKeys = []StaticKey{{Key: "hb_pb", Value: "1.00"}}
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("cfg.targeting should reject static keys which start with hb_, but it doesn't")
+	}
+}
+
+func TestTopics(t *testing.T) {
+	cfg := Topics{
+		AllowedDomains: []string{"example.com"},
+		Accounts:       []AccountTopics{{ID: "opted-in", Enabled: true}},
+	}
+	cmpBools(t, "topics for overridden account", cfg.EnabledFor("opted-in"), true)
+	cmpBools(t, "topics for other accounts", cfg.EnabledFor("other"), false)
+	cmpBools(t, "topics for allowed domain", cfg.DomainAllowed("example.com"), true)
+	cmpBools(t, "topics for subdomain", cfg.DomainAllowed("news.example.com"), true)
+	cmpBools(t, "topics for other domain", cfg.DomainAllowed("badexample.com"), false)
+
+	if errs := cfg.validate(nil); len(errs) == 0 {
+		t.Error("cfg.topics should require a provider when it's enabled, but it doesn't")
+	}
+	cfg.Provider = "topics.example.com"
+	if errs := cfg.validate(nil); len(errs) != 0 {
+		t.Errorf("cfg.topics should be valid. Got %v", errs)
+	}
+}
+
+func TestKafkaAvroNeedsSchemaRegistry(t *testing.T) {
+	cfg := Configuration{
+		Analytics: Analytics{
+			Kafka: KafkaLogs{
+				Brokers:       []string{"localhost:9092"},
+				Serialization: "avro",
+				BufferSize:    100,
+			},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.kafka.schema_registry_url should be required for avro, but it isn't")
+	}
+}
+
+func TestKafkaBadSerialization(t *testing.T) {
+	cfg := Configuration{
+		Analytics: Analytics{
+			Kafka: KafkaLogs{
+				Brokers:       []string{"localhost:9092"},
+				Serialization: "xml",
+				BufferSize:    100,
+			},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.kafka.serialization should only allow json and avro, but it doesn't")
+	}
+}
+
+func TestFirehoseNeedsAccounts(t *testing.T) {
+	cfg := Configuration{
+		StoredRequests: StoredRequests{
+			InMemoryCache: InMemoryCache{
+				Type: "none",
+			},
+		},
+		Analytics: Analytics{
+			Firehose: FirehoseLogs{
+				StreamName:      "auctions",
+				Region:          "us-east-1",
+				BatchSize:       500,
+				BatchBytes:      1024,
+				FlushIntervalMs: 1000,
+				BufferSize:      100,
+			},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.firehose.accounts should be required, but it isn't")
+	}
+	cfg.Analytics.Firehose.Accounts = []string{"1001"}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("cfg.analytics.firehose should be valid with an account. %v", err)
+	}
+}
+
+func TestFirehoseBatchLimits(t *testing.T) {
+	cfg := Configuration{
+		Analytics: Analytics{
+			Firehose: FirehoseLogs{
+				StreamName:      "auctions",
+				Region:          "us-east-1",
+				Accounts:        []string{"1001"},
+				BatchSize:       501,
+				BatchBytes:      1024,
+				FlushIntervalMs: 1000,
+				BufferSize:      100,
+			},
+		},
+	}
+
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.firehose.batch_size should be capped at 500, but it isn't")
+	}
+}
+
 func TestLimitTimeout(t *testing.T) {
 	doTimeoutTest(t, 10, 15, 10, 0)
 	doTimeoutTest(t, 10, 0, 10, 0)
@@ -218,3 +1665,41 @@ func doTimeoutTest(t *testing.T, expected int, requested int, max uint64, def ui
 		t.Errorf("Expected %dms timeout, got %dms", expectedDuration, limited/time.Millisecond)
 	}
 }
+
+func TestSamplingRates(t *testing.T) {
+	cfg := Configuration{
+		StoredRequests: StoredRequests{
+			InMemoryCache: InMemoryCache{
+				Type: "none",
+			},
+		},
+		Analytics: Analytics{
+			Sampling: AnalyticsSampling{
+				Rates: SamplingRates{"default": 1, "web": 0.05},
+				Accounts: []AccountSampling{
+					{ID: "1001", Rates: SamplingRates{"video": 0.5}},
+				},
+			},
+		},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("cfg.analytics.sampling should be valid. %v", err)
+	}
+
+	cfg.Analytics.Sampling.Rates["web"] = 1.5
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.sampling.rates should not allow rates above 1, but it does")
+	}
+	cfg.Analytics.Sampling.Rates["web"] = 0.05
+
+	cfg.Analytics.Sampling.Accounts[0].Rates["banner"] = 0.5
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.sampling.accounts should only allow known channels, but it doesn't")
+	}
+	delete(cfg.Analytics.Sampling.Accounts[0].Rates, "banner")
+
+	cfg.Analytics.Sampling.Accounts = append(cfg.Analytics.Sampling.Accounts, AccountSampling{ID: "1001"})
+	if err := cfg.validate(); err == nil {
+		t.Error("cfg.analytics.sampling.accounts should not allow duplicate accounts, but it does")
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 )
 
 // StoredRequests configures the backend used to store requests on the server.
@@ -33,6 +33,9 @@ type StoredRequests struct {
 	// HTTPEvents configures an instance of stored_requests/events/http/http.go.
 	// If non-nil, the server will use those endpoints to populate and update the cache.
 	HTTPEvents HTTPEventsConfig `mapstructure:"http_events"`
+	// CategoryRefreshRate is how often, in seconds, the category mappings are fetched again from the backends.
+	// Zero means they're fetched on every auction which asks for brand categories.
+	CategoryRefreshRate int `mapstructure:"category_refresh_rate_seconds"`
 }
 
 // HTTPEventsConfig configures stored_requests/events/http/http.go
@@ -55,6 +58,8 @@ func (cfg HTTPEventsConfig) RefreshRateDuration() time.Duration {
 type HTTPFetcherConfig struct {
 	Endpoint    string `mapstructure:"endpoint"`
 	AmpEndpoint string `mapstructure:"amp_endpoint"`
+	// CategoryEndpoint serves the category mappings. See stored_requests/backends/http_fetcher/categories.go
+	CategoryEndpoint string `mapstructure:"category_endpoint"`
 }
 
 func (cfg *StoredRequests) validate(errs configErrors) configErrors {
@@ -74,6 +79,9 @@ func (cfg *StoredRequests) validate(errs configErrors) configErrors {
 			errs = append(errs, errors.New("stored_requests.postgres.initialize_caches.query must be empty if stored_requests.in_memory_cache=none"))
 		}
 	}
+	if cfg.CategoryRefreshRate < 0 {
+		errs = append(errs, errors.New("stored_requests.category_refresh_rate_seconds must be >= 0"))
+	}
 	errs = cfg.InMemoryCache.validate(errs)
 	errs = cfg.Postgres.validate(errs)
 	return errs
@@ -93,6 +101,9 @@ func (cfg *PostgresConfig) validate(errs configErrors) configErrors {
 		return errs
 	}
 
+	if query := cfg.FetcherQueries.CategoryQuery; query != "" && (!strings.Contains(query, "$1") || !strings.Contains(query, "$2") || strings.Contains(query, "$3")) {
+		errs = append(errs, errors.New("stored_requests.postgres.fetcher.category_query must contain exactly two wildcards: $1 for the ad server and $2 for the publisher"))
+	}
 	return cfg.PollUpdates.validate(errs)
 }
 
@@ -170,6 +181,15 @@ type PostgresFetcherQueries struct {
 
 	// AmpQueryTemplate is the same as QueryTemplate, but used in the `/openrtb2/amp` endpoint.
 	AmpQueryTemplate string `mapstructure:"amp_query"`
+
+	// CategoryQuery fetches a category mapping. It's given the ad server as $1 and the publisher ID as $2,
+	// and should return (iab_category, category) rows. For example:
+	//   SELECT iab_category, category
+	//     FROM categories
+	//     WHERE ad_server = $1 AND publisher = $2
+	//
+	// The publisher ID is an empty string when the ad server's default mapping is wanted.
+	CategoryQuery string `mapstructure:"category_query"`
 }
 
 type PostgresCacheInitializer struct {
@@ -273,7 +293,7 @@ func resolve(template string, numReqs int, numImps int) (query string) {
 
 func ensureNonNegative(storedThing string, num int) int {
 	if num < 0 {
-		glog.Errorf("Can't build a SQL query for %d Stored %ss.", num, storedThing)
+		logger.Errorf("Can't build a SQL query for %d Stored %ss.", num, storedThing)
 		return 0
 	}
 	return num
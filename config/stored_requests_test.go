@@ -114,6 +114,22 @@ func TestInMemoryCacheValidation(t *testing.T) {
 	}).validate(nil))
 }
 
+func TestCategoryValidation(t *testing.T) {
+	withQuery := func(query string) *PostgresConfig {
+		return &PostgresConfig{
+			ConnectionInfo: PostgresConnection{Database: "db"},
+			FetcherQueries: PostgresFetcherQueries{CategoryQuery: query},
+		}
+	}
+	assertNoErrs(t, withQuery("").validate(nil))
+	assertNoErrs(t, withQuery("SELECT iab, category FROM categories WHERE ad_server = $1 AND publisher = $2").validate(nil))
+	assertErrsExist(t, withQuery("SELECT iab, category FROM categories WHERE ad_server = $1").validate(nil))
+	assertErrsExist(t, withQuery("SELECT iab, category FROM categories WHERE ad_server = $1 AND publisher = $2 AND site = $3").validate(nil))
+
+	assertNoErrs(t, (&StoredRequests{InMemoryCache: InMemoryCache{Type: "none"}, CategoryRefreshRate: 0}).validate(nil))
+	assertErrsExist(t, (&StoredRequests{InMemoryCache: InMemoryCache{Type: "none"}, CategoryRefreshRate: -1}).validate(nil))
+}
+
 func assertErrsExist(t *testing.T, err configErrors) {
 	t.Helper()
 	if len(err) == 0 {
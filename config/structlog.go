@@ -6,69 +6,90 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 )
 
 var mapregex *regexp.Regexp = regexp.MustCompile(`mapstructure:"([^"]+)"`)
+
+// blacklistregexp matches the full names of the settings which are secret, such as "adapters[appnexus].headers".
+// Their values are never logged.
 var blacklistregexp []*regexp.Regexp = []*regexp.Regexp{
 	regexp.MustCompile("password"),
+	// profiling.token, admin.token and the debug tokens.
+	regexp.MustCompile("token$"),
+	// The bidders' headers often carry their API keys.
+	regexp.MustCompile(`(^|\.)headers$`),
+	// Proxy URLs may have credentials in them.
+	regexp.MustCompile("proxy_url$"),
+	regexp.MustCompile(`(^|\.)adapter_proxy\.url$`),
 }
 
 // LogGeneral will log nearly any sort of value, but requires the name of the root object to be in the
 // prefix if you want that name to be logged. Structs will append .<fieldname> recursively to the prefix
 // to document deeper structure.
 func logGeneral(v reflect.Value, prefix string) {
-	logGeneralWithLogger(v, prefix, glog.Infof)
+	logGeneralWithLogger(v, prefix, logger.Infof)
 }
 
-func logGeneralWithLogger(v reflect.Value, prefix string, logger func(msg string, args ...interface{})) {
+func logGeneralWithLogger(v reflect.Value, prefix string, logf func(msg string, args ...interface{})) {
 	switch v.Kind() {
 	case reflect.Struct:
-		logStructWithLogger(v, prefix, logger)
+		logStructWithLogger(v, prefix, logf)
 	case reflect.Map:
-		logMapWithLogger(v, prefix, logger)
+		logMapWithLogger(v, prefix, logf)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			logGeneralWithLogger(v.Index(i), fmt.Sprintf("%s[%d]", prefix, i), logf)
+		}
+	case reflect.Ptr:
+		// Optional settings, such as accounts.known[].redact_debug, are nil when they aren't set.
+		if v.IsNil() {
+			logf("%s: <unset>", prefix)
+		} else {
+			logGeneralWithLogger(v.Elem(), prefix, logf)
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		logger("%s: %d", prefix, v.Int())
+		logf("%s: %d", prefix, v.Int())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		logger("%s: %d", prefix, v.Uint())
+		logf("%s: %d", prefix, v.Uint())
 	case reflect.Float32, reflect.Float64:
-		logger("%s: %f", prefix, v.Float())
+		logf("%s: %f", prefix, v.Float())
 	case reflect.Bool:
-		logger("%s: %t", prefix, v.Bool())
+		logf("%s: %t", prefix, v.Bool())
 	default:
 		// logString, by using v.String(), will not fail, and indicate what additional cases we need to handle
-		logger("%s: %s", prefix, v.String())
+		logf("%s: %s", prefix, v.String())
 	}
 }
 
-func logStructWithLogger(v reflect.Value, prefix string, logger func(msg string, args ...interface{})) {
+func logStructWithLogger(v reflect.Value, prefix string, logf func(msg string, args ...interface{})) {
 	if v.Kind() != reflect.Struct {
-		glog.Fatalf("LogStruct called on type %s, whuch is not a struct!", v.Type().String())
+		logger.Fatalf("LogStruct called on type %s, whuch is not a struct!", v.Type().String())
 	}
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		fieldname := fieldNameByTag(t.Field(i))
-		if allowedName(fieldname) {
-			logGeneralWithLogger(v.Field(i), extendPrefix(prefix, fieldname), logger)
+		if allowedName(extendPrefix(prefix, fieldname)) {
+			logGeneralWithLogger(v.Field(i), extendPrefix(prefix, fieldname), logf)
 		} else {
-			logger("%s.%s: <REDACTED>", prefix, fieldname)
+			logf("%s: <REDACTED>", extendPrefix(prefix, fieldname))
 		}
 	}
 }
 
-func logMapWithLogger(v reflect.Value, prefix string, logger func(msg string, args ...interface{})) {
+func logMapWithLogger(v reflect.Value, prefix string, logf func(msg string, args ...interface{})) {
 	if v.Kind() != reflect.Map {
-		glog.Fatalf("LogMap called on type %s, whuch is not a map!", v.Type().String())
+		logger.Fatalf("LogMap called on type %s, whuch is not a map!", v.Type().String())
 	}
 	for _, k := range v.MapKeys() {
-		if k.Kind() == reflect.String && !allowedName(k.String()) {
-			logger("%s: <REDACTED>", extendMapPrefix(prefix, k.String()))
+		if k.Kind() == reflect.String && !allowedName(extendMapPrefix(prefix, k.String())) {
+			logf("%s: <REDACTED>", extendMapPrefix(prefix, k.String()))
 		} else {
 			// Use Sprintf("%v", k.Interface) to handle non-string keys. Should not be possible to have a key
 			// too complex to represent by %v.
 			// NOTE: This will break if we have an unexported map in the object. If so we will have to switch
 			// on k.Kind() rather than rely on fmt.Sprintf("%v") doing that work.
-			logGeneralWithLogger(v.MapIndex(k), extendMapPrefix(prefix, fmt.Sprintf("%v", k.Interface())), logger)
+			logGeneralWithLogger(v.MapIndex(k), extendMapPrefix(prefix, fmt.Sprintf("%v", k.Interface())), logf)
 		}
 	}
 }
@@ -81,7 +102,9 @@ func fieldNameByTag(f reflect.StructField) string {
 	return match[1]
 }
 
+// allowedName returns false if the setting with this full name is secret.
 func allowedName(name string) bool {
+	name = strings.TrimSpace(name)
 	for _, r := range blacklistregexp {
 		if r.MatchString(name) {
 			return false
@@ -0,0 +1,105 @@
+// Package creativescan has a creative security service, such as Confiant, check the bidders' creatives before they
+// can win. Malicious creatives redirect users, mine cryptocurrency or carry malware, and publishers lose users
+// to them. The service sees each creative while the auction is still running, so it can keep them off the page
+// rather than reporting them afterwards.
+package creativescan
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/tracing"
+)
+
+// Creative is a bid's creative, with the context which the service needs to recognize it.
+type Creative struct {
+	BidID  string `json:"bidid"`
+	ImpID  string `json:"impid"`
+	Bidder string `json:"bidder"`
+	CrID   string `json:"crid,omitempty"`
+	// AdM is the bid's markup. Creatives which are only served from the NURL leave it empty.
+	AdM     string   `json:"adm,omitempty"`
+	NURL    string   `json:"nurl,omitempty"`
+	ADomain []string `json:"adomain,omitempty"`
+}
+
+// Verdict is the service's decision about a creative.
+type Verdict string
+
+const (
+	// VerdictClean creatives are left alone. Creatives which aren't in the service's results are clean too.
+	VerdictClean Verdict = "clean"
+	// VerdictMark creatives can still win, but carry the service's reason, so that the publisher's ad server can decide.
+	VerdictMark Verdict = "mark"
+	// VerdictReject creatives are removed from the auction.
+	VerdictReject Verdict = "reject"
+)
+
+// Result is the service's verdict about one creative.
+type Result struct {
+	BidID   string  `json:"bidid"`
+	Verdict Verdict `json:"verdict"`
+	// Reason says what the service found, such as "malvertising" or "auto-redirect".
+	Reason string `json:"reason,omitempty"`
+}
+
+// Module scans the creatives from one bidder's response.
+//
+// Implementations must be safe for concurrent access by multiple goroutines.
+type Module interface {
+	// Scan returns the verdicts about the creatives. It must return by ctx's deadline, which is the scan's budget.
+	Scan(ctx context.Context, creatives []Creative) ([]Result, error)
+}
+
+// Scanner runs the module on each bidder's creatives, within the budget.
+//
+// All functions on this struct are nil-safe. A nil Scanner finds every creative clean.
+type Scanner struct {
+	module     Module
+	timeout    time.Duration
+	failClosed bool
+}
+
+// NewScanner returns a Scanner which sends the creatives to the endpoint in cfg, or nil if scanning is off.
+func NewScanner(cfg *config.CreativeScanning, client *http.Client) *Scanner {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	return newScanner(newHTTPModule(cfg.Endpoint, client), time.Duration(cfg.TimeoutMillis)*time.Millisecond, cfg.FailClosed)
+}
+
+func newScanner(module Module, timeout time.Duration, failClosed bool) *Scanner {
+	return &Scanner{
+		module:     module,
+		timeout:    timeout,
+		failClosed: failClosed,
+	}
+}
+
+// FailClosed returns true if the creatives should be rejected when their scan fails.
+func (s *Scanner) FailClosed() bool {
+	return s != nil && s.failClosed
+}
+
+// Scan returns the verdicts about the creatives, by bid ID. If the module fails or runs out of time,
+// the error is returned instead. It's up to the caller whether the creatives are kept. See FailClosed.
+func (s *Scanner) Scan(ctx context.Context, creatives []Creative) (map[string]Result, error) {
+	if s == nil || len(creatives) == 0 {
+		return nil, nil
+	}
+	ctx, span := tracing.StartSpan(ctx, "creativescan.scan", tracing.Int("creatives", len(creatives)))
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	results, err := s.module.Scan(ctx, creatives)
+	tracing.EndSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	byBid := make(map[string]Result, len(results))
+	for _, result := range results {
+		byBid[result.BidID] = result
+	}
+	return byBid, nil
+}
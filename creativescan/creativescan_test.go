@@ -0,0 +1,78 @@
+package creativescan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestScan(t *testing.T) {
+	scanner := newScanner(&fakeModule{results: []Result{
+		{BidID: "bad", Verdict: VerdictReject, Reason: "malvertising"},
+		{BidID: "odd", Verdict: VerdictMark, Reason: "auto-redirect"},
+	}}, time.Second, false)
+
+	results, err := scanner.Scan(context.Background(), []Creative{{BidID: "bad"}, {BidID: "odd"}, {BidID: "good"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if results["bad"].Verdict != VerdictReject || results["odd"].Reason != "auto-redirect" {
+		t.Errorf("The verdicts should be returned by bid ID. Got %v", results)
+	}
+	if _, ok := results["good"]; ok {
+		t.Errorf("Creatives without a verdict shouldn't be in the results.")
+	}
+}
+
+func TestScanBudget(t *testing.T) {
+	scanner := newScanner(&fakeModule{delay: time.Second}, 10*time.Millisecond, true)
+	start := time.Now()
+	if _, err := scanner.Scan(context.Background(), []Creative{{BidID: "slow"}}); err == nil {
+		t.Errorf("Scans which run out of time should return an error.")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Scans shouldn't run past their budget. The scan took %v", elapsed)
+	}
+	if !scanner.FailClosed() {
+		t.Errorf("The scanner should fail closed, as it was configured.")
+	}
+
+	scanner = newScanner(&fakeModule{err: errors.New("service unavailable")}, time.Second, false)
+	if _, err := scanner.Scan(context.Background(), []Creative{{BidID: "bid"}}); err == nil {
+		t.Errorf("The module's errors should be returned.")
+	}
+}
+
+func TestNilScanner(t *testing.T) {
+	scanner := NewScanner(&config.CreativeScanning{TimeoutMillis: 20}, http.DefaultClient)
+	if scanner != nil {
+		t.Fatalf("A Scanner without an endpoint should be nil.")
+	}
+	if results, err := scanner.Scan(context.Background(), []Creative{{BidID: "bid"}}); results != nil || err != nil {
+		t.Errorf("A nil Scanner should find every creative clean. Got %v, %v", results, err)
+	}
+	if scanner.FailClosed() {
+		t.Errorf("A nil Scanner shouldn't fail closed.")
+	}
+}
+
+type fakeModule struct {
+	delay   time.Duration
+	results []Result
+	err     error
+}
+
+func (m *fakeModule) Scan(ctx context.Context, creatives []Creative) ([]Result, error) {
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return m.results, m.err
+}
@@ -0,0 +1,58 @@
+package creativescan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// httpModule sends the creatives to a scanning service. They're POSTed to the endpoint as {"creatives": [...]},
+// and the service responds with {"results": [...]}, or with a 204 if they're all clean.
+type httpModule struct {
+	endpoint string
+	client   *http.Client
+}
+
+type scanRequest struct {
+	Creatives []Creative `json:"creatives"`
+}
+
+type scanResponse struct {
+	Results []Result `json:"results"`
+}
+
+func newHTTPModule(endpoint string, client *http.Client) *httpModule {
+	return &httpModule{
+		endpoint: endpoint,
+		client:   client,
+	}
+}
+
+func (m *httpModule) Scan(ctx context.Context, creatives []Creative) ([]Result, error) {
+	body, err := json.Marshal(scanRequest{Creatives: creatives})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ctxhttp.Post(ctx, m.client, m.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var scanned scanResponse
+		if err := json.NewDecoder(resp.Body).Decode(&scanned); err != nil {
+			return nil, fmt.Errorf("the response wasn't valid results: %v", err)
+		}
+		return scanned.Results, nil
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("the service responded with status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,63 @@
+package creativescan
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestHTTPModule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var scan scanRequest
+		if err := json.NewDecoder(r.Body).Decode(&scan); err != nil || r.Method != "POST" || len(scan.Creatives) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		creative := scan.Creatives[0]
+		switch creative.CrID {
+		case "known":
+			if creative.Bidder != "appnexus" || creative.AdM != "<script></script>" || len(creative.ADomain) != 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"results":[{"bidid":"bid","verdict":"reject","reason":"malvertising"}]}`))
+		case "clean":
+			w.WriteHeader(http.StatusNoContent)
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	scanner := NewScanner(&config.CreativeScanning{Endpoint: server.URL, TimeoutMillis: 50}, server.Client())
+	if scanner == nil || scanner.timeout != 50*time.Millisecond {
+		t.Fatalf("The Scanner should have the configured budget. Got %v", scanner)
+	}
+	results, err := scanner.Scan(context.Background(), []Creative{{
+		BidID:   "bid",
+		Bidder:  "appnexus",
+		CrID:    "known",
+		AdM:     "<script></script>",
+		ADomain: []string{"advertiser.com"},
+	}})
+	if err != nil || results["bid"].Verdict != VerdictReject || results["bid"].Reason != "malvertising" {
+		t.Errorf("The service's verdict should be returned. Got %v, %v", results, err)
+	}
+
+	if results, err := scanner.Scan(context.Background(), []Creative{{BidID: "bid", CrID: "clean"}}); err != nil || len(results) != 0 {
+		t.Errorf("A 204 should mean every creative is clean. Got %v, %v", results, err)
+	}
+	for _, crID := range []string{"slow", "broken"} {
+		if _, err := scanner.Scan(context.Background(), []Creative{{BidID: "bid", CrID: crID}}); err == nil {
+			t.Errorf("%s: the scan should fail.", crID)
+		}
+	}
+}
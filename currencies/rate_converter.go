@@ -0,0 +1,116 @@
+package currencies
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prebid/prebid-server/logger"
+)
+
+// RateConverter keeps the server's conversion rates up to date, by fetching the currency file periodically.
+type RateConverter struct {
+	httpClient       *http.Client
+	syncSourceURL    string
+	fetchingInterval time.Duration
+	rates            atomic.Value // Holds a *Rates, once the first fetch succeeds
+	lastUpdated      atomic.Value // Holds a time.Time
+}
+
+// NewRateConverter fetches the rates from syncSourceURL, and then again every fetchingInterval.
+// If the URL is empty or the interval isn't positive, no rates are ever fetched.
+func NewRateConverter(httpClient *http.Client, syncSourceURL string, fetchingInterval time.Duration) *RateConverter {
+	rc := &RateConverter{
+		httpClient:       httpClient,
+		syncSourceURL:    syncSourceURL,
+		fetchingInterval: fetchingInterval,
+	}
+	if !rc.Active() {
+		return rc
+	}
+	logger.Infof("Loading currency conversion rates from GET %s", syncSourceURL)
+	rc.Update()
+	go rc.refresh(time.Tick(fetchingInterval))
+	return rc
+}
+
+// Update fetches the latest rates. If it fails, the rates from the last successful fetch are kept.
+func (rc *RateConverter) Update() error {
+	rates, err := rc.fetch()
+	if err != nil {
+		logger.Errorf("Failed to update currency conversion rates from %s: %v", rc.syncSourceURL, err)
+		return err
+	}
+	rc.rates.Store(rates)
+	rc.lastUpdated.Store(time.Now())
+	return nil
+}
+
+func (rc *RateConverter) fetch() (*Rates, error) {
+	resp, err := rc.httpClient.Get(rc.syncSourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+	rates := &Rates{}
+	if err := json.Unmarshal(body, rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (rc *RateConverter) refresh(ticker <-chan time.Time) {
+	for range ticker {
+		rc.Update()
+	}
+}
+
+// Rates returns the latest rates, or Conversions which can't convert anything if none have been fetched.
+func (rc *RateConverter) Rates() Conversions {
+	if rates, ok := rc.rates.Load().(*Rates); ok {
+		return rates
+	}
+	return NewConstantRates()
+}
+
+// Active returns true if the rates are being fetched.
+func (rc *RateConverter) Active() bool {
+	return rc.syncSourceURL != "" && rc.fetchingInterval > 0
+}
+
+// SyncSourceURL returns the URL which the rates are fetched from.
+func (rc *RateConverter) SyncSourceURL() string {
+	return rc.syncSourceURL
+}
+
+// FetchingInterval returns how often the rates are fetched.
+func (rc *RateConverter) FetchingInterval() time.Duration {
+	return rc.fetchingInterval
+}
+
+// Stale returns true if an active RateConverter has no rates, or if the last two fetches in a row have failed.
+func (rc *RateConverter) Stale() bool {
+	if !rc.Active() {
+		return false
+	}
+	lastUpdated := rc.LastUpdated()
+	return lastUpdated.IsZero() || time.Since(lastUpdated) > 2*rc.fetchingInterval
+}
+
+// LastUpdated returns the time of the last successful fetch, or the zero time if there hasn't been one.
+func (rc *RateConverter) LastUpdated() time.Time {
+	if lastUpdated, ok := rc.lastUpdated.Load().(time.Time); ok {
+		return lastUpdated
+	}
+	return time.Time{}
+}
@@ -0,0 +1,72 @@
+package currencies
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateConverterFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"dataAsOf":"2018-09-12","conversions":{"USD":{"GBP":0.5}}}`))
+	}))
+	defer server.Close()
+
+	rc := NewRateConverter(server.Client(), server.URL, time.Hour)
+	assertRate(t, rc.Rates(), "GBP", "USD", 2)
+	if rc.LastUpdated().IsZero() {
+		t.Errorf("LastUpdated should be set after a successful fetch.")
+	}
+	if rc.Stale() {
+		t.Errorf("Rates which were just fetched shouldn't be stale.")
+	}
+}
+
+func TestRateConverterKeepsRatesOnFailure(t *testing.T) {
+	var fail int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"conversions":{"USD":{"GBP":0.5}}}`))
+	}))
+	defer server.Close()
+
+	rc := NewRateConverter(server.Client(), server.URL, time.Hour)
+	atomic.StoreInt32(&fail, 1)
+	if err := rc.Update(); err == nil {
+		t.Errorf("Update should fail if the server does.")
+	}
+	assertRate(t, rc.Rates(), "USD", "GBP", 0.5)
+}
+
+func TestRateConverterDisabled(t *testing.T) {
+	rc := NewRateConverter(http.DefaultClient, "", time.Hour)
+	assertNoRate(t, rc.Rates(), "USD", "GBP")
+	if !rc.LastUpdated().IsZero() {
+		t.Errorf("LastUpdated should be zero if nothing was fetched.")
+	}
+	if rc.Active() || rc.Stale() {
+		t.Errorf("A RateConverter which doesn't fetch anything should be neither active nor stale.")
+	}
+}
+
+func TestRateConverterStale(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	rc := NewRateConverter(server.Client(), server.URL, time.Hour)
+	if !rc.Stale() {
+		t.Errorf("The rates should be stale if they've never been fetched.")
+	}
+	rc.rates.Store(NewRates(time.Time{}, nil))
+	rc.lastUpdated.Store(time.Now().Add(-3 * time.Hour))
+	if !rc.Stale() {
+		t.Errorf("The rates should be stale if they haven't been updated for two intervals.")
+	}
+}
@@ -0,0 +1,166 @@
+package currencies
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Conversions can find the rate needed to convert prices from one currency to another.
+type Conversions interface {
+	// GetRate returns the number which prices in the "from" currency should be multiplied by
+	// to get the price in the "to" currency. Currencies are ISO-4217 codes, like "USD".
+	GetRate(from string, to string) (float64, error)
+}
+
+// Rates holds conversion rates, in the same format as the currency file at https://currency.prebid.org
+//
+// For example:
+//
+//	{
+//	  "dataAsOf": "2018-09-12",
+//	  "conversions": {
+//	    "USD": {
+//	      "GBP": 0.7662523901
+//	    }
+//	  }
+//	}
+type Rates struct {
+	DataAsOf    time.Time
+	Conversions map[string]map[string]float64
+}
+
+// NewRates makes a Rates from the given conversions. The currency codes are case-insensitive.
+func NewRates(dataAsOf time.Time, conversions map[string]map[string]float64) *Rates {
+	normalized := make(map[string]map[string]float64, len(conversions))
+	for from, rates := range conversions {
+		to := make(map[string]float64, len(rates))
+		for currency, rate := range rates {
+			to[strings.ToUpper(currency)] = rate
+		}
+		normalized[strings.ToUpper(from)] = to
+	}
+	return &Rates{
+		DataAsOf:    dataAsOf,
+		Conversions: normalized,
+	}
+}
+
+// UnmarshalJSON reads a currency file. Its dataAsOf is a date, rather than a full timestamp.
+func (r *Rates) UnmarshalJSON(b []byte) error {
+	var contract struct {
+		DataAsOf    string                        `json:"dataAsOf"`
+		Conversions map[string]map[string]float64 `json:"conversions"`
+	}
+	if err := json.Unmarshal(b, &contract); err != nil {
+		return err
+	}
+	var dataAsOf time.Time
+	if contract.DataAsOf != "" {
+		var err error
+		if dataAsOf, err = time.Parse("2006-01-02", contract.DataAsOf); err != nil {
+			return fmt.Errorf("dataAsOf must be a date like 2018-09-12. Got %s", contract.DataAsOf)
+		}
+	}
+	*r = *NewRates(dataAsOf, contract.Conversions)
+	return nil
+}
+
+// GetRate returns the rate from one currency to another. If the file only has the opposite rate, its inverse is used.
+func (r *Rates) GetRate(from string, to string) (float64, error) {
+	from = strings.ToUpper(from)
+	to = strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	if rate, ok := r.Conversions[from][to]; ok && rate > 0 {
+		return rate, nil
+	}
+	if rate, ok := r.Conversions[to][from]; ok && rate > 0 {
+		return 1 / rate, nil
+	}
+	return 0, fmt.Errorf("Currency conversion rate not found: '%s' => '%s'", from, to)
+}
+
+// constantRates is used when no rates have been loaded. It can only "convert" a currency to itself.
+type constantRates struct{}
+
+// NewConstantRates returns Conversions which fail for every pair of different currencies.
+func NewConstantRates() Conversions {
+	return constantRates{}
+}
+
+func (constantRates) GetRate(from string, to string) (float64, error) {
+	if strings.EqualFold(from, to) {
+		return 1, nil
+	}
+	return 0, fmt.Errorf("Currency conversion rate not found: '%s' => '%s'. No rates have been loaded", from, to)
+}
+
+// AggregateConversions looks for rates in the request's custom rates first, and falls back to the server's rates.
+type AggregateConversions struct {
+	customRates Conversions
+	serverRates Conversions
+}
+
+// NewAggregateConversions returns Conversions which prefer the customRates over the serverRates.
+func NewAggregateConversions(customRates Conversions, serverRates Conversions) *AggregateConversions {
+	return &AggregateConversions{
+		customRates: customRates,
+		serverRates: serverRates,
+	}
+}
+
+func (a *AggregateConversions) GetRate(from string, to string) (float64, error) {
+	if rate, err := a.customRates.GetRate(from, to); err == nil {
+		return rate, nil
+	}
+	return a.serverRates.GetRate(from, to)
+}
+
+// CrossRates derives the rates which its Conversions are missing by converting through an intermediate currency.
+// For example, if USD is the intermediate, the GBP => EUR rate can be found from the GBP => USD and USD => EUR rates.
+type CrossRates struct {
+	conversions  Conversions
+	intermediate string
+	// onConversion is called for every rate which is found between two different currencies, with true if it had to be
+	// derived. Callers should only look up the rates which they apply to bids, so that this counts real conversions.
+	onConversion func(derived bool)
+}
+
+// NewCrossRates returns Conversions which derive missing rates through the intermediate currency.
+// If intermediate is empty, no rates are derived. onConversion may be nil.
+func NewCrossRates(conversions Conversions, intermediate string, onConversion func(derived bool)) *CrossRates {
+	return &CrossRates{
+		conversions:  conversions,
+		intermediate: strings.ToUpper(intermediate),
+		onConversion: onConversion,
+	}
+}
+
+func (c *CrossRates) GetRate(from string, to string) (float64, error) {
+	rate, err := c.conversions.GetRate(from, to)
+	if err == nil {
+		if !strings.EqualFold(from, to) {
+			c.record(false)
+		}
+		return rate, nil
+	}
+	if c.intermediate == "" || strings.EqualFold(from, c.intermediate) || strings.EqualFold(to, c.intermediate) {
+		return 0, err
+	}
+	fromRate, fromErr := c.conversions.GetRate(from, c.intermediate)
+	toRate, toErr := c.conversions.GetRate(c.intermediate, to)
+	if fromErr != nil || toErr != nil {
+		return 0, err
+	}
+	c.record(true)
+	return fromRate * toRate, nil
+}
+
+func (c *CrossRates) record(derived bool) {
+	if c.onConversion != nil {
+		c.onConversion(derived)
+	}
+}
@@ -0,0 +1,105 @@
+package currencies
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalRates(t *testing.T) {
+	rates := &Rates{}
+	if err := json.Unmarshal([]byte(`{"dataAsOf":"2018-09-12","conversions":{"usd":{"gbp":0.75}}}`), rates); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !rates.DataAsOf.Equal(time.Date(2018, 9, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Bad dataAsOf: %v", rates.DataAsOf)
+	}
+	if rates.Conversions["USD"]["GBP"] != 0.75 {
+		t.Errorf("Currency codes should be upper case. Got %v", rates.Conversions)
+	}
+}
+
+func TestUnmarshalBadDate(t *testing.T) {
+	if err := json.Unmarshal([]byte(`{"dataAsOf":"yesterday"}`), &Rates{}); err == nil {
+		t.Errorf("Bad dates should be rejected.")
+	}
+}
+
+func TestGetRate(t *testing.T) {
+	rates := NewRates(time.Time{}, map[string]map[string]float64{
+		"USD": {
+			"GBP": 0.5,
+		},
+	})
+	assertRate(t, rates, "USD", "GBP", 0.5)
+	assertRate(t, rates, "gbp", "usd", 2)
+	assertRate(t, rates, "EUR", "EUR", 1)
+	assertNoRate(t, rates, "USD", "EUR")
+}
+
+func TestConstantRates(t *testing.T) {
+	rates := NewConstantRates()
+	assertRate(t, rates, "USD", "usd", 1)
+	assertNoRate(t, rates, "USD", "GBP")
+}
+
+func TestAggregateConversions(t *testing.T) {
+	custom := NewRates(time.Time{}, map[string]map[string]float64{
+		"USD": {
+			"GBP": 0.8,
+		},
+	})
+	server := NewRates(time.Time{}, map[string]map[string]float64{
+		"USD": {
+			"GBP": 0.5,
+			"EUR": 0.9,
+		},
+	})
+	rates := NewAggregateConversions(custom, server)
+	assertRate(t, rates, "USD", "GBP", 0.8)
+	assertRate(t, rates, "USD", "EUR", 0.9)
+	assertNoRate(t, rates, "USD", "JPY")
+}
+
+func assertRate(t *testing.T, rates Conversions, from string, to string, expected float64) {
+	t.Helper()
+	rate, err := rates.GetRate(from, to)
+	if err != nil {
+		t.Errorf("Unexpected error converting %s to %s: %v", from, to, err)
+	} else if rate != expected {
+		t.Errorf("Bad rate from %s to %s. Expected %f, got %f", from, to, expected, rate)
+	}
+}
+
+func assertNoRate(t *testing.T, rates Conversions, from string, to string) {
+	t.Helper()
+	if _, err := rates.GetRate(from, to); err == nil {
+		t.Errorf("Expected an error converting %s to %s", from, to)
+	}
+}
+
+func TestCrossRates(t *testing.T) {
+	rates := NewRates(time.Time{}, map[string]map[string]float64{
+		"USD": {
+			"GBP": 0.5,
+			"EUR": 0.8,
+		},
+	})
+	var direct, derived int
+	crossRates := NewCrossRates(rates, "usd", func(isDerived bool) {
+		if isDerived {
+			derived++
+		} else {
+			direct++
+		}
+	})
+	assertRate(t, crossRates, "GBP", "EUR", 1.6)
+	assertRate(t, crossRates, "USD", "GBP", 0.5)
+	assertRate(t, crossRates, "EUR", "EUR", 1)
+	assertNoRate(t, crossRates, "GBP", "JPY")
+	if direct != 1 || derived != 1 {
+		t.Errorf("Expected 1 direct and 1 derived conversion. Got %d and %d", direct, derived)
+	}
+
+	assertNoRate(t, NewCrossRates(rates, "", nil), "GBP", "EUR")
+}
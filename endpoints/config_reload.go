@@ -0,0 +1,26 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/prebid/prebid-server/logger"
+)
+
+// NewConfigReloadEndpoint reloads the settings which can be changed without a restart.
+// If the new config doesn't pass validation, the server keeps running with the old one, and the errors are returned.
+func NewConfigReloadEndpoint(reload func() error) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(); err != nil {
+			logger.Warningf("/config/reload kept the old config: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,49 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigReload(t *testing.T) {
+	reloads := 0
+	handler := NewConfigReloadEndpoint(func() error {
+		reloads++
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/config/reload", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Successful reloads should return a 200. Got %d", w.Code)
+	}
+	if reloads != 1 {
+		t.Errorf("The config should be reloaded once. Got %d reloads", reloads)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/config/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET requests should return a 405. Got %d", w.Code)
+	}
+	if reloads != 1 {
+		t.Errorf("GET requests shouldn't reload the config.")
+	}
+}
+
+func TestConfigReloadFailure(t *testing.T) {
+	handler := NewConfigReloadEndpoint(func() error {
+		return errors.New("tie_breaking.strategy is invalid")
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/config/reload", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Failed reloads should return a 400. Got %d", w.Code)
+	}
+	if w.Body.String() != "tie_breaking.strategy is invalid" {
+		t.Errorf("Failed reloads should return the error. Got %s", w.Body.String())
+	}
+}
@@ -17,26 +17,31 @@ import (
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/usersync"
 )
 
-func NewCookieSyncEndpoint(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, hostCookie *config.HostCookie, syncPermissions gdpr.Permissions, metrics pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule) httprouter.Handle {
+func NewCookieSyncEndpoint(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, hostCookie *config.HostCookie, syncPermissions gdpr.Permissions, activities *config.Activities, cookieDeprecation *config.CookieDeprecation, metrics pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule) httprouter.Handle {
 	deps := &cookieSyncDeps{
-		syncers:         syncers,
-		hostCookie:      hostCookie,
-		syncPermissions: syncPermissions,
-		metrics:         metrics,
-		pbsAnalytics:    pbsAnalytics,
+		syncers:           syncers,
+		hostCookie:        hostCookie,
+		syncPermissions:   syncPermissions,
+		activities:        activities,
+		cookieDeprecation: cookieDeprecation,
+		metrics:           metrics,
+		pbsAnalytics:      pbsAnalytics,
 	}
 	return deps.Endpoint
 }
 
 type cookieSyncDeps struct {
-	syncers         map[openrtb_ext.BidderName]usersync.Usersyncer
-	hostCookie      *config.HostCookie
-	syncPermissions gdpr.Permissions
-	metrics         pbsmetrics.MetricsEngine
-	pbsAnalytics    analytics.PBSAnalyticsModule
+	syncers           map[openrtb_ext.BidderName]usersync.Usersyncer
+	hostCookie        *config.HostCookie
+	syncPermissions   gdpr.Permissions
+	activities        *config.Activities
+	cookieDeprecation *config.CookieDeprecation
+	metrics           pbsmetrics.MetricsEngine
+	pbsAnalytics      analytics.PBSAnalyticsModule
 }
 
 func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -97,7 +102,15 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 	}
 
 	parsedReq.filterExistingSyncs(deps.syncers, userSyncCookie)
-	parsedReq.filterForGDPR(deps.syncPermissions)
+	control := privacy.NewActivityControl(deps.activities, parsedReq.Account).WithPolicies(newSyncGDPRPolicy(parsedReq, deps.syncPermissions))
+	if parsedReq.COPPA == 1 {
+		control = control.WithPolicies(privacy.COPPAPolicy)
+	}
+	parsedReq.filterForActivities(control, deps.syncers)
+
+	if deps.cookieDeprecation.EnabledFor(parsedReq.Account) {
+		setCookieDeprecationCookie(w, r, deps.cookieDeprecation.TTLSec)
+	}
 
 	csResp := cookieSyncResponse{
 		Status:       cookieSyncStatus(userSyncCookie.LiveSyncCount()),
@@ -121,6 +134,18 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 	enc.Encode(csResp)
 }
 
+// receiveCookieDeprecation is the cookie which opts the user's browser into sending the Sec-Cookie-Deprecation header.
+const receiveCookieDeprecation = "receive-cookie-deprecation"
+
+// setCookieDeprecationCookie sets the receive-cookie-deprecation cookie, unless the browser already has it.
+// Chrome only honors it if it's partitioned, which net/http doesn't support, so the header is written by hand.
+func setCookieDeprecationCookie(w http.ResponseWriter, r *http.Request, ttlSec int) {
+	if _, err := r.Cookie(receiveCookieDeprecation); err == nil {
+		return
+	}
+	w.Header().Add("Set-Cookie", fmt.Sprintf("%s=1; Path=/; Max-Age=%d; HttpOnly; Secure; SameSite=None; Partitioned", receiveCookieDeprecation, ttlSec))
+}
+
 func gdprToString(gdpr *int) string {
 	if gdpr == nil {
 		return ""
@@ -149,6 +174,8 @@ type cookieSyncRequest struct {
 	Bidders []string `json:"bidders"`
 	GDPR    *int     `json:"gdpr"`
 	Consent string   `json:"gdpr_consent"`
+	COPPA   int      `json:"coppa"`
+	Account string   `json:"account"`
 }
 
 func (req *cookieSyncRequest) filterExistingSyncs(valid map[openrtb_ext.BidderName]usersync.Usersyncer, cookie *usersync.PBSCookie) {
@@ -161,18 +188,51 @@ func (req *cookieSyncRequest) filterExistingSyncs(valid map[openrtb_ext.BidderNa
 	}
 }
 
-func (req *cookieSyncRequest) filterForGDPR(permissions gdpr.Permissions) {
+// syncGDPRPolicy denies syncs to every bidder if the consent string doesn't allow the host's cookie,
+// and otherwise to the bidders which it doesn't allow to sync.
+type syncGDPRPolicy struct {
+	permissions gdpr.Permissions
+	consent     string
+	hostAllowed bool
+}
+
+// newSyncGDPRPolicy returns the policy for the request's consent string, or nil if the request says that GDPR doesn't apply.
+func newSyncGDPRPolicy(req *cookieSyncRequest, permissions gdpr.Permissions) privacy.Policy {
 	if req.GDPR != nil && *req.GDPR == 0 {
-		return
+		return nil
+	}
+	hostAllowed, err := permissions.HostCookiesAllowed(context.Background(), req.Consent)
+	return &syncGDPRPolicy{
+		permissions: permissions,
+		consent:     req.Consent,
+		hostAllowed: err == nil && hostAllowed,
 	}
+}
 
-	if allowSync, err := permissions.HostCookiesAllowed(context.Background(), req.Consent); err != nil || !allowSync {
-		req.Bidders = nil
-		return
+func (p *syncGDPRPolicy) Name() string {
+	return "gdpr"
+}
+
+func (p *syncGDPRPolicy) Allow(activity privacy.Activity, component privacy.Component) bool {
+	if activity != privacy.ActivitySyncUser {
+		return true
+	}
+	if !p.hostAllowed {
+		return false
 	}
+	allowSync, err := p.permissions.BidderSyncAllowed(context.Background(), openrtb_ext.BidderName(component.BidderName), p.consent)
+	return err == nil && allowSync
+}
 
+// filterForActivities drops the bidders which the control doesn't allow to sync, whether it's the account's rules
+// or the request's privacy policies which deny it.
+func (req *cookieSyncRequest) filterForActivities(control privacy.ActivityControl, syncers map[openrtb_ext.BidderName]usersync.Usersyncer) {
 	for i := 0; i < len(req.Bidders); i++ {
-		if allowSync, err := permissions.BidderSyncAllowed(context.Background(), openrtb_ext.BidderName(req.Bidders[i]), req.Consent); err != nil || !allowSync {
+		component := privacy.Component{
+			BidderName: req.Bidders[i],
+			GVLID:      syncers[openrtb_ext.BidderName(req.Bidders[i])].GDPRVendorID(),
+		}
+		if !control.Allow(privacy.ActivitySyncUser, component) {
 			req.Bidders = append(req.Bidders[:i], req.Bidders[i+1:]...)
 			i--
 		}
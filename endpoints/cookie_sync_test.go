@@ -52,6 +52,59 @@ func TestGDPRIgnoredIfZero(t *testing.T) {
 	assertStatus(t, rr.Body.Bytes(), "no_cookie")
 }
 
+func TestCOPPAPreventsSyncs(t *testing.T) {
+	rr := doPost(`{"coppa":1,"bidders":["appnexus", "pubmatic"]}`, nil, true, syncersForTest())
+	assertIntsMatch(t, http.StatusOK, rr.Code)
+
+	assertSyncsExist(t, rr.Body.Bytes())
+	assertStatus(t, rr.Body.Bytes(), "no_cookie")
+}
+
+func TestActivitiesPreventSyncs(t *testing.T) {
+	activities := &config.Activities{
+		Accounts: []config.AccountActivities{{
+			ID: "some-account",
+			Activities: config.ActivityControls{
+				SyncUser: config.Activity{
+					Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{BidderNames: []string{"appnexus"}}}},
+				},
+			},
+		}},
+	}
+	endpoint := NewCookieSyncEndpoint(syncersForTest(), &config.HostCookie{}, mockPermissions(true, nil), activities, &config.CookieDeprecation{}, &metricsConf.DummyMetricsEngine{}, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil))
+
+	rr := httptest.NewRecorder()
+	endpoint(rr, httptest.NewRequest("POST", "/cookie_sync", strings.NewReader(`{"gdpr":0,"account":"some-account","bidders":["appnexus", "audienceNetwork"]}`)), nil)
+	assertIntsMatch(t, http.StatusOK, rr.Code)
+	assertSyncsExist(t, rr.Body.Bytes(), "audienceNetwork")
+
+	rr = httptest.NewRecorder()
+	endpoint(rr, httptest.NewRequest("POST", "/cookie_sync", strings.NewReader(`{"gdpr":0,"account":"other-account","bidders":["appnexus", "audienceNetwork"]}`)), nil)
+	assertSyncsExist(t, rr.Body.Bytes(), "appnexus", "audienceNetwork")
+}
+
+func TestCookieDeprecationCookie(t *testing.T) {
+	cookieDeprecation := &config.CookieDeprecation{
+		TTLSec:   3600,
+		Accounts: []config.AccountCookieDeprecation{{ID: "some-account", Enabled: true}},
+	}
+	endpoint := NewCookieSyncEndpoint(syncersForTest(), &config.HostCookie{}, mockPermissions(true, nil), &config.Activities{}, cookieDeprecation, &metricsConf.DummyMetricsEngine{}, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil))
+
+	rr := httptest.NewRecorder()
+	endpoint(rr, httptest.NewRequest("POST", "/cookie_sync", strings.NewReader(`{"account":"some-account","bidders":["appnexus"]}`)), nil)
+	assertStringsMatch(t, "receive-cookie-deprecation=1; Path=/; Max-Age=3600; HttpOnly; Secure; SameSite=None; Partitioned", rr.Header().Get("Set-Cookie"))
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/cookie_sync", strings.NewReader(`{"account":"some-account","bidders":["appnexus"]}`))
+	req.AddCookie(&http.Cookie{Name: "receive-cookie-deprecation", Value: "1"})
+	endpoint(rr, req, nil)
+	assertStringsMatch(t, "", rr.Header().Get("Set-Cookie"))
+
+	rr = httptest.NewRecorder()
+	endpoint(rr, httptest.NewRequest("POST", "/cookie_sync", strings.NewReader(`{"account":"other-account","bidders":["appnexus"]}`)), nil)
+	assertStringsMatch(t, "", rr.Header().Get("Set-Cookie"))
+}
+
 func TestGDPRConsentRequired(t *testing.T) {
 	rr := doPost(`{"gdpr":1,"bidders":["appnexus", "pubmatic"]}`, nil, false, nil)
 	assertIntsMatch(t, http.StatusBadRequest, rr.Code)
@@ -103,7 +156,7 @@ func doPost(body string, existingSyncs map[string]string, gdprHostConsent bool,
 }
 
 func testableEndpoint(perms gdpr.Permissions) httprouter.Handle {
-	return NewCookieSyncEndpoint(syncersForTest(), &config.HostCookie{}, perms, &metricsConf.DummyMetricsEngine{}, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	return NewCookieSyncEndpoint(syncersForTest(), &config.HostCookie{}, perms, &config.Activities{}, &config.CookieDeprecation{}, &metricsConf.DummyMetricsEngine{}, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil))
 }
 
 func syncersForTest() map[openrtb_ext.BidderName]usersync.Usersyncer {
@@ -188,3 +241,7 @@ func (g *gdprPerms) BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.Bi
 	_, ok := g.allowedBidders[bidder]
 	return ok, nil
 }
+
+func (g *gdprPerms) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (gdpr.AuctionPermissions, error) {
+	return gdpr.AllowAll, nil
+}
@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/logger"
+)
+
+type currencyRatesInfo struct {
+	Active           bool                          `json:"active"`
+	Source           string                        `json:"source,omitempty"`
+	FetchingInterval string                        `json:"fetchingInterval,omitempty"`
+	LastUpdated      *time.Time                    `json:"lastUpdated,omitempty"`
+	DataAsOf         *time.Time                    `json:"dataAsOf,omitempty"`
+	Stale            bool                          `json:"stale"`
+	Rates            map[string]map[string]float64 `json:"rates,omitempty"`
+}
+
+// NewCurrencyRatesEndpoint returns the currency rates which the server is using, and whether they're up to date.
+func NewCurrencyRatesEndpoint(rateConverter *currencies.RateConverter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := currencyRatesInfo{
+			Active: rateConverter.Active(),
+			Stale:  rateConverter.Stale(),
+		}
+		if info.Active {
+			info.Source = rateConverter.SyncSourceURL()
+			info.FetchingInterval = rateConverter.FetchingInterval().String()
+		}
+		if lastUpdated := rateConverter.LastUpdated(); !lastUpdated.IsZero() {
+			info.LastUpdated = &lastUpdated
+		}
+		if rates, ok := rateConverter.Rates().(*currencies.Rates); ok {
+			if !rates.DataAsOf.IsZero() {
+				info.DataAsOf = &rates.DataAsOf
+			}
+			info.Rates = rates.Conversions
+		}
+
+		jsonOutput, err := json.Marshal(info)
+		if err != nil {
+			logger.Errorf("/currency/rates Critical error when trying to marshal currencyRatesInfo: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonOutput)
+	}
+}
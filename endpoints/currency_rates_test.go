@@ -0,0 +1,53 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/currencies"
+)
+
+func TestCurrencyRates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"dataAsOf":"2018-09-12","conversions":{"USD":{"GBP":0.75}}}`))
+	}))
+	defer server.Close()
+
+	rateConverter := currencies.NewRateConverter(server.Client(), server.URL, time.Hour)
+	result := getCurrencyRates(t, rateConverter)
+
+	if !result.Active || result.Stale {
+		t.Errorf("Freshly fetched rates should be active and not stale. Got %#v", result)
+	}
+	if result.Source != server.URL {
+		t.Errorf("Bad source. Expected %s, got %s", server.URL, result.Source)
+	}
+	if result.LastUpdated == nil || result.DataAsOf == nil || !result.DataAsOf.Equal(time.Date(2018, 9, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Bad timestamps. Got lastUpdated=%v, dataAsOf=%v", result.LastUpdated, result.DataAsOf)
+	}
+	if result.Rates["USD"]["GBP"] != 0.75 {
+		t.Errorf("Bad rates. Got %v", result.Rates)
+	}
+}
+
+func TestCurrencyRatesInactive(t *testing.T) {
+	result := getCurrencyRates(t, currencies.NewRateConverter(http.DefaultClient, "", 0))
+	if result.Active || result.Stale || result.LastUpdated != nil || len(result.Rates) != 0 {
+		t.Errorf("A converter which doesn't fetch rates should have nothing to show. Got %#v", result)
+	}
+}
+
+func getCurrencyRates(t *testing.T, rateConverter *currencies.RateConverter) currencyRatesInfo {
+	t.Helper()
+	w := httptest.NewRecorder()
+	NewCurrencyRatesEndpoint(rateConverter)(w, nil)
+
+	var result currencyRatesInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Bad response body: %v", err)
+	}
+	return result
+}
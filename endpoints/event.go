@@ -0,0 +1,38 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// NewEventEndpoint records the events reported by the trackers which Prebid Server added to bids.
+// The query has the event type in t, the bid ID in b, the bidder and the account in a.
+func NewEventEndpoint(metrics pbsmetrics.MetricsEngine) httprouter.Handle {
+	return httprouter.Handle(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		query := r.URL.Query()
+		event := pbsmetrics.EventType(query.Get("t"))
+		if !isEventType(event) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("t must be one of imp, vimp or click."))
+			return
+		}
+		if query.Get("b") == "" || query.Get("bidder") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("b and bidder are required."))
+			return
+		}
+		metrics.RecordEvent(event)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func isEventType(event pbsmetrics.EventType) bool {
+	for _, eventType := range pbsmetrics.EventTypes() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
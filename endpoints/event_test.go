@@ -0,0 +1,36 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestEventEndpoint(t *testing.T) {
+	metricsEngine := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	endpoint := NewEventEndpoint(metricsEngine)
+
+	w := httptest.NewRecorder()
+	endpoint(w, httptest.NewRequest("GET", "/event?t=click&b=bid&bidder=appnexus&a=1001", nil), nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Valid events should return a 204. Got %d", w.Code)
+	}
+	if count := metricsEngine.EventMeters[pbsmetrics.EventClick].Count(); count != 1 {
+		t.Errorf("The click should be recorded. Got %d", count)
+	}
+
+	for _, query := range []string{"t=win&b=bid&bidder=appnexus", "t=imp&bidder=appnexus", "t=imp&b=bid"} {
+		w = httptest.NewRecorder()
+		endpoint(w, httptest.NewRequest("GET", "/event?"+query, nil), nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Invalid events should return a 400. Got %d for %s", w.Code, query)
+		}
+	}
+	if count := metricsEngine.EventMeters[pbsmetrics.EventImpression].Count(); count != 0 {
+		t.Errorf("Invalid events shouldn't be recorded. Got %d", count)
+	}
+}
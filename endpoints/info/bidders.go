@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 )
 
@@ -19,13 +19,13 @@ func NewBiddersEndpoint() httprouter.Handle {
 
 	biddersJson, err := json.Marshal(bidderNames)
 	if err != nil {
-		glog.Fatalf("error creating /info/bidders endpoint response: %v", err)
+		logger.Fatalf("error creating /info/bidders endpoint response: %v", err)
 	}
 
 	return httprouter.Handle(func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 		w.Header().Set("Content-Type", "application/json")
 		if _, err := w.Write(biddersJson); err != nil {
-			glog.Errorf("error writing response to /info/bidders: %v", err)
+			logger.Errorf("error writing response to /info/bidders: %v", err)
 		}
 	})
 }
@@ -37,7 +37,7 @@ func NewBidderDetailsEndpoint(infos adapters.BidderInfos) httprouter.Handle {
 	for bidderName, bidderInfo := range infos {
 		jsonData, err := json.Marshal(bidderInfo)
 		if err != nil {
-			glog.Fatalf("Failed to JSON-marshal bidder-info/%s.yaml data.", bidderName)
+			logger.Fatalf("Failed to JSON-marshal bidder-info/%s.yaml data.", bidderName)
 		}
 		responses[bidderName] = jsonData
 	}
@@ -48,7 +48,7 @@ func NewBidderDetailsEndpoint(infos adapters.BidderInfos) httprouter.Handle {
 		if response, ok := responses[forBidder]; ok {
 			w.Header().Set("Content-Type", "application/json")
 			if _, err := w.Write(response); err != nil {
-				glog.Errorf("error writing response to /info/bidders/%s: %v", forBidder, err)
+				logger.Errorf("error writing response to /info/bidders/%s: %v", forBidder, err)
 			}
 		} else {
 			w.WriteHeader(http.StatusNotFound)
@@ -199,6 +199,20 @@ func validateInfo(info *adapters.BidderInfo) error {
 		return err
 	}
 
+	if err := validateDeprecation(info.Deprecation); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateDeprecation(info *adapters.DeprecationInfo) error {
+	if info == nil || info.Replacement == "" {
+		return nil
+	}
+	if _, ok := openrtb_ext.BidderMap[info.Replacement]; !ok {
+		return fmt.Errorf("deprecation.replacement %s is not a known bidder", info.Replacement)
+	}
 	return nil
 }
 
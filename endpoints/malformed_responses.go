@@ -0,0 +1,40 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// NewMalformedResponsesEndpoint returns the malformed bidder responses which were captured, keyed by bidder.
+// The bidder query param limits them to a single bidder.
+func NewMalformedResponsesEndpoint(capture *exchange.ResponseCapture) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if capture == nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Malformed responses aren't captured. Set response_capture.enabled to capture them."))
+			return
+		}
+		responses := capture.Responses()
+		if bidder := r.URL.Query().Get("bidder"); bidder != "" {
+			filtered := make(map[openrtb_ext.BidderName][]exchange.CapturedResponse, 1)
+			if list, ok := responses[openrtb_ext.BidderName(bidder)]; ok {
+				filtered[openrtb_ext.BidderName(bidder)] = list
+			}
+			responses = filtered
+		}
+
+		jsonOutput, err := json.Marshal(responses)
+		if err != nil {
+			logger.Errorf("/bidders/malformed_responses Critical error when trying to marshal the responses: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonOutput)
+	}
+}
@@ -0,0 +1,31 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/exchange"
+)
+
+func TestMalformedResponsesDisabled(t *testing.T) {
+	handler := NewMalformedResponsesEndpoint(exchange.NewResponseCapture(config.ResponseCapture{}))
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/bidders/malformed_responses", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("The endpoint should return a 404 when responses aren't captured. Got %d", w.Code)
+	}
+}
+
+func TestMalformedResponses(t *testing.T) {
+	handler := NewMalformedResponsesEndpoint(exchange.NewResponseCapture(config.ResponseCapture{Enabled: true, Size: 5}))
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/bidders/malformed_responses?bidder=appnexus", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a 200. Got %d", w.Code)
+	}
+	if w.Body.String() != "{}" {
+		t.Errorf("Expected no responses. Got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,155 @@
+package openrtb2
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/blocklist"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// The codes in the bodies of the responses to requests whose account can't be served.
+const (
+	accountErrorUnknown   = "account_unknown"
+	accountErrorDisabled  = "account_disabled"
+	accountErrorMalformed = "account_malformed"
+	accountErrorBlocked   = "blocked"
+)
+
+// accountError explains why a request's account can't be served. It's written to the response as JSON,
+// so that callers can tell the cases apart without parsing the message.
+type accountError struct {
+	status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *accountError) Error() string {
+	return err.Message
+}
+
+// fetchAccount returns an error if the requests from the account shouldn't be served.
+// Disabled accounts are always rejected. Other accounts which aren't in accounts.known are only rejected
+// if accounts.reject_unknown is set, and are served with the defaults otherwise.
+func fetchAccount(cfg *config.Accounts, id string) *accountError {
+	if account := cfg.Lookup(id); account != nil {
+		if account.Disabled {
+			return &accountError{http.StatusForbidden, accountErrorDisabled, fmt.Sprintf("Account %s is disabled.", id)}
+		}
+		return nil
+	}
+	if !cfg.RejectUnknown {
+		return nil
+	}
+	if id == "" {
+		return &accountError{http.StatusUnauthorized, accountErrorUnknown, "The request has no account. Set site.publisher.id or app.publisher.id."}
+	}
+	if !config.ValidAccountID(id) {
+		return &accountError{http.StatusBadRequest, accountErrorMalformed, fmt.Sprintf("Account %q must not contain spaces or control characters.", id)}
+	}
+	return &accountError{http.StatusUnauthorized, accountErrorUnknown, fmt.Sprintf("Account %s is unknown.", id)}
+}
+
+// checkBlocklist returns an error if the request comes from a publisher, domain or app which the host has banned.
+// It has its own status, so that the sources can tell that they've been cut off, rather than that something's broken
+// with their account.
+func checkBlocklist(list *blocklist.List, req *openrtb.BidRequest) *accountError {
+	if blocked := list.Blocked(req); blocked != "" {
+		return &accountError{http.StatusServiceUnavailable, accountErrorBlocked, fmt.Sprintf("Requests from %s are blocked by this host.", blocked)}
+	}
+	return nil
+}
+
+// debugTokenHeader carries the token which requests need to use test mode, if the account has one.
+const debugTokenHeader = "X-Prebid-Debug-Token"
+
+// gateTestMode returns false if the request asked for test mode but may not see the debugging info, with a warning
+// which says why. The request keeps request.test, so the bidders still treat it as a test, but the auction must be
+// run with exchange.WithoutDebug. The warning is empty if the request didn't ask for test mode, or may use it.
+func gateTestMode(cfg *config.Accounts, httpReq *http.Request, req *openrtb.BidRequest) (bool, string) {
+	if req.Test != 1 {
+		return true, ""
+	}
+	if !cfg.DebugAllowedFor(accountID(req)) {
+		return false, "response.ext.debug was left out, because test mode isn't allowed for this account."
+	}
+	if token := cfg.DebugTokenFor(accountID(req)); token != "" && subtle.ConstantTimeCompare([]byte(httpReq.Header.Get(debugTokenHeader)), []byte(token)) != 1 {
+		return false, fmt.Sprintf("response.ext.debug was left out, because the %s header was missing or wrong.", debugTokenHeader)
+	}
+	return true, ""
+}
+
+// writeAccountError writes the error to the response, with its status.
+func writeAccountError(w http.ResponseWriter, err *accountError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.status)
+	json.NewEncoder(w).Encode(err)
+}
+
+// removeDisallowedBidders removes the params of the bidders which the account isn't allowed to use from each imp,
+// so that pages can't send its traffic to partners it hasn't approved. It returns a warning for each one removed.
+func (deps *endpointDeps) removeDisallowedBidders(req *openrtb.BidRequest) []string {
+	account := deps.cfg.Accounts.Lookup(accountID(req))
+	if account == nil || len(account.Bidders) == 0 {
+		return nil
+	}
+	var aliases map[string]string
+	if requestExt, err := deps.parseBidExt(req.Ext); err == nil && requestExt != nil {
+		aliases = requestExt.Prebid.Aliases
+	}
+
+	var warnings []string
+	for i := range req.Imp {
+		bidderExts, err := openrtb_ext.ImpBidderParams(json.RawMessage(req.Imp[i].Ext))
+		if err != nil {
+			continue
+		}
+		var disallowed []string
+		for bidder := range bidderExts {
+			coreBidder := bidder
+			if tmp, isAlias := aliases[bidder]; isAlias {
+				coreBidder = tmp
+			}
+			if !account.AllowsBidder(bidder) && !account.AllowsBidder(coreBidder) {
+				disallowed = append(disallowed, bidder)
+			}
+		}
+		if len(disallowed) == 0 {
+			continue
+		}
+		ext, err := openrtb_ext.RemoveImpBidders(json.RawMessage(req.Imp[i].Ext), disallowed)
+		if err != nil {
+			continue
+		}
+		req.Imp[i].Ext = openrtb.RawJSON(ext)
+		sort.Strings(disallowed)
+		for _, bidder := range disallowed {
+			warnings = append(warnings, fmt.Sprintf("request.imp[%d].ext.%s was removed, because account %s isn't allowed to use %s.", i, bidder, account.ID, bidder))
+		}
+	}
+	return warnings
+}
+
+// applyAccountCurrency sets request.cur to the account's currency, if the request doesn't have one, or if the account
+// forces it. It returns a warning if a currency which the request asked for was replaced.
+func (deps *endpointDeps) applyAccountCurrency(req *openrtb.BidRequest) string {
+	account := deps.cfg.Accounts.Lookup(accountID(req))
+	if account == nil || account.Currency == "" {
+		return ""
+	}
+	if len(req.Cur) == 0 {
+		req.Cur = []string{account.Currency}
+		return ""
+	}
+	if !account.ForceCurrency || (len(req.Cur) == 1 && req.Cur[0] == account.Currency) {
+		return ""
+	}
+	warning := fmt.Sprintf("request.cur %v was replaced with %s, because account %s only takes bids in %s.", req.Cur, account.Currency, account.ID, account.Currency)
+	req.Cur = []string{account.Currency}
+	return warning
+}
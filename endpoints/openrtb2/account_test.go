@@ -0,0 +1,237 @@
+package openrtb2
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	analyticsConf "github.com/prebid/prebid-server/analytics/config"
+	"github.com/prebid/prebid-server/blocklist"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFetchAccount(t *testing.T) {
+	cfg := &config.Accounts{
+		Known: []config.Account{{ID: "1001"}, {ID: "1002", Disabled: true}},
+	}
+	testCases := []struct {
+		rejectUnknown bool
+		id            string
+		code          string
+	}{
+		{false, "1001", ""},
+		{false, "1002", accountErrorDisabled},
+		{false, "1003", ""},
+		{false, "", ""},
+		{false, "10 03", ""},
+		{true, "1001", ""},
+		{true, "1002", accountErrorDisabled},
+		{true, "1003", accountErrorUnknown},
+		{true, "", accountErrorUnknown},
+		{true, "10 03", accountErrorMalformed},
+	}
+	for _, test := range testCases {
+		cfg.RejectUnknown = test.rejectUnknown
+		err := fetchAccount(cfg, test.id)
+		if test.code == "" && err != nil {
+			t.Errorf("Account %q with reject_unknown=%t should be served. Got %v", test.id, test.rejectUnknown, err)
+		}
+		if test.code != "" && (err == nil || err.Code != test.code) {
+			t.Errorf("Account %q with reject_unknown=%t should get a %s error. Got %v", test.id, test.rejectUnknown, test.code, err)
+		}
+	}
+}
+
+func TestGateTestMode(t *testing.T) {
+	cfg := &config.Accounts{
+		Debug:      config.DebugDeny,
+		DebugToken: "s3cret",
+		Known:      []config.Account{{ID: "1001", Debug: config.DebugAllow}, {ID: "1003", Debug: config.DebugAllow, DebugToken: "0wn"}},
+	}
+	testCases := []struct {
+		description string
+		account     string
+		test        int8
+		token       string
+		allowed     bool
+	}{
+		{description: "allowed account with the token", account: "1001", test: 1, token: "s3cret", allowed: true},
+		{description: "allowed account without the token", account: "1001", test: 1},
+		{description: "allowed account with the wrong token", account: "1001", test: 1, token: "guess"},
+		{description: "denied account with the token", account: "1002", test: 1, token: "s3cret"},
+		{description: "account with its own token", account: "1003", test: 1, token: "0wn", allowed: true},
+		{description: "account with its own token, given the host's", account: "1003", test: 1, token: "s3cret"},
+		{description: "not a test request", account: "1002"},
+	}
+	for _, test := range testCases {
+		httpReq := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+		if test.token != "" {
+			httpReq.Header.Set(debugTokenHeader, test.token)
+		}
+		req := &openrtb.BidRequest{Test: test.test, Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: test.account}}}
+		allowed, warning := gateTestMode(cfg, httpReq, req)
+		if req.Test != test.test {
+			t.Errorf("%s: request.test should be kept for the bidders. Got %d", test.description, req.Test)
+		}
+		if test.allowed && (!allowed || warning != "") {
+			t.Errorf("%s: test mode should be allowed. Got %t and warning %q", test.description, allowed, warning)
+		}
+		if !test.allowed && test.test == 1 && (allowed || warning == "") {
+			t.Errorf("%s: the debugging info should be left out, with a warning which says why. Got %t and warning %q", test.description, allowed, warning)
+		}
+	}
+}
+
+func TestAccountErrors(t *testing.T) {
+	cfg := &config.Configuration{
+		MaxRequestSize: maxSize,
+		Accounts: config.Accounts{
+			RejectUnknown: true,
+			Known:         []config.Account{{ID: "1001"}, {ID: "1002", Disabled: true}},
+		},
+	}
+	endpoint, _ := NewEndpoint(&mockExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+
+	testCases := map[string]struct {
+		status int
+		code   string
+	}{
+		"1001":   {http.StatusOK, ""},
+		"1002":   {http.StatusForbidden, accountErrorDisabled},
+		"1003":   {http.StatusUnauthorized, accountErrorUnknown},
+		`10\t03`: {http.StatusBadRequest, accountErrorMalformed},
+	}
+	for id, expected := range testCases {
+		reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com","publisher":{"id":"` + id + `"}},"imp":[` +
+			`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+		if recorder.Code != expected.status {
+			t.Errorf("Account %s: expected status %d. Got %d: %s", id, expected.status, recorder.Code, recorder.Body.String())
+		}
+		if expected.code == "" {
+			continue
+		}
+		if code, _ := jsonparser.GetString(recorder.Body.Bytes(), "code"); code != expected.code {
+			t.Errorf("Account %s: expected the error code %s. Got %s", id, expected.code, recorder.Body.String())
+		}
+	}
+}
+
+func TestBlockedRequests(t *testing.T) {
+	file, err := ioutil.TempFile("", "blocklist")
+	if err != nil {
+		t.Fatalf("Failed to make the blocklist file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"publishers":["1002"],"domains":["fraud.com"]}`)
+	file.Close()
+	blocked, err := blocklist.NewList(&config.Blocklist{Enabled: true, File: file.Name()}, nil)
+	if err != nil {
+		t.Fatalf("Failed to load the blocklist: %v", err)
+	}
+	endpoint, _ := NewEndpoint(&mockExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, blocked, nil)
+
+	testCases := map[string]struct {
+		site   string
+		status int
+	}{
+		"allowed":           {`{"page":"https://news.com","publisher":{"id":"1001"}}`, http.StatusOK},
+		"blocked publisher": {`{"page":"https://news.com","publisher":{"id":"1002"}}`, http.StatusServiceUnavailable},
+		"blocked domain":    {`{"page":"https://www.fraud.com/article","publisher":{"id":"1001"}}`, http.StatusServiceUnavailable},
+	}
+	for name, expected := range testCases {
+		reqBody := `{"id":"some-request-id","site":` + expected.site + `,"imp":[` +
+			`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+		if recorder.Code != expected.status {
+			t.Errorf("%s: expected status %d. Got %d: %s", name, expected.status, recorder.Code, recorder.Body.String())
+		}
+		if code, _ := jsonparser.GetString(recorder.Body.Bytes(), "code"); expected.status != http.StatusOK && code != accountErrorBlocked {
+			t.Errorf("%s: expected the error code %s. Got %s", name, accountErrorBlocked, recorder.Body.String())
+		}
+	}
+}
+
+func TestRemoveDisallowedBidders(t *testing.T) {
+	deps := &endpointDeps{cfg: &config.Configuration{
+		Accounts: config.Accounts{
+			Known: []config.Account{{ID: "1001", Bidders: []string{"appnexus"}}, {ID: "1002"}},
+		},
+	}}
+	newRequest := func(account string) *openrtb.BidRequest {
+		return &openrtb.BidRequest{
+			Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: account}},
+			Imp: []openrtb.Imp{
+				{ID: "imp-1", Ext: openrtb.RawJSON(`{"prebid":{"bidder":{"appnexus":{"placementId":1},"rubicon":{"accountId":2},"myappnexus":{"placementId":3}}}}`)},
+				{ID: "imp-2", Ext: openrtb.RawJSON(`{"prebid":{"bidder":{"appnexus":{"placementId":1}}}}`)},
+			},
+			Ext: openrtb.RawJSON(`{"prebid":{"aliases":{"myappnexus":"appnexus"}}}`),
+		}
+	}
+
+	req := newRequest("1001")
+	warnings := deps.removeDisallowedBidders(req)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "request.imp[0].ext.rubicon was removed") {
+		t.Errorf("There should be a warning for rubicon. Got %v", warnings)
+	}
+	if ext := string(req.Imp[0].Ext); ext != `{"prebid":{"bidder":{"appnexus":{"placementId":1},"myappnexus":{"placementId":3}}}}` {
+		t.Errorf("Only rubicon should be removed. Aliases of allowed bidders are allowed too. Got %s", ext)
+	}
+	if ext := string(req.Imp[1].Ext); ext != `{"prebid":{"bidder":{"appnexus":{"placementId":1}}}}` {
+		t.Errorf("Imps with only allowed bidders shouldn't change. Got %s", ext)
+	}
+
+	for _, account := range []string{"1002", "1003"} {
+		req = newRequest(account)
+		if warnings := deps.removeDisallowedBidders(req); len(warnings) != 0 || !strings.Contains(string(req.Imp[0].Ext), "rubicon") {
+			t.Errorf("Account %s may use every bidder. Got %v", account, warnings)
+		}
+	}
+}
+
+func TestApplyAccountCurrency(t *testing.T) {
+	deps := &endpointDeps{cfg: &config.Configuration{
+		Accounts: config.Accounts{
+			Known: []config.Account{{ID: "1001", Currency: "EUR"}, {ID: "1002", Currency: "EUR", ForceCurrency: true}, {ID: "1003"}},
+		},
+	}}
+	testCases := []struct {
+		account string
+		cur     []string
+		want    []string
+		warning bool
+	}{
+		{"1001", nil, []string{"EUR"}, false},
+		{"1001", []string{"USD"}, []string{"USD"}, false},
+		{"1002", nil, []string{"EUR"}, false},
+		{"1002", []string{"EUR"}, []string{"EUR"}, false},
+		{"1002", []string{"USD", "EUR"}, []string{"EUR"}, true},
+		{"1003", nil, nil, false},
+		{"1004", []string{"GBP"}, []string{"GBP"}, false},
+	}
+	for _, test := range testCases {
+		req := &openrtb.BidRequest{Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: test.account}}, Cur: test.cur}
+		warning := deps.applyAccountCurrency(req)
+		if !reflect.DeepEqual(req.Cur, test.want) {
+			t.Errorf("Account %s with cur %v should have cur %v. Got %v", test.account, test.cur, test.want, req.Cur)
+		}
+		if (warning != "") != test.warning {
+			t.Errorf("Account %s with cur %v got the wrong warning: %q", test.account, test.cur, warning)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package openrtb2
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,15 +10,20 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang/glog"
+	"github.com/evanphx/json-patch"
 	"github.com/julienschmidt/httprouter"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/blocklist"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/ivt"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync"
 )
 
@@ -32,12 +36,16 @@ type AmpResponse struct {
 
 // We need to modify the OpenRTB endpoint to handle AMP requests. This will basically modify the parsing
 // of the request, and the return value, using the OpenRTB machinery to handle everything inbetween.
-func NewAmpEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule) (httprouter.Handle, error) {
+func NewAmpEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, bidderInfos adapters.BidderInfos, blocked *blocklist.List, ivtFilter *ivt.Filter) (httprouter.Handle, error) {
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
 		return nil, errors.New("NewAmpEndpoint requires non-nil arguments.")
 	}
+	accountParams, err := openrtb_ext.NewAccountParamsValidator(cfg.BidderParams.AccountSchemas())
+	if err != nil {
+		return nil, err
+	}
 
-	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, cfg, met, pbsAnalytics}).AmpAuction), nil
+	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, cfg, met, pbsAnalytics, bidderInfos, accountParams, nil, blocked, ivtFilter}).AmpAuction), nil
 }
 
 func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -101,8 +109,35 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 		labels.RequestStatus = pbsmetrics.RequestStatusBadInput
 		return
 	}
+	if err := checkBlocklist(deps.blocklist, req); err != nil {
+		writeAccountError(w, err)
+		labels.RequestStatus = pbsmetrics.RequestStatusBlocked
+		ao.Status = err.status
+		ao.Errors = append(ao.Errors, err)
+		return
+	}
+	if err := fetchAccount(&deps.cfg.Accounts, accountID(req)); err != nil {
+		writeAccountError(w, err)
+		labels.RequestStatus = pbsmetrics.RequestStatusBadInput
+		ao.Status = err.status
+		ao.Errors = append(ao.Errors, err)
+		return
+	}
+	// AMP responses don't have warnings, so the debugging info is left out silently.
+	debugAllowed, _ := gateTestMode(&deps.cfg.Accounts, r, req)
+	if reason := deps.ivtFilter.Check(req); reason != "" {
+		ao.InvalidTraffic = reason
+		if deps.ivtFilter.Drops() {
+			// The AMP runtime expects targeting, so dropped requests get the response of an auction without bids.
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"targeting":{}}`))
+			labels.RequestStatus = pbsmetrics.RequestStatusInvalidTraffic
+			ao.AmpTargetingValues = map[string]string{}
+			return
+		}
+	}
 
-	ctx := context.Background()
+	ctx := tracing.Detach(r.Context())
 	cancel := func() {}
 	if req.TMax > 0 {
 		ctx, cancel = context.WithDeadline(ctx, start.Add(time.Duration(req.TMax)*time.Millisecond))
@@ -110,6 +145,10 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 		ctx, cancel = context.WithDeadline(ctx, start.Add(time.Duration(defaultAmpRequestTimeoutMillis)*time.Millisecond))
 	}
 	defer cancel()
+	if !debugAllowed {
+		ctx = exchange.WithoutDebug(ctx)
+	}
+	log := logger.FromContext(ctx).With("account", accountID(req))
 
 	usersyncs := usersync.ParsePBSCookieFromRequest(r, &(deps.cfg.HostCookie))
 	if req.App != nil {
@@ -128,68 +167,38 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "Critical error while running the auction: %v", err)
-		glog.Errorf("/openrtb2/amp Critical error: %v", err)
+		log.Errorf("/openrtb2/amp Critical error: %v", err)
 		ao.Status = http.StatusInternalServerError
 		ao.Errors = append(ao.Errors, err)
 		return
 	}
 
-	// Need to extract the targeting parameters from the response, as those are all that
-	// go in the AMP response
-	targets := map[string]string{}
-	byteCache := []byte("\"hb_cache_id")
-	for _, seatBids := range response.SeatBid {
-		for _, bid := range seatBids.Bid {
-			if bytes.Contains(bid.Ext, byteCache) {
-				// Looking for cache_id to be set, as this should only be set on winning bids (or
-				// deal bids), and AMP can only deliver cached ads in any case.
-				// Note, this could cause issues if a targeting key value starts with "hb_cache_id",
-				// but this is a very unlikely corner case. Doing this so we can catch "hb_cache_id"
-				// and "hb_cache_id_{deal}", which allows for deal support in AMP.
-				bidExt := &openrtb_ext.ExtBid{}
-				err := json.Unmarshal(bid.Ext, bidExt)
-				if err != nil {
-					w.WriteHeader(http.StatusInternalServerError)
-					fmt.Fprintf(w, "Critical error while unpacking AMP targets: %v", err)
-					glog.Errorf("/openrtb2/amp Critical error unpacking targets: %v", err)
-					ao.Errors = append(ao.Errors, fmt.Errorf("Critical error while unpacking AMP targets: %v", err))
-					ao.Status = http.StatusInternalServerError
-					return
-				}
-				for key, value := range bidExt.Prebid.Targeting {
-					targets[key] = value
-				}
-			}
-		}
-	}
-	// Now JSONify the targets for the AMP response.
-	ampResponse := AmpResponse{
-		Targeting: targets,
+	// Only the targeting keys from the response go in the AMP response.
+	builder := &ampResponseBuilder{}
+	data, err := builder.Build(req, response)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%v", err)
+		log.Errorf("/openrtb2/amp Critical error building the response: %v", err)
+		ao.Errors = append(ao.Errors, err)
+		ao.Status = http.StatusInternalServerError
+		return
 	}
+	ao.AmpTargetingValues = builder.targeting
 
-	ao.AmpTargetingValues = targets
-
-	// add debug information if requested
-	if req.Test == 1 {
+	// Requests which weren't allowed test mode don't get the debugging info, so there's nothing to check.
+	if req.Test == 1 && debugAllowed {
 		var extResponse openrtb_ext.ExtBidResponse
-		if err := json.Unmarshal(response.Ext, &extResponse); err == nil && extResponse.Debug != nil {
-			ampResponse.Debug = extResponse.Debug
-		} else {
-			glog.Errorf("Test set on request but debug not present in response: %v", err)
+		if err := json.Unmarshal(response.Ext, &extResponse); err != nil || extResponse.Debug == nil {
+			log.Errorf("Test set on request but debug not present in response: %v", err)
 			ao.Errors = append(ao.Errors, fmt.Errorf("Test set on request but debug not present in response: %v", err))
 		}
 	}
 
-	// Fixes #231
-	enc := json.NewEncoder(w)
-	enc.SetEscapeHTML(false)
-
-	// If an error happens when encoding the response, there isn't much we can do.
-	// If we've sent _any_ bytes, then Go would have sent the 200 status code first.
-	// That status code can't be un-sent... so the best we can do is log the error.
-	if err := enc.Encode(ampResponse); err != nil {
-		glog.Errorf("/openrtb2/amp Error encoding response: %v", err)
-		ao.Errors = append(ao.Errors, fmt.Errorf("/openrtb2/amp Error encoding response: %v", err))
+	w.Header().Set("Content-Type", builder.ContentType())
+	if _, err := w.Write(data); err != nil {
+		log.Errorf("/openrtb2/amp Error writing response: %v", err)
+		ao.Errors = append(ao.Errors, fmt.Errorf("/openrtb2/amp Error writing response: %v", err))
 	}
 }
 
@@ -221,6 +230,9 @@ func (deps *endpointDeps) parseAmpRequest(httpRequest *http.Request) (req *openr
 		errs = []error{err}
 		return
 	}
+	// AMP responses don't have warnings, so the bidders are removed and the currency is replaced silently.
+	deps.removeDisallowedBidders(req)
+	deps.applyAccountCurrency(req)
 	return
 }
 
@@ -238,7 +250,7 @@ func (deps *endpointDeps) loadRequestJSONForAmp(httpRequest *http.Request) (req
 	debugParam := httpRequest.FormValue("debug")
 	debug := debugParam == "1"
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
+	ctx, cancel := context.WithTimeout(tracing.Detach(httpRequest.Context()), time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
 	defer cancel()
 
 	storedRequests, _, errs := deps.storedReqFetcher.FetchRequests(ctx, []string{ampID}, nil)
@@ -280,6 +292,10 @@ func (deps *endpointDeps) loadRequestJSONForAmp(httpRequest *http.Request) (req
 	}
 
 	deps.overrideWithParams(httpRequest, req)
+	if err := mergeAmpTargeting(httpRequest, req); err != nil {
+		errs = []error{err}
+		return
+	}
 
 	return
 }
@@ -319,6 +335,97 @@ func (deps *endpointDeps) overrideWithParams(httpRequest *http.Request, req *ope
 	}
 }
 
+// mergeAmpTargeting merges the first party data from the targeting param into the request. AMP pages can't change
+// the stored request, so this is how they pass on what only the page knows. The param is a JSON object. Its "site"
+// and "user" objects are merged into site.ext.data and user.ext.data, and its "bidders" become
+// request.ext.prebid.data.bidders, which limits the bidders who get the data. Any other fields describe the page,
+// so they're merged into site.ext.data as well.
+func mergeAmpTargeting(httpRequest *http.Request, req *openrtb.BidRequest) error {
+	targetingJSON := httpRequest.FormValue("targeting")
+	if targetingJSON == "" {
+		return nil
+	}
+	var targeting map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(targetingJSON), &targeting); err != nil {
+		return fmt.Errorf("targeting must be a JSON object: %v", err)
+	}
+
+	siteData := make(map[string]json.RawMessage, len(targeting))
+	for key, value := range targeting {
+		if key != "site" && key != "user" && key != "bidders" {
+			siteData[key] = value
+		}
+	}
+	if site, ok := targeting["site"]; ok {
+		if err := json.Unmarshal(site, &siteData); err != nil {
+			return fmt.Errorf("targeting.site must be an object: %v", err)
+		}
+	}
+	if len(siteData) > 0 {
+		if req.Site == nil {
+			req.Site = &openrtb.Site{}
+		}
+		ext, err := mergeExtData(req.Site.Ext, siteData)
+		if err != nil {
+			return fmt.Errorf("targeting couldn't be merged into site.ext.data: %v", err)
+		}
+		req.Site.Ext = ext
+	}
+
+	if user, ok := targeting["user"]; ok {
+		var userData map[string]json.RawMessage
+		if err := json.Unmarshal(user, &userData); err != nil {
+			return fmt.Errorf("targeting.user must be an object: %v", err)
+		}
+		if req.User == nil {
+			req.User = &openrtb.User{}
+		}
+		ext, err := mergeExtData(req.User.Ext, userData)
+		if err != nil {
+			return fmt.Errorf("targeting.user couldn't be merged into user.ext.data: %v", err)
+		}
+		req.User.Ext = ext
+	}
+
+	if biddersJSON, ok := targeting["bidders"]; ok {
+		var bidders []string
+		if err := json.Unmarshal(biddersJSON, &bidders); err != nil {
+			return fmt.Errorf("targeting.bidders must be an array of bidder names: %v", err)
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"prebid": map[string]interface{}{
+				"data": openrtb_ext.ExtRequestPrebidData{Bidders: bidders},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		ext, err := mergePatch(req.Ext, patch)
+		if err != nil {
+			return fmt.Errorf("targeting.bidders couldn't be merged into ext.prebid.data.bidders: %v", err)
+		}
+		req.Ext = ext
+	}
+	return nil
+}
+
+// mergeExtData merges the data into the ext's data object. Fields which are already there are overwritten.
+func mergeExtData(ext openrtb.RawJSON, data map[string]json.RawMessage) (openrtb.RawJSON, error) {
+	patch, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return nil, err
+	}
+	return mergePatch(ext, patch)
+}
+
+// mergePatch applies the JSON merge patch to the ext, which may be empty.
+func mergePatch(ext openrtb.RawJSON, patch []byte) (openrtb.RawJSON, error) {
+	if len(ext) == 0 {
+		ext = openrtb.RawJSON("{}")
+	}
+	return jsonpatch.MergePatch(ext, patch)
+}
+
 func makeFormatReplacement(overrideWidth uint64, overrideHeight uint64, width uint64, height uint64, multisize string) []openrtb.Format {
 	if overrideWidth != 0 && overrideHeight != 0 {
 		return []openrtb.Format{{
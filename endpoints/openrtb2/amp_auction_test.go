@@ -6,15 +6,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strconv"
 	"testing"
 
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/analytics"
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/ivt"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/rcrowley/go-metrics"
@@ -40,7 +43,7 @@ func TestGoodAmpRequests(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{goodRequests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{goodRequests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 
 	for requestID := range goodRequests {
 		request := httptest.NewRequest("GET", fmt.Sprintf("/openrtb2/auction/amp?tag_id=%s", requestID), nil)
@@ -82,7 +85,7 @@ func TestAmpBadRequests(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{badRequests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{badRequests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	for requestID := range badRequests {
 		request := httptest.NewRequest("GET", fmt.Sprintf("/openrtb2/auction/amp?tag_id=%s", requestID), nil)
 		recorder := httptest.NewRecorder()
@@ -103,7 +106,7 @@ func TestAmpDebug(t *testing.T) {
 	}
 
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 
 	for requestID := range requests {
 		request := httptest.NewRequest("GET", fmt.Sprintf("/openrtb2/auction/amp?tag_id=%s&debug=1", requestID), nil)
@@ -134,6 +137,57 @@ func TestAmpDebug(t *testing.T) {
 	}
 }
 
+// TestAmpDebugDenied makes sure that requests which weren't allowed test mode aren't logged as missing the debug info
+func TestAmpDebugDenied(t *testing.T) {
+	requests := map[string]json.RawMessage{
+		"1": json.RawMessage(validRequest(t, "site.json")),
+	}
+	cfg := &config.Configuration{MaxRequestSize: maxSize, Accounts: config.Accounts{Debug: config.DebugDeny}}
+	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	logged := &ampObjectLogger{}
+	endpoint, _ := NewAmpEndpoint(&noDebugAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, cfg, theMetrics, logged, nil, nil, nil)
+
+	request := httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1&debug=1", nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d. Got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if logged.ao == nil || len(logged.ao.Errors) != 0 {
+		t.Errorf("Leaving out the debug info which wasn't allowed isn't an error. Got %v", logged.ao)
+	}
+}
+
+func TestAmpInvalidTraffic(t *testing.T) {
+	requests := map[string]json.RawMessage{
+		"1": json.RawMessage(validRequest(t, "site.json")),
+	}
+	filter, err := ivt.NewFilter(&config.InvalidTraffic{Enabled: true, Action: config.InvalidTrafficDrop, UserAgents: []string{"bot"}})
+	if err != nil {
+		t.Fatalf("Failed to make the filter: %v", err)
+	}
+	ex := &mockAmpExchange{}
+	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	endpoint, _ := NewAmpEndpoint(ex, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, filter)
+
+	request := httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1", nil)
+	request.Header.Set("User-Agent", "Googlebot/2.1")
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d. Got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var response AmpResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshalling response: %s", err.Error())
+	}
+	if response.Targeting == nil || len(response.Targeting) != 0 || ex.lastRequest != nil {
+		t.Errorf("Dropped requests should get empty targeting without an auction. Got %s", recorder.Body)
+	}
+}
+
 // Prevents #452
 func TestAmpTargetingDefaults(t *testing.T) {
 	req := &openrtb.BidRequest{}
@@ -161,7 +215,7 @@ func TestQueryParamOverrides(t *testing.T) {
 		"1": json.RawMessage(validRequest(t, "site.json")),
 	}
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 
 	requestID := "1"
 	curl := "http://example.com"
@@ -198,6 +252,98 @@ func TestQueryParamOverrides(t *testing.T) {
 	}
 }
 
+// TestAmpTargetingParam makes sure that the first party data in the targeting param is merged into the request.
+func TestAmpTargetingParam(t *testing.T) {
+	requests := map[string]json.RawMessage{
+		"1": json.RawMessage(validRequest(t, "site.json")),
+	}
+	ex := &mockAmpExchange{}
+	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	endpoint, _ := NewAmpEndpoint(ex, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+
+	targeting := `{"section":"sports","site":{"keywords":"football"},"user":{"interests":["cycling"]},"bidders":["appnexus"]}`
+	request := httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1&targeting="+url.QueryEscape(targeting), nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status %d. Got %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var siteExt struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(ex.lastRequest.Site.Ext, &siteExt); err != nil {
+		t.Fatalf("Bad site.ext: %v", err)
+	}
+	if siteExt.Data["section"] != "sports" || siteExt.Data["keywords"] != "football" {
+		t.Errorf("The page's data should be merged into site.ext.data. Got %s", ex.lastRequest.Site.Ext)
+	}
+	var userExt struct {
+		Data struct {
+			Interests []string `json:"interests"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(ex.lastRequest.User.Ext, &userExt); err != nil {
+		t.Fatalf("Bad user.ext: %v", err)
+	}
+	if !reflect.DeepEqual(userExt.Data.Interests, []string{"cycling"}) {
+		t.Errorf("targeting.user should be merged into user.ext.data. Got %s", ex.lastRequest.User.Ext)
+	}
+	var requestExt openrtb_ext.ExtRequest
+	if err := json.Unmarshal(ex.lastRequest.Ext, &requestExt); err != nil {
+		t.Fatalf("Bad request.ext: %v", err)
+	}
+	if requestExt.Prebid.Data == nil || !reflect.DeepEqual(requestExt.Prebid.Data.Bidders, []string{"appnexus"}) {
+		t.Errorf("targeting.bidders should become ext.prebid.data.bidders. Got %s", ex.lastRequest.Ext)
+	}
+	if requestExt.Prebid.Targeting == nil || requestExt.Prebid.Cache == nil {
+		t.Errorf("The request should still have the AMP defaults. Got %s", ex.lastRequest.Ext)
+	}
+
+	request = httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1&targeting="+url.QueryEscape(`{"bidders":"appnexus"}`), nil)
+	recorder = httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Bad targeting should be rejected. Got status %d", recorder.Code)
+	}
+}
+
+func TestAmpResponseBuilder(t *testing.T) {
+	response := &openrtb.BidResponse{
+		SeatBid: []openrtb.SeatBid{{
+			Bid: []openrtb.Bid{
+				{ID: "winner", Ext: openrtb.RawJSON(`{"prebid":{"targeting":{"hb_pb":"1.20","hb_cache_id":"abc"}}}`)},
+				{ID: "loser", Ext: openrtb.RawJSON(`{"prebid":{"targeting":{"hb_pb_appnexus":"0.50"}}}`)},
+			},
+		}},
+		Ext: openrtb.RawJSON(`{"debug":{"resolvedrequest":{"id":"req-1"}}}`),
+	}
+	builder := &ampResponseBuilder{}
+	data, err := builder.Build(&openrtb.BidRequest{Test: 1}, response)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var ampResponse AmpResponse
+	if err := json.Unmarshal(data, &ampResponse); err != nil {
+		t.Fatalf("Failed to unmarshal the response: %v", err)
+	}
+	expected := map[string]string{"hb_pb": "1.20", "hb_cache_id": "abc"}
+	if !reflect.DeepEqual(ampResponse.Targeting, expected) || !reflect.DeepEqual(builder.targeting, expected) {
+		t.Errorf("Only the cached bids' targeting should be sent. Got %v", ampResponse.Targeting)
+	}
+	if ampResponse.Debug == nil {
+		t.Errorf("Test requests should get the debug info.")
+	}
+	if builder.ContentType() != "application/json" {
+		t.Errorf("AMP responses should be JSON. Got %s", builder.ContentType())
+	}
+
+	response.SeatBid[0].Bid[0].Ext = openrtb.RawJSON(`{"prebid":{"targeting":"hb_cache_id"}}`)
+	if _, err := builder.Build(&openrtb.BidRequest{}, response); err == nil {
+		t.Errorf("Bids with a bad ext should be an error.")
+	}
+}
+
 func TestOverrideDimensions(t *testing.T) {
 	formatOverrideSpec{
 		overrideWidth:  20,
@@ -278,7 +424,7 @@ func (s formatOverrideSpec) execute(t *testing.T) {
 		"1": json.RawMessage(validRequest(t, "site.json")),
 	}
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewAmpEndpoint(&mockAmpExchange{}, newParamsValidator(t), &mockAmpStoredReqFetcher{requests}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 
 	url := fmt.Sprintf("/openrtb2/auction/amp?tag_id=1&debug=1&w=%d&h=%d&ow=%d&oh=%d&ms=%s", s.width, s.height, s.overrideWidth, s.overrideHeight, s.multisize)
 	request := httptest.NewRequest("GET", url, nil)
@@ -342,3 +488,27 @@ func (m *mockAmpExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.B
 
 	return response, nil
 }
+
+// noDebugAmpExchange bids like mockAmpExchange, but leaves out the debug info, as the exchange does when
+// test mode wasn't allowed.
+type noDebugAmpExchange struct {
+	mockAmpExchange
+}
+
+func (m *noDebugAmpExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, ids exchange.IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	response, err := m.mockAmpExchange.HoldAuction(ctx, bidRequest, ids, labels)
+	response.Ext = nil
+	return response, err
+}
+
+// ampObjectLogger keeps the last AmpObject it was given.
+type ampObjectLogger struct {
+	ao *analytics.AmpObject
+}
+
+func (l *ampObjectLogger) LogAuctionObject(*analytics.AuctionObject)       {}
+func (l *ampObjectLogger) LogCookieSyncObject(*analytics.CookieSyncObject) {}
+func (l *ampObjectLogger) LogSetUIDObject(*analytics.SetUIDObject)         {}
+func (l *ampObjectLogger) LogAmpObject(ao *analytics.AmpObject) {
+	l.ao = ao
+}
@@ -9,35 +9,52 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/buger/jsonparser"
 	"github.com/evanphx/json-patch"
-	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
 	"github.com/mssola/user_agent"
 	"github.com/mxmCherry/openrtb"
 	nativeRequests "github.com/mxmCherry/openrtb/native/request"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/appstore"
+	"github.com/prebid/prebid-server/blocklist"
+	"github.com/prebid/prebid-server/clienthints"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/ivt"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/prebid"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync"
+	"github.com/prebid/prebid-server/util/uuidutil"
 	"golang.org/x/net/publicsuffix"
 )
 
 const storedRequestTimeoutMillis = 50
 
-func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule) (httprouter.Handle, error) {
+func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, bidderInfos adapters.BidderInfos, blocked *blocklist.List, ivtFilter *ivt.Filter) (httprouter.Handle, error) {
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
 		return nil, errors.New("NewEndpoint requires non-nil arguments.")
 	}
+	accountParams, err := openrtb_ext.NewAccountParamsValidator(cfg.BidderParams.AccountSchemas())
+	if err != nil {
+		return nil, err
+	}
+
+	appEnricher := appstore.NewEnricher(&cfg.AppEnrichment, http.DefaultClient)
 
-	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, cfg, met, pbsAnalytics}).Auction), nil
+	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, cfg, met, pbsAnalytics, bidderInfos, accountParams, appEnricher, blocked, ivtFilter}).Auction), nil
 }
 
 type endpointDeps struct {
@@ -47,9 +64,28 @@ type endpointDeps struct {
 	cfg              *config.Configuration
 	metricsEngine    pbsmetrics.MetricsEngine
 	analytics        analytics.PBSAnalyticsModule
+	bidderInfos      adapters.BidderInfos
+	// accountParams are the stricter bidder params schemas which some accounts use. It may be nil.
+	accountParams *openrtb_ext.AccountParamsValidator
+	// appEnricher fills in the app details which requests leave out. It's nil if app enrichment is off.
+	appEnricher *appstore.Enricher
+	// blocklist rejects the requests from banned sources. It's nil if the blocklist is off.
+	blocklist *blocklist.List
+	// ivtFilter flags or drops the requests which look like bot traffic. It's nil if the filter is off.
+	ivtFilter *ivt.Filter
 }
 
 func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var builder responseBuilder = jsonResponseBuilder{}
+	if wantsProtobufResponse(r) {
+		builder = protobufResponseBuilder{}
+	}
+	deps.runAuction(w, r, "/openrtb2/auction", builder)
+}
+
+// runAuction handles an OpenRTB request, and writes the response with the builder. The endpoint is only used
+// in the logs.
+func (deps *endpointDeps) runAuction(w http.ResponseWriter, r *http.Request, endpoint string, builder responseBuilder) {
 
 	ao := analytics.AuctionObject{
 		Status: http.StatusOK,
@@ -84,7 +120,7 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 		labels.Browser = pbsmetrics.BrowserSafari
 	}
 
-	req, errL := deps.parseRequest(r)
+	req, prebidWarnings, errL := deps.parseRequest(r)
 
 	if writeError(errL, w) {
 		labels.RequestStatus = pbsmetrics.RequestStatusBadInput
@@ -100,14 +136,50 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 			labels.PubID = req.App.Publisher.ID
 		}
 	}
+	if err := checkBlocklist(deps.blocklist, req); err != nil {
+		writeAccountError(w, err)
+		labels.RequestStatus = pbsmetrics.RequestStatusBlocked
+		ao.Status = err.status
+		ao.Errors = append(ao.Errors, err)
+		return
+	}
+	if err := fetchAccount(&deps.cfg.Accounts, accountID(req)); err != nil {
+		writeAccountError(w, err)
+		labels.RequestStatus = pbsmetrics.RequestStatusBadInput
+		ao.Status = err.status
+		ao.Errors = append(ao.Errors, err)
+		return
+	}
+	debugAllowed, warning := gateTestMode(&deps.cfg.Accounts, r, req)
+	if warning != "" {
+		prebidWarnings = append(prebidWarnings, warning)
+	}
+	if reason := deps.ivtFilter.Check(req); reason != "" {
+		ao.InvalidTraffic = reason
+		if deps.ivtFilter.Drops() {
+			// Bots can't tell a dropped request from an auction without bids, so they get the same response.
+			w.WriteHeader(http.StatusNoContent)
+			labels.RequestStatus = pbsmetrics.RequestStatusInvalidTraffic
+			ao.Request = req
+			ao.Status = http.StatusNoContent
+			return
+		}
+	}
 
-	ctx := context.Background()
+	ctx := tracing.Detach(r.Context())
 	cancel := func() {}
 	timeout := deps.cfg.AuctionTimeouts.LimitAuctionTimeout(time.Duration(req.TMax) * time.Millisecond)
+	if req.TMax > 0 && timeout < time.Duration(req.TMax)*time.Millisecond {
+		prebidWarnings = append(prebidWarnings, fmt.Sprintf("request.tmax of %d ms is longer than this host allows. The auction was limited to %d ms.", req.TMax, timeout/time.Millisecond))
+	}
 	if timeout > 0 {
 		ctx, cancel = context.WithDeadline(ctx, start.Add(timeout))
 	}
 	defer cancel()
+	if !debugAllowed {
+		ctx = exchange.WithoutDebug(ctx)
+	}
+	log := logger.FromContext(ctx).With("account", accountID(req))
 
 	usersyncs := usersync.ParsePBSCookieFromRequest(r, &(deps.cfg.HostCookie))
 	if req.App != nil {
@@ -129,25 +201,45 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 		labels.RequestStatus = pbsmetrics.RequestStatusErr
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "Critical error while running the auction: %v", err)
-		glog.Errorf("/openrtb2/auction Critical error: %v", err)
+		log.Errorf("%s Critical error: %v", endpoint, err)
 		ao.Status = http.StatusInternalServerError
 		ao.Errors = append(ao.Errors, err)
 		return
 	}
+	if minBids := deps.cfg.NoBids.MinBidsFor(accountID(req)); minBids > 0 && countBids(response) < minBids {
+		w.WriteHeader(http.StatusNoContent)
+		ao.Status = http.StatusNoContent
+		return
+	}
+	warnings := deps.deprecationWarnings(req)
+	if len(prebidWarnings) > 0 {
+		if warnings == nil {
+			warnings = make(map[openrtb_ext.BidderName][]string, 1)
+		}
+		warnings[openrtb_ext.WarningsPrebid] = prebidWarnings
+	}
+	if len(warnings) > 0 {
+		if err := addWarnings(response, warnings); err != nil {
+			log.Errorf("%s Error adding warnings to the response: %v", endpoint, err)
+		}
+	}
 
-	// Fixes #231
-	enc := json.NewEncoder(w)
-	enc.SetEscapeHTML(false)
+	data, err := builder.Build(req, response)
+	if err != nil {
+		labels.RequestStatus = pbsmetrics.RequestStatusErr
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error encoding the response: %v", err)
+		log.Errorf("%s Error encoding response: %v", endpoint, err)
+		ao.Status = http.StatusInternalServerError
+		ao.Errors = append(ao.Errors, fmt.Errorf("%s Error encoding response: %v", endpoint, err))
+		return
+	}
 
 	// Fixes #328
-	w.Header().Set("Content-Type", "application/json")
-
-	// If an error happens when encoding the response, there isn't much we can do.
-	// If we've sent _any_ bytes, then Go would have sent the 200 status code first.
-	// That status code can't be un-sent... so the best we can do is log the error.
-	if err := enc.Encode(response); err != nil {
-		glog.Errorf("/openrtb2/auction Error encoding response: %v", err)
-		ao.Errors = append(ao.Errors, fmt.Errorf("/openrtb2/auction Error encoding response: %v", err))
+	w.Header().Set("Content-Type", builder.ContentType())
+	if _, err := w.Write(data); err != nil {
+		log.Errorf("%s Error writing response: %v", endpoint, err)
+		ao.Errors = append(ao.Errors, fmt.Errorf("%s Error writing response: %v", endpoint, err))
 	}
 }
 
@@ -156,12 +248,14 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 //   - A context which times out appropriately, given the request.
 //   - A cancellation function which should be called if the auction finishes early.
 //
-// If the errors list is empty, then the returned request will be valid according to the OpenRTB 2.5 spec.
+// The warnings describe any changes made to the request which the caller should know about.
+//
+// If the errors list is empty, then the returned request will be valid according to the OpenRTB 2.6 spec.
 // In case of "strong recommendations" in the spec, it tends to be restrictive. If a better workaround is
 // possible, it will return errors with messages that suggest improvements.
 //
 // If the errors list has at least one element, then no guarantees are made about the returned request.
-func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.BidRequest, errs []error) {
+func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.BidRequest, warnings []string, errs []error) {
 	req = &openrtb.BidRequest{}
 	errs = nil
 
@@ -182,9 +276,15 @@ func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.
 			return
 		}
 	}
+	if isProtobufRequest(httpRequest) {
+		if requestJson, err = protobufToJSON(requestJson); err != nil {
+			errs = []error{err}
+			return
+		}
+	}
 
 	timeout := parseTimeout(requestJson, time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(tracing.Detach(httpRequest.Context()), timeout)
 	defer cancel()
 
 	// Fetch the Stored Request data and merge it into the HTTP request.
@@ -197,17 +297,123 @@ func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.
 		return
 	}
 
+	// Check the values against OpenRTB 2.6. Lenient accounts get the request without the bad values, rather than an error.
+	strict := deps.cfg.RequestValidation.StrictFor(accountID(req))
+	if cleanedJson, problems, err := validateORTBFields(requestJson); err != nil {
+		errs = []error{err}
+		return
+	} else if len(problems) > 0 {
+		if strict {
+			for _, problem := range problems {
+				errs = append(errs, errors.New(problem))
+			}
+			return
+		}
+		for _, problem := range problems {
+			warnings = append(warnings, fmt.Sprintf("%s. The value was removed.", problem))
+		}
+		requestJson = cleanedJson
+		*req = openrtb.BidRequest{}
+		if err := json.Unmarshal(requestJson, req); err != nil {
+			errs = []error{err}
+			return
+		}
+	}
+
+	// Our OpenRTB structs don't have the 2.6 fields which used to be in ext, so move them back there rather than lose them.
+	// They're moved out again for the bidders which expect 2.6.
+	if downgradedJson, downgraded, err := adapters.DowngradeRequestTo25(requestJson); err != nil {
+		errs = []error{err}
+		return
+	} else if downgraded {
+		*req = openrtb.BidRequest{}
+		if err := json.Unmarshal(downgradedJson, req); err != nil {
+			errs = []error{err}
+			return
+		}
+	}
+
 	// Populate any "missing" OpenRTB fields with info from other sources, (e.g. HTTP request headers).
 	deps.setFieldsImplicitly(httpRequest, req)
 
+	// Drop any extra Imps before validating, so that they can't make the request invalid.
+	if warning, err := deps.limitImps(req); err != nil {
+		errs = []error{err}
+		return
+	} else if warning != "" {
+		warnings = append(warnings, warning)
+	}
+
 	if err := deps.validateRequest(req); err != nil {
 		errs = []error{err}
 		return
 	}
+	for _, field := range unknownPrebidFields(req.Ext) {
+		if strict {
+			errs = append(errs, fmt.Errorf("request.ext.prebid.%s is not supported.", field))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("request.ext.prebid.%s is not supported, so it was ignored.", field))
+		}
+	}
+	warnings = append(warnings, deps.removeDisallowedBidders(req)...)
+	if warning := deps.applyAccountCurrency(req); warning != "" {
+		warnings = append(warnings, warning)
+	}
 
 	return
 }
 
+// knownPrebidFields are the JSON names of the fields in request.ext.prebid.
+var knownPrebidFields = jsonFieldNames(reflect.TypeOf(openrtb_ext.ExtRequestPrebid{}))
+
+// unknownPrebidFields returns the name of each field in request.ext.prebid which Prebid Server doesn't use.
+// These are usually typos, or options from other versions of Prebid Server.
+func unknownPrebidFields(requestExt openrtb.RawJSON) []string {
+	var ext struct {
+		Prebid map[string]json.RawMessage `json:"prebid"`
+	}
+	if len(requestExt) == 0 || json.Unmarshal(requestExt, &ext) != nil {
+		return nil
+	}
+	var unknown []string
+	for field := range ext.Prebid {
+		if _, ok := knownPrebidFields[field]; !ok {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// jsonFieldNames returns the names which the struct's fields use in JSON.
+func jsonFieldNames(structType reflect.Type) map[string]struct{} {
+	names := make(map[string]struct{}, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		name := strings.Split(structType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = structType.Field(i).Name
+		}
+		if name != "-" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// limitImps enforces the host's imp_limits. If the extra Imps are truncated, it returns a warning for the caller.
+func (deps *endpointDeps) limitImps(req *openrtb.BidRequest) (string, error) {
+	maxImps := deps.cfg.ImpLimits.Max
+	if maxImps <= 0 || len(req.Imp) <= maxImps {
+		return "", nil
+	}
+	if deps.cfg.ImpLimits.Action == config.ImpLimitTruncate {
+		warning := fmt.Sprintf("request.imp contained %d elements, but only the first %d were auctioned.", len(req.Imp), maxImps)
+		req.Imp = req.Imp[:maxImps]
+		return warning, nil
+	}
+	return "", fmt.Errorf("request.imp must contain at most %d elements. Got %d", maxImps, len(req.Imp))
+}
+
 // parseTimeout returns parses tmax from the requestJson, or returns the default if it doesn't exist.
 //
 // requestJson should be the content of the POST body.
@@ -252,7 +458,7 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) error {
 	}
 
 	for index, imp := range req.Imp {
-		if err := deps.validateImp(&imp, aliases, index); err != nil {
+		if err := deps.validateImp(&imp, aliases, accountID(req), index); err != nil {
 			return err
 		}
 	}
@@ -290,7 +496,7 @@ func validateBidAdjustmentFactors(adjustmentFactors map[string]float64, aliases
 	return nil
 }
 
-func (deps *endpointDeps) validateImp(imp *openrtb.Imp, aliases map[string]string, index int) error {
+func (deps *endpointDeps) validateImp(imp *openrtb.Imp, aliases map[string]string, account string, index int) error {
 	if imp.ID == "" {
 		return fmt.Errorf("request.imp[%d] missing required field: \"id\"", index)
 	}
@@ -327,7 +533,7 @@ func (deps *endpointDeps) validateImp(imp *openrtb.Imp, aliases map[string]strin
 		return err
 	}
 
-	if err := deps.validateImpExt(imp.Ext, aliases, index); err != nil {
+	if err := deps.validateImpExt(imp.Ext, aliases, account, index); err != nil {
 		return err
 	}
 
@@ -566,32 +772,105 @@ func validatePmp(pmp *openrtb.PMP, impIndex int) error {
 	return nil
 }
 
-func (deps *endpointDeps) validateImpExt(ext openrtb.RawJSON, aliases map[string]string, impIndex int) error {
-	var bidderExts map[string]openrtb.RawJSON
-	if err := json.Unmarshal(ext, &bidderExts); err != nil {
+// validateImpExt validates the imp's bidder params, which can be in imp.ext.prebid.bidder.{bidder} or imp.ext.{bidder}.
+// The errors name the bidders' params as imp.ext.{bidder} in both cases.
+func (deps *endpointDeps) validateImpExt(ext openrtb.RawJSON, aliases map[string]string, account string, impIndex int) error {
+	var impExt map[string]openrtb.RawJSON
+	if err := json.Unmarshal(ext, &impExt); err != nil {
 		return err
 	}
 
+	if tid, ok := impExt["tid"]; ok {
+		var tidString string
+		if err := json.Unmarshal(tid, &tidString); err != nil {
+			return fmt.Errorf("request.imp[%d].ext.tid must be a string", impIndex)
+		}
+	}
+
+	bidderExts, err := openrtb_ext.ImpBidderParams(json.RawMessage(ext))
+	if err != nil {
+		return fmt.Errorf("request.imp[%d].ext.prebid is invalid: %v", impIndex, err)
+	}
 	if len(bidderExts) < 1 {
 		return fmt.Errorf("request.imp[%d].ext must contain at least one bidder", impIndex)
 	}
 
 	for bidder, ext := range bidderExts {
-		if bidder != "prebid" {
+		coreBidder := bidder
+		if tmp, isAlias := aliases[bidder]; isAlias {
+			coreBidder = tmp
+		}
+		if bidderName, isValid := openrtb_ext.BidderMap[coreBidder]; isValid {
+			if deprecation := deps.bidderInfos.Deprecation(bidderName); deprecation != nil && deprecation.Removed {
+				return fmt.Errorf("request.imp[%d].ext.%s can't be used. %s", impIndex, bidder, deprecation.Message(bidderName))
+			}
+			if err := deps.paramsValidator.Validate(bidderName, openrtb.RawJSON(ext)); err != nil {
+				return fmt.Errorf("request.imp[%d].ext.%s failed validation.\n%v", impIndex, coreBidder, err)
+			}
+			if err := deps.accountParams.Validate(account, bidderName, openrtb.RawJSON(ext)); err != nil {
+				return fmt.Errorf("request.imp[%d].ext.%s isn't allowed for account %s.\n%v", impIndex, coreBidder, account, err)
+			}
+		} else {
+			return fmt.Errorf("request.imp[%d].ext contains unknown bidder: %s. Did you forget an alias in request.ext.prebid.aliases?", impIndex, bidder)
+		}
+	}
+
+	return nil
+}
+
+// deprecationWarnings returns a warning for each deprecated bidder which the request uses, by the name it used for it.
+func (deps *endpointDeps) deprecationWarnings(req *openrtb.BidRequest) map[openrtb_ext.BidderName][]string {
+	if len(deps.bidderInfos) == 0 {
+		return nil
+	}
+	var aliases map[string]string
+	if requestExt, err := deps.parseBidExt(req.Ext); err == nil && requestExt != nil {
+		aliases = requestExt.Prebid.Aliases
+	}
+
+	warnings := make(map[openrtb_ext.BidderName][]string)
+	for _, imp := range req.Imp {
+		bidderExts, err := openrtb_ext.ImpBidderParams(json.RawMessage(imp.Ext))
+		if err != nil {
+			continue
+		}
+		for bidder := range bidderExts {
 			coreBidder := bidder
 			if tmp, isAlias := aliases[bidder]; isAlias {
 				coreBidder = tmp
 			}
-			if bidderName, isValid := openrtb_ext.BidderMap[coreBidder]; isValid {
-				if err := deps.paramsValidator.Validate(bidderName, ext); err != nil {
-					return fmt.Errorf("request.imp[%d].ext.%s failed validation.\n%v", impIndex, coreBidder, err)
-				}
-			} else {
-				return fmt.Errorf("request.imp[%d].ext contains unknown bidder: %s. Did you forget an alias in request.ext.prebid.aliases?", impIndex, bidder)
+			if deprecation := deps.bidderInfos.Deprecation(openrtb_ext.BidderName(coreBidder)); deprecation != nil {
+				warnings[openrtb_ext.BidderName(bidder)] = []string{deprecation.Message(openrtb_ext.BidderName(coreBidder))}
 			}
 		}
 	}
+	return warnings
+}
 
+// addWarnings merges the warnings into response.ext.warnings, after any which the exchange already added.
+func addWarnings(response *openrtb.BidResponse, warnings map[openrtb_ext.BidderName][]string) error {
+	var existing openrtb_ext.ExtBidResponse
+	if len(response.Ext) > 0 {
+		if err := json.Unmarshal(response.Ext, &existing); err != nil {
+			return err
+		}
+	}
+	for key, existingWarnings := range existing.Warnings {
+		warnings[key] = append(existingWarnings, warnings[key]...)
+	}
+	patch, err := json.Marshal(openrtb_ext.ExtBidResponse{Warnings: warnings})
+	if err != nil {
+		return err
+	}
+	if len(response.Ext) == 0 {
+		response.Ext = patch
+		return nil
+	}
+	merged, err := jsonpatch.MergePatch(response.Ext, patch)
+	if err != nil {
+		return err
+	}
+	response.Ext = merged
 	return nil
 }
 
@@ -678,15 +957,34 @@ func validateRegs(regs *openrtb.Regs) error {
 // This function _should not_ override any fields which were defined explicitly by the caller in the request.
 func (deps *endpointDeps) setFieldsImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest) {
 	setDeviceImplicitly(httpReq, bidReq)
+	// The account's activity rules and the request's privacy policies decide which of the enrichers below
+	// may add data about the user.
+	control := privacy.NewActivityControl(&deps.cfg.Activities, accountID(bidReq)).WithPolicies(
+		privacy.NewCOPPAPolicy(bidReq),
+		privacy.NewLMTPolicy(&deps.cfg.LMT, bidReq),
+	)
+	if control.Allow(privacy.ActivityEnrichUFPD, privacy.Component{Name: clienthints.ComponentName}) {
+		setSUAImplicitly(httpReq, bidReq, deps.cfg.ClientHints.FromUserAgent)
+	}
 
 	// Per the OpenRTB spec: A bid request must not contain both a Site and an App object.
 	if bidReq.App == nil {
 		setSiteImplicitly(httpReq, bidReq)
+	} else {
+		deps.appEnricher.Enrich(bidReq.App)
 	}
 	setImpsImplicitly(httpReq, bidReq.Imp)
 
 	deps.setUserImplicitly(httpReq, bidReq)
 	setAuctionTypeImplicitly(bidReq)
+	migrateImpExts(bidReq.Imp)
+	setTIDsImplicitly(bidReq)
+	if deps.cfg.CookieDeprecation.EnabledFor(accountID(bidReq)) {
+		setCookieDeprecationImplicitly(httpReq, bidReq)
+	}
+	if deps.cfg.Topics.EnabledFor(accountID(bidReq)) && control.Allow(privacy.ActivityEnrichUFPD, privacy.Component{Name: privacy.TopicsComponentName}) {
+		setTopicsImplicitly(httpReq, bidReq, &deps.cfg.Topics)
+	}
 }
 
 // setDeviceImplicitly uses implicit info from httpReq to populate bidReq.Device
@@ -695,6 +993,20 @@ func setDeviceImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest) {
 	setUAImplicitly(httpReq, bidReq)
 }
 
+// migrateImpExts moves the bidder params in each imp.ext.{bidder} into imp.ext.prebid.bidder, where newer pages
+// put them, so that the rest of the auction handles both formats the same way. Exts which can't be parsed are left
+// for validation to reject.
+func migrateImpExts(imps []openrtb.Imp) {
+	for i := range imps {
+		if len(imps[i].Ext) == 0 {
+			continue
+		}
+		if migrated, err := openrtb_ext.MigrateImpExt(json.RawMessage(imps[i].Ext)); err == nil {
+			imps[i].Ext = openrtb.RawJSON(migrated)
+		}
+	}
+}
+
 // setAuctionTypeImplicitly sets the auction type to 1 if it wasn't on the request,
 // since header bidding is generally a first-price auction.
 func setAuctionTypeImplicitly(bidReq *openrtb.BidRequest) {
@@ -727,6 +1039,161 @@ func setSiteImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest) {
 	}
 }
 
+// maxCookieDeprecationLabelLength limits how much of the Sec-Cookie-Deprecation header is copied into the request.
+const maxCookieDeprecationLabelLength = 100
+
+// setCookieDeprecationImplicitly copies Chrome's cookie deprecation label from the Sec-Cookie-Deprecation header
+// into device.ext.cdep, unless the request already has one.
+func setCookieDeprecationImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest) {
+	label := httpReq.Header.Get("Sec-Cookie-Deprecation")
+	if label == "" {
+		return
+	}
+	if len(label) > maxCookieDeprecationLabelLength {
+		label = label[:maxCookieDeprecationLabelLength]
+	}
+	if bidReq.Device == nil {
+		bidReq.Device = &openrtb.Device{}
+	}
+	ext := bidReq.Device.Ext
+	if len(ext) == 0 {
+		ext = openrtb.RawJSON(`{}`)
+	}
+	if _, dataType, _, _ := jsonparser.Get(ext, "cdep"); dataType != jsonparser.NotExist {
+		return
+	}
+	labelJSON, err := json.Marshal(label)
+	if err != nil {
+		return
+	}
+	if ext, err := jsonparser.Set(ext, labelJSON, "cdep"); err == nil {
+		bidReq.Device.Ext = ext
+	}
+}
+
+// setSUAImplicitly adds OpenRTB 2.6's structured user agent to device.ext.sua, unless the request already has one.
+// It's built from the Sec-CH-UA headers if device.ua is the User-Agent of the browser which sent them. Otherwise,
+// or if the browser didn't send any, it's parsed from device.ua if fromUA is true.
+func setSUAImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest, fromUA bool) {
+	if bidReq.Device == nil {
+		return
+	}
+	ext := bidReq.Device.Ext
+	if len(ext) == 0 {
+		ext = openrtb.RawJSON(`{}`)
+	}
+	if _, dataType, _, _ := jsonparser.Get(ext, "sua"); dataType != jsonparser.NotExist {
+		return
+	}
+	var sua *openrtb_ext.UserAgent
+	if bidReq.Device.UA != "" && bidReq.Device.UA == httpReq.UserAgent() {
+		sua = clienthints.Parse(httpReq.Header)
+	}
+	if sua == nil && fromUA {
+		sua = clienthints.FromUserAgent(bidReq.Device.UA)
+	}
+	if sua == nil {
+		return
+	}
+	suaJSON, err := json.Marshal(sua)
+	if err != nil {
+		return
+	}
+	if ext, err := jsonparser.Set(ext, suaJSON, "sua"); err == nil {
+		bidReq.Device.Ext = ext
+	}
+}
+
+// setTopicsImplicitly adds the Privacy Sandbox topics from the Sec-Browsing-Topics header to user.data.
+// Only sites get topics, since apps don't send the header. Topics which the request already has
+// (with the same provider, taxonomy and classifier model) are left alone.
+func setTopicsImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest, cfg *config.Topics) {
+	if bidReq.Site == nil || !cfg.DomainAllowed(bidReq.Site.Domain) {
+		return
+	}
+	topics := privacy.ParseTopicsHeader(httpReq.Header.Get("Sec-Browsing-Topics"))
+	if len(topics) == 0 {
+		return
+	}
+	if bidReq.User == nil {
+		bidReq.User = &openrtb.User{}
+	}
+	for _, thisTopics := range topics {
+		if hasTopicsData(bidReq.User.Data, cfg.Provider, thisTopics.SegTax, thisTopics.SegClass) {
+			continue
+		}
+		segments := make([]openrtb.Segment, len(thisTopics.SegIDs))
+		for i, id := range thisTopics.SegIDs {
+			segments[i] = openrtb.Segment{ID: strconv.Itoa(id)}
+		}
+		ext, err := json.Marshal(openrtb_ext.ExtData{SegTax: thisTopics.SegTax, SegClass: thisTopics.SegClass})
+		if err != nil {
+			continue
+		}
+		bidReq.User.Data = append(bidReq.User.Data, openrtb.Data{
+			Name:    cfg.Provider,
+			Segment: segments,
+			Ext:     ext,
+		})
+	}
+}
+
+func hasTopicsData(data []openrtb.Data, provider string, segTax int, segClass string) bool {
+	for _, thisData := range data {
+		if thisData.Name != provider {
+			continue
+		}
+		var ext openrtb_ext.ExtData
+		if err := json.Unmarshal(thisData.Ext, &ext); err == nil && ext.SegTax == segTax && ext.SegClass == segClass {
+			return true
+		}
+	}
+	return false
+}
+
+// accountID returns the ID of the account which sent the request, which is the publisher ID.
+func accountID(bidReq *openrtb.BidRequest) string {
+	if bidReq.Site != nil && bidReq.Site.Publisher != nil {
+		return bidReq.Site.Publisher.ID
+	}
+	if bidReq.App != nil && bidReq.App.Publisher != nil {
+		return bidReq.App.Publisher.ID
+	}
+	return ""
+}
+
+// countBids returns the number of bids in the response.
+func countBids(response *openrtb.BidResponse) int {
+	count := 0
+	for _, seatBid := range response.SeatBid {
+		count += len(seatBid.Bid)
+	}
+	return count
+}
+
+// setTIDsImplicitly generates the source.tid and imp[i].ext.tid transaction IDs if they weren't on the request,
+// so that bidders can recognize the same impression when it comes to them through different routes.
+func setTIDsImplicitly(bidReq *openrtb.BidRequest) {
+	if bidReq.Source == nil {
+		bidReq.Source = &openrtb.Source{}
+	}
+	if bidReq.Source.TID == "" {
+		bidReq.Source.TID = uuidutil.NewV4()
+	}
+	for i := 0; i < len(bidReq.Imp); i++ {
+		// Imps with a missing or malformed ext are left alone, so that validation can reject them.
+		if _, dataType, _, err := jsonparser.Get(bidReq.Imp[i].Ext); err != nil || dataType != jsonparser.Object {
+			continue
+		}
+		if _, dataType, _, _ := jsonparser.Get(bidReq.Imp[i].Ext, "tid"); dataType != jsonparser.NotExist {
+			continue
+		}
+		if ext, err := jsonparser.Set(bidReq.Imp[i].Ext, []byte(`"`+uuidutil.NewV4()+`"`), "tid"); err == nil {
+			bidReq.Imp[i].Ext = ext
+		}
+	}
+}
+
 func setImpsImplicitly(httpReq *http.Request, imps []openrtb.Imp) {
 	secure := int8(1)
 	for i := 0; i < len(imps); i++ {
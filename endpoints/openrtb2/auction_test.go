@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -17,10 +18,13 @@ import (
 	"github.com/buger/jsonparser"
 	"github.com/evanphx/json-patch"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/ivt"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/openrtb_proto"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
 	"github.com/rcrowley/go-metrics"
@@ -81,7 +85,7 @@ func TestExplicitUserId(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, cfg, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, cfg, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	endpoint(httptest.NewRecorder(), request, nil)
 
 	if ex.lastRequest == nil {
@@ -117,7 +121,7 @@ func TestImplicitUserId(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, cfg, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, cfg, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	endpoint(httptest.NewRecorder(), request, nil)
 
 	if ex.lastRequest == nil {
@@ -188,7 +192,7 @@ func runFile(t *testing.T, filename string, preprocessor func(*testing.T, []byte
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewEndpoint(&nobidExchange{}, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewEndpoint(&nobidExchange{}, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	requestData := readFile(t, filename)
 
 	if preprocessor != nil {
@@ -247,7 +251,7 @@ func TestNilExchange(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	_, err := NewEndpoint(nil, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	_, err := NewEndpoint(nil, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	if err == nil {
 		t.Errorf("NewEndpoint should return an error when given a nil Exchange.")
 	}
@@ -258,7 +262,7 @@ func TestNilValidator(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	_, err := NewEndpoint(&nobidExchange{}, nil, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	_, err := NewEndpoint(&nobidExchange{}, nil, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	if err == nil {
 		t.Errorf("NewEndpoint should return an error when given a nil BidderParamValidator.")
 	}
@@ -269,7 +273,7 @@ func TestExchangeError(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewEndpoint(&brokenExchange{}, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewEndpoint(&brokenExchange{}, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	recorder := httptest.NewRecorder()
 	endpoint(recorder, request, nil)
@@ -327,7 +331,7 @@ func TestImplicitIPs(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	httpReq.Header.Set("X-Forwarded-For", "123.456.78.90")
 	recorder := httptest.NewRecorder()
@@ -359,6 +363,202 @@ func TestImplicitSecure(t *testing.T) {
 	}
 }
 
+func TestImplicitTIDs(t *testing.T) {
+	bidReq := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "without-tid", Ext: openrtb.RawJSON(`{"appnexus":{"placementId":1}}`)},
+			{ID: "with-tid", Ext: openrtb.RawJSON(`{"tid":"some-tid","appnexus":{"placementId":1}}`)},
+			{ID: "without-ext"},
+		},
+	}
+	setTIDsImplicitly(bidReq)
+
+	if bidReq.Source == nil || bidReq.Source.TID == "" {
+		t.Errorf("request.source.tid should be generated. Got %#v", bidReq.Source)
+	}
+	if tid, _ := jsonparser.GetString(bidReq.Imp[0].Ext, "tid"); tid == "" {
+		t.Errorf("request.imp[0].ext.tid should be generated. Got %s", string(bidReq.Imp[0].Ext))
+	}
+	if tid, _ := jsonparser.GetString(bidReq.Imp[1].Ext, "tid"); tid != "some-tid" {
+		t.Errorf("request.imp[1].ext.tid should not be changed. Got %s", tid)
+	}
+	if bidReq.Imp[2].Ext != nil {
+		t.Errorf("request.imp[2].ext should be left for validation to reject. Got %s", string(bidReq.Imp[2].Ext))
+	}
+
+	bidReq.Source.TID = "some-source-tid"
+	setTIDsImplicitly(bidReq)
+	if bidReq.Source.TID != "some-source-tid" {
+		t.Errorf("request.source.tid should not be changed. Got %s", bidReq.Source.TID)
+	}
+}
+
+func TestImplicitImpExtMigration(t *testing.T) {
+	imps := []openrtb.Imp{
+		{ID: "legacy", Ext: openrtb.RawJSON(`{"appnexus":{"placementId":1},"tid":"some-tid"}`)},
+		{ID: "migrated", Ext: openrtb.RawJSON(`{"prebid":{"bidder":{"appnexus":{"placementId":1}}}}`)},
+		{ID: "malformed", Ext: openrtb.RawJSON(`{"appnexus":{},"prebid":"bad"}`)},
+		{ID: "without-ext"},
+	}
+	migrateImpExts(imps)
+
+	if params, _, _, _ := jsonparser.Get(imps[0].Ext, "prebid", "bidder", "appnexus"); string(params) != `{"placementId":1}` {
+		t.Errorf("request.imp[0].ext.appnexus should be moved into request.imp[0].ext.prebid.bidder. Got %s", string(imps[0].Ext))
+	}
+	if _, dataType, _, _ := jsonparser.Get(imps[0].Ext, "appnexus"); dataType != jsonparser.NotExist {
+		t.Errorf("request.imp[0].ext.appnexus should be removed. Got %s", string(imps[0].Ext))
+	}
+	if tid, _ := jsonparser.GetString(imps[0].Ext, "tid"); tid != "some-tid" {
+		t.Errorf("request.imp[0].ext.tid should not be moved. Got %s", string(imps[0].Ext))
+	}
+	if string(imps[1].Ext) != `{"prebid":{"bidder":{"appnexus":{"placementId":1}}}}` {
+		t.Errorf("request.imp[1].ext should not be changed. Got %s", string(imps[1].Ext))
+	}
+	if string(imps[2].Ext) != `{"appnexus":{},"prebid":"bad"}` {
+		t.Errorf("request.imp[2].ext should be left for validation to reject. Got %s", string(imps[2].Ext))
+	}
+	if imps[3].Ext != nil {
+		t.Errorf("request.imp[3].ext should be left for validation to reject. Got %s", string(imps[3].Ext))
+	}
+}
+
+func TestImplicitCookieDeprecation(t *testing.T) {
+	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
+	httpReq.Header.Set("Sec-Cookie-Deprecation", "label_only_1")
+
+	bidReq := &openrtb.BidRequest{}
+	setCookieDeprecationImplicitly(httpReq, bidReq)
+	if bidReq.Device == nil || string(bidReq.Device.Ext) != `{"cdep":"label_only_1"}` {
+		t.Errorf("device.ext.cdep should come from the Sec-Cookie-Deprecation header. Got %#v", bidReq.Device)
+	}
+
+	bidReq = &openrtb.BidRequest{Device: &openrtb.Device{Ext: openrtb.RawJSON(`{"cdep":"from-request"}`)}}
+	setCookieDeprecationImplicitly(httpReq, bidReq)
+	if string(bidReq.Device.Ext) != `{"cdep":"from-request"}` {
+		t.Errorf("device.ext.cdep should not be overwritten. Got %s", string(bidReq.Device.Ext))
+	}
+
+	httpReq.Header.Set("Sec-Cookie-Deprecation", strings.Repeat("a", 150))
+	bidReq = &openrtb.BidRequest{}
+	setCookieDeprecationImplicitly(httpReq, bidReq)
+	if cdep, _ := jsonparser.GetString(bidReq.Device.Ext, "cdep"); len(cdep) != maxCookieDeprecationLabelLength {
+		t.Errorf("device.ext.cdep should be truncated to %d characters. Got %d", maxCookieDeprecationLabelLength, len(cdep))
+	}
+}
+
+func TestImplicitSUA(t *testing.T) {
+	chromeUA := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
+	httpReq.Header.Set("User-Agent", chromeUA)
+	httpReq.Header.Set("Sec-CH-UA", `"Google Chrome";v="124"`)
+	httpReq.Header.Set("Sec-CH-UA-Platform", `"Windows"`)
+
+	bidReq := &openrtb.BidRequest{Device: &openrtb.Device{UA: chromeUA, Ext: openrtb.RawJSON(`{"cdep":"label_only_1"}`)}}
+	setSUAImplicitly(httpReq, bidReq, false)
+	if source, _ := jsonparser.GetInt(bidReq.Device.Ext, "sua", "source"); source != 1 {
+		t.Errorf("device.ext.sua should come from the client hints. Got %s", string(bidReq.Device.Ext))
+	}
+	if cdep, _ := jsonparser.GetString(bidReq.Device.Ext, "cdep"); cdep != "label_only_1" {
+		t.Errorf("The rest of device.ext should be kept. Got %s", string(bidReq.Device.Ext))
+	}
+
+	bidReq = &openrtb.BidRequest{Device: &openrtb.Device{UA: chromeUA, Ext: openrtb.RawJSON(`{"sua":{"source":2}}`)}}
+	setSUAImplicitly(httpReq, bidReq, true)
+	if string(bidReq.Device.Ext) != `{"sua":{"source":2}}` {
+		t.Errorf("device.ext.sua should not be overwritten. Got %s", string(bidReq.Device.Ext))
+	}
+
+	// The client hints describe the caller, which isn't the device if the request has another User-Agent.
+	otherUA := "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1"
+	bidReq = &openrtb.BidRequest{Device: &openrtb.Device{UA: otherUA}}
+	setSUAImplicitly(httpReq, bidReq, false)
+	if bidReq.Device.Ext != nil {
+		t.Errorf("Client hints from another User-Agent shouldn't be used. Got %s", string(bidReq.Device.Ext))
+	}
+	setSUAImplicitly(httpReq, bidReq, true)
+	if source, _ := jsonparser.GetInt(bidReq.Device.Ext, "sua", "source"); source != 3 {
+		t.Errorf("device.ext.sua should be parsed from device.ua. Got %s", string(bidReq.Device.Ext))
+	}
+}
+
+func TestImplicitTopics(t *testing.T) {
+	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
+	httpReq.Header.Set("Sec-Browsing-Topics", "(1 2);v=chrome.1:1:2, (3);v=chrome.1:2:2, ();p=P0000000000")
+	cfg := &config.Topics{Provider: "topics.example.com", AllowedDomains: []string{"example.com"}}
+
+	bidReq := &openrtb.BidRequest{
+		Site: &openrtb.Site{Domain: "news.example.com"},
+		User: &openrtb.User{
+			Data: []openrtb.Data{{Name: "topics.example.com", Segment: []openrtb.Segment{{ID: "9"}}, Ext: openrtb.RawJSON(`{"segtax":601,"segclass":"2"}`)}},
+		},
+	}
+	setTopicsImplicitly(httpReq, bidReq, cfg)
+	if len(bidReq.User.Data) != 2 {
+		t.Fatalf("Only the topics which the request doesn't have should be added. Got %#v", bidReq.User.Data)
+	}
+	added := bidReq.User.Data[1]
+	if added.Name != "topics.example.com" || len(added.Segment) != 2 || added.Segment[0].ID != "1" || string(added.Ext) != `{"segtax":600,"segclass":"2"}` {
+		t.Errorf("Bad topics data. Got %#v with ext %s", added, string(added.Ext))
+	}
+
+	// Topics from another classifier model aren't duplicates, even though they use the same taxonomy.
+	bidReq = &openrtb.BidRequest{
+		Site: &openrtb.Site{Domain: "news.example.com"},
+		User: &openrtb.User{
+			Data: []openrtb.Data{{Name: "topics.example.com", Segment: []openrtb.Segment{{ID: "9"}}, Ext: openrtb.RawJSON(`{"segtax":601,"segclass":"1"}`)}},
+		},
+	}
+	setTopicsImplicitly(httpReq, bidReq, cfg)
+	if len(bidReq.User.Data) != 3 || string(bidReq.User.Data[2].Ext) != `{"segtax":601,"segclass":"2"}` {
+		t.Errorf("Topics from a different model should be added. Got %#v", bidReq.User.Data)
+	}
+
+	bidReq = &openrtb.BidRequest{Site: &openrtb.Site{Domain: "other.com"}}
+	setTopicsImplicitly(httpReq, bidReq, cfg)
+	if bidReq.User != nil {
+		t.Errorf("Topics should not be added for sites which aren't allowed. Got %#v", bidReq.User)
+	}
+
+	bidReq = &openrtb.BidRequest{App: &openrtb.App{}}
+	setTopicsImplicitly(httpReq, bidReq, cfg)
+	if bidReq.User != nil {
+		t.Errorf("Topics should not be added to app requests. Got %#v", bidReq.User)
+	}
+}
+
+func TestImplicitEnrichmentActivities(t *testing.T) {
+	chromeUA := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
+	httpReq.Header.Set("User-Agent", chromeUA)
+	httpReq.Header.Set("Sec-CH-UA", `"Google Chrome";v="124"`)
+	httpReq.Header.Set("Sec-Browsing-Topics", "(1 2);v=chrome.1:1:2")
+
+	deps := &endpointDeps{cfg: &config.Configuration{
+		Topics: config.Topics{Enabled: true, Provider: "topics.example.com", AllowedDomains: []string{"example.com"}},
+		Activities: config.Activities{
+			Host: config.ActivityControls{
+				EnrichUFPD: config.Activity{
+					Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{ComponentNames: []string{"topics"}}}},
+				},
+			},
+		},
+	}}
+	bidReq := &openrtb.BidRequest{Site: &openrtb.Site{Domain: "news.example.com"}}
+	deps.setFieldsImplicitly(httpReq, bidReq)
+	if bidReq.User != nil && len(bidReq.User.Data) > 0 {
+		t.Errorf("Topics shouldn't be added when the account's rules deny them. Got %#v", bidReq.User.Data)
+	}
+	if _, _, _, err := jsonparser.Get(bidReq.Device.Ext, "sua"); err != nil {
+		t.Errorf("The other enrichers should still run. Got %s", string(bidReq.Device.Ext))
+	}
+
+	bidReq = &openrtb.BidRequest{Site: &openrtb.Site{Domain: "news.example.com"}, Regs: &openrtb.Regs{COPPA: 1}}
+	deps.setFieldsImplicitly(httpReq, bidReq)
+	if len(bidReq.Device.Ext) > 0 {
+		t.Errorf("device.ext.sua shouldn't be added to COPPA requests. Got %s", string(bidReq.Device.Ext))
+	}
+}
+
 func TestRefererParsing(t *testing.T) {
 	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	httpReq.Header.Set("Referer", "http://test.mysite.com")
@@ -383,7 +583,7 @@ func TestStoredRequests(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	edep := &endpointDeps{&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{})}
+	edep := &endpointDeps{&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil, nil, nil}
 
 	for i, requestData := range testStoredRequests {
 		newRequest, errList := edep.processStoredRequests(context.Background(), json.RawMessage(requestData))
@@ -412,7 +612,12 @@ func TestOversizedRequest(t *testing.T) {
 		&mockStoredReqFetcher{},
 		&config.Configuration{MaxRequestSize: int64(len(reqBody) - 1)},
 		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
-		analyticsConf.NewPBSAnalytics(&config.Analytics{}),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	}
 
 	req := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody))
@@ -438,7 +643,12 @@ func TestRequestSizeEdgeCase(t *testing.T) {
 		&mockStoredReqFetcher{},
 		&config.Configuration{MaxRequestSize: int64(len(reqBody))},
 		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
-		analyticsConf.NewPBSAnalytics(&config.Analytics{}),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	}
 
 	req := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody))
@@ -463,7 +673,7 @@ func TestNoEncoding(t *testing.T) {
 		&mockStoredReqFetcher{},
 		&config.Configuration{MaxRequestSize: maxSize},
 		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
-		analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	recorder := httptest.NewRecorder()
 	endpoint(recorder, request, nil)
@@ -490,7 +700,7 @@ func TestContentType(t *testing.T) {
 		&mockStoredReqFetcher{},
 		&config.Configuration{MaxRequestSize: maxSize},
 		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
-		analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
 	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	recorder := httptest.NewRecorder()
 	endpoint(recorder, request, nil)
@@ -500,6 +710,409 @@ func TestContentType(t *testing.T) {
 	}
 }
 
+// TestDeprecatedBidder makes sure that requests for deprecated bidders get a warning in the response.
+func TestDeprecatedBidder(t *testing.T) {
+	bidderInfos := adapters.BidderInfos{
+		"appnexus": adapters.BidderInfo{Deprecation: &adapters.DeprecationInfo{}},
+	}
+	endpoint, _ := NewEndpoint(
+		&mockExchange{},
+		newParamsValidator(t),
+		&mockStoredReqFetcher{},
+		&config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), bidderInfos, nil, nil)
+	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Requests for deprecated bidders should still succeed. Got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response openrtb.BidResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal the response: %v", err)
+	}
+	var responseExt openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	if warnings := responseExt.Warnings[openrtb_ext.BidderAppnexus]; len(warnings) != 1 {
+		t.Errorf("Expected one warning for appnexus. Got %v", warnings)
+	}
+}
+
+// TestRemovedBidder makes sure that requests for removed bidders are rejected.
+func TestRemovedBidder(t *testing.T) {
+	bidderInfos := adapters.BidderInfos{
+		"appnexus": adapters.BidderInfo{Deprecation: &adapters.DeprecationInfo{Removed: true, Replacement: "rubicon"}},
+	}
+	endpoint, _ := NewEndpoint(
+		&mockExchange{},
+		newParamsValidator(t),
+		&mockStoredReqFetcher{},
+		&config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), bidderInfos, nil, nil)
+	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Requests for removed bidders should return a 400. Got %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "Use rubicon instead.") {
+		t.Errorf("The error should name the replacement bidder. Got %s", recorder.Body.String())
+	}
+}
+
+// TestAccountBidderParams makes sure that accounts can restrict the bidder params which their requests use.
+func TestAccountBidderParams(t *testing.T) {
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com","publisher":{"id":"%s"}},"imp":[` +
+		`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":%d}}}]}`
+
+	cfg := &config.Configuration{
+		MaxRequestSize: maxSize,
+		BidderParams: config.BidderParams{
+			Accounts: []config.AccountBidderParams{{
+				ID:      "managed",
+				Schemas: map[string]string{"appnexus": `{"properties":{"placementId":{"enum":[10433394]}}}`},
+			}},
+		},
+	}
+	endpoint, err := NewEndpoint(&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error building the endpoint: %v", err)
+	}
+
+	testCases := []struct {
+		account      string
+		placementID  int
+		expectStatus int
+	}{
+		{"managed", 10433394, http.StatusOK},
+		{"managed", 12345, http.StatusBadRequest},
+		{"self-serve", 12345, http.StatusOK},
+	}
+	for _, test := range testCases {
+		recorder := httptest.NewRecorder()
+		body := fmt.Sprintf(reqBody, test.account, test.placementID)
+		endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(body)), nil)
+		if recorder.Code != test.expectStatus {
+			t.Errorf("Placement %d for account %s: expected status %d. Got %d: %s", test.placementID, test.account, test.expectStatus, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	cfg.BidderParams.Accounts[0].Schemas["nosuchbidder"] = `{}`
+	if _, err := NewEndpoint(&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil); err == nil {
+		t.Error("Account schemas for unknown bidders should stop the endpoint from being built.")
+	}
+}
+
+// TestImpLimits makes sure that requests with too many imps are rejected or truncated, depending on the config.
+func TestImpLimits(t *testing.T) {
+	// The second imp has no media types, so the request is only valid if it gets truncated.
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com"},"imp":[` +
+		`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}},` +
+		`{"id":"imp-2","ext":{"appnexus":{"placementId":10433394}}}]}`
+
+	ex := &nobidExchange{}
+	cfg := &config.Configuration{MaxRequestSize: maxSize, ImpLimits: config.ImpLimits{Max: 1, Action: config.ImpLimitTruncate}}
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Requests with too many imps should be truncated. Got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if len(ex.gotRequest.Imp) != 1 || ex.gotRequest.Imp[0].ID != "imp-1" {
+		t.Errorf("Only the first imp should be auctioned. Got %v", ex.gotRequest.Imp)
+	}
+	if warnings, _, _, _ := jsonparser.Get(recorder.Body.Bytes(), "ext", "warnings", "prebid"); len(warnings) == 0 {
+		t.Errorf("The response should warn that the imps were truncated. Got %s", recorder.Body.String())
+	}
+
+	cfg.ImpLimits.Action = config.ImpLimitReject
+	recorder = httptest.NewRecorder()
+	endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Requests with too many imps should be rejected. Got status %d", recorder.Code)
+	}
+}
+
+func TestMinBidsToRespond(t *testing.T) {
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com","publisher":{"id":"1001"}},"imp":[` +
+		`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+
+	cfg := &config.Configuration{MaxRequestSize: maxSize, NoBids: config.NoBids{MinBidsToRespond: 1}}
+	doAuction := func(ex exchange.Exchange) *httptest.ResponseRecorder {
+		endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+			pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+			analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+		return recorder
+	}
+
+	if recorder := doAuction(&nobidExchange{}); recorder.Code != http.StatusNoContent || recorder.Body.Len() != 0 {
+		t.Errorf("Auctions without bids should get an empty 204. Got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := doAuction(&mockExchange{}); recorder.Code != http.StatusOK {
+		t.Errorf("Auctions with enough bids should get a response. Got status %d", recorder.Code)
+	}
+
+	cfg.NoBids.Accounts = []config.AccountNoBids{{ID: "1001", MinBidsToRespond: 2}}
+	if recorder := doAuction(&mockExchange{}); recorder.Code != http.StatusNoContent {
+		t.Errorf("The account's minimum should be used. Got status %d", recorder.Code)
+	}
+	cfg.NoBids.Accounts = []config.AccountNoBids{{ID: "1001"}}
+	if recorder := doAuction(&nobidExchange{}); recorder.Code != http.StatusOK {
+		t.Errorf("Accounts which opt out should always get a response. Got status %d", recorder.Code)
+	}
+}
+
+func TestInvalidTraffic(t *testing.T) {
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com"},"imp":[` +
+		`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+
+	doAuction := func(action string, userAgent string) (*httptest.ResponseRecorder, *mockExchange) {
+		filter, err := ivt.NewFilter(&config.InvalidTraffic{Enabled: true, Action: action, UserAgents: []string{"bot"}})
+		if err != nil {
+			t.Fatalf("Failed to make the filter: %v", err)
+		}
+		ex := &mockExchange{}
+		endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize},
+			pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+			analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, filter)
+		request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody))
+		request.Header.Set("User-Agent", userAgent)
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, request, nil)
+		return recorder, ex
+	}
+
+	if recorder, ex := doAuction(config.InvalidTrafficDrop, "Googlebot/2.1"); recorder.Code != http.StatusNoContent || ex.lastRequest != nil {
+		t.Errorf("Dropped requests should get a 204 without an auction. Got status %d", recorder.Code)
+	}
+	if recorder, ex := doAuction(config.InvalidTrafficDrop, "Mozilla/5.0"); recorder.Code != http.StatusOK || ex.lastRequest == nil {
+		t.Errorf("Requests which pass the filter should be auctioned. Got status %d", recorder.Code)
+	}
+	if recorder, ex := doAuction(config.InvalidTrafficFlag, "Googlebot/2.1"); recorder.Code != http.StatusOK || ex.lastRequest == nil {
+		t.Errorf("Flagged requests should still be auctioned. Got status %d", recorder.Code)
+	}
+}
+
+func TestPrebidWarnings(t *testing.T) {
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com"},"tmax":5000,"imp":[` +
+		`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}}],` +
+		`"ext":{"prebid":{"targetting":{},"supportdeals":true}}}`
+
+	cfg := &config.Configuration{MaxRequestSize: maxSize, AuctionTimeouts: config.AuctionTimeouts{Max: 1000}}
+	endpoint, _ := NewEndpoint(&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Warnings should not make the request fail. Got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response openrtb.BidResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal the response: %v", err)
+	}
+	var responseExt openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	warnings := responseExt.Warnings[openrtb_ext.WarningsPrebid]
+	if len(warnings) != 2 {
+		t.Fatalf("Expected warnings for the unknown field and the clamped tmax. Got %v", warnings)
+	}
+	if warnings[0] != "request.ext.prebid.targetting is not supported, so it was ignored." {
+		t.Errorf("Bad warning for the unknown field: %s", warnings[0])
+	}
+	if warnings[1] != "request.tmax of 5000 ms is longer than this host allows. The auction was limited to 1000 ms." {
+		t.Errorf("Bad warning for the clamped tmax: %s", warnings[1])
+	}
+	if len(responseExt.Errors) != 0 {
+		t.Errorf("Warnings should not be reported as errors. Got %v", responseExt.Errors)
+	}
+}
+
+func TestRequestValidationModes(t *testing.T) {
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com","publisher":{"id":"strict-pub"}},"imp":[` +
+		`{"id":"imp-1","banner":{"format":[{"w":300,"h":250}],"api":[3,9]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+
+	ex := &nobidExchange{}
+	cfg := &config.Configuration{MaxRequestSize: maxSize, RequestValidation: config.RequestValidation{Mode: config.ValidationLenient}}
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Lenient validation should drop bad values. Got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if api := ex.gotRequest.Imp[0].Banner.API; len(api) != 1 || api[0] != 3 {
+		t.Errorf("Only the valid banner.api values should be auctioned. Got %v", api)
+	}
+	if warnings, _, _, _ := jsonparser.Get(recorder.Body.Bytes(), "ext", "warnings", "prebid"); len(warnings) == 0 {
+		t.Errorf("The response should warn that the value was removed. Got %s", recorder.Body.String())
+	}
+
+	cfg.RequestValidation.Accounts = []config.AccountRequestValidation{{ID: "strict-pub", Mode: config.ValidationStrict}}
+	recorder = httptest.NewRecorder()
+	endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Strict validation should reject bad values. Got status %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "request.imp[0].banner.api[1] must be an integer from 1 to 7") {
+		t.Errorf("The error should say which value was bad. Got %s", recorder.Body.String())
+	}
+}
+
+// TestOpenRTB26Fields makes sure that the OpenRTB 2.6 fields which our structs don't have are kept in ext.
+func TestOpenRTB26Fields(t *testing.T) {
+	reqBody := `{"id":"some-request-id","site":{"page":"test.somepage.com"},"regs":{"gdpr":1,"ext":{"us_privacy":"1YNN"}},"user":{"consent":"BOEFEAyOEFEAyAHABDENAI4AAAB9vABAASA"},` +
+		`"imp":[{"id":"imp-1","banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+
+	ex := &nobidExchange{}
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody)), nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var regsExt openrtb_ext.ExtRegs
+	if err := json.Unmarshal(ex.gotRequest.Regs.Ext, &regsExt); err != nil {
+		t.Fatalf("Bad regs.ext: %v", err)
+	}
+	if regsExt.GDPR == nil || *regsExt.GDPR != 1 || regsExt.USPrivacy != "1YNN" {
+		t.Errorf("regs.gdpr should be moved into regs.ext, next to us_privacy. Got %s", ex.gotRequest.Regs.Ext)
+	}
+	if consent, _ := jsonparser.GetString(ex.gotRequest.User.Ext, "consent"); consent != "BOEFEAyOEFEAyAHABDENAI4AAAB9vABAASA" {
+		t.Errorf("user.consent should be moved into user.ext. Got %s", ex.gotRequest.User.Ext)
+	}
+}
+
+func TestProtobufAuction(t *testing.T) {
+	bidRequest := &openrtb.BidRequest{
+		ID:   "some-request-id",
+		Site: &openrtb.Site{Page: "test.somepage.com"},
+		Imp: []openrtb.Imp{{
+			ID:     "imp-1",
+			Banner: &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}},
+			Ext:    openrtb.RawJSON(`{"appnexus":{"placementId":10433394}}`),
+		}},
+	}
+	body, err := openrtb_proto.Marshal(bidRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal the request: %v", err)
+	}
+
+	ex := &nobidExchange{}
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	request := httptest.NewRequest("POST", "/openrtb2/auction", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Protobuf requests should be accepted. Got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if ex.gotRequest.Imp[0].Banner.Format[0].W != 300 {
+		t.Errorf("The exchange should get the decoded request. Got %#v", ex.gotRequest.Imp[0])
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/x-protobuf" {
+		t.Errorf("Protobuf requests should get a protobuf response by default. Got %s", contentType)
+	}
+	var response openrtb.BidResponse
+	if err := openrtb_proto.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal the response: %v", err)
+	}
+	if response.ID != "some-request-id" {
+		t.Errorf("Bad response id. Got %s", response.ID)
+	}
+
+	request = httptest.NewRequest("POST", "/openrtb2/auction", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("Accept", "application/json")
+	recorder = httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("The Accept header should decide the response format. Got %s", contentType)
+	}
+
+	request = httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader("not protobuf"))
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	recorder = httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Malformed protobuf requests should be rejected. Got status %d", recorder.Code)
+	}
+}
+
+func TestWantsProtobufResponse(t *testing.T) {
+	tests := []struct {
+		contentType string
+		accept      string
+		expected    bool
+	}{
+		{contentType: "application/json", accept: "", expected: false},
+		{contentType: "application/x-protobuf", accept: "", expected: true},
+		{contentType: "application/json", accept: "application/x-protobuf", expected: true},
+		{contentType: "application/json", accept: "text/html, application/x-protobuf;q=0.9", expected: true},
+		{contentType: "application/x-protobuf", accept: "application/json", expected: false},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+		request.Header.Set("Content-Type", test.contentType)
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+		if actual := wantsProtobufResponse(request); actual != test.expected {
+			t.Errorf("Content-Type %q with Accept %q: expected %t", test.contentType, test.accept, test.expected)
+		}
+	}
+}
+
+func TestAddWarnings(t *testing.T) {
+	response := &openrtb.BidResponse{Ext: openrtb.RawJSON(`{"warnings":{"appnexus":["media type dropped"]},"responsetimemillis":{"appnexus":5}}`)}
+	err := addWarnings(response, map[openrtb_ext.BidderName][]string{
+		"appnexus": {"appnexus is deprecated"},
+		"prebid":   {"tmax was clamped"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error adding warnings: %v", err)
+	}
+	var responseExt openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	if warnings := responseExt.Warnings["appnexus"]; len(warnings) != 2 || warnings[0] != "media type dropped" {
+		t.Errorf("New warnings should be added after the exchange's warnings. Got %v", warnings)
+	}
+	if warnings := responseExt.Warnings["prebid"]; len(warnings) != 1 {
+		t.Errorf("Expected the prebid warning. Got %v", warnings)
+	}
+	if responseExt.ResponseTimeMillis["appnexus"] != 5 {
+		t.Errorf("The rest of the ext should not change. Got %s", response.Ext)
+	}
+}
+
 func validRequest(t *testing.T, filename string) string {
 	requestData, err := ioutil.ReadFile("sample-requests/valid-whole/supplementary/" + filename)
 	if err != nil {
@@ -0,0 +1,225 @@
+package openrtb2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ortbField describes the values which OpenRTB 2.6 (and the AdCOM 1.0 lists it refers to) allow for one field.
+//
+// Paths are relative to the request, and a "[]" suffix means that the field is an array whose elements are
+// checked one at a time. This also covers the 2.6 fields which our OpenRTB structs don't have yet.
+type ortbField struct {
+	path     string
+	valid    func(value interface{}) bool
+	expected string
+}
+
+var ortbFields = []ortbField{
+	boolField("test"),
+	boolField("allimps"),
+	enumField("at", 1, 2),
+	boolField("imp[].instl"),
+	boolField("imp[].secure"),
+	boolField("imp[].rwdd"),
+	boolField("imp[].clickbrowser"),
+	enumField("imp[].banner.api[]", 1, 7),
+	enumField("imp[].banner.btype[]", 1, 4),
+	enumField("imp[].banner.battr[]", 1, 17),
+	enumField("imp[].banner.pos", 0, 7),
+	enumField("imp[].video.placement", 1, 5),
+	enumField("imp[].video.plcmt", 1, 4),
+	enumField("imp[].video.linearity", 1, 2),
+	enumField("imp[].video.protocols[]", 1, 14),
+	enumField("imp[].video.api[]", 1, 7),
+	enumField("imp[].video.playbackmethod[]", 1, 7),
+	enumField("imp[].video.playbackend", 1, 3),
+	enumField("imp[].video.delivery[]", 1, 3),
+	enumField("imp[].video.battr[]", 1, 17),
+	enumField("imp[].video.pos", 0, 7),
+	boolField("imp[].video.skip"),
+	boolField("imp[].video.boxingallowed"),
+	rangeField("imp[].video.podseq", -1, 1),
+	rangeField("imp[].video.slotinpod", -1, 2),
+	{path: "imp[].video.startdelay", valid: integerAtLeast(-2), expected: "an integer >= -2"},
+	enumField("imp[].audio.protocols[]", 1, 14),
+	enumField("imp[].audio.api[]", 1, 7),
+	enumField("imp[].audio.delivery[]", 1, 3),
+	enumField("imp[].audio.feed", 1, 3),
+	rangeField("imp[].audio.nvol", 0, 4),
+	boolField("imp[].audio.stitched"),
+	enumField("imp[].native.api[]", 1, 7),
+	enumField("imp[].native.battr[]", 1, 17),
+	boolField("site.mobile"),
+	boolField("site.privacypolicy"),
+	boolField("app.paid"),
+	boolField("app.privacypolicy"),
+	enumField("device.devicetype", 1, 8),
+	enumField("device.connectiontype", 0, 7),
+	boolField("device.dnt"),
+	boolField("device.lmt"),
+	boolField("device.js"),
+	boolField("device.geofetch"),
+	enumField("device.geo.type", 1, 3),
+	enumField("device.geo.ipservice", 1, 4),
+	rangeField("device.sua.source", 0, 3),
+	enumField("user.geo.type", 1, 3),
+	{path: "user.gender", valid: stringIn("M", "F", "O"), expected: `"M", "F" or "O"`},
+	boolField("regs.coppa"),
+	boolField("regs.gdpr"),
+	{path: "regs.us_privacy", valid: stringOfLength(4), expected: "a 4 character string"},
+	{path: "regs.gpp_sid[]", valid: integerAtLeast(1), expected: "a positive integer"},
+	boolField("source.fd"),
+}
+
+// boolField is for the fields which OpenRTB uses as booleans.
+func boolField(path string) ortbField {
+	return ortbField{path: path, valid: integerIn(0, 1), expected: "0 or 1"}
+}
+
+// rangeField is for fields whose values must be within a fixed range.
+func rangeField(path string, min int64, max int64) ortbField {
+	return ortbField{path: path, valid: integerIn(min, max), expected: fmt.Sprintf("an integer from %d to %d", min, max)}
+}
+
+// enumField is for fields which use an AdCOM list. These reserve 500 and above for vendor-specific values.
+func enumField(path string, min int64, max int64) ortbField {
+	inRange := integerIn(min, max)
+	vendorSpecific := integerAtLeast(500)
+	return ortbField{
+		path: path,
+		valid: func(value interface{}) bool {
+			return inRange(value) || vendorSpecific(value)
+		},
+		expected: fmt.Sprintf("an integer from %d to %d, or 500+ for vendor-specific values", min, max),
+	}
+}
+
+func integerIn(min int64, max int64) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		i, err := number.Int64()
+		return err == nil && i >= min && i <= max
+	}
+}
+
+func integerAtLeast(min int64) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		i, err := number.Int64()
+		return err == nil && i >= min
+	}
+}
+
+func stringIn(allowed ...string) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		str, ok := value.(string)
+		if !ok {
+			return false
+		}
+		for _, allowedValue := range allowed {
+			if str == allowedValue {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func stringOfLength(length int) func(value interface{}) bool {
+	return func(value interface{}) bool {
+		str, ok := value.(string)
+		return ok && len(str) == length
+	}
+}
+
+// validateORTBFields checks the request's values against ortbFields. It returns a message for each bad value,
+// and a copy of the request without them. If all the values are fine, the original request is returned.
+//
+// Fields which don't have the expected type of parent (e.g. an imp which isn't an object) are left
+// for the regular validation to report.
+func validateORTBFields(requestJson []byte) (cleaned []byte, problems []string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(requestJson))
+	dec.UseNumber()
+	var request map[string]interface{}
+	if err := dec.Decode(&request); err != nil {
+		return nil, nil, err
+	}
+	for i := range ortbFields {
+		problems = append(problems, ortbFields[i].check(request, strings.Split(ortbFields[i].path, "."), "request")...)
+	}
+	if len(problems) == 0 {
+		return requestJson, nil, nil
+	}
+	cleaned, err = json.Marshal(request)
+	return cleaned, problems, err
+}
+
+// check looks for the field along the remaining path segments, starting from node. Bad values are removed
+// from node, and described in the returned messages.
+func (field *ortbField) check(node map[string]interface{}, segments []string, location string) []string {
+	key := strings.TrimSuffix(segments[0], "[]")
+	isArray := key != segments[0]
+	isLast := len(segments) == 1
+	value, ok := node[key]
+	if !ok {
+		return nil
+	}
+	location = location + "." + key
+
+	if !isArray {
+		if isLast {
+			if !field.valid(value) {
+				delete(node, key)
+				return []string{fmt.Sprintf("%s must be %s. Got %s", location, field.expected, describeJSON(value))}
+			}
+			return nil
+		}
+		if child, ok := value.(map[string]interface{}); ok {
+			return field.check(child, segments[1:], location)
+		}
+		return nil
+	}
+
+	elements, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var problems []string
+	if isLast {
+		kept := make([]interface{}, 0, len(elements))
+		for i, element := range elements {
+			if field.valid(element) {
+				kept = append(kept, element)
+			} else {
+				problems = append(problems, fmt.Sprintf("%s[%d] must be %s. Got %s", location, i, field.expected, describeJSON(element)))
+			}
+		}
+		if len(problems) > 0 {
+			node[key] = kept
+		}
+		return problems
+	}
+	for i, element := range elements {
+		if child, ok := element.(map[string]interface{}); ok {
+			problems = append(problems, field.check(child, segments[1:], fmt.Sprintf("%s[%d]", location, i))...)
+		}
+	}
+	return problems
+}
+
+// describeJSON returns the value as it appeared in the request.
+func describeJSON(value interface{}) string {
+	if encoded, err := json.Marshal(value); err == nil {
+		return string(encoded)
+	}
+	return fmt.Sprintf("%v", value)
+}
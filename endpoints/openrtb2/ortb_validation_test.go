@@ -0,0 +1,63 @@
+package openrtb2
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateORTBFields(t *testing.T) {
+	requestJson := []byte(`{"id":"req","imp":[{"id":"imp-1","instl":2,"video":{"api":[1,9,501],"placement":3}}],"user":{"gender":"X"},"regs":{"gdpr":1}}`)
+	cleaned, problems, err := validateORTBFields(requestJson)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{
+		"request.imp[0].instl must be 0 or 1. Got 2",
+		"request.imp[0].video.api[1] must be an integer from 1 to 7, or 500+ for vendor-specific values. Got 9",
+		`request.user.gender must be "M", "F" or "O". Got "X"`,
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems. Got %v", len(expected), problems)
+	}
+	for i := range expected {
+		if problems[i] != expected[i] {
+			t.Errorf("Expected problem %d to be %q. Got %q", i, expected[i], problems[i])
+		}
+	}
+
+	var parsed struct {
+		Imp []struct {
+			Instl *int `json:"instl"`
+			Video struct {
+				API       []int `json:"api"`
+				Placement int   `json:"placement"`
+			} `json:"video"`
+		} `json:"imp"`
+		User struct {
+			Gender *string `json:"gender"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(cleaned, &parsed); err != nil {
+		t.Fatalf("The cleaned request should be valid JSON: %v", err)
+	}
+	if parsed.Imp[0].Instl != nil || parsed.User.Gender != nil {
+		t.Errorf("Bad values should be removed. Got %s", cleaned)
+	}
+	if api := parsed.Imp[0].Video.API; len(api) != 2 || api[0] != 1 || api[1] != 501 {
+		t.Errorf("Only the bad elements of arrays should be removed. Got %v", api)
+	}
+	if parsed.Imp[0].Video.Placement != 3 {
+		t.Errorf("Good values should be kept. Got %s", cleaned)
+	}
+}
+
+func TestValidateORTBFieldsUnchanged(t *testing.T) {
+	requestJson := []byte(`{"id":"req","imp":[{"id":"imp-1","secure":1,"banner":{"api":[3,5]}}],"device":{"devicetype":8},"regs":{"us_privacy":"1YNN","gpp_sid":[7]}}`)
+	cleaned, problems, err := validateORTBFields(requestJson)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("Valid requests should not have problems. Got %v, %v", problems, err)
+	}
+	if string(cleaned) != string(requestJson) {
+		t.Errorf("Valid requests should not be changed. Got %s", cleaned)
+	}
+}
@@ -0,0 +1,46 @@
+package openrtb2
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_proto"
+)
+
+// protobufContentType is the media type for OpenRTB requests and responses encoded with openrtb_proto.
+const protobufContentType = "application/x-protobuf"
+
+// isProtobufRequest returns true if the request body is protobuf, according to its Content-Type.
+func isProtobufRequest(httpRequest *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(httpRequest.Header.Get("Content-Type"))
+	return err == nil && mediaType == protobufContentType
+}
+
+// wantsProtobufResponse returns true if the caller asked for a protobuf response in its Accept header.
+// Callers which don't send an Accept header get the same format which they used for the request.
+func wantsProtobufResponse(httpRequest *http.Request) bool {
+	accept := httpRequest.Header.Get("Accept")
+	if accept == "" {
+		return isProtobufRequest(httpRequest)
+	}
+	for _, acceptedType := range strings.Split(accept, ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(acceptedType)); err == nil && mediaType == protobufContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// protobufToJSON converts a protobuf request body into JSON, so that it can go through the same
+// Stored Request merging and validation as every other request.
+func protobufToJSON(body []byte) ([]byte, error) {
+	var req openrtb.BidRequest
+	if err := openrtb_proto.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("request is not a valid protobuf BidRequest: %v", err)
+	}
+	return json.Marshal(&req)
+}
@@ -0,0 +1,99 @@
+package openrtb2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/openrtb_proto"
+)
+
+// responseBuilder shapes the exchange's response for one kind of caller. Every endpoint runs the same auction,
+// so a new integration (e.g. a compact format for a mobile SDK) only needs a new responseBuilder.
+type responseBuilder interface {
+	// ContentType is the Content-Type of the bodies which Build returns.
+	ContentType() string
+	// Build returns the body for the caller. If it returns an error, nothing has been written yet, so the
+	// endpoint can still respond with an error status.
+	Build(req *openrtb.BidRequest, response *openrtb.BidResponse) ([]byte, error)
+}
+
+// jsonResponseBuilder writes the OpenRTB response as JSON.
+type jsonResponseBuilder struct{}
+
+func (jsonResponseBuilder) ContentType() string {
+	return "application/json"
+}
+
+func (jsonResponseBuilder) Build(req *openrtb.BidRequest, response *openrtb.BidResponse) ([]byte, error) {
+	return marshalJSON(response)
+}
+
+// protobufResponseBuilder writes the OpenRTB response with openrtb_proto.
+type protobufResponseBuilder struct{}
+
+func (protobufResponseBuilder) ContentType() string {
+	return protobufContentType
+}
+
+func (protobufResponseBuilder) Build(req *openrtb.BidRequest, response *openrtb.BidResponse) ([]byte, error) {
+	return openrtb_proto.Marshal(response)
+}
+
+// ampResponseBuilder writes the targeting keys from the winning bids, which are all that AMP pages can use.
+// It's made for each request, since it keeps the targeting it built for the analytics.
+type ampResponseBuilder struct {
+	targeting map[string]string
+}
+
+func (b *ampResponseBuilder) ContentType() string {
+	return "application/json"
+}
+
+func (b *ampResponseBuilder) Build(req *openrtb.BidRequest, response *openrtb.BidResponse) ([]byte, error) {
+	targets := map[string]string{}
+	byteCache := []byte("\"hb_cache_id")
+	for _, seatBids := range response.SeatBid {
+		for _, bid := range seatBids.Bid {
+			if bytes.Contains(bid.Ext, byteCache) {
+				// Looking for cache_id to be set, as this should only be set on winning bids (or
+				// deal bids), and AMP can only deliver cached ads in any case.
+				// Note, this could cause issues if a targeting key value starts with "hb_cache_id",
+				// but this is a very unlikely corner case. Doing this so we can catch "hb_cache_id"
+				// and "hb_cache_id_{deal}", which allows for deal support in AMP.
+				bidExt := &openrtb_ext.ExtBid{}
+				if err := json.Unmarshal(bid.Ext, bidExt); err != nil {
+					return nil, fmt.Errorf("Critical error while unpacking AMP targets: %v", err)
+				}
+				for key, value := range bidExt.Prebid.Targeting {
+					targets[key] = value
+				}
+			}
+		}
+	}
+	b.targeting = targets
+
+	ampResponse := AmpResponse{
+		Targeting: targets,
+	}
+	if req.Test == 1 {
+		var extResponse openrtb_ext.ExtBidResponse
+		if err := json.Unmarshal(response.Ext, &extResponse); err == nil {
+			ampResponse.Debug = extResponse.Debug
+		}
+	}
+	return marshalJSON(ampResponse)
+}
+
+// marshalJSON is json.Marshal without the HTML escaping, since bids' markup is HTML (fixes #231).
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package openrtb2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/appstore"
+	"github.com/prebid/prebid-server/blocklist"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/ivt"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+// SDKResponse is the compact response which /openrtb2/sdk sends to the Prebid Mobile SDKs. It only has the winning
+// bid for each imp, and what the SDK needs to render it. The field names are short, since these responses often go
+// over cellular connections.
+type SDKResponse struct {
+	ID   string   `json:"id"`
+	Cur  string   `json:"cur,omitempty"`
+	Bids []SDKBid `json:"b,omitempty"`
+}
+
+// SDKBid is the winning bid for one imp.
+type SDKBid struct {
+	ImpID     string              `json:"i"`
+	Bidder    string              `json:"s"`
+	Price     float64             `json:"p"`
+	AdM       string              `json:"a,omitempty"`
+	NURL      string              `json:"n,omitempty"`
+	W         uint64              `json:"w,omitempty"`
+	H         uint64              `json:"h,omitempty"`
+	Type      openrtb_ext.BidType `json:"t,omitempty"`
+	CacheURL  string              `json:"c,omitempty"`
+	Targeting map[string]string   `json:"k,omitempty"`
+	// RendererName and RendererVersion name the renderer which the bid needs, if it isn't the SDK's default.
+	RendererName    string `json:"rn,omitempty"`
+	RendererVersion string `json:"rv,omitempty"`
+}
+
+func NewSDKEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, bidderInfos adapters.BidderInfos, blocked *blocklist.List, ivtFilter *ivt.Filter) (httprouter.Handle, error) {
+	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
+		return nil, errors.New("NewSDKEndpoint requires non-nil arguments.")
+	}
+	accountParams, err := openrtb_ext.NewAccountParamsValidator(cfg.BidderParams.AccountSchemas())
+	if err != nil {
+		return nil, err
+	}
+
+	appEnricher := appstore.NewEnricher(&cfg.AppEnrichment, http.DefaultClient)
+
+	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, cfg, met, pbsAnalytics, bidderInfos, accountParams, appEnricher, blocked, ivtFilter}).SDKAuction), nil
+}
+
+// SDKAuction runs the same auction as /openrtb2/auction, but responds with an SDKResponse.
+func (deps *endpointDeps) SDKAuction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	deps.runAuction(w, r, "/openrtb2/sdk", sdkResponseBuilder{})
+}
+
+// sdkResponseBuilder writes SDKResponses.
+type sdkResponseBuilder struct{}
+
+func (sdkResponseBuilder) ContentType() string {
+	return "application/json"
+}
+
+// Build picks the bid for each imp which the exchange's targeting marked as the winner. If the request didn't ask
+// for the winners' targeting keys, the highest bid wins. The bids are in the order of the request's imps.
+func (sdkResponseBuilder) Build(req *openrtb.BidRequest, response *openrtb.BidResponse) ([]byte, error) {
+	winners := make(map[string]SDKBid, len(req.Imp))
+	for _, seatBid := range response.SeatBid {
+		for _, bid := range seatBid.Bid {
+			sdkBid := SDKBid{
+				ImpID:  bid.ImpID,
+				Bidder: seatBid.Seat,
+				Price:  bid.Price,
+				AdM:    bid.AdM,
+				NURL:   bid.NURL,
+				W:      bid.W,
+				H:      bid.H,
+			}
+			if len(bid.Ext) > 0 {
+				var bidExt openrtb_ext.ExtBid
+				if err := json.Unmarshal(bid.Ext, &bidExt); err != nil {
+					return nil, fmt.Errorf("Critical error while unpacking the ext of bid %s: %v", bid.ID, err)
+				}
+				if bidExt.Prebid != nil {
+					sdkBid.Type = bidExt.Prebid.Type
+					sdkBid.Targeting = bidExt.Prebid.Targeting
+					if bidExt.Prebid.Cache != nil {
+						sdkBid.CacheURL = bidExt.Prebid.Cache.Url
+					}
+					if bidExt.Prebid.Meta != nil {
+						sdkBid.RendererName = bidExt.Prebid.Meta.RendererName
+						sdkBid.RendererVersion = bidExt.Prebid.Meta.RendererVersion
+					}
+				}
+			}
+			if winner, ok := winners[bid.ImpID]; !ok || isSDKWinner(sdkBid, winner) {
+				winners[bid.ImpID] = sdkBid
+			}
+		}
+	}
+
+	sdkResponse := SDKResponse{
+		ID:  response.ID,
+		Cur: response.Cur,
+	}
+	for _, imp := range req.Imp {
+		if winner, ok := winners[imp.ID]; ok {
+			sdkResponse.Bids = append(sdkResponse.Bids, winner)
+		}
+	}
+	return marshalJSON(sdkResponse)
+}
+
+// isSDKWinner returns true if the bid should replace the imp's current winner.
+func isSDKWinner(bid SDKBid, winner SDKBid) bool {
+	_, bidWon := bid.Targeting[string(openrtb_ext.HbBidderConstantKey)]
+	_, winnerWon := winner.Targeting[string(openrtb_ext.HbBidderConstantKey)]
+	if bidWon != winnerWon {
+		return bidWon
+	}
+	return bid.Price > winner.Price
+}
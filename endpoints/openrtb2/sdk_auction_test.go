@@ -0,0 +1,115 @@
+package openrtb2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	analyticsConf "github.com/prebid/prebid-server/analytics/config"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSDKResponseBuilder(t *testing.T) {
+	req := &openrtb.BidRequest{Imp: []openrtb.Imp{{ID: "imp-2"}, {ID: "imp-1"}, {ID: "imp-3"}}}
+	response := &openrtb.BidResponse{
+		ID:  "req-1",
+		Cur: "USD",
+		SeatBid: []openrtb.SeatBid{{
+			Seat: "appnexus",
+			Bid: []openrtb.Bid{
+				{ID: "a1", ImpID: "imp-1", Price: 2, AdM: "<div>appnexus</div>", Ext: openrtb.RawJSON(`{"prebid":{"type":"banner","targeting":{"hb_pb_appnexus":"2.00"}}}`)},
+				{ID: "a2", ImpID: "imp-2", Price: 1, W: 320, H: 50, Ext: openrtb.RawJSON(`{"prebid":{"type":"banner"}}`)},
+				{ID: "a3", ImpID: "imp-3", Price: 3, Ext: openrtb.RawJSON(`{"prebid":{"type":"video","meta":{"rendererName":"outstream","rendererVersion":"1.2"}}}`)},
+			},
+		}, {
+			Seat: "rubicon",
+			Bid: []openrtb.Bid{
+				{ID: "r1", ImpID: "imp-1", Price: 1.5, AdM: "<div>rubicon</div>", Ext: openrtb.RawJSON(`{"prebid":{"type":"banner","targeting":{"hb_bidder":"rubicon","hb_pb":"1.50"},"cache":{"key":"abc","url":"https://cache.example.com/abc"}}}`)},
+				{ID: "r2", ImpID: "imp-2", Price: 1.2},
+			},
+		}},
+	}
+
+	data, err := (sdkResponseBuilder{}).Build(req, response)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var sdkResponse SDKResponse
+	if err := json.Unmarshal(data, &sdkResponse); err != nil {
+		t.Fatalf("Failed to unmarshal the response: %v", err)
+	}
+	expected := SDKResponse{
+		ID:  "req-1",
+		Cur: "USD",
+		Bids: []SDKBid{
+			{ImpID: "imp-2", Bidder: "rubicon", Price: 1.2},
+			{ImpID: "imp-1", Bidder: "rubicon", Price: 1.5, AdM: "<div>rubicon</div>", Type: "banner", CacheURL: "https://cache.example.com/abc", Targeting: map[string]string{"hb_bidder": "rubicon", "hb_pb": "1.50"}},
+			{ImpID: "imp-3", Bidder: "appnexus", Price: 3, Type: "video", RendererName: "outstream", RendererVersion: "1.2"},
+		},
+	}
+	if !reflect.DeepEqual(sdkResponse, expected) {
+		t.Errorf("Expected %+v. Got %+v", expected, sdkResponse)
+	}
+	if strings.Contains(string(data), `\u003c`) {
+		t.Errorf("The markup shouldn't be HTML escaped. Got %s", data)
+	}
+
+	response.SeatBid[0].Bid[0].Ext = openrtb.RawJSON(`{"prebid":"banner"}`)
+	if _, err := (sdkResponseBuilder{}).Build(req, response); err == nil {
+		t.Errorf("Bids with a bad ext should be an error.")
+	}
+}
+
+func TestSDKEndpoint(t *testing.T) {
+	endpoint, err := NewSDKEndpoint(&mockSDKExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}, nil), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the endpoint: %v", err)
+	}
+	body := `{"id":"req-1","app":{"bundle":"com.example.app"},"imp":[{"id":"imp-1","banner":{"format":[{"w":320,"h":50}]},"ext":{"appnexus":{"placementId":10433394}}}]}`
+	request := httptest.NewRequest("POST", "/openrtb2/sdk", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type should be application/json. Got %s", contentType)
+	}
+	expected := `{"id":"req-1","b":[{"i":"imp-1","s":"appnexus","p":1.2,"a":"<div></div>","w":320,"h":50,"t":"banner"}]}` + "\n"
+	if recorder.Body.String() != expected {
+		t.Errorf("Expected %s. Got %s", expected, recorder.Body.String())
+	}
+}
+
+type mockSDKExchange struct{}
+
+func (m *mockSDKExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, ids exchange.IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	return &openrtb.BidResponse{
+		ID: bidRequest.ID,
+		SeatBid: []openrtb.SeatBid{{
+			Seat: "appnexus",
+			Bid: []openrtb.Bid{{
+				ID:    "bid-1",
+				ImpID: "imp-1",
+				Price: 1.2,
+				AdM:   "<div></div>",
+				W:     320,
+				H:     50,
+				CrID:  "creative-1",
+				Ext:   openrtb.RawJSON(`{"prebid":{"type":"banner"},"bidder":{"appnexus":{"brand_id":1}}}`),
+			}},
+		}},
+	}, nil
+}
@@ -0,0 +1,62 @@
+package endpoints
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+)
+
+// AddProfilingEndpoints registers the pprof, expvar and goroutine dump endpoints on the admin mux,
+// if they're enabled. If the config has a token, every call must send it as a bearer token.
+func AddProfilingEndpoints(mux *http.ServeMux, cfg config.Profiling) {
+	if !cfg.Enabled {
+		return
+	}
+	if cfg.Token == "" {
+		logger.Warning("The profiling endpoints are enabled without a token. Anyone who can reach the admin port can use them.")
+	}
+
+	handle := func(path string, handler http.HandlerFunc) {
+		mux.HandleFunc(path, RequireToken(cfg.Token, handler))
+	}
+	handle("/debug/pprof/", pprof.Index)
+	handle("/debug/pprof/cmdline", pprof.Cmdline)
+	handle("/debug/pprof/profile", pprof.Profile)
+	handle("/debug/pprof/symbol", pprof.Symbol)
+	handle("/debug/pprof/trace", pprof.Trace)
+	handle("/debug/vars", expvar.Handler().ServeHTTP)
+	handle("/debug/goroutines", dumpGoroutines)
+}
+
+// RequireToken rejects calls which don't send the token in the Authorization header.
+// An empty token lets every call through.
+func RequireToken(token string, handler http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// dumpGoroutines writes the stack traces of every goroutine, in the same format as an unrecovered panic.
+func dumpGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Goroutine-Count", strconv.Itoa(runtime.NumGoroutine()))
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		logger.Errorf("/debug/goroutines failed to write the goroutine dump: %v", err)
+	}
+}
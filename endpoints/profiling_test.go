@@ -0,0 +1,59 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestProfilingDisabled(t *testing.T) {
+	mux := http.NewServeMux()
+	AddProfilingEndpoints(mux, config.Profiling{Enabled: false})
+	if code := callAdmin(mux, "/debug/pprof/", "").Code; code != http.StatusNotFound {
+		t.Errorf("Disabled profiling endpoints shouldn't be registered. Got status %d", code)
+	}
+}
+
+func TestProfilingToken(t *testing.T) {
+	mux := http.NewServeMux()
+	AddProfilingEndpoints(mux, config.Profiling{Enabled: true, Token: "s3cret"})
+
+	if code := callAdmin(mux, "/debug/vars", "").Code; code != http.StatusUnauthorized {
+		t.Errorf("Calls without the token should be rejected. Got status %d", code)
+	}
+	if code := callAdmin(mux, "/debug/vars", "Bearer wrong").Code; code != http.StatusUnauthorized {
+		t.Errorf("Calls with the wrong token should be rejected. Got status %d", code)
+	}
+	if code := callAdmin(mux, "/debug/vars", "Bearer s3cret").Code; code != http.StatusOK {
+		t.Errorf("Calls with the token should be allowed. Got status %d", code)
+	}
+}
+
+func TestGoroutineDump(t *testing.T) {
+	mux := http.NewServeMux()
+	AddProfilingEndpoints(mux, config.Profiling{Enabled: true})
+
+	recorder := callAdmin(mux, "/debug/goroutines", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), "goroutine ") {
+		t.Errorf("The response should contain a goroutine dump. Got %s", recorder.Body.String())
+	}
+	if recorder.Header().Get("X-Goroutine-Count") == "" {
+		t.Error("The response should include the goroutine count.")
+	}
+}
+
+func callAdmin(handler http.Handler, path string, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -2,8 +2,11 @@ package endpoints
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
+	"net/url"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/prebid/prebid-server/analytics"
@@ -14,8 +17,7 @@ import (
 	"github.com/prebid/prebid-server/usersync"
 )
 
-func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalytics analytics.PBSAnalyticsModule, metrics pbsmetrics.MetricsEngine) httprouter.Handle {
-	cookieTTL := time.Duration(cfg.TTL) * 24 * time.Hour
+func NewSetUIDEndpoint(cfg config.HostCookie, setUID config.SetUID, perms gdpr.Permissions, pbsanalytics analytics.PBSAnalyticsModule, metrics pbsmetrics.MetricsEngine) httprouter.Handle {
 	return httprouter.Handle(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		so := analytics.SetUIDObject{
 			Status: http.StatusOK,
@@ -57,6 +59,21 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 		}
 		so.Bidder = bidder
 
+		redirect := query.Get("redirect")
+		if redirect != "" {
+			if err := validateRedirect(redirect, &setUID); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				metrics.RecordUserIDSet(pbsmetrics.UserLabels{
+					Action: pbsmetrics.RequestActionErr,
+					Bidder: openrtb_ext.BidderName(bidder),
+				})
+				so.Status = http.StatusBadRequest
+				so.Errors = append(so.Errors, err)
+				return
+			}
+		}
+
 		uid := query.Get("uid")
 		so.UID = uid
 
@@ -76,7 +93,11 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 			so.Success = true
 		}
 
-		pc.SetCookieOnResponse(w, cfg.Domain, cookieTTL)
+		pc.SetHostCookieOnResponse(w, &cfg)
+		if redirect != "" {
+			http.Redirect(w, r, redirect, http.StatusFound)
+			so.Status = http.StatusFound
+		}
 	})
 }
 
@@ -109,3 +130,54 @@ func preventSyncsGDPR(gdprEnabled string, gdprConsent string, perms gdpr.Permiss
 		return true, http.StatusBadRequest, "the gdpr query param must be either 0 or 1. You gave " + gdprEnabled
 	}
 }
+
+// validateRedirect returns an error unless /setuid may send the user on to the redirect URL.
+// It must be an http or https URL on one of the allowed domains, and not too long.
+// The URLs nested in its query are counted as the rest of the chain, which must not be too long either.
+func validateRedirect(redirect string, cfg *config.SetUID) error {
+	if cfg.MaxRedirectLength > 0 && len(redirect) > cfg.MaxRedirectLength {
+		return fmt.Errorf("redirect must not be longer than %d characters", cfg.MaxRedirectLength)
+	}
+	parsed, err := url.Parse(redirect)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("redirect must be an http or https URL")
+	}
+	if !cfg.AllowsRedirectTo(parsed.Hostname()) {
+		return fmt.Errorf("redirect to %s isn't allowed", parsed.Hostname())
+	}
+	if redirectChainLength(parsed, cfg.MaxRedirectChain) > cfg.MaxRedirectChain {
+		return fmt.Errorf("redirect has more than %d URLs nested in it", cfg.MaxRedirectChain)
+	}
+	return nil
+}
+
+// redirectChainLength counts the URLs nested in the query of the redirect, following the first one at each level.
+// It stops once the count passes max, so that a long chain is never followed all the way.
+func redirectChainLength(redirect *url.URL, max int) int {
+	chain := 0
+	for chain <= max {
+		if redirect = nestedURL(redirect); redirect == nil {
+			break
+		}
+		chain++
+	}
+	return chain
+}
+
+// nestedURL returns the first URL with a host in the query of the given one, or nil if there isn't one.
+func nestedURL(parent *url.URL) *url.URL {
+	for _, param := range strings.Split(parent.RawQuery, "&") {
+		index := strings.Index(param, "=")
+		if index < 0 {
+			continue
+		}
+		value, err := url.QueryUnescape(param[index+1:])
+		if err != nil {
+			continue
+		}
+		if nested, err := url.Parse(value); err == nil && nested.Host != "" {
+			return nested
+		}
+	}
+	return nil
+}
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prebid/prebid-server/usersync"
 
+	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
@@ -81,6 +84,24 @@ func TestBadRequests(t *testing.T) {
 	assertBadRequest(t, "/setuid?bidder=appnexus&uid=123&gdpr=1", "gdpr_consent is required when gdpr=1")
 }
 
+func TestRedirect(t *testing.T) {
+	response := doRequest(makeRequest("/setuid?bidder=pubmatic&uid=123&redirect="+url.QueryEscape("https://eu.sync.com/sync?rurl=https%3A%2F%2Fpbs.com%2Fsetuid"), nil), true, false)
+	assertIntsMatch(t, http.StatusFound, response.Code)
+	assertStringsMatch(t, "https://eu.sync.com/sync?rurl=https%3A%2F%2Fpbs.com%2Fsetuid", response.Header().Get("Location"))
+	assertHasSyncs(t, response, map[string]string{
+		"pubmatic": "123",
+	})
+}
+
+func TestBadRedirects(t *testing.T) {
+	assertBadRequest(t, "/setuid?bidder=pubmatic&uid=123&redirect="+url.QueryEscape("https://evil.com/sync"), "redirect to evil.com isn't allowed")
+	assertBadRequest(t, "/setuid?bidder=pubmatic&uid=123&redirect="+url.QueryEscape("javascript://sync.com/alert(1)"), "redirect must be an http or https URL")
+	assertBadRequest(t, "/setuid?bidder=pubmatic&uid=123&redirect="+url.QueryEscape("https://sync.com/sync?p="+strings.Repeat("a", 200)), "redirect must not be longer than 200 characters")
+
+	chained := "https://sync.com/sync?rurl=" + url.QueryEscape("https://pbs.com/setuid?redirect="+url.QueryEscape("https://sync.com/sync"))
+	assertBadRequest(t, "/setuid?bidder=pubmatic&uid=123&redirect="+url.QueryEscape(chained), "redirect has more than 1 URLs nested in it")
+}
+
 func TestOptedOut(t *testing.T) {
 	request := httptest.NewRequest("GET", "/setuid?bidder=pubmatic&uid=123", nil)
 	cookie := usersync.NewPBSCookie()
@@ -125,8 +146,14 @@ func doRequest(req *http.Request, gdprAllowsHostCookies bool, gdprReturnsError b
 		allowHost: gdprAllowsHostCookies,
 		errorHost: gdprReturnsError,
 	}
-	cfg := config.Configuration{}
-	endpoint := NewSetUIDEndpoint(cfg.HostCookie, perms, analyticsConf.NewPBSAnalytics(&cfg.Analytics), metricsConf.NewMetricsEngine(&cfg, openrtb_ext.BidderList()))
+	cfg := config.Configuration{
+		SetUID: config.SetUID{
+			RedirectDomains:   []string{"sync.com"},
+			MaxRedirectLength: 200,
+			MaxRedirectChain:  1,
+		},
+	}
+	endpoint := NewSetUIDEndpoint(cfg.HostCookie, cfg.SetUID, perms, analyticsConf.NewPBSAnalytics(&cfg.Analytics, perms), metricsConf.NewMetricsEngine(&cfg, openrtb_ext.BidderList()))
 	response := httptest.NewRecorder()
 	endpoint(response, req, nil)
 	return response
@@ -190,3 +217,7 @@ func (g *mockPermsSetUID) HostCookiesAllowed(ctx context.Context, consent string
 func (g *mockPermsSetUID) BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
 	return false, nil
 }
+
+func (g *mockPermsSetUID) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (gdpr.AuctionPermissions, error) {
+	return gdpr.AuctionPermissions{}, nil
+}
@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/logger"
+)
+
+// NewSnapshotReplayEndpoint runs the auction snapshot in the request body through the exchange again, and returns
+// the recorded and replayed responses, with the differences between them. The bidders get their recorded responses,
+// so nothing is sent to them.
+func NewSnapshotReplayEndpoint(ex exchange.Exchange) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var snapshot exchange.Snapshot
+		if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("The request body must be an auction snapshot: " + err.Error()))
+			return
+		}
+		result, err := exchange.Replay(r.Context(), ex, &snapshot)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		jsonOutput, err := json.Marshal(result)
+		if err != nil {
+			logger.Errorf("/snapshots/replay Critical error when trying to marshal the result: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonOutput)
+	}
+}
@@ -0,0 +1,56 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+func TestSnapshotReplay(t *testing.T) {
+	handler := NewSnapshotReplayEndpoint(&echoExchange{})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/snapshots/replay", strings.NewReader(`{"request":{"id":"req","imp":[{"id":"imp"}]},"response":{"id":"old"}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Valid snapshots should return a 200. Got %d: %s", w.Code, w.Body.String())
+	}
+	var result exchange.ReplayResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("The result should be JSON: %v", err)
+	}
+	if result.Replayed == nil || result.Replayed.ID != "req" || len(result.Differences) != 1 || result.Differences[0] != "id" {
+		t.Errorf("The result should compare the replayed response with the recorded one. Got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/snapshots/replay", strings.NewReader(`{"request":`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Malformed snapshots should return a 400. Got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/snapshots/replay", strings.NewReader(`{"request":{"id":5}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Snapshots with invalid requests should return a 400. Got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/snapshots/replay", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET requests should return a 405. Got %d", w.Code)
+	}
+}
+
+// echoExchange responds to each auction with an empty response, which has the request's ID.
+type echoExchange struct{}
+
+func (e *echoExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs exchange.IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	return &openrtb.BidResponse{ID: bidRequest.ID}, nil
+}
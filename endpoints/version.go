@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 )
 
 type versionModel struct {
@@ -22,7 +22,7 @@ func NewVersionEndpoint(version string) func(w http.ResponseWriter, r *http.Requ
 			Revision: version,
 		})
 		if err != nil {
-			glog.Errorf("/version Critical error when trying to marshal versionModel: %v", err)
+			logger.Errorf("/version Critical error when trying to marshal versionModel: %v", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -2,6 +2,8 @@ package exchange
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/adapters/adform"
@@ -21,36 +23,134 @@ import (
 	"github.com/prebid/prebid-server/adapters/somoaudience"
 	"github.com/prebid/prebid-server/adapters/sovrn"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/ssl"
 )
 
-// The newAdapterMap function is segregated to its own file to make it a simple and clean location for each Adapter
+// The newAdapterBuilders function is segregated to its own file to make it a simple and clean location for each Adapter
 // to register itself. No wading through Exchange code to find it.
 
-func newAdapterMap(client *http.Client, cfg *config.Configuration) map[openrtb_ext.BidderName]adaptedBidder {
-	return map[openrtb_ext.BidderName]adaptedBidder{
-		openrtb_ext.BidderAdform:      adaptBidder(adform.NewAdformBidder(client, cfg.Adapters["adform"].Endpoint), client),
-		openrtb_ext.BidderAdtelligent: adaptBidder(adtelligent.NewAdtelligentBidder(client), client),
-		openrtb_ext.BidderAppnexus:    adaptBidder(appnexus.NewAppNexusBidder(client, cfg.Adapters["appnexus"].Endpoint), client),
-		openrtb_ext.BidderBeachfront:  adaptBidder(beachfront.NewBeachfrontBidder(), client),
-		openrtb_ext.BidderBrightroll:  adaptBidder(brightroll.NewBrightrollBidder(cfg.Adapters["brightroll"].Endpoint), client),
+// adapterBuilder makes a bidder's adaptedBidder from its config.
+type adapterBuilder func(cfg config.Adapter) adaptedBidder
+
+func newAdapterBuilders(client *http.Client) map[openrtb_ext.BidderName]adapterBuilder {
+	return map[openrtb_ext.BidderName]adapterBuilder{
+		openrtb_ext.BidderAdform: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(adform.NewAdformBidder(client, cfg.Endpoint), client)
+		},
+		openrtb_ext.BidderAdtelligent: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(adtelligent.NewAdtelligentBidder(client), client)
+		},
+		openrtb_ext.BidderAppnexus: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(appnexus.NewAppNexusBidder(client, cfg.Endpoint), client)
+		},
+		openrtb_ext.BidderBeachfront: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(beachfront.NewBeachfrontBidder(), client)
+		},
+		openrtb_ext.BidderBrightroll: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(brightroll.NewBrightrollBidder(cfg.Endpoint), client)
+		},
 		// TODO #267: Upgrade the Conversant adapter
-		openrtb_ext.BidderConversant: adaptLegacyAdapter(conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["conversant"].Endpoint)),
-		openrtb_ext.BidderEPlanning:  adaptBidder(eplanning.NewEPlanningBidder(client, cfg.Adapters["eplanning"].Endpoint), client),
+		openrtb_ext.BidderConversant: func(cfg config.Adapter) adaptedBidder {
+			return adaptLegacyAdapter(conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint))
+		},
+		openrtb_ext.BidderEPlanning: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(eplanning.NewEPlanningBidder(client, cfg.Endpoint), client)
+		},
 		// TODO #211: Upgrade the Facebook adapter
-		openrtb_ext.BidderFacebook: adaptLegacyAdapter(audienceNetwork.NewAdapterFromFacebook(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["facebook"].PlatformID)),
+		openrtb_ext.BidderFacebook: func(cfg config.Adapter) adaptedBidder {
+			return adaptLegacyAdapter(audienceNetwork.NewAdapterFromFacebook(adapters.DefaultHTTPAdapterConfig, audienceNetwork.PlatformID(cfg.ExtraInfo, cfg.PlatformID)))
+		},
 		// TODO #212: Upgrade the Index adapter
-		openrtb_ext.BidderIndex: adaptLegacyAdapter(indexExchange.NewIndexAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["indexexchange"].Endpoint)),
+		openrtb_ext.BidderIndex: func(cfg config.Adapter) adaptedBidder {
+			return adaptLegacyAdapter(indexExchange.NewIndexAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint))
+		},
 		// TODO #213: Upgrade the Lifestreet adapter
-		openrtb_ext.BidderLifestreet: adaptLegacyAdapter(lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig)),
-		openrtb_ext.BidderOpenx:      adaptBidder(openx.NewOpenxBidder(), client),
+		openrtb_ext.BidderLifestreet: func(cfg config.Adapter) adaptedBidder {
+			lsm := lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig)
+			lsm.MaxResponseBytes = cfg.MaxResponseBytes
+			return adaptLegacyAdapter(lsm)
+		},
+		openrtb_ext.BidderOpenx: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(openx.NewOpenxBidder(), client)
+		},
 		// TODO #214: Upgrade the Pubmatic adapter
-		openrtb_ext.BidderPubmatic: adaptLegacyAdapter(pubmatic.NewPubmaticAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["pubmatic"].Endpoint)),
+		openrtb_ext.BidderPubmatic: func(cfg config.Adapter) adaptedBidder {
+			return adaptLegacyAdapter(pubmatic.NewPubmaticAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint))
+		},
 		// TODO #215: Upgrade the Pulsepoint adapter
-		openrtb_ext.BidderPulsepoint: adaptLegacyAdapter(pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["pulsepoint"].Endpoint)),
-		openrtb_ext.BidderRubicon: adaptBidder(rubicon.NewRubiconBidder(client, cfg.Adapters["rubicon"].Endpoint, cfg.Adapters["rubicon"].XAPI.Username,
-			cfg.Adapters["rubicon"].XAPI.Password, cfg.Adapters["rubicon"].XAPI.Tracker), client),
-		openrtb_ext.BidderSomoaudience: adaptBidder(somoaudience.NewSomoaudienceBidder(), client),
-		openrtb_ext.BidderSovrn:        adaptBidder(sovrn.NewSovrnBidder(client, cfg.Adapters["sovrn"].Endpoint), client),
+		openrtb_ext.BidderPulsepoint: func(cfg config.Adapter) adaptedBidder {
+			return adaptLegacyAdapter(pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint))
+		},
+		openrtb_ext.BidderRubicon: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(rubicon.NewRubiconBidder(client, cfg.Endpoint, cfg.XAPI.Username, cfg.XAPI.Password, cfg.XAPI.Tracker), client)
+		},
+		openrtb_ext.BidderSomoaudience: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(somoaudience.NewSomoaudienceBidder(), client)
+		},
+		openrtb_ext.BidderSovrn: func(cfg config.Adapter) adaptedBidder {
+			return adaptBidder(sovrn.NewSovrnBidder(client, cfg.Endpoint), client)
+		},
+	}
+}
+
+// newAdapterMap builds an adaptedBidder for every bidder in the openrtb_ext.BidderMap, except the disabled ones.
+// The host's aliases are built by their core bidder's builder, using their own config.
+func newAdapterMap(client *http.Client, cfg *config.Configuration) map[openrtb_ext.BidderName]adaptedBidder {
+	proxyTimeout := time.Duration(cfg.AdapterProxy.ConnectTimeoutMillis) * time.Millisecond
+	if cfg.AdapterProxy.URL != "" {
+		proxyClient, err := withProxy(client, cfg.AdapterProxy.URL, proxyTimeout)
+		if err != nil {
+			logger.Fatalf("Failed to set up adapter_proxy: %v", err)
+		}
+		client = proxyClient
+	}
+	builders := newAdapterBuilders(client)
+	adapterMap := make(map[openrtb_ext.BidderName]adaptedBidder, len(openrtb_ext.BidderMap))
+	for _, bidderName := range openrtb_ext.BidderMap {
+		if build, ok := builders[openrtb_ext.CoreBidderName(bidderName)]; ok {
+			adapterCfg := adapterConfig(cfg, bidderName)
+			if adapterCfg.Disabled {
+				continue
+			}
+			adapted := build(adapterCfg)
+			if bidder, ok := adapted.(*bidderAdapter); ok {
+				bidder.headers = newBidderHeaders(adapterCfg)
+				bidder.retryConnectionErrors = adapterCfg.RetryConnectionErrors
+				bidder.maxImps = adapterCfg.MaxImpsPerRequest
+				bidder.maxResponseBytes = adapterCfg.MaxResponseBytes
+				bidder.mediaTypeRules = adapterCfg.MediaTypeInference
+				bidder.trimRequests = cfg.RequestTrimming.Enabled
+				if adapterCfg.ProxyURL != "" && adapterCfg.ProxyURL != cfg.AdapterProxy.URL {
+					proxyClient, err := withProxy(bidder.Client, adapterCfg.ProxyURL, proxyTimeout)
+					if err != nil {
+						logger.Fatalf("Failed to set up adapters.%s.proxy_url: %v", bidderName, err)
+					}
+					bidder.Client = proxyClient
+				}
+				if adapterCfg.ClientCertificate.CertFile != "" {
+					certClient, err := ssl.WithClientCertificate(bidder.Client, adapterCfg.ClientCertificate)
+					if err != nil {
+						logger.Fatalf("Failed to load adapters.%s.client_certificate: %v", bidderName, err)
+					}
+					bidder.Client = certClient
+				}
+			} else if len(adapterCfg.Headers) > 0 || len(adapterCfg.ForwardHeaders) > 0 || adapterCfg.RetryConnectionErrors || adapterCfg.MaxImpsPerRequest > 0 || adapterCfg.ClientCertificate.CertFile != "" || adapterCfg.ProxyURL != "" || len(adapterCfg.MediaTypeInference) > 0 || (adapterCfg.MaxResponseBytes > 0 && bidderName != openrtb_ext.BidderLifestreet) {
+				// Legacy adapters make their own HTTP calls, so these settings can't be applied to them.
+				logger.Warningf("adapters.%s.headers, forward_headers, retry_connection_errors, max_imps_per_request, client_certificate, proxy_url, media_type_inference and max_response_bytes are ignored, because it's a legacy adapter.", bidderName)
+			}
+			adapterMap[bidderName] = adapted
+		}
+	}
+	return adapterMap
+}
+
+// adapterConfig returns a bidder's config. Bidders are configured under their lowercased name,
+// except for Audience Network, which is still configured under "facebook".
+func adapterConfig(cfg *config.Configuration, bidderName openrtb_ext.BidderName) config.Adapter {
+	if bidderName == openrtb_ext.BidderFacebook {
+		return cfg.Adapters["facebook"]
 	}
+	return cfg.Adapters[strings.ToLower(string(bidderName))]
 }
@@ -15,3 +15,17 @@ func TestNewAdapterMap(t *testing.T) {
 		}
 	}
 }
+
+func TestDisabledAdapters(t *testing.T) {
+	adapterMap := newAdapterMap(nil, &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"appnexus": {Disabled: true},
+		},
+	})
+	if _, ok := adapterMap[openrtb_ext.BidderAppnexus]; ok {
+		t.Errorf("adapterMap shouldn't contain disabled Bidders.")
+	}
+	if _, ok := adapterMap[openrtb_ext.BidderRubicon]; !ok {
+		t.Errorf("adapterMap should contain the Bidders which aren't disabled.")
+	}
+}
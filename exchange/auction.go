@@ -2,33 +2,49 @@ package exchange
 
 import (
 	"context"
+	"sort"
+	"time"
 
-	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/prebid_cache_client"
 )
 
-func newAuction(seatBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, numImps int) *auction {
+// newAuction finds the winning bids in each imp, using the tieBreaker to settle bids with the same price.
+func newAuction(seatBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, numImps int, ties *tieBreaker) *auction {
 	winningBids := make(map[string]*pbsOrtbBid, numImps)
 	winningBidsByBidder := make(map[string]map[openrtb_ext.BidderName]*pbsOrtbBid, numImps)
+	winningEntries := make(map[string]tieEntry, numImps)
+	winningEntriesByBidder := make(map[string]map[openrtb_ext.BidderName]tieEntry, numImps)
 
-	for bidderName, seatBid := range seatBids {
+	// The bidders are visited in a fixed order so that seeded tie breaks are reproducible.
+	bidders := make([]openrtb_ext.BidderName, 0, len(seatBids))
+	for bidderName := range seatBids {
+		bidders = append(bidders, bidderName)
+	}
+	sort.Slice(bidders, func(i, j int) bool {
+		return bidders[i] < bidders[j]
+	})
+
+	for _, bidderName := range bidders {
+		seatBid := seatBids[bidderName]
 		if seatBid != nil {
-			for _, bid := range seatBid.bids {
-				cpm := bid.bid.Price
-				wbid, ok := winningBids[bid.bid.ImpID]
-				if !ok || cpm > wbid.bid.Price {
-					winningBids[bid.bid.ImpID] = bid
+			for index, bid := range seatBid.bids {
+				impID := bid.bid.ImpID
+				entry := ties.entry(bid, bidderName, seatBid, index)
+				if best, ok := winningEntries[impID]; !ok || ties.beats(entry, best) {
+					winningEntries[impID] = entry
+					winningBids[impID] = bid
 				}
-				if bidMap, ok := winningBidsByBidder[bid.bid.ImpID]; ok {
-					bestSoFar, ok := bidMap[bidderName]
-					if !ok || cpm > bestSoFar.bid.Price {
-						bidMap[bidderName] = bid
-					}
-				} else {
-					winningBidsByBidder[bid.bid.ImpID] = make(map[openrtb_ext.BidderName]*pbsOrtbBid)
-					winningBidsByBidder[bid.bid.ImpID][bidderName] = bid
+				if _, ok := winningEntriesByBidder[impID]; !ok {
+					winningEntriesByBidder[impID] = make(map[openrtb_ext.BidderName]tieEntry)
+					winningBidsByBidder[impID] = make(map[openrtb_ext.BidderName]*pbsOrtbBid)
+				}
+				if best, ok := winningEntriesByBidder[impID][bidderName]; !ok || ties.beats(entry, best) {
+					winningEntriesByBidder[impID][bidderName] = entry
+					winningBidsByBidder[impID][bidderName] = bid
 				}
 			}
 		}
@@ -46,7 +62,7 @@ func (a *auction) setRoundedPrices(priceGranularity openrtb_ext.PriceGranularity
 		for _, topBidPerBidder := range topBidsPerImp {
 			roundedPrice, err := GetCpmStringValue(topBidPerBidder.bid.Price, priceGranularity)
 			if err != nil {
-				glog.Errorf(`Error rounding price according to granularity. This shouldn't happen unless /openrtb2 input validation is buggy. Granularity was "%v".`, priceGranularity)
+				logger.Errorf(`Error rounding price according to granularity. This shouldn't happen unless /openrtb2 input validation is buggy. Granularity was "%v".`, priceGranularity)
 			}
 			roundedPrices[topBidPerBidder] = roundedPrice
 		}
@@ -54,16 +70,31 @@ func (a *auction) setRoundedPrices(priceGranularity openrtb_ext.PriceGranularity
 	a.roundedPrices = roundedPrices
 }
 
-func (a *auction) doCache(ctx context.Context, cache prebid_cache_client.Client) {
+func (a *auction) doCache(ctx context.Context, cache prebid_cache_client.Client, batchSize int, ttl *config.CacheTTL, account string) {
+	bids, ttls := a.bidsToCache(ttl, account)
+	a.cacheIds = cacheBids(ctx, cache, bids, ttls, batchSize)
+}
+
+// doCacheAsync assigns the cache IDs right away, and saves the bids in the background.
+// If the save takes longer than the timeout, the IDs in the response won't point to anything.
+func (a *auction) doCacheAsync(cache prebid_cache_client.KeyedClient, batchSize int, timeout time.Duration, ttl *config.CacheTTL, account string) {
+	bids, ttls := a.bidsToCache(ttl, account)
+	a.cacheIds = cacheBidsAsync(cache, bids, ttls, batchSize, timeout)
+}
+
+// bidsToCache returns the bids which should be cached, and the number of seconds each one should live.
+// Bids which set bid.exp live that long. The others get the default for their media type.
+func (a *auction) bidsToCache(ttl *config.CacheTTL, account string) ([]*openrtb.Bid, []int64) {
 	toCache := make([]*openrtb.Bid, 0, len(a.roundedPrices))
+	ttls := make([]int64, 0, len(a.roundedPrices))
 
 	for _, topBidsPerImp := range a.winningBidsByBidder {
 		for _, topBidPerBidder := range topBidsPerImp {
 			toCache = append(toCache, topBidPerBidder.bid)
+			ttls = append(ttls, ttl.SecondsFor(account, string(topBidPerBidder.bidType), int64(topBidPerBidder.bid.Exp)))
 		}
 	}
-
-	a.cacheIds = cacheBids(ctx, cache, toCache)
+	return toCache, ttls
 }
 
 type auction struct {
@@ -0,0 +1,187 @@
+package exchange
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// DedupingExchange is an Exchange which answers repeats of a recent auction request with the first one's response.
+// Requests are repeats if they have the same account, ID, contents and user IDs. If the first auction is still running,
+// the repeats wait for it to finish. It runs until its deadline even if the first request is cancelled, since the
+// repeats need its response.
+type DedupingExchange struct {
+	exchange   Exchange
+	window     time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*dedupedAuction
+	// order has the entries in the order they were added, which is also the order they expire in.
+	order []dedupedKey
+}
+
+// dedupedAuction is a recent auction. response and err are set once done is closed.
+type dedupedAuction struct {
+	expires  time.Time
+	done     chan struct{}
+	response json.RawMessage
+	err      error
+}
+
+type dedupedKey struct {
+	key   string
+	entry *dedupedAuction
+}
+
+// NewDedupingExchange returns an Exchange which holds its auctions with ex, and dedupes them according to cfg.
+func NewDedupingExchange(ex Exchange, cfg config.AuctionDedup) *DedupingExchange {
+	return &DedupingExchange{
+		exchange:   ex,
+		window:     time.Duration(cfg.WindowMillis) * time.Millisecond,
+		maxEntries: cfg.MaxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*dedupedAuction),
+	}
+}
+
+func (e *DedupingExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	// The key is made first, since the auction may change the request.
+	key, ok := auctionKey(bidRequest, usersyncs)
+	if !ok {
+		return e.exchange.HoldAuction(ctx, bidRequest, usersyncs, labels)
+	}
+
+	e.lock.Lock()
+	now := e.now()
+	e.expire(now)
+	if entry, ok := e.entries[key]; ok {
+		e.lock.Unlock()
+		return entry.wait(ctx)
+	}
+	if len(e.entries) >= e.maxEntries {
+		e.lock.Unlock()
+		return e.exchange.HoldAuction(ctx, bidRequest, usersyncs, labels)
+	}
+	entry := &dedupedAuction{
+		expires: now.Add(e.window),
+		done:    make(chan struct{}),
+	}
+	e.entries[key] = entry
+	e.order = append(e.order, dedupedKey{key, entry})
+	e.lock.Unlock()
+
+	// The repeats share this auction, so it can't stop early just because this request gave up on it.
+	auctionCtx, cancel := detach(ctx, bidRequest.TMax)
+	defer cancel()
+	response, err := e.exchange.HoldAuction(auctionCtx, bidRequest, usersyncs, labels)
+	entry.err = err
+	if err == nil {
+		entry.response, entry.err = json.Marshal(response)
+	}
+	close(entry.done)
+	if entry.err != nil {
+		// Failed auctions aren't kept, so that the publisher can retry them.
+		e.lock.Lock()
+		if e.entries[key] == entry {
+			delete(e.entries, key)
+		}
+		e.lock.Unlock()
+	}
+	return response, err
+}
+
+// detach returns a context with the values from ctx, but not its cancellation. It has the same deadline as ctx, or
+// tmax milliseconds from now if ctx doesn't have one, so the auction still ends on time.
+func detach(ctx context.Context, tmax int64) (context.Context, context.CancelFunc) {
+	detached := valuesContext{ctx}
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	if tmax > 0 {
+		return context.WithTimeout(detached, time.Duration(tmax)*time.Millisecond)
+	}
+	return context.WithCancel(detached)
+}
+
+// valuesContext has the values of the context it wraps, but is never done.
+type valuesContext struct {
+	context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (valuesContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (valuesContext) Err() error {
+	return nil
+}
+
+// expire removes the entries which expired by now. It must be called with the lock held.
+func (e *DedupingExchange) expire(now time.Time) {
+	for len(e.order) > 0 && !now.Before(e.order[0].entry.expires) {
+		if e.entries[e.order[0].key] == e.order[0].entry {
+			delete(e.entries, e.order[0].key)
+		}
+		e.order = e.order[1:]
+	}
+}
+
+// wait returns a copy of the auction's response once it's done. The copy is made from JSON, so that the endpoints
+// can change it without affecting the other requests which get it.
+func (entry *dedupedAuction) wait(ctx context.Context) (*openrtb.BidResponse, error) {
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	var response openrtb.BidResponse
+	if err := json.Unmarshal(entry.response, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// auctionKey identifies the requests which are repeats of each other. Requests without an ID are never deduped.
+// The uids from the cookie are part of the key, since the bidders get them as user.buyeruid. Otherwise, one user
+// could be sent the bids which were made for another.
+func auctionKey(bidRequest *openrtb.BidRequest, usersyncs IdFetcher) (string, bool) {
+	if bidRequest.ID == "" {
+		return "", false
+	}
+	requestJSON, err := json.Marshal(bidRequest)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.New()
+	hash.Write(requestJSON)
+	if usersyncs != nil {
+		bidders := openrtb_ext.BidderList()
+		sort.Slice(bidders, func(i, j int) bool {
+			return bidders[i] < bidders[j]
+		})
+		for _, bidder := range bidders {
+			if uid, ok := usersyncs.GetId(bidder); ok {
+				fmt.Fprintf(hash, "\x00%s=%s", bidder, uid)
+			}
+		}
+	}
+	return accountID(bidRequest) + "\x00" + bidRequest.ID + "\x00" + string(hash.Sum(nil)), true
+}
@@ -0,0 +1,197 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+func TestDedupingExchange(t *testing.T) {
+	inner := &countingExchange{}
+	ex := NewDedupingExchange(inner, config.AuctionDedup{Enabled: true, WindowMillis: 2000, MaxEntries: 10})
+	now := time.Unix(1000, 0)
+	ex.now = func() time.Time { return now }
+
+	first := holdDedupedAuction(t, ex, newDedupedRequest("req", "1001"))
+	first.Ext = openrtb.RawJSON(`{"warnings":{}}`)
+	repeat := holdDedupedAuction(t, ex, newDedupedRequest("req", "1001"))
+	if inner.calls != 1 {
+		t.Errorf("A repeated request should get the first one's response. Got %d auctions", inner.calls)
+	}
+	if repeat == first || repeat.ID != "response-1" || repeat.Ext != nil {
+		t.Errorf("The repeat should get its own copy of the response. Got %v", repeat)
+	}
+
+	holdDedupedAuction(t, ex, newDedupedRequest("req", "1002"))
+	holdDedupedAuction(t, ex, newDedupedRequest("other", "1001"))
+	changed := newDedupedRequest("req", "1001")
+	changed.Imp[0].ID = "imp-2"
+	holdDedupedAuction(t, ex, changed)
+	if inner.calls != 4 {
+		t.Errorf("Requests from other accounts, or with other IDs or contents, shouldn't be deduped. Got %d auctions", inner.calls)
+	}
+
+	otherUser := mockIdFetcher{"appnexus": "other-user"}
+	if _, err := ex.HoldAuction(context.Background(), newDedupedRequest("req", "1001"), otherUser, pbsmetrics.Labels{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if inner.calls != 5 {
+		t.Errorf("Requests from users with other uids shouldn't be deduped. Got %d auctions", inner.calls)
+	}
+
+	now = now.Add(2 * time.Second)
+	holdDedupedAuction(t, ex, newDedupedRequest("req", "1001"))
+	if inner.calls != 6 {
+		t.Errorf("Requests shouldn't be deduped after the window. Got %d auctions", inner.calls)
+	}
+	holdDedupedAuction(t, ex, newDedupedRequest("", "1001"))
+	holdDedupedAuction(t, ex, newDedupedRequest("", "1001"))
+	if inner.calls != 8 {
+		t.Errorf("Requests without an ID shouldn't be deduped. Got %d auctions", inner.calls)
+	}
+}
+
+func TestDedupingExchangeConcurrent(t *testing.T) {
+	inner := &countingExchange{delay: 50 * time.Millisecond}
+	ex := NewDedupingExchange(inner, config.AuctionDedup{Enabled: true, WindowMillis: 2000, MaxEntries: 10})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ex.HoldAuction(context.Background(), newDedupedRequest("req", "1001"), nil, pbsmetrics.Labels{}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if inner.calls != 1 {
+		t.Errorf("Repeats should wait for the first auction, instead of running their own. Got %d auctions", inner.calls)
+	}
+}
+
+func TestDedupingExchangeCancelled(t *testing.T) {
+	inner := &countingExchange{delay: 50 * time.Millisecond}
+	ex := NewDedupingExchange(inner, config.AuctionDedup{Enabled: true, WindowMillis: 2000, MaxEntries: 10})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	type result struct {
+		response *openrtb.BidResponse
+		err      error
+	}
+	first := make(chan result)
+	go func() {
+		response, err := ex.HoldAuction(ctx, newDedupedRequest("req", "1001"), nil, pbsmetrics.Labels{})
+		first <- result{response, err}
+	}()
+	for atomic.LoadInt32(&inner.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	repeat, err := ex.HoldAuction(context.Background(), newDedupedRequest("req", "1001"), nil, pbsmetrics.Labels{})
+	if err != nil || repeat.ID != "response-1" {
+		t.Errorf("Repeats shouldn't fail because the first request was cancelled. Got %v, %v", repeat, err)
+	}
+	if firstResult := <-first; firstResult.err != nil {
+		t.Errorf("The first request's auction should have run to the end. Got %v", firstResult.err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("The repeat should have waited for the first auction. Got %d auctions", inner.calls)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "value"), time.Minute)
+	cancel()
+	detached, cancelDetached := detach(parent, 500)
+	defer cancelDetached()
+	if detached.Err() != nil || detached.Value(key{}) != "value" {
+		t.Errorf("The detached context should keep the values, but not the cancellation. Got %v", detached.Err())
+	}
+	parentDeadline, _ := parent.Deadline()
+	if deadline, ok := detached.Deadline(); !ok || !deadline.Equal(parentDeadline) {
+		t.Errorf("The detached context should keep the deadline. Got %v", deadline)
+	}
+
+	start := time.Now()
+	detached, cancelDetached = detach(context.Background(), 500)
+	defer cancelDetached()
+	if deadline, ok := detached.Deadline(); !ok || deadline.Before(start.Add(500*time.Millisecond)) || deadline.After(time.Now().Add(500*time.Millisecond)) {
+		t.Errorf("Contexts without a deadline should get one from tmax. Got %v", deadline)
+	}
+}
+
+func TestDedupingExchangeErrors(t *testing.T) {
+	inner := &countingExchange{err: errors.New("auction failed")}
+	ex := NewDedupingExchange(inner, config.AuctionDedup{Enabled: true, WindowMillis: 2000, MaxEntries: 10})
+	for i := 0; i < 2; i++ {
+		if _, err := ex.HoldAuction(context.Background(), newDedupedRequest("req", "1001"), nil, pbsmetrics.Labels{}); err == nil {
+			t.Errorf("The auction's error should be returned.")
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("Failed auctions shouldn't be deduped, so that they can be retried. Got %d auctions", inner.calls)
+	}
+}
+
+func TestDedupingExchangeMaxEntries(t *testing.T) {
+	inner := &countingExchange{}
+	ex := NewDedupingExchange(inner, config.AuctionDedup{Enabled: true, WindowMillis: 2000, MaxEntries: 1})
+	holdDedupedAuction(t, ex, newDedupedRequest("first", "1001"))
+	holdDedupedAuction(t, ex, newDedupedRequest("second", "1001"))
+	holdDedupedAuction(t, ex, newDedupedRequest("second", "1001"))
+	if inner.calls != 3 {
+		t.Errorf("Requests which arrive while the entries are full shouldn't be deduped. Got %d auctions", inner.calls)
+	}
+}
+
+func holdDedupedAuction(t *testing.T, ex Exchange, bidRequest *openrtb.BidRequest) *openrtb.BidResponse {
+	t.Helper()
+	response, err := ex.HoldAuction(context.Background(), bidRequest, nil, pbsmetrics.Labels{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return response
+}
+
+func newDedupedRequest(id string, account string) *openrtb.BidRequest {
+	return &openrtb.BidRequest{
+		ID:  id,
+		Imp: []openrtb.Imp{{ID: "imp-1"}},
+		Site: &openrtb.Site{
+			Publisher: &openrtb.Publisher{ID: account},
+		},
+	}
+}
+
+// countingExchange counts its auctions, and gives each one a response with a new ID. Auctions which are cancelled
+// during the delay fail.
+type countingExchange struct {
+	calls int32
+	delay time.Duration
+	err   error
+}
+
+func (e *countingExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	call := atomic.AddInt32(&e.calls, 1)
+	select {
+	case <-time.After(e.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &openrtb.BidResponse{ID: "response-" + strconv.Itoa(int(call))}, nil
+}
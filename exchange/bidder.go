@@ -3,13 +3,21 @@ package exchange
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"syscall"
 
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/tracing"
 	"golang.org/x/net/context/ctxhttp"
 )
 
@@ -35,7 +43,9 @@ type adaptedBidder interface {
 	//
 	// Any errors will be user-facing in the API.
 	// Error messages should help publishers understand what might account for "bad" bids.
-	requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64) (*pbsOrtbSeatBid, []error)
+	//
+	// Bid prices should be multiplied by the bidAdjustment, and converted into the request's currency using the conversions.
+	requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error)
 }
 
 // pbsOrtbBid is a Bid returned by an adaptedBidder.
@@ -43,10 +53,24 @@ type adaptedBidder interface {
 // pbsOrtbBid.bid.Ext will become "response.seatbid[i].bid.ext.bidder" in the final OpenRTB response.
 // pbsOrtbBid.bidType will become "response.seatbid[i].bid.ext.prebid.type" in the final OpenRTB response.
 // pbsOrtbBid.bidTargets does not need to be filled out by the Bidder. It will be set later by the exchange.
+//
+// pbsOrtbBid.bid.Price is in the request's currency. If the Bidder bid in a different one, originalCurrency and
+// originalPrice hold the price it bid (after any bid adjustment) before it was converted.
+//
+// pbsOrtbBid.bidVideo and pbsOrtbBid.dealPriority are optional, and are used to build the hb_pb_cat_dur targeting key.
 type pbsOrtbBid struct {
-	bid        *openrtb.Bid
-	bidType    openrtb_ext.BidType
-	bidTargets map[string]string
+	bid              *openrtb.Bid
+	bidType          openrtb_ext.BidType
+	bidTargets       map[string]string
+	bidVideo         *openrtb_ext.ExtBidPrebidVideo
+	bidMeta          *openrtb_ext.ExtBidPrebidMeta
+	dealPriority     int
+	originalCurrency string
+	originalPrice    float64
+	// grossPrice is the price before the host's revenue share was deducted. It's 0 if there isn't one.
+	grossPrice float64
+	// scan is the creative scanner's verdict, if it marked the creative as suspicious.
+	scan *openrtb_ext.ExtBidPrebidScan
 }
 
 // pbsOrtbSeatBid is a SeatBid returned by an adaptedBidder.
@@ -62,6 +86,16 @@ type pbsOrtbSeatBid struct {
 	// if len(bids) > 0, this will become response.seatbid[i].ext.{bidder} on the final OpenRTB response.
 	// if len(bids) == 0, this will be ignored because the OpenRTB spec doesn't allow a SeatBid with 0 Bids.
 	ext openrtb.RawJSON
+	// igi are the Protected Audience interest group signals for this seat.
+	// These will become part of response.ext.igi on the final OpenRTB response, even if len(bids) == 0.
+	igi []*openrtb_ext.ExtIGI
+	// retries has the result of each HTTP call which was retried after a connection error.
+	retries []pbsmetrics.AdapterRetryResult
+	// timings has the network timings of each HTTP call which the bidder made.
+	timings []*httpTimings
+	// arrival is the order in which the exchange received this seat's response, starting from 0.
+	// It's set by the exchange, rather than the adaptedBidder.
+	arrival int
 }
 
 // adaptBidder converts an adapters.Bidder into an exchange.adaptedBidder.
@@ -69,23 +103,58 @@ type pbsOrtbSeatBid struct {
 // The name refers to the "Adapter" architecture pattern, and should not be confused with a Prebid "Adapter"
 // (which is being phased out and replaced by Bidder for OpenRTB auctions)
 func adaptBidder(bidder adapters.Bidder, client *http.Client) adaptedBidder {
-	return &bidderAdapter{
+	adapted := &bidderAdapter{
 		Bidder: bidder,
 		Client: client,
 	}
+	if splitter, ok := bidder.(adapters.RequestSplitter); ok {
+		adapted.split = splitter.RequestSplit()
+	}
+	if versioner, ok := bidder.(adapters.OpenRTBVersioner); ok {
+		adapted.ortbVersion = versioner.OpenRTBVersion()
+	}
+	return adapted
 }
 
 type bidderAdapter struct {
 	Bidder adapters.Bidder
 	Client *http.Client
+	// headers are added to each request, on top of the ones the Bidder set. This is nil if the host didn't configure any.
+	headers *bidderHeaders
+	// retryConnectionErrors makes doRequest retry calls which failed because the connection was refused or reset.
+	retryConnectionErrors bool
+	// split says how the Bidder wants requests divided before they're passed to it.
+	split adapters.RequestSplit
+	// ortbVersion is the version of OpenRTB which the Bidder's server expects. It's empty if the Bidder didn't say, which means 2.5.
+	ortbVersion adapters.OpenRTBVersion
+	// maxImps is the most Imps which the Bidder's server accepts in one request, or 0 if there's no limit.
+	maxImps int
+	// maxResponseBytes is the largest response body which is read from the Bidder's server, or 0 if there's no limit.
+	maxResponseBytes int
+	// mediaTypeRules are used to correct the media types which the Bidder gave its bids. See adapters.InferMediaType.
+	mediaTypeRules []string
+	// capture keeps the responses which the Bidder couldn't make bids from without errors. It's nil if they aren't captured.
+	capture *responseRing
+	// trimRequests strips the empty fields from the bodies which the Bidder built.
+	trimRequests bool
 }
 
-func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64) (*pbsOrtbSeatBid, []error) {
-	reqData, errs := bidder.Bidder.MakeRequests(request)
+func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	reqData, errs := bidder.makeRequests(request)
 
 	if len(reqData) == 0 {
 		return nil, errs
 	}
+	for _, oneReqData := range reqData {
+		oneReqData.Headers = bidder.headers.apply(oneReqData.Headers, request.Device)
+	}
+	if bidder.ortbVersion == adapters.OpenRTB26 {
+		errs = append(errs, upgradeRequests(reqData)...)
+	}
+	// This comes after the upgrade, which can leave exts empty when it moves their fields.
+	if bidder.trimRequests {
+		trimRequests(reqData)
+	}
 
 	// Make any HTTP requests in parallel.
 	// If the bidder only needs to make one, save some cycles by just using the current one.
@@ -109,25 +178,55 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 	// even if the timeout occurs sometime halfway through.
 	for i := 0; i < len(reqData); i++ {
 		httpInfo := <-responseChannel
+		if httpInfo.retry != "" {
+			seatBid.retries = append(seatBid.retries, httpInfo.retry)
+		}
+		if httpInfo.timings != nil {
+			seatBid.timings = append(seatBid.timings, httpInfo.timings)
+		}
+		snapshotCallsFrom(ctx).add(name, httpInfo)
 		// If this is a test bid, capture debugging info from the requests.
-		if request.Test == 1 {
+		if request.Test == 1 && debugAllowed(ctx) {
 			seatBid.httpCalls = append(seatBid.httpCalls, makeExt(httpInfo))
 		}
 
 		if httpInfo.err == nil {
 			bidResponse, moreErrs := bidder.Bidder.MakeBids(request, httpInfo.request, httpInfo.response)
 			errs = append(errs, moreErrs...)
+			if len(moreErrs) > 0 {
+				bidder.capture.add(httpInfo.request, httpInfo.response, moreErrs)
+			}
 			if bidResponse != nil {
-				for i := 0; i < len(bidResponse.Bids); i++ {
-					if bidResponse.Bids[i].Bid != nil {
-						// TODO #280: Convert the bid price
-						bidResponse.Bids[i].Bid.Price = bidResponse.Bids[i].Bid.Price * bidAdjustment
+				if bidder.ortbVersion == adapters.OpenRTB26 {
+					addMTypes(bidResponse, httpInfo.response.Body)
+				}
+				// The rate is only looked up if there are bids to convert, since every lookup counts as a conversion in the metrics.
+				if len(bidResponse.Bids) > 0 {
+					// If the bids can't be converted into the request's currency, they can't compete with the others.
+					bidCurrency := responseCurrency(bidResponse)
+					conversionRate, err := conversions.GetRate(bidCurrency, requestCurrency(request))
+					if err != nil {
+						errs = append(errs, fmt.Errorf("Dropped %d bid(s) which couldn't be converted into %s: %v", len(bidResponse.Bids), requestCurrency(request), err))
+					} else {
+						for i := 0; i < len(bidResponse.Bids); i++ {
+							var originalPrice float64
+							if bidResponse.Bids[i].Bid != nil {
+								originalPrice = bidResponse.Bids[i].Bid.Price * bidAdjustment
+								bidResponse.Bids[i].Bid.Price = originalPrice * conversionRate
+							}
+							seatBid.bids = append(seatBid.bids, &pbsOrtbBid{
+								bid:              bidResponse.Bids[i].Bid,
+								bidType:          bidder.mediaType(bidResponse.Bids[i], request),
+								bidVideo:         bidResponse.Bids[i].BidVideo,
+								bidMeta:          bidResponse.Bids[i].BidMeta,
+								dealPriority:     bidResponse.Bids[i].DealPriority,
+								originalCurrency: bidCurrency,
+								originalPrice:    originalPrice,
+							})
+						}
 					}
-					seatBid.bids = append(seatBid.bids, &pbsOrtbBid{
-						bid:     bidResponse.Bids[i].Bid,
-						bidType: bidResponse.Bids[i].BidType,
-					})
 				}
+				seatBid.igi = append(seatBid.igi, bidResponse.IGI...)
 			}
 		} else {
 			errs = append(errs, httpInfo.err)
@@ -137,6 +236,89 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 	return seatBid, errs
 }
 
+// upgradeRequests moves the fields which OpenRTB 2.6 took out of ext to their 2.6 places in the request bodies.
+// Bodies which can't be upgraded are sent as they are, with a warning.
+func upgradeRequests(reqData []*adapters.RequestData) []error {
+	var errs []error
+	for _, oneReqData := range reqData {
+		if body, _, err := adapters.UpgradeRequestTo26(oneReqData.Body); err != nil {
+			errs = append(errs, adapters.Warning(fmt.Sprintf("The request to %s couldn't be upgraded to OpenRTB 2.6: %v", oneReqData.Uri, err)))
+		} else {
+			oneReqData.Body = body
+		}
+	}
+	return errs
+}
+
+// addMTypes fills in the mtypes which an OpenRTB 2.6 Bidder didn't take from its server's response, since the
+// OpenRTB structs which it unmarshalled the response into don't have the field.
+func addMTypes(bidResponse *adapters.BidderResponse, body []byte) {
+	var mtypes map[string]int
+	for _, typedBid := range bidResponse.Bids {
+		if typedBid.MType != 0 || typedBid.Bid == nil {
+			continue
+		}
+		if mtypes == nil {
+			if mtypes = adapters.ReadMTypes(body); mtypes == nil {
+				return
+			}
+		}
+		typedBid.MType = mtypes[typedBid.Bid.ID]
+	}
+}
+
+// requestCurrency returns the currency which the request wants bids in. OpenRTB says that's USD if it doesn't specify.
+func requestCurrency(request *openrtb.BidRequest) string {
+	if len(request.Cur) > 0 {
+		return request.Cur[0]
+	}
+	return "USD"
+}
+
+// responseCurrency returns the currency of the bidder's bids. Bidders which don't specify one are assumed to bid in USD.
+func responseCurrency(response *adapters.BidderResponse) string {
+	if response.Currency != "" {
+		return response.Currency
+	}
+	return "USD"
+}
+
+// mediaType returns the media type of the bid. If the host configured any rules for this Bidder and one of them
+// can tell, it overrides the type which the Bidder gave the bid.
+func (bidder *bidderAdapter) mediaType(bid *adapters.TypedBid, request *openrtb.BidRequest) openrtb_ext.BidType {
+	if len(bidder.mediaTypeRules) == 0 || bid.Bid == nil {
+		return bid.BidType
+	}
+	var imp *openrtb.Imp
+	for i := range request.Imp {
+		if request.Imp[i].ID == bid.Bid.ImpID {
+			imp = &request.Imp[i]
+			break
+		}
+	}
+	if bidType, ok := adapters.InferMediaType(bid, imp, bidder.mediaTypeRules); ok {
+		return bidType
+	}
+	return bid.BidType
+}
+
+// makeRequests calls the Bidder's MakeRequests once for each piece of the request, as split by splitRequest,
+// and combines the HTTP requests made for all of them.
+func (bidder *bidderAdapter) makeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	pieces := splitRequest(request, bidder.split, bidder.maxImps)
+	if len(pieces) == 1 {
+		return bidder.Bidder.MakeRequests(pieces[0])
+	}
+	var reqData []*adapters.RequestData
+	var errs []error
+	for _, piece := range pieces {
+		pieceData, pieceErrs := bidder.Bidder.MakeRequests(piece)
+		reqData = append(reqData, pieceData...)
+		errs = append(errs, pieceErrs...)
+	}
+	return reqData, errs
+}
+
 // makeExt transforms information about the HTTP call into the contract class for the PBS response.
 func makeExt(httpInfo *httpCallInfo) *openrtb_ext.ExtHttpCall {
 	if httpInfo.err == nil {
@@ -157,37 +339,51 @@ func makeExt(httpInfo *httpCallInfo) *openrtb_ext.ExtHttpCall {
 }
 
 // doRequest makes a request, handles the response, and returns the data needed by the
-// Bidder interface.
+// Bidder interface. If the auction shares identical calls, the response may come from another bidder's call.
+// If the auction is a replay, it comes from the snapshot instead, and nothing is sent.
 func (bidder *bidderAdapter) doRequest(ctx context.Context, req *adapters.RequestData) *httpCallInfo {
-	httpReq, err := http.NewRequest(req.Method, req.Uri, bytes.NewBuffer(req.Body))
-	if err != nil {
-		return &httpCallInfo{
-			request: req,
-			err:     err,
-		}
+	if replay := replayerFrom(ctx); replay != nil {
+		return replay.do(req)
 	}
-	httpReq.Header = req.Headers
+	return callDedupFrom(ctx).do(bidder.Client, req, func() *httpCallInfo {
+		return bidder.doUniqueRequest(ctx, req)
+	})
+}
 
-	httpResp, err := ctxhttp.Do(ctx, bidder.Client, httpReq)
+// doUniqueRequest makes the HTTP call for doRequest.
+func (bidder *bidderAdapter) doUniqueRequest(ctx context.Context, req *adapters.RequestData) *httpCallInfo {
+	timings := newHTTPTimings()
+	httpResp, err := bidder.send(ctx, req, timings)
+	var retry pbsmetrics.AdapterRetryResult
+	if err != nil && bidder.retryConnectionErrors && isConnectionError(err) {
+		retry = pbsmetrics.AdapterRetryFailure
+		if ctx.Err() == nil {
+			// Only the retry's timings are kept, since it's the attempt which the response came from.
+			timings = newHTTPTimings()
+			if httpResp, err = bidder.send(ctx, req, timings); err == nil {
+				retry = pbsmetrics.AdapterRetrySuccess
+			}
+		}
+	}
 	if err != nil {
+		if certErr := certificateError(err); certErr != nil {
+			err = certErr
+		}
 		return &httpCallInfo{
 			request: req,
 			err:     err,
+			retry:   retry,
+			timings: timings,
 		}
 	}
 
-	respBody, err := ioutil.ReadAll(httpResp.Body)
+	defer httpResp.Body.Close()
+	respBody, err := adapters.ReadResponseBody(httpResp, bidder.maxResponseBytes)
 	if err != nil {
 		return &httpCallInfo{
 			request: req,
 			err:     err,
-		}
-	}
-	defer httpResp.Body.Close()
-
-	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 400 {
-		err = &adapters.BadServerResponseError{
-			Message: fmt.Sprintf("Server responded with failure status: %d. Set request.test = 1 for debugging info.", httpResp.StatusCode),
+			timings: timings,
 		}
 	}
 
@@ -198,12 +394,92 @@ func (bidder *bidderAdapter) doRequest(ctx context.Context, req *adapters.Reques
 			Body:       respBody,
 			Headers:    httpResp.Header,
 		},
-		err: err,
+		err:     statusError(httpResp.StatusCode),
+		retry:   retry,
+		timings: timings,
+	}
+}
+
+// statusError returns the error for a response with a failure status, or nil if the status is a success.
+func statusError(status int) error {
+	if status < 200 || status >= 400 {
+		return &adapters.BadServerResponseError{
+			Message: fmt.Sprintf("Server responded with failure status: %d. Set request.test = 1 for debugging info.", status),
+		}
+	}
+	return nil
+}
+
+// send makes a single attempt at the HTTP call, and fills in its timings. The request is rebuilt each time,
+// because sending it consumes its body.
+func (bidder *bidderAdapter) send(ctx context.Context, req *adapters.RequestData, timings *httpTimings) (*http.Response, error) {
+	httpReq, err := http.NewRequest(req.Method, req.Uri, bytes.NewBuffer(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header = req.Headers
+
+	ctx, span := tracing.StartSpan(ctx, "bidder.http",
+		tracing.String("http.method", req.Method),
+		tracing.String("net.peer.name", httpReq.URL.Hostname()))
+	ctx = httptrace.WithClientTrace(ctx, timings.clientTrace())
+	httpResp, err := ctxhttp.Do(ctx, bidder.Client, httpReq)
+	if err == nil {
+		span.SetAttributes(tracing.Int("http.status_code", httpResp.StatusCode))
+	}
+	tracing.EndSpan(span, err)
+	return httpResp, err
+}
+
+// certificateError returns a clearer error if the call failed because the bidder's server presented a TLS certificate
+// which couldn't be verified, or nil if it failed for some other reason.
+func certificateError(err error) error {
+	for cause := err; cause != nil; {
+		switch typed := cause.(type) {
+		case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError:
+			return &adapters.BadServerResponseError{
+				Message: fmt.Sprintf("The bidder's server presented an invalid TLS certificate, so the call was rejected: %v", err),
+			}
+		case *url.Error:
+			cause = typed.Err
+		case wrappedError:
+			// Newer versions of crypto/tls wrap the x509 errors.
+			cause = typed.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// wrappedError is an error which wraps another one.
+type wrappedError interface {
+	Unwrap() error
+}
+
+// isConnectionError returns true if the call failed because the bidder's server refused or reset the connection.
+// Timeouts don't count, since a retry would rarely finish before the auction's deadline.
+func isConnectionError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	if !ok || opErr.Timeout() {
+		return false
+	}
+	err = opErr.Err
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
 	}
+	return err == syscall.ECONNREFUSED || err == syscall.ECONNRESET
 }
 
 type httpCallInfo struct {
 	request  *adapters.RequestData
 	response *adapters.ResponseData
 	err      error
+	// retry is the result of retrying the call after a connection error, or empty if it wasn't retried.
+	retry pbsmetrics.AdapterRetryResult
+	// timings are the network timings of the call, or nil if it was shared with another bidder's.
+	timings *httpTimings
 }
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
 )
 
 // TestSingleBidder makes sure that the following things work if the Bidder needs only one request.
@@ -56,7 +59,7 @@ func TestSingleBidder(t *testing.T) {
 		bidResponse: mockBidderResponse,
 	}
 	bidder := adaptBidder(bidderImpl, server.Client())
-	seatBid, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "test", bidAdjustment)
+	seatBid, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "test", bidAdjustment, currencies.NewConstantRates())
 
 	// Make sure the goodSingleBidder was called with the expected arguments.
 	if bidderImpl.httpResponse == nil {
@@ -140,7 +143,7 @@ func TestMultiBidder(t *testing.T) {
 		bidResponse: mockBidderResponse,
 	}
 	bidder := adaptBidder(bidderImpl, server.Client())
-	seatBid, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "test", 1.0)
+	seatBid, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "test", 1.0, currencies.NewConstantRates())
 
 	if seatBid == nil {
 		t.Fatalf("SeatBid should exist, because bids exist.")
@@ -230,6 +233,113 @@ func TestConnectionClose(t *testing.T) {
 	}
 }
 
+// TestResponseTooLarge makes sure that bidderAdapter.doRequest drops responses over the bidder's max_response_bytes.
+func TestResponseTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"a-response-which-is-too-large"}`))
+	}))
+	defer server.Close()
+
+	bidder := &bidderAdapter{
+		Bidder:           &mixedMultiBidder{},
+		Client:           server.Client(),
+		maxResponseBytes: 10,
+	}
+	callInfo := bidder.doRequest(context.Background(), &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	})
+	if _, ok := callInfo.err.(*adapters.ResponseTooLargeError); !ok {
+		t.Errorf("Responses over the limit should return a ResponseTooLargeError. Got %v", callInfo.err)
+	}
+	if _, ok := errorsToMetric([]error{callInfo.err})[pbsmetrics.AdapterErrorResponseTooLarge]; !ok {
+		t.Errorf("Responses over the limit should be recorded in the %s error metric.", pbsmetrics.AdapterErrorResponseTooLarge)
+	}
+
+	bidder.maxResponseBytes = 0
+	callInfo = bidder.doRequest(context.Background(), &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	})
+	if callInfo.err != nil {
+		t.Errorf("Responses shouldn't be dropped if there's no limit. Got %v", callInfo.err)
+	}
+}
+
+// TestConnectionRefusedRetry makes sure that bidderAdapter.doRequest retries refused connections once, if it's configured to.
+func TestConnectionRefusedRetry(t *testing.T) {
+	server := httptest.NewServer(mockHandler(200, "getBody", "postBody"))
+	// Closing the server makes its address refuse connections.
+	server.Close()
+
+	bidder := &bidderAdapter{
+		Bidder: &mixedMultiBidder{},
+		Client: server.Client(),
+	}
+	reqData := &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	}
+
+	callInfo := bidder.doRequest(context.Background(), reqData)
+	if !isConnectionError(callInfo.err) {
+		t.Fatalf("A closed server should refuse the connection. Got %v", callInfo.err)
+	}
+	if callInfo.retry != "" {
+		t.Errorf("bidderAdapter.doRequest shouldn't retry unless it's configured to. Got %s", callInfo.retry)
+	}
+
+	bidder.retryConnectionErrors = true
+	callInfo = bidder.doRequest(context.Background(), reqData)
+	if callInfo.retry != pbsmetrics.AdapterRetryFailure {
+		t.Errorf("The retry should fail, since the server is still closed. Got %s", callInfo.retry)
+	}
+}
+
+// TestInvalidCertificate makes sure that calls to servers with untrusted certificates fail with a clear error.
+func TestInvalidCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(mockHandler(200, "getBody", "postBody"))
+	defer server.Close()
+
+	bidder := &bidderAdapter{
+		Bidder: &mixedMultiBidder{},
+		// The default client doesn't trust the test server's self-signed certificate.
+		Client: &http.Client{},
+	}
+	callInfo := bidder.doRequest(context.Background(), &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	})
+	if _, ok := callInfo.err.(*adapters.BadServerResponseError); !ok {
+		t.Errorf("Calls to servers with invalid certificates should return a BadServerResponseError. Got %v", callInfo.err)
+	}
+}
+
+// TestTimeoutNotRetried makes sure that bidderAdapter.doRequest doesn't retry calls which time out.
+func TestTimeoutNotRetried(t *testing.T) {
+	ctx, cancelFunc := context.WithDeadline(context.Background(), time.Now().Add(-7*time.Hour))
+	cancelFunc()
+
+	server := httptest.NewServer(mockHandler(200, "getBody", "postBody"))
+	defer server.Close()
+	bidder := &bidderAdapter{
+		Bidder:                &mixedMultiBidder{},
+		Client:                server.Client(),
+		retryConnectionErrors: true,
+	}
+
+	callInfo := bidder.doRequest(ctx, &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	})
+	if callInfo.err == nil {
+		t.Fatalf("The bidder should report an error if the context has expired already.")
+	}
+	if callInfo.retry != "" {
+		t.Errorf("Timed out calls shouldn't be retried. Got %s", callInfo.retry)
+	}
+}
+
 // TestBadResponseLogging makes sure that openrtb_ext works properly on malformed HTTP requests.
 func TestBadRequestLogging(t *testing.T) {
 	info := &httpCallInfo{
@@ -322,7 +432,7 @@ func TestServerCallDebugging(t *testing.T) {
 
 	bids, _ := bidder.requestBid(context.Background(), &openrtb.BidRequest{
 		Test: 1,
-	}, "test", 1.0)
+	}, "test", 1.0, currencies.NewConstantRates())
 
 	if len(bids.httpCalls) != 1 {
 		t.Errorf("We should log the server call if this is a test bid. Got %d", len(bids.httpCalls))
@@ -343,7 +453,7 @@ func TestServerCallDebugging(t *testing.T) {
 
 func TestErrorReporting(t *testing.T) {
 	bidder := adaptBidder(&bidRejector{}, nil)
-	bids, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "test", 1.0)
+	bids, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "test", 1.0, currencies.NewConstantRates())
 	if bids != nil {
 		t.Errorf("There should be no seatbid if no http requests are returned.")
 	}
@@ -355,6 +465,196 @@ func TestErrorReporting(t *testing.T) {
 	}
 }
 
+func TestIGIWithoutBids(t *testing.T) {
+	server := httptest.NewServer(mockHandler(200, "getBody", "{}"))
+	defer server.Close()
+
+	bidderImpl := &goodSingleBidder{
+		httpRequest: &adapters.RequestData{
+			Method:  "POST",
+			Uri:     server.URL,
+			Headers: http.Header{},
+		},
+		bidResponse: &adapters.BidderResponse{
+			IGI: []*openrtb_ext.ExtIGI{{ImpID: "imp-1", IGB: []*openrtb_ext.ExtIGB{{Origin: "https://buyer.example.com"}}}},
+		},
+	}
+	seatBid, errs := adaptBidder(bidderImpl, server.Client()).requestBid(context.Background(), &openrtb.BidRequest{}, "test", 1.0, currencies.NewConstantRates())
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors. Got %v", errs)
+	}
+	if len(seatBid.bids) != 0 || len(seatBid.igi) != 1 || seatBid.igi[0].ImpID != "imp-1" {
+		t.Errorf("The interest group signals should be kept, even without bids. Got %#v", seatBid)
+	}
+}
+
+func TestBidCurrencyConversion(t *testing.T) {
+	server := httptest.NewServer(mockHandler(200, "getBody", "{}"))
+	defer server.Close()
+
+	conversions := currencies.NewRates(time.Time{}, map[string]map[string]float64{
+		"USD": {
+			"EUR": 0.5,
+		},
+	})
+	makeBidder := func(currency string) adaptedBidder {
+		return adaptBidder(&goodSingleBidder{
+			httpRequest: &adapters.RequestData{
+				Method:  "POST",
+				Uri:     server.URL,
+				Headers: http.Header{},
+			},
+			bidResponse: &adapters.BidderResponse{
+				Currency: currency,
+				Bids: []*adapters.TypedBid{{
+					Bid:     &openrtb.Bid{Price: 4},
+					BidType: openrtb_ext.BidTypeBanner,
+				}},
+			},
+		}, server.Client())
+	}
+
+	seatBid, errs := makeBidder("USD").requestBid(context.Background(), &openrtb.BidRequest{Cur: []string{"EUR"}}, "test", 2.0, conversions)
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors. Got %v", errs)
+	}
+	if len(seatBid.bids) != 1 || seatBid.bids[0].bid.Price != 4.0 {
+		t.Fatalf("Bids should be adjusted and converted into the request currency. Got %#v", seatBid.bids)
+	}
+	if seatBid.bids[0].originalCurrency != "USD" || seatBid.bids[0].originalPrice != 8.0 {
+		t.Errorf("The adjusted price should be kept in the bidder's currency. Got %f %s", seatBid.bids[0].originalPrice, seatBid.bids[0].originalCurrency)
+	}
+
+	seatBid, errs = makeBidder("JPY").requestBid(context.Background(), &openrtb.BidRequest{Cur: []string{"EUR"}}, "test", 1.0, conversions)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "Dropped 1 bid(s)") {
+		t.Errorf("Expected an error which says the bid was dropped. Got %v", errs)
+	}
+	if len(seatBid.bids) != 0 {
+		t.Errorf("Bids which can't be converted should be dropped. Got %d", len(seatBid.bids))
+	}
+
+	// Only the rates which are applied to bids should count as conversions.
+	var converted int
+	crossRates := currencies.NewCrossRates(conversions, "", func(bool) { converted++ })
+	noBidder := adaptBidder(&goodSingleBidder{
+		httpRequest: &adapters.RequestData{
+			Method:  "POST",
+			Uri:     server.URL,
+			Headers: http.Header{},
+		},
+		bidResponse: &adapters.BidderResponse{Currency: "USD"},
+	}, server.Client())
+	if _, errs := noBidder.requestBid(context.Background(), &openrtb.BidRequest{Cur: []string{"EUR"}}, "test", 1.0, crossRates); len(errs) != 0 {
+		t.Errorf("Expected no errors. Got %v", errs)
+	}
+	if converted != 0 {
+		t.Errorf("Responses without bids shouldn't count as conversions. Got %d", converted)
+	}
+	makeBidder("USD").requestBid(context.Background(), &openrtb.BidRequest{Cur: []string{"EUR"}}, "test", 1.0, crossRates)
+	if converted != 1 {
+		t.Errorf("Bids which were converted should count. Got %d", converted)
+	}
+}
+
+// TestImpBatching makes sure that requests with more Imps than the bidder accepts are split into batches.
+func TestImpBatching(t *testing.T) {
+	bidderImpl := &impBatchBidder{}
+	bidder := &bidderAdapter{
+		Bidder:  bidderImpl,
+		maxImps: 2,
+	}
+	reqData, errs := bidder.makeRequests(&openrtb.BidRequest{
+		ID:  "req-1",
+		Imp: []openrtb.Imp{{ID: "imp-1"}, {ID: "imp-2"}, {ID: "imp-3"}},
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if len(reqData) != 2 || len(bidderImpl.bidRequests) != 2 {
+		t.Fatalf("The bidder should be called once per batch. Got %d calls and %d requests", len(bidderImpl.bidRequests), len(reqData))
+	}
+	if first := bidderImpl.bidRequests[0]; first.ID != "req-1" || len(first.Imp) != 2 || first.Imp[1].ID != "imp-2" {
+		t.Errorf("The first batch should have the first 2 imps. Got %#v", first)
+	}
+	if second := bidderImpl.bidRequests[1]; second.ID != "req-1" || len(second.Imp) != 1 || second.Imp[0].ID != "imp-3" {
+		t.Errorf("The second batch should have the last imp. Got %#v", second)
+	}
+
+	bidder.maxImps = 0
+	bidderImpl.bidRequests = nil
+	bidder.makeRequests(&openrtb.BidRequest{Imp: []openrtb.Imp{{ID: "imp-1"}, {ID: "imp-2"}, {ID: "imp-3"}}})
+	if len(bidderImpl.bidRequests) != 1 {
+		t.Errorf("Requests shouldn't be split if there's no limit. Got %d calls", len(bidderImpl.bidRequests))
+	}
+}
+
+func TestMediaTypeRules(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "banner-imp", Banner: &openrtb.Banner{}},
+			{ID: "multi-format-imp", Banner: &openrtb.Banner{}, Video: &openrtb.Video{}},
+		},
+	}
+	vastBid := &adapters.TypedBid{
+		Bid:     &openrtb.Bid{ImpID: "multi-format-imp", AdM: "<VAST></VAST>"},
+		BidType: openrtb_ext.BidTypeBanner,
+	}
+	unknownBid := &adapters.TypedBid{
+		Bid:     &openrtb.Bid{ImpID: "multi-format-imp"},
+		BidType: openrtb_ext.BidTypeBanner,
+	}
+
+	bidder := &bidderAdapter{}
+	if bidType := bidder.mediaType(vastBid, request); bidType != openrtb_ext.BidTypeBanner {
+		t.Errorf("Without rules, the bidder's type should be used. Got %s", bidType)
+	}
+
+	bidder.mediaTypeRules = []string{adapters.MediaTypeFromImp, adapters.MediaTypeFromAdm}
+	if bidType := bidder.mediaType(vastBid, request); bidType != openrtb_ext.BidTypeVideo {
+		t.Errorf("A VAST bid in a multi-format imp should be video. Got %s", bidType)
+	}
+	if bidType := bidder.mediaType(unknownBid, request); bidType != openrtb_ext.BidTypeBanner {
+		t.Errorf("If no rule can tell, the bidder's type should be used. Got %s", bidType)
+	}
+}
+
+// TestOpenRTB26Bidder makes sure that bidders which expect OpenRTB 2.6 get the fields which used to be in ext
+// where 2.6 has them, and that the mtypes in their responses aren't lost.
+func TestOpenRTB26Bidder(t *testing.T) {
+	respBody := `{"id":"req-1","seatbid":[{"bid":[{"id":"bid-1","impid":"imp-1","price":1,"mtype":2},{"id":"bid-2","impid":"imp-1","price":1,"mtype":2}]}]}`
+	server := httptest.NewServer(mockHandler(200, "getBody", respBody))
+	defer server.Close()
+
+	bidderImpl := &openRTB26Bidder{goodSingleBidder{
+		httpRequest: &adapters.RequestData{
+			Method:  "POST",
+			Uri:     server.URL,
+			Body:    []byte(`{"id":"req-1","regs":{"ext":{"gdpr":1}}}`),
+			Headers: http.Header{},
+		},
+		bidResponse: &adapters.BidderResponse{
+			Bids: []*adapters.TypedBid{
+				{Bid: &openrtb.Bid{ID: "bid-1", ImpID: "imp-1", Price: 1}, BidType: openrtb_ext.BidTypeBanner},
+				{Bid: &openrtb.Bid{ID: "bid-2", ImpID: "imp-1", Price: 1}, BidType: openrtb_ext.BidTypeBanner, MType: 1},
+			},
+		},
+	}}
+	bidder := adaptBidder(bidderImpl, server.Client())
+	_, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{ID: "req-1"}, "test", 1.0, currencies.NewConstantRates())
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if body := string(bidderImpl.httpRequest.Body); body != `{"id":"req-1","regs":{"gdpr":1}}` {
+		t.Errorf("regs.ext.gdpr should have been moved to regs.gdpr. Got %s", body)
+	}
+	if mtype := bidderImpl.bidResponse.Bids[0].MType; mtype != 2 {
+		t.Errorf("The mtype should come from the response. Got %d", mtype)
+	}
+	if mtype := bidderImpl.bidResponse.Bids[1].MType; mtype != 1 {
+		t.Errorf("The bidder's mtype should be kept. Got %d", mtype)
+	}
+}
+
 type goodSingleBidder struct {
 	bidRequest   *openrtb.BidRequest
 	httpRequest  *adapters.RequestData
@@ -372,6 +672,15 @@ func (bidder *goodSingleBidder) MakeBids(internalRequest *openrtb.BidRequest, ex
 	return bidder.bidResponse, nil
 }
 
+// openRTB26Bidder is a goodSingleBidder whose server expects OpenRTB 2.6.
+type openRTB26Bidder struct {
+	goodSingleBidder
+}
+
+func (bidder *openRTB26Bidder) OpenRTBVersion() adapters.OpenRTBVersion {
+	return adapters.OpenRTB26
+}
+
 type mixedMultiBidder struct {
 	bidRequest    *openrtb.BidRequest
 	httpRequests  []*adapters.RequestData
@@ -402,3 +711,17 @@ func (bidder *bidRejector) MakeBids(internalRequest *openrtb.BidRequest, externa
 	bidder.httpResponse = response
 	return nil, []error{errors.New("Can't make a response.")}
 }
+
+// impBatchBidder makes one HTTP request for each call to MakeRequests.
+type impBatchBidder struct {
+	bidRequests []*openrtb.BidRequest
+}
+
+func (bidder *impBatchBidder) MakeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	bidder.bidRequests = append(bidder.bidRequests, request)
+	return []*adapters.RequestData{{Method: "POST", Uri: "http://bidder.com/openrtb2"}}, nil
+}
+
+func (bidder *impBatchBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	return nil, nil
+}
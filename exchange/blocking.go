@@ -0,0 +1,133 @@
+package exchange
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// addBlocks adds the account's blocks to each bidder's request. Values which the request already has aren't repeated.
+// The slices and imp objects are copied first, since the bidders' requests may share them.
+func addBlocks(cfg *config.Blocking, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest) {
+	for bidder, request := range cleanRequests {
+		blocked := cfg.BlockedFor(string(bidder))
+		if blocked == nil {
+			continue
+		}
+		request.BAdv = appendMissing(request.BAdv, blocked.BAdv)
+		request.BCat = appendMissing(request.BCat, blocked.BCat)
+		if len(blocked.BAttr) == 0 {
+			continue
+		}
+		imps := make([]openrtb.Imp, len(request.Imp))
+		copy(imps, request.Imp)
+		for i := range imps {
+			if imps[i].Banner != nil {
+				banner := *imps[i].Banner
+				banner.BAttr = appendMissingAttrs(banner.BAttr, blocked.BAttr)
+				imps[i].Banner = &banner
+			}
+			if imps[i].Video != nil {
+				video := *imps[i].Video
+				video.BAttr = appendMissingAttrs(video.BAttr, blocked.BAttr)
+				imps[i].Video = &video
+			}
+		}
+		request.Imp = imps
+	}
+}
+
+func appendMissing(values []string, blocked []string) []string {
+	if len(blocked) == 0 {
+		return values
+	}
+	merged := append(make([]string, 0, len(values)+len(blocked)), values...)
+	for _, value := range blocked {
+		if !containsString(merged, value) {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+func appendMissingAttrs(attrs []openrtb.CreativeAttribute, blocked []int) []openrtb.CreativeAttribute {
+	merged := append(make([]openrtb.CreativeAttribute, 0, len(attrs)+len(blocked)), attrs...)
+	for _, attr := range blocked {
+		if !containsAttr(merged, openrtb.CreativeAttribute(attr)) {
+			merged = append(merged, openrtb.CreativeAttribute(attr))
+		}
+	}
+	return merged
+}
+
+// enforceBlocks removes the bids which use a blocked advertiser, category or creative attribute, and returns an error
+// for each one. A nil blocked means there aren't any blocks.
+func (brw *bidResponseWrapper) enforceBlocks(blocked *config.BlockedLists) (err []error) {
+	if blocked == nil || brw.adapterBids == nil || len(brw.adapterBids.bids) == 0 {
+		return
+	}
+	keptBids := make([]*pbsOrtbBid, 0, len(brw.adapterBids.bids))
+	for _, bid := range brw.adapterBids.bids {
+		if reason := blockedReason(bid.bid, blocked); reason != "" {
+			err = append(err, fmt.Errorf("Bid \"%s\" rejected (%s): its %s, which the account blocks", bid.bid.ID, pbsmetrics.AdapterBidRejectedBlocked, reason))
+		} else {
+			keptBids = append(keptBids, bid)
+		}
+	}
+	if len(keptBids) != len(brw.adapterBids.bids) {
+		brw.adapterBids.bids = keptBids
+	}
+	return err
+}
+
+// blockedReason describes the first blocked value which the bid uses, or returns "" if it doesn't use any.
+func blockedReason(bid *openrtb.Bid, blocked *config.BlockedLists) string {
+	for _, domain := range bid.ADomain {
+		for _, blockedDomain := range blocked.BAdv {
+			if isDomainOrSubdomain(domain, blockedDomain) {
+				return fmt.Sprintf("adomain is %s", domain)
+			}
+		}
+	}
+	for _, cat := range bid.Cat {
+		if containsString(blocked.BCat, cat) {
+			return fmt.Sprintf("cat is %s", cat)
+		}
+	}
+	for _, attr := range bid.Attr {
+		for _, blockedAttr := range blocked.BAttr {
+			if int(attr) == blockedAttr {
+				return fmt.Sprintf("attr is %d", attr)
+			}
+		}
+	}
+	return ""
+}
+
+// isDomainOrSubdomain returns true if domain is parent, or one of its subdomains. Case doesn't matter.
+func isDomainOrSubdomain(domain string, parent string) bool {
+	domain, parent = strings.ToLower(domain), strings.ToLower(parent)
+	return domain == parent || strings.HasSuffix(domain, "."+parent)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAttr(attrs []openrtb.CreativeAttribute, attr openrtb.CreativeAttribute) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestAddBlocks(t *testing.T) {
+	cfg := &config.Blocking{
+		Blocked: config.BlockedLists{BAdv: []string{"competitor.com"}, BCat: []string{"IAB25"}, BAttr: []int{1}},
+		Bidders: map[string]config.BlockedLists{"rubicon": {}},
+	}
+	banner := &openrtb.Banner{BAttr: []openrtb.CreativeAttribute{1, 2}}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus": {BAdv: []string{"other.com"}, Imp: []openrtb.Imp{{ID: "imp", Banner: banner, Video: &openrtb.Video{}}}},
+		"rubicon":  {Imp: []openrtb.Imp{{ID: "imp", Banner: banner}}},
+	}
+
+	addBlocks(cfg, cleanRequests)
+	appnexus := cleanRequests["appnexus"]
+	if len(appnexus.BAdv) != 2 || appnexus.BAdv[0] != "other.com" || appnexus.BAdv[1] != "competitor.com" {
+		t.Errorf("The account's domains should be added to the request's. Got %v", appnexus.BAdv)
+	}
+	if len(appnexus.BCat) != 1 || appnexus.BCat[0] != "IAB25" {
+		t.Errorf("The account's categories should be added. Got %v", appnexus.BCat)
+	}
+	if battr := appnexus.Imp[0].Banner.BAttr; len(battr) != 2 {
+		t.Errorf("Attributes which the banner already blocks shouldn't be repeated. Got %v", battr)
+	}
+	if battr := appnexus.Imp[0].Video.BAttr; len(battr) != 1 || battr[0] != 1 {
+		t.Errorf("The account's attributes should be added to the video. Got %v", battr)
+	}
+	if appnexus.Imp[0].Banner == banner {
+		t.Errorf("The banner should be copied, since the bidders may share it.")
+	}
+	if rubicon := cleanRequests["rubicon"]; len(rubicon.BAdv) != 0 || rubicon.Imp[0].Banner != banner {
+		t.Errorf("Bidders without blocks shouldn't be changed. Got %v", rubicon)
+	}
+}
+
+func TestEnforceBlocks(t *testing.T) {
+	blocked := &config.BlockedLists{BAdv: []string{"competitor.com"}, BCat: []string{"IAB25"}, BAttr: []int{8}}
+	brw := &bidResponseWrapper{
+		adapterBids: &pbsOrtbSeatBid{bids: []*pbsOrtbBid{
+			{bid: &openrtb.Bid{ID: "subdomain", ADomain: []string{"Shop.Competitor.com"}}},
+			{bid: &openrtb.Bid{ID: "category", Cat: []string{"IAB1", "IAB25"}}},
+			{bid: &openrtb.Bid{ID: "attribute", Attr: []openrtb.CreativeAttribute{8}}},
+			{bid: &openrtb.Bid{ID: "lookalike", ADomain: []string{"notcompetitor.com"}, Cat: []string{"IAB1"}}},
+		}},
+	}
+
+	errs := brw.enforceBlocks(blocked)
+	if bids := brw.adapterBids.bids; len(bids) != 1 || bids[0].bid.ID != "lookalike" {
+		t.Errorf("Only the bid without blocked values should be kept. Got %v", bids)
+	}
+	if len(errs) != 3 || !strings.Contains(errs[0].Error(), "blocked") {
+		t.Errorf("Each rejected bid should have an error with the rejection code. Got %v", errs)
+	}
+	if errs := brw.enforceBlocks(nil); len(errs) != 0 || len(brw.adapterBids.bids) != 1 {
+		t.Errorf("Bids shouldn't be rejected without any blocks. Got %v", errs)
+	}
+}
@@ -3,29 +3,105 @@ package exchange
 import (
 	"context"
 	"encoding/json"
+	"sync"
+	"time"
 
-	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/prebid_cache_client"
+	"github.com/prebid/prebid-server/util/uuidutil"
 )
 
-func cacheBids(ctx context.Context, cache prebid_cache_client.Client, bids []*openrtb.Bid) map[*openrtb.Bid]string {
-	// Marshal the bids into JSON payloads. If any errors occur during marshalling, eject that bid from the array.
-	// After this block, we expect "bids" and "jsonValues" to have the same number of elements in the same order.
+// cacheBids saves the bids in Prebid Cache, and returns the ID of each one which was saved.
+// ttls has the number of seconds each bid should live, at the same index. If it's nil, they get the cache's default.
+// If batchSize is positive, the bids are sent in parallel PUTs of at most that many bids each.
+func cacheBids(ctx context.Context, cache prebid_cache_client.Client, bids []*openrtb.Bid, ttls []int64, batchSize int) map[*openrtb.Bid]string {
+	bids, jsonValues, ttls := marshalBids(bids, ttls)
+	ids := putInBatches(len(jsonValues), batchSize, func(start int, end int) []string {
+		return cache.PutJson(ctx, jsonValues[start:end], batchTTLs(ttls, start, end))
+	})
+	return mapCacheIds(bids, ids)
+}
+
+// cacheBidsAsync returns IDs for the bids right away, and saves them in Prebid Cache in the background.
+// The bids which haven't been saved by the time the timeout expires are lost.
+func cacheBidsAsync(cache prebid_cache_client.KeyedClient, bids []*openrtb.Bid, ttls []int64, batchSize int, timeout time.Duration) map[*openrtb.Bid]string {
+	bids, jsonValues, ttls := marshalBids(bids, ttls)
+	ids := make([]string, len(jsonValues))
+	for i := 0; i < len(ids); i++ {
+		ids[i] = uuidutil.NewV4()
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		saved := putInBatches(len(jsonValues), batchSize, func(start int, end int) []string {
+			return cache.PutJsonWithKeys(ctx, ids[start:end], jsonValues[start:end], batchTTLs(ttls, start, end))
+		})
+		for i := 0; i < len(saved); i++ {
+			if saved[i] == "" {
+				logger.Warningf("Async write of bid %s to Prebid Cache failed. Its hb_cache_id won't be found.", ids[i])
+			}
+		}
+	}()
+
+	return mapCacheIds(bids, ids)
+}
+
+// marshalBids makes the JSON payloads for the bids. If any errors occur during marshalling, that bid (and its TTL)
+// is ejected. The returned slices have the same number of elements, in the same order, unless ttls is nil.
+func marshalBids(bids []*openrtb.Bid, ttls []int64) ([]*openrtb.Bid, []json.RawMessage, []int64) {
 	jsonValues := make([]json.RawMessage, 0, len(bids))
 	for i := 0; i < len(bids); i++ {
 		if jsonBytes, err := json.Marshal(bids[i]); err != nil {
-			glog.Errorf("Error marshalling OpenRTB Bid for Prebid Cache: %v", err)
+			logger.Errorf("Error marshalling OpenRTB Bid for Prebid Cache: %v", err)
 			bids = append(bids[:i], bids[i+1:]...)
+			if ttls != nil {
+				ttls = append(ttls[:i], ttls[i+1:]...)
+			}
 			i--
 		} else {
 			jsonValues = append(jsonValues, jsonBytes)
 		}
 	}
+	return bids, jsonValues, ttls
+}
 
-	ids := cache.PutJson(ctx, jsonValues)
+// batchTTLs returns the TTLs for the values in [start, end), or nil if there aren't any.
+func batchTTLs(ttls []int64, start int, end int) []int64 {
+	if ttls == nil {
+		return nil
+	}
+	return ttls[start:end]
+}
+
+// putInBatches calls put on consecutive ranges of at most batchSize values, in parallel, and joins the IDs it returns.
+// If batchSize isn't positive, all the values go in a single call.
+func putInBatches(numValues int, batchSize int, put func(start int, end int) []string) []string {
+	if batchSize <= 0 || numValues <= batchSize {
+		return put(0, numValues)
+	}
+
+	ids := make([]string, numValues)
+	var wg sync.WaitGroup
+	for start := 0; start < numValues; start += batchSize {
+		end := start + batchSize
+		if end > numValues {
+			end = numValues
+		}
+		wg.Add(1)
+		go func(start int, end int) {
+			defer wg.Done()
+			copy(ids[start:end], put(start, end))
+		}(start, end)
+	}
+	wg.Wait()
+	return ids
+}
+
+func mapCacheIds(bids []*openrtb.Bid, ids []string) map[*openrtb.Bid]string {
 	toReturn := make(map[*openrtb.Bid]string, len(bids))
-	for i := 0; i < len(bids); i++ {
+	for i := 0; i < len(bids) && i < len(ids); i++ {
 		if ids[i] != "" {
 			toReturn[bids[i]] = ids[i]
 		}
@@ -3,10 +3,15 @@ package exchange
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/evanphx/json-patch"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
 )
 
 func TestBidSerialization(t *testing.T) {
@@ -28,7 +33,7 @@ func TestBidSerialization(t *testing.T) {
 		},
 	}
 
-	bidMap := cacheBids(context.Background(), mockClient, []*openrtb.Bid{winningBid, otherBid})
+	bidMap := cacheBids(context.Background(), mockClient, []*openrtb.Bid{winningBid, otherBid}, nil, 0)
 
 	assertStringValue(t, `bid "bar"`, "0", bidMap[winningBid])
 	assertStringValue(t, `bid "foo"`, "1", bidMap[otherBid])
@@ -52,7 +57,7 @@ func TestCacheFailures(t *testing.T) {
 			otherBid:   "1",
 		},
 	}
-	bidMap := cacheBids(context.Background(), mockClient, []*openrtb.Bid{winningBid, otherBid})
+	bidMap := cacheBids(context.Background(), mockClient, []*openrtb.Bid{winningBid, otherBid}, nil, 0)
 
 	assertStringValue(t, `bid "foo"`, "1", bidMap[otherBid])
 	if _, ok := bidMap[winningBid]; ok {
@@ -78,7 +83,7 @@ func TestMarshalFailure(t *testing.T) {
 		},
 	}
 
-	bidMap := cacheBids(context.Background(), mockClient, []*openrtb.Bid{goodBid, badBid})
+	bidMap := cacheBids(context.Background(), mockClient, []*openrtb.Bid{goodBid, badBid}, nil, 0)
 	if _, ok := bidMap[badBid]; ok {
 		t.Errorf("bids with malformed JSON should not be cached.")
 	}
@@ -91,11 +96,113 @@ func TestMarshalFailure(t *testing.T) {
 	}
 }
 
+func TestCacheTTLs(t *testing.T) {
+	badBid := &openrtb.Bid{
+		ImpID: "foo",
+		Ext:   openrtb.RawJSON("{"),
+	}
+	goodBid := &openrtb.Bid{
+		ImpID: "bar",
+	}
+	mockClient := &mockCacheClient{}
+
+	cacheBids(context.Background(), mockClient, []*openrtb.Bid{badBid, goodBid}, []int64{300, 3600}, 0)
+	if len(mockClient.ttls) != 1 || mockClient.ttls[0] != 3600 {
+		t.Errorf("Each bid should be sent with its own TTL, and bids which can't be marshalled should drop theirs. Got %v", mockClient.ttls)
+	}
+}
+
+func TestBidsToCacheTTLs(t *testing.T) {
+	banner := &openrtb.Bid{ID: "banner", ImpID: "1", Price: 1}
+	video := &openrtb.Bid{ID: "video", ImpID: "2", Price: 1}
+	expiring := &openrtb.Bid{ID: "expiring", ImpID: "3", Price: 1, Exp: 60}
+	auc := newAuction(map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {
+			bids: []*pbsOrtbBid{
+				{bid: banner, bidType: openrtb_ext.BidTypeBanner},
+				{bid: video, bidType: openrtb_ext.BidTypeVideo},
+				{bid: expiring, bidType: openrtb_ext.BidTypeVideo},
+			},
+		},
+	}, 3, newTieBreaker(config.TieBreaking{}, &openrtb.BidRequest{}))
+
+	bids, ttls := auc.bidsToCache(&config.CacheTTL{Banner: 300, Video: 3600}, "1001")
+	expected := map[*openrtb.Bid]int64{banner: 300, video: 3600, expiring: 60}
+	if len(bids) != len(expected) || len(ttls) != len(bids) {
+		t.Fatalf("Every winning bid should be cached with a TTL. Got %d bids and %d TTLs", len(bids), len(ttls))
+	}
+	for i, bid := range bids {
+		if ttls[i] != expected[bid] {
+			t.Errorf("Bad TTL for bid %s. Expected %d, got %d", bid.ID, expected[bid], ttls[i])
+		}
+	}
+}
+
+func TestBatchedCache(t *testing.T) {
+	bids := make([]*openrtb.Bid, 5)
+	mockReturns := make(map[*openrtb.Bid]string, len(bids))
+	for i := 0; i < len(bids); i++ {
+		bids[i] = &openrtb.Bid{
+			ID:    "bid-" + strconv.Itoa(i),
+			ImpID: "imp",
+			Price: float64(i),
+		}
+		mockReturns[bids[i]] = strconv.Itoa(i)
+	}
+	mockClient := &mockCacheClient{
+		mockReturns: mockReturns,
+	}
+
+	bidMap := cacheBids(context.Background(), mockClient, bids, nil, 2)
+	for i, bid := range bids {
+		assertStringValue(t, bid.ID, strconv.Itoa(i), bidMap[bid])
+	}
+	if len(mockClient.batchSizes) != 3 {
+		t.Fatalf("5 bids in batches of 2 should make 3 calls. Got %d", len(mockClient.batchSizes))
+	}
+	for _, size := range mockClient.batchSizes {
+		if size > 2 {
+			t.Errorf("No batch should have more than 2 bids. Got %d", size)
+		}
+	}
+}
+
+func TestAsyncCache(t *testing.T) {
+	bid := &openrtb.Bid{
+		ID:    "bar",
+		ImpID: "a",
+		Price: 1.5,
+	}
+	mockClient := &mockKeyedCacheClient{
+		saved: make(chan string, 1),
+	}
+
+	bidMap := cacheBidsAsync(mockClient, []*openrtb.Bid{bid}, nil, 0, time.Second)
+	id, ok := bidMap[bid]
+	if !ok || id == "" {
+		t.Fatalf("Async caching should assign an ID to the bid right away.")
+	}
+	select {
+	case saved := <-mockClient.saved:
+		assertStringValue(t, "saved key", id, saved)
+	case <-time.After(time.Second):
+		t.Errorf("The bid was never saved.")
+	}
+}
+
 type mockCacheClient struct {
 	mockReturns map[*openrtb.Bid]string
+	batchSizes  []int
+	ttls        []int64
+	mutex       sync.Mutex
 }
 
-func (c *mockCacheClient) PutJson(ctx context.Context, values []json.RawMessage) []string {
+func (c *mockCacheClient) PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) []string {
+	c.mutex.Lock()
+	c.batchSizes = append(c.batchSizes, len(values))
+	c.ttls = append(c.ttls, ttlSeconds...)
+	c.mutex.Unlock()
+
 	returns := make([]string, len(values))
 	for i, value := range values {
 		for bid, id := range c.mockReturns {
@@ -109,6 +216,21 @@ func (c *mockCacheClient) PutJson(ctx context.Context, values []json.RawMessage)
 	return returns
 }
 
+type mockKeyedCacheClient struct {
+	saved chan string
+}
+
+func (c *mockKeyedCacheClient) PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) []string {
+	return make([]string, len(values))
+}
+
+func (c *mockKeyedCacheClient) PutJsonWithKeys(ctx context.Context, keys []string, values []json.RawMessage, ttlSeconds []int64) []string {
+	for _, key := range keys {
+		c.saved <- key
+	}
+	return keys
+}
+
 func assertStringValue(t *testing.T, object string, expect string, value string) {
 	t.Helper()
 	if expect != value {
@@ -0,0 +1,127 @@
+package exchange
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// ResponseCapture keeps the last few malformed responses from each bidder. See config.ResponseCapture.
+//
+// Legacy adapters make their own HTTP calls, so their responses are never captured.
+type ResponseCapture struct {
+	size int
+	// bidders are the lowercased names of the bidders to capture, or nil to capture all of them.
+	bidders map[string]bool
+	lock    sync.Mutex
+	rings   map[openrtb_ext.BidderName]*responseRing
+}
+
+// CapturedResponse is a response which the Bidder couldn't make bids from without errors, along with the request it answered.
+type CapturedResponse struct {
+	Time         time.Time `json:"time"`
+	Uri          string    `json:"uri"`
+	RequestBody  string    `json:"requestbody"`
+	Status       int       `json:"status"`
+	ResponseBody string    `json:"responsebody"`
+	Errors       []string  `json:"errors"`
+}
+
+// NewResponseCapture returns a ResponseCapture for the config, or nil if the capture is disabled.
+func NewResponseCapture(cfg config.ResponseCapture) *ResponseCapture {
+	if !cfg.Enabled {
+		return nil
+	}
+	capture := &ResponseCapture{
+		size:  cfg.Size,
+		rings: make(map[openrtb_ext.BidderName]*responseRing),
+	}
+	if len(cfg.Bidders) > 0 {
+		capture.bidders = make(map[string]bool, len(cfg.Bidders))
+		for _, bidder := range cfg.Bidders {
+			capture.bidders[strings.ToLower(bidder)] = true
+		}
+	}
+	return capture
+}
+
+// Responses returns the captured responses for each bidder, oldest first.
+func (c *ResponseCapture) Responses() map[openrtb_ext.BidderName][]CapturedResponse {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	responses := make(map[openrtb_ext.BidderName][]CapturedResponse, len(c.rings))
+	for bidder, ring := range c.rings {
+		if list := ring.list(); len(list) > 0 {
+			responses[bidder] = list
+		}
+	}
+	return responses
+}
+
+// forBidder returns the ring which holds the bidder's responses, or nil if they aren't captured.
+// The same ring is returned each time, so that rebuilding the exchange keeps the responses.
+func (c *ResponseCapture) forBidder(bidder openrtb_ext.BidderName) *responseRing {
+	if c == nil || (c.bidders != nil && !c.bidders[strings.ToLower(string(bidder))]) {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ring, ok := c.rings[bidder]
+	if !ok {
+		ring = &responseRing{responses: make([]CapturedResponse, 0, c.size)}
+		c.rings[bidder] = ring
+	}
+	return ring
+}
+
+// responseRing holds the last cap(responses) responses from one bidder.
+type responseRing struct {
+	lock      sync.Mutex
+	responses []CapturedResponse
+	// next is the index which the next response goes in, once the ring is full.
+	next int
+}
+
+// add captures the response, if the ring isn't nil.
+func (r *responseRing) add(req *adapters.RequestData, resp *adapters.ResponseData, errs []error) {
+	if r == nil {
+		return
+	}
+	captured := CapturedResponse{
+		Time:         time.Now(),
+		Uri:          req.Uri,
+		RequestBody:  string(req.Body),
+		Status:       resp.StatusCode,
+		ResponseBody: string(resp.Body),
+		Errors:       make([]string, len(errs)),
+	}
+	for i, err := range errs {
+		captured.Errors[i] = err.Error()
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.responses) < cap(r.responses) {
+		r.responses = append(r.responses, captured)
+	} else {
+		r.responses[r.next] = captured
+	}
+	r.next = (r.next + 1) % cap(r.responses)
+}
+
+func (r *responseRing) list() []CapturedResponse {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	list := make([]CapturedResponse, 0, len(r.responses))
+	if len(r.responses) == cap(r.responses) {
+		list = append(list, r.responses[r.next:]...)
+		list = append(list, r.responses[:r.next]...)
+	} else {
+		list = append(list, r.responses...)
+	}
+	return list
+}
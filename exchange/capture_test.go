@@ -0,0 +1,103 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestResponseCaptureDisabled(t *testing.T) {
+	capture := NewResponseCapture(config.ResponseCapture{Size: 5})
+	if capture != nil {
+		t.Fatalf("Responses shouldn't be captured unless it's enabled.")
+	}
+	if ring := capture.forBidder(openrtb_ext.BidderAppnexus); ring != nil {
+		t.Errorf("A nil ResponseCapture shouldn't capture any bidders.")
+	}
+}
+
+func TestResponseCaptureBidders(t *testing.T) {
+	capture := NewResponseCapture(config.ResponseCapture{Enabled: true, Size: 5, Bidders: []string{"indexexchange"}})
+	if capture.forBidder(openrtb_ext.BidderIndex) == nil {
+		t.Errorf("The listed bidders should be captured, regardless of case.")
+	}
+	if capture.forBidder(openrtb_ext.BidderAppnexus) != nil {
+		t.Errorf("Bidders which aren't listed shouldn't be captured.")
+	}
+	if capture.forBidder(openrtb_ext.BidderIndex) != capture.forBidder(openrtb_ext.BidderIndex) {
+		t.Errorf("Each bidder should have a single ring, so that the exchange can be rebuilt without losing responses.")
+	}
+}
+
+func TestResponseRing(t *testing.T) {
+	capture := NewResponseCapture(config.ResponseCapture{Enabled: true, Size: 2})
+	ring := capture.forBidder(openrtb_ext.BidderAppnexus)
+	capture.forBidder(openrtb_ext.BidderRubicon)
+	for _, body := range []string{"first", "second", "third"} {
+		ring.add(&adapters.RequestData{Uri: "http://bidder.com"}, &adapters.ResponseData{StatusCode: 200, Body: []byte(body)}, []error{errors.New("bad " + body)})
+	}
+
+	responses := capture.Responses()
+	if len(responses) != 1 {
+		t.Errorf("Only the bidders with malformed responses should be returned. Got %v", responses)
+	}
+	list := responses[openrtb_ext.BidderAppnexus]
+	if len(list) != 2 || list[0].ResponseBody != "second" || list[1].ResponseBody != "third" {
+		t.Fatalf("The ring should have the last 2 responses, oldest first. Got %v", list)
+	}
+	if list[1].Uri != "http://bidder.com" || list[1].Status != 200 || len(list[1].Errors) != 1 || list[1].Errors[0] != "bad third" {
+		t.Errorf("Wrong captured response: %v", list[1])
+	}
+}
+
+func TestMalformedResponsesCaptured(t *testing.T) {
+	server := httptest.NewServer(mockHandler(200, "getBody", "not json"))
+	defer server.Close()
+
+	capture := NewResponseCapture(config.ResponseCapture{Enabled: true, Size: 5})
+	bidderImpl := &malformedResponseBidder{uri: server.URL}
+	bidder := adaptBidder(bidderImpl, server.Client()).(*bidderAdapter)
+	bidder.capture = capture.forBidder(openrtb_ext.BidderAppnexus)
+
+	bidder.requestBid(context.Background(), &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates())
+	if len(capture.Responses()) != 0 {
+		t.Errorf("Responses should only be captured if the Bidder returned errors for them.")
+	}
+
+	bidderImpl.err = errors.New("Bad response body")
+	bidder.requestBid(context.Background(), &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates())
+	list := capture.Responses()[openrtb_ext.BidderAppnexus]
+	if len(list) != 1 || list[0].ResponseBody != "not json" || list[0].RequestBody != "{\"id\":\"req\"}" {
+		t.Errorf("The malformed response should be captured with its request. Got %v", list)
+	}
+}
+
+// malformedResponseBidder makes one request, and returns err from MakeBids.
+type malformedResponseBidder struct {
+	uri string
+	err error
+}
+
+func (bidder *malformedResponseBidder) MakeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	return []*adapters.RequestData{{
+		Method:  "POST",
+		Uri:     bidder.uri,
+		Body:    []byte("{\"id\":\"req\"}"),
+		Headers: http.Header{},
+	}}, nil
+}
+
+func (bidder *malformedResponseBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	if bidder.err != nil {
+		return nil, []error{bidder.err}
+	}
+	return &adapters.BidderResponse{}, nil
+}
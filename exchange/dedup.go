@@ -0,0 +1,95 @@
+package exchange
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/prebid/prebid-server/adapters"
+)
+
+// callDedup shares the HTTP calls made by the bidders in one auction. If a bidder would send a byte-identical request
+// to the same server, with the same client, as a call which was already made, it gets that call's response instead.
+// This usually happens with aliases of the same bidder, which were given the same params.
+type callDedup struct {
+	lock  sync.Mutex
+	calls map[string]*sharedCall
+}
+
+// sharedCall is an HTTP call which may be shared. info is set once done is closed.
+type sharedCall struct {
+	done chan struct{}
+	info *httpCallInfo
+}
+
+type callDedupKey struct{}
+
+// withCallDedup returns a context whose auction shares identical HTTP calls.
+func withCallDedup(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callDedupKey{}, &callDedup{calls: make(map[string]*sharedCall)})
+}
+
+// callDedupFrom returns the context's callDedup, or nil if its auction doesn't share calls.
+func callDedupFrom(ctx context.Context) *callDedup {
+	dedup, _ := ctx.Value(callDedupKey{}).(*callDedup)
+	return dedup
+}
+
+// do returns the info for req. The first bidder to make each call runs it. The others wait for it to finish,
+// and get a copy of its info. If d is nil, every call is run.
+func (d *callDedup) do(client *http.Client, req *adapters.RequestData, call func() *httpCallInfo) *httpCallInfo {
+	if d == nil {
+		return call()
+	}
+	key := callKey(client, req)
+	d.lock.Lock()
+	if shared, ok := d.calls[key]; ok {
+		d.lock.Unlock()
+		<-shared.done
+		return shared.info.sharedWith(req)
+	}
+	shared := &sharedCall{done: make(chan struct{})}
+	d.calls[key] = shared
+	d.lock.Unlock()
+
+	shared.info = call()
+	close(shared.done)
+	return shared.info
+}
+
+// callKey identifies the calls which would be identical. Bidders with their own client, such as ones with a client
+// certificate or proxy, never share calls with the others.
+func callKey(client *http.Client, req *adapters.RequestData) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%p\n%s\n%s\n", client, req.Method, req.Uri)
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s: %q\n", name, req.Headers[name])
+	}
+	hash.Write(req.Body)
+	return string(hash.Sum(nil))
+}
+
+// sharedWith returns a copy of the info for another bidder's identical request. Bidders may change the response,
+// so it gets a body of its own. The retry and timings aren't copied, so that they're only counted once in the metrics.
+func (info *httpCallInfo) sharedWith(req *adapters.RequestData) *httpCallInfo {
+	shared := &httpCallInfo{
+		request: req,
+		err:     info.err,
+	}
+	if info.response != nil {
+		shared.response = &adapters.ResponseData{
+			StatusCode: info.response.StatusCode,
+			Body:       append([]byte(nil), info.response.Body...),
+			Headers:    info.response.Headers,
+		}
+	}
+	return shared
+}
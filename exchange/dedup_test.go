@@ -0,0 +1,85 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters"
+)
+
+func TestCallDedup(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte("response"))
+	}))
+	defer server.Close()
+
+	alias := &bidderAdapter{Client: server.Client()}
+	core := &bidderAdapter{Client: server.Client()}
+	ctx := withCallDedup(context.Background())
+
+	var wg sync.WaitGroup
+	infos := make([]*httpCallInfo, 4)
+	for i, bidder := range []*bidderAdapter{alias, core, alias, core} {
+		wg.Add(1)
+		go func(i int, bidder *bidderAdapter) {
+			defer wg.Done()
+			infos[i] = bidder.doRequest(ctx, newDedupRequest(server.URL, "body"))
+		}(i, bidder)
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("Identical requests should share one call. Got %d calls", calls)
+	}
+	for i, info := range infos {
+		if info.err != nil || string(info.response.Body) != "response" {
+			t.Errorf("Each bidder should get the response. Bidder %d got %v", i, info)
+		}
+	}
+	infos[0].response.Body[0] = 'R'
+	if string(infos[1].response.Body) != "response" {
+		t.Errorf("Each bidder should get its own copy of the response body.")
+	}
+
+	core.doRequest(ctx, newDedupRequest(server.URL, "other body"))
+	if calls != 2 {
+		t.Errorf("Requests with different bodies shouldn't share calls. Got %d calls", calls)
+	}
+	(&bidderAdapter{Client: &http.Client{}}).doRequest(ctx, newDedupRequest(server.URL, "body"))
+	if calls != 3 {
+		t.Errorf("Bidders with their own client shouldn't share calls. Got %d calls", calls)
+	}
+	core.doRequest(context.Background(), newDedupRequest(server.URL, "body"))
+	if calls != 4 {
+		t.Errorf("Calls shouldn't be shared unless the auction enables it. Got %d calls", calls)
+	}
+}
+
+func TestCallKeyHeaders(t *testing.T) {
+	client := &http.Client{}
+	first := newDedupRequest("http://bidder.com", "body")
+	second := newDedupRequest("http://bidder.com", "body")
+	if callKey(client, first) != callKey(client, second) {
+		t.Errorf("Identical requests should have the same key.")
+	}
+	second.Headers.Set("X-Seat", "alias")
+	if callKey(client, first) == callKey(client, second) {
+		t.Errorf("Requests with different headers should have different keys.")
+	}
+}
+
+func newDedupRequest(uri string, body string) *adapters.RequestData {
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	return &adapters.RequestData{
+		Method:  "POST",
+		Uri:     uri,
+		Body:    []byte(body),
+		Headers: headers,
+	}
+}
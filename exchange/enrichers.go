@@ -0,0 +1,37 @@
+package exchange
+
+import (
+	"net/http"
+
+	"github.com/prebid/prebid-server/adstxt"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/creativescan"
+	"github.com/prebid/prebid-server/geolocation"
+	"github.com/prebid/prebid-server/rtd"
+)
+
+// Enrichers are the parts of the auction which call out to other services, or read databases, to add data to the
+// requests and check the bids. They keep caches, open files and background goroutines, so they're built once on
+// startup, and shared by every Exchange which is built as the config is reloaded.
+//
+// Any of them may be nil, if it's turned off.
+type Enrichers struct {
+	Geo     *geolocation.Enricher
+	RTD     *rtd.Enricher
+	AdsTxt  *adstxt.Validator
+	Scanner *creativescan.Scanner
+}
+
+// NewEnrichers builds the enrichers which are turned on in cfg.
+func NewEnrichers(cfg *config.Configuration, client *http.Client) (*Enrichers, error) {
+	geo, err := geolocation.NewEnricher(&cfg.GeoEnrichment)
+	if err != nil {
+		return nil, err
+	}
+	return &Enrichers{
+		Geo:     geo,
+		RTD:     rtd.NewEnricher(&cfg.RTD, client),
+		AdsTxt:  adstxt.NewValidator(&cfg.AdsTxt, client),
+		Scanner: creativescan.NewScanner(&cfg.CreativeScanning, client),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+)
+
+func TestEnrichersShared(t *testing.T) {
+	cfg := &config.Configuration{
+		AdsTxt:           config.AdsTxt{Enabled: true, RefreshHours: 1},
+		RTD:              config.RTD{Modules: []config.RTDModule{{Name: "weather", Endpoint: "http://rtd.com"}}},
+		CreativeScanning: config.CreativeScanning{Endpoint: "http://scanner.com"},
+	}
+	enrichers, err := NewEnrichers(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("Failed to build the enrichers: %v", err)
+	}
+	if enrichers.Geo != nil || enrichers.RTD == nil || enrichers.AdsTxt == nil || enrichers.Scanner == nil {
+		t.Fatalf("Only the enrichers which are turned on should be built. Got %+v", enrichers)
+	}
+
+	build := func() *exchange {
+		return NewExchange(http.DefaultClient, noCache{}, cfg, &metricsConf.DummyMetricsEngine{}, gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil), nil, nil, nil, enrichers).(*exchange)
+	}
+	first, reloaded := build(), build()
+	if first.rtd != reloaded.rtd || first.adsTxt != reloaded.adsTxt || first.scanner != reloaded.scanner {
+		t.Errorf("Exchanges built on reload should share the enrichers, rather than starting new ones.")
+	}
+}
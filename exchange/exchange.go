@@ -3,20 +3,35 @@ package exchange
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/prebid/prebid-server/adapters"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 
 	"github.com/mxmCherry/openrtb"
 
+	"github.com/prebid/prebid-server/adstxt"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/creativescan"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/geolocation"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/prebid_cache_client"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/prebid/prebid-server/rtd"
+	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/tracing"
+	"github.com/prebid/prebid-server/usersync/usersyncers"
 )
 
 // Exchange runs Auctions. Implementations must be threadsafe, and will be shared across many goroutines.
@@ -31,17 +46,88 @@ type IdFetcher interface {
 	GetId(bidder openrtb_ext.BidderName) (string, bool)
 }
 
+// liveIdFetcher is implemented by IdFetchers which know when their IDs expire, like the uids cookie.
+type liveIdFetcher interface {
+	// GetLiveId is like GetId, but ignores IDs which have expired.
+	GetLiveId(bidder openrtb_ext.BidderName) (string, bool)
+}
+
+// liveIds is an IdFetcher which ignores expired IDs.
+type liveIds struct {
+	fetcher liveIdFetcher
+}
+
+func (ids liveIds) GetId(bidder openrtb_ext.BidderName) (string, bool) {
+	return ids.fetcher.GetLiveId(bidder)
+}
+
 type exchange struct {
 	adapterMap map[openrtb_ext.BidderName]adaptedBidder
 	me         pbsmetrics.MetricsEngine
 	cache      prebid_cache_client.Client
 	cacheTime  time.Duration
+	gDPR       gdpr.Permissions
+	lmt        config.LMT
+	activities config.Activities
+	vendorIDs  map[openrtb_ext.BidderName]uint16
+	geoPrivacy config.GeoPrivacy
+	buyerUIDs  config.BuyerUIDs
+	targeting  config.Targeting
+	// privacyDefaults are used for requests which don't carry their own GDPR or CCPA signals.
+	privacyDefaults config.PrivacyDefaults
+	// partialResponses decides which accounts get a response at the deadline, without the slow bidders.
+	partialResponses config.PartialResponses
+	// tieBreaking decides which bid wins when bids in an imp have the same price.
+	tieBreaking config.TieBreaking
+	// debug decides whether response.ext.debug has the personal data removed, for the accounts which don't say.
+	debug config.Debug
+	// priceCeilings reject bids which are priced too high to be real.
+	priceCeilings config.PriceCeilings
+	// accounts have the settings of the known accounts, such as their seat floors, failover groups and blocking.
+	accounts config.Accounts
+	// failover decides whether there's time for the fallback bidders, which get the imps that each account's primary
+	// bidders don't bid on.
+	failover config.Failover
+	// pinnedDeals are each account's deals which win their imp whenever they bid, by account ID.
+	pinnedDeals map[string]pinnedDeals
+	// revenueShare is the host's fee, which is deducted from the bids' prices.
+	revenueShare config.RevenueShare
+	// bidValidation decides which extra checks are run on the bids.
+	bidValidation config.BidValidation
+	// maxResponseBytes caps the size of the responses, or is 0 if there's no cap.
+	maxResponseBytes int
+	// dedupCalls makes the bidders in an auction share identical HTTP calls.
+	dedupCalls bool
+	// events decides which accounts' native bids get trackers, which report to the eventURL.
+	events   config.Events
+	eventURL string
+	// currencyConverter may be nil, in which case bids can only be made in the request's currency.
+	currencyConverter    *currencies.RateConverter
+	intermediateCurrency string
+	cacheBatchSize       int
+	allowCacheAsync      bool
+	cacheAsyncTimeout    time.Duration
+	// cacheTTL decides how long each cached bid lives.
+	cacheTTL config.CacheTTL
+	// vastWrapperBidders are the bidders whose nurl-only video bids get a generated VAST wrapper.
+	vastWrapperBidders map[openrtb_ext.BidderName]bool
+	// categories fetches the mappings used for brand categories. It may be nil, if none are configured.
+	categories stored_requests.CategoryFetcher
+	// geo adds device.geo to the requests which don't have it. It's nil if geo enrichment is off.
+	geo *geolocation.Enricher
+	// rtd adds the real-time data modules' segments to the requests. It's nil if there aren't any modules.
+	rtd *rtd.Enricher
+	// adsTxt checks which bidders the publishers' ads.txt files authorize. It's nil if the checks are off.
+	adsTxt *adstxt.Validator
+	// scanner has a creative security service check the bids. It's nil if creative scanning is off.
+	scanner *creativescan.Scanner
 }
 
 // Container to pass out response ext data from the GetAllBids goroutines back into the main thread
 type seatResponseExtra struct {
 	ResponseTimeMillis int
 	Errors             []string
+	Warnings           []string
 }
 
 type bidResponseWrapper struct {
@@ -50,22 +136,93 @@ type bidResponseWrapper struct {
 	bidder       openrtb_ext.BidderName
 }
 
-func NewExchange(client *http.Client, cache prebid_cache_client.Client, cfg *config.Configuration, metricsEngine pbsmetrics.MetricsEngine) Exchange {
+func NewExchange(client *http.Client, cache prebid_cache_client.Client, cfg *config.Configuration, metricsEngine pbsmetrics.MetricsEngine, gDPR gdpr.Permissions, currencyConverter *currencies.RateConverter, categories stored_requests.CategoryFetcher, capture *ResponseCapture, enrichers *Enrichers) Exchange {
 	e := new(exchange)
 
 	e.adapterMap = newAdapterMap(client, cfg)
+	for bidderName, adapted := range e.adapterMap {
+		if bidder, ok := adapted.(*bidderAdapter); ok {
+			bidder.capture = capture.forBidder(bidderName)
+		}
+	}
 	e.cache = cache
 	e.cacheTime = time.Duration(cfg.CacheURL.ExpectedTimeMillis) * time.Millisecond
+	e.cacheBatchSize = cfg.CacheURL.MaxBatchSize
+	e.allowCacheAsync = cfg.CacheURL.AllowAsync
+	e.cacheAsyncTimeout = time.Duration(cfg.CacheURL.AsyncTimeoutMillis) * time.Millisecond
+	e.cacheTTL = cfg.CacheURL.TTL
 	e.me = metricsEngine
+	e.gDPR = gDPR
+	e.lmt = cfg.LMT
+	e.activities = cfg.Activities
+	e.geoPrivacy = cfg.GeoPrivacy
+	e.privacyDefaults = cfg.PrivacyDefaults
+	e.buyerUIDs = cfg.BuyerUIDs
+	e.targeting = cfg.Targeting
+	e.partialResponses = cfg.PartialResponses
+	e.tieBreaking = cfg.TieBreaking
+	e.debug = cfg.Debug
+	e.priceCeilings = cfg.PriceCeilings
+	e.accounts = cfg.Accounts
+	e.failover = cfg.Failover
+	e.pinnedDeals = newPinnedDeals(cfg.Accounts.Known)
+	e.revenueShare = cfg.RevenueShare
+	e.bidValidation = cfg.BidValidation
+	e.maxResponseBytes = cfg.ResponseSize.MaxBytes
+	e.dedupCalls = cfg.AdapterDedup.Enabled
+	e.events = cfg.Events
+	e.eventURL = strings.TrimSuffix(cfg.ExternalURL, "/") + "/event"
+	e.currencyConverter = currencyConverter
+	e.categories = categories
+	if enrichers != nil {
+		e.geo = enrichers.Geo
+		e.rtd = enrichers.RTD
+		e.adsTxt = enrichers.AdsTxt
+		e.scanner = enrichers.Scanner
+	}
+	e.intermediateCurrency = cfg.CurrencyConverter.IntermediateCurrency
+	e.vendorIDs = usersyncers.GDPRAwareSyncerIDs(usersyncers.NewSyncerMap(cfg))
+	// Aliases belong to the same vendor as their core bidder, even if they don't sync users.
+	for _, bidderName := range openrtb_ext.BidderMap {
+		if _, ok := e.vendorIDs[bidderName]; !ok && openrtb_ext.IsAlias(bidderName) {
+			if vendorID, ok := e.vendorIDs[openrtb_ext.CoreBidderName(bidderName)]; ok {
+				e.vendorIDs[bidderName] = vendorID
+			}
+		}
+	}
+	e.vastWrapperBidders = make(map[openrtb_ext.BidderName]bool)
+	for _, bidderName := range openrtb_ext.BidderMap {
+		if adapterConfig(cfg, bidderName).GenerateVASTWrapper {
+			e.vastWrapperBidders[bidderName] = true
+		}
+	}
 	return e
 }
 
 func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	ctx, span := tracing.StartSpan(ctx, "exchange.auction", tracing.Int("imps", len(bidRequest.Imp)))
+	defer span.End()
+
+	// The policies which the request brings with it apply to the enrichers as well as the bidders.
+	// GDPR is added later, since the consent string's permissions are looked up for each bidder.
+	regime := privacy.DetectRegime(&e.geoPrivacy, bidRequest)
+	privacyDefaults := e.privacyDefaults.ForChannel(privacyChannel(labels.RType))
+	control := privacy.NewActivityControl(&e.activities, accountID(bidRequest)).WithPolicies(
+		privacy.NewCOPPAPolicy(bidRequest),
+		privacy.NewCCPAPolicy(bidRequest, privacyDefaults.CCPAOptOut),
+		privacy.NewLMTPolicy(&e.lmt, bidRequest),
+	)
+
+	// device.geo and the real-time data are added first, so that they're in the debug snapshot and every bidder's
+	// request, and so that the privacy rules apply to them. The geo comes first, so that the modules can use it.
+	e.geo.Enrich(ctx, bidRequest, control)
+	e.rtd.Enrich(ctx, bidRequest, control)
+
 	// Snapshot of resolved bid request for debug if test request
 	var resolvedRequest json.RawMessage
-	if bidRequest.Test == 1 {
+	if bidRequest.Test == 1 && debugAllowed(ctx) {
 		if r, err := json.Marshal(bidRequest); err != nil {
-			glog.Errorf("Error marshalling bid request for debug: %v", err)
+			logger.FromContext(ctx).With("account", accountID(bidRequest)).Errorf("Error marshalling bid request for debug: %v", err)
 		} else {
 			resolvedRequest = r
 		}
@@ -73,7 +230,29 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 
 	// Slice of BidRequests, each a copy of the original cleaned to only contain bidder data for the named bidder
 	blabels := make(map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels)
+	if fetcher, ok := usersyncs.(liveIdFetcher); ok && e.buyerUIDs.DropExpired {
+		usersyncs = liveIds{fetcher}
+	}
 	cleanRequests, aliases, errs := cleanOpenRTBRequests(bidRequest, usersyncs, blabels, labels)
+	errs = append(errs, dropDisabledBidders(e.adapterMap, cleanRequests, aliases)...)
+	adsTxtErrs, adsTxtWarnings := checkAdsTxt(e.adsTxt, bidRequest, cleanRequests, aliases)
+	errs = append(errs, adsTxtErrs...)
+	if e.buyerUIDs.SkipUnmatchedBidders {
+		skipUnmatchedBidders(&e.buyerUIDs, bidRequest, cleanRequests, aliases)
+	}
+	gdprPolicy, gdprErrs := newGDPRPolicy(ctx, e.gDPR, bidRequest, cleanRequests, aliases, defaultGDPRSignal(regime, privacyDefaults))
+	errs = append(errs, gdprErrs...)
+	if regime != nil && regime.Regime == config.RegimeUSState {
+		if err := addGPPSID(bidRequest, cleanRequests, regime.GPPSID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for policy := range enforceActivities(control.WithPolicies(gdprPolicy), e.vendorIDs, cleanRequests, aliases) {
+		e.me.RecordPrivacyScrubbed(pbsmetrics.PrivacyPolicy(policy))
+	}
+	addBlocks(&e.knownAccount(accountID(bidRequest)).Blocking, cleanRequests)
+	// Fallback bidders wait for their primary bidders' bids, so they're left out of the first wave.
+	heldFallbacks := holdFallbacks(e.knownAccount(accountID(bidRequest)).Failover, cleanRequests)
 	// List of bidders we have requests for.
 	liveAdapters := make([]openrtb_ext.BidderName, len(cleanRequests))
 	i := 0
@@ -86,7 +265,9 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 	// Process the request to check for targeting parameters.
 	var targData *targetData
 	shouldCacheBids := false
+	asyncCache := false
 	var bidAdjustmentFactors map[string]float64
+	var currencyExt *openrtb_ext.ExtRequestCurrency
 	if len(bidRequest.Ext) > 0 {
 		var requestExt openrtb_ext.ExtRequest
 		err := json.Unmarshal(bidRequest.Ext, &requestExt)
@@ -94,36 +275,135 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 			return nil, fmt.Errorf("Error decoding Request.ext : %s", err.Error())
 		}
 		bidAdjustmentFactors = requestExt.Prebid.BidAdjustmentFactors
+		currencyExt = requestExt.Prebid.CurrencyConversions
 		shouldCacheBids = requestExt.Prebid.Cache != nil && requestExt.Prebid.Cache.Bids != nil
+		asyncCache = shouldCacheBids && requestExt.Prebid.Cache.Async
 
 		if requestExt.Prebid.Targeting != nil {
 			targData = &targetData{
-				priceGranularity:  requestExt.Prebid.Targeting.PriceGranularity,
-				includeWinners:    requestExt.Prebid.Targeting.IncludeWinners,
-				includeBidderKeys: requestExt.Prebid.Targeting.IncludeBidderKeys,
+				priceGranularity:     requestExt.Prebid.Targeting.PriceGranularity,
+				includeWinners:       requestExt.Prebid.Targeting.IncludeWinners,
+				includeBidderKeys:    requestExt.Prebid.Targeting.IncludeBidderKeys,
+				includeFormat:        requestExt.Prebid.Targeting.IncludeFormat,
+				maxKeys:              requestExt.Prebid.Targeting.MaxKeys,
+				staticKeys:           e.targeting.StaticKeysFor(accountID(bidRequest)),
+				durationRanges:       requestExt.Prebid.Targeting.DurationRangeSec,
+				requireExactDuration: requestExt.Prebid.Targeting.RequireExactDuration,
+			}
+			if requestExt.Prebid.SupportDeals {
+				targData.dealTiers = readDealTiers(bidRequest.Imp)
 			}
 			if shouldCacheBids {
 				targData.includeCache = true
 			}
+			if brandCategory := requestExt.Prebid.Targeting.IncludeBrandCategory; brandCategory != nil {
+				targData.includeBrandCategory = true
+				var err error
+				if targData.brandCategories, err = e.fetchBrandCategories(ctx, brandCategory); err != nil {
+					errs = append(errs, err)
+				}
+			}
 		}
 	}
 
+	// Async writes must be allowed by the host, and need a client which can save bids under IDs chosen ahead of time.
+	keyedCache, canCacheAsync := e.cache.(prebid_cache_client.KeyedClient)
+	asyncCache = asyncCache && canCacheAsync && e.allowCacheAsync
+
 	// If we need to cache bids, then it will take some time to call prebid cache.
 	// We should reduce the amount of time the bidders have, to compensate.
-	auctionCtx, cancel := e.makeAuctionContext(ctx, shouldCacheBids)
+	// Async writes happen after the response is sent, so they don't need any of the bidders' time.
+	auctionCtx, cancel := e.makeAuctionContext(ctx, shouldCacheBids && !asyncCache)
 	defer cancel()
+	if e.dedupCalls {
+		auctionCtx = withCallDedup(auctionCtx)
+	}
 
-	adapterBids, adapterExtra := e.getAllBids(auctionCtx, cleanRequests, aliases, bidAdjustmentFactors, blabels)
-	auc := newAuction(adapterBids, len(bidRequest.Imp))
+	conversions := e.getConversions(currencyExt)
+	partial := e.partialResponses.EnabledFor(accountID(bidRequest))
+	adapterBids, adapterExtra := e.getAllBids(auctionCtx, cleanRequests, aliases, bidAdjustmentFactors, conversions, blabels, accountID(bidRequest), partial)
+	pinned := e.pinnedDeals[accountID(bidRequest)]
+	if fallbacks := fallbackRequests(heldFallbacks, adapterBids, pinned.pinnedImps(adapterBids)); len(fallbacks) > 0 && hasTimeLeft(auctionCtx, e.failover.MinRemainingMillis) {
+		fallbackBids, fallbackExtra := e.getAllBids(auctionCtx, fallbacks, aliases, bidAdjustmentFactors, conversions, blabels, accountID(bidRequest), partial)
+		liveAdapters = append(liveAdapters, mergeFallbacks(adapterBids, adapterExtra, fallbackBids, fallbackExtra)...)
+	}
+	for bidder, warning := range adsTxtWarnings {
+		if extra, ok := adapterExtra[bidder]; ok {
+			extra.Warnings = append(extra.Warnings, warning)
+		}
+	}
+	targData.dropUnfitDurations(adapterBids, adapterExtra)
+	ties := newTieBreaker(e.tieBreaking, bidRequest)
+	ties.pinned = pinned
+	auc := newAuction(adapterBids, len(bidRequest.Imp), ties)
+	e.recordWins(auc, blabels, aliases)
 	if targData != nil {
 		auc.setRoundedPrices(targData.priceGranularity)
 		if targData.includeCache {
-			auc.doCache(ctx, e.cache)
+			if asyncCache {
+				auc.doCacheAsync(keyedCache, e.cacheBatchSize, e.cacheAsyncTimeout, &e.cacheTTL, accountID(bidRequest))
+			} else {
+				auc.doCache(ctx, e.cache, e.cacheBatchSize, &e.cacheTTL, accountID(bidRequest))
+			}
 		}
 		targData.setTargeting(auc, bidRequest.App != nil)
 	}
 	// Build the response
-	return e.buildBidResponse(ctx, liveAdapters, adapterBids, bidRequest, resolvedRequest, adapterExtra, errs)
+	bidResponse, err := e.buildBidResponse(ctx, liveAdapters, adapterBids, bidRequest, resolvedRequest, adapterExtra, errs)
+	if err == nil && e.maxResponseBytes > 0 {
+		if pruneErr := pruneResponse(bidResponse, e.maxResponseBytes, keptBids(auc, adapterBids)); pruneErr != nil {
+			logger.FromContext(ctx).With("account", accountID(bidRequest)).Errorf("Error pruning the response: %v", pruneErr)
+		}
+	}
+	return bidResponse, err
+}
+
+// fetchBrandCategories returns the mapping from IAB categories to the primary ad server's. The publisher's own mapping
+// is used if it has one, and the ad server's default mapping otherwise.
+func (e *exchange) fetchBrandCategories(ctx context.Context, brandCategory *openrtb_ext.ExtIncludeBrandCategory) (map[string]string, error) {
+	if e.categories == nil {
+		return nil, errors.New("ext.prebid.targeting.includebrandcategory can't be used, because this server has no category mappings")
+	}
+	adServer := brandCategory.PrimaryAdServerName()
+	if brandCategory.Publisher != "" {
+		categories, err := e.categories.FetchCategories(ctx, adServer, brandCategory.Publisher)
+		if _, notFound := err.(stored_requests.NotFoundError); !notFound {
+			return categories, err
+		}
+	}
+	return e.categories.FetchCategories(ctx, adServer, "")
+}
+
+// getConversions returns the rates used to convert bids into the request's currency.
+// Custom rates from the request take precedence over the server's, and replace them entirely if usepbsrates is false.
+// Missing rates are derived through the intermediate currency, if there is one.
+func (e *exchange) getConversions(currencyExt *openrtb_ext.ExtRequestCurrency) currencies.Conversions {
+	return currencies.NewCrossRates(e.getRates(currencyExt), e.intermediateCurrency, e.recordCurrencyConversion)
+}
+
+func (e *exchange) getRates(currencyExt *openrtb_ext.ExtRequestCurrency) currencies.Conversions {
+	var serverRates currencies.Conversions
+	if e.currencyConverter != nil {
+		serverRates = e.currencyConverter.Rates()
+	} else {
+		serverRates = currencies.NewConstantRates()
+	}
+	if currencyExt == nil || len(currencyExt.ConversionRates) == 0 {
+		return serverRates
+	}
+	customRates := currencies.NewRates(time.Time{}, currencyExt.ConversionRates)
+	if currencyExt.UsePBSRates != nil && !*currencyExt.UsePBSRates {
+		return customRates
+	}
+	return currencies.NewAggregateConversions(customRates, serverRates)
+}
+
+func (e *exchange) recordCurrencyConversion(derived bool) {
+	if derived {
+		e.me.RecordCurrencyConversion(pbsmetrics.CurrencyConversionDerived)
+	} else {
+		e.me.RecordCurrencyConversion(pbsmetrics.CurrencyConversionDirect)
+	}
 }
 
 func (e *exchange) makeAuctionContext(ctx context.Context, needsCache bool) (auctionCtx context.Context, cancel func()) {
@@ -137,20 +417,42 @@ func (e *exchange) makeAuctionContext(ctx context.Context, needsCache bool) (auc
 	return
 }
 
+// knownAccount returns the account's settings from accounts.known, or empty settings if it isn't listed.
+func (e *exchange) knownAccount(id string) *config.Account {
+	if account := e.accounts.Lookup(id); account != nil {
+		return account
+	}
+	return &config.Account{}
+}
+
 // This piece sends all the requests to the bidder adapters and gathers the results.
-func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, bidAdjustments map[string]float64, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels) (map[openrtb_ext.BidderName]*pbsOrtbSeatBid, map[openrtb_ext.BidderName]*seatResponseExtra) {
+func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, bidAdjustments map[string]float64, conversions currencies.Conversions, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, account string, partial bool) (map[openrtb_ext.BidderName]*pbsOrtbSeatBid, map[openrtb_ext.BidderName]*seatResponseExtra) {
 	// Set up pointers to the bid results
 	adapterBids := make(map[openrtb_ext.BidderName]*pbsOrtbSeatBid, len(cleanRequests))
 	adapterExtra := make(map[openrtb_ext.BidderName]*seatResponseExtra, len(cleanRequests))
 	chBids := make(chan *bidResponseWrapper, len(cleanRequests))
+	trackNative := e.events.EnabledFor(account)
+	settings := e.knownAccount(account)
+	// If the auction ends early, the bidders which are still running are cancelled.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// With early termination, the auction ends once none of these imps are left without a pinned deal's bid.
+	pinned := e.pinnedDeals[account]
+	var unpinnedImps map[string]bool
+	if pinned.earlyTermination {
+		unpinnedImps = impIDs(cleanRequests)
+	}
 
 	for bidderName, req := range cleanRequests {
 		// Here we actually call the adapters and collect the bids.
 		coreBidder := resolveBidder(string(bidderName), aliases)
-		go func(aName openrtb_ext.BidderName, coreBidder openrtb_ext.BidderName, request *openrtb.BidRequest, bidlabels *pbsmetrics.AdapterLabels) {
+		// Each goroutine fills in its own copy of the labels. Bidders which the auction stops waiting for are still
+		// running, so they mustn't share them with the bidders which finished, or with the win metrics.
+		go func(aName openrtb_ext.BidderName, coreBidder openrtb_ext.BidderName, request *openrtb.BidRequest, labels pbsmetrics.AdapterLabels) {
 			// Passing in aName so a doesn't change out from under the go routine
+			bidlabels := &labels
 			if bidlabels.Adapter == "" {
-				glog.Errorf("Exchange: bidlables for %s (%s) missing adapter string", aName, coreBidder)
+				logger.FromContext(ctx).With("bidder", aName).Errorf("Exchange: bidlables for %s (%s) missing adapter string", aName, coreBidder)
 				bidlabels.Adapter = coreBidder
 			}
 			brw := new(bidResponseWrapper)
@@ -160,12 +462,14 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 				e.me.RecordAdapterRequest(*bidlabels)
 			}()
 			start := time.Now()
+			bidderCtx, span := tracing.StartSpan(ctx, "exchange.bidder", tracing.String("bidder", string(aName)))
+			defer span.End()
 
 			adjustmentFactor := 1.0
 			if givenAdjustment, ok := bidAdjustments[string(aName)]; ok {
 				adjustmentFactor = givenAdjustment
 			}
-			bids, err := e.adapterMap[coreBidder].requestBid(ctx, request, aName, adjustmentFactor)
+			bids, err := e.callBidder(bidderCtx, coreBidder, request, aName, adjustmentFactor, conversions)
 
 			// Add in time reporting
 			elapsed := time.Since(start)
@@ -175,6 +479,36 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 			if len(err2) > 0 {
 				err = append(err, err2...)
 			}
+			// Prices are in the request's currency by now, so they can be compared with the ceiling.
+			rejected := brw.enforcePriceCeiling(e.priceCeilings.CeilingFor(account, string(aName)))
+			for range rejected {
+				e.me.RecordAdapterBidRejected(*bidlabels, pbsmetrics.AdapterBidRejectedPriceCeiling)
+			}
+			err = append(err, rejected...)
+			rejectedFloor := brw.enforceSeatFloor(settings.SeatFloors[string(aName)])
+			for range rejectedFloor {
+				e.me.RecordAdapterBidRejected(*bidlabels, pbsmetrics.AdapterBidRejectedSeatFloor)
+			}
+			err = append(err, rejectedFloor...)
+			rejectedBlocked := brw.enforceBlocks(settings.Blocking.BlockedFor(string(aName)))
+			for range rejectedBlocked {
+				e.me.RecordAdapterBidRejected(*bidlabels, pbsmetrics.AdapterBidRejectedBlocked)
+			}
+			err = append(err, rejectedBlocked...)
+			rejectedVAST, vastWarnings := brw.validateVAST(e.bidValidation.VASTFor(settings))
+			for range rejectedVAST {
+				e.me.RecordAdapterBidRejected(*bidlabels, pbsmetrics.AdapterBidRejectedInvalidVAST)
+			}
+			err = append(err, rejectedVAST...)
+			err = append(err, vastWarnings...)
+			// The scan comes last, so that the service isn't sent the bids which were already rejected.
+			rejectedScan, scanWarnings := brw.scanCreatives(bidderCtx, e.scanner)
+			for range rejectedScan {
+				e.me.RecordAdapterBidRejected(*bidlabels, pbsmetrics.AdapterBidRejectedCreativeScan)
+			}
+			err = append(err, rejectedScan...)
+			err = append(err, scanWarnings...)
+			err, warnings := splitWarnings(err)
 			// Structure to record extra tracking data generated during bidding
 			ae := new(seatResponseExtra)
 			ae.ResponseTimeMillis = int(elapsed / time.Millisecond)
@@ -183,29 +517,89 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 			serr := errsToStrings(err)
 			bidlabels.AdapterBids = bidsToMetric(bids)
 			bidlabels.AdapterErrors = errorsToMetric(err)
+			if bidlabels.AdapterBids == pbsmetrics.AdapterBidNone {
+				e.me.RecordAdapterNoBid(*bidlabels, noBidReason(bids, bidlabels.AdapterErrors, len(err2) > 0 || len(rejected) > 0 || len(rejectedFloor) > 0 || len(rejectedBlocked) > 0 || len(rejectedVAST) > 0 || len(rejectedScan) > 0))
+			}
 			// Append any bid validation errors to the error list
 			ae.Errors = serr
+			ae.Warnings = errsToStrings(warnings)
 			brw.adapterExtra = ae
 			if bids != nil {
+				for _, result := range bids.retries {
+					e.me.RecordAdapterRetry(*bidlabels, result)
+				}
+				for _, timings := range bids.timings {
+					timings.record(e.me, *bidlabels)
+				}
 				for _, bid := range bids.bids {
 					var cpm = float64(bid.bid.Price * 1000)
 					e.me.RecordAdapterPrice(*bidlabels, cpm)
 					e.me.RecordAdapterBidReceived(*bidlabels, bid.bidType, bid.bid.AdM != "")
 				}
 			}
+			// The fee is deducted after the metrics, so that they record the prices which the bidders bid.
+			applyRevenueShare(bids, e.revenueShare.PercentFor(account))
+			// This happens after the metrics, so that they still count the bids which came without an adm.
+			if e.vastWrapperBidders[coreBidder] {
+				addVASTWrappers(bids)
+			}
+			if trackNative {
+				addNativeTrackers(bids, e.eventURL, e.events.Viewable, aName, account)
+			}
+			addRenderers(bids, &settings.Renderers)
 			chBids <- brw
-		}(bidderName, coreBidder, req, blabels[coreBidder])
+		}(bidderName, coreBidder, req, *blabels[coreBidder])
 	}
-	// Wait for the bidders to do their thing
+	// Wait for the bidders to do their thing.
+	// For partial responses, stop waiting at the deadline. A nil channel never fires, so otherwise this waits for them all.
+	var deadline <-chan struct{}
+	if partial {
+		deadline = ctx.Done()
+	}
+	start := time.Now()
 	for i := 0; i < len(cleanRequests); i++ {
-		brw := <-chBids
-		adapterBids[brw.bidder] = brw.adapterBids
-		adapterExtra[brw.bidder] = brw.adapterExtra
+		select {
+		case brw := <-chBids:
+			if brw.adapterBids != nil {
+				brw.adapterBids.arrival = i
+			}
+			adapterBids[brw.bidder] = brw.adapterBids
+			adapterExtra[brw.bidder] = brw.adapterExtra
+			if pinned.earlyTermination && brw.adapterBids != nil {
+				for _, bid := range brw.adapterBids.bids {
+					if pinned.isPinned(bid) {
+						delete(unpinnedImps, bid.bid.ImpID)
+					}
+				}
+				if len(unpinnedImps) == 0 {
+					skipUnfinishedBidders(cleanRequests, adapterExtra, start, "The auction ended before the bidder responded, because every imp had a bid from a pinned deal.")
+					return adapterBids, adapterExtra
+				}
+			}
+		case <-deadline:
+			skipUnfinishedBidders(cleanRequests, adapterExtra, start, "The bidder didn't respond before the auction's deadline, so the response was sent without it.")
+			return adapterBids, adapterExtra
+		}
 	}
 
 	return adapterBids, adapterExtra
 }
 
+// skipUnfinishedBidders gives each bidder which hasn't responded a response ext with the error, when the auction ends
+// without them. Their goroutines will still finish, and record their metrics, once their calls are cancelled.
+// chBids has room for all of them, so they won't block.
+func skipUnfinishedBidders(cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, start time.Time, message string) {
+	elapsed := int(time.Since(start) / time.Millisecond)
+	for bidderName := range cleanRequests {
+		if _, ok := adapterExtra[bidderName]; !ok {
+			adapterExtra[bidderName] = &seatResponseExtra{
+				ResponseTimeMillis: elapsed,
+				Errors:             []string{message},
+			}
+		}
+	}
+}
+
 func bidsToMetric(bids *pbsOrtbSeatBid) pbsmetrics.AdapterBid {
 	if bids == nil || len(bids.bids) == 0 {
 		return pbsmetrics.AdapterBidNone
@@ -213,6 +607,61 @@ func bidsToMetric(bids *pbsOrtbSeatBid) pbsmetrics.AdapterBid {
 	return pbsmetrics.AdapterBidPresent
 }
 
+// noBidReason works out why a bidder which took part in the auction contributed no bids.
+// Errors are checked roughly in order of how early they could have stopped the bidder.
+func noBidReason(bids *pbsOrtbSeatBid, errs map[pbsmetrics.AdapterError]struct{}, rejectedBids bool) pbsmetrics.AdapterNoBidReason {
+	if bids == nil {
+		return pbsmetrics.AdapterNoBidNoRequest
+	}
+	if _, ok := errs[pbsmetrics.AdapterErrorTimeout]; ok {
+		return pbsmetrics.AdapterNoBidTimeout
+	}
+	if rejectedBids {
+		return pbsmetrics.AdapterNoBidRejected
+	}
+	if len(errs) > 0 {
+		return pbsmetrics.AdapterNoBidError
+	}
+	return pbsmetrics.AdapterNoBidEmpty
+}
+
+// recordWins records a win for the top bid on each imp. These are the bids which get the top-level targeting keys.
+func (e *exchange) recordWins(auc *auction, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, aliases map[string]string) {
+	for impID, winningBid := range auc.winningBids {
+		for bidderName, bid := range auc.winningBidsByBidder[impID] {
+			if bid != winningBid {
+				continue
+			}
+			if bidlabels, ok := blabels[resolveBidder(string(bidderName), aliases)]; ok {
+				e.me.RecordAdapterBidWon(*bidlabels, bid.bidType, float64(bid.bid.Price*1000))
+			}
+		}
+	}
+}
+
+// callBidder requests bids from a single bidder. If the bidder's code panics, the panic is logged and returned as an
+// error for that bidder alone, so that the rest of the auction isn't affected.
+func (e *exchange) callBidder(ctx context.Context, coreBidder openrtb_ext.BidderName, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (bids *pbsOrtbSeatBid, errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.FromContext(ctx).With("bidder", name).Errorf("Recovered from a panic in bidder %s: %v\n%s", name, r, debug.Stack())
+			bids = nil
+			errs = []error{&bidderPanicError{bidder: name}}
+		}
+	}()
+	return e.adapterMap[coreBidder].requestBid(ctx, request, name, bidAdjustment, conversions)
+}
+
+// bidderPanicError is returned for bidders whose code panicked. The details are only logged,
+// since the stack trace doesn't mean anything to the publisher.
+type bidderPanicError struct {
+	bidder openrtb_ext.BidderName
+}
+
+func (err *bidderPanicError) Error() string {
+	return fmt.Sprintf("The %s bidder failed unexpectedly, so its bids were dropped.", err.bidder)
+}
+
 func errorsToMetric(errs []error) map[pbsmetrics.AdapterError]struct{} {
 	if len(errs) == 0 {
 		return nil
@@ -222,12 +671,19 @@ func errorsToMetric(errs []error) map[pbsmetrics.AdapterError]struct{} {
 	for _, err := range errs {
 		if err == context.DeadlineExceeded {
 			ret[pbsmetrics.AdapterErrorTimeout] = s
+		} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			// These include timeouts connecting to the bidder's server, or to the adapter proxy.
+			ret[pbsmetrics.AdapterErrorTimeout] = s
 		} else {
 			switch err.(type) {
 			case *adapters.BadInputError:
 				ret[pbsmetrics.AdapterErrorBadInput] = s
 			case *adapters.BadServerResponseError:
 				ret[pbsmetrics.AdapterErrorBadServerResponse] = s
+			case *bidderPanicError:
+				ret[pbsmetrics.AdapterErrorPanic] = s
+			case *adapters.ResponseTooLargeError:
+				ret[pbsmetrics.AdapterErrorResponseTooLarge] = s
 			default:
 				ret[pbsmetrics.AdapterErrorUnknown] = s
 			}
@@ -236,6 +692,18 @@ func errorsToMetric(errs []error) map[pbsmetrics.AdapterError]struct{} {
 	return ret
 }
 
+// splitWarnings separates the adapters.WarningErrors from the real errors.
+func splitWarnings(errs []error) (realErrs []error, warnings []error) {
+	for _, err := range errs {
+		if _, ok := err.(*adapters.WarningError); ok {
+			warnings = append(warnings, err)
+		} else {
+			realErrs = append(realErrs, err)
+		}
+	}
+	return
+}
+
 func errsToStrings(errs []error) []string {
 	serr := make([]string, len(errs))
 	for i := 0; i < len(errs); i++ {
@@ -249,6 +717,9 @@ func (e *exchange) buildBidResponse(ctx context.Context, liveAdapters []openrtb_
 	bidResponse := new(openrtb.BidResponse)
 
 	bidResponse.ID = bidRequest.ID
+	if len(bidRequest.Cur) > 0 {
+		bidResponse.Cur = bidRequest.Cur[0]
+	}
 	if len(liveAdapters) == 0 {
 		// signal "Invalid Request" if no valid bidders.
 		bidResponse.NBR = openrtb.NoBidReasonCode.Ptr(openrtb.NoBidReasonCodeInvalidRequest)
@@ -257,46 +728,71 @@ func (e *exchange) buildBidResponse(ctx context.Context, liveAdapters []openrtb_
 	// Create the SeatBids. We use a zero sized slice so that we can append non-zero seat bids, and not include seatBid
 	// objects for seatBids without any bids. Preallocate the max possible size to avoid reallocating the array as we go.
 	seatBids := make([]openrtb.SeatBid, 0, len(liveAdapters))
+	impPassthrough := readImpPassthrough(bidRequest.Imp)
 	for _, a := range liveAdapters {
 		if adapterBids[a] != nil && len(adapterBids[a].bids) > 0 {
-			sb := e.makeSeatBid(adapterBids[a], a, adapterExtra)
+			sb := e.makeSeatBid(adapterBids[a], a, adapterExtra, bidRequest.Cur, impPassthrough)
 			seatBids = append(seatBids, *sb)
 		}
 	}
 
 	bidResponse.SeatBid = seatBids
 
-	bidResponseExt := e.makeExtBidResponse(adapterBids, adapterExtra, bidRequest, resolvedRequest, errList)
+	withDebug := bidRequest.Test == 1 && debugAllowed(ctx)
+	bidResponseExt := e.makeExtBidResponse(adapterBids, adapterExtra, bidRequest, withDebug, resolvedRequest, errList)
 	ext, err := json.Marshal(bidResponseExt)
 	bidResponse.Ext = ext
 	return bidResponse, err
 }
 
-// Extract all the data from the SeatBids and build the ExtBidResponse
-func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, req *openrtb.BidRequest, resolvedRequest json.RawMessage, errList []error) *openrtb_ext.ExtBidResponse {
+// Extract all the data from the SeatBids and build the ExtBidResponse. The debugging info is only added if withDebug is true.
+func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, req *openrtb.BidRequest, withDebug bool, resolvedRequest json.RawMessage, errList []error) *openrtb_ext.ExtBidResponse {
 	bidResponseExt := &openrtb_ext.ExtBidResponse{
-		Errors:             make(map[openrtb_ext.BidderName][]string, len(adapterBids)),
-		ResponseTimeMillis: make(map[openrtb_ext.BidderName]int, len(adapterBids)),
+		Errors:             make(map[openrtb_ext.BidderName][]string, len(adapterExtra)),
+		ResponseTimeMillis: make(map[openrtb_ext.BidderName]int, len(adapterExtra)),
 	}
-	if req.Test == 1 {
+	redact := withDebug && e.debug.RedactFor(e.accounts.Lookup(accountID(req)))
+	if withDebug {
 		bidResponseExt.Debug = &openrtb_ext.ExtResponseDebug{
 			HttpCalls: make(map[openrtb_ext.BidderName][]*openrtb_ext.ExtHttpCall),
 		}
+		if redact {
+			resolvedRequest = redactJSON(resolvedRequest)
+		}
 		if err := json.Unmarshal(resolvedRequest, &bidResponseExt.Debug.ResolvedRequest); err != nil {
-			glog.Errorf("Error unmarshalling bid request snapshot: %v", err)
+			logger.Errorf("Error unmarshalling bid request snapshot: %v", err)
 		}
 	}
 
-	for a, b := range adapterBids {
-		if b != nil {
-			if req.Test == 1 {
+	if passthrough := readPassthrough(req.Ext); passthrough != nil {
+		bidResponseExt.Prebid = &openrtb_ext.ExtResponsePrebid{Passthrough: passthrough}
+	}
+
+	// This must come first, because it can add errors for the bidders.
+	bidResponseExt.IGI = collectIGI(adapterBids, adapterExtra, req)
+
+	// This goes through adapterExtra, rather than adapterBids, because the bidders which the auction ended without
+	// only have an entry there.
+	for a, extra := range adapterExtra {
+		if b := adapterBids[a]; b != nil {
+			if withDebug {
 				// Fill debug info
-				bidResponseExt.Debug.HttpCalls[a] = b.httpCalls
+				if redact {
+					bidResponseExt.Debug.HttpCalls[a] = redactHttpCalls(b.httpCalls)
+				} else {
+					bidResponseExt.Debug.HttpCalls[a] = b.httpCalls
+				}
 			}
 		}
 		// Only make an entry for bidder errors if the bidder reported any.
-		if len(adapterExtra[a].Errors) > 0 {
-			bidResponseExt.Errors[a] = adapterExtra[a].Errors
+		if len(extra.Errors) > 0 {
+			bidResponseExt.Errors[a] = extra.Errors
+		}
+		if len(extra.Warnings) > 0 {
+			if bidResponseExt.Warnings == nil {
+				bidResponseExt.Warnings = make(map[openrtb_ext.BidderName][]string)
+			}
+			bidResponseExt.Warnings[a] = extra.Warnings
 		}
 		if len(errList) > 0 {
 			s := make([]string, len(errList))
@@ -305,16 +801,53 @@ func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*pb
 			}
 			bidResponseExt.Errors["prebid"] = s
 		}
-		bidResponseExt.ResponseTimeMillis[a] = adapterExtra[a].ResponseTimeMillis
+		bidResponseExt.ResponseTimeMillis[a] = extra.ResponseTimeMillis
 		// Defering the filling of bidResponseExt.Usersync[a] until later
 
 	}
 	return bidResponseExt
 }
 
+// collectIGI gathers the Protected Audience interest group signals from every seat, in order of bidder name
+// so that the response is stable. Signals for imps which aren't in the request are dropped, and reported as
+// errors for the bidder which sent them.
+func collectIGI(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, req *openrtb.BidRequest) []*openrtb_ext.ExtIGI {
+	bidders := make([]string, 0, len(adapterBids))
+	for bidder, seatBid := range adapterBids {
+		if seatBid != nil && len(seatBid.igi) > 0 {
+			bidders = append(bidders, string(bidder))
+		}
+	}
+	if len(bidders) == 0 {
+		return nil
+	}
+	sort.Strings(bidders)
+
+	impIDs := make(map[string]struct{}, len(req.Imp))
+	for _, imp := range req.Imp {
+		impIDs[imp.ID] = struct{}{}
+	}
+
+	var igi []*openrtb_ext.ExtIGI
+	for _, bidder := range bidders {
+		bidderName := openrtb_ext.BidderName(bidder)
+		for _, thisIGI := range adapterBids[bidderName].igi {
+			if thisIGI == nil {
+				continue
+			}
+			if _, ok := impIDs[thisIGI.ImpID]; !ok {
+				adapterExtra[bidderName].Errors = append(adapterExtra[bidderName].Errors, fmt.Sprintf("igi for unknown imp \"%s\" was ignored", thisIGI.ImpID))
+				continue
+			}
+			igi = append(igi, thisIGI)
+		}
+	}
+	return igi
+}
+
 // Return an openrtb seatBid for a bidder
 // BuildBidResponse is responsible for ensuring nil bid seatbids are not included
-func (e *exchange) makeSeatBid(adapterBid *pbsOrtbSeatBid, adapter openrtb_ext.BidderName, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra) *openrtb.SeatBid {
+func (e *exchange) makeSeatBid(adapterBid *pbsOrtbSeatBid, adapter openrtb_ext.BidderName, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, allowedCurrencies []string, impPassthrough map[string]openrtb.RawJSON) *openrtb.SeatBid {
 	seatBid := new(openrtb.SeatBid)
 	seatBid.Seat = adapter.String()
 	// Prebid cannot support roadblocking
@@ -333,7 +866,7 @@ func (e *exchange) makeSeatBid(adapterBid *pbsOrtbSeatBid, adapter openrtb_ext.B
 	}
 
 	var errList []string
-	seatBid.Bid, errList = e.makeBid(adapterBid.bids, adapter)
+	seatBid.Bid, errList = e.makeBid(adapterBid.bids, adapter, allowedCurrencies, impPassthrough)
 	if len(errList) > 0 {
 		adapterExtra[adapter].Errors = append(adapterExtra[adapter].Errors, errList...)
 	}
@@ -341,18 +874,25 @@ func (e *exchange) makeSeatBid(adapterBid *pbsOrtbSeatBid, adapter openrtb_ext.B
 	return seatBid
 }
 
-// Create the Bid array inside of SeatBid
-func (e *exchange) makeBid(Bids []*pbsOrtbBid, adapter openrtb_ext.BidderName) ([]openrtb.Bid, []string) {
+// Create the Bid array inside of SeatBid. Each bid gets the passthrough data from its imp, if there is any.
+func (e *exchange) makeBid(Bids []*pbsOrtbBid, adapter openrtb_ext.BidderName, allowedCurrencies []string, impPassthrough map[string]openrtb.RawJSON) ([]openrtb.Bid, []string) {
 	bids := make([]openrtb.Bid, 0, len(Bids))
 	errList := make([]string, 0, 1)
 	for i, thisBid := range Bids {
 		bidExt := &openrtb_ext.ExtBid{
 			Bidder: thisBid.bid.Ext,
 			Prebid: &openrtb_ext.ExtBidPrebid{
-				Targeting: thisBid.bidTargets,
-				Type:      thisBid.bidType,
+				DealPriority: thisBid.dealPriority,
+				Meta:         thisBid.bidMeta,
+				Passthrough:  impPassthrough[thisBid.bid.ImpID],
+				Scan:         thisBid.scan,
+				Targeting:    thisBid.bidTargets,
+				Type:         thisBid.bidType,
+				Video:        thisBid.bidVideo,
 			},
 		}
+		bidExt.Prebid.Currency = bidCurrencyExt(thisBid, allowedCurrencies)
+		bidExt.Prebid.GrossPrice = thisBid.grossPrice
 
 		ext, err := json.Marshal(bidExt)
 		if err != nil {
@@ -365,6 +905,24 @@ func (e *exchange) makeBid(Bids []*pbsOrtbBid, adapter openrtb_ext.BidderName) (
 	return bids, errList
 }
 
+// bidCurrencyExt returns the price which a bid was made at, if the bidder bid in one of the other currencies from
+// request.cur. The response has a single currency, so bid.price is always converted into the first one. This lets
+// publishers see the bid as it was made too. It returns nil if the bid was made in any other currency.
+func bidCurrencyExt(bid *pbsOrtbBid, allowedCurrencies []string) *openrtb_ext.ExtBidPrebidCurrency {
+	if len(allowedCurrencies) < 2 {
+		return nil
+	}
+	for _, allowed := range allowedCurrencies[1:] {
+		if strings.EqualFold(allowed, bid.originalCurrency) {
+			return &openrtb_ext.ExtBidPrebidCurrency{
+				Cur:   allowed,
+				Price: bid.originalPrice,
+			}
+		}
+	}
+	return nil
+}
+
 // validateBids will run some validation checks on the returned bids and excise any invalid bids
 func (brw *bidResponseWrapper) validateBids() (err []error) {
 	// Exit early if there is nothing to do.
@@ -390,6 +948,60 @@ func (brw *bidResponseWrapper) validateBids() (err []error) {
 	return err
 }
 
+// applyRevenueShare deducts the host's fee from the price of each bid, and keeps the gross prices so that they can be
+// returned in bid.ext.prebid. percent is the share of the price which is deducted.
+func applyRevenueShare(seatBid *pbsOrtbSeatBid, percent float64) {
+	if percent <= 0 || seatBid == nil {
+		return
+	}
+	net := 1 - percent/100
+	for _, bid := range seatBid.bids {
+		bid.grossPrice = bid.bid.Price
+		bid.bid.Price *= net
+		bid.originalPrice *= net
+	}
+}
+
+// enforcePriceCeiling removes the bids priced above the ceiling, and returns an error for each one.
+// A ceiling of 0 means there isn't one.
+func (brw *bidResponseWrapper) enforcePriceCeiling(ceiling float64) (err []error) {
+	if ceiling <= 0 || brw.adapterBids == nil || len(brw.adapterBids.bids) == 0 {
+		return
+	}
+	keptBids := make([]*pbsOrtbBid, 0, len(brw.adapterBids.bids))
+	for _, bid := range brw.adapterBids.bids {
+		if bid.bid.Price > ceiling {
+			err = append(err, fmt.Errorf("Bid \"%s\" rejected (%s): its price %g is above the account's max CPM of %g", bid.bid.ID, pbsmetrics.AdapterBidRejectedPriceCeiling, bid.bid.Price, ceiling))
+		} else {
+			keptBids = append(keptBids, bid)
+		}
+	}
+	if len(keptBids) != len(brw.adapterBids.bids) {
+		brw.adapterBids.bids = keptBids
+	}
+	return err
+}
+
+// enforceSeatFloor removes the bids priced below the floor, and returns an error for each one.
+// A floor of 0 means there isn't one.
+func (brw *bidResponseWrapper) enforceSeatFloor(floor float64) (err []error) {
+	if floor <= 0 || brw.adapterBids == nil || len(brw.adapterBids.bids) == 0 {
+		return
+	}
+	keptBids := make([]*pbsOrtbBid, 0, len(brw.adapterBids.bids))
+	for _, bid := range brw.adapterBids.bids {
+		if bid.bid.Price < floor {
+			err = append(err, fmt.Errorf("Bid \"%s\" rejected (%s): its price %g is below the account's floor of %g for this bidder", bid.bid.ID, pbsmetrics.AdapterBidRejectedSeatFloor, bid.bid.Price, floor))
+		} else {
+			keptBids = append(keptBids, bid)
+		}
+	}
+	if len(keptBids) != len(brw.adapterBids.bids) {
+		brw.adapterBids.bids = keptBids
+	}
+	return err
+}
+
 // validateBid will run the supplied bid through validation checks and return true if it passes, false otherwise.
 func validateBid(bid *pbsOrtbBid) (bool, error) {
 	if bid.bid == nil {
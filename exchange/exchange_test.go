@@ -9,12 +9,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/buger/jsonparser"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
@@ -37,7 +41,7 @@ func TestNewExchange(t *testing.T) {
 		},
 	}
 
-	e := NewExchange(server.Client(), nil, cfg, pbsmetrics.NewMetrics(metrics.NewRegistry(), knownAdapters)).(*exchange)
+	e := NewExchange(server.Client(), nil, cfg, pbsmetrics.NewMetrics(metrics.NewRegistry(), knownAdapters), gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil), nil, nil, nil, nil).(*exchange)
 	for _, bidderName := range knownAdapters {
 		if _, ok := e.adapterMap[bidderName]; !ok {
 			t.Errorf("NewExchange produced an Exchange without bidder %s", bidderName)
@@ -74,7 +78,7 @@ func TestRaceIntegration(t *testing.T) {
 	}
 
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
-	ex := NewExchange(server.Client(), &wellBehavedCache{}, cfg, theMetrics)
+	ex := NewExchange(server.Client(), &wellBehavedCache{}, cfg, theMetrics, gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil), nil, nil, nil, nil)
 	_, err := ex.HoldAuction(context.Background(), newRaceCheckingRequest(t), &emptyUsersync{}, pbsmetrics.Labels{})
 	if err != nil {
 		t.Errorf("HoldAuction returned unexpected error: %v", err)
@@ -176,6 +180,500 @@ func TestTimeoutComputation(t *testing.T) {
 	}
 }
 
+func TestNoBidReason(t *testing.T) {
+	var s struct{}
+	timeout := map[pbsmetrics.AdapterError]struct{}{pbsmetrics.AdapterErrorTimeout: s}
+	badResponse := map[pbsmetrics.AdapterError]struct{}{pbsmetrics.AdapterErrorBadServerResponse: s}
+	emptySeat := &pbsOrtbSeatBid{}
+
+	assertNoBidReason(t, noBidReason(nil, nil, false), pbsmetrics.AdapterNoBidNoRequest)
+	assertNoBidReason(t, noBidReason(emptySeat, timeout, true), pbsmetrics.AdapterNoBidTimeout)
+	assertNoBidReason(t, noBidReason(emptySeat, badResponse, true), pbsmetrics.AdapterNoBidRejected)
+	assertNoBidReason(t, noBidReason(emptySeat, badResponse, false), pbsmetrics.AdapterNoBidError)
+	assertNoBidReason(t, noBidReason(emptySeat, nil, false), pbsmetrics.AdapterNoBidEmpty)
+}
+
+func TestCollectIGI(t *testing.T) {
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		"rubicon": {igi: []*openrtb_ext.ExtIGI{{ImpID: "imp-1", IGS: []*openrtb_ext.ExtIGS{{Config: openrtb.RawJSON(`{"seller":"https://rubicon.example.com"}`)}}}}},
+		"appnexus": {igi: []*openrtb_ext.ExtIGI{
+			{ImpID: "imp-1", IGB: []*openrtb_ext.ExtIGB{{Origin: "https://appnexus.example.com"}}},
+			{ImpID: "unknown-imp"},
+		}},
+		"pubmatic": nil,
+	}
+	adapterExtra := map[openrtb_ext.BidderName]*seatResponseExtra{
+		"rubicon":  {},
+		"appnexus": {},
+		"pubmatic": {},
+	}
+	req := &openrtb.BidRequest{Imp: []openrtb.Imp{{ID: "imp-1"}}}
+
+	igi := collectIGI(adapterBids, adapterExtra, req)
+	if len(igi) != 2 {
+		t.Fatalf("Expected 2 igi entries. Got %d", len(igi))
+	}
+	if len(igi[0].IGB) != 1 || len(igi[1].IGS) != 1 {
+		t.Errorf("The igi entries should be sorted by bidder. Got %#v, %#v", igi[0], igi[1])
+	}
+	if len(adapterExtra["appnexus"].Errors) != 1 {
+		t.Errorf("igi for unknown imps should be reported as errors. Got %v", adapterExtra["appnexus"].Errors)
+	}
+	if collectIGI(map[openrtb_ext.BidderName]*pbsOrtbSeatBid{"rubicon": {}}, adapterExtra, req) != nil {
+		t.Errorf("Responses without igi should not get an empty list.")
+	}
+}
+
+func TestGetConversions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"conversions":{"USD":{"GBP":0.5}}}`))
+	}))
+	defer server.Close()
+
+	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), nil)
+	ex := exchange{
+		me:                   theMetrics,
+		currencyConverter:    currencies.NewRateConverter(server.Client(), server.URL, time.Hour),
+		intermediateCurrency: "USD",
+	}
+	usePBSRates := false
+	custom := map[string]map[string]float64{
+		"USD": {
+			"EUR": 0.9,
+		},
+	}
+
+	if rate, err := ex.getConversions(nil).GetRate("USD", "GBP"); err != nil || rate != 0.5 {
+		t.Errorf("The server's rates should be used by default. Got %f, %v", rate, err)
+	}
+	conversions := ex.getConversions(&openrtb_ext.ExtRequestCurrency{ConversionRates: custom})
+	if rate, err := conversions.GetRate("USD", "EUR"); err != nil || rate != 0.9 {
+		t.Errorf("The custom rates should be used. Got %f, %v", rate, err)
+	}
+	if rate, err := conversions.GetRate("USD", "GBP"); err != nil || rate != 0.5 {
+		t.Errorf("The server's rates should fill in for missing custom rates. Got %f, %v", rate, err)
+	}
+	conversions = ex.getConversions(&openrtb_ext.ExtRequestCurrency{ConversionRates: custom, UsePBSRates: &usePBSRates})
+	if _, err := conversions.GetRate("USD", "GBP"); err == nil {
+		t.Errorf("The server's rates shouldn't be used if usepbsrates is false.")
+	}
+	if rate, err := ex.getConversions(&openrtb_ext.ExtRequestCurrency{ConversionRates: custom}).GetRate("GBP", "EUR"); err != nil || rate != 2*0.9 {
+		t.Errorf("Missing rates should be derived through USD. Got %f, %v", rate, err)
+	}
+	if theMetrics.CurrencyConversionMeters[pbsmetrics.CurrencyConversionDerived].Count() != 1 {
+		t.Errorf("Derived conversions should be recorded.")
+	}
+}
+
+func TestMultipleCurrencies(t *testing.T) {
+	ex := &exchange{}
+	bids := []*pbsOrtbBid{
+		{
+			bid:              &openrtb.Bid{ID: "gbp-bid", Price: 2},
+			originalCurrency: "GBP",
+			originalPrice:    1,
+		},
+		{
+			bid:              &openrtb.Bid{ID: "jpy-bid", Price: 3},
+			originalCurrency: "JPY",
+			originalPrice:    300,
+		},
+	}
+
+	madeBids, errs := ex.makeBid(bids, "appnexus", []string{"USD", "gbp"}, nil)
+	if len(errs) != 0 || len(madeBids) != 2 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	assertBidCurrency(t, madeBids[0], 2, openrtb_ext.ExtBidPrebidCurrency{Cur: "gbp", Price: 1})
+	if madeBids[1].Price != 3 {
+		t.Errorf("Bids should be returned in the response currency. Got %f", madeBids[1].Price)
+	}
+	if currency, _, _, _ := jsonparser.Get(madeBids[1].Ext, "prebid", "currency"); currency != nil {
+		t.Errorf("The currency ext should only be set for bids made in one of the other allowed currencies. Got %s", currency)
+	}
+
+	madeBids, _ = ex.makeBid(bids, "appnexus", []string{"USD"}, nil)
+	if madeBids[0].Price != 2 {
+		t.Errorf("Bids should be returned in the request currency if it only allows one. Got %f", madeBids[0].Price)
+	}
+	if currency, _, _, _ := jsonparser.Get(madeBids[0].Ext, "prebid", "currency"); currency != nil {
+		t.Errorf("The currency ext should only be set if the request allows several currencies. Got %s", currency)
+	}
+}
+
+func TestBidderPanic(t *testing.T) {
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &panickingBidder{},
+		},
+	}
+	bids, errs := ex.callBidder(context.Background(), openrtb_ext.BidderAppnexus, &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 1.0, nil)
+	if bids != nil {
+		t.Errorf("A bidder which panics shouldn't return any bids. Got %v", bids)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("A bidder which panics should return one error. Got %v", errs)
+	}
+	if _, ok := errorsToMetric(errs)[pbsmetrics.AdapterErrorPanic]; !ok {
+		t.Errorf("Panics should be recorded in the %s error metric.", pbsmetrics.AdapterErrorPanic)
+	}
+}
+
+func TestPartialResponses(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &slowBidder{},
+			openrtb_ext.BidderRubicon:  &slowBidder{release: release},
+		},
+		me: pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		openrtb_ext.BidderAppnexus: {},
+		openrtb_ext.BidderRubicon:  {},
+	}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+		openrtb_ext.BidderRubicon:  {Adapter: openrtb_ext.BidderRubicon},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	bids, extra := ex.getAllBids(ctx, cleanRequests, nil, nil, nil, blabels, "", true)
+	if _, ok := bids[openrtb_ext.BidderAppnexus]; !ok {
+		t.Errorf("The bidder which responded in time should be in the response.")
+	}
+	if _, ok := bids[openrtb_ext.BidderRubicon]; ok {
+		t.Errorf("The bidder which didn't respond in time shouldn't be in the response.")
+	}
+	if rubiconExtra, ok := extra[openrtb_ext.BidderRubicon]; !ok || len(rubiconExtra.Errors) != 1 {
+		t.Errorf("The bidder which didn't respond in time should have an error in the response ext. Got %v", rubiconExtra)
+	}
+}
+
+func TestPartialResponsesExt(t *testing.T) {
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	ex := NewExchange(http.DefaultClient, &wellBehavedCache{}, &config.Configuration{
+		PartialResponses: config.PartialResponses{Enabled: true},
+	}, me, gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil), nil, nil, nil, nil).(*exchange)
+	ex.adapterMap = map[openrtb_ext.BidderName]adaptedBidder{
+		openrtb_ext.BidderAppnexus: &aliasSlowBidder{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	bidResponse, err := ex.HoldAuction(ctx, aliasedRequest(), &emptyUsersync{}, pbsmetrics.Labels{})
+	if err != nil {
+		t.Fatalf("The auction shouldn't fail. Got %v", err)
+	}
+	var ext openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(bidResponse.Ext, &ext); err != nil {
+		t.Fatalf("Bad response ext: %v", err)
+	}
+	if errs := ext.Errors["districtm"]; len(errs) != 1 || !strings.Contains(errs[0], "deadline") {
+		t.Errorf("The bidder which didn't respond in time should have an error in the response ext. Got %v", ext.Errors)
+	}
+	if _, ok := ext.ResponseTimeMillis["districtm"]; !ok {
+		t.Errorf("The bidder which didn't respond in time should have a response time in the response ext. Got %v", ext.ResponseTimeMillis)
+	}
+	if _, ok := ext.ResponseTimeMillis["appnexus"]; !ok {
+		t.Errorf("The bidder which responded should have a response time in the response ext. Got %v", ext.ResponseTimeMillis)
+	}
+	// The abandoned bidder still finishes in the background. Under -race, this checks that it doesn't share
+	// any labels with the rest of the auction.
+	waitForAdapterRequests(me, openrtb_ext.BidderAppnexus, 2)
+}
+
+// aliasedRequest has an imp for appnexus, and for the districtm alias of appnexus.
+func aliasedRequest() *openrtb.BidRequest {
+	return &openrtb.BidRequest{
+		ID:  "req",
+		Imp: []openrtb.Imp{{ID: "imp", Banner: &openrtb.Banner{}, Ext: openrtb.RawJSON(`{"appnexus":{},"districtm":{}}`)}},
+		Ext: openrtb.RawJSON(`{"prebid":{"aliases":{"districtm":"appnexus"}}}`),
+	}
+}
+
+// waitForAdapterRequests waits, for up to a second, until the adapter's requests have been recorded.
+func waitForAdapterRequests(me *pbsmetrics.Metrics, bidder openrtb_ext.BidderName, count int64) {
+	adapterMetrics := me.AdapterMetrics[bidder]
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if adapterMetrics.GotBidsMeter.Count()+adapterMetrics.NoBidMeter.Count() >= count {
+			return
+		}
+	}
+}
+
+// aliasSlowBidder bids straight away under its own name, but under an alias, it doesn't bid until the auction
+// is cancelled.
+type aliasSlowBidder struct {
+	dealID string
+}
+
+func (b *aliasSlowBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	if name != openrtb_ext.BidderAppnexus {
+		<-ctx.Done()
+		return nil, []error{ctx.Err()}
+	}
+	seatBid := &pbsOrtbSeatBid{}
+	for _, imp := range request.Imp {
+		seatBid.bids = append(seatBid.bids, &pbsOrtbBid{
+			bid:     &openrtb.Bid{ID: "bid", ImpID: imp.ID, Price: 1, CrID: "creative", DealID: b.dealID},
+			bidType: openrtb_ext.BidTypeBanner,
+		})
+	}
+	return seatBid, nil
+}
+
+func TestBidderWarnings(t *testing.T) {
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &warningBidder{},
+		},
+		me: me,
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{openrtb_ext.BidderAppnexus: {}}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+	}
+
+	bids, extra := ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "", false)
+	appnexusExtra := extra[openrtb_ext.BidderAppnexus]
+	if len(appnexusExtra.Warnings) != 1 || len(appnexusExtra.Errors) != 1 {
+		t.Fatalf("Expected one warning and one error. Got %v, %v", appnexusExtra.Warnings, appnexusExtra.Errors)
+	}
+	errorCount := int64(0)
+	for _, meter := range me.AdapterMetrics[openrtb_ext.BidderAppnexus].ErrorMeters {
+		errorCount += meter.Count()
+	}
+	if errorCount != 1 {
+		t.Errorf("Warnings should not be recorded as adapter errors. Got %d errors", errorCount)
+	}
+
+	responseExt := ex.makeExtBidResponse(bids, extra, &openrtb.BidRequest{}, false, nil, nil)
+	if warnings := responseExt.Warnings[openrtb_ext.BidderAppnexus]; len(warnings) != 1 || warnings[0] != "request.imp[0] uses video, but this bidder doesn't support it" {
+		t.Errorf("The warning should be in the response ext. Got %v", warnings)
+	}
+	if errs := responseExt.Errors[openrtb_ext.BidderAppnexus]; len(errs) != 1 {
+		t.Errorf("The error should be in the response ext. Got %v", errs)
+	}
+}
+
+func TestPriceCeilings(t *testing.T) {
+	registry := metrics.NewRegistry()
+	me := pbsmetrics.NewMetrics(registry, openrtb_ext.BidderList())
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &pricedBidder{prices: []float64{2, 5000}},
+			openrtb_ext.BidderRubicon:  &pricedBidder{prices: []float64{3000}},
+		},
+		me: me,
+		priceCeilings: config.PriceCeilings{
+			MaxCPM:   100,
+			Accounts: []config.AccountPriceCeilings{{ID: "test-seats", MaxCPM: 0}},
+		},
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		openrtb_ext.BidderAppnexus: {},
+		openrtb_ext.BidderRubicon:  {},
+	}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+		openrtb_ext.BidderRubicon:  {Adapter: openrtb_ext.BidderRubicon},
+	}
+
+	bids, extra := ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "publisher", false)
+	if appnexusBids := bids[openrtb_ext.BidderAppnexus].bids; len(appnexusBids) != 1 || appnexusBids[0].bid.Price != 2 {
+		t.Errorf("Only the bid under the ceiling should be kept. Got %v", appnexusBids)
+	}
+	if rubiconBids := bids[openrtb_ext.BidderRubicon].bids; len(rubiconBids) != 0 {
+		t.Errorf("The bid over the ceiling should be rejected. Got %v", rubiconBids)
+	}
+	if errs := extra[openrtb_ext.BidderAppnexus].Errors; len(errs) != 1 || !strings.Contains(errs[0], "price_ceiling") {
+		t.Errorf("The rejected bid should have an error with the rejection code. Got %v", errs)
+	}
+	if rejected := me.AdapterMetrics[openrtb_ext.BidderRubicon].RejectedMeters[pbsmetrics.AdapterBidRejectedPriceCeiling].Count(); rejected != 1 {
+		t.Errorf("The rejected bid should be counted. Got %d", rejected)
+	}
+	if noBids := me.AdapterMetrics[openrtb_ext.BidderRubicon].NoBidReasonMeters[pbsmetrics.AdapterNoBidRejected].Count(); noBids != 1 {
+		t.Errorf("A bidder whose bids were all rejected should count as a rejected no-bid. Got %d", noBids)
+	}
+
+	bids, _ = ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "test-seats", false)
+	if rubiconBids := bids[openrtb_ext.BidderRubicon].bids; len(rubiconBids) != 1 {
+		t.Errorf("Accounts without a ceiling should keep every bid. Got %v", rubiconBids)
+	}
+}
+
+func TestSeatFloors(t *testing.T) {
+	registry := metrics.NewRegistry()
+	me := pbsmetrics.NewMetrics(registry, openrtb_ext.BidderList())
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &pricedBidder{prices: []float64{0.5, 2}},
+			openrtb_ext.BidderRubicon:  &pricedBidder{prices: []float64{0.5}},
+		},
+		me: me,
+		accounts: config.Accounts{
+			Known: []config.Account{{ID: "publisher", SeatFloors: map[string]float64{"appnexus": 1}}},
+		},
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		openrtb_ext.BidderAppnexus: {},
+		openrtb_ext.BidderRubicon:  {},
+	}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+		openrtb_ext.BidderRubicon:  {Adapter: openrtb_ext.BidderRubicon},
+	}
+
+	bids, extra := ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "publisher", false)
+	if appnexusBids := bids[openrtb_ext.BidderAppnexus].bids; len(appnexusBids) != 1 || appnexusBids[0].bid.Price != 2 {
+		t.Errorf("Only the bid over the floor should be kept. Got %v", appnexusBids)
+	}
+	if rubiconBids := bids[openrtb_ext.BidderRubicon].bids; len(rubiconBids) != 1 {
+		t.Errorf("Bidders without a floor should keep every bid. Got %v", rubiconBids)
+	}
+	if errs := extra[openrtb_ext.BidderAppnexus].Errors; len(errs) != 1 || !strings.Contains(errs[0], "seat_floor") {
+		t.Errorf("The rejected bid should have an error with the rejection code. Got %v", errs)
+	}
+	if rejected := me.AdapterMetrics[openrtb_ext.BidderAppnexus].RejectedMeters[pbsmetrics.AdapterBidRejectedSeatFloor].Count(); rejected != 1 {
+		t.Errorf("The rejected bid should be counted. Got %d", rejected)
+	}
+
+	bids, _ = ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "other", false)
+	if appnexusBids := bids[openrtb_ext.BidderAppnexus].bids; len(appnexusBids) != 2 {
+		t.Errorf("Accounts without floors should keep every bid. Got %v", appnexusBids)
+	}
+}
+
+func TestRevenueShare(t *testing.T) {
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &pricedBidder{prices: []float64{4}},
+		},
+		me: pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		revenueShare: config.RevenueShare{
+			Percent:  25,
+			Accounts: []config.AccountRevenueShare{{ID: "direct", Percent: 0}},
+		},
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{openrtb_ext.BidderAppnexus: {}}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+	}
+
+	bids, _ := ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "publisher", false)
+	appnexusBids := bids[openrtb_ext.BidderAppnexus].bids
+	if len(appnexusBids) != 1 || appnexusBids[0].bid.Price != 3 || appnexusBids[0].grossPrice != 4 {
+		t.Fatalf("The revenue share should be deducted from the bid's price. Got %v", appnexusBids)
+	}
+	madeBids, _ := ex.makeBid(appnexusBids, "appnexus", []string{"USD"}, nil)
+	if grossPrice, _ := jsonparser.GetFloat(madeBids[0].Ext, "prebid", "grossprice"); grossPrice != 4 {
+		t.Errorf("The gross price should be in bid.ext.prebid.grossprice. Got %f", grossPrice)
+	}
+
+	bids, _ = ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "direct", false)
+	if directBids := bids[openrtb_ext.BidderAppnexus].bids; directBids[0].bid.Price != 4 || directBids[0].grossPrice != 0 {
+		t.Errorf("Accounts without a revenue share should keep the bidder's price. Got %v", directBids[0])
+	}
+	madeBids, _ = ex.makeBid(bids[openrtb_ext.BidderAppnexus].bids, "appnexus", []string{"USD"}, nil)
+	if grossPrice, _, _, _ := jsonparser.Get(madeBids[0].Ext, "prebid", "grossprice"); grossPrice != nil {
+		t.Errorf("The gross price should only be set if there's a revenue share. Got %s", grossPrice)
+	}
+}
+
+func TestRevenueShareCurrencies(t *testing.T) {
+	seatBid := &pbsOrtbSeatBid{
+		bids: []*pbsOrtbBid{{
+			bid:              &openrtb.Bid{ID: "gbp-bid", Price: 2},
+			originalCurrency: "GBP",
+			originalPrice:    1,
+		}},
+	}
+	applyRevenueShare(seatBid, 50)
+	madeBids, errs := (&exchange{}).makeBid(seatBid.bids, "appnexus", []string{"USD", "GBP"}, nil)
+	if len(errs) != 0 || len(madeBids) != 1 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	assertBidCurrency(t, madeBids[0], 1, openrtb_ext.ExtBidPrebidCurrency{Cur: "GBP", Price: 0.5})
+	if grossPrice, _ := jsonparser.GetFloat(madeBids[0].Ext, "prebid", "grossprice"); grossPrice != 2 {
+		t.Errorf("The gross price should be in the same currency as the bid's price. Got %f", grossPrice)
+	}
+}
+
+// pricedBidder returns one bid at each of the prices.
+type pricedBidder struct {
+	prices []float64
+}
+
+func (b *pricedBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	seatBid := &pbsOrtbSeatBid{}
+	for i, price := range b.prices {
+		seatBid.bids = append(seatBid.bids, &pbsOrtbBid{
+			bid: &openrtb.Bid{
+				ID:    fmt.Sprintf("bid-%d", i),
+				ImpID: "imp",
+				Price: price,
+				CrID:  "creative",
+			},
+			bidType: openrtb_ext.BidTypeBanner,
+		})
+	}
+	return seatBid, nil
+}
+
+// warningBidder returns one warning and one error.
+type warningBidder struct{}
+
+func (b *warningBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	return &pbsOrtbSeatBid{}, []error{
+		adapters.Warning("request.imp[0] uses video, but this bidder doesn't support it"),
+		&adapters.BadInputError{Message: "request.imp[1] has no supported MediaTypes. It will be ignored"},
+	}
+}
+
+// slowBidder doesn't bid until release is closed. If release is nil, it doesn't bid at all.
+type slowBidder struct {
+	release chan struct{}
+}
+
+func (b *slowBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	if b.release != nil {
+		<-b.release
+	}
+	return &pbsOrtbSeatBid{}, nil
+}
+
+type panickingBidder struct{}
+
+func (b *panickingBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	var response *openrtb.BidResponse
+	return &pbsOrtbSeatBid{ext: response.Ext}, nil
+}
+
+func assertBidCurrency(t *testing.T, bid openrtb.Bid, price float64, expected openrtb_ext.ExtBidPrebidCurrency) {
+	t.Helper()
+	if bid.Price != price {
+		t.Errorf("Bid %s should have price %f. Got %f", bid.ID, price, bid.Price)
+	}
+	var ext openrtb_ext.ExtBid
+	if err := json.Unmarshal(bid.Ext, &ext); err != nil {
+		t.Fatalf("Failed to unmarshal bid ext: %v", err)
+	}
+	if ext.Prebid.Currency == nil || *ext.Prebid.Currency != expected {
+		t.Errorf("Bid %s has the wrong currency ext. Expected %#v, got %#v", bid.ID, expected, ext.Prebid.Currency)
+	}
+}
+
+func assertNoBidReason(t *testing.T, actual pbsmetrics.AdapterNoBidReason, expected pbsmetrics.AdapterNoBidReason) {
+	t.Helper()
+	if actual != expected {
+		t.Errorf("Expected no-bid reason %s. Got %s", expected, actual)
+	}
+}
+
 // TestExchangeJSON executes tests for all the *.json files in exchangetest.
 func TestExchangeJSON(t *testing.T) {
 	if specFiles, err := ioutil.ReadDir("./exchangetest"); err == nil {
@@ -307,6 +805,7 @@ func newExchangeForTests(t *testing.T, filename string, expectations map[string]
 		me:         metricsConf.NewMetricsEngine(&config.Configuration{}, openrtb_ext.BidderList()),
 		cache:      &wellBehavedCache{},
 		cacheTime:  0,
+		gDPR:       gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil),
 	}
 }
 
@@ -351,8 +850,10 @@ type bidderSeatBid struct {
 // bidderBid is basically a subset of pbsOrtbBid from exchange/bidder.go.
 // See the comment on bidderSeatBid for more info.
 type bidderBid struct {
-	Bid  *openrtb.Bid `json:"ortbBid,omitempty"`
-	Type string       `json:"bidType,omitempty"`
+	Bid          *openrtb.Bid                   `json:"ortbBid,omitempty"`
+	Type         string                         `json:"bidType,omitempty"`
+	Video        *openrtb_ext.ExtBidPrebidVideo `json:"bidVideo,omitempty"`
+	DealPriority int                            `json:"dealPriority,omitempty"`
 }
 
 type mockIdFetcher map[string]string
@@ -372,7 +873,7 @@ type validatingBidder struct {
 	mockResponses map[string]bidderResponse
 }
 
-func (b *validatingBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64) (seatBid *pbsOrtbSeatBid, errs []error) {
+func (b *validatingBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (seatBid *pbsOrtbSeatBid, errs []error) {
 	if expectedRequest, ok := b.expectations[string(name)]; ok {
 		if expectedRequest != nil {
 			if expectedRequest.BidAdjustment != bidAdjustment {
@@ -389,8 +890,10 @@ func (b *validatingBidder) requestBid(ctx context.Context, request *openrtb.BidR
 			bids := make([]*pbsOrtbBid, len(mockResponse.SeatBid.Bids))
 			for i := 0; i < len(bids); i++ {
 				bids[i] = &pbsOrtbBid{
-					bid:     mockResponse.SeatBid.Bids[i].Bid,
-					bidType: openrtb_ext.BidType(mockResponse.SeatBid.Bids[i].Type),
+					bid:          mockResponse.SeatBid.Bids[i].Bid,
+					bidType:      openrtb_ext.BidType(mockResponse.SeatBid.Bids[i].Type),
+					bidVideo:     mockResponse.SeatBid.Bids[i].Video,
+					dealPriority: mockResponse.SeatBid.Bids[i].DealPriority,
 				}
 			}
 
@@ -499,7 +1002,7 @@ func mockHandler(statusCode int, getBody string, postBody string) http.Handler {
 
 type wellBehavedCache struct{}
 
-func (c *wellBehavedCache) PutJson(ctx context.Context, values []json.RawMessage) []string {
+func (c *wellBehavedCache) PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) []string {
 	ids := make([]string, len(values))
 	for i := 0; i < len(values); i++ {
 		ids[i] = strconv.Itoa(i)
@@ -0,0 +1,86 @@
+package exchange
+
+import (
+	"context"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// heldFallback is a fallback bidder's request, which waits for its primary bidder's bids.
+type heldFallback struct {
+	primary openrtb_ext.BidderName
+	request *openrtb.BidRequest
+}
+
+// holdFallbacks removes the fallback bidders from cleanRequests, so that they aren't called in the first wave.
+// Fallbacks are only held if their primary bidder is in the auction too. Otherwise they're called as usual.
+func holdFallbacks(groups []config.FailoverGroup, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest) map[openrtb_ext.BidderName]heldFallback {
+	if len(groups) == 0 {
+		return nil
+	}
+	held := make(map[openrtb_ext.BidderName]heldFallback, len(groups))
+	for _, group := range groups {
+		primary, fallback := openrtb_ext.BidderName(group.Primary), openrtb_ext.BidderName(group.Fallback)
+		_, hasPrimary := cleanRequests[primary]
+		if request, ok := cleanRequests[fallback]; ok && hasPrimary {
+			held[fallback] = heldFallback{primary: primary, request: request}
+			delete(cleanRequests, fallback)
+		}
+	}
+	return held
+}
+
+// fallbackRequests returns the requests for the second wave. Each fallback only gets the imps which its primary bidder
+// didn't bid on. If the primary failed, or didn't respond, that's all of them. Imps which have a bid from a pinned deal
+// are left out too, since the fallback couldn't win them. Fallbacks with no imps left aren't called.
+func fallbackRequests(held map[openrtb_ext.BidderName]heldFallback, adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, pinnedImps map[string]bool) map[openrtb_ext.BidderName]*openrtb.BidRequest {
+	requests := make(map[openrtb_ext.BidderName]*openrtb.BidRequest, len(held))
+	for fallback, h := range held {
+		filled := make(map[string]bool)
+		if seatBid := adapterBids[h.primary]; seatBid != nil {
+			for _, bid := range seatBid.bids {
+				filled[bid.bid.ImpID] = true
+			}
+		}
+		imps := make([]openrtb.Imp, 0, len(h.request.Imp))
+		for _, imp := range h.request.Imp {
+			if !filled[imp.ID] && !pinnedImps[imp.ID] {
+				imps = append(imps, imp)
+			}
+		}
+		if len(imps) > 0 {
+			request := *h.request
+			request.Imp = imps
+			requests[fallback] = &request
+		}
+	}
+	return requests
+}
+
+// hasTimeLeft returns true if the auction has at least minMillis left before its deadline.
+func hasTimeLeft(ctx context.Context, minMillis int) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) >= time.Duration(minMillis)*time.Millisecond
+}
+
+// mergeFallbacks adds the second wave's bids to the first's, and returns the fallback bidders which were called.
+// Their responses count as arriving after all of the first wave's.
+func mergeFallbacks(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, fallbackBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, fallbackExtra map[openrtb_ext.BidderName]*seatResponseExtra) []openrtb_ext.BidderName {
+	firstWave := len(adapterExtra)
+	called := make([]openrtb_ext.BidderName, 0, len(fallbackExtra))
+	for bidder, extra := range fallbackExtra {
+		if seatBid := fallbackBids[bidder]; seatBid != nil {
+			seatBid.arrival += firstWave
+			adapterBids[bidder] = seatBid
+		}
+		adapterExtra[bidder] = extra
+		called = append(called, bidder)
+	}
+	return called
+}
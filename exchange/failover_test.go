@@ -0,0 +1,102 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestHoldFallbacks(t *testing.T) {
+	groups := []config.FailoverGroup{{Primary: "appnexus", Fallback: "rubicon"}, {Primary: "pubmatic", Fallback: "openx"}}
+	rubiconRequest := &openrtb.BidRequest{ID: "rubicon"}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus": {ID: "appnexus"},
+		"rubicon":  rubiconRequest,
+		"openx":    {ID: "openx"},
+	}
+
+	held := holdFallbacks(groups, cleanRequests)
+	if len(held) != 1 || held["rubicon"].primary != "appnexus" || held["rubicon"].request != rubiconRequest {
+		t.Errorf("Only fallbacks whose primary is in the auction should be held. Got %v", held)
+	}
+	if _, ok := cleanRequests["rubicon"]; ok || len(cleanRequests) != 2 {
+		t.Errorf("Held fallbacks shouldn't be in the first wave. Got %v", cleanRequests)
+	}
+	if held := holdFallbacks(nil, cleanRequests); held != nil {
+		t.Errorf("Accounts without groups shouldn't hold any bidders. Got %v", held)
+	}
+}
+
+func TestFallbackRequests(t *testing.T) {
+	held := map[openrtb_ext.BidderName]heldFallback{
+		"rubicon": {primary: "appnexus", request: &openrtb.BidRequest{ID: "req", Imp: []openrtb.Imp{{ID: "imp-1"}, {ID: "imp-2"}}}},
+		"openx":   {primary: "pubmatic", request: &openrtb.BidRequest{ID: "req", Imp: []openrtb.Imp{{ID: "imp-1"}}}},
+		"sovrn":   {primary: "sharethrough", request: &openrtb.BidRequest{ID: "req", Imp: []openrtb.Imp{{ID: "imp-1"}}}},
+	}
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		"appnexus": {bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ImpID: "imp-1"}}}},
+		"pubmatic": {bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ImpID: "imp-1"}}}},
+	}
+
+	requests := fallbackRequests(held, adapterBids, nil)
+	if rubicon := requests["rubicon"]; rubicon == nil || len(rubicon.Imp) != 1 || rubicon.Imp[0].ID != "imp-2" || rubicon.ID != "req" {
+		t.Errorf("The fallback should only get the imps which its primary didn't bid on. Got %v", rubicon)
+	}
+	if len(held["rubicon"].request.Imp) != 2 {
+		t.Errorf("The held request shouldn't be changed.")
+	}
+	if _, ok := requests["openx"]; ok {
+		t.Errorf("Fallbacks shouldn't be called if their primary bid on every imp.")
+	}
+	if sovrn := requests["sovrn"]; sovrn == nil || len(sovrn.Imp) != 1 {
+		t.Errorf("Fallbacks should get every imp if their primary didn't respond. Got %v", sovrn)
+	}
+
+	requests = fallbackRequests(held, adapterBids, map[string]bool{"imp-1": true})
+	if _, ok := requests["sovrn"]; ok {
+		t.Errorf("Fallbacks shouldn't get the imps which a pinned deal bid on.")
+	}
+}
+
+func TestHasTimeLeft(t *testing.T) {
+	if !hasTimeLeft(context.Background(), 50) {
+		t.Errorf("Auctions without a deadline always have time left.")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !hasTimeLeft(ctx, 50) {
+		t.Errorf("A second should be enough for 50ms.")
+	}
+	if hasTimeLeft(ctx, 5000) {
+		t.Errorf("A second shouldn't be enough for 5000ms.")
+	}
+	cancel()
+	if hasTimeLeft(ctx, 0) {
+		t.Errorf("Cancelled auctions don't have time left.")
+	}
+}
+
+func TestMergeFallbacks(t *testing.T) {
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{"appnexus": {arrival: 0}, "pubmatic": nil}
+	adapterExtra := map[openrtb_ext.BidderName]*seatResponseExtra{"appnexus": {}, "pubmatic": {}}
+	fallbackBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{"rubicon": {arrival: 0}}
+	fallbackExtra := map[openrtb_ext.BidderName]*seatResponseExtra{"rubicon": {ResponseTimeMillis: 20}, "openx": {}}
+
+	called := mergeFallbacks(adapterBids, adapterExtra, fallbackBids, fallbackExtra)
+	if len(called) != 2 {
+		t.Errorf("Both fallbacks should be returned. Got %v", called)
+	}
+	if rubicon := adapterBids["rubicon"]; rubicon == nil || rubicon.arrival != 2 {
+		t.Errorf("The fallback's response should arrive after the first wave's. Got %v", rubicon)
+	}
+	if extra := adapterExtra["rubicon"]; extra == nil || extra.ResponseTimeMillis != 20 {
+		t.Errorf("The fallback's response ext should be kept. Got %v", extra)
+	}
+	if _, ok := adapterExtra["openx"]; !ok {
+		t.Errorf("Fallbacks without bids should still have a response ext.")
+	}
+}
@@ -0,0 +1,79 @@
+package exchange
+
+import (
+	"encoding/json"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// restrictFPD removes the first party data in site.ext.data, app.ext.data and user.ext.data from the requests for
+// the bidders which request.ext.prebid.data.bidders doesn't list. Bidders may be listed by their own name, or by their
+// core bidder's. If the list can't be parsed, no bidder gets the data.
+func restrictFPD(orig *openrtb.BidRequest, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string) []error {
+	var errs []error
+	var bidders []string
+	value, dataType, _, err := jsonparser.Get(orig.Ext, "prebid", "data", "bidders")
+	if dataType == jsonparser.NotExist || err == jsonparser.KeyPathNotFoundError {
+		return nil
+	}
+	if err == nil {
+		err = json.Unmarshal(value, &bidders)
+	}
+	if err != nil {
+		errs = append(errs, err)
+		bidders = nil
+	} else if len(bidders) == 0 || containsBidder(bidders, "*") {
+		return nil
+	}
+
+	for bidder, req := range requestsByBidder {
+		if containsBidder(bidders, string(bidder)) || containsBidder(bidders, string(resolveBidder(string(bidder), aliases))) {
+			continue
+		}
+		if req.Site != nil {
+			if ext, changed := withoutFPD(req.Site.Ext); changed {
+				site := *req.Site
+				site.Ext = ext
+				req.Site = &site
+			}
+		}
+		if req.App != nil {
+			if ext, changed := withoutFPD(req.App.Ext); changed {
+				app := *req.App
+				app.Ext = ext
+				req.App = &app
+			}
+		}
+		if req.User != nil {
+			if ext, changed := withoutFPD(req.User.Ext); changed {
+				user := *req.User
+				user.Ext = ext
+				req.User = &user
+			}
+		}
+	}
+	return errs
+}
+
+// withoutFPD returns the ext without its data. The bool is false if there wasn't any. If the ext can't be parsed,
+// we can't tell what else is in it, so the whole thing is dropped.
+func withoutFPD(ext openrtb.RawJSON) (openrtb.RawJSON, bool) {
+	if _, dataType, _, _ := jsonparser.Get(ext, "data"); dataType == jsonparser.NotExist {
+		return ext, false
+	}
+	var extMap map[string]json.RawMessage
+	if err := json.Unmarshal(ext, &extMap); err != nil {
+		return nil, true
+	}
+	delete(extMap, "data")
+	if len(extMap) == 0 {
+		return nil, true
+	}
+	newExt, err := json.Marshal(extMap)
+	if err != nil {
+		return nil, true
+	}
+	return newExt, true
+}
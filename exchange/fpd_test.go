@@ -0,0 +1,57 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestRestrictFPD(t *testing.T) {
+	site := &openrtb.Site{Page: "example.com", Ext: openrtb.RawJSON(`{"amp":1,"data":{"section":"sports"}}`)}
+	user := &openrtb.User{ID: "user-1", Ext: openrtb.RawJSON(`{"data":{"interests":["cycling"]}}`)}
+	newRequests := func() map[openrtb_ext.BidderName]*openrtb.BidRequest {
+		return map[openrtb_ext.BidderName]*openrtb.BidRequest{
+			"appnexus":   {Site: site, User: user},
+			"rubicon":    {Site: site, User: user},
+			"mypubmatic": {Site: site, User: user},
+		}
+	}
+	aliases := map[string]string{"mypubmatic": "pubmatic"}
+
+	orig := &openrtb.BidRequest{Ext: openrtb.RawJSON(`{"prebid":{"data":{"bidders":["appnexus","pubmatic"]}}}`)}
+	requests := newRequests()
+	if errs := restrictFPD(orig, requests, aliases); len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	for _, bidder := range []openrtb_ext.BidderName{"appnexus", "mypubmatic"} {
+		if requests[bidder].Site != site || requests[bidder].User != user {
+			t.Errorf("%s is allowed the first party data, so its request shouldn't change.", bidder)
+		}
+	}
+	if ext := string(requests["rubicon"].Site.Ext); ext != `{"amp":1}` {
+		t.Errorf("rubicon shouldn't get site.ext.data. Got %s", ext)
+	}
+	if requests["rubicon"].User.Ext != nil || requests["rubicon"].User.ID != "user-1" {
+		t.Errorf("rubicon shouldn't get user.ext.data, but should keep the rest of the user. Got %#v", requests["rubicon"].User)
+	}
+	if string(site.Ext) != `{"amp":1,"data":{"section":"sports"}}` || string(user.Ext) != `{"data":{"interests":["cycling"]}}` {
+		t.Errorf("The shared site and user shouldn't be changed.")
+	}
+
+	for _, ext := range []string{``, `{"prebid":{}}`, `{"prebid":{"data":{"bidders":["*"]}}}`} {
+		requests = newRequests()
+		restrictFPD(&openrtb.BidRequest{Ext: openrtb.RawJSON(ext)}, requests, aliases)
+		if requests["rubicon"].Site != site || requests["rubicon"].User != user {
+			t.Errorf("Every bidder should get the first party data with the ext %s", ext)
+		}
+	}
+
+	requests = newRequests()
+	if errs := restrictFPD(&openrtb.BidRequest{Ext: openrtb.RawJSON(`{"prebid":{"data":{"bidders":"appnexus"}}}`)}, requests, aliases); len(errs) != 1 {
+		t.Errorf("A bad bidders list should be an error. Got %v", errs)
+	}
+	if requests["appnexus"].Site.Ext == nil || string(requests["appnexus"].Site.Ext) != `{"amp":1}` {
+		t.Errorf("No bidder should get the first party data if the list can't be parsed. Got %s", requests["appnexus"].Site.Ext)
+	}
+}
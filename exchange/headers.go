@@ -0,0 +1,75 @@
+package exchange
+
+import (
+	"net/http"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+// bidderHeaders are the headers which the host adds to the requests sent to a bidder, from its adapter config.
+type bidderHeaders struct {
+	static  http.Header
+	forward []string
+}
+
+// newBidderHeaders returns the bidder's headers, or nil if its config doesn't set any.
+func newBidderHeaders(cfg config.Adapter) *bidderHeaders {
+	if len(cfg.Headers) == 0 && len(cfg.ForwardHeaders) == 0 {
+		return nil
+	}
+	headers := &bidderHeaders{
+		static:  make(http.Header, len(cfg.Headers)),
+		forward: make([]string, 0, len(cfg.ForwardHeaders)),
+	}
+	for name, value := range cfg.Headers {
+		headers.static.Set(name, value)
+	}
+	for _, name := range cfg.ForwardHeaders {
+		headers.forward = append(headers.forward, http.CanonicalHeaderKey(name))
+	}
+	return headers
+}
+
+// apply adds the headers to a request which the adapter made. The adapter's own headers are copied
+// rather than changed, since some adapters share one http.Header across all their requests.
+func (h *bidderHeaders) apply(reqHeaders http.Header, device *openrtb.Device) http.Header {
+	if h == nil {
+		return reqHeaders
+	}
+	headers := make(http.Header, len(reqHeaders)+len(h.static)+len(h.forward))
+	for name, values := range reqHeaders {
+		headers[name] = values
+	}
+	for _, name := range h.forward {
+		if headers.Get(name) != "" {
+			continue
+		}
+		if value := forwardedValue(name, device); value != "" {
+			headers.Set(name, value)
+		}
+	}
+	for name, values := range h.static {
+		headers[name] = values
+	}
+	return headers
+}
+
+// forwardedValue returns the value of one of the config.ForwardableHeaders, from the request's device.
+func forwardedValue(name string, device *openrtb.Device) string {
+	if device == nil {
+		return ""
+	}
+	switch name {
+	case "User-Agent":
+		return device.UA
+	case "Accept-Language":
+		return device.Language
+	case "X-Forwarded-For":
+		if device.IP != "" {
+			return device.IP
+		}
+		return device.IPv6
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package exchange
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestNoBidderHeaders(t *testing.T) {
+	headers := newBidderHeaders(config.Adapter{})
+	if headers != nil {
+		t.Fatalf("Adapters without headers config shouldn't get any bidderHeaders.")
+	}
+	reqHeaders := http.Header{"Content-Type": []string{"application/json"}}
+	if applied := headers.apply(reqHeaders, &openrtb.Device{UA: "some-ua"}); applied.Get("User-Agent") != "" {
+		t.Errorf("No headers should be forwarded without config. Got %v", applied)
+	}
+}
+
+func TestBidderHeaders(t *testing.T) {
+	headers := newBidderHeaders(config.Adapter{
+		Headers:        map[string]string{"x-openrtb-version": "2.5", "content-type": "application/json;charset=utf-8"},
+		ForwardHeaders: []string{"user-agent", "accept-language", "x-forwarded-for"},
+	})
+	reqHeaders := http.Header{
+		"Content-Type":    []string{"application/json"},
+		"Accept-Language": []string{"fr"},
+	}
+	device := &openrtb.Device{UA: "some-ua", Language: "en", IPv6: "2001:db8::1"}
+
+	applied := headers.apply(reqHeaders, device)
+	assertHeader(t, applied, "X-Openrtb-Version", "2.5")
+	assertHeader(t, applied, "Content-Type", "application/json;charset=utf-8")
+	assertHeader(t, applied, "User-Agent", "some-ua")
+	assertHeader(t, applied, "Accept-Language", "fr")
+	assertHeader(t, applied, "X-Forwarded-For", "2001:db8::1")
+	assertHeader(t, reqHeaders, "Content-Type", "application/json")
+	if reqHeaders.Get("User-Agent") != "" {
+		t.Errorf("The adapter's headers shouldn't be changed. Got %v", reqHeaders)
+	}
+
+	if applied := headers.apply(nil, nil); applied.Get("User-Agent") != "" {
+		t.Errorf("Headers shouldn't be forwarded from a missing device. Got %v", applied)
+	}
+}
+
+func assertHeader(t *testing.T, headers http.Header, name string, expected string) {
+	t.Helper()
+	if actual := headers.Get(name); actual != expected {
+		t.Errorf("Bad %s header. Expected %q, got %q", name, expected, actual)
+	}
+}
@@ -0,0 +1,84 @@
+package exchange
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// httpTimings records where the time went in one HTTP call to a bidder, so that a slow network can be told apart
+// from a slow bidder. Phases which failed or never finished, such as the first byte of a call which timed out,
+// aren't recorded. The call's error says what went wrong with those.
+type httpTimings struct {
+	mutex sync.Mutex
+	// connection is empty if the call never got a connection.
+	connection pbsmetrics.AdapterConnection
+	started    map[pbsmetrics.AdapterHTTPPhase]time.Time
+	phases     map[pbsmetrics.AdapterHTTPPhase]time.Duration
+}
+
+func newHTTPTimings() *httpTimings {
+	return &httpTimings{
+		started: make(map[pbsmetrics.AdapterHTTPPhase]time.Time, 4),
+		phases:  make(map[pbsmetrics.AdapterHTTPPhase]time.Duration, 4),
+	}
+}
+
+// clientTrace returns the hooks which fill in the timings. The transport may call them from several goroutines,
+// such as when it dials more than one of the host's addresses.
+func (t *httpTimings) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			if info.Reused {
+				t.connection = pbsmetrics.AdapterConnectionReused
+			} else {
+				t.connection = pbsmetrics.AdapterConnectionNew
+			}
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { t.start(pbsmetrics.AdapterHTTPPhaseDNS) },
+		DNSDone:              func(info httptrace.DNSDoneInfo) { t.done(pbsmetrics.AdapterHTTPPhaseDNS, info.Err) },
+		ConnectStart:         func(string, string) { t.start(pbsmetrics.AdapterHTTPPhaseConnect) },
+		ConnectDone:          func(_, _ string, err error) { t.done(pbsmetrics.AdapterHTTPPhaseConnect, err) },
+		TLSHandshakeStart:    func() { t.start(pbsmetrics.AdapterHTTPPhaseTLS) },
+		TLSHandshakeDone:     func(_ tls.ConnectionState, err error) { t.done(pbsmetrics.AdapterHTTPPhaseTLS, err) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.start(pbsmetrics.AdapterHTTPPhaseFirstByte) },
+		GotFirstResponseByte: func() { t.done(pbsmetrics.AdapterHTTPPhaseFirstByte, nil) },
+	}
+}
+
+// start marks the beginning of the phase. If it starts more than once, such as when several addresses are dialed,
+// it's timed from the first start.
+func (t *httpTimings) start(phase pbsmetrics.AdapterHTTPPhase) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.started[phase]; !ok {
+		t.started[phase] = time.Now()
+	}
+}
+
+// done marks the end of the phase, unless it failed. If it ends more than once, it's timed to the last success.
+func (t *httpTimings) done(phase pbsmetrics.AdapterHTTPPhase, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if started, ok := t.started[phase]; ok && err == nil {
+		t.phases[phase] = time.Since(started)
+	}
+}
+
+// record sends the timings to the metrics. Reused connections skip the DNS, connect and TLS phases, so the
+// phase timers only see the calls which paid for them.
+func (t *httpTimings) record(me pbsmetrics.MetricsEngine, labels pbsmetrics.AdapterLabels) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.connection != "" {
+		me.RecordAdapterConnection(labels, t.connection)
+	}
+	for phase, length := range t.phases {
+		me.RecordAdapterHTTPPhase(labels, phase, length)
+	}
+}
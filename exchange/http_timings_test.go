@@ -0,0 +1,84 @@
+package exchange
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/rcrowley/go-metrics"
+)
+
+// TestHTTPTimings makes sure that bidderAdapter.doRequest times the phases of its calls, and notices reused connections.
+func TestHTTPTimings(t *testing.T) {
+	server := httptest.NewTLSServer(mockHandler(200, "getBody", "postBody"))
+	defer server.Close()
+
+	bidder := &bidderAdapter{
+		Bidder: &mixedMultiBidder{},
+		Client: server.Client(),
+	}
+	reqData := &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	}
+
+	first := bidder.doRequest(context.Background(), reqData).timings
+	if first == nil || first.connection != pbsmetrics.AdapterConnectionNew {
+		t.Fatalf("The first call should open a new connection. Got %v", first)
+	}
+	for _, phase := range []pbsmetrics.AdapterHTTPPhase{pbsmetrics.AdapterHTTPPhaseConnect, pbsmetrics.AdapterHTTPPhaseTLS, pbsmetrics.AdapterHTTPPhaseFirstByte} {
+		if _, ok := first.phases[phase]; !ok {
+			t.Errorf("The first call should have a %s time. Got %v", phase, first.phases)
+		}
+	}
+	if _, ok := first.phases[pbsmetrics.AdapterHTTPPhaseDNS]; ok {
+		t.Errorf("The test server's address is an IP, so it shouldn't need a DNS lookup.")
+	}
+
+	second := bidder.doRequest(context.Background(), reqData).timings
+	if second == nil || second.connection != pbsmetrics.AdapterConnectionReused {
+		t.Fatalf("The second call should reuse the first's connection. Got %v", second)
+	}
+	if _, ok := second.phases[pbsmetrics.AdapterHTTPPhaseFirstByte]; !ok || len(second.phases) != 1 {
+		t.Errorf("Reused connections should only have a first_byte time. Got %v", second.phases)
+	}
+
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderAppnexus}
+	first.record(me, labels)
+	second.record(me, labels)
+	am := me.AdapterMetrics[openrtb_ext.BidderAppnexus]
+	if count := am.ConnectionMeters[pbsmetrics.AdapterConnectionReused].Count(); count != 1 {
+		t.Errorf("One reused connection should be recorded. Got %d", count)
+	}
+	if count := am.HTTPPhaseTimers[pbsmetrics.AdapterHTTPPhaseTLS].Count(); count != 1 {
+		t.Errorf("One TLS handshake should be recorded. Got %d", count)
+	}
+	if count := am.HTTPPhaseTimers[pbsmetrics.AdapterHTTPPhaseFirstByte].Count(); count != 2 {
+		t.Errorf("Both calls' first bytes should be recorded. Got %d", count)
+	}
+}
+
+// TestHTTPTimingsNoConnection makes sure that calls which never connect don't count as new or reused connections.
+func TestHTTPTimingsNoConnection(t *testing.T) {
+	server := httptest.NewServer(mockHandler(200, "getBody", "postBody"))
+	server.Close()
+
+	bidder := &bidderAdapter{
+		Bidder: &mixedMultiBidder{},
+		Client: server.Client(),
+	}
+	callInfo := bidder.doRequest(context.Background(), &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	})
+	if callInfo.timings == nil || callInfo.timings.connection != "" {
+		t.Fatalf("Refused calls shouldn't have a connection. Got %v", callInfo.timings)
+	}
+	if len(callInfo.timings.phases) != 0 {
+		t.Errorf("Refused calls shouldn't have any times, since the connect phase failed. Got %v", callInfo.timings.phases)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/buger/jsonparser"
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbs"
 	"github.com/prebid/prebid-server/usersync"
@@ -32,19 +33,31 @@ type adaptedAdapter struct {
 //
 // This is not ideal. OpenRTB provides a superset of the legacy data structures.
 // For requests which use those features, the best we can do is respond with "no bid".
-func (bidder *adaptedAdapter) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64) (*pbsOrtbSeatBid, []error) {
+func (bidder *adaptedAdapter) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
 	legacyRequest, legacyBidder, errs := bidder.toLegacyAdapterInputs(request, name)
 	if legacyRequest == nil || legacyBidder == nil {
 		return nil, errs
 	}
 
+	// Legacy adapters make their own HTTP calls, so they aren't in snapshots, and can't be replayed.
+	if replayerFrom(ctx) != nil {
+		return nil, append(errs, errors.New("Legacy adapters can't be replayed, since their calls aren't in the snapshot."))
+	}
 	legacyBids, err := bidder.adapter.Call(ctx, legacyRequest, legacyBidder)
 	if err != nil {
 		errs = append(errs, err)
 	}
 
-	for i := 0; i < len(legacyBids); i++ {
-		legacyBids[i].Price = legacyBids[i].Price * bidAdjustment
+	// Legacy adapters always bid in USD. The rate is only looked up if there are bids to convert, since every lookup
+	// counts as a conversion in the metrics.
+	if len(legacyBids) > 0 {
+		conversionRate, err := conversions.GetRate("USD", requestCurrency(request))
+		if err != nil {
+			return nil, append(errs, err)
+		}
+		for i := 0; i < len(legacyBids); i++ {
+			legacyBids[i].Price = legacyBids[i].Price * bidAdjustment * conversionRate
+		}
 	}
 
 	finalResponse, moreErrs := toNewResponse(legacyBids, legacyBidder, name)
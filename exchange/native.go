@@ -0,0 +1,142 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// Event and method IDs from the Native Ads 1.2 spec.
+const (
+	nativeEventImpression  = 1
+	nativeEventViewable50  = 2
+	nativeEventMethodImage = 1
+)
+
+type nativeEventTracker struct {
+	Event  int    `json:"event"`
+	Method int    `json:"method"`
+	URL    string `json:"url"`
+}
+
+// addNativeTrackers adds event trackers which point at the eventURL to the markup of each native bid.
+// Bids whose markup isn't a native response are left alone.
+func addNativeTrackers(seatBid *pbsOrtbSeatBid, eventURL string, viewable bool, bidder openrtb_ext.BidderName, account string) {
+	if seatBid == nil {
+		return
+	}
+	for _, bid := range seatBid.bids {
+		if bid.bidType != openrtb_ext.BidTypeNative || bid.bid == nil || bid.bid.AdM == "" {
+			continue
+		}
+		trackers := []nativeEventTracker{{
+			Event:  nativeEventImpression,
+			Method: nativeEventMethodImage,
+			URL:    makeEventURL(eventURL, pbsmetrics.EventImpression, bid.bid.ID, bidder, account),
+		}}
+		if viewable {
+			trackers = append(trackers, nativeEventTracker{
+				Event:  nativeEventViewable50,
+				Method: nativeEventMethodImage,
+				URL:    makeEventURL(eventURL, pbsmetrics.EventViewable, bid.bid.ID, bidder, account),
+			})
+		}
+		clickURL := makeEventURL(eventURL, pbsmetrics.EventClick, bid.bid.ID, bidder, account)
+		if adm, err := injectNativeTrackers(bid.bid.AdM, trackers, clickURL); err == nil {
+			bid.bid.AdM = adm
+		}
+	}
+}
+
+// makeEventURL returns the URL which reports the event for the bid to the /event endpoint.
+func makeEventURL(eventURL string, event pbsmetrics.EventType, bidID string, bidder openrtb_ext.BidderName, account string) string {
+	query := url.Values{}
+	query.Set("t", string(event))
+	query.Set("b", bidID)
+	query.Set("bidder", string(bidder))
+	if account != "" {
+		query.Set("a", account)
+	}
+	return eventURL + "?" + query.Encode()
+}
+
+// injectNativeTrackers adds the trackers to the native response's eventtrackers, and the clickURL to its link's
+// clicktrackers. The response may be wrapped in a "native" object, as in versions before 1.2.
+// The other fields are kept as they are.
+func injectNativeTrackers(adm string, trackers []nativeEventTracker, clickURL string) (string, error) {
+	var outer map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(adm), &outer); err != nil {
+		return "", err
+	}
+	native := outer
+	wrapped, isWrapped := outer["native"]
+	if isWrapped {
+		native = nil
+		if err := json.Unmarshal(wrapped, &native); err != nil {
+			return "", err
+		}
+	}
+	if native == nil {
+		return "", errors.New("the native response is null")
+	}
+
+	var eventTrackers []json.RawMessage
+	if raw, ok := native["eventtrackers"]; ok {
+		if err := json.Unmarshal(raw, &eventTrackers); err != nil {
+			return "", err
+		}
+	}
+	for _, tracker := range trackers {
+		raw, err := marshalUnescaped(tracker)
+		if err != nil {
+			return "", err
+		}
+		eventTrackers = append(eventTrackers, raw)
+	}
+	var err error
+	if native["eventtrackers"], err = marshalUnescaped(eventTrackers); err != nil {
+		return "", err
+	}
+
+	if raw, ok := native["link"]; ok {
+		var link map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &link); err != nil {
+			return "", err
+		}
+		var clickTrackers []string
+		if raw, ok := link["clicktrackers"]; ok {
+			if err := json.Unmarshal(raw, &clickTrackers); err != nil {
+				return "", err
+			}
+		}
+		if link["clicktrackers"], err = marshalUnescaped(append(clickTrackers, clickURL)); err != nil {
+			return "", err
+		}
+		if native["link"], err = marshalUnescaped(link); err != nil {
+			return "", err
+		}
+	}
+
+	if isWrapped {
+		if outer["native"], err = marshalUnescaped(native); err != nil {
+			return "", err
+		}
+	}
+	result, err := marshalUnescaped(outer)
+	return string(result), err
+}
+
+// marshalUnescaped is like json.Marshal, but leaves the &s in URLs alone.
+func marshalUnescaped(value interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,88 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestInjectNativeTrackers(t *testing.T) {
+	trackers := []nativeEventTracker{{Event: nativeEventImpression, Method: nativeEventMethodImage, URL: "http://pbs.com/event?t=imp&b=1"}}
+	adm, err := injectNativeTrackers(`{"ver":"1.2","assets":[{"id":1}],"link":{"url":"http://ad.com","clicktrackers":["http://bidder.com/click"]},"eventtrackers":[{"event":1,"method":1,"url":"http://bidder.com/imp"}]}`, trackers, "http://pbs.com/event?t=click&b=1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var parsed struct {
+		Ver    string            `json:"ver"`
+		Assets []json.RawMessage `json:"assets"`
+		Link   struct {
+			URL           string   `json:"url"`
+			ClickTrackers []string `json:"clicktrackers"`
+		} `json:"link"`
+		EventTrackers []nativeEventTracker `json:"eventtrackers"`
+	}
+	if err := json.Unmarshal([]byte(adm), &parsed); err != nil {
+		t.Fatalf("The markup should still be valid JSON. Got error %v for %s", err, adm)
+	}
+	assertStringValue(t, "ver", "1.2", parsed.Ver)
+	assertStringValue(t, "link.url", "http://ad.com", parsed.Link.URL)
+	if len(parsed.Assets) != 1 {
+		t.Errorf("The assets should be kept. Got %s", adm)
+	}
+	if len(parsed.EventTrackers) != 2 || parsed.EventTrackers[0].URL != "http://bidder.com/imp" || parsed.EventTrackers[1] != trackers[0] {
+		t.Errorf("The tracker should be added after the bidder's. Got %v", parsed.EventTrackers)
+	}
+	if len(parsed.Link.ClickTrackers) != 2 || parsed.Link.ClickTrackers[1] != "http://pbs.com/event?t=click&b=1" {
+		t.Errorf("The click tracker should be added after the bidder's. Got %v", parsed.Link.ClickTrackers)
+	}
+}
+
+func TestInjectWrappedNativeTrackers(t *testing.T) {
+	trackers := []nativeEventTracker{{Event: nativeEventImpression, Method: nativeEventMethodImage, URL: "http://pbs.com/event"}}
+	adm, err := injectNativeTrackers(`{"native":{"ver":"1.1","assets":[]}}`, trackers, "http://pbs.com/click")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertStringValue(t, "adm", `{"native":{"assets":[],"eventtrackers":[{"event":1,"method":1,"url":"http://pbs.com/event"}],"ver":"1.1"}}`, adm)
+
+	if _, err := injectNativeTrackers("<div>not native</div>", trackers, "http://pbs.com/click"); err == nil {
+		t.Errorf("Markup which isn't JSON should return an error.")
+	}
+	if _, err := injectNativeTrackers(`{"native":null}`, trackers, "http://pbs.com/click"); err == nil {
+		t.Errorf("Null native responses should return an error.")
+	}
+}
+
+func TestAddNativeTrackers(t *testing.T) {
+	native := &openrtb.Bid{ID: "native", AdM: `{"assets":[]}`}
+	banner := &openrtb.Bid{ID: "banner", AdM: "<div></div>"}
+	broken := &openrtb.Bid{ID: "broken", AdM: "not json"}
+	seatBid := &pbsOrtbSeatBid{
+		bids: []*pbsOrtbBid{
+			{bid: native, bidType: openrtb_ext.BidTypeNative},
+			{bid: banner, bidType: openrtb_ext.BidTypeBanner},
+			{bid: broken, bidType: openrtb_ext.BidTypeNative},
+		},
+	}
+	addNativeTrackers(seatBid, "http://pbs.com/event", true, "districtm", "1001")
+
+	var parsed struct {
+		EventTrackers []nativeEventTracker `json:"eventtrackers"`
+	}
+	if err := json.Unmarshal([]byte(native.AdM), &parsed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(parsed.EventTrackers) != 2 {
+		t.Fatalf("Native bids should get impression and viewable trackers. Got %s", native.AdM)
+	}
+	assertStringValue(t, "impression tracker", "http://pbs.com/event?a=1001&b=native&bidder=districtm&t=imp", parsed.EventTrackers[0].URL)
+	assertStringValue(t, "viewable tracker", "http://pbs.com/event?a=1001&b=native&bidder=districtm&t=vimp", parsed.EventTrackers[1].URL)
+	if parsed.EventTrackers[1].Event != nativeEventViewable50 {
+		t.Errorf("The viewable tracker should be for the viewable-mrc50 event. Got %d", parsed.EventTrackers[1].Event)
+	}
+	assertStringValue(t, "banner adm", "<div></div>", banner.AdM)
+	assertStringValue(t, "broken adm", "not json", broken.AdM)
+}
@@ -0,0 +1,35 @@
+package exchange
+
+import (
+	"encoding/json"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+// readPassthrough returns the ext.prebid.passthrough from a request or imp ext, or nil if it doesn't have one.
+// Passthrough data belongs to the caller, so it's returned exactly as it was sent.
+func readPassthrough(ext openrtb.RawJSON) openrtb.RawJSON {
+	var parsed struct {
+		Prebid struct {
+			Passthrough openrtb.RawJSON `json:"passthrough"`
+		} `json:"prebid"`
+	}
+	if len(ext) == 0 || json.Unmarshal(ext, &parsed) != nil {
+		return nil
+	}
+	return parsed.Prebid.Passthrough
+}
+
+// readImpPassthrough returns the imp.ext.prebid.passthrough of each imp which has one, keyed by imp ID.
+func readImpPassthrough(imps []openrtb.Imp) map[string]openrtb.RawJSON {
+	var passthrough map[string]openrtb.RawJSON
+	for _, imp := range imps {
+		if impPassthrough := readPassthrough(imp.Ext); impPassthrough != nil {
+			if passthrough == nil {
+				passthrough = make(map[string]openrtb.RawJSON)
+			}
+			passthrough[imp.ID] = impPassthrough
+		}
+	}
+	return passthrough
+}
@@ -0,0 +1,75 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestPassthrough(t *testing.T) {
+	bidRequest := &openrtb.BidRequest{
+		ID: "request",
+		Imp: []openrtb.Imp{
+			{ID: "imp-1", Ext: openrtb.RawJSON(`{"prebid":{"passthrough":{"slot":"top","ids":[1,2]}},"appnexus":{"placementId":1}}`)},
+			{ID: "imp-2", Ext: openrtb.RawJSON(`{"appnexus":{"placementId":2}}`)},
+		},
+		Ext: openrtb.RawJSON(`{"prebid":{"passthrough":"correlation-id"}}`),
+	}
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {
+			bids: []*pbsOrtbBid{
+				{bid: &openrtb.Bid{ID: "bid-1", ImpID: "imp-1", Price: 1}, bidType: openrtb_ext.BidTypeBanner},
+				{bid: &openrtb.Bid{ID: "bid-2", ImpID: "imp-2", Price: 1}, bidType: openrtb_ext.BidTypeBanner},
+			},
+		},
+	}
+	adapterExtra := map[openrtb_ext.BidderName]*seatResponseExtra{openrtb_ext.BidderAppnexus: {}}
+
+	response, err := (&exchange{}).buildBidResponse(context.Background(), []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}, adapterBids, bidRequest, nil, adapterExtra, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var responseExt openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	if responseExt.Prebid == nil || string(responseExt.Prebid.Passthrough) != `"correlation-id"` {
+		t.Errorf("request.ext.prebid.passthrough should be in response.ext.prebid.passthrough. Got %s", response.Ext)
+	}
+
+	bids := response.SeatBid[0].Bid
+	if len(bids) != 2 {
+		t.Fatalf("Expected 2 bids. Got %d", len(bids))
+	}
+	assertBidPassthrough(t, bids[0], `{"slot":"top","ids":[1,2]}`)
+	assertBidPassthrough(t, bids[1], "")
+}
+
+func TestNoPassthrough(t *testing.T) {
+	bidRequest := &openrtb.BidRequest{ID: "request", Ext: openrtb.RawJSON(`{"prebid":{"targeting":{}}}`)}
+	response, err := (&exchange{}).buildBidResponse(context.Background(), nil, nil, bidRequest, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var responseExt map[string]json.RawMessage
+	if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	if prebid, ok := responseExt["prebid"]; ok {
+		t.Errorf("response.ext.prebid should be left out if there's no passthrough. Got %s", prebid)
+	}
+}
+
+func assertBidPassthrough(t *testing.T, bid openrtb.Bid, expected string) {
+	t.Helper()
+	var ext openrtb_ext.ExtBid
+	if err := json.Unmarshal(bid.Ext, &ext); err != nil {
+		t.Fatalf("Failed to unmarshal bid ext: %v", err)
+	}
+	if string(ext.Prebid.Passthrough) != expected {
+		t.Errorf("Bid %s should have passthrough %s. Got %s", bid.ID, expected, ext.Prebid.Passthrough)
+	}
+}
@@ -0,0 +1,62 @@
+package exchange
+
+import (
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// pinnedDeals are an account's deals which must win their imp whenever they bid, such as programmatic guaranteed
+// line items. The zero value has none.
+type pinnedDeals struct {
+	dealIDs map[string]bool
+	// earlyTermination stops the wait for the bidders as soon as every imp has a bid from a pinned deal.
+	earlyTermination bool
+}
+
+// newPinnedDeals returns the known accounts' pinned deals, by account ID.
+func newPinnedDeals(known []config.Account) map[string]pinnedDeals {
+	accounts := make(map[string]pinnedDeals, len(known))
+	for _, account := range known {
+		dealIDs := make(map[string]bool, len(account.PinnedDeals.DealIDs))
+		for _, dealID := range account.PinnedDeals.DealIDs {
+			dealIDs[dealID] = true
+		}
+		accounts[account.ID] = pinnedDeals{dealIDs: dealIDs, earlyTermination: account.PinnedDeals.EarlyTermination}
+	}
+	return accounts
+}
+
+func (p pinnedDeals) isPinned(bid *pbsOrtbBid) bool {
+	return bid.bid.DealID != "" && p.dealIDs[bid.bid.DealID]
+}
+
+// pinnedImps returns the IDs of the imps which have a bid from one of the pinned deals. Nothing else can win them.
+func (p pinnedDeals) pinnedImps(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid) map[string]bool {
+	imps := make(map[string]bool)
+	if len(p.dealIDs) == 0 {
+		return imps
+	}
+	for _, seatBid := range adapterBids {
+		if seatBid == nil {
+			continue
+		}
+		for _, bid := range seatBid.bids {
+			if p.isPinned(bid) {
+				imps[bid.bid.ImpID] = true
+			}
+		}
+	}
+	return imps
+}
+
+// impIDs returns the IDs of the imps which any of the bidders were asked to bid on.
+func impIDs(cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest) map[string]bool {
+	ids := make(map[string]bool)
+	for _, request := range cleanRequests {
+		for _, imp := range request.Imp {
+			ids[imp.ID] = true
+		}
+	}
+	return ids
+}
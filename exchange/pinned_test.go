@@ -0,0 +1,143 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestNewPinnedDeals(t *testing.T) {
+	accounts := newPinnedDeals([]config.Account{
+		{ID: "publisher", PinnedDeals: config.PinnedDeals{DealIDs: []string{"pg-1"}, EarlyTermination: true}},
+	})
+	pinned := accounts["publisher"]
+	if !pinned.earlyTermination || !pinned.isPinned(&pbsOrtbBid{bid: &openrtb.Bid{DealID: "pg-1"}}) {
+		t.Errorf("The account's deal should be pinned, with early termination. Got %v", pinned)
+	}
+	if pinned.isPinned(&pbsOrtbBid{bid: &openrtb.Bid{DealID: "pmp-1"}}) || pinned.isPinned(&pbsOrtbBid{bid: &openrtb.Bid{}}) {
+		t.Errorf("Other deals, and bids without a deal, shouldn't be pinned.")
+	}
+	if other := accounts["other"]; other.isPinned(&pbsOrtbBid{bid: &openrtb.Bid{DealID: "pg-1"}}) || other.earlyTermination {
+		t.Errorf("Other accounts shouldn't have any pinned deals.")
+	}
+}
+
+func TestPinnedImps(t *testing.T) {
+	pinned := pinnedDeals{dealIDs: map[string]bool{"pg-1": true}}
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		"appnexus": {bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ImpID: "imp-1", DealID: "pg-1"}}, {bid: &openrtb.Bid{ImpID: "imp-2"}}}},
+		"rubicon":  {bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ImpID: "imp-3", DealID: "pmp-1"}}}},
+		"openx":    nil,
+	}
+	if imps := pinned.pinnedImps(adapterBids); len(imps) != 1 || !imps["imp-1"] {
+		t.Errorf("Only the imp with the pinned deal's bid should be returned. Got %v", imps)
+	}
+	if imps := (pinnedDeals{}).pinnedImps(adapterBids); len(imps) != 0 {
+		t.Errorf("Accounts without pinned deals shouldn't have any pinned imps. Got %v", imps)
+	}
+}
+
+func TestPinnedDealsWin(t *testing.T) {
+	seatBids := tiedSeatBids()
+	seatBids[openrtb_ext.BidderAppnexus].bids[0].bid.Price = 10
+	seatBids[openrtb_ext.BidderRubicon].bids[0].bid.DealID = "pg-1"
+
+	ties := newTieBreaker(config.TieBreaking{}, &openrtb.BidRequest{})
+	ties.pinned = pinnedDeals{dealIDs: map[string]bool{"pg-1": true}}
+	if winner := newAuction(seatBids, 1, ties).winningBids["imp"].bid.ID; winner != "rubicon-bid" {
+		t.Errorf("The pinned deal should win, whatever the other bids are priced at. Got %s", winner)
+	}
+}
+
+func TestEarlyTermination(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ex := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &dealBidder{dealID: "pg-1"},
+			openrtb_ext.BidderRubicon:  &slowBidder{release: release},
+		},
+		me: pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList()),
+		pinnedDeals: newPinnedDeals([]config.Account{
+			{ID: "publisher", PinnedDeals: config.PinnedDeals{DealIDs: []string{"pg-1"}, EarlyTermination: true}},
+		}),
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		openrtb_ext.BidderAppnexus: {Imp: []openrtb.Imp{{ID: "imp"}}},
+		openrtb_ext.BidderRubicon:  {Imp: []openrtb.Imp{{ID: "imp"}}},
+	}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+		openrtb_ext.BidderRubicon:  {Adapter: openrtb_ext.BidderRubicon},
+	}
+
+	// The slow bidder never responds until the test ends, so this would hang without early termination.
+	bids, extra := ex.getAllBids(context.Background(), cleanRequests, nil, nil, nil, blabels, "publisher", false)
+	if appnexusBids := bids[openrtb_ext.BidderAppnexus]; appnexusBids == nil || len(appnexusBids.bids) != 1 {
+		t.Errorf("The pinned deal's bid should be in the response. Got %v", appnexusBids)
+	}
+	if _, ok := bids[openrtb_ext.BidderRubicon]; ok {
+		t.Errorf("The bidder which was still running shouldn't be in the response.")
+	}
+	if rubiconExtra, ok := extra[openrtb_ext.BidderRubicon]; !ok || len(rubiconExtra.Errors) != 1 {
+		t.Errorf("The bidder which was still running should have an error in the response ext. Got %v", rubiconExtra)
+	}
+}
+
+func TestEarlyTerminationExt(t *testing.T) {
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList())
+	ex := NewExchange(http.DefaultClient, &wellBehavedCache{}, &config.Configuration{
+		Accounts: config.Accounts{Known: []config.Account{
+			{ID: "publisher", PinnedDeals: config.PinnedDeals{DealIDs: []string{"pg-1"}, EarlyTermination: true}},
+		}},
+	}, me, gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil), nil, nil, nil, nil).(*exchange)
+	ex.adapterMap = map[openrtb_ext.BidderName]adaptedBidder{
+		openrtb_ext.BidderAppnexus: &aliasSlowBidder{dealID: "pg-1"},
+	}
+	bidRequest := aliasedRequest()
+	bidRequest.Site = &openrtb.Site{Publisher: &openrtb.Publisher{ID: "publisher"}}
+
+	// The alias never responds until the auction is cancelled, so this would hang without early termination.
+	bidResponse, err := ex.HoldAuction(context.Background(), bidRequest, &emptyUsersync{}, pbsmetrics.Labels{})
+	if err != nil {
+		t.Fatalf("The auction shouldn't fail. Got %v", err)
+	}
+	var ext openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(bidResponse.Ext, &ext); err != nil {
+		t.Fatalf("Bad response ext: %v", err)
+	}
+	if errs := ext.Errors["districtm"]; len(errs) != 1 || !strings.Contains(errs[0], "pinned deal") {
+		t.Errorf("The bidder which was still running should have an error in the response ext. Got %v", ext.Errors)
+	}
+	if _, ok := ext.ResponseTimeMillis["districtm"]; !ok {
+		t.Errorf("The bidder which was still running should have a response time in the response ext. Got %v", ext.ResponseTimeMillis)
+	}
+	// The cancelled bidder finishes in the background, while the winning bid is recorded.
+	waitForAdapterRequests(me, openrtb_ext.BidderAppnexus, 2)
+}
+
+// dealBidder bids on every imp with the deal.
+type dealBidder struct {
+	dealID string
+}
+
+func (b *dealBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*pbsOrtbSeatBid, []error) {
+	seatBid := &pbsOrtbSeatBid{}
+	for _, imp := range request.Imp {
+		seatBid.bids = append(seatBid.bids, &pbsOrtbBid{
+			bid:     &openrtb.Bid{ID: "deal-bid", ImpID: imp.ID, Price: 1, CrID: "creative", DealID: b.dealID},
+			bidType: openrtb_ext.BidTypeBanner,
+		})
+	}
+	return seatBid, nil
+}
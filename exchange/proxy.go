@@ -0,0 +1,38 @@
+package exchange
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prebid/prebid-server/ssl"
+)
+
+// withProxy returns a copy of the client which sends its requests through the proxy.
+// If connectTimeout is positive, connecting to the proxy fails once it takes that long.
+// The client's Transport must be an *http.Transport.
+func withProxy(client *http.Client, proxyURL string, connectTimeout time.Duration) (*http.Client, error) {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("proxies need an *http.Transport. Got %T", client.Transport)
+	}
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	transport = ssl.CopyTransport(transport)
+	transport.Proxy = http.ProxyURL(parsed)
+	if connectTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	withProxy := *client
+	withProxy.Transport = transport
+	return &withProxy, nil
+}
@@ -0,0 +1,50 @@
+package exchange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+func TestWithProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	client, err := withProxy(&http.Client{Transport: &http.Transport{}}, proxy.URL, time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := client.Get("http://bidder.example.com/openrtb2")
+	if err != nil {
+		t.Fatalf("The request should go through the proxy. Got %v", err)
+	}
+	resp.Body.Close()
+	if proxiedHost != "bidder.example.com" {
+		t.Errorf("The proxy should get the request for the bidder's server. Got %s", proxiedHost)
+	}
+
+	if _, err := withProxy(&http.Client{}, proxy.URL, 0); err == nil {
+		t.Error("Clients without an *http.Transport should be an error.")
+	}
+}
+
+// TestConnectTimeoutMetrics makes sure that timeouts connecting to a server or proxy count as timeouts.
+func TestConnectTimeoutMetrics(t *testing.T) {
+	errs := errorsToMetric([]error{&timeoutError{}})
+	if _, ok := errs[pbsmetrics.AdapterErrorTimeout]; !ok {
+		t.Errorf("Network timeouts should be recorded in the %s error metric. Got %v", pbsmetrics.AdapterErrorTimeout, errs)
+	}
+}
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "proxyconnect tcp: i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
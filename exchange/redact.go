@@ -0,0 +1,159 @@
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// redactedValue replaces the strings which were removed from the debugging info.
+const redactedValue = "[REDACTED]"
+
+// redactedKeys are the JSON keys whose values identify the user, or where they are.
+// They're matched anywhere in a document, because each bidder has its own request format.
+var redactedKeys = map[string]bool{
+	"ip":         true,
+	"ipv6":       true,
+	"ifa":        true,
+	"didsha1":    true,
+	"didmd5":     true,
+	"dpidsha1":   true,
+	"dpidmd5":    true,
+	"macsha1":    true,
+	"macmd5":     true,
+	"buyeruid":   true,
+	"buyeruids":  true,
+	"customdata": true,
+	"eids":       true,
+	"digitrust":  true,
+	"lat":        true,
+	"lon":        true,
+}
+
+// redactedChildren are keys which are only redacted inside the given parent, because they mean something else elsewhere.
+var redactedChildren = map[string]map[string]bool{
+	"user": {"id": true},
+}
+
+// redactedQueryKeys are the query params which identify the user, on top of the redactedKeys. Bidders which take
+// their requests in the URL each name them in their own way.
+var redactedQueryKeys = map[string]bool{
+	"uid":       true,
+	"userid":    true,
+	"user_id":   true,
+	"idfa":      true,
+	"aaid":      true,
+	"gaid":      true,
+	"adid":      true,
+	"deviceid":  true,
+	"device_id": true,
+}
+
+// redactHttpCalls returns copies of the calls whose URIs, and request and response bodies, have had their
+// personal data removed.
+func redactHttpCalls(calls []*openrtb_ext.ExtHttpCall) []*openrtb_ext.ExtHttpCall {
+	redacted := make([]*openrtb_ext.ExtHttpCall, 0, len(calls))
+	for _, call := range calls {
+		if call == nil {
+			continue
+		}
+		redactedCall := *call
+		redactedCall.Uri = redactURI(call.Uri)
+		redactedCall.RequestBody = string(redactJSON([]byte(call.RequestBody)))
+		redactedCall.ResponseBody = string(redactJSON([]byte(call.ResponseBody)))
+		redacted = append(redacted, &redactedCall)
+	}
+	return redacted
+}
+
+// redactURI replaces the values of the query params which identify the user with redactedValue.
+// The other params are kept as they are, and in the same order. URIs which can't be parsed lose their whole query.
+func redactURI(uri string) string {
+	queryStart := strings.IndexByte(uri, '?')
+	if queryStart < 0 {
+		return uri
+	}
+	params := strings.Split(uri[queryStart+1:], "&")
+	for i, param := range params {
+		key := param
+		if equals := strings.IndexByte(param, '='); equals >= 0 {
+			key = param[:equals]
+		}
+		unescaped, err := url.QueryUnescape(key)
+		if err != nil {
+			return uri[:queryStart]
+		}
+		if lower := strings.ToLower(unescaped); redactedKeys[lower] || redactedQueryKeys[lower] {
+			params[i] = key + "=" + url.QueryEscape(redactedValue)
+		}
+	}
+	return uri[:queryStart+1] + strings.Join(params, "&")
+}
+
+// redactJSON removes the personal data from a JSON document. Strings are replaced by redactedValue, so that it's
+// clear something was there, and any other values are removed. Data which isn't JSON is dropped, since there's no
+// telling what's in it, and nil is returned.
+func redactJSON(data []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return nil
+	}
+	if !redactValue(doc, nil) {
+		return data
+	}
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+// redactValue redacts the value in place, and returns true if it changed anything.
+// parentKeys holds the redactedChildren of the object which contains value, if any.
+func redactValue(value interface{}, parentKeys map[string]bool) bool {
+	changed := false
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			if redactedKeys[key] || parentKeys[key] {
+				if _, ok := child.(string); ok {
+					typed[key] = redactedValue
+				} else {
+					delete(typed, key)
+				}
+				changed = true
+				continue
+			}
+			if redactValue(child, redactedChildren[key]) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range typed {
+			if redactValue(child, nil) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+type noDebugKey struct{}
+
+// WithoutDebug returns a context whose auction leaves the debugging info out of its response, even if the request is
+// a test request. Bidders still get request.test, so that they bid as they would for any test.
+func WithoutDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noDebugKey{}, true)
+}
+
+// debugAllowed returns true unless the context's auction was told to leave out the debugging info.
+func debugAllowed(ctx context.Context) bool {
+	noDebug, _ := ctx.Value(noDebugKey{}).(bool)
+	return !noDebug
+}
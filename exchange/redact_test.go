@@ -0,0 +1,136 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestRedactJSON(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		expected    string
+	}{
+		{
+			description: "OpenRTB request",
+			input:       `{"id":"req","device":{"ip":"1.2.3.4","ifa":"abc","ua":"agent","geo":{"lat":12.5,"lon":-3.25,"country":"USA"}},"user":{"id":"user-1","buyeruid":"uid","ext":{"eids":[{"source":"x"}],"consent":"BONV"}}}`,
+			expected:    `{"id":"req","device":{"ip":"[REDACTED]","ifa":"[REDACTED]","ua":"agent","geo":{"country":"USA"}},"user":{"id":"[REDACTED]","buyeruid":"[REDACTED]","ext":{"consent":"BONV"}}}`,
+		},
+		{
+			description: "IDs outside the user are kept",
+			input:       `{"imp":[{"id":"imp-1","ext":{"ip":"5.6.7.8"}}],"site":{"id":"site-1"}}`,
+			expected:    `{"imp":[{"id":"imp-1","ext":{"ip":"[REDACTED]"}}],"site":{"id":"site-1"}}`,
+		},
+		{
+			description: "Nothing to redact",
+			input:       `{"id":"req","tmax":500}`,
+			expected:    `{"id":"req","tmax":500}`,
+		},
+	}
+
+	for _, test := range testCases {
+		var actual, expected interface{}
+		if err := json.Unmarshal(redactJSON([]byte(test.input)), &actual); err != nil {
+			t.Errorf("%s: redacted JSON is invalid: %v", test.description, err)
+			continue
+		}
+		json.Unmarshal([]byte(test.expected), &expected)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %s. Got %v", test.description, test.expected, actual)
+		}
+	}
+}
+
+func TestRedactNonJSON(t *testing.T) {
+	for _, body := range []string{"", "not json", `{"ip":"1.2.3.4"} trailing`, "<VAST><Ad>192.0.2.1</Ad></VAST>"} {
+		if redacted := redactJSON([]byte(body)); redacted != nil {
+			t.Errorf("Bodies which aren't JSON should be dropped. Got %q for %q", redacted, body)
+		}
+	}
+}
+
+func TestRedactURI(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		expected    string
+	}{
+		{
+			description: "No query",
+			input:       "http://bidder.com/bid",
+			expected:    "http://bidder.com/bid",
+		},
+		{
+			description: "Personal params",
+			input:       "http://bidder.com/bid?placement=123&IP=1.2.3.4&uid=abc&ifa&ts=1",
+			expected:    "http://bidder.com/bid?placement=123&IP=%5BREDACTED%5D&uid=%5BREDACTED%5D&ifa=%5BREDACTED%5D&ts=1",
+		},
+		{
+			description: "Escaped keys",
+			input:       "http://bidder.com/bid?user%5Fid=abc",
+			expected:    "http://bidder.com/bid?user%5Fid=%5BREDACTED%5D",
+		},
+		{
+			description: "Unparseable query",
+			input:       "http://bidder.com/bid?ip%zz=1.2.3.4&placement=123",
+			expected:    "http://bidder.com/bid",
+		},
+	}
+	for _, test := range testCases {
+		if actual := redactURI(test.input); actual != test.expected {
+			t.Errorf("%s: expected %s. Got %s", test.description, test.expected, actual)
+		}
+	}
+}
+
+func TestRedactHttpCalls(t *testing.T) {
+	original := &openrtb_ext.ExtHttpCall{
+		Uri:          "http://bidder.com?ip=1.2.3.4",
+		RequestBody:  `{"device":{"ip":"1.2.3.4"}}`,
+		ResponseBody: `{"seatbid":[]}`,
+		Status:       200,
+	}
+	redacted := redactHttpCalls([]*openrtb_ext.ExtHttpCall{original, nil})
+
+	if len(redacted) != 1 {
+		t.Fatalf("Expected 1 redacted call. Got %d", len(redacted))
+	}
+	if redacted[0].RequestBody != `{"device":{"ip":"[REDACTED]"}}` {
+		t.Errorf("The request body should be redacted. Got %s", redacted[0].RequestBody)
+	}
+	if redacted[0].Uri != "http://bidder.com?ip=%5BREDACTED%5D" {
+		t.Errorf("The personal data in the URI should be redacted. Got %s", redacted[0].Uri)
+	}
+	if redacted[0].ResponseBody != original.ResponseBody || redacted[0].Status != original.Status {
+		t.Errorf("The rest of the call should be unchanged. Got %v", redacted[0])
+	}
+	if original.RequestBody != `{"device":{"ip":"1.2.3.4"}}` {
+		t.Errorf("The original call shouldn't be modified. Got %s", original.RequestBody)
+	}
+}
+
+func TestWithoutDebug(t *testing.T) {
+	if !debugAllowed(context.Background()) {
+		t.Error("Auctions should include the debugging info by default.")
+	}
+	if debugAllowed(WithoutDebug(context.Background())) {
+		t.Error("WithoutDebug should leave the debugging info out.")
+	}
+
+	ex := &exchange{}
+	bids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {httpCalls: []*openrtb_ext.ExtHttpCall{{Uri: "http://bidder.com"}}},
+	}
+	extra := map[openrtb_ext.BidderName]*seatResponseExtra{openrtb_ext.BidderAppnexus: {}}
+	if responseExt := ex.makeExtBidResponse(bids, extra, &openrtb.BidRequest{Test: 1}, false, json.RawMessage(`{}`), nil); responseExt.Debug != nil {
+		t.Errorf("Test requests which may not see the debugging info shouldn't get it. Got %v", responseExt.Debug)
+	}
+	if responseExt := ex.makeExtBidResponse(bids, extra, &openrtb.BidRequest{Test: 1}, true, json.RawMessage(`{}`), nil); responseExt.Debug == nil || len(responseExt.Debug.HttpCalls[openrtb_ext.BidderAppnexus]) != 1 {
+		t.Errorf("Test requests should get the debugging info. Got %v", responseExt.Debug)
+	}
+}
@@ -0,0 +1,39 @@
+package exchange
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// ReloadableExchange is an Exchange which can be rebuilt from a new config while the server is running.
+// Auctions which are already running finish with the Exchange they started with.
+type ReloadableExchange struct {
+	build   func(cfg *config.Configuration) Exchange
+	current atomic.Value
+}
+
+// exchangeHolder lets the atomic.Value hold Exchanges of different types.
+type exchangeHolder struct {
+	exchange Exchange
+}
+
+// NewReloadableExchange builds the first Exchange from cfg. build is called again with the new config on each Reload.
+func NewReloadableExchange(build func(cfg *config.Configuration) Exchange, cfg *config.Configuration) *ReloadableExchange {
+	e := &ReloadableExchange{build: build}
+	e.current.Store(exchangeHolder{build(cfg)})
+	return e
+}
+
+func (e *ReloadableExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	return e.current.Load().(exchangeHolder).exchange.HoldAuction(ctx, bidRequest, usersyncs, labels)
+}
+
+// Reload builds a new Exchange from cfg, and uses it for all the auctions which start afterwards.
+// cfg should already be validated.
+func (e *ReloadableExchange) Reload(cfg *config.Configuration) {
+	e.current.Store(exchangeHolder{e.build(cfg)})
+}
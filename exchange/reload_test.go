@@ -0,0 +1,41 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+func TestReloadableExchange(t *testing.T) {
+	build := func(cfg *config.Configuration) Exchange {
+		return &configIDExchange{id: cfg.TieBreaking.Strategy}
+	}
+	ex := NewReloadableExchange(build, &config.Configuration{TieBreaking: config.TieBreaking{Strategy: config.TieBreakRandom}})
+	assertExchangeID(t, ex, config.TieBreakRandom)
+
+	ex.Reload(&config.Configuration{TieBreaking: config.TieBreaking{Strategy: config.TieBreakDealPriority}})
+	assertExchangeID(t, ex, config.TieBreakDealPriority)
+}
+
+func assertExchangeID(t *testing.T, ex Exchange, expected string) {
+	t.Helper()
+	resp, err := ex.HoldAuction(context.Background(), &openrtb.BidRequest{}, nil, pbsmetrics.Labels{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.ID != expected {
+		t.Errorf("The auction should be held by the exchange built from the %s config. Got %s", expected, resp.ID)
+	}
+}
+
+// configIDExchange responds with the ID of the config it was built from.
+type configIDExchange struct {
+	id string
+}
+
+func (e *configIDExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	return &openrtb.BidResponse{ID: e.id}, nil
+}
@@ -0,0 +1,30 @@
+package exchange
+
+import (
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// addRenderers names the account's renderer in the meta of each bid whose bidder didn't name one, so that the
+// mobile SDKs know how to show it. The bidder's renderer always wins, since it knows what its markup needs.
+func addRenderers(seatBid *pbsOrtbSeatBid, renderers *config.AccountRenderers) {
+	if seatBid == nil || *renderers == (config.AccountRenderers{}) {
+		return
+	}
+	for _, bid := range seatBid.bids {
+		if bid.bidMeta != nil && bid.bidMeta.RendererName != "" {
+			continue
+		}
+		renderer := renderers.RendererFor(string(bid.bidType))
+		if renderer.Name == "" {
+			continue
+		}
+		var meta openrtb_ext.ExtBidPrebidMeta
+		if bid.bidMeta != nil {
+			meta = *bid.bidMeta
+		}
+		meta.RendererName = renderer.Name
+		meta.RendererVersion = renderer.Version
+		bid.bidMeta = &meta
+	}
+}
@@ -0,0 +1,37 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestAddRenderers(t *testing.T) {
+	renderers := &config.AccountRenderers{Video: config.Renderer{Name: "outstream", Version: "1.2"}}
+	bidderMeta := &openrtb_ext.ExtBidPrebidMeta{RendererName: "bidder-player", RendererVersion: "2.0"}
+	seatBid := &pbsOrtbSeatBid{bids: []*pbsOrtbBid{
+		{bid: &openrtb.Bid{ID: "video"}, bidType: openrtb_ext.BidTypeVideo},
+		{bid: &openrtb.Bid{ID: "video-with-renderer"}, bidType: openrtb_ext.BidTypeVideo, bidMeta: bidderMeta},
+		{bid: &openrtb.Bid{ID: "banner"}, bidType: openrtb_ext.BidTypeBanner},
+	}}
+
+	addRenderers(seatBid, renderers)
+	if meta := seatBid.bids[0].bidMeta; meta == nil || meta.RendererName != "outstream" || meta.RendererVersion != "1.2" {
+		t.Errorf("Video bids should get the account's renderer. Got %#v", meta)
+	}
+	if meta := seatBid.bids[1].bidMeta; meta != bidderMeta || meta.RendererName != "bidder-player" {
+		t.Errorf("The bidder's renderer should win. Got %#v", meta)
+	}
+	if meta := seatBid.bids[2].bidMeta; meta != nil {
+		t.Errorf("The account doesn't have a banner renderer. Got %#v", meta)
+	}
+
+	seatBid.bids[0].bidMeta = nil
+	addRenderers(seatBid, &config.AccountRenderers{})
+	if meta := seatBid.bids[0].bidMeta; meta != nil {
+		t.Errorf("Accounts without renderers shouldn't get any. Got %#v", meta)
+	}
+	addRenderers(nil, renderers)
+}
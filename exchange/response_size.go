@@ -0,0 +1,150 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// responseBidKey identifies a bid in the response.
+type responseBidKey struct {
+	seat  string
+	impID string
+	bidID string
+}
+
+// keptBids returns the bids which are never pruned from the response: the winning bid in each imp,
+// and the bids with targeting keys, which the ad server may be given.
+func keptBids(auc *auction, adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid) map[responseBidKey]bool {
+	kept := make(map[responseBidKey]bool, len(auc.winningBids))
+	for bidderName, seatBid := range adapterBids {
+		if seatBid == nil {
+			continue
+		}
+		for _, bid := range seatBid.bids {
+			if len(bid.bidTargets) > 0 || auc.winningBids[bid.bid.ImpID] == bid {
+				kept[responseBidKey{bidderName.String(), bid.bid.ImpID, bid.bid.ID}] = true
+			}
+		}
+	}
+	return kept
+}
+
+// pruneResponse removes parts of the response until it's no bigger than maxBytes. response.ext.debug goes first,
+// and then the bids which aren't kept, from the lowest price up. If anything was removed, a warning in
+// response.ext.warnings says so. The response may still be too big, if the kept bids don't fit on their own.
+func pruneResponse(bidResponse *openrtb.BidResponse, maxBytes int, kept map[responseBidKey]bool) error {
+	var ext map[string]json.RawMessage
+	if len(bidResponse.Ext) > 0 {
+		if err := json.Unmarshal(bidResponse.Ext, &ext); err != nil {
+			return err
+		}
+	}
+	var warnings map[string][]string
+	if rawWarnings, ok := ext["warnings"]; ok {
+		if err := json.Unmarshal(rawWarnings, &warnings); err != nil {
+			return err
+		}
+	}
+	prunedDebug := false
+	prunedBids := 0
+	warned := false
+	losers := losingBids(bidResponse, kept)
+
+	for {
+		size, err := responseSize(bidResponse)
+		if err != nil {
+			return err
+		}
+		if size <= maxBytes {
+			return nil
+		}
+		if _, ok := ext["debug"]; ok {
+			delete(ext, "debug")
+			prunedDebug = true
+		} else if prunedBids < len(losers) {
+			removeBid(bidResponse, losers[prunedBids])
+			prunedBids++
+		} else {
+			return nil
+		}
+
+		if warnings == nil {
+			warnings = make(map[string][]string, 1)
+		}
+		prebidWarnings := warnings[string(openrtb_ext.WarningsPrebid)]
+		if warned {
+			// The warning is replaced, rather than adding another one for each bid.
+			prebidWarnings = prebidWarnings[:len(prebidWarnings)-1]
+		}
+		warnings[string(openrtb_ext.WarningsPrebid)] = append(prebidWarnings, pruningWarning(maxBytes, prunedDebug, prunedBids))
+		warned = true
+		if ext == nil {
+			ext = make(map[string]json.RawMessage, 1)
+		}
+		if ext["warnings"], err = json.Marshal(warnings); err != nil {
+			return err
+		}
+		if bidResponse.Ext, err = json.Marshal(ext); err != nil {
+			return err
+		}
+	}
+}
+
+// losingBids returns the bids which can be pruned, in the order they should be pruned.
+func losingBids(bidResponse *openrtb.BidResponse, kept map[responseBidKey]bool) []responseBidKey {
+	var losers []responseBidKey
+	prices := make(map[responseBidKey]float64)
+	for _, seatBid := range bidResponse.SeatBid {
+		for _, bid := range seatBid.Bid {
+			key := responseBidKey{seatBid.Seat, bid.ImpID, bid.ID}
+			if !kept[key] {
+				losers = append(losers, key)
+				prices[key] = bid.Price
+			}
+		}
+	}
+	sort.SliceStable(losers, func(i, j int) bool {
+		return prices[losers[i]] < prices[losers[j]]
+	})
+	return losers
+}
+
+// removeBid removes the bid from the response, along with its seatbid if that was its last bid.
+func removeBid(bidResponse *openrtb.BidResponse, key responseBidKey) {
+	for i := range bidResponse.SeatBid {
+		seatBid := &bidResponse.SeatBid[i]
+		if seatBid.Seat != key.seat {
+			continue
+		}
+		for j, bid := range seatBid.Bid {
+			if bid.ImpID == key.impID && bid.ID == key.bidID {
+				seatBid.Bid = append(seatBid.Bid[:j], seatBid.Bid[j+1:]...)
+				break
+			}
+		}
+		if len(seatBid.Bid) == 0 {
+			bidResponse.SeatBid = append(bidResponse.SeatBid[:i], bidResponse.SeatBid[i+1:]...)
+		}
+		return
+	}
+}
+
+func responseSize(bidResponse *openrtb.BidResponse) (int, error) {
+	data, err := json.Marshal(bidResponse)
+	return len(data), err
+}
+
+func pruningWarning(maxBytes int, prunedDebug bool, prunedBids int) string {
+	switch {
+	case prunedDebug && prunedBids > 0:
+		return fmt.Sprintf("The response was larger than %d bytes, so response.ext.debug and %d losing bids were removed.", maxBytes, prunedBids)
+	case prunedDebug:
+		return fmt.Sprintf("The response was larger than %d bytes, so response.ext.debug was removed.", maxBytes)
+	default:
+		return fmt.Sprintf("The response was larger than %d bytes, so %d losing bids were removed.", maxBytes, prunedBids)
+	}
+}
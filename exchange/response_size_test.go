@@ -0,0 +1,132 @@
+package exchange
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestKeptBids(t *testing.T) {
+	winner := &pbsOrtbBid{bid: &openrtb.Bid{ID: "winner", ImpID: "imp-1", Price: 5}}
+	targeted := &pbsOrtbBid{bid: &openrtb.Bid{ID: "targeted", ImpID: "imp-1", Price: 3}, bidTargets: map[string]string{"hb_pb_rubicon": "3.00"}}
+	loser := &pbsOrtbBid{bid: &openrtb.Bid{ID: "loser", ImpID: "imp-1", Price: 1}}
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {bids: []*pbsOrtbBid{winner, loser}},
+		openrtb_ext.BidderRubicon:  {bids: []*pbsOrtbBid{targeted}},
+	}
+	auc := &auction{winningBids: map[string]*pbsOrtbBid{"imp-1": winner}}
+
+	kept := keptBids(auc, adapterBids)
+	if len(kept) != 2 || !kept[responseBidKey{"appnexus", "imp-1", "winner"}] || !kept[responseBidKey{"rubicon", "imp-1", "targeted"}] {
+		t.Errorf("The winning bid and the bids with targeting should be kept. Got %v", kept)
+	}
+}
+
+func TestPruneResponse(t *testing.T) {
+	kept := map[responseBidKey]bool{{"appnexus", "imp-1", "winner"}: true}
+
+	bidResponse := newPrunableResponse()
+	if err := pruneResponse(bidResponse, 1000000, kept); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if countResponseBids(bidResponse) != 4 || !strings.Contains(string(bidResponse.Ext), `"debug":`) || strings.Contains(string(bidResponse.Ext), "warnings") {
+		t.Errorf("Responses which fit shouldn't be changed. Got %s", string(bidResponse.Ext))
+	}
+
+	bidResponse = newPrunableResponse()
+	maxBytes := sizeWithoutDebug(t) + 200
+	if err := pruneResponse(bidResponse, maxBytes, kept); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if countResponseBids(bidResponse) != 4 || strings.Contains(string(bidResponse.Ext), `"debug":`) {
+		t.Errorf("Only response.ext.debug should be removed. Got %d bids and ext %s", countResponseBids(bidResponse), string(bidResponse.Ext))
+	}
+	assertPruningWarning(t, bidResponse, "response.ext.debug was removed")
+	assertResponseSize(t, bidResponse, maxBytes)
+
+	bidResponse = newPrunableResponse()
+	if err := pruneResponse(bidResponse, 10, kept); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if countResponseBids(bidResponse) != 1 || len(bidResponse.SeatBid) != 1 || bidResponse.SeatBid[0].Bid[0].ID != "winner" {
+		t.Errorf("Only the kept bids should be left when they don't fit on their own. Got %v", bidResponse.SeatBid)
+	}
+	assertPruningWarning(t, bidResponse, "response.ext.debug and 3 losing bids were removed")
+}
+
+func TestPruneResponseOrder(t *testing.T) {
+	bidResponse := newPrunableResponse()
+	losers := losingBids(bidResponse, map[responseBidKey]bool{{"appnexus", "imp-1", "winner"}: true})
+	expected := []string{"cheapest", "cheap", "expensive"}
+	if len(losers) != len(expected) {
+		t.Fatalf("Expected %d losing bids. Got %v", len(expected), losers)
+	}
+	for i, bidID := range expected {
+		if losers[i].bidID != bidID {
+			t.Errorf("The losing bids should be pruned from the lowest price up. Expected %s at %d, got %s", bidID, i, losers[i].bidID)
+		}
+	}
+}
+
+func newPrunableResponse() *openrtb.BidResponse {
+	return &openrtb.BidResponse{
+		ID: "some-request-id",
+		SeatBid: []openrtb.SeatBid{{
+			Seat: "appnexus",
+			Bid: []openrtb.Bid{
+				{ID: "winner", ImpID: "imp-1", Price: 5, AdM: strings.Repeat("a", 100)},
+				{ID: "cheap", ImpID: "imp-1", Price: 2, AdM: strings.Repeat("b", 100)},
+			},
+		}, {
+			Seat: "rubicon",
+			Bid: []openrtb.Bid{
+				{ID: "expensive", ImpID: "imp-1", Price: 4, AdM: strings.Repeat("c", 100)},
+				{ID: "cheapest", ImpID: "imp-1", Price: 1, AdM: strings.Repeat("d", 100)},
+			},
+		}},
+		Ext: openrtb.RawJSON(`{"debug":{"httpcalls":{"appnexus":[{"uri":"` + strings.Repeat("e", 1000) + `"}]}},"responsetimemillis":{"appnexus":5}}`),
+	}
+}
+
+func sizeWithoutDebug(t *testing.T) int {
+	bidResponse := newPrunableResponse()
+	bidResponse.Ext = openrtb.RawJSON(`{"responsetimemillis":{"appnexus":5}}`)
+	size, err := responseSize(bidResponse)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return size
+}
+
+func assertPruningWarning(t *testing.T, bidResponse *openrtb.BidResponse, expected string) {
+	t.Helper()
+	var ext openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(bidResponse.Ext, &ext); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	warnings := ext.Warnings[openrtb_ext.WarningsPrebid]
+	if len(warnings) != 1 || !strings.Contains(warnings[0], expected) {
+		t.Errorf("Expected one warning that %s. Got %v", expected, warnings)
+	}
+	if ext.ResponseTimeMillis[openrtb_ext.BidderAppnexus] != 5 {
+		t.Errorf("The rest of the response ext should be kept. Got %s", string(bidResponse.Ext))
+	}
+}
+
+func assertResponseSize(t *testing.T, bidResponse *openrtb.BidResponse, maxBytes int) {
+	t.Helper()
+	if size, _ := responseSize(bidResponse); size > maxBytes {
+		t.Errorf("The response should be no bigger than %d bytes. Got %d", maxBytes, size)
+	}
+}
+
+func countResponseBids(bidResponse *openrtb.BidResponse) int {
+	count := 0
+	for _, seatBid := range bidResponse.SeatBid {
+		count += len(seatBid.Bid)
+	}
+	return count
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/creativescan"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// scanCreatives has the scanner check the bids' creatives. The ones it rejects are removed, with an error each,
+// and the ones it marks are kept, with a warning and bid.ext.prebid.scan. If the scan fails, every bid is rejected
+// when the scanner fails closed. Otherwise they're all kept, with a warning.
+func (brw *bidResponseWrapper) scanCreatives(ctx context.Context, scanner *creativescan.Scanner) (rejected []error, warnings []error) {
+	if scanner == nil || brw.adapterBids == nil || len(brw.adapterBids.bids) == 0 {
+		return
+	}
+	creatives := make([]creativescan.Creative, len(brw.adapterBids.bids))
+	for i, bid := range brw.adapterBids.bids {
+		creatives[i] = creativescan.Creative{
+			BidID:   bid.bid.ID,
+			ImpID:   bid.bid.ImpID,
+			Bidder:  string(brw.bidder),
+			CrID:    bid.bid.CrID,
+			AdM:     bid.bid.AdM,
+			NURL:    bid.bid.NURL,
+			ADomain: bid.bid.ADomain,
+		}
+	}
+	results, err := scanner.Scan(ctx, creatives)
+	if err != nil {
+		if !scanner.FailClosed() {
+			warnings = append(warnings, adapters.Warning(fmt.Sprintf("The creatives weren't scanned: %v", err)))
+			return
+		}
+		for _, bid := range brw.adapterBids.bids {
+			rejected = append(rejected, fmt.Errorf("Bid \"%s\" rejected (%s): its creative couldn't be scanned: %v", bid.bid.ID, pbsmetrics.AdapterBidRejectedCreativeScan, err))
+		}
+		brw.adapterBids.bids = nil
+		return
+	}
+
+	keptBids := make([]*pbsOrtbBid, 0, len(brw.adapterBids.bids))
+	for _, bid := range brw.adapterBids.bids {
+		result, ok := results[bid.bid.ID]
+		switch {
+		case ok && result.Verdict == creativescan.VerdictReject:
+			rejected = append(rejected, fmt.Errorf("Bid \"%s\" rejected (%s): the creative scanning service rejected it%s", bid.bid.ID, pbsmetrics.AdapterBidRejectedCreativeScan, scanReason(result)))
+			continue
+		case ok && result.Verdict == creativescan.VerdictMark:
+			bid.scan = &openrtb_ext.ExtBidPrebidScan{Verdict: string(result.Verdict), Reason: result.Reason}
+			warnings = append(warnings, adapters.Warning(fmt.Sprintf("Bid \"%s\" was marked by the creative scanning service%s", bid.bid.ID, scanReason(result))))
+		}
+		keptBids = append(keptBids, bid)
+	}
+	if len(rejected) > 0 {
+		brw.adapterBids.bids = keptBids
+	}
+	return
+}
+
+func scanReason(result creativescan.Result) string {
+	if result.Reason == "" {
+		return ""
+	}
+	return ": " + result.Reason
+}
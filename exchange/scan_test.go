@@ -0,0 +1,68 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/creativescan"
+)
+
+func TestScanCreatives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[{"bidid":"malicious","verdict":"reject","reason":"malvertising"},{"bidid":"suspicious","verdict":"mark","reason":"auto-redirect"}]}`))
+	}))
+	defer server.Close()
+	scanner := creativescan.NewScanner(&config.CreativeScanning{Endpoint: server.URL, TimeoutMillis: 1000}, server.Client())
+
+	brw := &bidResponseWrapper{
+		bidder: "appnexus",
+		adapterBids: &pbsOrtbSeatBid{bids: []*pbsOrtbBid{
+			{bid: &openrtb.Bid{ID: "malicious", AdM: "<script></script>"}},
+			{bid: &openrtb.Bid{ID: "suspicious"}},
+			{bid: &openrtb.Bid{ID: "clean"}},
+		}},
+	}
+	rejected, warnings := brw.scanCreatives(context.Background(), scanner)
+	if len(rejected) != 1 || !strings.Contains(rejected[0].Error(), "creative_scan") || !strings.Contains(rejected[0].Error(), "malvertising") {
+		t.Errorf("The rejected bid should have an error with the rejection code and reason. Got %v", rejected)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("The marked bid should have a warning. Got %v", warnings)
+	}
+	bids := brw.adapterBids.bids
+	if len(bids) != 2 || bids[0].bid.ID != "suspicious" || bids[1].bid.ID != "clean" {
+		t.Fatalf("The marked and clean bids should be kept. Got %v", bids)
+	}
+	if scan := bids[0].scan; scan == nil || scan.Verdict != "mark" || scan.Reason != "auto-redirect" {
+		t.Errorf("The marked bid should carry the verdict. Got %v", scan)
+	}
+	if bids[1].scan != nil {
+		t.Errorf("Clean bids shouldn't be marked. Got %v", bids[1].scan)
+	}
+}
+
+func TestScanCreativesFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	for _, failClosed := range []bool{false, true} {
+		scanner := creativescan.NewScanner(&config.CreativeScanning{Endpoint: server.URL, TimeoutMillis: 1000, FailClosed: failClosed}, server.Client())
+		brw := &bidResponseWrapper{
+			adapterBids: &pbsOrtbSeatBid{bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ID: "bid-1"}}, {bid: &openrtb.Bid{ID: "bid-2"}}}},
+		}
+		rejected, warnings := brw.scanCreatives(context.Background(), scanner)
+		if failClosed && (len(rejected) != 2 || len(brw.adapterBids.bids) != 0) {
+			t.Errorf("Scanners which fail closed should reject every bid. Got %v", rejected)
+		}
+		if !failClosed && (len(warnings) != 1 || len(rejected) != 0 || len(brw.adapterBids.bids) != 2) {
+			t.Errorf("Scanners which fail open should keep every bid, with a warning. Got %v and %v", rejected, warnings)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+// Snapshot is a whole auction, which can be replayed through a later build to see if its response changes.
+// The user's personal data is scrubbed before it's written. See config.Snapshots.
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// Request is the request as it reached the exchange, before the auction changed it. The user's and device's IDs,
+	// demographics and precise geo are removed, and the IP addresses are masked.
+	Request json.RawMessage `json:"request"`
+	// UserIDs are the hashes of the bidders' IDs for the user, from the uids cookie. They're only kept so that the
+	// replay has the same bidders synced.
+	UserIDs map[openrtb_ext.BidderName]string `json:"userids,omitempty"`
+	Labels  pbsmetrics.Labels                 `json:"labels"`
+	// Calls are the HTTP calls which the bidders made, in the order they finished. Calls which were still running
+	// when the auction ended aren't included. Their URIs and bodies have had the personal data redacted, and bodies
+	// which aren't JSON are left out.
+	Calls []SnapshotCall `json:"calls"`
+	// Response is the auction's response, with its winners, targeting, errors and warnings. The personal data in its
+	// debugging info is redacted. It's nil if the auction failed, in which case Error says why.
+	Response *openrtb.BidResponse `json:"response,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// SnapshotCall is one of the HTTP calls which a bidder made during a Snapshot's auction.
+type SnapshotCall struct {
+	Bidder       string `json:"bidder"`
+	Method       string `json:"method"`
+	Uri          string `json:"uri"`
+	RequestBody  string `json:"requestbody"`
+	Status       int    `json:"status,omitempty"`
+	ResponseBody string `json:"responsebody,omitempty"`
+	// Error is set if the call failed without a response, such as when it timed out.
+	Error string `json:"error,omitempty"`
+}
+
+// SnapshotExchange is an Exchange which writes a sample of its auctions to disk, as Snapshots.
+type SnapshotExchange struct {
+	exchange   Exchange
+	dir        string
+	sampleRate float64
+	maxFiles   int64
+	// taken counts the snapshots which were started. It's only changed atomically.
+	taken  int64
+	sample func() float64
+}
+
+// NewSnapshotExchange returns an Exchange which holds its auctions with ex, and writes some of them to the
+// directory in cfg. The directory is created if it doesn't exist.
+func NewSnapshotExchange(ex Exchange, cfg config.Snapshots) (*SnapshotExchange, error) {
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, err
+	}
+	return &SnapshotExchange{
+		exchange:   ex,
+		dir:        cfg.Dir,
+		sampleRate: cfg.SampleRate,
+		maxFiles:   int64(cfg.MaxFiles),
+		sample:     rand.Float64,
+	}, nil
+}
+
+func (e *SnapshotExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	if e.sample() >= e.sampleRate || atomic.LoadInt64(&e.taken) >= e.maxFiles {
+		return e.exchange.HoldAuction(ctx, bidRequest, usersyncs, labels)
+	}
+	// The request is copied first, since the auction may change it.
+	requestJSON, err := json.Marshal(scrubbedRequest(bidRequest))
+	if err != nil {
+		return e.exchange.HoldAuction(ctx, bidRequest, usersyncs, labels)
+	}
+	number := atomic.AddInt64(&e.taken, 1)
+	if number > e.maxFiles {
+		return e.exchange.HoldAuction(ctx, bidRequest, usersyncs, labels)
+	}
+
+	snapshot := &Snapshot{
+		Time:    time.Now(),
+		Request: requestJSON,
+		UserIDs: hashedUserIDs(usersyncs),
+		Labels:  labels,
+	}
+	calls := &snapshotCalls{}
+	response, err := e.exchange.HoldAuction(context.WithValue(ctx, snapshotCallsKey{}, calls), bidRequest, usersyncs, labels)
+	snapshot.Calls = calls.close()
+	snapshot.Response = scrubbedResponse(response)
+	if err != nil {
+		snapshot.Error = err.Error()
+	}
+	// The endpoints may change the response once it's returned, so it's encoded now, and only written in the background.
+	snapshotJSON, jsonErr := json.Marshal(snapshot)
+	if jsonErr != nil {
+		logger.Warningf("Failed to encode an auction snapshot: %v", jsonErr)
+		return response, err
+	}
+	go e.write(fmt.Sprintf("%s-%d.json", snapshot.Time.UTC().Format("20060102T150405Z"), number), snapshotJSON)
+	return response, err
+}
+
+func (e *SnapshotExchange) write(name string, snapshotJSON []byte) {
+	if err := ioutil.WriteFile(filepath.Join(e.dir, name), snapshotJSON, 0600); err != nil {
+		logger.Warningf("Failed to write the auction snapshot %s: %v", name, err)
+	}
+}
+
+// scrubbedRequest returns a copy of the request without the user's and device's IDs, demographics or precise geo,
+// and with its IP addresses masked.
+func scrubbedRequest(bidRequest *openrtb.BidRequest) *openrtb.BidRequest {
+	scrubbed := *bidRequest
+	scrubbed.Device = privacy.ScrubDeviceGeo(privacy.ScrubDeviceIDs(bidRequest.Device))
+	scrubbed.User = privacy.ScrubUserGeo(privacy.ScrubUserIDsAndDemographics(bidRequest.User))
+	return &scrubbed
+}
+
+// scrubbedResponse returns a copy of the response with the personal data in its debugging info redacted.
+// The response itself is left alone, since it's still returned to the caller.
+func scrubbedResponse(response *openrtb.BidResponse) *openrtb.BidResponse {
+	if response == nil || len(response.Ext) == 0 {
+		return response
+	}
+	scrubbed := *response
+	scrubbed.Ext = redactJSON(response.Ext)
+	// The bidders' calls hold their URIs and bodies as strings, which redactJSON can't see into.
+	var ext struct {
+		Debug *struct {
+			HttpCalls map[string][]*openrtb_ext.ExtHttpCall `json:"httpcalls"`
+		} `json:"debug"`
+	}
+	if err := json.Unmarshal(scrubbed.Ext, &ext); err != nil || ext.Debug == nil || len(ext.Debug.HttpCalls) == 0 {
+		return &scrubbed
+	}
+	for bidder, calls := range ext.Debug.HttpCalls {
+		ext.Debug.HttpCalls[bidder] = redactHttpCalls(calls)
+	}
+	callsJSON, err := json.Marshal(ext.Debug.HttpCalls)
+	if err == nil {
+		scrubbed.Ext, err = jsonparser.Set(scrubbed.Ext, callsJSON, "debug", "httpcalls")
+	}
+	if err != nil {
+		// Better to lose the debugging info than to write the personal data.
+		scrubbed.Ext = jsonparser.Delete(scrubbed.Ext, "debug")
+	}
+	return &scrubbed
+}
+
+// hashedUserIDs returns the hashes of the IDs which the usersyncs have for each bidder.
+func hashedUserIDs(usersyncs IdFetcher) map[openrtb_ext.BidderName]string {
+	if usersyncs == nil {
+		return nil
+	}
+	ids := make(map[openrtb_ext.BidderName]string)
+	for _, bidder := range openrtb_ext.BidderMap {
+		if id, ok := usersyncs.GetId(bidder); ok {
+			ids[bidder] = privacy.HashID(id)
+		}
+	}
+	return ids
+}
+
+// snapshotCalls collects the bidders' HTTP calls for a Snapshot. Calls which finish after it's closed are left out,
+// since the auction's response was made without them.
+type snapshotCalls struct {
+	lock   sync.Mutex
+	calls  []SnapshotCall
+	closed bool
+}
+
+type snapshotCallsKey struct{}
+
+// snapshotCallsFrom returns the context's snapshotCalls, or nil if its auction isn't being snapshotted.
+func snapshotCallsFrom(ctx context.Context) *snapshotCalls {
+	calls, _ := ctx.Value(snapshotCallsKey{}).(*snapshotCalls)
+	return calls
+}
+
+// add records the bidder's call, with the personal data in its URI and bodies redacted, as they are for redacted
+// debugging info. If s is nil, it does nothing.
+func (s *snapshotCalls) add(bidder openrtb_ext.BidderName, info *httpCallInfo) {
+	if s == nil {
+		return
+	}
+	call := SnapshotCall{
+		Bidder:      string(bidder),
+		Method:      info.request.Method,
+		Uri:         redactURI(info.request.Uri),
+		RequestBody: string(redactJSON(info.request.Body)),
+	}
+	if info.response != nil {
+		call.Status = info.response.StatusCode
+		call.ResponseBody = string(redactJSON(info.response.Body))
+	} else if info.err != nil {
+		call.Error = info.err.Error()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.calls = append(s.calls, call)
+	}
+}
+
+func (s *snapshotCalls) close() []SnapshotCall {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return s.calls
+}
+
+// ReplayResult compares the response which a Snapshot recorded with the one which the auction gets now.
+type ReplayResult struct {
+	Recorded *openrtb.BidResponse `json:"recorded,omitempty"`
+	Replayed *openrtb.BidResponse `json:"replayed,omitempty"`
+	// Error is set if the replayed auction failed.
+	Error string `json:"error,omitempty"`
+	// Differences are the JSON paths where the responses differ. The seatbids and bids are sorted before they're
+	// compared, and ext.responsetimemillis is left out, since it's never the same twice.
+	Differences []string `json:"differences"`
+	// UnmatchedCalls are the calls which the bidders made during the replay, but which aren't in the Snapshot.
+	// They failed with an error, rather than being sent to the bidders.
+	UnmatchedCalls []string `json:"unmatchedcalls,omitempty"`
+}
+
+// Replay runs the Snapshot's request through ex again. The bidders get the responses which they got in the Snapshot,
+// so the only differences should come from changes to the code or the config. Nothing is sent to the bidders.
+// ex should come from NewReplayExchange, so that the replay doesn't reach anything else outside the auction either.
+func Replay(ctx context.Context, ex Exchange, snapshot *Snapshot) (*ReplayResult, error) {
+	var bidRequest openrtb.BidRequest
+	if err := json.Unmarshal(snapshot.Request, &bidRequest); err != nil {
+		return nil, fmt.Errorf("The snapshot's request isn't valid OpenRTB: %v", err)
+	}
+	replay := &replayer{calls: snapshot.Calls, used: make([]bool, len(snapshot.Calls))}
+	response, err := ex.HoldAuction(context.WithValue(ctx, replayerKey{}, replay), &bidRequest, snapshotIDs(snapshot.UserIDs), snapshot.Labels)
+
+	result := &ReplayResult{
+		Recorded:       snapshot.Response,
+		Replayed:       response,
+		UnmatchedCalls: replay.unmatchedCalls(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.Differences, err = responseDifferences(snapshot.Response, response); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// NewReplayExchange returns an Exchange for replaying Snapshots. It's cut off from everything outside the auction:
+// it records no metrics, caches nothing, and skips the geo, real-time data, ads.txt and creative scanning enrichers,
+// which would call out to other services or add data which the Snapshot's auction didn't have.
+func NewReplayExchange(client *http.Client, cfg *config.Configuration, gDPR gdpr.Permissions, currencyConverter *currencies.RateConverter, categories stored_requests.CategoryFetcher) Exchange {
+	return NewExchange(client, noCache{}, cfg, &metricsConf.DummyMetricsEngine{}, gDPR, currencyConverter, categories, nil, nil)
+}
+
+// noCache is the cache for replays. It doesn't save anything, so the replayed bids never have cache IDs.
+type noCache struct{}
+
+func (c noCache) PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) []string {
+	return make([]string, len(values))
+}
+
+// snapshotIDs is the IdFetcher for a replay, which has the Snapshot's user IDs.
+type snapshotIDs map[openrtb_ext.BidderName]string
+
+func (ids snapshotIDs) GetId(bidder openrtb_ext.BidderName) (string, bool) {
+	id, ok := ids[bidder]
+	return id, ok
+}
+
+// replayer answers the bidders' calls during a replay from the Snapshot's calls. Each recorded call answers one call.
+type replayer struct {
+	lock      sync.Mutex
+	calls     []SnapshotCall
+	used      []bool
+	unmatched []string
+}
+
+type replayerKey struct{}
+
+// replayerFrom returns the context's replayer, or nil if its auction isn't a replay.
+func replayerFrom(ctx context.Context) *replayer {
+	replay, _ := ctx.Value(replayerKey{}).(*replayer)
+	return replay
+}
+
+// do returns the recorded call which matches req. A call with the same method, URI and body is preferred. Otherwise,
+// it's the first unused call to the same method and URI, since bodies often change from one auction to the next,
+// such as when the bidder puts the time in them. The URI and body are redacted first, as the recorded ones were.
+func (r *replayer) do(req *adapters.RequestData) *httpCallInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	uri := redactURI(req.Uri)
+	body := string(redactJSON(req.Body))
+	match := -1
+	for i, call := range r.calls {
+		if r.used[i] || call.Method != req.Method || call.Uri != uri {
+			continue
+		}
+		if call.RequestBody == body {
+			match = i
+			break
+		}
+		if match == -1 {
+			match = i
+		}
+	}
+	if match == -1 {
+		r.unmatched = append(r.unmatched, req.Method+" "+req.Uri)
+		return &httpCallInfo{
+			request: req,
+			err:     fmt.Errorf("The call to %s isn't in the snapshot, so it wasn't replayed.", req.Uri),
+		}
+	}
+	r.used[match] = true
+	call := r.calls[match]
+	if call.Status == 0 {
+		return &httpCallInfo{
+			request: req,
+			err:     errors.New(call.Error),
+		}
+	}
+	return &httpCallInfo{
+		request: req,
+		response: &adapters.ResponseData{
+			StatusCode: call.Status,
+			Body:       []byte(call.ResponseBody),
+		},
+		err: statusError(call.Status),
+	}
+}
+
+func (r *replayer) unmatchedCalls() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.unmatched
+}
+
+// responseDifferences returns the JSON paths where the two responses differ.
+func responseDifferences(recorded *openrtb.BidResponse, replayed *openrtb.BidResponse) ([]string, error) {
+	recordedJSON, err := comparableResponse(recorded)
+	if err != nil {
+		return nil, err
+	}
+	replayedJSON, err := comparableResponse(replayed)
+	if err != nil {
+		return nil, err
+	}
+	return jsonDifferences("", recordedJSON, replayedJSON, []string{}), nil
+}
+
+// comparableResponse decodes the response into generic JSON values, with its seatbids sorted by seat and its bids
+// sorted by ID. The exchange builds the seatbids from a map, so their order is random.
+func comparableResponse(response *openrtb.BidResponse) (interface{}, error) {
+	if response == nil {
+		return nil, nil
+	}
+	sorted := *response
+	sorted.SeatBid = make([]openrtb.SeatBid, len(response.SeatBid))
+	for i, seatBid := range response.SeatBid {
+		sorted.SeatBid[i] = seatBid
+		sorted.SeatBid[i].Bid = append([]openrtb.Bid(nil), seatBid.Bid...)
+		sort.SliceStable(sorted.SeatBid[i].Bid, func(a, b int) bool { return sorted.SeatBid[i].Bid[a].ID < sorted.SeatBid[i].Bid[b].ID })
+	}
+	sort.SliceStable(sorted.SeatBid, func(a, b int) bool { return sorted.SeatBid[a].Seat < sorted.SeatBid[b].Seat })
+
+	responseJSON, err := json.Marshal(&sorted)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(responseJSON, &value); err != nil {
+		return nil, err
+	}
+	if ext, ok := value.(map[string]interface{})["ext"].(map[string]interface{}); ok {
+		delete(ext, "responsetimemillis")
+	}
+	return value, nil
+}
+
+// jsonDifferences adds the paths under path where the values differ to diffs, and returns them.
+func jsonDifferences(path string, a interface{}, b interface{}, diffs []string) []string {
+	switch aValue := a.(type) {
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok {
+			return append(diffs, rootPath(path))
+		}
+		keys := make([]string, 0, len(aValue)+len(bValue))
+		for key := range aValue {
+			keys = append(keys, key)
+		}
+		for key := range bValue {
+			if _, ok := aValue[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			diffs = jsonDifferences(keyPath, aValue[key], bValue[key], diffs)
+		}
+		return diffs
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok {
+			return append(diffs, rootPath(path))
+		}
+		for i := 0; i < len(aValue) || i < len(bValue); i++ {
+			var aElement, bElement interface{}
+			if i < len(aValue) {
+				aElement = aValue[i]
+			}
+			if i < len(bValue) {
+				bElement = bValue[i]
+			}
+			diffs = jsonDifferences(fmt.Sprintf("%s[%d]", path, i), aElement, bElement, diffs)
+		}
+		return diffs
+	}
+	if a != b {
+		return append(diffs, rootPath(path))
+	}
+	return diffs
+}
+
+// rootPath names the top of the response, which has an empty path.
+func rootPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
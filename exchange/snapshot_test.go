@@ -0,0 +1,287 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+func TestSnapshotExchange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatalf("Failed to make the snapshots directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ex, err := NewSnapshotExchange(&replayingExchange{}, config.Snapshots{Enabled: true, Dir: dir, SampleRate: 0.5, MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("Failed to make the exchange: %v", err)
+	}
+	ex.sample = func() float64 { return 0.7 }
+	ex.HoldAuction(context.Background(), snapshotRequest(), mockIdFetcher{"appnexus": "appnexus-id"}, pbsmetrics.Labels{PubID: "publisher"})
+	if files := snapshotFiles(t, dir, 0); len(files) != 0 {
+		t.Errorf("Auctions outside the sample shouldn't be written. Got %v", files)
+	}
+
+	ex.sample = func() float64 { return 0.2 }
+	bidRequest := snapshotRequest()
+	ex.HoldAuction(context.Background(), bidRequest, mockIdFetcher{"appnexus": "appnexus-id"}, pbsmetrics.Labels{PubID: "publisher"})
+	ex.HoldAuction(context.Background(), snapshotRequest(), nil, pbsmetrics.Labels{})
+	files := snapshotFiles(t, dir, 1)
+	if len(files) != 1 {
+		t.Fatalf("Only max_files snapshots should be written. Got %v", files)
+	}
+
+	snapshotJSON, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("Failed to read the snapshot: %v", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(snapshotJSON, &snapshot); err != nil {
+		t.Fatalf("The snapshot should be JSON: %v", err)
+	}
+	var recordedRequest openrtb.BidRequest
+	if err := json.Unmarshal(snapshot.Request, &recordedRequest); err != nil || recordedRequest.ID != "req" || recordedRequest.Imp[0].BidFloor != 0 {
+		t.Errorf("The snapshot should have the request from before the auction changed it. Got %s", snapshot.Request)
+	}
+	if recordedRequest.Device.IFA != "" || recordedRequest.Device.IP != "198.51.100.0" || recordedRequest.User.ID != "" || recordedRequest.User.Yob != 0 {
+		t.Errorf("The snapshot's request should have the user's personal data scrubbed. Got %s", snapshot.Request)
+	}
+	if bidRequest.Device.IFA != "device-id" || bidRequest.User.ID != "user-id" {
+		t.Errorf("The auction's request shouldn't be scrubbed. Got %v and %v", bidRequest.Device, bidRequest.User)
+	}
+	if snapshot.UserIDs["appnexus"] != privacy.HashID("appnexus-id") || snapshot.Labels.PubID != "publisher" {
+		t.Errorf("The snapshot should have the hashed user IDs and the labels. Got %v and %v", snapshot.UserIDs, snapshot.Labels)
+	}
+	if len(snapshot.Calls) != 1 || snapshot.Calls[0].Bidder != "appnexus" || snapshot.Calls[0].ResponseBody != `"live-bid"` {
+		t.Errorf("The snapshot should have the bidder's call. Got %v", snapshot.Calls)
+	}
+	if snapshot.Response == nil || snapshot.Response.SeatBid[0].Bid[0].AdM != `"live-bid"` {
+		t.Errorf("The snapshot should have the auction's response. Got %v", snapshot.Response)
+	}
+}
+
+func TestSnapshotCallsRedacted(t *testing.T) {
+	calls := &snapshotCalls{}
+	calls.add("appnexus", &httpCallInfo{
+		request:  &adapters.RequestData{Method: "POST", Uri: "http://bidder.com?uid=user-id&p=1", Body: []byte(`{"user":{"id":"user-id","buyeruid":"buyer-id"}}`)},
+		response: &adapters.ResponseData{StatusCode: 200, Body: []byte(`{"seatbid":[]}`)},
+	})
+	calls.add("rubicon", &httpCallInfo{
+		request:  &adapters.RequestData{Method: "GET", Uri: "http://other.com?ip=198.51.100.7"},
+		response: &adapters.ResponseData{StatusCode: 200, Body: []byte(`<VAST><Impression>http://t.com?ip=198.51.100.7</Impression></VAST>`)},
+	})
+	recorded := calls.close()
+	if len(recorded) != 2 || strings.Contains(recorded[0].RequestBody, "user-id") || strings.Contains(recorded[0].RequestBody, "buyer-id") {
+		t.Errorf("The personal data in the calls should be redacted. Got %v", recorded)
+	}
+	if recorded[0].Uri != "http://bidder.com?uid=%5BREDACTED%5D&p=1" || recorded[1].Uri != "http://other.com?ip=%5BREDACTED%5D" {
+		t.Errorf("The personal data in the URIs should be redacted. Got %s and %s", recorded[0].Uri, recorded[1].Uri)
+	}
+	if recorded[0].ResponseBody != `{"seatbid":[]}` {
+		t.Errorf("Bodies without personal data should be kept as they are. Got %s", recorded[0].ResponseBody)
+	}
+	if recorded[1].ResponseBody != "" {
+		t.Errorf("Bodies which aren't JSON should be left out. Got %s", recorded[1].ResponseBody)
+	}
+}
+
+func TestScrubbedResponse(t *testing.T) {
+	response := &openrtb.BidResponse{
+		ID:  "req",
+		Ext: openrtb.RawJSON(`{"debug":{"httpcalls":{"appnexus":[{"uri":"http://bidder.com?ifa=device-id","requestbody":"{\"device\":{\"ip\":\"198.51.100.7\"}}","responsebody":"not json","status":200}]},"resolvedrequest":{"user":{"id":"user-id"}}},"responsetimemillis":{"appnexus":20}}`),
+	}
+	scrubbed := scrubbedResponse(response)
+	for _, personal := range []string{"device-id", "198.51.100.7", "user-id", "not json"} {
+		if strings.Contains(string(scrubbed.Ext), personal) {
+			t.Errorf("The snapshot's response shouldn't contain %s. Got %s", personal, scrubbed.Ext)
+		}
+	}
+	if millis, err := jsonparser.GetInt(scrubbed.Ext, "responsetimemillis", "appnexus"); err != nil || millis != 20 {
+		t.Errorf("The rest of the ext should be kept. Got %s", scrubbed.Ext)
+	}
+	if !strings.Contains(string(response.Ext), "device-id") {
+		t.Errorf("The auction's response shouldn't be scrubbed. Got %s", response.Ext)
+	}
+}
+
+func TestNewReplayExchange(t *testing.T) {
+	cfg := &config.Configuration{
+		AdsTxt:           config.AdsTxt{Enabled: true, RefreshHours: 1},
+		RTD:              config.RTD{Modules: []config.RTDModule{{Name: "weather", Endpoint: "http://rtd.com"}}},
+		CreativeScanning: config.CreativeScanning{Endpoint: "http://scanner.com"},
+	}
+	e := NewReplayExchange(http.DefaultClient, cfg, gdpr.NewPermissions(context.Background(), config.GDPR{}, nil, nil), nil, nil).(*exchange)
+	if e.geo != nil || e.rtd != nil || e.adsTxt != nil || e.scanner != nil {
+		t.Errorf("Replays shouldn't run the enrichers.")
+	}
+	if _, ok := e.me.(*metricsConf.DummyMetricsEngine); !ok {
+		t.Errorf("Replays shouldn't be counted in the metrics. Got %T", e.me)
+	}
+	if ids := e.cache.PutJson(context.Background(), []json.RawMessage{json.RawMessage(`{}`)}, nil); len(ids) != 1 || ids[0] != "" {
+		t.Errorf("Replays shouldn't cache their bids. Got %v", ids)
+	}
+	if len(cfg.RTD.Modules) != 1 || !cfg.AdsTxt.Enabled || cfg.CreativeScanning.Endpoint == "" {
+		t.Errorf("The host's config shouldn't be changed.")
+	}
+}
+
+func TestSnapshotCallsClosed(t *testing.T) {
+	calls := &snapshotCalls{}
+	info := &httpCallInfo{request: &adapters.RequestData{Method: "POST", Uri: "http://bidder.com"}, err: context.DeadlineExceeded}
+	calls.add("appnexus", info)
+	recorded := calls.close()
+	calls.add("rubicon", info)
+	if len(recorded) != 1 || recorded[0].Error != context.DeadlineExceeded.Error() || len(calls.calls) != 1 {
+		t.Errorf("Only the calls which finished before the snapshot closed should be kept. Got %v", calls.calls)
+	}
+	snapshotCallsFrom(context.Background()).add("appnexus", info)
+}
+
+func TestReplayer(t *testing.T) {
+	replay := &replayer{
+		calls: []SnapshotCall{
+			{Method: "POST", Uri: "http://bidder.com", RequestBody: `"first"`, Status: 200, ResponseBody: "first-response"},
+			{Method: "POST", Uri: "http://bidder.com", RequestBody: `"second"`, Status: 200, ResponseBody: "second-response"},
+			{Method: "POST", Uri: "http://failing.com", Status: 500},
+			{Method: "POST", Uri: "http://slow.com", Error: "context deadline exceeded"},
+			{Method: "GET", Uri: "http://sync.com?uid=%5BREDACTED%5D", Status: 200, ResponseBody: "{}"},
+		},
+		used: make([]bool, 5),
+	}
+	if info := replay.do(&adapters.RequestData{Method: "GET", Uri: "http://sync.com?uid=user-id"}); info.err != nil || string(info.response.Body) != "{}" {
+		t.Errorf("Calls should match the recorded ones once their URIs are redacted. Got %v", info)
+	}
+	if info := replay.do(&adapters.RequestData{Method: "POST", Uri: "http://bidder.com", Body: []byte(`"second"`)}); string(info.response.Body) != "second-response" {
+		t.Errorf("The call with the same body should be preferred. Got %s", info.response.Body)
+	}
+	if info := replay.do(&adapters.RequestData{Method: "POST", Uri: "http://bidder.com", Body: []byte(`"changed"`)}); string(info.response.Body) != "first-response" || info.err != nil {
+		t.Errorf("Calls whose bodies changed should get the next call to the same URI. Got %v", info)
+	}
+	if info := replay.do(&adapters.RequestData{Method: "POST", Uri: "http://failing.com"}); info.response.StatusCode != 500 || info.err == nil {
+		t.Errorf("Recorded failure statuses should be errors. Got %v", info)
+	}
+	if info := replay.do(&adapters.RequestData{Method: "POST", Uri: "http://slow.com"}); info.response != nil || info.err.Error() != "context deadline exceeded" {
+		t.Errorf("Recorded failures should return their error. Got %v", info)
+	}
+	if info := replay.do(&adapters.RequestData{Method: "POST", Uri: "http://bidder.com"}); info.err == nil {
+		t.Errorf("Each recorded call should only answer one call.")
+	}
+	if unmatched := replay.unmatchedCalls(); !reflect.DeepEqual(unmatched, []string{"POST http://bidder.com"}) {
+		t.Errorf("The calls which weren't in the snapshot should be listed. Got %v", unmatched)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	snapshot := &Snapshot{
+		Request: json.RawMessage(`{"id":"req","imp":[{"id":"imp"}]}`),
+		Calls:   []SnapshotCall{{Bidder: "appnexus", Method: "POST", Uri: "http://bidder.com", RequestBody: "{}", Status: 200, ResponseBody: "recorded-bid"}},
+		Response: &openrtb.BidResponse{
+			ID:      "req",
+			SeatBid: []openrtb.SeatBid{{Seat: "appnexus", Bid: []openrtb.Bid{{ID: "bid", ImpID: "imp", Price: 2, AdM: "recorded-bid"}}}},
+		},
+	}
+	result, err := Replay(context.Background(), &replayingExchange{}, snapshot)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Replayed == nil || result.Replayed.SeatBid[0].Bid[0].AdM != "recorded-bid" {
+		t.Errorf("The bidder should get its recorded response. Got %v", result.Replayed)
+	}
+	if !reflect.DeepEqual(result.Differences, []string{"seatbid[0].bid[0].price"}) {
+		t.Errorf("Only the changed price should be different. Got %v", result.Differences)
+	}
+
+	snapshot.Request = json.RawMessage(`{"id":`)
+	if _, err := Replay(context.Background(), &replayingExchange{}, snapshot); err == nil {
+		t.Errorf("Snapshots with broken requests can't be replayed.")
+	}
+}
+
+func TestResponseDifferences(t *testing.T) {
+	recorded := &openrtb.BidResponse{
+		ID: "req",
+		SeatBid: []openrtb.SeatBid{
+			{Seat: "appnexus", Bid: []openrtb.Bid{{ID: "a1", Price: 1}, {ID: "a2", Price: 2}}},
+			{Seat: "rubicon", Bid: []openrtb.Bid{{ID: "r1", Price: 3}}},
+		},
+		Ext: openrtb.RawJSON(`{"responsetimemillis":{"appnexus":20}}`),
+	}
+	replayed := &openrtb.BidResponse{
+		ID: "req",
+		SeatBid: []openrtb.SeatBid{
+			{Seat: "rubicon", Bid: []openrtb.Bid{{ID: "r1", Price: 3}}},
+			{Seat: "appnexus", Bid: []openrtb.Bid{{ID: "a2", Price: 2}, {ID: "a1", Price: 1}}},
+		},
+		Ext: openrtb.RawJSON(`{"responsetimemillis":{"appnexus":35},"warnings":{"prebid":["new warning"]}}`),
+	}
+	diffs, err := responseDifferences(recorded, replayed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(diffs, []string{"ext.warnings"}) {
+		t.Errorf("The order of the seatbids and bids, and the response times, shouldn't count as differences. Got %v", diffs)
+	}
+	if diffs, _ := responseDifferences(recorded, nil); !reflect.DeepEqual(diffs, []string{"."}) {
+		t.Errorf("A missing response should differ at the top. Got %v", diffs)
+	}
+}
+
+// replayingExchange has appnexus make one call, and bid its response's body at a price of 1.
+// It changes the request, as the real exchange does.
+type replayingExchange struct{}
+
+func (e *replayingExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, usersyncs IdFetcher, labels pbsmetrics.Labels) (*openrtb.BidResponse, error) {
+	bidRequest.Imp[0].BidFloor = 1
+	req := &adapters.RequestData{Method: "POST", Uri: "http://bidder.com", Body: []byte("{}")}
+	info := &httpCallInfo{request: req, response: &adapters.ResponseData{StatusCode: 200, Body: []byte(`"live-bid"`)}}
+	if replay := replayerFrom(ctx); replay != nil {
+		info = replay.do(req)
+	}
+	snapshotCallsFrom(ctx).add(openrtb_ext.BidderAppnexus, info)
+	return &openrtb.BidResponse{
+		ID:      bidRequest.ID,
+		SeatBid: []openrtb.SeatBid{{Seat: "appnexus", Bid: []openrtb.Bid{{ID: "bid", ImpID: "imp", Price: 1, AdM: string(info.response.Body)}}}},
+	}, nil
+}
+
+func snapshotRequest() *openrtb.BidRequest {
+	return &openrtb.BidRequest{
+		ID:     "req",
+		Imp:    []openrtb.Imp{{ID: "imp"}},
+		Device: &openrtb.Device{IP: "198.51.100.7", IFA: "device-id"},
+		User:   &openrtb.User{ID: "user-id", Yob: 1980},
+	}
+}
+
+// snapshotFiles returns the files in dir, once there are at least count of them. The snapshots are written in the
+// background, so this waits for up to a second.
+func snapshotFiles(t *testing.T, dir string, count int) []os.FileInfo {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("Failed to read the snapshots directory: %v", err)
+		}
+		if len(files) >= count || time.Now().After(deadline) {
+			return files
+		}
+	}
+}
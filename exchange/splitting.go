@@ -0,0 +1,99 @@
+package exchange
+
+import (
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// splitRequest divides a request into the pieces which should be passed to a Bidder's MakeRequests.
+// The Imps are grouped according to the Bidder's RequestSplit, and then each group is divided into batches of at most
+// maxImps Imps, if maxImps is positive. Each piece is a shallow copy of the request with one batch of Imps.
+//
+// Requests which don't need to be split are returned as they are.
+func splitRequest(request *openrtb.BidRequest, split adapters.RequestSplit, maxImps int) []*openrtb.BidRequest {
+	if split == adapters.SplitNone && (maxImps <= 0 || len(request.Imp) <= maxImps) {
+		return []*openrtb.BidRequest{request}
+	}
+
+	var groups [][]openrtb.Imp
+	switch split {
+	case adapters.SplitPerImp:
+		groups = make([][]openrtb.Imp, len(request.Imp))
+		for i := range request.Imp {
+			groups[i] = request.Imp[i : i+1 : i+1]
+		}
+	case adapters.SplitPerMediaType:
+		groups = splitImpsByMediaType(request.Imp)
+	default:
+		groups = [][]openrtb.Imp{request.Imp}
+	}
+
+	pieces := make([]*openrtb.BidRequest, 0, len(groups))
+	for _, imps := range groups {
+		for _, batch := range batchImps(imps, maxImps) {
+			piece := *request
+			piece.Imp = batch
+			pieces = append(pieces, &piece)
+		}
+	}
+	return pieces
+}
+
+// batchImps divides the imps into batches of at most maxImps each. If maxImps isn't positive, there's one batch.
+// The batches are capped slices, so that a Bidder which appends Imps to one can't overwrite the next.
+func batchImps(imps []openrtb.Imp, maxImps int) [][]openrtb.Imp {
+	if maxImps <= 0 || len(imps) <= maxImps {
+		return [][]openrtb.Imp{imps}
+	}
+	batches := make([][]openrtb.Imp, 0, (len(imps)+maxImps-1)/maxImps)
+	for start := 0; start < len(imps); start += maxImps {
+		end := start + maxImps
+		if end > len(imps) {
+			end = len(imps)
+		}
+		batches = append(batches, imps[start:end:end])
+	}
+	return batches
+}
+
+// splitImpsByMediaType groups the imps by media type, in the order banner, video, audio, native.
+// An imp with several media types is copied into each of their groups, with only that group's media type set.
+func splitImpsByMediaType(imps []openrtb.Imp) [][]openrtb.Imp {
+	mediaTypes := []openrtb_ext.BidType{openrtb_ext.BidTypeBanner, openrtb_ext.BidTypeVideo, openrtb_ext.BidTypeAudio, openrtb_ext.BidTypeNative}
+	groups := make([][]openrtb.Imp, 0, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		var group []openrtb.Imp
+		for _, imp := range imps {
+			if single, ok := impWithOnly(imp, mediaType); ok {
+				group = append(group, single)
+			}
+		}
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// impWithOnly returns a copy of the imp with every media type except the given one removed.
+// It returns false if the imp doesn't use that media type.
+func impWithOnly(imp openrtb.Imp, mediaType openrtb_ext.BidType) (openrtb.Imp, bool) {
+	single := imp
+	single.Banner, single.Video, single.Audio, single.Native = nil, nil, nil, nil
+	switch mediaType {
+	case openrtb_ext.BidTypeBanner:
+		single.Banner = imp.Banner
+	case openrtb_ext.BidTypeVideo:
+		single.Video = imp.Video
+	case openrtb_ext.BidTypeAudio:
+		single.Audio = imp.Audio
+	case openrtb_ext.BidTypeNative:
+		single.Native = imp.Native
+	}
+	return single, hasAnyMediaType(&single)
+}
+
+func hasAnyMediaType(imp *openrtb.Imp) bool {
+	return imp.Banner != nil || imp.Video != nil || imp.Audio != nil || imp.Native != nil
+}
@@ -0,0 +1,82 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+)
+
+func TestSplitNone(t *testing.T) {
+	request := &openrtb.BidRequest{Imp: []openrtb.Imp{{ID: "imp-1"}, {ID: "imp-2"}}}
+	if pieces := splitRequest(request, adapters.SplitNone, 0); len(pieces) != 1 || pieces[0] != request {
+		t.Errorf("Requests which don't need to be split should be returned as they are. Got %v", pieces)
+	}
+}
+
+func TestSplitPerImp(t *testing.T) {
+	request := &openrtb.BidRequest{ID: "req-1", Imp: []openrtb.Imp{{ID: "imp-1"}, {ID: "imp-2"}}}
+	pieces := splitRequest(request, adapters.SplitPerImp, 0)
+	if len(pieces) != 2 {
+		t.Fatalf("There should be one piece per imp. Got %d", len(pieces))
+	}
+	for i, piece := range pieces {
+		if piece.ID != "req-1" || len(piece.Imp) != 1 || piece.Imp[0].ID != request.Imp[i].ID {
+			t.Errorf("Piece %d should only have imp %s. Got %#v", i, request.Imp[i].ID, piece)
+		}
+	}
+}
+
+func TestSplitPerMediaType(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "multi", Banner: &openrtb.Banner{}, Video: &openrtb.Video{}},
+			{ID: "native", Native: &openrtb.Native{}},
+			{ID: "banner", Banner: &openrtb.Banner{}},
+		},
+	}
+	pieces := splitRequest(request, adapters.SplitPerMediaType, 0)
+	if len(pieces) != 3 {
+		t.Fatalf("There should be one piece per media type. Got %d", len(pieces))
+	}
+	assertImpIDs(t, pieces[0], "multi", "banner")
+	assertImpIDs(t, pieces[1], "multi")
+	assertImpIDs(t, pieces[2], "native")
+	if pieces[0].Imp[0].Video != nil || pieces[1].Imp[0].Banner != nil {
+		t.Errorf("Each piece should only have its own media type.")
+	}
+	if request.Imp[0].Banner == nil || request.Imp[0].Video == nil {
+		t.Errorf("The original request shouldn't be changed.")
+	}
+}
+
+func TestSplitPerMediaTypeBatches(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "banner-1", Banner: &openrtb.Banner{}},
+			{ID: "banner-2", Banner: &openrtb.Banner{}},
+			{ID: "banner-3", Banner: &openrtb.Banner{}},
+			{ID: "video-1", Video: &openrtb.Video{}},
+		},
+	}
+	pieces := splitRequest(request, adapters.SplitPerMediaType, 2)
+	if len(pieces) != 3 {
+		t.Fatalf("The media type groups should be batched by maxImps. Got %d pieces", len(pieces))
+	}
+	assertImpIDs(t, pieces[0], "banner-1", "banner-2")
+	assertImpIDs(t, pieces[1], "banner-3")
+	assertImpIDs(t, pieces[2], "video-1")
+}
+
+func assertImpIDs(t *testing.T, request *openrtb.BidRequest, expected ...string) {
+	t.Helper()
+	if len(request.Imp) != len(expected) {
+		t.Errorf("Expected imps %v. Got %d imps", expected, len(request.Imp))
+		return
+	}
+	for i, imp := range request.Imp {
+		if imp.ID != expected[i] {
+			t.Errorf("Expected imp %d to be %s. Got %s", i, expected[i], imp.ID)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package exchange
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 )
 
@@ -20,7 +23,22 @@ type targetData struct {
 	priceGranularity  openrtb_ext.PriceGranularity
 	includeWinners    bool
 	includeBidderKeys bool
+	includeFormat     bool
 	includeCache      bool
+	// maxKeys caps the number of keys set across all the bids in an imp. Zero means there's no cap.
+	maxKeys int
+	// staticKeys are the account's extra keys, which are added to every bid which gets targeting keys.
+	staticKeys []config.StaticKey
+	// dealTiers holds the deal tiers from each imp, by imp ID. It's only set if the request supports deals.
+	dealTiers map[string]openrtb_ext.DealTierBidderMap
+	// includeBrandCategory says whether hb_pb_cat_dur should use the ad server's categories, from brandCategories.
+	includeBrandCategory bool
+	// brandCategories maps IAB categories onto the primary ad server's. It's nil if the mapping couldn't be fetched.
+	brandCategories map[string]string
+	// durationRanges are the video durations which hb_pb_cat_dur may have. If it's empty, any duration may be used.
+	durationRanges []int
+	// requireExactDuration drops the video bids whose duration isn't in durationRanges, instead of rounding them up.
+	requireExactDuration bool
 }
 
 // setTargeting writes all the targeting params into the bids.
@@ -29,17 +47,28 @@ type targetData struct {
 // The one exception is the `hb_cache_id` key. Since our APIs explicitly document cache keys to be on a "best effort" basis,
 // it's ok if those stay in the auction. For now, this method implements a very naive cache strategy.
 // In the future, we should implement a more clever retry & backoff strategy to balance the success rate & performance.
+//
+// If maxKeys is set, the overall winner always gets its keys. The other bidders get theirs in order of
+// descending price, until the next bidder's keys would push the imp over the cap.
 func (targData *targetData) setTargeting(auc *auction, isApp bool) {
 	for impId, topBidsPerImp := range auc.winningBidsByBidder {
 		overallWinner := auc.winningBids[impId]
-		for bidderName, topBidPerBidder := range topBidsPerImp {
+		keyCount := 0
+		for _, bidderName := range targetingOrder(topBidsPerImp, overallWinner) {
+			topBidPerBidder := topBidsPerImp[bidderName]
 			isOverallWinner := overallWinner == topBidPerBidder
 
 			targets := make(map[string]string, 10)
 			if cpm, ok := auc.roundedPrices[topBidPerBidder]; ok {
 				targData.addKeys(targets, openrtb_ext.HbpbConstantKey, cpm, bidderName, isOverallWinner)
+				if catDur := targData.makeHbCategoryDuration(topBidPerBidder, cpm, impId, bidderName); catDur != "" {
+					targData.addKeys(targets, openrtb_ext.HbCategoryDurationKey, catDur, bidderName, isOverallWinner)
+				}
 			}
 			targData.addKeys(targets, openrtb_ext.HbBidderConstantKey, string(bidderName), bidderName, isOverallWinner)
+			if targData.includeFormat && topBidPerBidder.bidType != "" {
+				targData.addKeys(targets, openrtb_ext.HbFormatKey, string(topBidPerBidder.bidType), bidderName, isOverallWinner)
+			}
 			if hbSize := makeHbSize(topBidPerBidder.bid); hbSize != "" {
 				targData.addKeys(targets, openrtb_ext.HbSizeConstantKey, hbSize, bidderName, isOverallWinner)
 			}
@@ -60,11 +89,40 @@ func (targData *targetData) setTargeting(auc *auction, isApp bool) {
 				targData.addKeys(targets, openrtb_ext.HbEnvKey, openrtb_ext.HbEnvKeyApp, bidderName, isOverallWinner)
 			}
 
+			for _, staticKey := range targData.staticKeys {
+				targets[staticKey.Key] = staticKey.Value
+			}
+
+			if targData.maxKeys > 0 && !isOverallWinner && keyCount+len(targets) > targData.maxKeys {
+				break
+			}
+			keyCount += len(targets)
 			topBidPerBidder.bidTargets = targets
 		}
 	}
 }
 
+// targetingOrder returns the bidders in the order their keys should be kept when the imp has a key cap:
+// the overall winner first, then the rest by descending price. Ties are broken by bidder name, so that
+// the same bids always get the same keys.
+func targetingOrder(topBidsPerImp map[openrtb_ext.BidderName]*pbsOrtbBid, overallWinner *pbsOrtbBid) []openrtb_ext.BidderName {
+	bidders := make([]openrtb_ext.BidderName, 0, len(topBidsPerImp))
+	for bidderName := range topBidsPerImp {
+		bidders = append(bidders, bidderName)
+	}
+	sort.Slice(bidders, func(i, j int) bool {
+		bidI, bidJ := topBidsPerImp[bidders[i]], topBidsPerImp[bidders[j]]
+		if (bidI == overallWinner) != (bidJ == overallWinner) {
+			return bidI == overallWinner
+		}
+		if bidI.bid.Price != bidJ.bid.Price {
+			return bidI.bid.Price > bidJ.bid.Price
+		}
+		return bidders[i] < bidders[j]
+	})
+	return bidders
+}
+
 func (targData *targetData) addKeys(keys map[string]string, key openrtb_ext.TargetingKey, value string, bidderName openrtb_ext.BidderName, overallWinner bool) {
 	if targData.includeBidderKeys {
 		keys[key.BidderKey(bidderName, maxKeyLength)] = value
@@ -74,6 +132,95 @@ func (targData *targetData) addKeys(keys map[string]string, key openrtb_ext.Targ
 	}
 }
 
+// makeHbCategoryDuration returns the hb_pb_cat_dur value for a video bid which says how long it is, or "" for any other bid.
+// If the bid's deal priority meets its bidder's deal tier in the imp, the price is replaced by the tier's label.
+//
+// If the request asked for brand categories, the bid's category is replaced by the ad server's. Bids whose
+// category can't be mapped don't get the key at all, since the ad server couldn't keep competing brands apart.
+func (targData *targetData) makeHbCategoryDuration(bid *pbsOrtbBid, cpm string, impId string, bidderName openrtb_ext.BidderName) string {
+	if bid.bidVideo == nil || bid.bidVideo.Duration <= 0 {
+		return ""
+	}
+	price := cpm
+	if tier, ok := targData.dealTiers[impId][bidderName]; ok && bid.dealPriority >= tier.MinDealTier {
+		price = tier.Prefix + strconv.Itoa(bid.dealPriority)
+	}
+	category := bid.bidVideo.PrimaryCategory
+	if category == "" && len(bid.bid.Cat) > 0 {
+		category = bid.bid.Cat[0]
+	}
+	if targData.includeBrandCategory {
+		if category = targData.brandCategories[category]; category == "" {
+			return ""
+		}
+	}
+	bucket, ok := targData.durationBucket(bid.bidVideo.Duration)
+	if !ok {
+		return ""
+	}
+	duration := strconv.Itoa(bucket) + "s"
+	if category == "" {
+		return price + "_" + duration
+	}
+	return price + "_" + category + "_" + duration
+}
+
+// durationBucket returns the duration which goes in a video bid's hb_pb_cat_dur key. This is the bid's own duration
+// if the request didn't list the allowed ones, or if it's one of them. Otherwise, it's rounded up to the shortest
+// allowed duration which is longer, unless exact durations are required. It returns false if the bid doesn't fit any.
+func (targData *targetData) durationBucket(duration int) (int, bool) {
+	if len(targData.durationRanges) == 0 {
+		return duration, true
+	}
+	bucket := 0
+	for _, allowed := range targData.durationRanges {
+		if allowed == duration {
+			return duration, true
+		}
+		if allowed > duration && !targData.requireExactDuration && (bucket == 0 || allowed < bucket) {
+			bucket = allowed
+		}
+	}
+	return bucket, bucket > 0
+}
+
+// dropUnfitDurations removes the video bids whose duration doesn't fit any of the allowed ones before the auction,
+// since the ad server has no line items which they could serve from. Each bidder gets an error for the bids it lost.
+func (targData *targetData) dropUnfitDurations(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra) {
+	if targData == nil || len(targData.durationRanges) == 0 {
+		return
+	}
+	for bidderName, seatBid := range adapterBids {
+		if seatBid == nil {
+			continue
+		}
+		kept := make([]*pbsOrtbBid, 0, len(seatBid.bids))
+		for _, bid := range seatBid.bids {
+			if bid.bidVideo != nil && bid.bidVideo.Duration > 0 {
+				if _, ok := targData.durationBucket(bid.bidVideo.Duration); !ok {
+					if extra, ok := adapterExtra[bidderName]; ok {
+						extra.Errors = append(extra.Errors, fmt.Sprintf("Bid %s was dropped because its duration of %ds doesn't fit any of request.ext.prebid.targeting.durationrangesec", bid.bid.ID, bid.bidVideo.Duration))
+					}
+					continue
+				}
+			}
+			kept = append(kept, bid)
+		}
+		seatBid.bids = kept
+	}
+}
+
+// readDealTiers returns the deal tiers from each imp, by imp ID. Imps whose deal tiers are malformed are skipped.
+func readDealTiers(imps []openrtb.Imp) map[string]openrtb_ext.DealTierBidderMap {
+	dealTiers := make(map[string]openrtb_ext.DealTierBidderMap, len(imps))
+	for _, imp := range imps {
+		if impDealTiers, err := openrtb_ext.ReadDealTiersFromImp(imp); err == nil {
+			dealTiers[imp.ID] = impDealTiers
+		}
+	}
+	return dealTiers
+}
+
 func makeHbSize(bid *openrtb.Bid) string {
 	if bid.W != 0 && bid.H != 0 {
 		return strconv.FormatUint(bid.W, 10) + "x" + strconv.FormatUint(bid.H, 10)
@@ -3,17 +3,21 @@ package exchange
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
 
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/stored_requests"
 )
 
 // Using this set of bids in more than one test
@@ -52,6 +56,208 @@ func TestTargetingCache(t *testing.T) {
 	assertKeyExists(t, bids["losing-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderAppnexus, maxKeyLength), false)
 }
 
+func TestTargetingFormatAndStaticKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(mockServer))
+	defer server.Close()
+
+	ex := &exchange{
+		adapterMap: buildAdapterMap(mockBids, server.URL, server.Client()),
+		me:         &metricsConf.DummyMetricsEngine{},
+		targeting: config.Targeting{
+			Accounts: []config.AccountTargeting{
+				{ID: "with-keys", StaticKeys: []config.StaticKey{{Key: "siteSection", Value: "sports"}}},
+			},
+		},
+	}
+	req := &openrtb.BidRequest{
+		Imp: buildImps(t, mockBids),
+		Site: &openrtb.Site{
+			Publisher: &openrtb.Publisher{ID: "with-keys"},
+		},
+		Ext: buildTargetingExt(false, true, true),
+	}
+
+	bidResp, err := ex.HoldAuction(context.Background(), req, &mockFetcher{}, pbsmetrics.Labels{})
+	if err != nil {
+		t.Fatalf("Unexpected errors running auction: %v", err)
+	}
+	bids := buildBidMap(bidResp.SeatBid, len(mockBids))
+
+	winningTargets := parseTargets(t, bids["winning-bid"])
+	assertStringValue(t, "hb_format", "banner", winningTargets[string(openrtb_ext.HbFormatKey)])
+	assertStringValue(t, "hb_format_appnexus", "banner", winningTargets[openrtb_ext.HbFormatKey.BidderKey(openrtb_ext.BidderAppnexus, maxKeyLength)])
+	assertStringValue(t, "winning static key", "sports", winningTargets["siteSection"])
+
+	contendingTargets := parseTargets(t, bids["contending-bid"])
+	assertStringValue(t, "contending static key", "sports", contendingTargets["siteSection"])
+	assertKeyExists(t, bids["contending-bid"], string(openrtb_ext.HbFormatKey), false)
+}
+
+func TestTargetingWithoutFormat(t *testing.T) {
+	bids := runTargetingAuctionWithExt(t, mockBids, openrtb.RawJSON(`{"prebid":{"targeting":{"includeformat":false}}}`), false)
+
+	assertKeyExists(t, bids["winning-bid"], string(openrtb_ext.HbpbConstantKey), true)
+	assertKeyExists(t, bids["winning-bid"], string(openrtb_ext.HbFormatKey), false)
+	assertKeyExists(t, bids["winning-bid"], openrtb_ext.HbFormatKey.BidderKey(openrtb_ext.BidderAppnexus, maxKeyLength), false)
+	assertKeyExists(t, bids["contending-bid"], openrtb_ext.HbFormatKey.BidderKey(openrtb_ext.BidderRubicon, maxKeyLength), false)
+}
+
+func TestTargetingMaxKeys(t *testing.T) {
+	// The winning bid gets 7 keys: hb_pb, hb_bidder and hb_format with and without the bidder suffix, plus hb_creative_loadtype.
+	// The contending bid gets 4, because it only has the suffixed ones.
+	testCases := []struct {
+		description      string
+		maxKeys          int
+		expectContending bool
+	}{
+		{description: "no cap", maxKeys: 0, expectContending: true},
+		{description: "room for both bidders", maxKeys: 11, expectContending: true},
+		{description: "room for the winner only", maxKeys: 10, expectContending: false},
+		{description: "cap below the winner's keys", maxKeys: 1, expectContending: false},
+	}
+
+	for _, test := range testCases {
+		ext := openrtb.RawJSON(fmt.Sprintf(`{"prebid":{"targeting":{"maxkeys":%d}}}`, test.maxKeys))
+		bids := runTargetingAuctionWithExt(t, mockBids, ext, false)
+
+		if winningTargets := parseTargets(t, bids["winning-bid"]); len(winningTargets) != 7 {
+			t.Errorf("%s: the winning bid should keep all 7 keys. Got %v", test.description, winningTargets)
+		}
+		contendingTargets := parseTargets(t, bids["contending-bid"])
+		if test.expectContending && len(contendingTargets) != 4 {
+			t.Errorf("%s: the contending bid should have 4 keys. Got %v", test.description, contendingTargets)
+		}
+		if !test.expectContending && len(contendingTargets) != 0 {
+			t.Errorf("%s: the contending bid should have no keys. Got %v", test.description, contendingTargets)
+		}
+	}
+}
+
+func TestTargetingOrder(t *testing.T) {
+	winner := &pbsOrtbBid{bid: &openrtb.Bid{Price: 1}}
+	topBids := map[openrtb_ext.BidderName]*pbsOrtbBid{
+		openrtb_ext.BidderRubicon:  &pbsOrtbBid{bid: &openrtb.Bid{Price: 2}},
+		openrtb_ext.BidderAppnexus: winner,
+		openrtb_ext.BidderPubmatic: &pbsOrtbBid{bid: &openrtb.Bid{Price: 3}},
+		openrtb_ext.BidderOpenx:    &pbsOrtbBid{bid: &openrtb.Bid{Price: 2}},
+	}
+	expected := []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus, openrtb_ext.BidderPubmatic, openrtb_ext.BidderOpenx, openrtb_ext.BidderRubicon}
+	if actual := targetingOrder(topBids, winner); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Wrong bidder order. Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestBrandCategories(t *testing.T) {
+	targData := &targetData{
+		includeBrandCategory: true,
+		brandCategories:      map[string]string{"IAB1-1": "395"},
+	}
+	videoBid := func(category string) *pbsOrtbBid {
+		return &pbsOrtbBid{
+			bid:      &openrtb.Bid{Cat: []string{category}},
+			bidVideo: &openrtb_ext.ExtBidPrebidVideo{Duration: 30},
+		}
+	}
+	assertStringValue(t, "mapped category", "10.00_395_30s", targData.makeHbCategoryDuration(videoBid("IAB1-1"), "10.00", "imp", openrtb_ext.BidderAppnexus))
+	assertStringValue(t, "unmapped category", "", targData.makeHbCategoryDuration(videoBid("IAB1-2"), "10.00", "imp", openrtb_ext.BidderAppnexus))
+
+	targData.includeBrandCategory = false
+	assertStringValue(t, "IAB category", "10.00_IAB1-2_30s", targData.makeHbCategoryDuration(videoBid("IAB1-2"), "10.00", "imp", openrtb_ext.BidderAppnexus))
+}
+
+func TestDurationBuckets(t *testing.T) {
+	videoBid := func(id string, duration int) *pbsOrtbBid {
+		return &pbsOrtbBid{
+			bid:      &openrtb.Bid{ID: id},
+			bidVideo: &openrtb_ext.ExtBidPrebidVideo{Duration: duration},
+		}
+	}
+	testCases := []struct {
+		description string
+		exact       bool
+		duration    int
+		expected    string
+	}{
+		{description: "exact match", duration: 30, expected: "10.00_30s"},
+		{description: "rounded up", duration: 20, expected: "10.00_30s"},
+		{description: "shortest bucket", duration: 5, expected: "10.00_15s"},
+		{description: "too long", duration: 61, expected: ""},
+		{description: "exact match required", exact: true, duration: 30, expected: "10.00_30s"},
+		{description: "exact match required, not listed", exact: true, duration: 20, expected: ""},
+	}
+	for _, test := range testCases {
+		targData := &targetData{durationRanges: []int{60, 15, 30}, requireExactDuration: test.exact}
+		assertStringValue(t, test.description, test.expected, targData.makeHbCategoryDuration(videoBid("bid", test.duration), "10.00", "imp", openrtb_ext.BidderAppnexus))
+	}
+
+	targData := &targetData{}
+	assertStringValue(t, "no buckets", "10.00_20s", targData.makeHbCategoryDuration(videoBid("bid", 20), "10.00", "imp", openrtb_ext.BidderAppnexus))
+
+	targData = &targetData{durationRanges: []int{15, 30}}
+	adapterBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {
+			bids: []*pbsOrtbBid{videoBid("short", 20), videoBid("long", 45), {bid: &openrtb.Bid{ID: "banner"}}},
+		},
+	}
+	adapterExtra := map[openrtb_ext.BidderName]*seatResponseExtra{
+		openrtb_ext.BidderAppnexus: {},
+	}
+	targData.dropUnfitDurations(adapterBids, adapterExtra)
+	if bids := adapterBids[openrtb_ext.BidderAppnexus].bids; len(bids) != 2 || bids[0].bid.ID != "short" || bids[1].bid.ID != "banner" {
+		t.Errorf("Only the video bid which doesn't fit any duration should be dropped. Got %v", bids)
+	}
+	if errs := adapterExtra[openrtb_ext.BidderAppnexus].Errors; len(errs) != 1 {
+		t.Errorf("The bidder should get an error for the dropped bid. Got %v", errs)
+	}
+}
+
+func TestFetchBrandCategories(t *testing.T) {
+	e := &exchange{
+		categories: mockCategoryFetcher{
+			"freewheel":     {"IAB1-1": "395"},
+			"freewheel_pub": {"IAB1-1": "pub-395"},
+		},
+	}
+	testCases := []struct {
+		description string
+		publisher   string
+		expected    string
+	}{
+		{description: "publisher mapping", publisher: "pub", expected: "pub-395"},
+		{description: "no publisher mapping", publisher: "other", expected: "395"},
+		{description: "no publisher", expected: "395"},
+	}
+	for _, test := range testCases {
+		categories, err := e.fetchBrandCategories(context.Background(), &openrtb_ext.ExtIncludeBrandCategory{PrimaryAdServer: 1, Publisher: test.publisher})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.description, err)
+		}
+		assertStringValue(t, test.description, test.expected, categories["IAB1-1"])
+	}
+
+	if _, err := e.fetchBrandCategories(context.Background(), &openrtb_ext.ExtIncludeBrandCategory{PrimaryAdServer: 2}); err == nil {
+		t.Errorf("Ad servers without a mapping should return an error.")
+	}
+	e.categories = nil
+	if _, err := e.fetchBrandCategories(context.Background(), &openrtb_ext.ExtIncludeBrandCategory{PrimaryAdServer: 1}); err == nil {
+		t.Errorf("Servers without category mappings should return an error.")
+	}
+}
+
+// mockCategoryFetcher holds category mappings by "{ad_server}" or "{ad_server}_{publisher}".
+type mockCategoryFetcher map[string]map[string]string
+
+func (f mockCategoryFetcher) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	key := primaryAdServer
+	if publisherID != "" {
+		key += "_" + publisherID
+	}
+	if categories, ok := f[key]; ok {
+		return categories, nil
+	}
+	return nil, stored_requests.CategoryNotFound(primaryAdServer, publisherID)
+}
+
 func assertKeyExists(t *testing.T, bid *openrtb.Bid, key string, expected bool) {
 	t.Helper()
 	targets := parseTargets(t, bid)
@@ -63,6 +269,12 @@ func assertKeyExists(t *testing.T, bid *openrtb.Bid, key string, expected bool)
 // runAuction takes a bunch of mock bids by Bidder and runs an auction. It returns a map of Bids indexed by their ImpID.
 // If includeCache is true, the auction will be run with cacheing as well, so the cache targeting keys should exist.
 func runTargetingAuction(t *testing.T, mockBids map[openrtb_ext.BidderName][]*openrtb.Bid, includeCache bool, includeWinners bool, includeBidderKeys bool, isApp bool) map[string]*openrtb.Bid {
+	return runTargetingAuctionWithExt(t, mockBids, buildTargetingExt(includeCache, includeWinners, includeBidderKeys), isApp)
+}
+
+// runTargetingAuctionWithExt is like runTargetingAuction, but uses the given request.ext.
+func runTargetingAuctionWithExt(t *testing.T, mockBids map[openrtb_ext.BidderName][]*openrtb.Bid, ext openrtb.RawJSON, isApp bool) map[string]*openrtb.Bid {
+	t.Helper()
 	server := httptest.NewServer(http.HandlerFunc(mockServer))
 	defer server.Close()
 
@@ -77,7 +289,7 @@ func runTargetingAuction(t *testing.T, mockBids map[openrtb_ext.BidderName][]*op
 
 	req := &openrtb.BidRequest{
 		Imp: imps,
-		Ext: buildTargetingExt(includeCache, includeWinners, includeBidderKeys),
+		Ext: ext,
 	}
 	if isApp {
 		req.App = &openrtb.App{}
@@ -0,0 +1,102 @@
+package exchange
+
+import (
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// tieBreaker decides which bid wins when two bids in an imp have the same price.
+//
+// Whatever the strategy, any ties which are left over are broken at random, so that one bidder isn't
+// always favoured over another. In test mode the random numbers are seeded from the request ID,
+// so the same request always gets the same winners.
+type tieBreaker struct {
+	strategy string
+	// bidderRank is each bidder's position in the bidder_priority list.
+	bidderRank map[openrtb_ext.BidderName]int
+	// lottery draws the random number which breaks any remaining ties.
+	lottery func() int64
+	// pinned are the account's deals which beat every other bid, whatever it's priced at.
+	pinned pinnedDeals
+}
+
+// tieEntry is a bid, along with everything the tieBreaker needs to compare it to the others.
+type tieEntry struct {
+	bid     *pbsOrtbBid
+	bidder  openrtb_ext.BidderName
+	arrival int
+	index   int
+	lottery int64
+}
+
+func newTieBreaker(cfg config.TieBreaking, bidRequest *openrtb.BidRequest) *tieBreaker {
+	ties := &tieBreaker{
+		strategy:   cfg.Strategy,
+		bidderRank: make(map[openrtb_ext.BidderName]int, len(cfg.BidderPriority)),
+		lottery:    rand.Int63,
+	}
+	for i, bidder := range cfg.BidderPriority {
+		ties.bidderRank[openrtb_ext.BidderName(bidder)] = i
+	}
+	if bidRequest.Test == 1 {
+		seed := fnv.New64a()
+		seed.Write([]byte(bidRequest.ID))
+		ties.lottery = rand.New(rand.NewSource(int64(seed.Sum64()))).Int63
+	}
+	return ties
+}
+
+// entry gets the bid ready to be compared. The seat's bids must be passed in order, because their index
+// is used by the first_received strategy.
+func (ties *tieBreaker) entry(bid *pbsOrtbBid, bidder openrtb_ext.BidderName, seatBid *pbsOrtbSeatBid, index int) tieEntry {
+	return tieEntry{
+		bid:     bid,
+		bidder:  bidder,
+		arrival: seatBid.arrival,
+		index:   index,
+		lottery: ties.lottery(),
+	}
+}
+
+// beats returns true if the candidate should replace the current best bid.
+func (ties *tieBreaker) beats(candidate tieEntry, best tieEntry) bool {
+	if pinned, bestPinned := ties.pinned.isPinned(candidate.bid), ties.pinned.isPinned(best.bid); pinned != bestPinned {
+		return pinned
+	}
+	if candidate.bid.bid.Price != best.bid.bid.Price {
+		return candidate.bid.bid.Price > best.bid.bid.Price
+	}
+	switch ties.strategy {
+	case config.TieBreakDealPriority:
+		if candidate.bid.dealPriority != best.bid.dealPriority {
+			return candidate.bid.dealPriority > best.bid.dealPriority
+		}
+		if isDeal, bestIsDeal := candidate.bid.bid.DealID != "", best.bid.bid.DealID != ""; isDeal != bestIsDeal {
+			return isDeal
+		}
+	case config.TieBreakBidderPriority:
+		if rank, bestRank := ties.rank(candidate.bidder), ties.rank(best.bidder); rank != bestRank {
+			return rank < bestRank
+		}
+	case config.TieBreakFirstReceived:
+		if candidate.arrival != best.arrival {
+			return candidate.arrival < best.arrival
+		}
+		if candidate.index != best.index {
+			return candidate.index < best.index
+		}
+	}
+	return candidate.lottery > best.lottery
+}
+
+// rank returns the bidder's position in the bidder_priority list. Unlisted bidders come after all the listed ones.
+func (ties *tieBreaker) rank(bidder openrtb_ext.BidderName) int {
+	if rank, ok := ties.bidderRank[bidder]; ok {
+		return rank
+	}
+	return len(ties.bidderRank)
+}
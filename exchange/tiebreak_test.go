@@ -0,0 +1,112 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// tiedSeatBids returns two bidders which each bid 1.00 on the same imp.
+// Rubicon's response arrived first, and appnexus has the higher deal priority.
+func tiedSeatBids() map[openrtb_ext.BidderName]*pbsOrtbSeatBid {
+	return map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {
+			bids: []*pbsOrtbBid{{
+				bid:          &openrtb.Bid{ID: "appnexus-bid", ImpID: "imp", Price: 1, DealID: "deal"},
+				dealPriority: 5,
+			}},
+			arrival: 1,
+		},
+		openrtb_ext.BidderRubicon: {
+			bids: []*pbsOrtbBid{{
+				bid: &openrtb.Bid{ID: "rubicon-bid", ImpID: "imp", Price: 1},
+			}},
+			arrival: 0,
+		},
+	}
+}
+
+func TestTieBreakingStrategies(t *testing.T) {
+	testCases := []struct {
+		description string
+		cfg         config.TieBreaking
+		expectedBid string
+	}{
+		{
+			description: "deal priority",
+			cfg:         config.TieBreaking{Strategy: config.TieBreakDealPriority},
+			expectedBid: "appnexus-bid",
+		},
+		{
+			description: "bidder priority",
+			cfg:         config.TieBreaking{Strategy: config.TieBreakBidderPriority, BidderPriority: []string{"rubicon"}},
+			expectedBid: "rubicon-bid",
+		},
+		{
+			description: "first received",
+			cfg:         config.TieBreaking{Strategy: config.TieBreakFirstReceived},
+			expectedBid: "rubicon-bid",
+		},
+	}
+
+	for _, test := range testCases {
+		auc := newAuction(tiedSeatBids(), 1, newTieBreaker(test.cfg, &openrtb.BidRequest{}))
+		if winner := auc.winningBids["imp"].bid.ID; winner != test.expectedBid {
+			t.Errorf("%s: expected %s to win. Got %s", test.description, test.expectedBid, winner)
+		}
+	}
+}
+
+func TestTieBreakingPrefersHigherPrice(t *testing.T) {
+	seatBids := tiedSeatBids()
+	seatBids[openrtb_ext.BidderAppnexus].bids[0].bid.Price = 0.5
+
+	ties := newTieBreaker(config.TieBreaking{Strategy: config.TieBreakDealPriority}, &openrtb.BidRequest{})
+	if winner := newAuction(seatBids, 1, ties).winningBids["imp"].bid.ID; winner != "rubicon-bid" {
+		t.Errorf("The highest price should win, whatever the strategy. Got %s", winner)
+	}
+}
+
+func TestTieBreakingWithinSeat(t *testing.T) {
+	seatBids := map[openrtb_ext.BidderName]*pbsOrtbSeatBid{
+		openrtb_ext.BidderAppnexus: {
+			bids: []*pbsOrtbBid{
+				{bid: &openrtb.Bid{ID: "first", ImpID: "imp", Price: 1}},
+				{bid: &openrtb.Bid{ID: "second", ImpID: "imp", Price: 1}},
+			},
+		},
+	}
+
+	ties := newTieBreaker(config.TieBreaking{Strategy: config.TieBreakFirstReceived}, &openrtb.BidRequest{})
+	auc := newAuction(seatBids, 1, ties)
+	if winner := auc.winningBidsByBidder["imp"][openrtb_ext.BidderAppnexus].bid.ID; winner != "first" {
+		t.Errorf("The seat's first bid should win a tie within the seat. Got %s", winner)
+	}
+}
+
+func TestTieBreakingTestModeIsDeterministic(t *testing.T) {
+	req := &openrtb.BidRequest{ID: "some-request", Test: 1}
+	cfg := config.TieBreaking{Strategy: config.TieBreakRandom}
+
+	expected := newAuction(tiedSeatBids(), 1, newTieBreaker(cfg, req)).winningBids["imp"].bid.ID
+	for i := 0; i < 20; i++ {
+		if winner := newAuction(tiedSeatBids(), 1, newTieBreaker(cfg, req)).winningBids["imp"].bid.ID; winner != expected {
+			t.Fatalf("Test requests with the same ID should always get the same winner. Got %s, then %s", expected, winner)
+		}
+	}
+}
+
+func TestTieBreakerRank(t *testing.T) {
+	ties := newTieBreaker(config.TieBreaking{BidderPriority: []string{"rubicon", "appnexus"}}, &openrtb.BidRequest{})
+	if rank := ties.rank(openrtb_ext.BidderRubicon); rank != 0 {
+		t.Errorf("rubicon should rank 0. Got %d", rank)
+	}
+	if rank := ties.rank(openrtb_ext.BidderAppnexus); rank != 1 {
+		t.Errorf("appnexus should rank 1. Got %d", rank)
+	}
+	if rank := ties.rank(openrtb_ext.BidderOpenx); rank != 2 {
+		t.Errorf("Unlisted bidders should rank after the listed ones. Got %d", rank)
+	}
+}
@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/prebid/prebid-server/adapters"
+)
+
+// trimRequests strips the empty fields from the JSON bodies which the Bidder built. See config.RequestTrimming.
+func trimRequests(reqData []*adapters.RequestData) {
+	for _, oneReqData := range reqData {
+		if body, ok := trimJSON(oneReqData.Body); ok {
+			oneReqData.Body = body
+		}
+	}
+}
+
+// trimJSON returns the body without its nulls, empty strings, empty objects and empty arrays.
+// It returns false if the body isn't JSON, or trimming it wouldn't make it any smaller.
+func trimJSON(body []byte) ([]byte, bool) {
+	if len(body) == 0 {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Numbers are kept as they were written, so that large IDs and prices aren't reformatted.
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return nil, false
+	}
+	trimValue(value)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// URLs in the request shouldn't grow from having their &s escaped.
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, false
+	}
+	trimmed := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if len(trimmed) >= len(body) {
+		return nil, false
+	}
+	return trimmed, true
+}
+
+// trimValue removes the empty fields from the objects in value, and returns true if value is empty itself once
+// they're gone. Arrays keep all their elements, since their positions may matter, but the elements are trimmed too.
+func trimValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]interface{}:
+		for key, field := range v {
+			if trimValue(field) {
+				delete(v, key)
+			}
+		}
+		return len(v) == 0
+	case []interface{}:
+		for _, element := range v {
+			trimValue(element)
+		}
+		return len(v) == 0
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters"
+)
+
+func TestTrimJSON(t *testing.T) {
+	testCases := []struct {
+		description string
+		body        string
+		expected    string
+		trimmed     bool
+	}{
+		{
+			description: "Empty fields are removed, along with objects which only had empty fields",
+			body:        `{"id":"req","site":{"page":"https://example.com/?a=1&b=2","name":"","ext":{"amp":null}},"imp":[{"id":"1","banner":{"format":[]},"ext":{}}]}`,
+			expected:    `{"id":"req","imp":[{"id":"1"}],"site":{"page":"https://example.com/?a=1&b=2"}}`,
+			trimmed:     true,
+		},
+		{
+			description: "Zeros and falses are signals, so they're kept",
+			body:        `{"regs":{"ext":{"gdpr":0}},"device":{"lmt":0},"test":false,"ext":null}`,
+			expected:    `{"device":{"lmt":0},"regs":{"ext":{"gdpr":0}},"test":false}`,
+			trimmed:     true,
+		},
+		{
+			description: "Array elements keep their positions",
+			body:        `{"format":[{},{"w":300,"h":250}],"id":""}`,
+			expected:    `{"format":[{},{"h":250,"w":300}]}`,
+			trimmed:     true,
+		},
+		{
+			description: "Numbers aren't reformatted",
+			body:        `{"id":12345678901234567890,"bidfloor":1.50,"x":""}`,
+			expected:    `{"bidfloor":1.50,"id":12345678901234567890}`,
+			trimmed:     true,
+		},
+		{
+			description: "Bodies which wouldn't get smaller are left alone",
+			body:        `{"id":"req","n":1}`,
+		},
+		{
+			description: "Bodies which aren't JSON are left alone",
+			body:        `id=req&name=`,
+		},
+		{
+			description: "Bodies with more than one JSON value are left alone",
+			body:        `{"id":"req","x":""} {}`,
+		},
+	}
+	for _, test := range testCases {
+		trimmed, ok := trimJSON([]byte(test.body))
+		if ok != test.trimmed || string(trimmed) != test.expected {
+			t.Errorf("%s: expected %s (%t). Got %s (%t)", test.description, test.expected, test.trimmed, trimmed, ok)
+		}
+	}
+}
+
+func TestTrimRequests(t *testing.T) {
+	reqData := []*adapters.RequestData{
+		{Method: "POST", Body: []byte(`{"id":"req","ext":{}}`)},
+		{Method: "GET"},
+	}
+	trimRequests(reqData)
+	if body := string(reqData[0].Body); body != `{"id":"req"}` {
+		t.Errorf("The body should be trimmed. Got %s", body)
+	}
+	if reqData[1].Body != nil {
+		t.Errorf("Requests without a body shouldn't get one. Got %s", reqData[1].Body)
+	}
+}
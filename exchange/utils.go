@@ -1,21 +1,28 @@
 package exchange
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/url"
 
 	"github.com/buger/jsonparser"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 // cleanOpenRTBRequests splits the input request into requests which are sanitized for each bidder. Intended behavior is:
 //
-//   1. BidRequest.Imp[].Ext will only contain the "prebid" field and a "bidder" field which has the params for the intended Bidder.
+//   1. BidRequest.Imp[].Ext will only contain the "prebid" and "tid" fields, and a "bidder" field which has the params for the intended Bidder.
 //   2. Every BidRequest.Imp[] requested Bids from the Bidder who keys it.
 //   3. BidRequest.User.BuyerUID will be set to that Bidder's ID.
+//   4. Bidders which request.ext.prebid.data.bidders doesn't list won't get the first party data in site.ext.data,
+//      app.ext.data or user.ext.data.
 func cleanOpenRTBRequests(orig *openrtb.BidRequest, usersyncs IdFetcher, blables map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, labels pbsmetrics.Labels) (requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, errs []error) {
 	impsByBidder, errs := splitImps(orig.Imp)
 	if len(errs) > 0 {
@@ -28,6 +35,11 @@ func cleanOpenRTBRequests(orig *openrtb.BidRequest, usersyncs IdFetcher, blables
 	}
 
 	requestsByBidder, errs = splitBidRequest(orig, impsByBidder, aliases, usersyncs, blables, labels)
+	if len(errs) > 0 {
+		return
+	}
+
+	errs = restrictFPD(orig, requestsByBidder, aliases)
 	return
 }
 
@@ -61,6 +73,253 @@ func splitBidRequest(req *openrtb.BidRequest, impsByBidder map[string][]openrtb.
 	return requestsByBidder, nil
 }
 
+// skipUnmatchedBidders removes the bidders which have no user.buyeruid from site requests, since they're unlikely
+// to bid without one. App requests are left alone, because apps don't use cookies.
+func skipUnmatchedBidders(cfg *config.BuyerUIDs, orig *openrtb.BidRequest, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string) {
+	if orig.App != nil {
+		return
+	}
+	for bidder, req := range requestsByBidder {
+		if req.User != nil && req.User.BuyerUID != "" {
+			continue
+		}
+		if containsBidder(cfg.SkipExceptions, string(bidder)) || containsBidder(cfg.SkipExceptions, string(resolveBidder(string(bidder), aliases))) {
+			continue
+		}
+		delete(requestsByBidder, bidder)
+	}
+}
+
+func containsBidder(bidders []string, bidder string) bool {
+	for _, thisBidder := range bidders {
+		if thisBidder == bidder {
+			return true
+		}
+	}
+	return false
+}
+
+// dropDisabledBidders removes the requests for bidders which have no adapter, because they're disabled on this server.
+// Request aliases of a disabled bidder are removed too.
+func dropDisabledBidders(adapterMap map[openrtb_ext.BidderName]adaptedBidder, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string) (errs []error) {
+	for bidder := range requestsByBidder {
+		if _, ok := adapterMap[resolveBidder(string(bidder), aliases)]; !ok {
+			delete(requestsByBidder, bidder)
+			errs = append(errs, fmt.Errorf("Bidder %s is disabled on this server.", bidder))
+		}
+	}
+	return
+}
+
+// sellerAuthorizer decides which bidders are authorized to sell a publisher's inventory. It's an adstxt.Validator,
+// outside of the tests.
+type sellerAuthorizer interface {
+	Authorized(domain string, bidder string) bool
+	// Blocks returns true if the bidders which aren't authorized shouldn't be called.
+	Blocks() bool
+}
+
+// checkAdsTxt finds the bidders which the publisher's ads.txt doesn't authorize to sell its inventory. If the
+// authorizer blocks them, their requests are removed and errs say why. Otherwise, they're still called, and the
+// warnings should be added to their responses.
+func checkAdsTxt(authorizer sellerAuthorizer, orig *openrtb.BidRequest, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string) (errs []error, warnings map[openrtb_ext.BidderName]string) {
+	domain := publisherDomain(orig)
+	if domain == "" {
+		return
+	}
+	for bidder := range requestsByBidder {
+		if authorizer.Authorized(domain, string(resolveBidder(string(bidder), aliases))) {
+			continue
+		}
+		if authorizer.Blocks() {
+			delete(requestsByBidder, bidder)
+			errs = append(errs, fmt.Errorf("Bidder %s isn't authorized to sell %s's inventory, according to its ads.txt.", bidder, domain))
+			continue
+		}
+		if warnings == nil {
+			warnings = make(map[openrtb_ext.BidderName]string)
+		}
+		warnings[bidder] = fmt.Sprintf("This bidder isn't authorized to sell %s's inventory, according to its ads.txt.", domain)
+	}
+	return
+}
+
+// publisherDomain returns the domain whose ads.txt lists the sellers of the request's inventory. It's empty for apps,
+// since they list their sellers in app-ads.txt files instead.
+func publisherDomain(orig *openrtb.BidRequest) string {
+	if orig.Site == nil {
+		return ""
+	}
+	if orig.Site.Domain != "" {
+		return orig.Site.Domain
+	}
+	if orig.Site.Publisher != nil && orig.Site.Publisher.Domain != "" {
+		return orig.Site.Publisher.Domain
+	}
+	if page, err := url.Parse(orig.Site.Page); err == nil {
+		return page.Hostname()
+	}
+	return ""
+}
+
+// gdprPolicy denies each bidder the activities which the user's consent string doesn't allow.
+type gdprPolicy struct {
+	perms map[openrtb_ext.BidderName]gdpr.AuctionPermissions
+}
+
+// newGDPRPolicy looks up what the consent string allows each bidder to do. Bidders which can't select basic
+// ads for the user can't fetch bids, and the rest may lose the user's IDs and precise geo.
+//
+// It returns nil unless the request says that GDPR applies, or it doesn't say and defaultSignal is "1".
+func newGDPRPolicy(ctx context.Context, permissions gdpr.Permissions, orig *openrtb.BidRequest, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, defaultSignal string) (policy privacy.Policy, errs []error) {
+	signal, consent := gdpr.RequestSignals(orig)
+	if signal == "" {
+		signal = defaultSignal
+	}
+	if signal != "1" {
+		return nil, nil
+	}
+
+	perms := make(map[openrtb_ext.BidderName]gdpr.AuctionPermissions, len(requestsByBidder))
+	for bidder := range requestsByBidder {
+		bidderPerms, err := permissions.AuctionPermissions(ctx, resolveBidder(string(bidder), aliases), consent)
+		// The consent string is the same for every bidder, so one error is enough.
+		if err != nil && len(errs) == 0 {
+			errs = append(errs, err)
+		}
+		perms[bidder] = bidderPerms
+	}
+	return &gdprPolicy{perms: perms}, errs
+}
+
+func (p *gdprPolicy) Name() string {
+	return string(pbsmetrics.PrivacyPolicyGDPR)
+}
+
+func (p *gdprPolicy) Allow(activity privacy.Activity, component privacy.Component) bool {
+	perms, ok := p.perms[openrtb_ext.BidderName(component.BidderName)]
+	if !ok {
+		return true
+	}
+	switch activity {
+	case privacy.ActivityFetchBids:
+		return perms.BidAllowed
+	case privacy.ActivityTransmitUFPD:
+		return perms.PersonalizationAllowed
+	case privacy.ActivityTransmitPreciseGeo:
+		return perms.PreciseGeoAllowed
+	}
+	return true
+}
+
+// regimeUsesGDPR returns true if the geo privacy rule should be enforced through the GDPR config.
+func regimeUsesGDPR(rule *config.GeoPrivacyRule) bool {
+	return rule != nil && (rule.Regime == config.RegimeGDPR || rule.Regime == config.RegimeLGPD)
+}
+
+// defaultGDPRSignal returns the GDPR signal to use for requests which don't have one.
+// The user's location wins over the host's defaults.
+func defaultGDPRSignal(rule *config.GeoPrivacyRule, defaults config.ChannelPrivacyDefaults) string {
+	if regimeUsesGDPR(rule) {
+		return "1"
+	}
+	return defaults.GDPR
+}
+
+// privacyChannel returns the privacy_defaults channel which the request came from.
+func privacyChannel(requestType pbsmetrics.RequestType) string {
+	switch requestType {
+	case pbsmetrics.ReqTypeORTB2App:
+		return config.ChannelApp
+	case pbsmetrics.ReqTypeAMP:
+		return config.ChannelAMP
+	default:
+		return config.ChannelWeb
+	}
+}
+
+// addGPPSID tells each bidder which US state law covers the user, through regs.ext.gpp_sid.
+// Requests which already have a gpp_sid are left alone.
+func addGPPSID(orig *openrtb.BidRequest, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, sid int) error {
+	regsExt := make(map[string]openrtb.RawJSON)
+	if orig.Regs != nil && len(orig.Regs.Ext) > 0 {
+		if err := json.Unmarshal(orig.Regs.Ext, &regsExt); err != nil {
+			return fmt.Errorf("Error decoding Request.regs.ext: %v", err)
+		}
+		if _, ok := regsExt["gpp_sid"]; ok {
+			return nil
+		}
+	}
+	regsExt["gpp_sid"] = openrtb.RawJSON(fmt.Sprintf("[%d]", sid))
+	newExt, err := json.Marshal(regsExt)
+	if err != nil {
+		return err
+	}
+
+	for _, req := range requestsByBidder {
+		var regs openrtb.Regs
+		if req.Regs != nil {
+			regs = *req.Regs
+		}
+		regs.Ext = newExt
+		req.Regs = &regs
+	}
+	return nil
+}
+
+// enforceActivities applies the activity control, with the request's privacy policies, to each bidder's request.
+// Bidders which can't fetch bids are removed, and the rest lose any data which they aren't allowed to receive.
+//
+// It returns the names of the policies which changed any of the requests. They match the pbsmetrics.PrivacyPolicy
+// labels, with privacy.PolicyActivityRules for the account's own rules.
+func enforceActivities(control privacy.ActivityControl, vendorIDs map[openrtb_ext.BidderName]uint16, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string) (deniedBy map[string]bool) {
+	deniedBy = make(map[string]bool)
+	for bidder, req := range requestsByBidder {
+		coreBidder := resolveBidder(string(bidder), aliases)
+		component := privacy.Component{
+			BidderName: string(bidder),
+			GVLID:      vendorIDs[coreBidder],
+		}
+
+		if policy := control.DeniedBy(privacy.ActivityFetchBids, component); policy != "" {
+			delete(requestsByBidder, bidder)
+			deniedBy[policy] = true
+			continue
+		}
+		if policy := control.DeniedBy(privacy.ActivityTransmitUFPD, component); policy != "" {
+			req.User = privacy.ScrubUserIDsAndDemographics(req.User)
+			req.Device = privacy.ScrubDeviceIDs(req.Device)
+			deniedBy[policy] = true
+		}
+		if policy := control.DeniedBy(privacy.ActivityTransmitPreciseGeo, component); policy != "" {
+			req.User = privacy.ScrubUserGeo(req.User)
+			req.Device = privacy.ScrubDeviceGeo(req.Device)
+			deniedBy[policy] = true
+		}
+		if policy := control.DeniedBy(privacy.ActivityTransmitTIDs, component); policy != "" {
+			if control.RandomizeTIDs() {
+				req.Source = privacy.RandomizeSourceTID(req.Source)
+			} else {
+				req.Source = privacy.ScrubSourceTID(req.Source)
+			}
+			req.Imp = privacy.ScrubImpTIDs(req.Imp, control.RandomizeTIDs())
+			deniedBy[policy] = true
+		}
+	}
+	return
+}
+
+// accountID returns the publisher ID from the site or app. Accounts are identified by their publisher ID.
+func accountID(req *openrtb.BidRequest) string {
+	if req.Site != nil && req.Site.Publisher != nil {
+		return req.Site.Publisher.ID
+	}
+	if req.App != nil && req.App.Publisher != nil {
+		return req.App.Publisher.ID
+	}
+	return ""
+}
+
 // extractBuyerUIDs parses the values from user.ext.prebid.buyeruids, and then deletes those values from the ext.
 // This prevents a Bidder from using these values to figure out who else is involved in the Auction.
 func extractBuyerUIDs(user *openrtb.User) (map[string]string, error) {
@@ -101,6 +360,7 @@ func extractBuyerUIDs(user *openrtb.User) (map[string]string, error) {
 // The returned map will have three keys: rubicon, appnexus, and index--each with one Imp.
 // The "imp.ext" value of the appnexus Imp will only contain the "prebid" values, and "appnexus" value at the "bidder" key.
 // The "imp.ext" value of the rubicon Imp will only contain the "prebid" values, and "rubicon" value at the "bidder" key.
+// The bidders' params can be in either imp.ext.{bidder} or imp.ext.prebid.bidder.{bidder}.
 //
 // The goal here is so that Bidders only get Imps and Imp.Ext values which are intended for them.
 func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
@@ -113,14 +373,17 @@ func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
 	var errList []error
 	for i := 0; i < len(imps); i++ {
 		thisImp := imps[i]
-		theseBidders := impExts[i]
-		for intendedBidder := range theseBidders {
-			if intendedBidder == "prebid" {
-				continue
-			}
-
+		theseBidders, err := openrtb_ext.ImpBidderParams(json.RawMessage(thisImp.Ext))
+		if err != nil {
+			return nil, []error{fmt.Errorf("Error unpacking bidder params for Imp[%d]: %s", i, err.Error())}
+		}
+		// Each bidder gets its own params, so the others' are removed from the imp.ext.prebid which they all share.
+		if err := removeBidderParams(impExts[i]); err != nil {
+			return nil, []error{fmt.Errorf("Error unpacking extensions for Imp[%d]: %s", i, err.Error())}
+		}
+		for intendedBidder, params := range theseBidders {
 			otherImps, _ := splitImps[intendedBidder]
-			if impForBidder, err := sanitizedImpCopy(&thisImp, theseBidders, intendedBidder); err != nil {
+			if impForBidder, err := sanitizedImpCopy(&thisImp, impExts[i], params); err != nil {
 				errList = append(errList, err)
 			} else {
 				splitImps[intendedBidder] = append(otherImps, *impForBidder)
@@ -131,16 +394,46 @@ func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
 	return splitImps, nil
 }
 
-// sanitizedImpCopy returns a copy of imp with its ext filtered so that only "prebid" and intendedBidder exist.
-// It will not mutate the input imp.
+// removeBidderParams removes the "bidder" field from the imp's ext.prebid. If that leaves ext.prebid empty,
+// it's removed too, so that the bidders get the same imp.ext whichever place the params were in.
+func removeBidderParams(ext map[string]openrtb.RawJSON) error {
+	prebidExt, ok := ext["prebid"]
+	if !ok {
+		return nil
+	}
+	var prebid map[string]json.RawMessage
+	if err := json.Unmarshal(prebidExt, &prebid); err != nil {
+		return err
+	}
+	if _, ok := prebid["bidder"]; !ok {
+		return nil
+	}
+	delete(prebid, "bidder")
+	if len(prebid) == 0 {
+		delete(ext, "prebid")
+		return nil
+	}
+	trimmed, err := json.Marshal(prebid)
+	if err != nil {
+		return err
+	}
+	ext["prebid"] = trimmed
+	return nil
+}
+
+// sanitizedImpCopy returns a copy of imp with its ext filtered so that only "prebid", "tid" and the intended bidder's
+// params, under "bidder", exist. It will not mutate the input imp.
 // This function expects the "ext" argument to have been unmarshalled from "imp", so we don't have to repeat that work.
-func sanitizedImpCopy(imp *openrtb.Imp, ext map[string]openrtb.RawJSON, intendedBidder string) (*openrtb.Imp, error) {
+func sanitizedImpCopy(imp *openrtb.Imp, ext map[string]openrtb.RawJSON, params json.RawMessage) (*openrtb.Imp, error) {
 	impCopy := *imp
-	newExt := make(map[string]openrtb.RawJSON, 2)
+	newExt := make(map[string]openrtb.RawJSON, 3)
 	if value, ok := ext["prebid"]; ok {
 		newExt["prebid"] = value
 	}
-	newExt["bidder"] = ext[intendedBidder]
+	if value, ok := ext["tid"]; ok {
+		newExt["tid"] = value
+	}
+	newExt["bidder"] = openrtb.RawJSON(params)
 	extBytes, err := json.Marshal(newExt)
 	if err != nil {
 		return nil, err
@@ -191,7 +484,7 @@ func resolveBidder(bidder string, aliases map[string]string) openrtb_ext.BidderN
 }
 
 // parseImpExts does a partial-unmarshal of the imp[].Ext field.
-// The keys in the returned map are expected to be "prebid", core BidderNames, or Aliases for this request.
+// The keys in the returned map are expected to be "prebid", "tid", core BidderNames, or Aliases for this request.
 func parseImpExts(imps []openrtb.Imp) ([]map[string]openrtb.RawJSON, error) {
 	exts := make([]map[string]openrtb.RawJSON, len(imps))
 	// Loop over every impression in the request
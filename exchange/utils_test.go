@@ -1,9 +1,16 @@
 package exchange
 
 import (
+	"context"
 	"testing"
 
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 func TestRandomizeList(t *testing.T) {
@@ -26,3 +33,401 @@ func TestRandomizeList(t *testing.T) {
 	}
 
 }
+
+func TestEnforceGDPR(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Regs:   &openrtb.Regs{Ext: openrtb.RawJSON(`{"gdpr":1}`)},
+		User:   &openrtb.User{ID: "user-id", Ext: openrtb.RawJSON(`{"consent":"some-consent"}`)},
+		Device: &openrtb.Device{IP: "1.2.3.4", IFA: "some-ifa"},
+	}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus":   copyRequest(orig),
+		"rubicon":    copyRequest(orig),
+		"pubmatic":   copyRequest(orig),
+		"lifestreet": copyRequest(orig),
+	}
+	perms := &mockAuctionPermissions{
+		perms: map[openrtb_ext.BidderName]gdpr.AuctionPermissions{
+			"appnexus":   gdpr.AllowAll,
+			"rubicon":    {BidAllowed: true, PreciseGeoAllowed: true},
+			"pubmatic":   {BidAllowed: true, PersonalizationAllowed: true},
+			"lifestreet": {},
+		},
+	}
+
+	policy, errs := newGDPRPolicy(context.Background(), perms, orig, requests, nil, "")
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if deniedBy := enforcePolicy(policy, requests); !deniedBy["gdpr"] {
+		t.Fatalf("Expected the requests to be scrubbed by the GDPR policy. Got %v", deniedBy)
+	}
+	if _, ok := requests["lifestreet"]; ok {
+		t.Errorf("Bidders without purpose 2 permissions should not be called.")
+	}
+	if req := requests["appnexus"]; req.User.ID != "user-id" || req.Device.IP != "1.2.3.4" {
+		t.Errorf("Bidders with every permission should get the full request.")
+	}
+	if req := requests["rubicon"]; req.User.ID != "" || req.Device.IFA != "" || req.Device.IP != "1.2.3.4" {
+		t.Errorf("Bidders without personalization permissions should not get IDs. Got %#v, %#v", req.User, req.Device)
+	}
+	if req := requests["pubmatic"]; req.User.ID != "user-id" || req.Device.IP != "1.2.3.0" {
+		t.Errorf("Bidders without precise geo permissions should only get masked IPs. Got %#v, %#v", req.User, req.Device)
+	}
+	if orig.User.ID != "user-id" || orig.Device.IP != "1.2.3.4" {
+		t.Errorf("The original request should not be changed.")
+	}
+}
+
+func TestEnforceGDPRNotApplicable(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Regs: &openrtb.Regs{Ext: openrtb.RawJSON(`{"gdpr":0}`)},
+		User: &openrtb.User{ID: "user-id"},
+	}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus": copyRequest(orig),
+	}
+
+	policy, _ := newGDPRPolicy(context.Background(), &mockAuctionPermissions{}, orig, requests, nil, "1")
+	if policy != nil {
+		t.Errorf("Requests which GDPR doesn't apply to should not be changed, even if the user's location says it does.")
+	}
+}
+
+func TestEnforceGDPRByGeo(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		User: &openrtb.User{ID: "user-id"},
+	}
+	perms := &mockAuctionPermissions{
+		perms: map[openrtb_ext.BidderName]gdpr.AuctionPermissions{
+			"appnexus": {BidAllowed: true, PreciseGeoAllowed: true},
+		},
+	}
+
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{"appnexus": copyRequest(orig)}
+	if policy, _ := newGDPRPolicy(context.Background(), perms, orig, requests, nil, ""); policy != nil {
+		t.Errorf("Requests without a GDPR signal should not be scrubbed unless the user's location or the host's defaults say GDPR applies.")
+	}
+
+	policy, _ := newGDPRPolicy(context.Background(), perms, orig, requests, nil, "1")
+	if deniedBy := enforcePolicy(policy, requests); !deniedBy["gdpr"] || requests["appnexus"].User.ID != "" {
+		t.Errorf("Requests without a GDPR signal should be scrubbed if the user's location or the host's defaults say GDPR applies.")
+	}
+}
+
+func TestDefaultGDPRSignal(t *testing.T) {
+	gdprRule := &config.GeoPrivacyRule{Regime: config.RegimeGDPR}
+	if signal := defaultGDPRSignal(gdprRule, config.ChannelPrivacyDefaults{GDPR: "0"}); signal != "1" {
+		t.Errorf("The user's location should win over the host's defaults. Got %s", signal)
+	}
+	if signal := defaultGDPRSignal(nil, config.ChannelPrivacyDefaults{GDPR: "0"}); signal != "0" {
+		t.Errorf("The host's default should be used if the location doesn't match a GDPR rule. Got %s", signal)
+	}
+	if signal := defaultGDPRSignal(nil, config.ChannelPrivacyDefaults{}); signal != "" {
+		t.Errorf("The signal should stay unknown without a location or a default. Got %s", signal)
+	}
+}
+
+func TestEnforcePolicies(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		App:    &openrtb.App{Publisher: &openrtb.Publisher{ID: "some-account"}},
+		User:   &openrtb.User{ID: "user-id", Yob: 1980, Geo: &openrtb.Geo{Lat: 51.5}},
+		Device: &openrtb.Device{IFA: "some-ifa", IP: "1.2.3.4", Lmt: 1},
+	}
+	testCases := []struct {
+		description string
+		policy      privacy.Policy
+		preciseGeo  bool
+	}{
+		{"coppa", privacy.COPPAPolicy, false},
+		{"ccpa", privacy.NewCCPAPolicy(orig, true), true},
+		{"lmt", privacy.NewLMTPolicy(&config.LMT{Enforce: true}, orig), false},
+	}
+	for _, test := range testCases {
+		requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{"appnexus": copyRequest(orig)}
+		if deniedBy := enforcePolicy(test.policy, requests); !deniedBy[test.description] {
+			t.Errorf("%s: expected the requests to be scrubbed by the policy. Got %v", test.description, deniedBy)
+			continue
+		}
+		req := requests["appnexus"]
+		if req.User.ID != "" || req.User.Yob != 0 || req.Device.IFA != "" {
+			t.Errorf("%s: the user's IDs and demographics should be removed. Got %#v, %#v", test.description, req.User, req.Device)
+		}
+		if keptGeo := req.Device.IP == "1.2.3.4" && req.User.Geo.Lat == 51.5; keptGeo != test.preciseGeo {
+			t.Errorf("%s: expected precise geo %t. Got %#v, %#v", test.description, test.preciseGeo, req.User.Geo, req.Device)
+		}
+	}
+	if orig.User.ID != "user-id" || orig.Device.IP != "1.2.3.4" {
+		t.Errorf("The original request should not be changed.")
+	}
+}
+
+func TestPrivacyChannel(t *testing.T) {
+	if channel := privacyChannel(pbsmetrics.ReqTypeORTB2App); channel != config.ChannelApp {
+		t.Errorf("App requests should use the app defaults. Got %s", channel)
+	}
+	if channel := privacyChannel(pbsmetrics.ReqTypeAMP); channel != config.ChannelAMP {
+		t.Errorf("AMP requests should use the amp defaults. Got %s", channel)
+	}
+	if channel := privacyChannel(pbsmetrics.ReqTypeORTB2Web); channel != config.ChannelWeb {
+		t.Errorf("Web requests should use the web defaults. Got %s", channel)
+	}
+}
+
+func TestAddGPPSID(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Regs: &openrtb.Regs{COPPA: 1, Ext: openrtb.RawJSON(`{"gdpr":0}`)},
+	}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{"appnexus": copyRequest(orig)}
+	if err := addGPPSID(orig, requests, 8); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if regs := requests["appnexus"].Regs; regs.COPPA != 1 || string(regs.Ext) != `{"gdpr":0,"gpp_sid":[8]}` {
+		t.Errorf("The gpp_sid should be added to the existing regs. Got %#v", regs)
+	}
+	if string(orig.Regs.Ext) != `{"gdpr":0}` {
+		t.Errorf("The original request should not be changed.")
+	}
+
+	orig.Regs.Ext = openrtb.RawJSON(`{"gpp_sid":[7]}`)
+	requests = map[openrtb_ext.BidderName]*openrtb.BidRequest{"appnexus": copyRequest(orig)}
+	addGPPSID(orig, requests, 8)
+	if string(requests["appnexus"].Regs.Ext) != `{"gpp_sid":[7]}` {
+		t.Errorf("Existing gpp_sid values should not be replaced. Got %s", string(requests["appnexus"].Regs.Ext))
+	}
+}
+
+func TestEnforceActivities(t *testing.T) {
+	deny := false
+	cfg := &config.Activities{
+		Accounts: []config.AccountActivities{{
+			ID: "some-account",
+			Activities: config.ActivityControls{
+				FetchBids: config.Activity{
+					Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{BidderNames: []string{"pubmatic"}}}},
+				},
+				TransmitUFPD: config.Activity{
+					Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{GVLIDs: []int{52}}}},
+				},
+				TransmitTIDs: config.Activity{Default: &deny},
+			},
+		}},
+	}
+	orig := &openrtb.BidRequest{
+		Site:   &openrtb.Site{Publisher: &openrtb.Publisher{ID: "some-account"}},
+		User:   &openrtb.User{ID: "user-id"},
+		Source: &openrtb.Source{TID: "some-tid"},
+	}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus": copyRequest(orig),
+		"rubicon":  copyRequest(orig),
+		"pubmatic": copyRequest(orig),
+	}
+	vendorIDs := map[openrtb_ext.BidderName]uint16{
+		"appnexus": 32,
+		"rubicon":  52,
+	}
+
+	if deniedBy := enforceActivities(privacy.NewActivityControl(cfg, accountID(orig)), vendorIDs, requests, nil); !deniedBy[privacy.PolicyActivityRules] {
+		t.Fatalf("The requests should be scrubbed by the account's rules. Got %v", deniedBy)
+	}
+	if _, ok := requests["pubmatic"]; ok {
+		t.Errorf("Bidders which can't fetch bids should be removed.")
+	}
+	if requests["appnexus"].User.ID != "user-id" || requests["rubicon"].User.ID != "" {
+		t.Errorf("Only rubicon should lose the user's data. Got %#v, %#v", requests["appnexus"].User, requests["rubicon"].User)
+	}
+	if requests["appnexus"].Source.TID != "" || orig.Source.TID != "some-tid" {
+		t.Errorf("The TID should be removed from the bidder's copy of the request.")
+	}
+
+	orig.Site.Publisher.ID = "other-account"
+	requests = map[openrtb_ext.BidderName]*openrtb.BidRequest{"pubmatic": copyRequest(orig)}
+	if deniedBy := enforceActivities(privacy.NewActivityControl(cfg, accountID(orig)), vendorIDs, requests, nil); len(deniedBy) != 0 || len(requests) != 1 {
+		t.Errorf("Other accounts should not be affected.")
+	}
+}
+
+func TestEnforceActivitiesRandomizesTIDs(t *testing.T) {
+	deny := false
+	randomize := true
+	cfg := &config.Activities{
+		Host: config.ActivityControls{
+			TransmitTIDs:  config.Activity{Default: &deny},
+			RandomizeTIDs: &randomize,
+		},
+	}
+	orig := &openrtb.BidRequest{
+		Source: &openrtb.Source{TID: "some-tid"},
+		Imp:    []openrtb.Imp{{ID: "some-imp", Ext: openrtb.RawJSON(`{"tid":"some-imp-tid","bidder":{}}`)}},
+	}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus": copyRequest(orig),
+		"rubicon":  copyRequest(orig),
+	}
+
+	if deniedBy := enforceActivities(privacy.NewActivityControl(cfg, ""), nil, requests, nil); !deniedBy[privacy.PolicyActivityRules] {
+		t.Fatalf("The requests should be scrubbed. Got %v", deniedBy)
+	}
+	appnexusTID, rubiconTID := requests["appnexus"].Source.TID, requests["rubicon"].Source.TID
+	if appnexusTID == "" || appnexusTID == "some-tid" || appnexusTID == rubiconTID {
+		t.Errorf("Each bidder should get its own random TID. Got %s and %s", appnexusTID, rubiconTID)
+	}
+	if impTID, _ := jsonparser.GetString(requests["appnexus"].Imp[0].Ext, "tid"); impTID == "" || impTID == "some-imp-tid" {
+		t.Errorf("The imp's TID should be randomized. Got %s", impTID)
+	}
+	if impTID, _ := jsonparser.GetString(orig.Imp[0].Ext, "tid"); impTID != "some-imp-tid" {
+		t.Errorf("The original request should not be changed. Got %s", impTID)
+	}
+}
+
+func TestSkipUnmatchedBidders(t *testing.T) {
+	cfg := &config.BuyerUIDs{
+		SkipUnmatchedBidders: true,
+		SkipExceptions:       []string{"rubicon"},
+	}
+	orig := &openrtb.BidRequest{Site: &openrtb.Site{}}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus":  {User: &openrtb.User{BuyerUID: "appnexus-id"}},
+		"districtm": {},
+		"pubmatic":  {User: &openrtb.User{}},
+		"rubicon":   {},
+		"rubi2":     {},
+	}
+
+	skipUnmatchedBidders(cfg, orig, requests, map[string]string{"districtm": "appnexus", "rubi2": "rubicon"})
+	if _, ok := requests["appnexus"]; !ok {
+		t.Errorf("Bidders with a buyeruid should be called.")
+	}
+	if _, ok := requests["districtm"]; ok {
+		t.Errorf("Aliases without a buyeruid should be skipped.")
+	}
+	if _, ok := requests["pubmatic"]; ok {
+		t.Errorf("Bidders without a buyeruid should be skipped.")
+	}
+	if _, ok := requests["rubicon"]; !ok {
+		t.Errorf("Exceptions should always be called.")
+	}
+	if _, ok := requests["rubi2"]; !ok {
+		t.Errorf("Aliases of exceptions should always be called.")
+	}
+
+	requests = map[openrtb_ext.BidderName]*openrtb.BidRequest{"pubmatic": {}}
+	skipUnmatchedBidders(cfg, &openrtb.BidRequest{App: &openrtb.App{}}, requests, nil)
+	if len(requests) != 1 {
+		t.Errorf("Bidders should not be skipped on app requests.")
+	}
+}
+
+func TestDropDisabledBidders(t *testing.T) {
+	adapterMap := map[openrtb_ext.BidderName]adaptedBidder{
+		openrtb_ext.BidderRubicon: &panickingBidder{},
+	}
+	requests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		"appnexus": {},
+		"rubicon":  {},
+		"rubi2":    {},
+		"anx2":     {},
+	}
+
+	errs := dropDisabledBidders(adapterMap, requests, map[string]string{"rubi2": "rubicon", "anx2": "appnexus"})
+	if len(requests) != 2 || requests["rubicon"] == nil || requests["rubi2"] == nil {
+		t.Errorf("Only the disabled bidders and their aliases should be removed. Got %v", requests)
+	}
+	if len(errs) != 2 {
+		t.Errorf("Each removed bidder should get an error. Got %v", errs)
+	}
+}
+
+func TestCheckAdsTxt(t *testing.T) {
+	orig := &openrtb.BidRequest{Site: &openrtb.Site{Page: "https://www.example.com/article"}}
+	aliases := map[string]string{"rubi2": "rubicon"}
+	authorizer := &mockSellerAuthorizer{authorized: map[string]bool{"appnexus": true}}
+	newRequests := func() map[openrtb_ext.BidderName]*openrtb.BidRequest {
+		return map[openrtb_ext.BidderName]*openrtb.BidRequest{"appnexus": {}, "rubicon": {}, "rubi2": {}}
+	}
+
+	requests := newRequests()
+	errs, warnings := checkAdsTxt(authorizer, orig, requests, aliases)
+	if len(requests) != 3 || len(errs) != 0 {
+		t.Errorf("Bidders shouldn't be removed unless the authorizer blocks them. Got %v and %v", requests, errs)
+	}
+	if len(warnings) != 2 || warnings["rubicon"] == "" || warnings["rubi2"] == "" {
+		t.Errorf("The unauthorized bidders and their aliases should get warnings. Got %v", warnings)
+	}
+	if authorizer.domain != "www.example.com" {
+		t.Errorf("The domain should come from site.page. Got %s", authorizer.domain)
+	}
+
+	authorizer.blocks = true
+	requests = newRequests()
+	errs, warnings = checkAdsTxt(authorizer, orig, requests, aliases)
+	if len(requests) != 1 || requests["appnexus"] == nil || len(errs) != 2 || len(warnings) != 0 {
+		t.Errorf("The unauthorized bidders should be removed, with errors. Got %v, %v and %v", requests, errs, warnings)
+	}
+
+	requests = newRequests()
+	errs, warnings = checkAdsTxt(authorizer, &openrtb.BidRequest{App: &openrtb.App{Domain: "example.com"}}, requests, aliases)
+	if len(requests) != 3 || len(errs) != 0 || len(warnings) != 0 {
+		t.Errorf("Apps shouldn't be checked. Got %v, %v and %v", requests, errs, warnings)
+	}
+}
+
+func TestPublisherDomain(t *testing.T) {
+	tests := []struct {
+		site     *openrtb.Site
+		expected string
+	}{
+		{&openrtb.Site{Domain: "example.com", Page: "https://other.com/page"}, "example.com"},
+		{&openrtb.Site{Publisher: &openrtb.Publisher{Domain: "example.com"}, Page: "https://other.com/page"}, "example.com"},
+		{&openrtb.Site{Page: "https://example.com:8080/page?id=1"}, "example.com"},
+		{&openrtb.Site{Page: "%%invalid"}, ""},
+		{nil, ""},
+	}
+	for i, test := range tests {
+		if actual := publisherDomain(&openrtb.BidRequest{Site: test.site}); actual != test.expected {
+			t.Errorf("Test %d: expected the domain %s. Got %s", i, test.expected, actual)
+		}
+	}
+}
+
+// enforcePolicy applies a single policy to the requests, without any activity rules.
+func enforcePolicy(policy privacy.Policy, requests map[openrtb_ext.BidderName]*openrtb.BidRequest) map[string]bool {
+	return enforceActivities(privacy.ActivityControl{}.WithPolicies(policy), nil, requests, nil)
+}
+
+func copyRequest(req *openrtb.BidRequest) *openrtb.BidRequest {
+	clone := *req
+	return &clone
+}
+
+type mockAuctionPermissions struct {
+	perms map[openrtb_ext.BidderName]gdpr.AuctionPermissions
+}
+
+func (m *mockAuctionPermissions) HostCookiesAllowed(ctx context.Context, consent string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAuctionPermissions) BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAuctionPermissions) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (gdpr.AuctionPermissions, error) {
+	return m.perms[bidder], nil
+}
+
+type mockSellerAuthorizer struct {
+	authorized map[string]bool
+	blocks     bool
+	domain     string
+}
+
+func (m *mockSellerAuthorizer) Authorized(domain string, bidder string) bool {
+	m.domain = domain
+	return m.authorized[bidder]
+}
+
+func (m *mockSellerAuthorizer) Blocks() bool {
+	return m.blocks
+}
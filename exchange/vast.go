@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// addVASTWrappers fills in the adm of each video bid which only has a nurl, so that
+// players which fetch the bid's VAST from Prebid Cache have something to play.
+func addVASTWrappers(seatBid *pbsOrtbSeatBid) {
+	if seatBid == nil {
+		return
+	}
+	for _, bid := range seatBid.bids {
+		if bid.bidType == openrtb_ext.BidTypeVideo && bid.bid != nil && bid.bid.AdM == "" && bid.bid.NURL != "" {
+			bid.bid.AdM = makeVASTWrapper(bid.bid.ID, bid.bid.NURL)
+		}
+	}
+}
+
+// makeVASTWrapper returns a VAST 2.0 Wrapper which points the player at the tagURI.
+func makeVASTWrapper(adID string, tagURI string) string {
+	var buf bytes.Buffer
+	buf.WriteString(`<VAST version="2.0"><Ad id="`)
+	xml.EscapeText(&buf, []byte(adID))
+	buf.WriteString(`"><Wrapper><AdSystem>prebid.org wrapper</AdSystem><VASTAdTagURI><![CDATA[`)
+	// A CDATA section can't contain its own terminator, so it has to be split across two sections.
+	buf.WriteString(strings.Replace(tagURI, "]]>", "]]]]><![CDATA[>", -1))
+	buf.WriteString(`]]></VASTAdTagURI><Impression></Impression><Creatives></Creatives></Wrapper></Ad></VAST>`)
+	return buf.String()
+}
+
+// validateVAST checks the markup of the video and audio bids with checkVAST. The bids which fail are removed if the
+// level is config.BidValidationEnforce, and kept with a warning if it's config.BidValidationWarn. Bids without an adm
+// are skipped, since their VAST comes from their nurl.
+func (brw *bidResponseWrapper) validateVAST(level string) (rejected []error, warnings []error) {
+	if (level != config.BidValidationWarn && level != config.BidValidationEnforce) || brw.adapterBids == nil {
+		return
+	}
+	keptBids := make([]*pbsOrtbBid, 0, len(brw.adapterBids.bids))
+	for _, bid := range brw.adapterBids.bids {
+		if (bid.bidType == openrtb_ext.BidTypeVideo || bid.bidType == openrtb_ext.BidTypeAudio) && bid.bid.AdM != "" {
+			if err := checkVAST(bid.bid.AdM); err != nil {
+				if level == config.BidValidationEnforce {
+					rejected = append(rejected, fmt.Errorf("Bid \"%s\" rejected (%s): its VAST %v", bid.bid.ID, pbsmetrics.AdapterBidRejectedInvalidVAST, err))
+					continue
+				}
+				warnings = append(warnings, adapters.Warning(fmt.Sprintf("Bid \"%s\" may not play: its VAST %v", bid.bid.ID, err)))
+			}
+		}
+		keptBids = append(keptBids, bid)
+	}
+	if len(rejected) > 0 {
+		brw.adapterBids.bids = keptBids
+	}
+	return
+}
+
+// checkVAST returns an error if the markup isn't well-formed XML, or isn't a VAST document with at least one
+// MediaFile or Wrapper. Without either of those, the player has nothing to play.
+func checkVAST(adm string) error {
+	decoder := xml.NewDecoder(strings.NewReader(adm))
+	// Only the structure is checked, so the text doesn't need decoding from whatever charset the VAST declares.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	root := ""
+	playable := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("isn't well-formed XML: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if root == "" {
+				root = start.Name.Local
+				if root != "VAST" {
+					return fmt.Errorf("has the root element <%s>, rather than <VAST>", root)
+				}
+			}
+			if start.Name.Local == "MediaFile" || start.Name.Local == "Wrapper" {
+				playable = true
+			}
+		}
+	}
+	if root == "" {
+		return fmt.Errorf("has no XML elements")
+	}
+	if !playable {
+		return fmt.Errorf("has no <MediaFile> or <Wrapper>")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package exchange
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+func TestMakeVASTWrapper(t *testing.T) {
+	wrapper := makeVASTWrapper(`bid"1`, "http://example.com/win?a=1&b=]]>")
+
+	var parsed struct {
+		Ad struct {
+			ID      string `xml:"id,attr"`
+			Wrapper struct {
+				VASTAdTagURI string
+			}
+		}
+	}
+	if err := xml.Unmarshal([]byte(wrapper), &parsed); err != nil {
+		t.Fatalf("Generated VAST should be valid XML. Got error %v for %s", err, wrapper)
+	}
+	assertStringValue(t, "Ad id", `bid"1`, parsed.Ad.ID)
+	assertStringValue(t, "VASTAdTagURI", "http://example.com/win?a=1&b=]]>", parsed.Ad.Wrapper.VASTAdTagURI)
+}
+
+func TestAddVASTWrappers(t *testing.T) {
+	nurlOnly := &openrtb.Bid{ID: "nurl-only", NURL: "http://example.com/vast"}
+	withAdm := &openrtb.Bid{ID: "with-adm", NURL: "http://example.com/vast", AdM: "<VAST></VAST>"}
+	banner := &openrtb.Bid{ID: "banner", NURL: "http://example.com/banner"}
+	seatBid := &pbsOrtbSeatBid{
+		bids: []*pbsOrtbBid{
+			{bid: nurlOnly, bidType: openrtb_ext.BidTypeVideo},
+			{bid: withAdm, bidType: openrtb_ext.BidTypeVideo},
+			{bid: banner, bidType: openrtb_ext.BidTypeBanner},
+		},
+	}
+
+	addVASTWrappers(seatBid)
+	addVASTWrappers(nil)
+
+	assertStringValue(t, "nurl-only adm", makeVASTWrapper("nurl-only", "http://example.com/vast"), nurlOnly.AdM)
+	assertStringValue(t, "with-adm adm", "<VAST></VAST>", withAdm.AdM)
+	assertStringValue(t, "banner adm", "", banner.AdM)
+}
+
+func TestCheckVAST(t *testing.T) {
+	testCases := []struct {
+		description string
+		adm         string
+		valid       bool
+	}{
+		{"inline with a media file", `<?xml version="1.0" encoding="UTF-8"?><VAST version="3.0"><Ad><InLine><Creatives><Creative><Linear><MediaFiles><MediaFile><![CDATA[http://example.com/ad.mp4]]></MediaFile></MediaFiles></Linear></Creative></Creatives></InLine></Ad></VAST>`, true},
+		{"wrapper", makeVASTWrapper("bid-1", "http://example.com/vast"), true},
+		{"other charsets", `<?xml version="1.0" encoding="ISO-8859-1"?><VAST><Ad><Wrapper></Wrapper></Ad></VAST>`, true},
+		{"not well-formed", `<VAST><Ad><Wrapper></Ad></VAST>`, false},
+		{"unclosed", `<VAST><Ad><Wrapper></Wrapper></Ad>`, false},
+		{"not VAST", `<div><MediaFile></MediaFile></div>`, false},
+		{"empty VAST", `<VAST version="3.0"></VAST>`, false},
+		{"not XML", `http://example.com/vast`, false},
+	}
+	for _, test := range testCases {
+		if err := checkVAST(test.adm); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid to be %t. Got error %v", test.description, test.valid, err)
+		}
+	}
+}
+
+func TestValidateVAST(t *testing.T) {
+	makeWrapper := func() *bidResponseWrapper {
+		return &bidResponseWrapper{
+			adapterBids: &pbsOrtbSeatBid{
+				bids: []*pbsOrtbBid{
+					{bid: &openrtb.Bid{ID: "good", AdM: makeVASTWrapper("good", "http://example.com/vast")}, bidType: openrtb_ext.BidTypeVideo},
+					{bid: &openrtb.Bid{ID: "broken", AdM: "<VAST><Ad>"}, bidType: openrtb_ext.BidTypeVideo},
+					{bid: &openrtb.Bid{ID: "nurl-only", NURL: "http://example.com/vast"}, bidType: openrtb_ext.BidTypeVideo},
+					{bid: &openrtb.Bid{ID: "banner", AdM: "<div></div>"}, bidType: openrtb_ext.BidTypeBanner},
+				},
+			},
+		}
+	}
+
+	brw := makeWrapper()
+	if rejected, warnings := brw.validateVAST(config.BidValidationSkip); len(rejected) != 0 || len(warnings) != 0 {
+		t.Errorf("Bids shouldn't be checked when the level is skip. Got %v and %v", rejected, warnings)
+	}
+
+	rejected, warnings := brw.validateVAST(config.BidValidationWarn)
+	if len(rejected) != 0 || len(warnings) != 1 || len(brw.adapterBids.bids) != 4 {
+		t.Errorf("The broken bid should be kept with a warning. Got %v and %v, with %d bids left", rejected, warnings, len(brw.adapterBids.bids))
+	}
+	if _, ok := warnings[0].(*adapters.WarningError); !ok {
+		t.Errorf("The warning should be an adapters.WarningError. Got %T", warnings[0])
+	}
+
+	brw = makeWrapper()
+	rejected, warnings = brw.validateVAST(config.BidValidationEnforce)
+	if len(rejected) != 1 || len(warnings) != 0 {
+		t.Errorf("The broken bid should be rejected. Got %v and %v", rejected, warnings)
+	}
+	if len(brw.adapterBids.bids) != 3 {
+		t.Fatalf("Only the broken bid should be removed. Got %d bids", len(brw.adapterBids.bids))
+	}
+	for _, bid := range brw.adapterBids.bids {
+		if bid.bid.ID == "broken" {
+			t.Errorf("The broken bid should have been removed.")
+		}
+	}
+}
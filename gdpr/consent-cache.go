@@ -0,0 +1,110 @@
+package gdpr
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prebid/go-gdpr/vendorconsent"
+)
+
+// This file memoizes consent string parsing for Prebid Server.
+//
+// Publishers' CMPs generally produce one consent string per page view, which is then shared by
+// every auction and cookie sync on that page. Parsing it once saves the repeated work.
+//
+// Nothing in this file is exported. Public APIs can be found in gdpr.go
+
+// consentCache is a least-recently-used cache of parsed consent strings, whose entries expire after a TTL.
+// Strings which fail to parse are cached too, along with their error.
+//
+// All functions on this struct are nil-safe. A nil cache parses every string it's given.
+type consentCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	// recent orders the entries from most to least recently used.
+	recent *list.List
+	parse  func(consent string) (vendorconsent.VendorConsents, error)
+	now    func() time.Time
+}
+
+type consentCacheEntry struct {
+	consent string
+	parsed  vendorconsent.VendorConsents
+	err     error
+	expires time.Time
+}
+
+// newConsentCache makes a cache which holds up to size consent strings, for up to ttl each.
+// If size is 0, it returns nil, which parses every string. If ttl is 0, the entries don't expire.
+func newConsentCache(size int, ttl time.Duration) *consentCache {
+	if size <= 0 {
+		return nil
+	}
+	return &consentCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element, size),
+		recent:  list.New(),
+		parse:   vendorconsent.ParseString,
+		now:     time.Now,
+	}
+}
+
+// parseConsent returns the parsed consent string, using the cached copy if there is one.
+func (c *consentCache) parseConsent(consent string) (vendorconsent.VendorConsents, error) {
+	if c == nil {
+		return vendorconsent.ParseString(consent)
+	}
+	if entry, ok := c.get(consent); ok {
+		return entry.parsed, entry.err
+	}
+
+	// Parsing happens outside the lock. If two callers parse the same string at once, they'll get equal results.
+	parsed, err := c.parse(consent)
+	c.put(consent, parsed, err)
+	return parsed, err
+}
+
+func (c *consentCache) get(consent string) (*consentCacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[consent]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*consentCacheEntry)
+	if c.ttl > 0 && !c.now().Before(entry.expires) {
+		c.recent.Remove(element)
+		delete(c.entries, consent)
+		return nil, false
+	}
+	c.recent.MoveToFront(element)
+	return entry, true
+}
+
+func (c *consentCache) put(consent string, parsed vendorconsent.VendorConsents, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &consentCacheEntry{
+		consent: consent,
+		parsed:  parsed,
+		err:     err,
+		expires: c.now().Add(c.ttl),
+	}
+	if element, ok := c.entries[consent]; ok {
+		element.Value = entry
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.entries[consent] = c.recent.PushFront(entry)
+	for c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*consentCacheEntry).consent)
+	}
+}
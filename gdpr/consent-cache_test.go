@@ -0,0 +1,96 @@
+package gdpr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prebid/go-gdpr/vendorconsent"
+)
+
+// countingParser returns a parse function which fails on "bad", and counts how often each string was parsed.
+func countingParser(counts map[string]int) func(string) (vendorconsent.VendorConsents, error) {
+	return func(consent string) (vendorconsent.VendorConsents, error) {
+		counts[consent]++
+		if consent == "bad" {
+			return nil, errors.New("malformed")
+		}
+		return nil, nil
+	}
+}
+
+func newTestConsentCache(size int, ttl time.Duration, counts map[string]int, now *time.Time) *consentCache {
+	cache := newConsentCache(size, ttl)
+	cache.parse = countingParser(counts)
+	cache.now = func() time.Time {
+		return *now
+	}
+	return cache
+}
+
+func TestConsentCacheHits(t *testing.T) {
+	counts := make(map[string]int)
+	now := time.Now()
+	cache := newTestConsentCache(10, time.Minute, counts, &now)
+
+	for i := 0; i < 3; i++ {
+		cache.parseConsent("good")
+		if _, err := cache.parseConsent("bad"); err == nil {
+			t.Error("Cached parse errors should still be returned.")
+		}
+	}
+	if counts["good"] != 1 || counts["bad"] != 1 {
+		t.Errorf("Each consent string should only be parsed once. Got %v", counts)
+	}
+}
+
+func TestConsentCacheExpiry(t *testing.T) {
+	counts := make(map[string]int)
+	now := time.Now()
+	cache := newTestConsentCache(10, time.Minute, counts, &now)
+
+	cache.parseConsent("good")
+	now = now.Add(59 * time.Second)
+	cache.parseConsent("good")
+	if counts["good"] != 1 {
+		t.Errorf("The consent string should still be cached before its TTL. Parsed %d times", counts["good"])
+	}
+	now = now.Add(time.Second)
+	cache.parseConsent("good")
+	if counts["good"] != 2 {
+		t.Errorf("The consent string should be parsed again after its TTL. Parsed %d times", counts["good"])
+	}
+}
+
+func TestConsentCacheEviction(t *testing.T) {
+	counts := make(map[string]int)
+	now := time.Now()
+	cache := newTestConsentCache(2, 0, counts, &now)
+
+	cache.parseConsent("a")
+	cache.parseConsent("b")
+	cache.parseConsent("a") // "b" is now the least recently used.
+	cache.parseConsent("c")
+	cache.parseConsent("a")
+	cache.parseConsent("b")
+
+	if counts["a"] != 1 {
+		t.Errorf("The most recently used string should stay cached. Parsed %d times", counts["a"])
+	}
+	if counts["b"] != 2 {
+		t.Errorf("The least recently used string should be dropped for space. Parsed %d times", counts["b"])
+	}
+	if len(cache.entries) != 2 || cache.recent.Len() != 2 {
+		t.Errorf("The cache should hold 2 strings. Got %d entries and %d in the list", len(cache.entries), cache.recent.Len())
+	}
+}
+
+func TestDisabledConsentCache(t *testing.T) {
+	if cache := newConsentCache(0, time.Minute); cache != nil {
+		t.Errorf("A cache with size 0 should be nil. Got %v", cache)
+	}
+	var cache *consentCache
+	if _, err := cache.parseConsent("BON3PCUON3PCUABABBAAABoAAAAAMw"); err != nil {
+		t.Errorf("A nil cache should still parse consent strings. Got %v", err)
+	}
+}
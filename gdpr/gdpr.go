@@ -3,6 +3,7 @@ package gdpr
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
@@ -18,6 +19,31 @@ type Permissions interface {
 	//
 	// If the consent string was nonsenical, the returned error will be an ErrorMalformedConsent.
 	BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error)
+
+	// Determines which personal info the given bidder may receive in its bid request, using the
+	// purpose enforcement rules from the app config.
+	//
+	// If the consent string was nonsenical, the returned error will be an ErrorMalformedConsent.
+	// The returned permissions are still safe to use in that case, and will treat the user as if they consented to nothing.
+	AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (AuctionPermissions, error)
+}
+
+// AuctionPermissions describe what a bidder is allowed to do with the personal info in a bid request.
+type AuctionPermissions struct {
+	// BidAllowed is false if the bidder may not select basic ads for this user (purpose 2), and so shouldn't be called at all.
+	BidAllowed bool
+	// PersonalizationAllowed is false if the bidder may not build or use an ad profile for this user (purposes 3 and 4).
+	// If so, the user's IDs and demographics must be removed.
+	PersonalizationAllowed bool
+	// PreciseGeoAllowed is false if the bidder may not use precise geolocation data (special feature 1).
+	PreciseGeoAllowed bool
+}
+
+// AllowAll permits everything. It's used for bidders when GDPR doesn't apply, or isn't being enforced.
+var AllowAll = AuctionPermissions{
+	BidAllowed:             true,
+	PersonalizationAllowed: true,
+	PreciseGeoAllowed:      true,
 }
 
 // NewPermissions gets an instance of the Permissions for use elsewhere in the project.
@@ -31,9 +57,16 @@ func NewPermissions(ctx context.Context, cfg config.GDPR, vendorIDs map[openrtb_
 		cfg:             cfg,
 		vendorIDs:       vendorIDs,
 		fetchVendorList: newVendorListFetcher(ctx, cfg, client, vendorListURLMaker),
+		consents:        newConsentCache(cfg.ConsentCache.Size, cfg.ConsentCache.TTL()),
 	}
 }
 
+// VendorListFetchFailures returns the number of vendor list fetches in a row which have failed.
+// It's 0 if the last fetch succeeded, or if there haven't been any.
+func VendorListFetchFailures() int {
+	return int(atomic.LoadInt32(&vendorListFailures))
+}
+
 // An ErrorMalformedConsent will be returned by the Permissions interface if
 // the consent string argument was the reason for the failure.
 type ErrorMalformedConsent struct {
@@ -19,6 +19,7 @@ type permissionsImpl struct {
 	cfg             config.GDPR
 	vendorIDs       map[openrtb_ext.BidderName]uint16
 	fetchVendorList func(ctx context.Context, id uint16) (vendorlist.VendorList, error)
+	consents        *consentCache
 }
 
 func (p *permissionsImpl) HostCookiesAllowed(ctx context.Context, consent string) (bool, error) {
@@ -44,7 +45,7 @@ func (p *permissionsImpl) allowSync(ctx context.Context, vendorID uint16, consen
 		return p.cfg.UsersyncIfAmbiguous, nil
 	}
 
-	parsedConsent, err := vendorconsent.ParseString(consent)
+	parsedConsent, err := p.consents.parseConsent(consent)
 	if err != nil {
 		return false, &ErrorMalformedConsent{
 			consent: consent,
@@ -69,6 +70,79 @@ func (p *permissionsImpl) allowSync(ctx context.Context, vendorID uint16, consen
 	return false, nil
 }
 
+func (p *permissionsImpl) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (AuctionPermissions, error) {
+	var parsedConsent vendorconsent.VendorConsents
+	var vendor vendorlist.Vendor
+	vendorID, hasVendorID := p.vendorIDs[bidder]
+
+	if consent != "" {
+		var err error
+		if parsedConsent, err = p.consents.parseConsent(consent); err != nil {
+			return p.auctionPermissions(bidder, nil, 0, nil), &ErrorMalformedConsent{
+				consent: consent,
+				cause:   err,
+			}
+		}
+		if hasVendorID {
+			vendorList, err := p.fetchVendorList(ctx, parsedConsent.VendorListVersion())
+			if err != nil {
+				return p.auctionPermissions(bidder, nil, 0, nil), err
+			}
+			vendor = vendorList.Vendor(vendorID)
+		}
+	}
+
+	return p.auctionPermissions(bidder, parsedConsent, vendorID, vendor), nil
+}
+
+// auctionPermissions applies the purpose enforcement rules from the config. If consent is nil, the user is
+// treated as if they consented to nothing. If vendor is nil, the bidder is treated as if it declared no purposes.
+func (p *permissionsImpl) auctionPermissions(bidder openrtb_ext.BidderName, consent vendorconsent.VendorConsents, vendorID uint16, vendor vendorlist.Vendor) AuctionPermissions {
+	purposeAllowed := func(id int) bool {
+		return p.purposeAllowed(bidder, id, consent, vendorID, vendor)
+	}
+	return AuctionPermissions{
+		BidAllowed:             purposeAllowed(2),
+		PersonalizationAllowed: purposeAllowed(3) && purposeAllowed(4),
+		PreciseGeoAllowed:      p.preciseGeoAllowed(bidder),
+	}
+}
+
+func (p *permissionsImpl) purposeAllowed(bidder openrtb_ext.BidderName, id int, consent vendorconsent.VendorConsents, vendorID uint16, vendor vendorlist.Vendor) bool {
+	cfg := p.cfg.Purpose(id)
+	if cfg.Enforce == "" || cfg.Enforce == config.TCFEnforceOff || isVendorException(bidder, cfg.VendorExceptions) {
+		return true
+	}
+
+	purpose := consentconstants.Purpose(id)
+	if cfg.Enforce == config.TCFEnforceBasic {
+		return consent != nil && consent.PurposeAllowed(purpose)
+	}
+
+	if vendor == nil {
+		return false
+	}
+	if vendor.LegitimateInterest(purpose) {
+		return true
+	}
+	return consent != nil && vendor.Purpose(purpose) && consent.PurposeAllowed(purpose) && consent.VendorConsent(vendorID)
+}
+
+// preciseGeoAllowed checks special feature 1. Version 1 consent strings have no way for the user to opt in to
+// special features, so enforcing it blocks precise geo for every bidder except the vendor exceptions.
+func (p *permissionsImpl) preciseGeoAllowed(bidder openrtb_ext.BidderName) bool {
+	return !p.cfg.SpecialFeature1.Enforce || isVendorException(bidder, p.cfg.SpecialFeature1.VendorExceptions)
+}
+
+func isVendorException(bidder openrtb_ext.BidderName, exceptions []string) bool {
+	for _, exception := range exceptions {
+		if exception == string(bidder) {
+			return true
+		}
+	}
+	return false
+}
+
 type alwaysAllow struct{}
 
 func (a alwaysAllow) HostCookiesAllowed(ctx context.Context, consent string) (bool, error) {
@@ -78,3 +152,7 @@ func (a alwaysAllow) HostCookiesAllowed(ctx context.Context, consent string) (bo
 func (a alwaysAllow) BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
 	return true, nil
 }
+
+func (a alwaysAllow) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (AuctionPermissions, error) {
+	return AllowAll, nil
+}
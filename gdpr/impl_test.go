@@ -152,6 +152,122 @@ func TestMalformedConsent(t *testing.T) {
 	assertBoolsEqual(t, false, sync)
 }
 
+func TestAuctionPermissionsNotEnforced(t *testing.T) {
+	perms := permissionsImpl{
+		cfg: config.GDPR{
+			HostVendorID: 2,
+		},
+		fetchVendorList: failedListFetcher,
+	}
+	auctionPerms, err := perms.AuctionPermissions(context.Background(), openrtb_ext.BidderAppnexus, "")
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AllowAll, auctionPerms)
+}
+
+func TestAuctionPermissionsFullEnforcement(t *testing.T) {
+	vendorListData := mockVendorListData(t, 1, map[uint16]*purposes{
+		2: &purposes{
+			purposes: []uint8{1, 2, 3, 4},
+		},
+		3: &purposes{
+			purposes:            []uint8{3, 4},
+			legitimateInterests: []uint8{2},
+		},
+	})
+	perms := permissionsImpl{
+		cfg: config.GDPR{
+			HostVendorID: 2,
+			Purpose2:     config.TCFPurpose{Enforce: config.TCFEnforceFull},
+			Purpose3:     config.TCFPurpose{Enforce: config.TCFEnforceFull},
+			Purpose4:     config.TCFPurpose{Enforce: config.TCFEnforceFull},
+		},
+		vendorIDs: map[openrtb_ext.BidderName]uint16{
+			openrtb_ext.BidderAppnexus: 2,
+			openrtb_ext.BidderPubmatic: 3,
+		},
+		fetchVendorList: listFetcher(map[uint16]vendorlist.VendorList{
+			1: parseVendorListData(t, vendorListData),
+		}),
+	}
+
+	// Purposes 1-4 are allowed, but only vendor 2 has consent.
+	consent := "BON3PCUON3PCUABABBAAAB8AAAAAMg"
+	auctionPerms, err := perms.AuctionPermissions(context.Background(), openrtb_ext.BidderAppnexus, consent)
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AllowAll, auctionPerms)
+
+	auctionPerms, err = perms.AuctionPermissions(context.Background(), openrtb_ext.BidderPubmatic, consent)
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AuctionPermissions{BidAllowed: true, PreciseGeoAllowed: true}, auctionPerms)
+
+	auctionPerms, err = perms.AuctionPermissions(context.Background(), openrtb_ext.BidderLifestreet, consent)
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AuctionPermissions{PreciseGeoAllowed: true}, auctionPerms)
+}
+
+func TestAuctionPermissionsBasicEnforcement(t *testing.T) {
+	perms := permissionsImpl{
+		cfg: config.GDPR{
+			HostVendorID: 2,
+			Purpose2:     config.TCFPurpose{Enforce: config.TCFEnforceBasic},
+			Purpose3:     config.TCFPurpose{Enforce: config.TCFEnforceBasic},
+		},
+		vendorIDs:       map[openrtb_ext.BidderName]uint16{},
+		fetchVendorList: failedListFetcher,
+	}
+
+	// Purposes 1 and 2 are allowed. Basic enforcement doesn't care about vendors.
+	auctionPerms, err := perms.AuctionPermissions(context.Background(), openrtb_ext.BidderLifestreet, "BON3PCUON3PCUABABBAAABwAAAAAMw")
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AuctionPermissions{BidAllowed: true, PreciseGeoAllowed: true}, auctionPerms)
+}
+
+func TestAuctionPermissionsExceptions(t *testing.T) {
+	perms := permissionsImpl{
+		cfg: config.GDPR{
+			HostVendorID: 2,
+			Purpose2: config.TCFPurpose{
+				Enforce:          config.TCFEnforceFull,
+				VendorExceptions: []string{"appnexus"},
+			},
+			SpecialFeature1: config.TCFSpecialFeature{
+				Enforce:          true,
+				VendorExceptions: []string{"appnexus"},
+			},
+		},
+		fetchVendorList: failedListFetcher,
+	}
+
+	auctionPerms, err := perms.AuctionPermissions(context.Background(), openrtb_ext.BidderAppnexus, "")
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AllowAll, auctionPerms)
+
+	auctionPerms, err = perms.AuctionPermissions(context.Background(), openrtb_ext.BidderPubmatic, "")
+	assertNilErr(t, err)
+	assertAuctionPermissions(t, AuctionPermissions{PersonalizationAllowed: true}, auctionPerms)
+}
+
+func TestAuctionPermissionsMalformedConsent(t *testing.T) {
+	perms := permissionsImpl{
+		cfg: config.GDPR{
+			HostVendorID: 2,
+			Purpose2:     config.TCFPurpose{Enforce: config.TCFEnforceBasic},
+		},
+		fetchVendorList: listFetcher(nil),
+	}
+
+	auctionPerms, err := perms.AuctionPermissions(context.Background(), openrtb_ext.BidderAppnexus, "BON")
+	assertErr(t, err, true)
+	assertBoolsEqual(t, false, auctionPerms.BidAllowed)
+}
+
+func assertAuctionPermissions(t *testing.T, expected AuctionPermissions, actual AuctionPermissions) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+}
+
 func parseVendorListData(t *testing.T, data string) vendorlist.VendorList {
 	t.Helper()
 	parsed, err := vendorlist.ParseEagerly([]byte(data))
@@ -206,3 +322,10 @@ func assertStringsEqual(t *testing.T, expected string, actual string) {
 		t.Errorf("Expected %s, got %s", expected, actual)
 	}
 }
+
+func assertIntsEqual(t *testing.T, expected int, actual int) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("Expected %d, got %d", expected, actual)
+	}
+}
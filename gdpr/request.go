@@ -0,0 +1,32 @@
+package gdpr
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// RequestSignals reads the GDPR signal from regs.ext.gdpr and the consent string from user.ext.consent.
+//
+// The signal is "1" or "0" if the request says whether GDPR applies, and "" if it doesn't.
+// These match the gdpr and gdpr_consent query params used by the /setuid endpoint.
+func RequestSignals(req *openrtb.BidRequest) (signal string, consent string) {
+	if req == nil {
+		return "", ""
+	}
+	if req.Regs != nil && len(req.Regs.Ext) > 0 {
+		var regsExt openrtb_ext.ExtRegs
+		if err := json.Unmarshal(req.Regs.Ext, &regsExt); err == nil && regsExt.GDPR != nil {
+			signal = strconv.Itoa(int(*regsExt.GDPR))
+		}
+	}
+	if req.User != nil && len(req.User.Ext) > 0 {
+		var userExt openrtb_ext.ExtUser
+		if err := json.Unmarshal(req.User.Ext, &userExt); err == nil {
+			consent = userExt.Consent
+		}
+	}
+	return
+}
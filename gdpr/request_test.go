@@ -0,0 +1,30 @@
+package gdpr
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+func TestRequestSignals(t *testing.T) {
+	signal, consent := RequestSignals(&openrtb.BidRequest{
+		Regs: &openrtb.Regs{Ext: openrtb.RawJSON(`{"gdpr":1}`)},
+		User: &openrtb.User{Ext: openrtb.RawJSON(`{"consent":"BOONs2HOONs2HABABBENAGgAAAAPrABACGA"}`)},
+	})
+	if signal != "1" || consent != "BOONs2HOONs2HABABBENAGgAAAAPrABACGA" {
+		t.Errorf("Expected gdpr=1 with a consent string. Got %q and %q", signal, consent)
+	}
+}
+
+func TestRequestSignalsMissing(t *testing.T) {
+	signal, consent := RequestSignals(&openrtb.BidRequest{
+		Regs: &openrtb.Regs{Ext: openrtb.RawJSON(`{}`)},
+		User: &openrtb.User{},
+	})
+	if signal != "" || consent != "" {
+		t.Errorf("Missing signals should be empty. Got %q and %q", signal, consent)
+	}
+	if signal, consent := RequestSignals(nil); signal != "" || consent != "" {
+		t.Errorf("A nil request should have empty signals. Got %q and %q", signal, consent)
+	}
+}
@@ -5,14 +5,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/prebid/go-gdpr/vendorlist"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
 	"golang.org/x/net/context/ctxhttp"
 )
 
@@ -23,35 +25,48 @@ import (
 // Nothing in this file is exported. Public APIs can be found in gdpr.go
 
 func newVendorListFetcher(initCtx context.Context, cfg config.GDPR, client *http.Client, urlMaker func(uint16) string) func(ctx context.Context, id uint16) (vendorlist.VendorList, error) {
-	// These save and load functions can be used to store & retrieve lists from our cache.
-	save, load := newVendorListCache()
+	// This cache holds every version we've seen, and writes them to disk if cfg.VendorListCacheDir is set.
+	cache := newVendorListCache(cfg.VendorListCacheDir)
+	cache.loadDir()
 
 	withTimeout, cancel := context.WithTimeout(initCtx, cfg.Timeouts.InitTimeout())
 	defer cancel()
-	populateCache(withTimeout, client, urlMaker, save)
+	populateCache(withTimeout, client, urlMaker, cache)
 
-	saveOneSometimes := newOccasionalSaver(cfg.Timeouts.ActiveTimeout())
+	saveOneSometimes := newOccasionalSaver(cfg.Timeouts.ActiveTimeout(), vendorListFetchInterval)
+	// fellBack holds the versions which have been answered with the newest list, so each is only logged once.
+	var fellBack sync.Map
 
 	return func(ctx context.Context, id uint16) (vendorlist.VendorList, error) {
-		list := load(id)
+		list := cache.load(id)
 		if list != nil {
 			return list, nil
 		}
-		saveOneSometimes(ctx, client, urlMaker(id), save)
-		list = load(id)
+		saveOneSometimes(ctx, client, id, urlMaker(id), cache.save)
+		list = cache.load(id)
 		if list != nil {
 			return list, nil
 		}
+		// A list for a newer version is a better guess than nothing at all, since vendors are rarely removed.
+		if newest := cache.loadNewest(); newest != nil {
+			if _, logged := fellBack.LoadOrStore(id, true); !logged {
+				logger.Warningf("gdpr vendor list version %d hasn't been loaded. Using version %d until it is.", id, newest.Version())
+			}
+			return newest, nil
+		}
 		return nil, fmt.Errorf("gdpr vendor list version %d does not exist, or has not been loaded yet. Try again in a few minutes", id)
 	}
 }
 
 // populateCache saves all the known versions of the vendor list for future use.
-func populateCache(ctx context.Context, client *http.Client, urlMaker func(uint16) string, saver func(id uint16, list vendorlist.VendorList)) {
-	latestVersion := saveOne(ctx, client, urlMaker(0), saver)
+// Versions which are already in the cache (e.g. because they were loaded from disk) aren't fetched again.
+func populateCache(ctx context.Context, client *http.Client, urlMaker func(uint16) string, cache *vendorListCache) {
+	latestVersion := saveOne(ctx, client, urlMaker(0), cache.save)
 
 	for i := uint16(1); i < latestVersion; i++ {
-		saveOne(ctx, client, urlMaker(i), saver)
+		if cache.load(i) == nil {
+			saveOne(ctx, client, urlMaker(i), cache.save)
+		}
 	}
 }
 
@@ -64,72 +79,185 @@ func vendorListURLMaker(version uint16) string {
 	return "https://vendorlist.consensu.org/v-" + strconv.Itoa(int(version)) + "/vendorlist.json"
 }
 
-// newOccasionalSaver returns a wrapped version of saveOne() which only activates every few minutes.
+const (
+	// vendorListFetchInterval is the least time between two lazy fetches, whichever versions they're for.
+	vendorListFetchInterval = time.Minute
+	// vendorListRetryInterval is the least time between two lazy fetches of the same version.
+	vendorListRetryInterval = 10 * time.Minute
+)
+
+// newOccasionalSaver returns a wrapped version of saveOne() which fetches at most once every interval, and only
+// tries each version once every vendorListRetryInterval.
 //
 // The goal here is to update quickly when new versions of the VendorList are released, but not wreck
-// server performance if a bad CMP starts sending us malformed consent strings that advertize a version
-// that doesn't exist yet.
-func newOccasionalSaver(timeout time.Duration) func(ctx context.Context, client *http.Client, url string, saver func(id uint16, list vendorlist.VendorList)) {
-	lastSaved := &atomic.Value{}
-	lastSaved.Store(time.Time{})
+// server performance if a bad CMP starts sending us malformed consent strings that advertize versions
+// that don't exist yet. Since each of those may advertize a different version, the limit has to cover
+// all versions, not just each one. Concurrent calls for the same version share a single fetch.
+func newOccasionalSaver(timeout time.Duration, interval time.Duration) func(ctx context.Context, client *http.Client, id uint16, url string, saver vendorListSaver) {
+	var lock sync.Mutex
+	var lastFetch time.Time
+	lastAttempts := make(map[uint16]time.Time)
+	inFlight := make(map[uint16]*sync.WaitGroup)
 
-	return func(ctx context.Context, client *http.Client, url string, saver func(id uint16, list vendorlist.VendorList)) {
+	return func(ctx context.Context, client *http.Client, id uint16, url string, saver vendorListSaver) {
+		lock.Lock()
+		if wg, ok := inFlight[id]; ok {
+			lock.Unlock()
+			wg.Wait()
+			return
+		}
 		now := time.Now()
-		if now.Sub(lastSaved.Load().(time.Time)).Minutes() > 10 {
-			withTimeout, cancel := context.WithTimeout(ctx, timeout)
-			defer cancel()
-			saveOne(withTimeout, client, url, saver)
-			lastSaved.Store(now)
+		if now.Sub(lastFetch) < interval || now.Sub(lastAttempts[id]) < vendorListRetryInterval {
+			lock.Unlock()
+			return
 		}
+		lastFetch = now
+		lastAttempts[id] = now
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		inFlight[id] = wg
+		lock.Unlock()
+
+		withTimeout, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		saveOne(withTimeout, client, url, saver)
+
+		lock.Lock()
+		delete(inFlight, id)
+		lock.Unlock()
+		wg.Done()
 	}
 }
 
-func saveOne(ctx context.Context, client *http.Client, url string, saver func(id uint16, list vendorlist.VendorList)) uint16 {
+// vendorListSaver stores a parsed vendor list, along with the JSON it was parsed from.
+type vendorListSaver func(id uint16, list vendorlist.VendorList, data []byte)
+
+// vendorListFailures counts the fetches which have failed since the last one which succeeded.
+var vendorListFailures int32
+
+func saveOne(ctx context.Context, client *http.Client, url string, saver vendorListSaver) (version uint16) {
+	defer func() {
+		if version == 0 {
+			atomic.AddInt32(&vendorListFailures, 1)
+		} else {
+			atomic.StoreInt32(&vendorListFailures, 0)
+		}
+	}()
+
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		glog.Errorf("Failed to build GET %s request. Cookie syncs may be affected: %v", url, err)
+		logger.Errorf("Failed to build GET %s request. Cookie syncs may be affected: %v", url, err)
 		return 0
 	}
 
 	resp, err := ctxhttp.Do(ctx, client, req)
 	if err != nil {
-		glog.Errorf("Error calling GET %s. Cookie syncs may be affected: %v", url, err)
+		logger.Errorf("Error calling GET %s. Cookie syncs may be affected: %v", url, err)
 		return 0
 	}
 	defer resp.Body.Close()
 
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		glog.Errorf("Error reading response body from GET %s. Cookie syncs may be affected: %v", url, err)
+		logger.Errorf("Error reading response body from GET %s. Cookie syncs may be affected: %v", url, err)
 		return 0
 	}
 	if resp.StatusCode != http.StatusOK {
-		glog.Errorf("GET %s returned %d. Cookie syncs may be affected.", url, resp.StatusCode)
+		logger.Errorf("GET %s returned %d. Cookie syncs may be affected.", url, resp.StatusCode)
 		return 0
 	}
 
 	newList, err := vendorlist.ParseEagerly(respBody)
 	if err != nil {
-		glog.Errorf("GET %s returned malformed JSON. Cookie syncs may be affected. Error was %v. Body was %s", url, err, string(respBody))
+		logger.Errorf("GET %s returned malformed JSON. Cookie syncs may be affected. Error was %v. Body was %s", url, err, string(respBody))
 		return 0
 	}
 
-	saver(newList.Version(), newList)
+	saver(newList.Version(), newList, respBody)
 	return newList.Version()
 }
 
-func newVendorListCache() (save func(id uint16, list vendorlist.VendorList), load func(id uint16) vendorlist.VendorList) {
-	cache := &sync.Map{}
+// vendorListCache holds every version of the vendor list which we've loaded.
+// If dir isn't empty, the lists are also written there, so that they survive restarts.
+type vendorListCache struct {
+	lists  sync.Map
+	newest uint32
+	dir    string
+}
 
-	save = func(id uint16, list vendorlist.VendorList) {
-		cache.Store(id, list)
+func newVendorListCache(dir string) *vendorListCache {
+	return &vendorListCache{
+		dir: dir,
 	}
-	load = func(id uint16) vendorlist.VendorList {
-		list, ok := cache.Load(id)
-		if ok {
-			return list.(vendorlist.VendorList)
+}
+
+func (c *vendorListCache) save(id uint16, list vendorlist.VendorList, data []byte) {
+	c.store(id, list)
+	if c.dir == "" {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(c.dir, vendorListFileName(id)), data, 0644); err != nil {
+		logger.Errorf("Failed to write gdpr vendor list version %d to %s: %v", id, c.dir, err)
+	}
+}
+
+func (c *vendorListCache) store(id uint16, list vendorlist.VendorList) {
+	c.lists.Store(id, list)
+	for {
+		newest := atomic.LoadUint32(&c.newest)
+		if uint32(id) <= newest || atomic.CompareAndSwapUint32(&c.newest, newest, uint32(id)) {
+			return
 		}
+	}
+}
+
+func (c *vendorListCache) load(id uint16) vendorlist.VendorList {
+	list, ok := c.lists.Load(id)
+	if ok {
+		return list.(vendorlist.VendorList)
+	}
+	return nil
+}
+
+// loadNewest returns the newest version of the vendor list in the cache, or nil if it's empty.
+func (c *vendorListCache) loadNewest() vendorlist.VendorList {
+	newest := atomic.LoadUint32(&c.newest)
+	if newest == 0 {
 		return nil
 	}
-	return
+	return c.load(uint16(newest))
+}
+
+// loadDir loads the vendor lists which were written to disk by previous runs.
+func (c *vendorListCache) loadDir() {
+	if c.dir == "" {
+		return
+	}
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		logger.Errorf("Failed to read gdpr vendor lists from %s: %v", c.dir, err)
+		return
+	}
+	for _, file := range files {
+		if file.IsDir() || !vendorListFilePattern.MatchString(file.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(c.dir, file.Name()))
+		if err != nil {
+			logger.Errorf("Failed to read gdpr vendor list %s: %v", file.Name(), err)
+			continue
+		}
+		list, err := vendorlist.ParseEagerly(data)
+		if err != nil {
+			logger.Errorf("Ignoring malformed gdpr vendor list %s: %v", file.Name(), err)
+			continue
+		}
+		c.store(list.Version(), list)
+	}
+}
+
+var vendorListFilePattern = regexp.MustCompile(`^vendorlist-v[0-9]+\.json$`)
+
+func vendorListFileName(id uint16) string {
+	return "vendorlist-v" + strconv.Itoa(int(id)) + ".json"
 }
@@ -3,12 +3,17 @@ package gdpr
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prebid/go-gdpr/vendorlist"
 	"github.com/prebid/prebid-server/config"
 )
 
@@ -99,18 +104,142 @@ func TestFetchThrottling(t *testing.T) {
 			purposes: []uint8{1, 2},
 		},
 	})
-	server := httptest.NewServer(http.HandlerFunc(mockServer(1, map[int]string{
+	var hits int32
+	handler := mockServer(1, map[int]string{
 		1: "{}",
 		2: vendorListTwo,
 		3: vendorListThree,
-	})))
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("version") == "4" {
+			atomic.AddInt32(&hits, 1)
+		}
+		handler(w, req)
+	}))
 	defer server.Close()
 
 	fetcher := newVendorListFetcher(context.Background(), testConfig(), server.Client(), testURLMaker(server))
 	_, err := fetcher(context.Background(), 2)
 	assertNilErr(t, err)
-	_, err = fetcher(context.Background(), 3)
-	assertErr(t, err, false)
+	// The limit covers every version, so fetching 2 should stop us from fetching 3 or 4 for a while.
+	list, err := fetcher(context.Background(), 3)
+	assertNilErr(t, err)
+	assertIntsEqual(t, 2, int(list.Version()))
+
+	fetcher(context.Background(), 4)
+	assertIntsEqual(t, 0, int(atomic.LoadInt32(&hits)))
+}
+
+func TestOccasionalSaver(t *testing.T) {
+	hits := make(map[string]int)
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		hits[req.URL.Query().Get("version")]++
+		lock.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	urlMaker := testURLMaker(server)
+	saver := func(id uint16, list vendorlist.VendorList, data []byte) {}
+
+	saveOneSometimes := newOccasionalSaver(time.Second, 20*time.Millisecond)
+	for id := uint16(3); id < 10; id++ {
+		saveOneSometimes(context.Background(), server.Client(), id, urlMaker(id), saver)
+	}
+	if len(hits) != 1 || hits["3"] != 1 {
+		t.Errorf("Only one version should be fetched in each interval. Got %v", hits)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	saveOneSometimes(context.Background(), server.Client(), 3, urlMaker(3), saver)
+	saveOneSometimes(context.Background(), server.Client(), 4, urlMaker(4), saver)
+	if len(hits) != 2 || hits["3"] != 1 || hits["4"] != 1 {
+		t.Errorf("Each version should only be tried once every %v. Got %v", vendorListRetryInterval, hits)
+	}
+}
+
+func TestConcurrentFetchDedup(t *testing.T) {
+	vendorListTwo := mockVendorListData(t, 2, map[uint16]*purposes{
+		32: &purposes{
+			purposes: []uint8{1, 2},
+		},
+	})
+	var hits int32
+	handler := mockServer(1, map[int]string{
+		1: "{}",
+		2: vendorListTwo,
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("version") == "2" {
+			atomic.AddInt32(&hits, 1)
+			time.Sleep(50 * time.Millisecond)
+		}
+		handler(w, req)
+	}))
+	defer server.Close()
+
+	fetcher := newVendorListFetcher(context.Background(), testConfig(), server.Client(), testURLMaker(server))
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := fetcher(context.Background(), 2)
+			assertNilErr(t, err)
+		}()
+	}
+	wg.Wait()
+	assertIntsEqual(t, 1, int(atomic.LoadInt32(&hits)))
+}
+
+func TestFallbackToNewestVersion(t *testing.T) {
+	vendorListOne := mockVendorListData(t, 1, map[uint16]*purposes{
+		32: &purposes{
+			purposes: []uint8{1},
+		},
+	})
+	vendorListTwo := mockVendorListData(t, 2, map[uint16]*purposes{
+		32: &purposes{
+			purposes: []uint8{1, 2},
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(mockServer(2, map[int]string{
+		1: vendorListOne,
+		2: vendorListTwo,
+	})))
+	defer server.Close()
+
+	fetcher := newVendorListFetcher(context.Background(), testConfig(), server.Client(), testURLMaker(server))
+	list, err := fetcher(context.Background(), 5)
+	assertNilErr(t, err)
+	assertIntsEqual(t, 2, int(list.Version()))
+}
+
+func TestVendorListDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vendorlists")
+	assertNilErr(t, err)
+	defer os.RemoveAll(dir)
+
+	vendorListOne := mockVendorListData(t, 1, map[uint16]*purposes{
+		32: &purposes{
+			purposes: []uint8{1, 2},
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(mockServer(1, map[int]string{
+		1: vendorListOne,
+	})))
+
+	cfg := testConfig()
+	cfg.VendorListCacheDir = dir
+	newVendorListFetcher(context.Background(), cfg, server.Client(), testURLMaker(server))
+	server.Close()
+
+	// After a "restart", the list should come from disk, even though the server is gone.
+	fetcher := newVendorListFetcher(context.Background(), cfg, http.DefaultClient, testURLMaker(server))
+	list, err := fetcher(context.Background(), 1)
+	assertNilErr(t, err)
+	assertBoolsEqual(t, true, list.Vendor(32).Purpose(2))
 }
 
 func TestMalformedVendorlistFetch(t *testing.T) {
@@ -131,6 +260,25 @@ func TestMissingVendorlistFetch(t *testing.T) {
 	assertErr(t, err, false)
 }
 
+func TestVendorListFetchFailures(t *testing.T) {
+	list := mockVendorListData(t, 1, map[uint16]*purposes{
+		32: &purposes{
+			purposes: []uint8{1},
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(mockServer(1, map[int]string{1: list})))
+	defer server.Close()
+	saver := func(id uint16, list vendorlist.VendorList, data []byte) {}
+
+	saveOne(context.Background(), server.Client(), server.URL+"?version=1", saver)
+	saveOne(context.Background(), server.Client(), server.URL+"?version=2", saver)
+	saveOne(context.Background(), server.Client(), server.URL+"?version=3", saver)
+	assertIntsEqual(t, 2, VendorListFetchFailures())
+
+	saveOne(context.Background(), server.Client(), server.URL+"?version=1", saver)
+	assertIntsEqual(t, 0, VendorListFetchFailures())
+}
+
 func TestVendorListMaker(t *testing.T) {
 	assertStringsEqual(t, "https://vendorlist.consensu.org/vendorlist.json", vendorListURLMaker(0))
 	assertStringsEqual(t, "https://vendorlist.consensu.org/v-2/vendorlist.json", vendorListURLMaker(2))
@@ -172,8 +320,9 @@ func mockServer(latestVersion int, responses map[int]string) func(http.ResponseW
 
 func mockVendorListData(t *testing.T, version uint16, vendors map[uint16]*purposes) string {
 	type vendorContract struct {
-		ID       uint16  `json:"id"`
-		Purposes []uint8 `json:"purposeIds"`
+		ID                  uint16  `json:"id"`
+		Purposes            []uint8 `json:"purposeIds"`
+		LegitimateInterests []uint8 `json:"legIntPurposeIds"`
 	}
 
 	type vendorListContract struct {
@@ -185,8 +334,9 @@ func mockVendorListData(t *testing.T, version uint16, vendors map[uint16]*purpos
 		vendors := make([]vendorContract, 0, len(input))
 		for id, purpose := range input {
 			vendors = append(vendors, vendorContract{
-				ID:       id,
-				Purposes: purpose.purposes,
+				ID:                  id,
+				Purposes:            purpose.purposes,
+				LegitimateInterests: purpose.legitimateInterests,
 			})
 		}
 		return vendors
@@ -218,5 +368,6 @@ func testConfig() config.GDPR {
 }
 
 type purposes struct {
-	purposes []uint8
+	purposes            []uint8
+	legitimateInterests []uint8
 }
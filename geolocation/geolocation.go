@@ -0,0 +1,73 @@
+// Package geolocation fills in device.geo from the device's IP address. Many bidders bid poorly, or not at all,
+// on requests which don't say where the user is.
+package geolocation
+
+import (
+	"context"
+	"net"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+// locator finds where IP addresses are.
+type locator interface {
+	// Locate returns the location of the IP address, or nil if it's unknown.
+	Locate(ip net.IP) (*openrtb.Geo, error)
+}
+
+// ComponentName is the name which the activity controls know the Enricher by.
+const ComponentName = "geo_enrichment"
+
+// Enricher adds device.geo to the requests which don't have it.
+//
+// All functions on this struct are nil-safe. A nil Enricher leaves every request alone.
+type Enricher struct {
+	locator locator
+}
+
+// NewEnricher returns an Enricher which uses the database in cfg, or nil if enrichment is off.
+func NewEnricher(cfg *config.GeoEnrichment) (*Enricher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	locator, err := openMaxMind(cfg.DatabaseFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Enricher{locator: locator}, nil
+}
+
+// Enrich adds device.geo, with the country, region and metro of device.ip or device.ipv6, if the request doesn't
+// have it. The device is copied first, since the caller may share it with other requests.
+//
+// This happens before the privacy rules are enforced, so the added geo is scrubbed like the geo which the
+// requests bring with them. Nothing is added if the control doesn't allow the Enricher to enrich_ufpd.
+func (e *Enricher) Enrich(ctx context.Context, bidRequest *openrtb.BidRequest, control privacy.ActivityControl) {
+	if e == nil || bidRequest.Device == nil || bidRequest.Device.Geo != nil {
+		return
+	}
+	if !control.Allow(privacy.ActivityEnrichUFPD, privacy.Component{Name: ComponentName}) {
+		return
+	}
+	ip := net.ParseIP(bidRequest.Device.IP)
+	if ip == nil {
+		ip = net.ParseIP(bidRequest.Device.IPv6)
+	}
+	if ip == nil {
+		return
+	}
+	geo, err := e.locator.Locate(ip)
+	if err != nil {
+		logger.FromContext(ctx).Debugf("Failed to locate the device's IP address: %v", err)
+		return
+	}
+	if geo == nil {
+		return
+	}
+	device := *bidRequest.Device
+	device.Geo = geo
+	bidRequest.Device = &device
+}
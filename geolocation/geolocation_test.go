@@ -0,0 +1,99 @@
+package geolocation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+func TestEnrich(t *testing.T) {
+	enricher := &Enricher{locator: &fakeLocator{}}
+
+	device := &openrtb.Device{IP: "1.2.3.4"}
+	bidRequest := &openrtb.BidRequest{Device: device}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if bidRequest.Device.Geo == nil || bidRequest.Device.Geo.Country != "USA" {
+		t.Fatalf("device.geo should be added from device.ip. Got %v", bidRequest.Device.Geo)
+	}
+	if device.Geo != nil {
+		t.Errorf("The original device shouldn't be changed. Got %v", device.Geo)
+	}
+
+	bidRequest = &openrtb.BidRequest{Device: &openrtb.Device{IPv6: "2001:db8::1"}}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if bidRequest.Device.Geo == nil || bidRequest.Device.Geo.Country != "DEU" {
+		t.Errorf("device.geo should be added from device.ipv6. Got %v", bidRequest.Device.Geo)
+	}
+}
+
+func TestEnrichLeavesRequestsAlone(t *testing.T) {
+	enricher := &Enricher{locator: &fakeLocator{}}
+	tests := []struct {
+		description string
+		device      *openrtb.Device
+	}{
+		{"no device", nil},
+		{"existing geo", &openrtb.Device{IP: "1.2.3.4", Geo: &openrtb.Geo{Lat: 51.5, Lon: -0.12}}},
+		{"no IP", &openrtb.Device{UA: "some-ua"}},
+		{"invalid IP", &openrtb.Device{IP: "not-an-ip"}},
+		{"unknown IP", &openrtb.Device{IP: "10.0.0.1"}},
+		{"lookup error", &openrtb.Device{IP: "5.6.7.8"}},
+	}
+	for _, test := range tests {
+		bidRequest := &openrtb.BidRequest{Device: test.device}
+		enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+		if bidRequest.Device != test.device {
+			t.Errorf("%s: the device shouldn't be changed. Got %v", test.description, bidRequest.Device)
+		}
+	}
+}
+
+func TestEnrichActivities(t *testing.T) {
+	enricher := &Enricher{locator: &fakeLocator{}}
+	bidRequest := &openrtb.BidRequest{
+		Regs:   &openrtb.Regs{COPPA: 1},
+		Device: &openrtb.Device{IP: "1.2.3.4"},
+	}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{}.WithPolicies(privacy.NewCOPPAPolicy(bidRequest)))
+	if bidRequest.Device.Geo != nil {
+		t.Errorf("device.geo shouldn't be added when the Enricher can't enrich_ufpd. Got %v", bidRequest.Device.Geo)
+	}
+}
+
+func TestNilEnricher(t *testing.T) {
+	enricher, err := NewEnricher(&config.GeoEnrichment{DatabaseFile: "GeoLite2-City.mmdb"})
+	if enricher != nil || err != nil {
+		t.Fatalf("A disabled Enricher should be nil. Got %v, %v", enricher, err)
+	}
+	bidRequest := &openrtb.BidRequest{Device: &openrtb.Device{IP: "1.2.3.4"}}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if bidRequest.Device.Geo != nil {
+		t.Errorf("A nil Enricher shouldn't change the request. Got %v", bidRequest.Device.Geo)
+	}
+}
+
+func TestNewEnricherMissingDatabase(t *testing.T) {
+	if _, err := NewEnricher(&config.GeoEnrichment{Enabled: true, DatabaseFile: "does-not-exist.mmdb"}); err == nil {
+		t.Errorf("A missing database should be an error.")
+	}
+}
+
+// fakeLocator knows where a few addresses are, and fails to look up 5.6.7.8.
+type fakeLocator struct{}
+
+func (l *fakeLocator) Locate(ip net.IP) (*openrtb.Geo, error) {
+	switch ip.String() {
+	case "1.2.3.4":
+		return newGeo("US", "CA", 807), nil
+	case "2001:db8::1":
+		return newGeo("DE", "BY", 0), nil
+	case "5.6.7.8":
+		return nil, errors.New("the database is corrupt")
+	}
+	return nil, nil
+}
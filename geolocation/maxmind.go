@@ -0,0 +1,61 @@
+package geolocation
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/oschwald/geoip2-golang"
+)
+
+const (
+	// geoTypeIP is OpenRTB's location type for locations which come from an IP address.
+	geoTypeIP = 2
+	// ipServiceMaxMind is OpenRTB's IP location service code for MaxMind.
+	ipServiceMaxMind = 3
+)
+
+// maxMindLocator looks up IP addresses in a MaxMind GeoIP2 or GeoLite2 City database.
+type maxMindLocator struct {
+	reader *geoip2.Reader
+}
+
+// openMaxMind opens the database. A new database is only picked up when the server restarts.
+func openMaxMind(file string) (*maxMindLocator, error) {
+	reader, err := geoip2.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return &maxMindLocator{reader: reader}, nil
+}
+
+func (l *maxMindLocator) Locate(ip net.IP) (*openrtb.Geo, error) {
+	city, err := l.reader.City(ip)
+	if err != nil {
+		return nil, err
+	}
+	var region string
+	if len(city.Subdivisions) > 0 {
+		region = city.Subdivisions[0].IsoCode
+	}
+	return newGeo(city.Country.IsoCode, region, city.Location.MetroCode), nil
+}
+
+// newGeo returns the location, or nil if the country isn't known. The country is an ISO-3166-1 alpha-2 code,
+// and the region an ISO-3166-2 subdivision code. The metro is a Nielsen DMA code, or 0 if there isn't one.
+func newGeo(countryCode string, region string, metroCode uint) *openrtb.Geo {
+	country, ok := alpha3Codes[countryCode]
+	if !ok {
+		return nil
+	}
+	geo := &openrtb.Geo{
+		Type:      geoTypeIP,
+		IPService: ipServiceMaxMind,
+		Country:   country,
+		Region:    region,
+	}
+	if metroCode != 0 {
+		geo.Metro = strconv.FormatUint(uint64(metroCode), 10)
+	}
+	return geo
+}
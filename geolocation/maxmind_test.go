@@ -0,0 +1,25 @@
+package geolocation
+
+import (
+	"testing"
+)
+
+func TestNewGeo(t *testing.T) {
+	geo := newGeo("US", "CA", 807)
+	if geo == nil {
+		t.Fatalf("Known countries should have a geo.")
+	}
+	if geo.Country != "USA" || geo.Region != "CA" || geo.Metro != "807" {
+		t.Errorf("Expected USA, CA and 807. Got %s, %s and %s", geo.Country, geo.Region, geo.Metro)
+	}
+	if geo.Type != geoTypeIP || geo.IPService != ipServiceMaxMind {
+		t.Errorf("The geo should say it came from MaxMind's IP lookup. Got type %d and ipservice %d", geo.Type, geo.IPService)
+	}
+
+	if geo := newGeo("GB", "", 0); geo == nil || geo.Country != "GBR" || geo.Metro != "" {
+		t.Errorf("Locations outside of a metro shouldn't have one. Got %v", geo)
+	}
+	if geo := newGeo("", "", 0); geo != nil {
+		t.Errorf("Locations without a country should be unknown. Got %v", geo)
+	}
+}
@@ -0,0 +1,222 @@
+// Package ivt looks for invalid traffic (IVT), such as bots and crawlers, before it's auctioned. Bidders pay for the
+// impressions they win whether or not a person saw them, so every auction run for a bot spends their budgets for nothing.
+package ivt
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+// Check is one of the Filter's tests for invalid traffic. It returns why the request looks invalid,
+// or an empty string if it doesn't. Checks are run concurrently, so they must be safe for that.
+type Check interface {
+	Check(req *openrtb.BidRequest) string
+}
+
+// Filter runs its Checks on each request, and says what should happen to the suspect ones.
+//
+// All functions on this struct are nil-safe. A nil Filter passes every request.
+type Filter struct {
+	checks []Check
+	drop   bool
+}
+
+// NewFilter returns a Filter with the checks in cfg, followed by the extra checks, or nil if the filter is off.
+// It returns an error if the datacenter ranges file can't be read.
+func NewFilter(cfg *config.InvalidTraffic, extra ...Check) (*Filter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	f := &Filter{drop: cfg.Action == config.InvalidTrafficDrop}
+	if len(cfg.UserAgents) > 0 {
+		check, err := newUserAgentCheck(cfg.UserAgents)
+		if err != nil {
+			return nil, err
+		}
+		f.checks = append(f.checks, check)
+	}
+	ranges := cfg.DatacenterRanges
+	if cfg.DatacenterRangesFile != "" {
+		fileRanges, err := readRanges(cfg.DatacenterRangesFile)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(append([]string(nil), ranges...), fileRanges...)
+	}
+	if len(ranges) > 0 {
+		check, err := newDatacenterCheck(ranges)
+		if err != nil {
+			return nil, err
+		}
+		f.checks = append(f.checks, check)
+	}
+	if cfg.MaxRequestsPerIP > 0 {
+		f.checks = append(f.checks, newVelocityCheck(cfg.MaxRequestsPerIP, time.Duration(cfg.WindowSeconds)*time.Second, cfg.MaxTrackedIPs))
+	}
+	f.checks = append(f.checks, extra...)
+	return f, nil
+}
+
+// Drops returns true if the suspect requests shouldn't be auctioned.
+func (f *Filter) Drops() bool {
+	return f != nil && f.drop
+}
+
+// Check returns why the request looks like invalid traffic, from the first check which failed.
+// It's empty if the request passed them all.
+func (f *Filter) Check(req *openrtb.BidRequest) string {
+	if f == nil {
+		return ""
+	}
+	for _, check := range f.checks {
+		if reason := check.Check(req); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// userAgentCheck fails the requests whose device.ua matches one of its patterns.
+type userAgentCheck struct {
+	patterns []*regexp.Regexp
+}
+
+func newUserAgentCheck(patterns []string) (*userAgentCheck, error) {
+	check := &userAgentCheck{patterns: make([]*regexp.Regexp, 0, len(patterns))}
+	for _, pattern := range patterns {
+		compiled, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, err
+		}
+		check.patterns = append(check.patterns, compiled)
+	}
+	return check, nil
+}
+
+func (c *userAgentCheck) Check(req *openrtb.BidRequest) string {
+	if req.Device == nil || req.Device.UA == "" {
+		return ""
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(req.Device.UA) {
+			return fmt.Sprintf("The user agent matches the bot pattern %q.", pattern.String()[len("(?i)"):])
+		}
+	}
+	return ""
+}
+
+// datacenterCheck fails the requests whose device.ip or device.ipv6 is in one of its ranges.
+type datacenterCheck struct {
+	ranges []*net.IPNet
+}
+
+func newDatacenterCheck(cidrs []string) (*datacenterCheck, error) {
+	check := &datacenterCheck{ranges: make([]*net.IPNet, 0, len(cidrs))}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		check.ranges = append(check.ranges, ipNet)
+	}
+	return check, nil
+}
+
+func (c *datacenterCheck) Check(req *openrtb.BidRequest) string {
+	if req.Device == nil {
+		return ""
+	}
+	for _, address := range []string{req.Device.IP, req.Device.IPv6} {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+		for _, ipNet := range c.ranges {
+			if ipNet.Contains(ip) {
+				return fmt.Sprintf("The IP is in the datacenter range %s.", ipNet)
+			}
+		}
+	}
+	return ""
+}
+
+// readRanges returns the CIDR ranges in the file, one per line. Blank lines and lines starting with # are skipped.
+func readRanges(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ranges []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ranges = append(ranges, line)
+	}
+	return ranges, scanner.Err()
+}
+
+// velocityCheck fails the requests from the IPs which have sent more than max requests in the current window.
+// The counts start again from zero in each window.
+type velocityCheck struct {
+	max      int
+	window   time.Duration
+	maxIPs   int
+	now      func() time.Time
+	lock     sync.Mutex
+	started  time.Time
+	requests map[string]int
+}
+
+func newVelocityCheck(max int, window time.Duration, maxIPs int) *velocityCheck {
+	return &velocityCheck{
+		max:      max,
+		window:   window,
+		maxIPs:   maxIPs,
+		now:      time.Now,
+		requests: make(map[string]int),
+	}
+}
+
+func (c *velocityCheck) Check(req *openrtb.BidRequest) string {
+	if req.Device == nil {
+		return ""
+	}
+	ip := req.Device.IP
+	if ip == "" {
+		ip = req.Device.IPv6
+	}
+	if ip == "" {
+		return ""
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if now := c.now(); now.Sub(c.started) >= c.window {
+		c.started = now
+		c.requests = make(map[string]int, len(c.requests))
+	}
+	count, ok := c.requests[ip]
+	if !ok && len(c.requests) >= c.maxIPs {
+		return ""
+	}
+	count++
+	c.requests[ip] = count
+	if count > c.max {
+		return fmt.Sprintf("The IP has sent more than %d requests in %v.", c.max, c.window)
+	}
+	return ""
+}
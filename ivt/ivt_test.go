@@ -0,0 +1,130 @@
+package ivt
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := NewFilter(&config.InvalidTraffic{
+		Enabled:          true,
+		Action:           config.InvalidTrafficDrop,
+		UserAgents:       []string{"bot", "^curl/"},
+		DatacenterRanges: []string{"203.0.113.0/24", "2001:db8::/32"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !f.Drops() {
+		t.Errorf("The filter should drop suspect requests when the action is %q.", config.InvalidTrafficDrop)
+	}
+
+	testCases := map[string]struct {
+		device  *openrtb.Device
+		suspect bool
+	}{
+		"bot user agent":      {&openrtb.Device{UA: "Mozilla/5.0 (compatible; Googlebot/2.1)"}, true},
+		"anchored user agent": {&openrtb.Device{UA: "curl/7.58.0"}, true},
+		"datacenter IP":       {&openrtb.Device{IP: "203.0.113.7"}, true},
+		"datacenter IPv6":     {&openrtb.Device{IPv6: "2001:db8::1"}, true},
+		"person":              {&openrtb.Device{UA: "Mozilla/5.0 (iPhone) curl/1", IP: "198.51.100.7"}, false},
+		"unparseable IP":      {&openrtb.Device{IP: "unknown"}, false},
+		"no device":           {nil, false},
+	}
+	for name, test := range testCases {
+		reason := f.Check(&openrtb.BidRequest{Device: test.device})
+		if test.suspect && reason == "" {
+			t.Errorf("%s: the request should be suspect.", name)
+		}
+		if !test.suspect && reason != "" {
+			t.Errorf("%s: the request shouldn't be suspect. Got %q", name, reason)
+		}
+	}
+
+	var nilFilter *Filter
+	if reason := nilFilter.Check(&openrtb.BidRequest{Device: &openrtb.Device{UA: "bot"}}); reason != "" || nilFilter.Drops() {
+		t.Errorf("A nil Filter should pass every request. Got %q", reason)
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	file, err := ioutil.TempFile("", "datacenters")
+	if err != nil {
+		t.Fatalf("Failed to make the ranges file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("# Example hosting provider\n203.0.113.0/24\n\n198.51.100.0/24\n")
+	file.Close()
+
+	f, err := NewFilter(&config.InvalidTraffic{Enabled: true, Action: config.InvalidTrafficFlag, DatacenterRangesFile: file.Name()}, &deviceCheck{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.Drops() {
+		t.Errorf("The filter shouldn't drop suspect requests when the action is %q.", config.InvalidTrafficFlag)
+	}
+	if reason := f.Check(&openrtb.BidRequest{Device: &openrtb.Device{IP: "198.51.100.7"}}); !strings.Contains(reason, "198.51.100.0/24") {
+		t.Errorf("The ranges in the file should be checked. Got %q", reason)
+	}
+	if reason := f.Check(&openrtb.BidRequest{}); reason != "no device" {
+		t.Errorf("The extra checks should run after the configured ones. Got %q", reason)
+	}
+
+	if f, err := NewFilter(&config.InvalidTraffic{UserAgents: []string{"bot"}}); f != nil || err != nil {
+		t.Errorf("A disabled filter should be nil. Got %v, %v", f, err)
+	}
+	if _, err := NewFilter(&config.InvalidTraffic{Enabled: true, DatacenterRangesFile: file.Name() + ".missing"}); err == nil {
+		t.Errorf("A ranges file which can't be read should return an error.")
+	}
+}
+
+func TestVelocityCheck(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newVelocityCheck(2, time.Minute, 2)
+	c.now = func() time.Time {
+		return now
+	}
+	check := func(ip string) string {
+		return c.Check(&openrtb.BidRequest{Device: &openrtb.Device{IP: ip}})
+	}
+
+	for i := 0; i < 2; i++ {
+		if reason := check("198.51.100.1"); reason != "" {
+			t.Errorf("Request %d should be under the limit. Got %q", i+1, reason)
+		}
+	}
+	if reason := check("198.51.100.1"); reason == "" {
+		t.Errorf("The third request in the window should be over the limit.")
+	}
+	if reason := check("198.51.100.2"); reason != "" {
+		t.Errorf("Each IP should have its own limit. Got %q", reason)
+	}
+
+	// The map is full, so new IPs go uncounted.
+	for i := 0; i < 3; i++ {
+		if reason := check("198.51.100.3"); reason != "" {
+			t.Errorf("IPs over max_tracked_ips shouldn't be counted. Got %q", reason)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	if reason := check("198.51.100.1"); reason != "" {
+		t.Errorf("The counts should start again in the next window. Got %q", reason)
+	}
+}
+
+// deviceCheck fails the requests without a device.
+type deviceCheck struct{}
+
+func (c *deviceCheck) Check(req *openrtb.BidRequest) string {
+	if req.Device == nil {
+		return "no device"
+	}
+	return ""
+}
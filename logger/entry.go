@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type entry struct {
+	time   time.Time
+	level  Level
+	module string
+	caller string
+	msg    string
+	fields []field
+}
+
+// json encodes the entry as a single line JSON object. The standard keys come first, in a fixed order,
+// followed by the fields in the order they were added.
+func (e *entry) json() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, e.time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, e.level.String())
+	if e.module != "" {
+		buf.WriteString(`,"module":`)
+		writeJSON(&buf, e.module)
+	}
+	if e.caller != "" {
+		buf.WriteString(`,"caller":`)
+		writeJSON(&buf, e.caller)
+	}
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, e.msg)
+	for _, f := range e.fields {
+		buf.WriteByte(',')
+		writeJSON(&buf, f.key)
+		buf.WriteByte(':')
+		writeJSON(&buf, f.value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	buf.Write(encoded)
+}
+
+// console encodes the entry as a line of text, like:
+//
+//   2018-10-16T15:04:05.123Z WARNING exchange exchange.go:123 Some message account=1001 bidder=appnexus
+func (e *entry) console() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(e.time.Format("2006-01-02T15:04:05.000Z07:00"))
+	buf.WriteByte(' ')
+	buf.WriteString(strings.ToUpper(e.level.String()))
+	if e.module != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(e.module)
+	}
+	if e.caller != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(e.caller)
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(e.msg)
+	for _, f := range e.fields {
+		fmt.Fprintf(&buf, " %s=%v", f.key, f.value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
@@ -0,0 +1,275 @@
+// Package logger writes structured log entries. Each entry records its level, the module (package) which
+// logged it, and any fields attached to the Logger, such as the account, bidder or trace ID.
+//
+// The package-level functions log without any fields. Use With or FromContext to attach some.
+// Until Setup is called, entries at info level and above are written to stderr in the console format.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+	LevelFatal
+)
+
+var levelNames = []string{"debug", "info", "warning", "error", "fatal"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelFatal {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the Level with the given name, or LevelInfo if the name is empty.
+// Fatal entries are always written, so "fatal" isn't allowed.
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return LevelInfo, nil
+	}
+	for i, levelName := range levelNames[:LevelFatal] {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level \"%s\". Must be one of %s", name, strings.Join(levelNames[:LevelFatal], ", "))
+}
+
+const (
+	// FormatJSON writes each entry as a JSON object on its own line.
+	FormatJSON = "json"
+	// FormatConsole writes each entry as a line of text, with the fields as key=value pairs at the end.
+	FormatConsole = "console"
+)
+
+// LeveledWriter is implemented by outputs which record the severity of each entry separately from its text,
+// such as syslog. WriteLevel is used instead of Write.
+type LeveledWriter interface {
+	io.Writer
+	WriteLevel(level Level, entry []byte) error
+}
+
+type sink struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	format string
+	level  Level
+}
+
+var std = &sink{
+	out:    os.Stderr,
+	format: FormatConsole,
+	level:  LevelInfo,
+}
+
+// exit is replaced in tests, so that Fatal entries can be checked.
+var exit = os.Exit
+
+// Setup sets the least severe level which gets logged, the format of the entries, and where they're written to.
+func Setup(level Level, format string, out io.Writer) {
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	std.level = level
+	std.format = format
+	std.out = out
+}
+
+// Enabled returns true if entries at the given level are being logged.
+func Enabled(level Level) bool {
+	std.mutex.Lock()
+	defer std.mutex.Unlock()
+	return level >= std.level
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Logger writes entries with a fixed set of fields. The zero value has no fields.
+// Loggers are immutable, so they can be shared between goroutines.
+type Logger struct {
+	fields []field
+}
+
+var root = &Logger{}
+
+// With returns a Logger which adds the given field to every entry.
+func With(key string, value interface{}) *Logger {
+	return root.With(key, value)
+}
+
+// FromContext returns a Logger which adds the ID of the trace in ctx to every entry, if there is one.
+func FromContext(ctx context.Context) *Logger {
+	return root.WithContext(ctx)
+}
+
+// With returns a copy of l which also adds the given field to every entry.
+func (l *Logger) With(key string, value interface{}) *Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return &Logger{fields: append(fields, field{key, value})}
+}
+
+// WithContext returns a copy of l which also adds the ID of the trace in ctx to every entry, if there is one.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	if traceID, ok := ctx.Value(traceIDKey{}).(string); ok && traceID != "" {
+		return l.With("trace_id", traceID)
+	}
+	return l
+}
+
+type traceIDKey struct{}
+
+// WithTraceID returns a context whose Loggers add the given trace ID to every entry.
+// The tracing package calls it for each span, so that log entries can be matched up with traces.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.output(LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	l.output(LevelInfo, fmt.Sprint(args...))
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.output(LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warning(args ...interface{}) {
+	l.output(LevelWarning, fmt.Sprint(args...))
+}
+
+func (l *Logger) Warningf(format string, args ...interface{}) {
+	l.output(LevelWarning, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	l.output(LevelError, fmt.Sprint(args...))
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.output(LevelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs the entry and then exits the process.
+func (l *Logger) Fatal(args ...interface{}) {
+	l.output(LevelFatal, fmt.Sprint(args...))
+}
+
+// Fatalf logs the entry and then exits the process.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.output(LevelFatal, fmt.Sprintf(format, args...))
+}
+
+func Debugf(format string, args ...interface{}) {
+	root.output(LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func Info(args ...interface{}) {
+	root.output(LevelInfo, fmt.Sprint(args...))
+}
+
+func Infof(format string, args ...interface{}) {
+	root.output(LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func Warning(args ...interface{}) {
+	root.output(LevelWarning, fmt.Sprint(args...))
+}
+
+func Warningf(format string, args ...interface{}) {
+	root.output(LevelWarning, fmt.Sprintf(format, args...))
+}
+
+func Error(args ...interface{}) {
+	root.output(LevelError, fmt.Sprint(args...))
+}
+
+func Errorf(format string, args ...interface{}) {
+	root.output(LevelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs the entry and then exits the process.
+func Fatal(args ...interface{}) {
+	root.output(LevelFatal, fmt.Sprint(args...))
+}
+
+// Fatalf logs the entry and then exits the process.
+func Fatalf(format string, args ...interface{}) {
+	root.output(LevelFatal, fmt.Sprintf(format, args...))
+}
+
+// output writes the entry. It must be called directly by the exported logging functions,
+// so that it can find the code which called them.
+func (l *Logger) output(level Level, msg string) {
+	if !Enabled(level) {
+		return
+	}
+	e := entry{
+		time:   time.Now().UTC(),
+		level:  level,
+		msg:    strings.TrimSuffix(msg, "\n"),
+		fields: l.fields,
+	}
+	if pc, file, line, ok := runtime.Caller(2); ok {
+		e.caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			e.module = moduleOf(fn.Name())
+		}
+	}
+
+	std.mutex.Lock()
+	var encoded []byte
+	if std.format == FormatJSON {
+		encoded = e.json()
+	} else {
+		encoded = e.console()
+	}
+	var err error
+	if leveled, ok := std.out.(LeveledWriter); ok {
+		err = leveled.WriteLevel(level, encoded)
+	} else {
+		_, err = std.out.Write(encoded)
+	}
+	std.mutex.Unlock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write log entry: %v\n%s", err, encoded)
+	}
+
+	if level == LevelFatal {
+		exit(255)
+	}
+}
+
+const modulePrefix = "github.com/prebid/prebid-server/"
+
+// moduleOf returns the package which a function belongs to, relative to the root of this repo.
+// For example, "github.com/prebid/prebid-server/adapters/rubicon.(*RubiconAdapter).Call" is in "adapters/rubicon".
+func moduleOf(funcName string) string {
+	lastSlash := strings.LastIndex(funcName, "/")
+	pkg := funcName
+	if dot := strings.Index(funcName[lastSlash+1:], "."); dot >= 0 {
+		pkg = funcName[:lastSlash+1+dot]
+	}
+	return strings.TrimPrefix(pkg, modulePrefix)
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONEntry(t *testing.T) {
+	buf := captureLogs(LevelInfo, FormatJSON)
+	With("account", "1001").With("bidder", "appnexus").Warningf("Bad bid %d", 3)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("JSON logs should be valid JSON. Got %s: %v", buf.String(), err)
+	}
+	assertEntryField(t, entry, "level", "warning")
+	assertEntryField(t, entry, "module", "logger")
+	assertEntryField(t, entry, "msg", "Bad bid 3")
+	assertEntryField(t, entry, "account", "1001")
+	assertEntryField(t, entry, "bidder", "appnexus")
+	if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logger_test.go:") {
+		t.Errorf("The caller should be the code which logged the entry. Got %s", caller)
+	}
+}
+
+func TestConsoleEntry(t *testing.T) {
+	buf := captureLogs(LevelInfo, FormatConsole)
+	With("account", "1001").Errorf("Something broke\n")
+
+	line := buf.String()
+	if !strings.Contains(line, " ERROR logger logger_test.go:") || !strings.HasSuffix(line, " Something broke account=1001\n") {
+		t.Errorf("Bad console entry: %s", line)
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	buf := captureLogs(LevelWarning, FormatConsole)
+	Debugf("debug")
+	Infof("info")
+	if buf.Len() != 0 {
+		t.Errorf("Entries below the configured level shouldn't be logged. Got %s", buf.String())
+	}
+	Warning("warning")
+	if buf.Len() == 0 {
+		t.Error("Entries at the configured level should be logged.")
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	buf := captureLogs(LevelInfo, FormatJSON)
+	ctx := WithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	FromContext(ctx).Info("traced")
+	FromContext(context.Background()).Info("untraced")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) || strings.Contains(lines[1], "trace_id") {
+		t.Errorf("Only entries logged with a traced context should have a trace_id. Got %v", lines)
+	}
+}
+
+func TestFatalExits(t *testing.T) {
+	captureLogs(LevelError, FormatJSON)
+	exitCode := 0
+	exit = func(code int) { exitCode = code }
+	defer func() { exit = osExit }()
+
+	Fatalf("bye")
+	if exitCode != 255 {
+		t.Errorf("Fatal entries should exit with status 255. Got %d", exitCode)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("WARNING"); err != nil || level != LevelWarning {
+		t.Errorf("Level names should be case insensitive. Got %v, %v", level, err)
+	}
+	if _, err := ParseLevel("fatal"); err == nil {
+		t.Error("Fatal entries are always logged, so it shouldn't be a valid level.")
+	}
+}
+
+func TestModuleOf(t *testing.T) {
+	assertModule(t, "github.com/prebid/prebid-server/adapters/rubicon.(*RubiconAdapter).Call", "adapters/rubicon")
+	assertModule(t, "github.com/prebid/prebid-server/exchange.(*exchange).getAllBids.func1", "exchange")
+	assertModule(t, "main.serve", "main")
+}
+
+var osExit = exit
+
+func captureLogs(level Level, format string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	Setup(level, format, buf)
+	return buf
+}
+
+func assertEntryField(t *testing.T, entry map[string]interface{}, key string, expected string) {
+	t.Helper()
+	if actual, _ := entry[key].(string); actual != expected {
+		t.Errorf("Bad %s. Expected %q, got %q", key, expected, actual)
+	}
+}
+
+func assertModule(t *testing.T, funcName string, expected string) {
+	t.Helper()
+	if actual := moduleOf(funcName); actual != expected {
+		t.Errorf("Bad module for %s. Expected %s, got %s", funcName, expected, actual)
+	}
+}
@@ -0,0 +1,39 @@
+// +build !windows,!nacl,!plan9
+
+package logger
+
+import "log/syslog"
+
+type syslogWriter struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogWriter connects to a syslog daemon. If network is empty, it connects to the local one.
+// Entries are sent with the syslog severity which matches their level.
+func NewSyslogWriter(network string, address string, tag string) (LeveledWriter, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{writer}, nil
+}
+
+func (w *syslogWriter) Write(entry []byte) (int, error) {
+	return w.writer.Write(entry)
+}
+
+func (w *syslogWriter) WriteLevel(level Level, entry []byte) error {
+	msg := string(entry)
+	switch level {
+	case LevelDebug:
+		return w.writer.Debug(msg)
+	case LevelInfo:
+		return w.writer.Info(msg)
+	case LevelWarning:
+		return w.writer.Warning(msg)
+	case LevelError:
+		return w.writer.Err(msg)
+	default:
+		return w.writer.Crit(msg)
+	}
+}
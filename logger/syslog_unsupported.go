@@ -0,0 +1,10 @@
+// +build windows nacl plan9
+
+package logger
+
+import "errors"
+
+// NewSyslogWriter always fails, because syslog isn't supported on this platform.
+func NewSyslogWriter(network string, address string, tag string) (LeveledWriter, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,20 @@
+package logger
+
+import (
+	"io"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// NewFileWriter returns a writer which appends to the file at path. Once the file grows past maxSizeMB,
+// it's renamed with a timestamp and a new one is started. Old files are deleted once there are more than
+// maxBackups of them, or they're older than maxAgeDays. Zero values keep old files forever.
+func NewFileWriter(path string, maxSizeMB int, maxBackups int, maxAgeDays int, compress bool) io.WriteCloser {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSizeMB,
+		MaxBackups: maxBackups,
+		MaxAge:     maxAgeDays,
+		Compress:   compress,
+	}
+}
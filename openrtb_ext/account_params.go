@@ -0,0 +1,63 @@
+package openrtb_ext
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// AccountParamsValidator enforces the extra bidder params schemas which some accounts use to tighten the bidders' own.
+//
+// Params have to pass both the bidder's schema and the account's, so an account's schema can only make the
+// params stricter. For example, it can allow only certain appnexus placement IDs.
+type AccountParamsValidator struct {
+	schemas map[string]map[BidderName]*gojsonschema.Schema
+}
+
+// NewAccountParamsValidator parses the schemas, which are JSON strings keyed by account ID and then bidder name.
+// Bidder names are matched case-insensitively, since the host config lowercases them.
+func NewAccountParamsValidator(accountSchemas map[string]map[string]string) (*AccountParamsValidator, error) {
+	validator := &AccountParamsValidator{
+		schemas: make(map[string]map[BidderName]*gojsonschema.Schema, len(accountSchemas)),
+	}
+	for account, bidderSchemas := range accountSchemas {
+		parsed := make(map[BidderName]*gojsonschema.Schema, len(bidderSchemas))
+		for bidder, schema := range bidderSchemas {
+			bidderName, ok := bidderNameIgnoringCase(bidder)
+			if !ok {
+				return nil, fmt.Errorf("The bidder params schema for account %s uses an unknown bidder: %s", account, bidder)
+			}
+			loaded, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load the %s params schema for account %s: %v", bidder, account, err)
+			}
+			parsed[bidderName] = loaded
+		}
+		validator.schemas[account] = parsed
+	}
+	return validator, nil
+}
+
+// Validate returns an error if the params don't pass the account's schema for the bidder.
+// Bidders which the account has no schema for always pass. A nil validator passes everything.
+func (validator *AccountParamsValidator) Validate(account string, name BidderName, ext openrtb.RawJSON) error {
+	if validator == nil {
+		return nil
+	}
+	schema, ok := validator.schemas[account][CoreBidderName(name)]
+	if !ok {
+		return nil
+	}
+	return validateAgainstSchema(schema, ext)
+}
+
+func bidderNameIgnoringCase(name string) (BidderName, bool) {
+	for key, bidderName := range BidderMap {
+		if strings.EqualFold(key, name) {
+			return bidderName, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,53 @@
+package openrtb_ext
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+func TestAccountParamsValidator(t *testing.T) {
+	validator, err := NewAccountParamsValidator(map[string]map[string]string{
+		"managed": {
+			"appnexus":        `{"properties":{"placementId":{"enum":[10433394,10433395]}}}`,
+			"audiencenetwork": `{"required":["placementId"]}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error building the validator: %v", err)
+	}
+
+	testCases := []struct {
+		description string
+		account     string
+		bidder      BidderName
+		params      string
+		expectError bool
+	}{
+		{"allowed placement", "managed", BidderAppnexus, `{"placementId":10433394}`, false},
+		{"other placement", "managed", BidderAppnexus, `{"placementId":12345}`, true},
+		{"other account", "self-serve", BidderAppnexus, `{"placementId":12345}`, false},
+		{"bidder without an account schema", "managed", BidderRubicon, `{"accountId":1}`, false},
+		{"bidder matched ignoring case", "managed", BidderFacebook, `{"publisherId":"abc"}`, true},
+	}
+	for _, test := range testCases {
+		err := validator.Validate(test.account, test.bidder, openrtb.RawJSON(test.params))
+		if (err != nil) != test.expectError {
+			t.Errorf("%s: expected an error? %t. Got %v", test.description, test.expectError, err)
+		}
+	}
+
+	var nilValidator *AccountParamsValidator
+	if err := nilValidator.Validate("managed", BidderAppnexus, openrtb.RawJSON(`{"placementId":12345}`)); err != nil {
+		t.Errorf("A nil validator should pass everything. Got %v", err)
+	}
+}
+
+func TestAccountParamsValidatorErrors(t *testing.T) {
+	if _, err := NewAccountParamsValidator(map[string]map[string]string{"managed": {"nosuchbidder": `{}`}}); err == nil {
+		t.Error("Schemas for unknown bidders should be rejected.")
+	}
+	if _, err := NewAccountParamsValidator(map[string]map[string]string{"managed": {"appnexus": `{"type":5}`}}); err == nil {
+		t.Error("Invalid schemas should be rejected.")
+	}
+}
@@ -14,9 +14,46 @@ type ExtBid struct {
 
 // ExtBidPrebid defines the contract for bidresponse.seatbid.bid[i].ext.prebid
 type ExtBidPrebid struct {
-	Cache     *ExtBidPrebidCache `json:"cache,omitempty"`
-	Targeting map[string]string  `json:"targeting,omitempty"`
-	Type      BidType            `json:"type"`
+	Cache        *ExtBidPrebidCache    `json:"cache,omitempty"`
+	Currency     *ExtBidPrebidCurrency `json:"currency,omitempty"`
+	DealPriority int                   `json:"dealpriority,omitempty"`
+	GrossPrice   float64               `json:"grossprice,omitempty"`
+	Meta         *ExtBidPrebidMeta     `json:"meta,omitempty"`
+	Passthrough  openrtb.RawJSON       `json:"passthrough,omitempty"`
+	Scan         *ExtBidPrebidScan     `json:"scan,omitempty"`
+	Targeting    map[string]string     `json:"targeting,omitempty"`
+	Type         BidType               `json:"type"`
+	Video        *ExtBidPrebidVideo    `json:"video,omitempty"`
+}
+
+// ExtBidPrebidVideo defines the contract for bidresponse.seatbid.bid[i].ext.prebid.video
+type ExtBidPrebidVideo struct {
+	// Duration is the length of the video creative, in seconds.
+	Duration        int    `json:"duration"`
+	PrimaryCategory string `json:"primary_category"`
+}
+
+// ExtBidPrebidMeta defines the contract for bidresponse.seatbid.bid[i].ext.prebid.meta
+type ExtBidPrebidMeta struct {
+	// RendererName and RendererVersion tell the mobile SDKs which renderer the bid needs, e.g. for outstream video.
+	RendererName    string `json:"rendererName,omitempty"`
+	RendererVersion string `json:"rendererVersion,omitempty"`
+}
+
+// ExtBidPrebidScan defines the contract for bidresponse.seatbid.bid[i].ext.prebid.scan
+// It's only set if the creative scanning service marked the creative as suspicious.
+type ExtBidPrebidScan struct {
+	Verdict string `json:"verdict"`
+	// Reason says what the service found, if it said.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExtBidPrebidCurrency defines the contract for bidresponse.seatbid.bid[i].ext.prebid.currency
+// It's only set if the bidder bid in one of the other currencies from request.cur. bid.price is always in bidresponse.cur.
+type ExtBidPrebidCurrency struct {
+	// Cur and Price are what the bidder bid, before it was converted into bidresponse.cur.
+	Cur   string  `json:"cur"`
+	Price float64 `json:"price"`
 }
 
 // ExtBidPrebidCache defines the contract for  bidresponse.seatbid.bid[i].ext.prebid.cache
@@ -81,6 +118,11 @@ const (
 	// HbBidderConstantKey is the name of the Bidder. For example, "appnexus" or "rubicon".
 	HbBidderConstantKey TargetingKey = "hb_bidder"
 	HbSizeConstantKey   TargetingKey = "hb_size"
+	// HbCategoryDurationKey is set on video bids which say how long they are. Its value is {hb_pb}_{category}_{duration}s,
+	// where the price may be replaced by a deal tier label. See DealTier.
+	HbCategoryDurationKey TargetingKey = "hb_pb_cat_dur"
+	// HbFormatKey is the bid's media type, so that one set of line items can serve every format. For example, "banner" or "video".
+	HbFormatKey TargetingKey = "hb_format"
 
 	// HbCreativeLoadMethodConstantKey is used exclusively by Prebid Mobile to accomodate Facebook.
 	// Facebook requires that ads from their network be loaded using their own SDK.
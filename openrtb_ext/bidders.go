@@ -38,7 +38,8 @@ const (
 	BidderSovrn        BidderName = "sovrn"
 )
 
-// BidderMap stores all the valid OpenRTB 2.x Bidders in the project. This map *must not* be mutated.
+// BidderMap stores all the valid OpenRTB 2.x Bidders in the project. This map *must not* be mutated,
+// except by SetAliasBidderName when the host's aliases are registered on startup.
 var BidderMap = map[string]BidderName{
 	"adtelligent":     BidderAdtelligent,
 	"adform":          BidderAdform,
@@ -58,6 +59,37 @@ var BidderMap = map[string]BidderName{
 	"sovrn":           BidderSovrn,
 }
 
+// aliasBidderToParent maps each alias which the host defined in its config to the core bidder which it uses.
+var aliasBidderToParent = map[BidderName]BidderName{}
+
+// SetAliasBidderName registers a permanent alias of a core bidder. From then on, the alias is a valid BidderName,
+// which uses the parent's bidder params schema. This must only be called on startup, before any requests are served.
+func SetAliasBidderName(aliasName string, parentName BidderName) error {
+	if _, exists := BidderMap[aliasName]; exists {
+		return fmt.Errorf("alias %s is already a bidder name", aliasName)
+	}
+	if _, isCoreBidder := BidderMap[string(parentName)]; !isCoreBidder || IsAlias(parentName) {
+		return fmt.Errorf("alias %s refers to unknown bidder: %s", aliasName, parentName)
+	}
+	BidderMap[aliasName] = BidderName(aliasName)
+	aliasBidderToParent[BidderName(aliasName)] = parentName
+	return nil
+}
+
+// IsAlias returns true if the bidder was registered through SetAliasBidderName.
+func IsAlias(name BidderName) bool {
+	_, ok := aliasBidderToParent[name]
+	return ok
+}
+
+// CoreBidderName returns the core bidder which a host alias uses. Any other name is returned as-is.
+func CoreBidderName(name BidderName) BidderName {
+	if parent, ok := aliasBidderToParent[name]; ok {
+		return parent
+	}
+	return name
+}
+
 // BidderList returns the values of the BidderMap
 func BidderList() []BidderName {
 	bidders := make([]BidderName, 0, len(BidderMap))
@@ -134,7 +166,16 @@ type bidderParamValidator struct {
 }
 
 func (validator *bidderParamValidator) Validate(name BidderName, ext openrtb.RawJSON) error {
-	result, err := validator.parsedSchemas[name].Validate(gojsonschema.NewBytesLoader(ext))
+	return validateAgainstSchema(validator.parsedSchemas[CoreBidderName(name)], ext)
+}
+
+func (validator *bidderParamValidator) Schema(name BidderName) string {
+	return validator.schemaContents[CoreBidderName(name)]
+}
+
+// validateAgainstSchema returns an error which lists all the ways in which ext fails the schema, if it does.
+func validateAgainstSchema(schema *gojsonschema.Schema, ext openrtb.RawJSON) error {
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(ext))
 	if err != nil {
 		return err
 	}
@@ -147,7 +188,3 @@ func (validator *bidderParamValidator) Validate(name BidderName, ext openrtb.Raw
 	}
 	return nil
 }
-
-func (validator *bidderParamValidator) Schema(name BidderName) string {
-	return validator.schemaContents[name]
-}
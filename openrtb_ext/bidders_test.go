@@ -49,6 +49,39 @@ func TestInvalidParams(t *testing.T) {
 	}
 }
 
+func TestSetAliasBidderName(t *testing.T) {
+	defer func() {
+		delete(BidderMap, "districtm")
+		delete(aliasBidderToParent, "districtm")
+	}()
+
+	if err := SetAliasBidderName("districtm", BidderAppnexus); err != nil {
+		t.Fatalf("Unexpected error registering alias: %v", err)
+	}
+	if BidderMap["districtm"] != BidderName("districtm") {
+		t.Error("Aliases should be added to the BidderMap.")
+	}
+	if !IsAlias("districtm") || IsAlias(BidderAppnexus) {
+		t.Error("Only the registered alias should be an alias.")
+	}
+	if parent := CoreBidderName("districtm"); parent != BidderAppnexus {
+		t.Errorf("Wrong core bidder for alias. Expected appnexus, got %s", parent)
+	}
+	if err := validator.Validate("districtm", openrtb.RawJSON(`{"placementId":123}`)); err != nil {
+		t.Errorf("Aliases should use their parent's params schema. Error was: %v", err)
+	}
+
+	if err := SetAliasBidderName("rubicon", BidderAppnexus); err == nil {
+		t.Error("Core bidder names should not be usable as aliases.")
+	}
+	if err := SetAliasBidderName("other", "districtm"); err == nil {
+		t.Error("Aliases of aliases should not be allowed.")
+	}
+	if err := SetAliasBidderName("other", "unknown"); err == nil {
+		t.Error("Aliases of unknown bidders should not be allowed.")
+	}
+}
+
 func TestBidderList(t *testing.T) {
 	list := BidderList()
 	for _, bidderName := range BidderMap {
@@ -0,0 +1,45 @@
+package openrtb_ext
+
+import (
+	"encoding/json"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+// DealTier defines the contract for bidrequest.imp[i].ext.prebid.bidder.{bidder}.dealTier
+//
+// A bid whose ext.prebid.dealpriority is at least MinDealTier gets its price replaced by {Prefix}{dealpriority}
+// in the hb_pb_cat_dur targeting key, so that ad server line items for programmatic guaranteed deals can
+// be prioritized by tier rather than by price.
+type DealTier struct {
+	Prefix      string `json:"prefix"`
+	MinDealTier int    `json:"minDealTier"`
+}
+
+// DealTierBidderMap holds the deal tier which each bidder was given in a single imp.
+type DealTierBidderMap map[BidderName]DealTier
+
+// ReadDealTiersFromImp returns the valid deal tiers in the imp's bidder params.
+// Tiers without a prefix or a positive minDealTier are ignored.
+func ReadDealTiersFromImp(imp openrtb.Imp) (DealTierBidderMap, error) {
+	dealTiers := make(DealTierBidderMap)
+	if len(imp.Ext) == 0 {
+		return dealTiers, nil
+	}
+	impBidders, err := ImpBidderParams(json.RawMessage(imp.Ext))
+	if err != nil {
+		return nil, err
+	}
+	for bidder, params := range impBidders {
+		var bidderExt struct {
+			DealTier *DealTier `json:"dealTier"`
+		}
+		if err := json.Unmarshal(params, &bidderExt); err != nil {
+			return nil, err
+		}
+		if tier := bidderExt.DealTier; tier != nil && tier.Prefix != "" && tier.MinDealTier > 0 {
+			dealTiers[BidderName(bidder)] = *tier
+		}
+	}
+	return dealTiers, nil
+}
@@ -0,0 +1,38 @@
+package openrtb_ext
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+func TestReadDealTiersFromImp(t *testing.T) {
+	imp := openrtb.Imp{
+		ID: "imp-1",
+		Ext: openrtb.RawJSON(`{
+			"appnexus": {"placementId": 1, "dealTier": {"prefix": "tier", "minDealTier": 5}},
+			"rubicon": {"accountId": 1, "dealTier": {"prefix": "", "minDealTier": 5}},
+			"openx": {"unit": "1", "dealTier": {"prefix": "tier", "minDealTier": 0}},
+			"prebid": {"storedrequest": {"id": "foo"}, "bidder": {"pubmatic": {"dealTier": {"prefix": "pm", "minDealTier": 3}}}},
+			"tid": "some-tid"
+		}`),
+	}
+	dealTiers, err := ReadDealTiersFromImp(imp)
+	if err != nil {
+		t.Fatalf("Unexpected error reading deal tiers: %v", err)
+	}
+	if len(dealTiers) != 2 {
+		t.Fatalf("Only the valid deal tiers should be read. Got %v", dealTiers)
+	}
+	if tier := dealTiers[BidderPubmatic]; tier.Prefix != "pm" || tier.MinDealTier != 3 {
+		t.Errorf("Deal tiers in imp.ext.prebid.bidder should be read. Got %v", tier)
+	}
+	if tier := dealTiers[BidderAppnexus]; tier.Prefix != "tier" || tier.MinDealTier != 5 {
+		t.Errorf("Wrong deal tier for appnexus. Got %v", tier)
+	}
+
+	imp.Ext = openrtb.RawJSON(`{"appnexus": {"dealTier": "tier5"}}`)
+	if _, err := ReadDealTiersFromImp(imp); err == nil {
+		t.Error("Malformed deal tiers should return an error.")
+	}
+}
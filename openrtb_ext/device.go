@@ -0,0 +1,53 @@
+package openrtb_ext
+
+// ExtDevice defines the contract for bidrequest.device.ext
+type ExtDevice struct {
+
+	// ATTS is the app tracking authorization status from iOS 14+. For more info, see:
+	// https://github.com/InteractiveAdvertisingBureau/openrtb/blob/master/extensions/community_extensions/skadnetwork.md
+	ATTS *int8 `json:"atts,omitempty"`
+
+	// CDep is Chrome's cookie deprecation label, from the Sec-Cookie-Deprecation header.
+	CDep string `json:"cdep,omitempty"`
+
+	// SUA is OpenRTB 2.6's structured user agent, which our OpenRTB structs don't have yet.
+	SUA *UserAgent `json:"sua,omitempty"`
+}
+
+// These are the values of device.ext.atts, from Apple's ATTrackingManager.AuthorizationStatus.
+const (
+	ATTSNotDetermined int8 = 0
+	ATTSRestricted    int8 = 1
+	ATTSDenied        int8 = 2
+	ATTSAuthorized    int8 = 3
+)
+
+// UserAgent describes the user's browser and device, as OpenRTB 2.6's device.sua does. It usually comes from the
+// browser's User-Agent Client Hints. For more info, see section 3.2.29 of the OpenRTB 2.6 spec.
+type UserAgent struct {
+	// Browsers are the browser and its engine, such as "Google Chrome" and "Chromium", from the Sec-CH-UA headers.
+	Browsers []BrandVersion `json:"browsers,omitempty"`
+	// Platform is the operating system, from the Sec-CH-UA-Platform headers.
+	Platform *BrandVersion `json:"platform,omitempty"`
+	// Mobile is 1 if the browser prefers a mobile layout, 0 if it doesn't, and nil if it's unknown.
+	Mobile       *int8  `json:"mobile,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+	Bitness      string `json:"bitness,omitempty"`
+	Model        string `json:"model,omitempty"`
+	// Source says where the values came from. It's one of the UserAgentSource constants.
+	Source int8 `json:"source,omitempty"`
+}
+
+// BrandVersion is a browser or platform, with its version split into components, like ["124", "0", "6367", "60"].
+type BrandVersion struct {
+	Brand   string   `json:"brand"`
+	Version []string `json:"version,omitempty"`
+}
+
+// These are the values of device.sua.source.
+const (
+	UserAgentSourceUnknown     int8 = 0
+	UserAgentSourceLowEntropy  int8 = 1
+	UserAgentSourceHighEntropy int8 = 2
+	UserAgentSourceParsed      int8 = 3
+)
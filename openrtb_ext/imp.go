@@ -1,5 +1,7 @@
 package openrtb_ext
 
+import "encoding/json"
+
 // ExtImp defines the contract for bidrequest.imp[i].ext
 type ExtImp struct {
 	Prebid   *ExtImpPrebid   `json:"prebid"`
@@ -11,9 +13,136 @@ type ExtImp struct {
 // ExtImpPrebid defines the contract for bidrequest.imp[i].ext.prebid
 type ExtImpPrebid struct {
 	StoredRequest *ExtStoredRequest `json:"storedrequest"`
+	// Passthrough is returned untouched in the ext.prebid.passthrough of each bid for the imp.
+	Passthrough json.RawMessage `json:"passthrough,omitempty"`
+	// Bidder holds the params for each bidder, keyed by bidder name or alias.
+	// This is where newer pages put them, instead of directly in bidrequest.imp[i].ext.
+	Bidder map[string]json.RawMessage `json:"bidder,omitempty"`
 }
 
 // ExtStoredRequest defines the contract for bidrequest.imp[i].ext.prebid.storedrequest
 type ExtStoredRequest struct {
 	ID string `json:"id"`
 }
+
+// IsBidderImpExtKey returns false for the keys in bidrequest.imp[i].ext which don't hold a bidder's params.
+func IsBidderImpExtKey(key string) bool {
+	return key != "prebid" && key != "tid"
+}
+
+// ImpBidderParams returns the params for each bidder in an imp.ext, keyed by bidder name or alias.
+// Params can be in imp.ext.prebid.bidder.{bidder}, or in the legacy imp.ext.{bidder}. If a bidder is in both,
+// the params in imp.ext.prebid.bidder are used.
+func ImpBidderParams(impExt json.RawMessage) (map[string]json.RawMessage, error) {
+	var ext map[string]json.RawMessage
+	if err := json.Unmarshal(impExt, &ext); err != nil {
+		return nil, err
+	}
+	params := make(map[string]json.RawMessage, len(ext))
+	for key, value := range ext {
+		if IsBidderImpExtKey(key) {
+			params[key] = value
+		}
+	}
+	if prebidExt, ok := ext["prebid"]; ok {
+		var prebid struct {
+			Bidder map[string]json.RawMessage `json:"bidder"`
+		}
+		if err := json.Unmarshal(prebidExt, &prebid); err != nil {
+			return nil, err
+		}
+		for bidder, value := range prebid.Bidder {
+			params[bidder] = value
+		}
+	}
+	return params, nil
+}
+
+// MigrateImpExt moves the bidder params in the legacy imp.ext.{bidder} locations into imp.ext.prebid.bidder,
+// so that pages in either format can be handled the same way. Params which are already in imp.ext.prebid.bidder
+// are kept over the legacy ones. The imp.ext is returned unchanged if it has no legacy params.
+func MigrateImpExt(impExt json.RawMessage) (json.RawMessage, error) {
+	var ext map[string]json.RawMessage
+	if err := json.Unmarshal(impExt, &ext); err != nil {
+		return nil, err
+	}
+	legacy := make(map[string]json.RawMessage)
+	for key, value := range ext {
+		if IsBidderImpExtKey(key) {
+			legacy[key] = value
+			delete(ext, key)
+		}
+	}
+	if len(legacy) == 0 {
+		return impExt, nil
+	}
+
+	// The other imp.ext.prebid fields are kept as they are.
+	prebid := make(map[string]json.RawMessage)
+	if prebidExt, ok := ext["prebid"]; ok {
+		if err := json.Unmarshal(prebidExt, &prebid); err != nil {
+			return nil, err
+		}
+	}
+	bidders := make(map[string]json.RawMessage, len(legacy))
+	if biddersExt, ok := prebid["bidder"]; ok {
+		if err := json.Unmarshal(biddersExt, &bidders); err != nil {
+			return nil, err
+		}
+	}
+	for bidder, value := range legacy {
+		if _, ok := bidders[bidder]; !ok {
+			bidders[bidder] = value
+		}
+	}
+
+	var err error
+	if prebid["bidder"], err = json.Marshal(bidders); err != nil {
+		return nil, err
+	}
+	if ext["prebid"], err = json.Marshal(prebid); err != nil {
+		return nil, err
+	}
+	return json.Marshal(ext)
+}
+
+// RemoveImpBidders removes the params of the bidders from an imp.ext, from both of the places which ImpBidderParams
+// reads them. An imp.ext.prebid.bidder which is left empty is removed too.
+func RemoveImpBidders(impExt json.RawMessage, bidders []string) (json.RawMessage, error) {
+	var ext map[string]json.RawMessage
+	if err := json.Unmarshal(impExt, &ext); err != nil {
+		return nil, err
+	}
+	for _, bidder := range bidders {
+		if IsBidderImpExtKey(bidder) {
+			delete(ext, bidder)
+		}
+	}
+	if prebidExt, ok := ext["prebid"]; ok {
+		var prebid map[string]json.RawMessage
+		if err := json.Unmarshal(prebidExt, &prebid); err != nil {
+			return nil, err
+		}
+		if biddersExt, ok := prebid["bidder"]; ok {
+			var params map[string]json.RawMessage
+			if err := json.Unmarshal(biddersExt, &params); err != nil {
+				return nil, err
+			}
+			for _, bidder := range bidders {
+				delete(params, bidder)
+			}
+			var err error
+			if len(params) > 0 {
+				if prebid["bidder"], err = json.Marshal(params); err != nil {
+					return nil, err
+				}
+			} else {
+				delete(prebid, "bidder")
+			}
+			if ext["prebid"], err = json.Marshal(prebid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return json.Marshal(ext)
+}
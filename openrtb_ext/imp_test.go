@@ -0,0 +1,75 @@
+package openrtb_ext
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestImpBidderParams(t *testing.T) {
+	params, err := ImpBidderParams(json.RawMessage(`{
+		"appnexus": {"placementId": 1},
+		"rubicon": {"accountId": 1},
+		"prebid": {"storedrequest": {"id": "foo"}, "bidder": {"rubicon": {"accountId": 2}, "openx": {"unit": "1"}}},
+		"tid": "some-tid"
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"appnexus": `{"placementId": 1}`,
+		"rubicon":  `{"accountId": 2}`,
+		"openx":    `{"unit": "1"}`,
+	}
+	if len(params) != len(expected) {
+		t.Errorf("Expected params for %d bidders. Got %v", len(expected), params)
+	}
+	for bidder, value := range expected {
+		if string(params[bidder]) != value {
+			t.Errorf("Wrong params for %s. Expected %s, got %s", bidder, value, params[bidder])
+		}
+	}
+
+	if _, err := ImpBidderParams(json.RawMessage(`{"prebid": {"bidder": []}}`)); err == nil {
+		t.Error("A malformed imp.ext.prebid.bidder should return an error.")
+	}
+}
+
+func TestMigrateImpExt(t *testing.T) {
+	migrated, err := MigrateImpExt(json.RawMessage(`{"appnexus":{"placementId":1},"rubicon":{"accountId":1},"tid":"some-tid","prebid":{"storedrequest":{"id":"foo"},"bidder":{"rubicon":{"accountId":2}}}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"prebid":{"bidder":{"appnexus":{"placementId":1},"rubicon":{"accountId":2}},"storedrequest":{"id":"foo"}},"tid":"some-tid"}`
+	if string(migrated) != expected {
+		t.Errorf("The legacy params should be moved into imp.ext.prebid.bidder. Expected %s, got %s", expected, migrated)
+	}
+
+	unchanged := json.RawMessage(`{"prebid": {"bidder": {"appnexus": {"placementId": 1}}}}`)
+	if migrated, err := MigrateImpExt(unchanged); err != nil || string(migrated) != string(unchanged) {
+		t.Errorf("An imp.ext without legacy params should be left alone. Got %s, %v", migrated, err)
+	}
+
+	if _, err := MigrateImpExt(json.RawMessage(`{"appnexus": {}, "prebid": "bad"}`)); err == nil {
+		t.Error("A malformed imp.ext.prebid should return an error.")
+	}
+}
+
+func TestRemoveImpBidders(t *testing.T) {
+	removed, err := RemoveImpBidders(json.RawMessage(`{"appnexus":{"placementId":1},"tid":"some-tid","prebid":{"storedrequest":{"id":"foo"},"bidder":{"rubicon":{"accountId":2},"ix":{"siteId":"3"}}}}`), []string{"appnexus", "rubicon"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"prebid":{"bidder":{"ix":{"siteId":"3"}},"storedrequest":{"id":"foo"}},"tid":"some-tid"}`
+	if string(removed) != expected {
+		t.Errorf("Expected %s, got %s", expected, removed)
+	}
+
+	removed, err = RemoveImpBidders(json.RawMessage(`{"prebid":{"bidder":{"rubicon":{"accountId":2}}}}`), []string{"rubicon"})
+	if err != nil || string(removed) != `{"prebid":{}}` {
+		t.Errorf("An empty imp.ext.prebid.bidder should be removed. Got %s, %v", removed, err)
+	}
+
+	if _, err := RemoveImpBidders(json.RawMessage(`{"prebid":{"bidder":"bad"}}`), []string{"rubicon"}); err == nil {
+		t.Error("A malformed imp.ext.prebid.bidder should return an error.")
+	}
+}
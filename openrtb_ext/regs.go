@@ -6,4 +6,12 @@ type ExtRegs struct {
 	// GDPR should be "1" if the caller believes the user is subject to GDPR laws, "0" if not, and undefined
 	// if it's unknown. For more info on this parameter, see: https://iabtechlab.com/wp-content/uploads/2018/02/OpenRTB_Advisory_GDPR_2018-02.pdf
 	GDPR *int8 `json:"gdpr,omitempty"`
+
+	// GPPSID lists the GPP section IDs of the privacy laws which apply to the request.
+	// For more info, see: https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform
+	GPPSID []int8 `json:"gpp_sid,omitempty"`
+
+	// USPrivacy is the IAB US Privacy (CCPA) string, like "1YNN". For more info, see:
+	// https://github.com/InteractiveAdvertisingBureau/USPrivacy/blob/master/CCPA/US%20Privacy%20String.md
+	USPrivacy string `json:"us_privacy,omitempty"`
 }
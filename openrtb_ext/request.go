@@ -3,6 +3,7 @@ package openrtb_ext
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 // ExtRequest defines the contract for bidrequest.ext
@@ -15,13 +16,36 @@ type ExtRequestPrebid struct {
 	Aliases              map[string]string      `json:"aliases,omitempty"`
 	BidAdjustmentFactors map[string]float64     `json:"bidadjustmentfactors,omitempty"`
 	Cache                *ExtRequestPrebidCache `json:"cache,omitempty"`
+	CurrencyConversions  *ExtRequestCurrency    `json:"currency,omitempty"`
+	Data                 *ExtRequestPrebidData  `json:"data,omitempty"`
+	Passthrough          json.RawMessage        `json:"passthrough,omitempty"`
 	StoredRequest        *ExtStoredRequest      `json:"storedrequest,omitempty"`
+	SupportDeals         bool                   `json:"supportdeals,omitempty"`
 	Targeting            *ExtRequestTargeting   `json:"targeting,omitempty"`
 }
 
+// ExtRequestPrebidData defines the contract for bidrequest.ext.prebid.data
+type ExtRequestPrebidData struct {
+	// Bidders are the bidders, or aliases, which get the first party data in site.ext.data, app.ext.data and
+	// user.ext.data. "*" means every bidder. If it's empty, every bidder gets the data.
+	Bidders []string `json:"bidders,omitempty"`
+}
+
+// ExtRequestCurrency defines the contract for bidrequest.ext.prebid.currency
+type ExtRequestCurrency struct {
+	// ConversionRates are custom rates, in the same format as the "conversions" in the server's currency file.
+	ConversionRates map[string]map[string]float64 `json:"rates"`
+	// UsePBSRates says whether the server's rates can be used for conversions which aren't in ConversionRates.
+	// If omitted, they can.
+	UsePBSRates *bool `json:"usepbsrates"`
+}
+
 // ExtRequestPrebidCache defines the contract for bidrequest.ext.prebid.cache
 type ExtRequestPrebidCache struct {
 	Bids *ExtRequestPrebidCacheBids `json:"bids"`
+	// Async lets the response go out before the bids have been cached. The hb_cache_id keys are still set,
+	// but may point to bids which haven't been saved yet, or which failed to save.
+	Async bool `json:"async,omitempty"`
 }
 
 // UnmarshalJSON prevents nil bids arguments.
@@ -48,6 +72,38 @@ type ExtRequestTargeting struct {
 	PriceGranularity  PriceGranularity `json:"pricegranularity"`
 	IncludeWinners    bool             `json:"includewinners"`
 	IncludeBidderKeys bool             `json:"includebidderkeys"`
+	// IncludeFormat says whether bids should get the hb_format keys. If omitted, they do.
+	IncludeFormat bool `json:"includeformat"`
+	// MaxKeys caps the number of targeting keys set across all the bids in an imp. Zero means no cap.
+	MaxKeys int `json:"maxkeys,omitempty"`
+	// IncludeBrandCategory says whether the categories in hb_pb_cat_dur should be mapped onto the ad server's own.
+	IncludeBrandCategory *ExtIncludeBrandCategory `json:"includebrandcategory,omitempty"`
+	// DurationRangeSec are the video durations which the ad server has line items for. If it's set, the duration in
+	// hb_pb_cat_dur is rounded up to the nearest one, and video bids which are longer than all of them are dropped.
+	DurationRangeSec []int `json:"durationrangesec,omitempty"`
+	// RequireExactDuration drops the video bids whose duration isn't one of DurationRangeSec, instead of rounding them up.
+	RequireExactDuration bool `json:"requireexactduration,omitempty"`
+}
+
+// ExtIncludeBrandCategory defines the contract for bidrequest.ext.prebid.targeting.includebrandcategory
+type ExtIncludeBrandCategory struct {
+	// PrimaryAdServer is the ad server whose categories are used. 1 is Freewheel, and 2 is DFP.
+	PrimaryAdServer int `json:"primaryadserver"`
+	// Publisher is the publisher whose own category mapping should be used, if it has one.
+	// The ad server's default mapping is used otherwise.
+	Publisher string `json:"publisher,omitempty"`
+}
+
+// PrimaryAdServerName returns the name of the ad server, which the category mappings are stored under.
+// It returns "" for unknown ad servers.
+func (b *ExtIncludeBrandCategory) PrimaryAdServerName() string {
+	switch b.PrimaryAdServer {
+	case 1:
+		return "freewheel"
+	case 2:
+		return "dfp"
+	}
+	return ""
 }
 
 // Make an unmarshaller that will set a default PriceGranularity
@@ -62,6 +118,7 @@ func (ert *ExtRequestTargeting) UnmarshalJSON(b []byte) error {
 		PriceGranularity:  priceGranularityMed,
 		IncludeWinners:    true,
 		IncludeBidderKeys: true,
+		IncludeFormat:     true,
 	}
 
 	err := json.Unmarshal(b, defaults)
@@ -69,6 +126,17 @@ func (ert *ExtRequestTargeting) UnmarshalJSON(b []byte) error {
 		if !defaults.IncludeWinners && !defaults.IncludeBidderKeys {
 			return errors.New("ext.prebid.targeting: At least one of includewinners or includebidderkeys must be enabled to enable targeting support")
 		}
+		if defaults.MaxKeys < 0 {
+			return errors.New("ext.prebid.targeting.maxkeys must be a non-negative number")
+		}
+		if defaults.IncludeBrandCategory != nil && defaults.IncludeBrandCategory.PrimaryAdServerName() == "" {
+			return errors.New("ext.prebid.targeting.includebrandcategory.primaryadserver must be 1 (Freewheel) or 2 (DFP)")
+		}
+		for _, duration := range defaults.DurationRangeSec {
+			if duration <= 0 {
+				return fmt.Errorf("ext.prebid.targeting.durationrangesec must only contain positive numbers. Got %d", duration)
+			}
+		}
 		*ert = ExtRequestTargeting(*defaults)
 	}
 
@@ -46,6 +46,62 @@ func TestExtRequestTargeting(t *testing.T) {
 	}
 }
 
+func TestTargetingKeyOptions(t *testing.T) {
+	var targeting ExtRequestTargeting
+	if err := json.Unmarshal([]byte(`{}`), &targeting); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !targeting.IncludeFormat {
+		t.Error("includeformat should default to true.")
+	}
+	if targeting.MaxKeys != 0 {
+		t.Errorf("maxkeys should default to 0. Got %d", targeting.MaxKeys)
+	}
+
+	if err := json.Unmarshal([]byte(`{"includeformat":false,"maxkeys":8}`), &targeting); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if targeting.IncludeFormat {
+		t.Error("includeformat should be false when the request disables it.")
+	}
+	if targeting.MaxKeys != 8 {
+		t.Errorf("maxkeys should be 8. Got %d", targeting.MaxKeys)
+	}
+
+	if err := json.Unmarshal([]byte(`{"maxkeys":-1}`), &targeting); err == nil {
+		t.Error("Unmarshal should fail when maxkeys is negative.")
+	}
+}
+
+func TestIncludeBrandCategory(t *testing.T) {
+	var targeting ExtRequestTargeting
+	if err := json.Unmarshal([]byte(`{"includebrandcategory":{"primaryadserver":1,"publisher":"pub"}}`), &targeting); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if targeting.IncludeBrandCategory == nil || targeting.IncludeBrandCategory.PrimaryAdServerName() != "freewheel" || targeting.IncludeBrandCategory.Publisher != "pub" {
+		t.Errorf("Wrong includebrandcategory: %v", targeting.IncludeBrandCategory)
+	}
+	if err := json.Unmarshal([]byte(`{"includebrandcategory":{"primaryadserver":3}}`), &targeting); err == nil {
+		t.Error("Unmarshal should fail when the primaryadserver is unknown.")
+	}
+}
+
+func TestDurationRangeSec(t *testing.T) {
+	var targeting ExtRequestTargeting
+	if err := json.Unmarshal([]byte(`{"durationrangesec":[15,30]}`), &targeting); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(targeting.DurationRangeSec) != 2 || targeting.DurationRangeSec[0] != 15 || targeting.DurationRangeSec[1] != 30 {
+		t.Errorf("Wrong durationrangesec: %v", targeting.DurationRangeSec)
+	}
+	if err := json.Unmarshal([]byte(`{"durationrangesec":[15,0]}`), &targeting); err == nil {
+		t.Error("Unmarshal should fail when a duration is zero.")
+	}
+	if err := json.Unmarshal([]byte(`{"durationrangesec":[-15]}`), &targeting); err == nil {
+		t.Error("Unmarshal should fail when a duration is negative.")
+	}
+}
+
 const ext1 = `{
 	"prebid": {
 		"non_target": "some junk"
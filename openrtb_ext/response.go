@@ -9,10 +9,53 @@ type ExtBidResponse struct {
 	Debug *ExtResponseDebug `json:"debug,omitempty"`
 	// ExtResponseErrors defines the contract for bidresponse.ext.errors
 	Errors map[BidderName][]string `json:"errors,omitempty"`
+	// ExtResponseWarnings defines the contract for bidresponse.ext.warnings. Unlike errors, these never affect the bids.
+	Warnings map[BidderName][]string `json:"warnings,omitempty"`
 	// ExtResponseTimeMillis defines the contract for bidresponse.ext.responsetimemillis
 	ResponseTimeMillis map[BidderName]int `json:"responsetimemillis,omitempty"`
 	// ExtResponseUserSync defines the contract for bidresponse.ext.usersync
 	Usersync map[BidderName]*ExtResponseSyncData `json:"usersync,omitempty"`
+	// IGI defines the contract for bidresponse.ext.igi
+	IGI []*ExtIGI `json:"igi,omitempty"`
+	// Prebid defines the contract for bidresponse.ext.prebid
+	Prebid *ExtResponsePrebid `json:"prebid,omitempty"`
+}
+
+// ExtResponsePrebid defines the contract for bidresponse.ext.prebid
+type ExtResponsePrebid struct {
+	// Passthrough is a copy of bidrequest.ext.prebid.passthrough.
+	Passthrough openrtb.RawJSON `json:"passthrough,omitempty"`
+}
+
+// WarningsPrebid is the key in bidresponse.ext.warnings for warnings about the whole request, rather than one bidder.
+const WarningsPrebid BidderName = "prebid"
+
+// ExtIGI defines the contract for bidresponse.ext.igi[i]. It holds the interest group signals which let
+// the page run a Protected Audience (PAAPI) component auction for one imp. For more info, see:
+// https://github.com/InteractiveAdvertisingBureau/openrtb/blob/main/extensions/community_extensions/Protected%20Audience%20Support.md
+type ExtIGI struct {
+	ImpID string `json:"impid"`
+	// IGB are the interest group buyers which want to take part in the auction.
+	IGB []*ExtIGB `json:"igb,omitempty"`
+	// IGS are the sellers which want to run a component auction.
+	IGS []*ExtIGS `json:"igs,omitempty"`
+}
+
+// ExtIGB defines the contract for bidresponse.ext.igi[i].igb[j]
+type ExtIGB struct {
+	Origin string          `json:"origin"`
+	MaxBid float64         `json:"maxbid,omitempty"`
+	Cur    string          `json:"cur,omitempty"`
+	PBS    string          `json:"pbs,omitempty"`
+	PS     string          `json:"ps,omitempty"`
+	Ext    openrtb.RawJSON `json:"ext,omitempty"`
+}
+
+// ExtIGS defines the contract for bidresponse.ext.igi[i].igs[j]
+type ExtIGS struct {
+	ImpID  string          `json:"impid,omitempty"`
+	Config openrtb.RawJSON `json:"config,omitempty"`
+	Ext    openrtb.RawJSON `json:"ext,omitempty"`
 }
 
 // ExtResponseDebug defines the contract for bidresponse.ext.debug
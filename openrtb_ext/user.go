@@ -27,3 +27,11 @@ type ExtUserDigiTrust struct {
 	KeyV int    `json:"keyv"` // Key version used to encrypt ID
 	Pref int    `json:"pref"` // User optout preference
 }
+
+// ExtData defines the contract for bidrequest.user.data[i].ext
+type ExtData struct {
+	// SegTax is the IAB segment taxonomy which the segment IDs come from.
+	SegTax int `json:"segtax,omitempty"`
+	// SegClass is the version of the classifier which picked the segments.
+	SegClass string `json:"segclass,omitempty"`
+}
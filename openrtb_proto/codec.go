@@ -0,0 +1,408 @@
+package openrtb_proto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Protobuf wire types. See https://developers.google.com/protocol-buffers/docs/encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("openrtb_proto: unexpected end of message")
+
+// Marshal encodes an OpenRTB object from github.com/mxmCherry/openrtb (e.g. an *openrtb.BidResponse)
+// using the schema in openrtb.proto.
+//
+// Like the JSON encoding, fields with zero values are left out. Pointer fields are always encoded if they're set,
+// so that an explicit 0 (like imp.secure) survives the round trip.
+func Marshal(v interface{}) ([]byte, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, errors.New("openrtb_proto: can't marshal a nil object")
+		}
+		value = value.Elem()
+	}
+	return appendMessage(nil, value)
+}
+
+// Unmarshal decodes protobuf data into an OpenRTB object from github.com/mxmCherry/openrtb (e.g. an *openrtb.BidRequest).
+// Fields which aren't in openrtb.proto are skipped, so that clients can send fields from newer versions of the schema.
+func Unmarshal(data []byte, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.New("openrtb_proto: Unmarshal needs a non-nil pointer to a struct")
+	}
+	return decodeMessage(data, value.Elem())
+}
+
+// messagePlan says which struct field holds each protobuf field of a message.
+type messagePlan struct {
+	// fields are sorted by number, so that the encoding is stable.
+	fields   []fieldPlan
+	byNumber map[int]fieldPlan
+}
+
+type fieldPlan struct {
+	number int
+	index  int
+	name   string
+}
+
+var plans sync.Map
+
+func planFor(structType reflect.Type) (*messagePlan, error) {
+	if cached, ok := plans.Load(structType); ok {
+		return cached.(*messagePlan), nil
+	}
+	numbers, ok := fieldNumbers[structType.Name()]
+	if !ok {
+		return nil, fmt.Errorf("openrtb_proto: there are no field numbers for %s", structType.Name())
+	}
+	plan := &messagePlan{byNumber: make(map[int]fieldPlan, len(numbers))}
+	for i := 0; i < structType.NumField(); i++ {
+		name := strings.Split(structType.Field(i).Tag.Get("json"), ",")[0]
+		if number, ok := numbers[name]; ok {
+			field := fieldPlan{number: number, index: i, name: structType.Name() + "." + name}
+			plan.fields = append(plan.fields, field)
+			plan.byNumber[number] = field
+		}
+	}
+	sort.Slice(plan.fields, func(i, j int) bool {
+		return plan.fields[i].number < plan.fields[j].number
+	})
+	plans.Store(structType, plan)
+	return plan, nil
+}
+
+func appendMessage(buf []byte, value reflect.Value) ([]byte, error) {
+	plan, err := planFor(value.Type())
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range plan.fields {
+		if buf, err = appendField(buf, field, value.Field(field.index)); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendField(buf []byte, field fieldPlan, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return buf, nil
+		}
+		if value.Elem().Kind() == reflect.Struct {
+			return appendEmbedded(buf, field.number, value.Elem())
+		}
+		return appendScalar(buf, field, value.Elem())
+	case reflect.Struct:
+		return appendEmbedded(buf, field.number, value)
+	case reflect.Slice:
+		return appendSlice(buf, field, value)
+	}
+	if isZero(value) {
+		return buf, nil
+	}
+	return appendScalar(buf, field, value)
+}
+
+func appendEmbedded(buf []byte, number int, value reflect.Value) ([]byte, error) {
+	message, err := appendMessage(nil, value)
+	if err != nil {
+		return nil, err
+	}
+	buf = appendKey(buf, number, wireBytes)
+	buf = appendVarint(buf, uint64(len(message)))
+	return append(buf, message...), nil
+}
+
+func appendSlice(buf []byte, field fieldPlan, value reflect.Value) ([]byte, error) {
+	if value.Len() == 0 {
+		return buf, nil
+	}
+	elemType := value.Type().Elem()
+	switch {
+	case elemType.Kind() == reflect.Uint8:
+		// Every ext (and any other []byte) is sent as-is.
+		buf = appendKey(buf, field.number, wireBytes)
+		buf = appendVarint(buf, uint64(value.Len()))
+		return append(buf, value.Bytes()...), nil
+	case elemType.Kind() == reflect.String:
+		for i := 0; i < value.Len(); i++ {
+			buf = appendString(buf, field.number, value.Index(i).String())
+		}
+		return buf, nil
+	case elemType.Kind() == reflect.Struct || elemType.Kind() == reflect.Ptr && elemType.Elem().Kind() == reflect.Struct:
+		var err error
+		for i := 0; i < value.Len(); i++ {
+			elem := value.Index(i)
+			if elem.Kind() == reflect.Ptr {
+				if elem.IsNil() {
+					continue
+				}
+				elem = elem.Elem()
+			}
+			if buf, err = appendEmbedded(buf, field.number, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+
+	// Repeated numbers are packed, like proto3 does by default.
+	var packed []byte
+	var err error
+	for i := 0; i < value.Len(); i++ {
+		if packed, err = appendNumber(packed, field, value.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	buf = appendKey(buf, field.number, wireBytes)
+	buf = appendVarint(buf, uint64(len(packed)))
+	return append(buf, packed...), nil
+}
+
+func appendScalar(buf []byte, field fieldPlan, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.String:
+		return appendString(buf, field.number, value.String()), nil
+	case reflect.Float32, reflect.Float64:
+		buf = appendKey(buf, field.number, wireFixed64)
+	default:
+		buf = appendKey(buf, field.number, wireVarint)
+	}
+	return appendNumber(buf, field, value)
+}
+
+// appendNumber appends the value of a numeric field, without its key.
+func appendNumber(buf []byte, field fieldPlan, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendVarint(buf, uint64(value.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendVarint(buf, value.Uint()), nil
+	case reflect.Bool:
+		if value.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Float32, reflect.Float64:
+		return appendFixed64(buf, math.Float64bits(value.Float())), nil
+	}
+	return nil, fmt.Errorf("openrtb_proto: %s has unsupported type %s", field.name, value.Type())
+}
+
+func appendString(buf []byte, number int, s string) []byte {
+	buf = appendKey(buf, number, wireBytes)
+	buf = appendVarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendKey(buf []byte, number int, wireType int) []byte {
+	return appendVarint(buf, uint64(number)<<3|uint64(wireType))
+}
+
+func appendVarint(buf []byte, x uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], x)
+	return append(buf, scratch[:n]...)
+}
+
+func appendFixed64(buf []byte, x uint64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], x)
+	return append(buf, scratch[:]...)
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return value.Len() == 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	}
+	return false
+}
+
+func decodeMessage(data []byte, value reflect.Value) error {
+	plan, err := planFor(value.Type())
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		number, wireType := int(key>>3), int(key&7)
+
+		var varint uint64
+		var payload []byte
+		switch wireType {
+		case wireVarint:
+			if varint, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errTruncated
+			}
+			varint, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errTruncated
+			}
+			payload, data = data[n:n+int(length)], data[n+int(length):]
+		case wireFixed32:
+			if len(data) < 4 {
+				return errTruncated
+			}
+			varint, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("openrtb_proto: field %d of %s has unsupported wire type %d", number, value.Type().Name(), wireType)
+		}
+
+		field, ok := plan.byNumber[number]
+		if !ok {
+			continue
+		}
+		if err := decodeField(field, value.Field(field.index), wireType, varint, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeField(field fieldPlan, value reflect.Value, wireType int, varint uint64, payload []byte) error {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return decodeField(field, value.Elem(), wireType, varint, payload)
+	case reflect.Struct:
+		if wireType != wireBytes {
+			return wrongWireType(field, wireType)
+		}
+		return decodeMessage(payload, value)
+	case reflect.Slice:
+		return decodeRepeated(field, value, wireType, varint, payload)
+	}
+	return decodeScalar(field, value, wireType, varint, payload)
+}
+
+func decodeRepeated(field fieldPlan, value reflect.Value, wireType int, varint uint64, payload []byte) error {
+	elemType := value.Type().Elem()
+	switch elemType.Kind() {
+	case reflect.Uint8:
+		if wireType != wireBytes {
+			return wrongWireType(field, wireType)
+		}
+		value.SetBytes(append([]byte(nil), payload...))
+		return nil
+	case reflect.String, reflect.Struct, reflect.Ptr:
+		elem := reflect.New(elemType).Elem()
+		if err := decodeField(field, elem, wireType, varint, payload); err != nil {
+			return err
+		}
+		value.Set(reflect.Append(value, elem))
+		return nil
+	}
+
+	if wireType != wireBytes {
+		elem := reflect.New(elemType).Elem()
+		if err := decodeScalar(field, elem, wireType, varint, nil); err != nil {
+			return err
+		}
+		value.Set(reflect.Append(value, elem))
+		return nil
+	}
+	// Packed numbers.
+	for len(payload) > 0 {
+		elem := reflect.New(elemType).Elem()
+		if elemType.Kind() == reflect.Float32 || elemType.Kind() == reflect.Float64 {
+			if len(payload) < 8 {
+				return errTruncated
+			}
+			elem.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(payload)))
+			payload = payload[8:]
+		} else {
+			number, n := binary.Uvarint(payload)
+			if n <= 0 {
+				return errTruncated
+			}
+			if err := decodeScalar(field, elem, wireVarint, number, nil); err != nil {
+				return err
+			}
+			payload = payload[n:]
+		}
+		value.Set(reflect.Append(value, elem))
+	}
+	return nil
+}
+
+func decodeScalar(field fieldPlan, value reflect.Value, wireType int, varint uint64, payload []byte) error {
+	switch value.Kind() {
+	case reflect.String:
+		if wireType != wireBytes {
+			return wrongWireType(field, wireType)
+		}
+		value.SetString(string(payload))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if wireType != wireVarint {
+			return wrongWireType(field, wireType)
+		}
+		if value.OverflowInt(int64(varint)) {
+			return fmt.Errorf("openrtb_proto: %d is out of range for %s", int64(varint), field.name)
+		}
+		value.SetInt(int64(varint))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if wireType != wireVarint {
+			return wrongWireType(field, wireType)
+		}
+		if value.OverflowUint(varint) {
+			return fmt.Errorf("openrtb_proto: %d is out of range for %s", varint, field.name)
+		}
+		value.SetUint(varint)
+	case reflect.Bool:
+		if wireType != wireVarint {
+			return wrongWireType(field, wireType)
+		}
+		value.SetBool(varint != 0)
+	case reflect.Float32, reflect.Float64:
+		if wireType != wireFixed64 {
+			return wrongWireType(field, wireType)
+		}
+		value.SetFloat(math.Float64frombits(varint))
+	default:
+		return fmt.Errorf("openrtb_proto: %s has unsupported type %s", field.name, value.Type())
+	}
+	return nil
+}
+
+func wrongWireType(field fieldPlan, wireType int) error {
+	return fmt.Errorf("openrtb_proto: %s can't use wire type %d", field.name, wireType)
+}
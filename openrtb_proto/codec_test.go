@@ -0,0 +1,174 @@
+package openrtb_proto
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	secure := int8(0)
+	request := &openrtb.BidRequest{
+		ID: "some-request-id",
+		Imp: []openrtb.Imp{{
+			ID:       "imp-1",
+			Banner:   &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}, {W: 300, H: 600}}},
+			Video:    &openrtb.Video{MIMEs: []string{"video/mp4"}, StartDelay: openrtb.StartDelayGenericMidRoll.Ptr()},
+			BidFloor: 0.5,
+			Secure:   &secure,
+			Ext:      openrtb.RawJSON(`{"appnexus":{"placementId":10433394}}`),
+		}},
+		Site:   &openrtb.Site{Page: "http://example.com", Publisher: &openrtb.Publisher{ID: "pub-1"}},
+		Device: &openrtb.Device{UA: "some-ua", Geo: &openrtb.Geo{Lat: 51.5, Lon: -0.12}},
+		User:   &openrtb.User{ID: "user-1", Data: []openrtb.Data{{ID: "data-1", Segment: []openrtb.Segment{{ID: "seg-1"}}}}},
+		TMax:   500,
+		Cur:    []string{"USD", "EUR"},
+		Ext:    openrtb.RawJSON(`{"prebid":{"targeting":{}}}`),
+	}
+
+	data, err := Marshal(request)
+	if err != nil {
+		t.Fatalf("Failed to marshal the request: %v", err)
+	}
+	var decoded openrtb.BidRequest
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal the request: %v", err)
+	}
+	if !reflect.DeepEqual(request, &decoded) {
+		t.Errorf("The request changed in the round trip.\nExpected %#v\nGot %#v", request, &decoded)
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	response := &openrtb.BidResponse{
+		ID: "some-request-id",
+		SeatBid: []openrtb.SeatBid{{
+			Seat: "appnexus",
+			Bid: []openrtb.Bid{{
+				ID:      "bid-1",
+				ImpID:   "imp-1",
+				Price:   1.23,
+				AdM:     "<div>ad</div>",
+				ADomain: []string{"example.com"},
+				W:       300,
+				H:       250,
+				Ext:     openrtb.RawJSON(`{"prebid":{"type":"banner"}}`),
+			}},
+		}},
+		Cur: "USD",
+		NBR: openrtb.NoBidReasonCodeUnknownError.Ptr(),
+		Ext: openrtb.RawJSON(`{"responsetimemillis":{"appnexus":12}}`),
+	}
+
+	data, err := Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to marshal the response: %v", err)
+	}
+	var decoded openrtb.BidResponse
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal the response: %v", err)
+	}
+	if !reflect.DeepEqual(response, &decoded) {
+		t.Errorf("The response changed in the round trip.\nExpected %#v\nGot %#v", response, &decoded)
+	}
+}
+
+func TestUnpackedNumbers(t *testing.T) {
+	// Banner.api (field 10) sent as two separate varints, rather than packed.
+	data := []byte{10<<3 | wireVarint, 3, 10<<3 | wireVarint, 5}
+	var banner openrtb.Banner
+	if err := Unmarshal(data, &banner); err != nil {
+		t.Fatalf("Failed to unmarshal the banner: %v", err)
+	}
+	if len(banner.API) != 2 || banner.API[0] != 3 || banner.API[1] != 5 {
+		t.Errorf("Unpacked repeated numbers should be accepted. Got %v", banner.API)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	// Field 50 isn't in the schema. It should be skipped, and the id (field 1) kept.
+	// The key for field 50 is 50<<3 | wireBytes = 402, which is 0x92 0x03 as a varint.
+	data := []byte{0x92, 0x03, 2, 'h', 'i', 1<<3 | wireBytes, 2, 'i', 'd'}
+	var request openrtb.BidRequest
+	if err := Unmarshal(data, &request); err != nil {
+		t.Fatalf("Unknown fields should be skipped. Got %v", err)
+	}
+	if request.ID != "id" {
+		t.Errorf("Expected the request id after an unknown field. Got %s", request.ID)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	var request openrtb.BidRequest
+	if err := Unmarshal([]byte{1<<3 | wireBytes, 10, 'i'}, &request); err == nil {
+		t.Errorf("Truncated messages should be rejected.")
+	}
+	if err := Unmarshal([]byte{1<<3 | wireVarint, 1}, &request); err == nil {
+		t.Errorf("Fields with the wrong wire type should be rejected.")
+	}
+}
+
+// TestSchema makes sure that openrtb.proto, prebid.proto and fieldNumbers agree.
+func TestSchema(t *testing.T) {
+	schema := make(map[string]map[string]int)
+
+	// openrtb.proto nests the messages. Each field is added to the innermost message around it.
+	messageStart := regexp.MustCompile(`^\s*message (\w+) \{$`)
+	messageEnd := regexp.MustCompile(`^\s*\}$`)
+	fieldLine := regexp.MustCompile(`^\s*(?:optional|repeated|required) \w+ (\w+) = (\d+)(?: \[.*\])?;$`)
+	var messages []string
+	readSchema(t, "openrtb.proto", func(line string) {
+		if match := messageStart.FindStringSubmatch(line); match != nil {
+			messages = append(messages, goTypeName(match[1]))
+			schema[goTypeName(match[1])] = make(map[string]int)
+		} else if messageEnd.MatchString(line) {
+			messages = messages[:len(messages)-1]
+		} else if match := fieldLine.FindStringSubmatch(line); match != nil {
+			number, _ := strconv.Atoi(match[2])
+			schema[messages[len(messages)-1]][match[1]] = number
+		}
+	})
+
+	// prebid.proto declares the ext of each message as an extension.
+	extendStart := regexp.MustCompile(`^extend com\.google\.openrtb\.(?:\w+\.)*(\w+) \{$`)
+	extLine := regexp.MustCompile(`^\s+optional bytes \w+_ext = (\d+);$`)
+	var extended string
+	readSchema(t, "prebid.proto", func(line string) {
+		if match := extendStart.FindStringSubmatch(line); match != nil {
+			extended = goTypeName(match[1])
+		} else if match := extLine.FindStringSubmatch(line); match != nil {
+			number, _ := strconv.Atoi(match[1])
+			schema[extended]["ext"] = number
+		}
+	})
+
+	if !reflect.DeepEqual(schema, fieldNumbers) {
+		t.Errorf("The .proto files don't match fieldNumbers.\nSchema: %v\nfieldNumbers: %v", schema, fieldNumbers)
+	}
+}
+
+func readSchema(t *testing.T, path string, readLine func(line string)) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		readLine(scanner.Text())
+	}
+}
+
+// goTypeName returns the name of the github.com/mxmCherry/openrtb type for a message.
+func goTypeName(message string) string {
+	if message == "Pmp" {
+		return "PMP"
+	}
+	return message
+}
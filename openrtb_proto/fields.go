@@ -0,0 +1,114 @@
+package openrtb_proto
+
+// extField is the field number which every message uses for its ext. It's the first number in the extension
+// range which the standard OpenRTB messages reserve, and is declared as an extension in prebid.proto.
+const extField = 100
+
+// fieldNumbers maps each OpenRTB type's JSON field names to their protobuf field numbers.
+// The types are named as they are in github.com/mxmCherry/openrtb, and the numbers are the ones from
+// the standard OpenRTB 2.5 protobuf schema.
+//
+// This must match openrtb.proto and prebid.proto.
+var fieldNumbers = map[string]map[string]int{
+	"BidRequest": {
+		"id": 1, "imp": 2, "site": 3, "app": 4, "device": 5, "regs": 14, "user": 6, "at": 7, "tmax": 8,
+		"wseat": 9, "bseat": 17, "allimps": 10, "cur": 11, "wlang": 18, "bcat": 12, "badv": 13, "bapp": 16,
+		"test": 15, "source": 19, "ext": extField,
+	},
+	"Source": {
+		"fd": 1, "tid": 2, "pchain": 3, "ext": extField,
+	},
+	"Imp": {
+		"id": 1, "banner": 2, "video": 3, "audio": 15, "displaymanager": 4, "displaymanagerver": 5, "instl": 6,
+		"tagid": 7, "bidfloor": 8, "bidfloorcur": 9, "clickbrowser": 16, "secure": 12, "iframebuster": 10,
+		"pmp": 11, "native": 13, "exp": 14, "metric": 17, "ext": extField,
+	},
+	"Metric": {
+		"type": 1, "value": 2, "vendor": 3, "ext": extField,
+	},
+	"Banner": {
+		"w": 1, "h": 2, "format": 15, "wmax": 11, "hmax": 12, "wmin": 13, "hmin": 14, "btype": 5, "battr": 6,
+		"pos": 4, "mimes": 7, "topframe": 8, "expdir": 9, "api": 10, "id": 3, "vcm": 16, "ext": extField,
+	},
+	"Format": {
+		"w": 1, "h": 2, "wratio": 3, "hratio": 4, "wmin": 5, "ext": extField,
+	},
+	"Video": {
+		"mimes": 1, "minduration": 3, "maxduration": 4, "startdelay": 8, "protocols": 21, "protocol": 5, "w": 6,
+		"h": 7, "placement": 26, "linearity": 2, "skip": 23, "skipmin": 24, "skipafter": 25, "sequence": 9,
+		"battr": 10, "maxextended": 11, "minbitrate": 12, "maxbitrate": 13, "boxingallowed": 14,
+		"playbackmethod": 15, "playbackend": 27, "delivery": 16, "pos": 17, "companionad": 18, "api": 19,
+		"companiontype": 20, "ext": extField,
+	},
+	"Audio": {
+		"mimes": 1, "minduration": 2, "maxduration": 3, "protocols": 4, "startdelay": 5, "sequence": 6,
+		"battr": 7, "maxextended": 8, "minbitrate": 9, "maxbitrate": 10, "delivery": 11, "companionad": 12,
+		"api": 13, "companiontype": 20, "maxseq": 21, "feed": 22, "stitched": 23, "nvol": 24, "ext": extField,
+	},
+	"Native": {
+		"request": 1, "ver": 2, "api": 3, "battr": 4, "ext": extField,
+	},
+	"PMP": {
+		"private_auction": 1, "deals": 2, "ext": extField,
+	},
+	"Deal": {
+		"id": 1, "bidfloor": 2, "bidfloorcur": 3, "wseat": 4, "wadomain": 5, "at": 6, "ext": extField,
+	},
+	"Site": {
+		"id": 1, "name": 2, "domain": 3, "cat": 4, "sectioncat": 5, "pagecat": 6, "page": 7, "ref": 9,
+		"search": 10, "mobile": 15, "privacypolicy": 8, "publisher": 11, "content": 12, "keywords": 13,
+		"ext": extField,
+	},
+	"App": {
+		"id": 1, "name": 2, "bundle": 8, "domain": 3, "storeurl": 16, "cat": 4, "sectioncat": 5, "pagecat": 6,
+		"ver": 7, "privacypolicy": 9, "paid": 10, "publisher": 11, "content": 12, "keywords": 13,
+		"ext": extField,
+	},
+	"Publisher": {
+		"id": 1, "name": 2, "cat": 3, "domain": 4, "ext": extField,
+	},
+	"Content": {
+		"id": 1, "episode": 2, "title": 3, "series": 4, "season": 5, "artist": 21, "genre": 22, "album": 23,
+		"isrc": 24, "producer": 15, "url": 6, "cat": 7, "prodq": 25, "videoquality": 8, "context": 20,
+		"contentrating": 10, "userrating": 11, "qagmediarating": 17, "keywords": 9, "livestream": 13,
+		"sourcerelationship": 14, "len": 16, "language": 19, "embeddable": 18, "data": 28, "ext": extField,
+	},
+	"Producer": {
+		"id": 1, "name": 2, "cat": 3, "domain": 4, "ext": extField,
+	},
+	"Device": {
+		"geo": 4, "dnt": 1, "lmt": 23, "ua": 2, "ip": 3, "ipv6": 9, "devicetype": 18, "make": 12, "model": 13,
+		"os": 14, "osv": 15, "hwv": 24, "w": 25, "h": 26, "ppi": 27, "pxratio": 28, "js": 16, "geofetch": 29,
+		"flashver": 19, "language": 11, "carrier": 10, "mccmnc": 30, "connectiontype": 17, "ifa": 20,
+		"didsha1": 5, "didmd5": 6, "dpidsha1": 7, "dpidmd5": 8, "macsha1": 21, "macmd5": 22, "ext": extField,
+	},
+	"Geo": {
+		"lat": 1, "lon": 2, "type": 9, "accuracy": 11, "lastfix": 12, "ipservice": 13, "country": 3,
+		"region": 4, "regionfips104": 5, "metro": 6, "city": 7, "zip": 8, "utcoffset": 10, "ext": extField,
+	},
+	"User": {
+		"id": 1, "buyeruid": 2, "yob": 3, "gender": 4, "keywords": 5, "customdata": 6, "geo": 7, "data": 8,
+		"ext": extField,
+	},
+	"Data": {
+		"id": 1, "name": 2, "segment": 3, "ext": extField,
+	},
+	"Segment": {
+		"id": 1, "name": 2, "value": 3, "ext": extField,
+	},
+	"Regs": {
+		"coppa": 1, "ext": extField,
+	},
+	"BidResponse": {
+		"id": 1, "seatbid": 2, "bidid": 3, "cur": 4, "customdata": 5, "nbr": 6, "ext": extField,
+	},
+	"SeatBid": {
+		"bid": 1, "seat": 2, "group": 3, "ext": extField,
+	},
+	"Bid": {
+		"id": 1, "impid": 2, "price": 3, "adid": 4, "nurl": 5, "adm": 6, "adomain": 7, "bundle": 14, "iurl": 8,
+		"cid": 9, "crid": 10, "tactic": 24, "cat": 15, "attr": 11, "api": 18, "protocol": 19,
+		"qagmediarating": 20, "language": 25, "dealid": 13, "w": 16, "h": 17, "wratio": 26, "hratio": 27,
+		"exp": 21, "burl": 22, "lurl": 23, "ext": extField,
+	},
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/stored_requests"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 	"golang.org/x/net/publicsuffix"
 
 	"github.com/blang/semver"
@@ -203,7 +203,7 @@ func ParseMediaTypes(types []string) []MediaType {
 		for _, t := range types {
 			mt, er := ParseMediaType(t)
 			if er != nil {
-				glog.Infof("Invalid media type: %s", er)
+				logger.Infof("Invalid media type: %s", er)
 			} else {
 				if !mtmap[mt] {
 					mtypes = append(mtypes, mt)
@@ -307,16 +307,14 @@ func ParsePBSRequest(r *http.Request, cfg *config.AuctionTimeouts, cache cache.C
 			bidders, err = ConfigGet(cache, unit.ConfigID)
 			if err != nil {
 				if _, notFound := err.(*stored_requests.NotFoundError); !notFound {
-					glog.Warningf("Failed to load config '%s' from cache: %v", unit.ConfigID, err)
+					logger.Warningf("Failed to load config '%s' from cache: %v", unit.ConfigID, err)
 				}
 				// proceed with other ad units
 				continue
 			}
 		}
 
-		if glog.V(2) {
-			glog.Infof("Ad unit %s has %d bidders for %d sizes", unit.Code, len(bidders), len(unit.Sizes))
-		}
+		logger.Debugf("Ad unit %s has %d bidders for %d sizes", unit.Code, len(bidders), len(unit.Sizes))
 
 		mtypes := ParseMediaTypes(unit.MediaTypes)
 		for _, b := range bidders {
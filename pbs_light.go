@@ -4,23 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
-	"net/http/pprof"
+	"os"
+	"os/signal"
 	"sort"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
 	"github.com/mssola/user_agent"
 	"github.com/rs/cors"
 	"github.com/spf13/viper"
 
-	"crypto/tls"
 	"strings"
 
 	_ "github.com/lib/pq"
@@ -35,17 +36,22 @@ import (
 	"github.com/prebid/prebid-server/adapters/pulsepoint"
 	"github.com/prebid/prebid-server/adapters/rubicon"
 	"github.com/prebid/prebid-server/adapters/sovrn"
+	"github.com/prebid/prebid-server/alerts"
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
+	"github.com/prebid/prebid-server/blocklist"
 	"github.com/prebid/prebid-server/cache"
 	"github.com/prebid/prebid-server/cache/dummycache"
 	"github.com/prebid/prebid-server/cache/filecache"
 	"github.com/prebid/prebid-server/cache/postgrescache"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/endpoints"
 	infoEndpoints "github.com/prebid/prebid-server/endpoints/info"
 	"github.com/prebid/prebid-server/endpoints/openrtb2"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/ivt"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbs"
 	"github.com/prebid/prebid-server/pbsmetrics"
@@ -53,6 +59,7 @@ import (
 	pbc "github.com/prebid/prebid-server/prebid_cache_client"
 	"github.com/prebid/prebid-server/server"
 	"github.com/prebid/prebid-server/ssl"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync"
 	"github.com/prebid/prebid-server/usersync/usersyncers"
 
@@ -109,7 +116,7 @@ func writeAuctionError(w http.ResponseWriter, s string, err error) {
 	}
 	b, err := json.Marshal(&resp)
 	if err != nil {
-		glog.Errorf("Failed to marshal auction error JSON: %s", err)
+		logger.Errorf("Failed to marshal auction error JSON: %s", err)
 	} else {
 		w.Write(b)
 	}
@@ -156,9 +163,7 @@ func (deps *auctionDeps) auction(w http.ResponseWriter, r *http.Request, _ httpr
 	}()
 
 	if err != nil {
-		if glog.V(2) {
-			glog.Infof("Failed to parse /auction request: %v", err)
-		}
+		logger.Debugf("Failed to parse /auction request: %v", err)
 		writeAuctionError(w, "Error parsing request", err)
 		labels.RequestStatus = pbsmetrics.RequestStatusBadInput
 		return
@@ -177,14 +182,12 @@ func (deps *auctionDeps) auction(w http.ResponseWriter, r *http.Request, _ httpr
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(pbs_req.TimeoutMillis))
+	ctx, cancel := context.WithTimeout(tracing.Detach(r.Context()), time.Millisecond*time.Duration(pbs_req.TimeoutMillis))
 	defer cancel()
 
 	account, err := dataCache.Accounts().Get(pbs_req.AccountID)
 	if err != nil {
-		if glog.V(2) {
-			glog.Infof("Invalid account id: %v", err)
-		}
+		logger.Debugf("Invalid account id: %v", err)
 		writeAuctionError(w, "Unknown account id", fmt.Errorf("Unknown account"))
 		labels.RequestStatus = pbsmetrics.RequestStatusBadInput
 		return
@@ -244,7 +247,7 @@ func (deps *auctionDeps) auction(w http.ResponseWriter, r *http.Request, _ httpr
 			sentBids++
 			go func(bidder *pbs.PBSBidder, blables pbsmetrics.AdapterLabels) {
 				start := time.Now()
-				bid_list, err := ex.Call(ctx, pbs_req, bidder)
+				bid_list, err := adapters.SafeCall(ctx, ex, pbs_req, bidder)
 				deps.metricsEngine.RecordAdapterTime(blabels, time.Since(start))
 				bidder.ResponseTime = int(time.Since(start) / time.Millisecond)
 				if err != nil {
@@ -263,7 +266,7 @@ func (deps *auctionDeps) auction(w http.ResponseWriter, r *http.Request, _ httpr
 						case *adapters.BadServerResponseError:
 							blabels.AdapterErrors = map[pbsmetrics.AdapterError]struct{}{pbsmetrics.AdapterErrorBadServerResponse: s}
 						default:
-							glog.Warningf("Error from bidder %v. Ignoring all bids: %v", bidder.BidderCode, err)
+							logger.Warningf("Error from bidder %v. Ignoring all bids: %v", bidder.BidderCode, err)
 							blabels.AdapterErrors = map[pbsmetrics.AdapterError]struct{}{pbsmetrics.AdapterErrorUnknown: s}
 						}
 					}
@@ -348,9 +351,7 @@ func (deps *auctionDeps) auction(w http.ResponseWriter, r *http.Request, _ httpr
 		sortBidsAddKeywordsMobile(pbs_resp.Bids, pbs_req, account.PriceGranularity)
 	}
 
-	if glog.V(2) {
-		glog.Infof("Request for %d ad units on url %s by account %s got %d bids", len(pbs_req.AdUnits), pbs_req.Url, pbs_req.AccountID, len(pbs_resp.Bids))
-	}
+	logger.Debugf("Request for %d ad units on url %s by account %s got %d bids", len(pbs_req.AdUnits), pbs_req.Url, pbs_req.AccountID, len(pbs_resp.Bids))
 
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(false)
@@ -422,7 +423,7 @@ bidLoop:
 						finalValidBids[finalBidCounter] = bid
 						finalBidCounter = finalBidCounter + 1
 					} else if len(adunit.Sizes) > 1 {
-						glog.Warningf("Bid was rejected for bidder %s because no size was defined", bid.BidderCode)
+						logger.Warningf("Bid was rejected for bidder %s because no size was defined", bid.BidderCode)
 					}
 					continue bidLoop
 				}
@@ -454,9 +455,7 @@ func sortBidsAddKeywordsMobile(bids pbs.PBSBidSlice, pbs_req *pbs.PBSRequest, pr
 		bar := code_bids[unit.Code]
 
 		if len(bar) == 0 {
-			if glog.V(3) {
-				glog.Infof("No bids for ad unit '%s'", unit.Code)
-			}
+			logger.Debugf("No bids for ad unit '%s'", unit.Code)
 			continue
 		}
 		sort.Sort(bar)
@@ -466,7 +465,7 @@ func sortBidsAddKeywordsMobile(bids pbs.PBSBidSlice, pbs_req *pbs.PBSRequest, pr
 			// We should eventually check for the error and do something.
 			roundedCpm, err := exchange.GetCpmStringValue(bid.Price, openrtb_ext.PriceGranularityFromString(priceGranularitySetting))
 			if err != nil {
-				glog.Error(err.Error())
+				logger.Error(err.Error())
 			}
 
 			hbSize := ""
@@ -536,11 +535,14 @@ func sortBidsAddKeywordsMobile(bids pbs.PBSBidSlice, pbs_req *pbs.PBSRequest, pr
 //
 // This function stores the file contents in memory, and should not be used on large directories.
 // If the root directory, or any of the files in it, cannot be read, then the program will exit.
-func NewJsonDirectoryServer(validator openrtb_ext.BidderParamValidator) httprouter.Handle {
+//
+// Requests with an account query param get the schemas which that account's params are checked against: only the
+// bidders in its accounts.known[].bidders, if it has any, and its bidder_params schemas combined with the bidders'.
+func NewJsonDirectoryServer(validator openrtb_ext.BidderParamValidator, cfg *config.Configuration) httprouter.Handle {
 	// Slurp the files into memory first, since they're small and it minimizes request latency.
 	files, err := ioutil.ReadDir(schemaDirectory)
 	if err != nil {
-		glog.Fatalf("Failed to read directory %s: %v", schemaDirectory, err)
+		logger.Fatalf("Failed to read directory %s: %v", schemaDirectory, err)
 	}
 
 	data := make(map[string]json.RawMessage, len(files))
@@ -548,21 +550,61 @@ func NewJsonDirectoryServer(validator openrtb_ext.BidderParamValidator) httprout
 		bidder := strings.TrimSuffix(file.Name(), ".json")
 		bidderName, isValid := openrtb_ext.BidderMap[bidder]
 		if !isValid {
-			glog.Fatalf("Schema exists for an unknown bidder: %s", bidder)
+			logger.Fatalf("Schema exists for an unknown bidder: %s", bidder)
 		}
 		data[bidder] = json.RawMessage(validator.Schema(bidderName))
 	}
 	response, err := json.Marshal(data)
 	if err != nil {
-		glog.Fatalf("Failed to marshal bidder param JSON-schema: %v", err)
+		logger.Fatalf("Failed to marshal bidder param JSON-schema: %v", err)
+	}
+
+	// The accounts are known up front, so their responses are built now too.
+	accountResponses := make(map[string][]byte)
+	accountSchemas := cfg.BidderParams.AccountSchemas()
+	accounts := make([]string, 0, len(accountSchemas)+len(cfg.Accounts.Known))
+	for account := range accountSchemas {
+		accounts = append(accounts, account)
+	}
+	for _, account := range cfg.Accounts.Known {
+		if len(account.Bidders) > 0 {
+			accounts = append(accounts, account.ID)
+		}
+	}
+	for _, account := range accounts {
+		if accountResponses[account], err = json.Marshal(accountBidderParams(data, accountSchemas[account], cfg.Accounts.Lookup(account))); err != nil {
+			logger.Fatalf("Failed to marshal bidder param JSON-schema for account %s: %v", account, err)
+		}
 	}
 
 	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		w.Header().Add("Content-Type", "application/json")
+		if accountResponse, ok := accountResponses[r.URL.Query().Get("account")]; ok {
+			w.Write(accountResponse)
+			return
+		}
 		w.Write(response)
 	}
 }
 
+// accountBidderParams returns the bidders' schemas for an account. Bidders which the account can't use are left out.
+// If the account has its own schema for a bidder, the params have to pass both, so the two are combined with allOf.
+func accountBidderParams(schemas map[string]json.RawMessage, accountSchemas map[string]string, account *config.Account) map[string]json.RawMessage {
+	result := make(map[string]json.RawMessage, len(schemas))
+	for bidder, schema := range schemas {
+		if account != nil && !account.AllowsBidder(bidder) {
+			continue
+		}
+		result[bidder] = schema
+		for accountBidder, accountSchema := range accountSchemas {
+			if strings.EqualFold(accountBidder, bidder) {
+				result[bidder] = json.RawMessage(`{"allOf":[` + string(schema) + `,` + accountSchema + `]}`)
+			}
+		}
+	}
+	return result
+}
+
 func serveIndex(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	http.ServeFile(w, r, "static/index.html")
 }
@@ -583,7 +625,7 @@ func loadDataCache(cfg *config.Configuration, db *sql.DB) (err error) {
 	case "dummy":
 		dataCache, err = dummycache.New()
 		if err != nil {
-			glog.Fatalf("Dummy cache not configured: %s", err.Error())
+			logger.Fatalf("Dummy cache not configured: %s", err.Error())
 		}
 
 	case "postgres":
@@ -609,8 +651,6 @@ func loadDataCache(cfg *config.Configuration, db *sql.DB) (err error) {
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
-
-	flag.Parse() // read glog settings from cmd line
 }
 
 func main() {
@@ -618,11 +658,68 @@ func main() {
 	config.SetupViper(v)
 	cfg, err := config.New(v)
 	if err != nil {
-		glog.Fatalf("Configuration could not be loaded or did not pass validation: %v", err)
+		logger.Fatalf("Configuration could not be loaded or did not pass validation: %v", err)
+	}
+	if err := setupLogging(&cfg.Logging); err != nil {
+		logger.Fatalf("Logging could not be set up: %v", err)
 	}
 
-	if err := serve(Rev, cfg); err != nil {
-		glog.Errorf("prebid-server failed: %v", err)
+	if err := serve(Rev, v, cfg); err != nil {
+		logger.Errorf("prebid-server failed: %v", err)
+	}
+}
+
+// setupLogging sends the logs to the configured output, in the configured format.
+func setupLogging(cfg *config.Logging) error {
+	level, err := logger.ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	var out io.Writer
+	switch cfg.Output {
+	case "stdout":
+		out = os.Stdout
+	case "file":
+		out = logger.NewFileWriter(cfg.File.Path, cfg.File.MaxSizeMB, cfg.File.MaxBackups, cfg.File.MaxAgeDays, cfg.File.Compress)
+	case "syslog":
+		if out, err = logger.NewSyslogWriter(cfg.Syslog.Network, cfg.Syslog.Address, cfg.Syslog.Tag); err != nil {
+			return err
+		}
+	default:
+		out = os.Stderr
+	}
+	logger.Setup(level, cfg.Format, out)
+	return nil
+}
+
+// newConfigReloader returns a func which reloads the config, and rebuilds the exchanges with it if it passes validation.
+func newConfigReloader(v *viper.Viper, cfg *config.Configuration, exchanges ...*exchange.ReloadableExchange) func() error {
+	var lock sync.Mutex
+	current := cfg
+	return func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		next, err := config.Reload(v, current)
+		if err != nil {
+			return err
+		}
+		for _, theExchange := range exchanges {
+			theExchange.Reload(next)
+		}
+		current = next
+		logger.Infof("Reloaded the config.")
+		return nil
+	}
+}
+
+// reloadOnSignal reloads the config whenever the process gets a SIGHUP.
+func reloadOnSignal(reload func() error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := reload(); err != nil {
+			logger.Errorf("Kept the old config, because the new one could not be loaded: %v", err)
+		}
 	}
 }
 
@@ -636,7 +733,7 @@ func newExchangeMap(cfg *config.Configuration) map[string]adapters.Adapter {
 		"pulsepoint":    pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["pulsepoint"].Endpoint),
 		"rubicon": rubicon.NewRubiconAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["rubicon"].Endpoint,
 			cfg.Adapters["rubicon"].XAPI.Username, cfg.Adapters["rubicon"].XAPI.Password, cfg.Adapters["rubicon"].XAPI.Tracker),
-		"audienceNetwork": audienceNetwork.NewAdapterFromFacebook(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["facebook"].PlatformID),
+		"audienceNetwork": audienceNetwork.NewAdapterFromFacebook(adapters.DefaultHTTPAdapterConfig, audienceNetwork.PlatformID(cfg.Adapters["facebook"].ExtraInfo, cfg.Adapters["facebook"].PlatformID)),
 		"lifestreet":      lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig),
 		"conversant":      conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["conversant"].Endpoint),
 		"adform":          adform.NewAdformAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters["adform"].Endpoint),
@@ -644,62 +741,137 @@ func newExchangeMap(cfg *config.Configuration) map[string]adapters.Adapter {
 	}
 }
 
-func serve(revision string, cfg *config.Configuration) error {
+func serve(revision string, v *viper.Viper, cfg *config.Configuration) error {
+	shutdownTracing, err := tracing.Setup(&cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("Prebid Server could not set up tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Errorf("Error flushing traces: %v", err)
+		}
+	}()
+
 	router := httprouter.New()
+	tlsConfig, err := ssl.NewTLSConfig(&cfg.AdapterTLS)
+	if err != nil {
+		return fmt.Errorf("Prebid Server could not set up TLS for the adapters: %v", err)
+	}
 	theClient := &http.Client{
 		Transport: &http.Transport{
 			MaxIdleConns:        400,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     60 * time.Second,
-			TLSClientConfig:     &tls.Config{RootCAs: ssl.GetRootCAPool()},
+			TLSClientConfig:     tlsConfig,
 		},
 	}
-	fetcher, ampFetcher, db, shutdown := storedRequestsConf.NewStoredRequests(&cfg.StoredRequests, theClient, router)
-	defer shutdown()
-
-	if err := loadDataCache(cfg, db); err != nil {
-		return fmt.Errorf("Prebid Server could not load data cache: %v", err)
+	// Register the host's aliases first, so that they get their own metrics, syncers and adapters.
+	for alias, coreBidder := range cfg.AdapterAliases() {
+		if err := openrtb_ext.SetAliasBidderName(alias, openrtb_ext.BidderName(coreBidder)); err != nil {
+			return fmt.Errorf("Prebid Server could not register the aliases in the adapters config: %v", err)
+		}
 	}
 
-	pbsAnalytics := analyticsConf.NewPBSAnalytics(&cfg.Analytics)
-
 	// Hack because of how legacy handles districtm
 	bidderList := openrtb_ext.BidderList()
 	bidderList = append(bidderList, openrtb_ext.BidderName("districtm"))
 
 	metricsEngine := metricsConf.NewMetricsEngine(cfg, bidderList)
 
+	fetcher, ampFetcher, db, shutdown := storedRequestsConf.NewStoredRequests(&cfg.StoredRequests, theClient, router, metricsEngine)
+	defer shutdown()
+
+	if err := loadDataCache(cfg, db); err != nil {
+		return fmt.Errorf("Prebid Server could not load data cache: %v", err)
+	}
+
+	syncers := usersyncers.NewSyncerMap(cfg)
+	if errs := usersyncers.ValidateSyncers(syncers); len(errs) > 0 {
+		return fmt.Errorf("Prebid Server has invalid usersync URLs: %v", errs)
+	}
+	gdprPerms := gdpr.NewPermissions(context.Background(), cfg.GDPR, usersyncers.GDPRAwareSyncerIDs(syncers), theClient)
+
+	pbsAnalytics := analyticsConf.NewPBSAnalytics(&cfg.Analytics, gdprPerms)
+
 	paramsValidator, err := openrtb_ext.NewBidderParamsValidator(schemaDirectory)
 	if err != nil {
-		glog.Fatalf("Failed to create the bidder params validator. %v", err)
+		logger.Fatalf("Failed to create the bidder params validator. %v", err)
 	}
 
 	exchanges = newExchangeMap(cfg)
-	theExchange := exchange.NewExchange(theClient, pbc.NewClient(&cfg.CacheURL), cfg, metricsEngine)
-
-	openrtbEndpoint, err := openrtb2.NewEndpoint(theExchange, paramsValidator, fetcher, cfg, metricsEngine, pbsAnalytics)
+	currencyConverter := currencies.NewRateConverter(theClient, cfg.CurrencyConverter.FetchURL, time.Duration(cfg.CurrencyConverter.FetchIntervalSeconds)*time.Second)
+	if cfg.Alerts.WebhookURL != "" {
+		// The monitor counts each bidder's requests, so it has to join the metrics engines before the exchange uses them.
+		monitor := alerts.NewMonitor(cfg.Alerts, theClient, currencyConverter, gdpr.VendorListFetchFailures)
+		metricsEngine.MetricsEngine = &metricsConf.MultiMetricsEngine{metricsEngine.MetricsEngine, monitor}
+	}
+	categoryFetcher := storedRequestsConf.NewCategoryFetcher(&cfg.StoredRequests, theClient, db)
+	blocked, err := blocklist.NewList(&cfg.Blocklist, db)
+	if err != nil {
+		logger.Fatalf("Failed to load the blocklist: %v", err)
+	}
+	ivtFilter, err := ivt.NewFilter(&cfg.InvalidTraffic)
 	if err != nil {
-		glog.Fatalf("Failed to create the openrtb endpoint handler. %v", err)
+		logger.Fatalf("Failed to set up the invalid traffic filter: %v", err)
+	}
+	responseCapture := exchange.NewResponseCapture(cfg.ResponseCapture)
+	// The cache's and the enrichers' settings can't be reloaded, so they're built once, and shared by every exchange
+	// which the reloads build. Building them again would leave the old connections, files and goroutines open.
+	cacheClient := pbc.NewClient(&cfg.CacheURL)
+	enrichers, err := exchange.NewEnrichers(cfg, theClient)
+	if err != nil {
+		logger.Fatalf("Failed to set up the enrichers: %v", err)
+	}
+	buildExchange := func(cfg *config.Configuration) exchange.Exchange {
+		return exchange.NewExchange(theClient, cacheClient, cfg, metricsEngine, gdprPerms, currencyConverter, categoryFetcher, responseCapture, enrichers)
+	}
+	theExchange := exchange.NewReloadableExchange(buildExchange, cfg)
+	buildReplayExchange := func(cfg *config.Configuration) exchange.Exchange {
+		return exchange.NewReplayExchange(theClient, cfg, gdprPerms, currencyConverter, categoryFetcher)
+	}
+	replayExchange := exchange.NewReloadableExchange(buildReplayExchange, cfg)
+	reloadConfig := newConfigReloader(v, cfg, theExchange, replayExchange)
+	go reloadOnSignal(reloadConfig)
+	var auctionExchange exchange.Exchange = theExchange
+	if cfg.Snapshots.Enabled {
+		// Snapshots are taken inside the dedup, so that they only record the auctions which really ran.
+		snapshotExchange, err := exchange.NewSnapshotExchange(theExchange, cfg.Snapshots)
+		if err != nil {
+			logger.Fatalf("Failed to set up the snapshots directory: %v", err)
+		}
+		auctionExchange = snapshotExchange
+	}
+	if cfg.AuctionDedup.Enabled {
+		auctionExchange = exchange.NewDedupingExchange(auctionExchange, cfg.AuctionDedup)
 	}
 
-	ampEndpoint, err := openrtb2.NewAmpEndpoint(theExchange, paramsValidator, ampFetcher, cfg, metricsEngine, pbsAnalytics)
+	bidderInfos := adapters.ParseBidderInfos("./static/bidder-info", openrtb_ext.BidderList())
+
+	openrtbEndpoint, err := openrtb2.NewEndpoint(auctionExchange, paramsValidator, fetcher, cfg, metricsEngine, pbsAnalytics, bidderInfos, blocked, ivtFilter)
 	if err != nil {
-		glog.Fatalf("Failed to create the amp endpoint handler. %v", err)
+		logger.Fatalf("Failed to create the openrtb endpoint handler. %v", err)
 	}
 
-	syncers := usersyncers.NewSyncerMap(cfg)
-	gdprPerms := gdpr.NewPermissions(context.Background(), cfg.GDPR, usersyncers.GDPRAwareSyncerIDs(syncers), theClient)
+	ampEndpoint, err := openrtb2.NewAmpEndpoint(auctionExchange, paramsValidator, ampFetcher, cfg, metricsEngine, pbsAnalytics, bidderInfos, blocked, ivtFilter)
+	if err != nil {
+		logger.Fatalf("Failed to create the amp endpoint handler. %v", err)
+	}
 
-	bidderInfos := adapters.ParseBidderInfos("./static/bidder-info", openrtb_ext.BidderList())
+	sdkEndpoint, err := openrtb2.NewSDKEndpoint(auctionExchange, paramsValidator, fetcher, cfg, metricsEngine, pbsAnalytics, bidderInfos, blocked, ivtFilter)
+	if err != nil {
+		logger.Fatalf("Failed to create the sdk endpoint handler. %v", err)
+	}
 
-	router.POST("/auction", (&auctionDeps{cfg, syncers, gdprPerms, metricsEngine}).auction)
-	router.POST("/openrtb2/auction", openrtbEndpoint)
-	router.GET("/openrtb2/amp", ampEndpoint)
+	router.POST("/auction", tracing.Handle("auction", (&auctionDeps{cfg, syncers, gdprPerms, metricsEngine}).auction))
+	router.POST("/openrtb2/auction", tracing.Handle("openrtb2.auction", openrtbEndpoint))
+	router.GET("/openrtb2/amp", tracing.Handle("openrtb2.amp", ampEndpoint))
+	router.POST("/openrtb2/sdk", tracing.Handle("openrtb2.sdk", sdkEndpoint))
 	router.GET("/info/bidders", infoEndpoints.NewBiddersEndpoint())
 	router.GET("/info/bidders/:bidderName", infoEndpoints.NewBidderDetailsEndpoint(bidderInfos))
-	router.GET("/bidders/params", NewJsonDirectoryServer(paramsValidator))
-	router.POST("/cookie_sync", endpoints.NewCookieSyncEndpoint(syncers, &(cfg.HostCookie), gdprPerms, metricsEngine, pbsAnalytics))
+	router.GET("/bidders/params", NewJsonDirectoryServer(paramsValidator, cfg))
+	router.POST("/cookie_sync", tracing.Handle("cookie_sync", endpoints.NewCookieSyncEndpoint(syncers, &(cfg.HostCookie), gdprPerms, &cfg.Activities, &cfg.CookieDeprecation, metricsEngine, pbsAnalytics)))
 	router.GET("/status", endpoints.NewStatusEndpoint(cfg.StatusResponse))
+	router.GET("/event", endpoints.NewEventEndpoint(metricsEngine))
 	router.GET("/", serveIndex)
 	router.ServeFiles("/static/*filepath", http.Dir("static"))
 
@@ -711,7 +883,7 @@ func serve(revision string, cfg *config.Configuration) error {
 		PBSAnalytics:     pbsAnalytics,
 	}
 
-	router.GET("/setuid", endpoints.NewSetUIDEndpoint(cfg.HostCookie, gdprPerms, pbsAnalytics, metricsEngine))
+	router.GET("/setuid", endpoints.NewSetUIDEndpoint(cfg.HostCookie, cfg.SetUID, gdprPerms, pbsAnalytics, metricsEngine))
 	router.POST("/optout", userSyncDeps.OptOut)
 	router.GET("/optout", userSyncDeps.OptOut)
 
@@ -727,18 +899,21 @@ func serve(revision string, cfg *config.Configuration) error {
 	noCacheHandler := NoCache{corsRouter}
 
 	// Add endpoints to the admin server
-	// Making sure to add pprof routes
 	adminRouter := http.NewServeMux()
 
-	// Register pprof handlers
-	adminRouter.HandleFunc("/debug/pprof/", pprof.Index)
-	adminRouter.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	adminRouter.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	adminRouter.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	adminRouter.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// Register the pprof, expvar and goroutine dump handlers, if enabled
+	endpoints.AddProfilingEndpoints(adminRouter, cfg.Profiling)
 
-	// Register prebid-server defined admin handlers
+	// Register prebid-server defined admin handlers. The ones which change the server or show its data need the admin token.
+	adminToken := cfg.Admin.Token
+	if adminToken == "" {
+		logger.Warning("The admin endpoints are enabled without a token. Anyone who can reach the admin port can reload the config, replay snapshots, and see the currency rates and malformed responses.")
+	}
 	adminRouter.HandleFunc("/version", endpoints.NewVersionEndpoint(revision))
+	adminRouter.HandleFunc("/currency/rates", endpoints.RequireToken(adminToken, endpoints.NewCurrencyRatesEndpoint(currencyConverter)))
+	adminRouter.HandleFunc("/config/reload", endpoints.RequireToken(adminToken, endpoints.NewConfigReloadEndpoint(reloadConfig)))
+	adminRouter.HandleFunc("/bidders/malformed_responses", endpoints.RequireToken(adminToken, endpoints.NewMalformedResponsesEndpoint(responseCapture)))
+	adminRouter.HandleFunc("/snapshots/replay", endpoints.RequireToken(adminToken, endpoints.NewSnapshotReplayEndpoint(replayExchange)))
 
 	server.Listen(cfg, noCacheHandler, adminRouter, metricsEngine)
 	return nil
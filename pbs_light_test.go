@@ -415,6 +415,10 @@ func (m *mockPermissions) BidderSyncAllowed(ctx context.Context, bidder openrtb_
 	return m.allowBidderSync, nil
 }
 
+func (m *mockPermissions) AuctionPermissions(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (gdpr.AuctionPermissions, error) {
+	return gdpr.AllowAll, nil
+}
+
 func TestBidSizeValidate(t *testing.T) {
 
 	bids := make(pbs.PBSBidSlice, 0)
@@ -581,7 +585,7 @@ func TestBidSizeValidate(t *testing.T) {
 }
 
 func TestNewJsonDirectoryServer(t *testing.T) {
-	handler := NewJsonDirectoryServer(&testValidator{})
+	handler := NewJsonDirectoryServer(&testValidator{}, &config.Configuration{})
 	recorder := httptest.NewRecorder()
 	request, _ := http.NewRequest("GET", "/whatever", nil)
 	handler(recorder, request, nil)
@@ -602,6 +606,31 @@ func TestNewJsonDirectoryServer(t *testing.T) {
 	}
 }
 
+func TestAccountBidderParams(t *testing.T) {
+	schemas := map[string]json.RawMessage{
+		"appnexus": json.RawMessage(`{"appnexus":true}`),
+		"rubicon":  json.RawMessage(`{"appnexus":false}`),
+		"pubmatic": json.RawMessage(`{"appnexus":false}`),
+	}
+	accountSchemas := map[string]string{"AppNexus": `{"required":["placementId"]}`}
+
+	data := accountBidderParams(schemas, accountSchemas, nil)
+	if len(data) != 3 {
+		t.Errorf("Accounts which aren't known should get every bidder. Got %v", data)
+	}
+	if schema := string(data["appnexus"]); schema != `{"allOf":[{"appnexus":true},{"required":["placementId"]}]}` {
+		t.Errorf("The account's schema should be combined with the bidder's. Got %s", schema)
+	}
+	if schema := string(data["rubicon"]); schema != `{"appnexus":false}` {
+		t.Errorf("Bidders without an account schema should be unchanged. Got %s", schema)
+	}
+
+	data = accountBidderParams(schemas, nil, &config.Account{ID: "acct-1", Bidders: []string{"rubicon", "pubmatic"}})
+	if _, ok := data["appnexus"]; ok || len(data) != 2 {
+		t.Errorf("Only the account's bidders should be served. Got %v", data)
+	}
+}
+
 func TestWriteAuctionError(t *testing.T) {
 	recorder := httptest.NewRecorder()
 	writeAuctionError(recorder, "some error message", nil)
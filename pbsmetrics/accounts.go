@@ -0,0 +1,62 @@
+package pbsmetrics
+
+import "sync"
+
+// AccountOther is the account label which is used for all the accounts which don't get metrics of their own.
+const AccountOther = "other"
+
+// AccountLimiter decides which accounts get metrics of their own. Each account adds a full set of series
+// to the metrics backend, so recording every account which sends a request can overwhelm it.
+//
+// The accounts in the allowlist always get their own metrics. Up to maxAccounts others do too, on a first come,
+// first served basis since startup. The rest share the AccountOther label.
+type AccountLimiter struct {
+	allowed     map[string]bool
+	maxAccounts int
+
+	mutex sync.RWMutex
+	seen  map[string]bool
+}
+
+// NewAccountLimiter makes an AccountLimiter. A negative maxAccounts means that every account gets its own metrics.
+func NewAccountLimiter(allowlist []string, maxAccounts int) *AccountLimiter {
+	allowed := make(map[string]bool, len(allowlist))
+	for _, id := range allowlist {
+		allowed[id] = true
+	}
+	return &AccountLimiter{
+		allowed:     allowed,
+		maxAccounts: maxAccounts,
+		seen:        make(map[string]bool),
+	}
+}
+
+// Label returns the label to record the account's metrics under: its ID if it gets metrics of its own,
+// or AccountOther if it doesn't. A nil AccountLimiter lets every account have its own metrics.
+func (l *AccountLimiter) Label(id string) string {
+	if l == nil || l.maxAccounts < 0 || l.allowed[id] {
+		return id
+	}
+
+	l.mutex.RLock()
+	seen := l.seen[id]
+	full := len(l.seen) >= l.maxAccounts
+	l.mutex.RUnlock()
+	if seen {
+		return id
+	}
+	if full {
+		return AccountOther
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.seen[id] {
+		return id
+	}
+	if len(l.seen) >= l.maxAccounts {
+		return AccountOther
+	}
+	l.seen[id] = true
+	return id
+}
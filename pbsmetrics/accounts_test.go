@@ -0,0 +1,47 @@
+package pbsmetrics
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestAccountLimiter(t *testing.T) {
+	limiter := NewAccountLimiter([]string{"big-pub"}, 1)
+
+	assertLabel(t, limiter, "first", "first")
+	assertLabel(t, limiter, "second", AccountOther)
+	assertLabel(t, limiter, "first", "first")
+	assertLabel(t, limiter, "big-pub", "big-pub")
+}
+
+func TestUnlimitedAccounts(t *testing.T) {
+	var nilLimiter *AccountLimiter
+	assertLabel(t, nilLimiter, "some-pub", "some-pub")
+	assertLabel(t, NewAccountLimiter(nil, -1), "some-pub", "some-pub")
+	assertLabel(t, NewAccountLimiter(nil, 0), "some-pub", AccountOther)
+}
+
+func TestLimitedAccountMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.AccountLimiter = NewAccountLimiter([]string{"big-pub"}, 0)
+
+	m.RecordRequest(Labels{RType: ReqTypeORTB2Web, RequestStatus: RequestStatusOK, PubID: "big-pub"})
+	m.RecordRequest(Labels{RType: ReqTypeORTB2Web, RequestStatus: RequestStatusOK, PubID: "small-pub"})
+	m.RecordRequest(Labels{RType: ReqTypeORTB2Web, RequestStatus: RequestStatusOK, PubID: "tiny-pub"})
+
+	VerifyMetrics(t, "account.big-pub.requests", m.getAccountMetrics("big-pub").requestMeter.Count(), 1)
+	VerifyMetrics(t, "account.other.requests", m.getAccountMetrics(AccountOther).requestMeter.Count(), 2)
+	if registry.Get("account.small-pub.requests") != nil {
+		t.Error("Accounts which aren't allowed their own metrics shouldn't be registered.")
+	}
+}
+
+func assertLabel(t *testing.T, limiter *AccountLimiter, id string, expected string) {
+	t.Helper()
+	if label := limiter.Label(id); label != expected {
+		t.Errorf("Bad label for account %s. Expected %s, got %s", id, expected, label)
+	}
+}
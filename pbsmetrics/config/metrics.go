@@ -19,10 +19,16 @@ func NewMetricsEngine(cfg *config.Configuration, adapterList []openrtb_ext.Bidde
 	// of 1 we won't use the list so it will be garbage collected.
 	engineList := make(MultiMetricsEngine, 0, 2)
 	returnEngine := DetailedMetricsEngine{}
+	// The engines share a limiter, so that they agree on which accounts get their own metrics.
+	var accountLimiter *pbsmetrics.AccountLimiter
+	if cfg.Metrics.Accounts.Limited() {
+		accountLimiter = pbsmetrics.NewAccountLimiter(cfg.Metrics.Accounts.Allowlist, cfg.Metrics.Accounts.MaxAccounts)
+	}
 
 	if cfg.Metrics.Influxdb.Host != "" {
 		// Currently use go-metrics as the metrics piece for influx
 		returnEngine.GoMetrics = pbsmetrics.NewMetrics(metrics.NewPrefixedRegistry("prebidserver."), adapterList)
+		returnEngine.GoMetrics.AccountLimiter = accountLimiter
 		engineList = append(engineList, returnEngine.GoMetrics)
 		// Set up the Influx logger
 		go influxdb.InfluxDB(
@@ -38,6 +44,7 @@ func NewMetricsEngine(cfg *config.Configuration, adapterList []openrtb_ext.Bidde
 	if cfg.Metrics.Prometheus.Port != 0 {
 		// Set up the Prometheus metrics.
 		returnEngine.PrometheusMetrics = prometheusmetrics.NewMetrics(cfg.Metrics.Prometheus)
+		returnEngine.PrometheusMetrics.AccountLimiter = accountLimiter
 		engineList = append(engineList, returnEngine.PrometheusMetrics)
 	}
 
@@ -125,6 +132,90 @@ func (me *MultiMetricsEngine) RecordAdapterTime(labels pbsmetrics.AdapterLabels,
 	}
 }
 
+// RecordAdapterBidWon across all engines
+func (me *MultiMetricsEngine) RecordAdapterBidWon(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType, cpm float64) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterBidWon(labels, bidType, cpm)
+	}
+}
+
+// RecordAdapterNoBid across all engines
+func (me *MultiMetricsEngine) RecordAdapterNoBid(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterNoBidReason) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterNoBid(labels, reason)
+	}
+}
+
+// RecordAdapterRetry across all engines
+func (me *MultiMetricsEngine) RecordAdapterRetry(labels pbsmetrics.AdapterLabels, result pbsmetrics.AdapterRetryResult) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterRetry(labels, result)
+	}
+}
+
+// RecordAdapterBidRejected across all engines
+func (me *MultiMetricsEngine) RecordAdapterBidRejected(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterBidRejection) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterBidRejected(labels, reason)
+	}
+}
+
+// RecordAdapterHTTPPhase across all engines
+func (me *MultiMetricsEngine) RecordAdapterHTTPPhase(labels pbsmetrics.AdapterLabels, phase pbsmetrics.AdapterHTTPPhase, length time.Duration) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterHTTPPhase(labels, phase, length)
+	}
+}
+
+// RecordAdapterConnection across all engines
+func (me *MultiMetricsEngine) RecordAdapterConnection(labels pbsmetrics.AdapterLabels, connection pbsmetrics.AdapterConnection) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterConnection(labels, connection)
+	}
+}
+
+// RecordStoredDataFetchTime across all engines
+func (me *MultiMetricsEngine) RecordStoredDataFetchTime(labels pbsmetrics.StoredDataLabels, length time.Duration) {
+	for _, thisME := range *me {
+		thisME.RecordStoredDataFetchTime(labels, length)
+	}
+}
+
+// RecordStoredDataError across all engines
+func (me *MultiMetricsEngine) RecordStoredDataError(labels pbsmetrics.StoredDataLabels) {
+	for _, thisME := range *me {
+		thisME.RecordStoredDataError(labels)
+	}
+}
+
+// RecordStoredDataCacheResult across all engines
+func (me *MultiMetricsEngine) RecordStoredDataCacheResult(dataType pbsmetrics.StoredDataType, result pbsmetrics.CacheResult, inc int) {
+	for _, thisME := range *me {
+		thisME.RecordStoredDataCacheResult(dataType, result, inc)
+	}
+}
+
+// RecordPrivacyScrubbed across all engines
+func (me *MultiMetricsEngine) RecordPrivacyScrubbed(policy pbsmetrics.PrivacyPolicy) {
+	for _, thisME := range *me {
+		thisME.RecordPrivacyScrubbed(policy)
+	}
+}
+
+// RecordCurrencyConversion across all engines
+func (me *MultiMetricsEngine) RecordCurrencyConversion(conversion pbsmetrics.CurrencyConversion) {
+	for _, thisME := range *me {
+		thisME.RecordCurrencyConversion(conversion)
+	}
+}
+
+// RecordEvent across all engines
+func (me *MultiMetricsEngine) RecordEvent(event pbsmetrics.EventType) {
+	for _, thisME := range *me {
+		thisME.RecordEvent(event)
+	}
+}
+
 // RecordCookieSync across all engines
 func (me *MultiMetricsEngine) RecordCookieSync(labels pbsmetrics.Labels) {
 	for _, thisME := range *me {
@@ -187,6 +278,66 @@ func (me *DummyMetricsEngine) RecordAdapterTime(labels pbsmetrics.AdapterLabels,
 	return
 }
 
+// RecordAdapterBidWon as a noop
+func (me *DummyMetricsEngine) RecordAdapterBidWon(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType, cpm float64) {
+	return
+}
+
+// RecordAdapterNoBid as a noop
+func (me *DummyMetricsEngine) RecordAdapterNoBid(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterNoBidReason) {
+	return
+}
+
+// RecordAdapterRetry as a noop
+func (me *DummyMetricsEngine) RecordAdapterRetry(labels pbsmetrics.AdapterLabels, result pbsmetrics.AdapterRetryResult) {
+	return
+}
+
+// RecordAdapterBidRejected as a noop
+func (me *DummyMetricsEngine) RecordAdapterBidRejected(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterBidRejection) {
+	return
+}
+
+// RecordAdapterHTTPPhase as a noop
+func (me *DummyMetricsEngine) RecordAdapterHTTPPhase(labels pbsmetrics.AdapterLabels, phase pbsmetrics.AdapterHTTPPhase, length time.Duration) {
+	return
+}
+
+// RecordAdapterConnection as a noop
+func (me *DummyMetricsEngine) RecordAdapterConnection(labels pbsmetrics.AdapterLabels, connection pbsmetrics.AdapterConnection) {
+	return
+}
+
+// RecordStoredDataFetchTime as a noop
+func (me *DummyMetricsEngine) RecordStoredDataFetchTime(labels pbsmetrics.StoredDataLabels, length time.Duration) {
+	return
+}
+
+// RecordStoredDataError as a noop
+func (me *DummyMetricsEngine) RecordStoredDataError(labels pbsmetrics.StoredDataLabels) {
+	return
+}
+
+// RecordStoredDataCacheResult as a noop
+func (me *DummyMetricsEngine) RecordStoredDataCacheResult(dataType pbsmetrics.StoredDataType, result pbsmetrics.CacheResult, inc int) {
+	return
+}
+
+// RecordPrivacyScrubbed as a noop
+func (me *DummyMetricsEngine) RecordPrivacyScrubbed(policy pbsmetrics.PrivacyPolicy) {
+	return
+}
+
+// RecordCurrencyConversion as a noop
+func (me *DummyMetricsEngine) RecordCurrencyConversion(conversion pbsmetrics.CurrencyConversion) {
+	return
+}
+
+// RecordEvent as a noop
+func (me *DummyMetricsEngine) RecordEvent(event pbsmetrics.EventType) {
+	return
+}
+
 // RecordCookieSync as a noop
 func (me *DummyMetricsEngine) RecordCookieSync(labels pbsmetrics.Labels) {
 	return
@@ -78,6 +78,8 @@ func TestMultiMetricsEngine(t *testing.T) {
 		metricsEngine.RecordAdapterPrice(pubLabels, 1.34)
 		metricsEngine.RecordAdapterBidReceived(pubLabels, openrtb_ext.BidTypeBanner, true)
 		metricsEngine.RecordAdapterTime(pubLabels, time.Millisecond*20)
+		metricsEngine.RecordAdapterBidWon(pubLabels, openrtb_ext.BidTypeBanner, 1.34)
+		metricsEngine.RecordAdapterNoBid(apnLabels, pbsmetrics.AdapterNoBidEmpty)
 	}
 	VerifyMetrics(t, "RequestStatuses.OpenRTB2.OK", goEngine.RequestStatuses[pbsmetrics.ReqTypeORTB2Web][pbsmetrics.RequestStatusOK].Count(), 5)
 	VerifyMetrics(t, "RequestStatuses.Legacy.OK", goEngine.RequestStatuses[pbsmetrics.ReqTypeLegacy][pbsmetrics.RequestStatusOK].Count(), 0)
@@ -96,6 +98,8 @@ func TestMultiMetricsEngine(t *testing.T) {
 	}
 	VerifyMetrics(t, "AdapterMetrics.AppNexus.GotBidsMeter", goEngine.AdapterMetrics[openrtb_ext.BidderAppnexus].GotBidsMeter.Count(), 0)
 	VerifyMetrics(t, "AdapterMetrics.AppNexus.NoBidMeter", goEngine.AdapterMetrics[openrtb_ext.BidderAppnexus].NoBidMeter.Count(), 5)
+	VerifyMetrics(t, "AdapterMetrics.AppNexus.NoBidReasonMeters.Empty", goEngine.AdapterMetrics[openrtb_ext.BidderAppnexus].NoBidReasonMeters[pbsmetrics.AdapterNoBidEmpty].Count(), 5)
+	VerifyMetrics(t, "AdapterMetrics.Pubmatic.BidsWonMeter", goEngine.AdapterMetrics[openrtb_ext.BidderPubmatic].BidsWonMeter.Count(), 5)
 }
 
 func VerifyMetrics(t *testing.T, name string, expected int64, actual int64) {
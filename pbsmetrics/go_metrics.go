@@ -5,7 +5,7 @@ import (
 	"sync"
 	"time"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/rcrowley/go-metrics"
 )
@@ -32,6 +32,16 @@ type Metrics struct {
 	userSyncSet         map[openrtb_ext.BidderName]metrics.Meter
 	userSyncGDPRPrevent map[openrtb_ext.BidderName]metrics.Meter
 
+	StoredDataFetchTimers map[StoredDataType]map[StoredDataSource]metrics.Timer
+	StoredDataErrorMeters map[StoredDataType]map[StoredDataSource]map[StoredDataError]metrics.Meter
+	StoredDataCacheMeters map[StoredDataType]map[CacheResult]metrics.Meter
+
+	PrivacyScrubbedMeters map[PrivacyPolicy]metrics.Meter
+
+	CurrencyConversionMeters map[CurrencyConversion]metrics.Meter
+
+	EventMeters map[EventType]metrics.Meter
+
 	AdapterMetrics map[openrtb_ext.BidderName]*AdapterMetrics
 	// Don't export accountMetrics because we need helper functions here to insure its properly populated dynamically
 	accountMetrics        map[string]*accountMetrics
@@ -39,6 +49,8 @@ type Metrics struct {
 	userSyncRwMutex       sync.RWMutex
 
 	exchanges []openrtb_ext.BidderName
+	// AccountLimiter decides which accounts get their own metrics. If it's nil, they all do.
+	AccountLimiter *AccountLimiter
 }
 
 // AdapterMetrics houses the metrics for a particular adapter
@@ -50,7 +62,15 @@ type AdapterMetrics struct {
 	RequestTimer      metrics.Timer
 	PriceHistogram    metrics.Histogram
 	BidsReceivedMeter metrics.Meter
+	BidsWonMeter      metrics.Meter
+	WonPriceHistogram metrics.Histogram
+	NoBidReasonMeters map[AdapterNoBidReason]metrics.Meter
+	RetryMeters       map[AdapterRetryResult]metrics.Meter
+	RejectedMeters    map[AdapterBidRejection]metrics.Meter
 	MarkupMetrics     map[openrtb_ext.BidType]*MarkupDeliveryMetrics
+	// The network timings don't depend on the account, so these are only registered for the adapter itself.
+	HTTPPhaseTimers  map[AdapterHTTPPhase]metrics.Timer
+	ConnectionMeters map[AdapterConnection]metrics.Meter
 }
 
 type MarkupDeliveryMetrics struct {
@@ -97,6 +117,15 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		userSyncSet:                make(map[openrtb_ext.BidderName]metrics.Meter),
 		userSyncGDPRPrevent:        make(map[openrtb_ext.BidderName]metrics.Meter),
 
+		StoredDataFetchTimers: make(map[StoredDataType]map[StoredDataSource]metrics.Timer),
+		StoredDataErrorMeters: make(map[StoredDataType]map[StoredDataSource]map[StoredDataError]metrics.Meter),
+		StoredDataCacheMeters: make(map[StoredDataType]map[CacheResult]metrics.Meter),
+
+		PrivacyScrubbedMeters: make(map[PrivacyPolicy]metrics.Meter),
+
+		CurrencyConversionMeters: make(map[CurrencyConversion]metrics.Meter),
+		EventMeters:              make(map[EventType]metrics.Meter),
+
 		AdapterMetrics: make(map[openrtb_ext.BidderName]*AdapterMetrics, len(exchanges)),
 		accountMetrics: make(map[string]*accountMetrics),
 
@@ -113,6 +142,34 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		}
 	}
 
+	for _, dt := range StoredDataTypes() {
+		newMetrics.StoredDataFetchTimers[dt] = make(map[StoredDataSource]metrics.Timer)
+		newMetrics.StoredDataErrorMeters[dt] = make(map[StoredDataSource]map[StoredDataError]metrics.Meter)
+		for _, src := range StoredDataSources() {
+			newMetrics.StoredDataFetchTimers[dt][src] = &metrics.NilTimer{}
+			newMetrics.StoredDataErrorMeters[dt][src] = make(map[StoredDataError]metrics.Meter)
+			for _, err := range StoredDataErrors() {
+				newMetrics.StoredDataErrorMeters[dt][src][err] = blankMeter
+			}
+		}
+		newMetrics.StoredDataCacheMeters[dt] = make(map[CacheResult]metrics.Meter)
+		for _, cr := range CacheResults() {
+			newMetrics.StoredDataCacheMeters[dt][cr] = blankMeter
+		}
+	}
+
+	for _, policy := range PrivacyPolicies() {
+		newMetrics.PrivacyScrubbedMeters[policy] = blankMeter
+	}
+
+	for _, conversion := range CurrencyConversions() {
+		newMetrics.CurrencyConversionMeters[conversion] = blankMeter
+	}
+
+	for _, event := range EventTypes() {
+		newMetrics.EventMeters[event] = blankMeter
+	}
+
 	return newMetrics
 }
 
@@ -146,6 +203,32 @@ func NewMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderName) *
 			statusMap[stat] = metrics.GetOrRegisterMeter("requests."+string(stat)+"."+string(typ), registry)
 		}
 	}
+	for dt, timers := range newMetrics.StoredDataFetchTimers {
+		for src := range timers {
+			timers[src] = metrics.GetOrRegisterTimer(fmt.Sprintf("stored_data.%s.%s.fetch_time", dt, src), registry)
+		}
+	}
+	for dt, sourceMap := range newMetrics.StoredDataErrorMeters {
+		for src, errMap := range sourceMap {
+			for err := range errMap {
+				errMap[err] = metrics.GetOrRegisterMeter(fmt.Sprintf("stored_data.%s.%s.errors.%s", dt, src, err), registry)
+			}
+		}
+	}
+	for dt, resultMap := range newMetrics.StoredDataCacheMeters {
+		for cr := range resultMap {
+			resultMap[cr] = metrics.GetOrRegisterMeter(fmt.Sprintf("stored_data.%s.cache.%s", dt, cr), registry)
+		}
+	}
+	for policy := range newMetrics.PrivacyScrubbedMeters {
+		newMetrics.PrivacyScrubbedMeters[policy] = metrics.GetOrRegisterMeter(fmt.Sprintf("privacy.%s.scrubbed_requests", policy), registry)
+	}
+	for conversion := range newMetrics.CurrencyConversionMeters {
+		newMetrics.CurrencyConversionMeters[conversion] = metrics.GetOrRegisterMeter(fmt.Sprintf("currency.conversions.%s", conversion), registry)
+	}
+	for event := range newMetrics.EventMeters {
+		newMetrics.EventMeters[event] = metrics.GetOrRegisterMeter(fmt.Sprintf("events.%s", event), registry)
+	}
 	newMetrics.userSyncSet[unknownBidder] = metrics.GetOrRegisterMeter("usersync.unknown.sets", registry)
 	newMetrics.userSyncGDPRPrevent[unknownBidder] = metrics.GetOrRegisterMeter("usersync.unknown.gdpr_prevent", registry)
 	return newMetrics
@@ -162,11 +245,33 @@ func makeBlankAdapterMetrics() *AdapterMetrics {
 		RequestTimer:      &metrics.NilTimer{},
 		PriceHistogram:    &metrics.NilHistogram{},
 		BidsReceivedMeter: blankMeter,
+		BidsWonMeter:      blankMeter,
+		WonPriceHistogram: &metrics.NilHistogram{},
+		NoBidReasonMeters: make(map[AdapterNoBidReason]metrics.Meter),
+		RetryMeters:       make(map[AdapterRetryResult]metrics.Meter),
+		RejectedMeters:    make(map[AdapterBidRejection]metrics.Meter),
 		MarkupMetrics:     makeBlankBidMarkupMetrics(),
+		HTTPPhaseTimers:   make(map[AdapterHTTPPhase]metrics.Timer),
+		ConnectionMeters:  make(map[AdapterConnection]metrics.Meter),
 	}
 	for _, err := range AdapterErrors() {
 		newAdapter.ErrorMeters[err] = blankMeter
 	}
+	for _, reason := range AdapterNoBidReasons() {
+		newAdapter.NoBidReasonMeters[reason] = blankMeter
+	}
+	for _, result := range AdapterRetryResults() {
+		newAdapter.RetryMeters[result] = blankMeter
+	}
+	for _, reason := range AdapterBidRejections() {
+		newAdapter.RejectedMeters[reason] = blankMeter
+	}
+	for _, phase := range AdapterHTTPPhases() {
+		newAdapter.HTTPPhaseTimers[phase] = &metrics.NilTimer{}
+	}
+	for _, connection := range AdapterConnections() {
+		newAdapter.ConnectionMeters[connection] = blankMeter
+	}
 	return newAdapter
 }
 
@@ -192,6 +297,8 @@ func registerAdapterMetrics(registry metrics.Registry, adapterOrAccount string,
 	am.GotBidsMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.requests.gotbids", adapterOrAccount, exchange), registry)
 	am.RequestTimer = metrics.GetOrRegisterTimer(fmt.Sprintf("%[1]s.%[2]s.request_time", adapterOrAccount, exchange), registry)
 	am.PriceHistogram = metrics.GetOrRegisterHistogram(fmt.Sprintf("%[1]s.%[2]s.prices", adapterOrAccount, exchange), registry, metrics.NewExpDecaySample(1028, 0.015))
+	am.BidsWonMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.bids_won", adapterOrAccount, exchange), registry)
+	am.WonPriceHistogram = metrics.GetOrRegisterHistogram(fmt.Sprintf("%[1]s.%[2]s.won_prices", adapterOrAccount, exchange), registry, metrics.NewExpDecaySample(1028, 0.015))
 	am.MarkupMetrics = map[openrtb_ext.BidType]*MarkupDeliveryMetrics{
 		openrtb_ext.BidTypeBanner: makeDeliveryMetrics(registry, adapterOrAccount+"."+exchange, openrtb_ext.BidTypeBanner),
 		openrtb_ext.BidTypeVideo:  makeDeliveryMetrics(registry, adapterOrAccount+"."+exchange, openrtb_ext.BidTypeVideo),
@@ -201,8 +308,24 @@ func registerAdapterMetrics(registry metrics.Registry, adapterOrAccount string,
 	for err := range am.ErrorMeters {
 		am.ErrorMeters[err] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.requests.%s", adapterOrAccount, exchange, err), registry)
 	}
+	for reason := range am.NoBidReasonMeters {
+		am.NoBidReasonMeters[reason] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.nobid_reasons.%s", adapterOrAccount, exchange, reason), registry)
+	}
+	for result := range am.RetryMeters {
+		am.RetryMeters[result] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.retries.%s", adapterOrAccount, exchange, result), registry)
+	}
+	for reason := range am.RejectedMeters {
+		am.RejectedMeters[reason] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.rejected_bids.%s", adapterOrAccount, exchange, reason), registry)
+	}
 	if adapterOrAccount != "adapter" {
 		am.BidsReceivedMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.bids_received", adapterOrAccount, exchange), registry)
+	} else {
+		for phase := range am.HTTPPhaseTimers {
+			am.HTTPPhaseTimers[phase] = metrics.GetOrRegisterTimer(fmt.Sprintf("adapter.%s.http_time.%s", exchange, phase), registry)
+		}
+		for connection := range am.ConnectionMeters {
+			am.ConnectionMeters[connection] = metrics.GetOrRegisterMeter(fmt.Sprintf("adapter.%s.connections.%s", exchange, connection), registry)
+		}
 	}
 }
 
@@ -218,6 +341,7 @@ func makeDeliveryMetrics(registry metrics.Registry, prefix string, bidType openr
 func (me *Metrics) getAccountMetrics(id string) *accountMetrics {
 	var am *accountMetrics
 	var ok bool
+	id = me.AccountLimiter.Label(id)
 
 	me.accountMetricsRWMutex.RLock()
 	am, ok = me.accountMetrics[id]
@@ -311,7 +435,7 @@ func (me *Metrics) RecordRequestTime(labels Labels, length time.Duration) {
 func (me *Metrics) RecordAdapterRequest(labels AdapterLabels) {
 	am, ok := me.AdapterMetrics[labels.Adapter]
 	if !ok {
-		glog.Errorf("Trying to run adapter metrics on %s: adapter metrics not found", string(labels.Adapter))
+		logger.Errorf("Trying to run adapter metrics on %s: adapter metrics not found", string(labels.Adapter))
 		return
 	}
 
@@ -324,7 +448,7 @@ func (me *Metrics) RecordAdapterRequest(labels AdapterLabels) {
 		am.GotBidsMeter.Mark(1)
 		aam.GotBidsMeter.Mark(1)
 	default:
-		glog.Warningf("No go-metrics logged for AdapterBids value: %s", labels.AdapterBids)
+		logger.Warningf("No go-metrics logged for AdapterBids value: %s", labels.AdapterBids)
 	}
 	for errType := range labels.AdapterErrors {
 		am.ErrorMeters[errType].Mark(1)
@@ -340,7 +464,7 @@ func (me *Metrics) RecordAdapterRequest(labels AdapterLabels) {
 func (me *Metrics) RecordAdapterBidReceived(labels AdapterLabels, bidType openrtb_ext.BidType, hasAdm bool) {
 	am, ok := me.AdapterMetrics[labels.Adapter]
 	if !ok {
-		glog.Errorf("Trying to run adapter bid metrics on %s: adapter metrics not found", string(labels.Adapter))
+		logger.Errorf("Trying to run adapter bid metrics on %s: adapter metrics not found", string(labels.Adapter))
 		return
 	}
 
@@ -357,7 +481,7 @@ func (me *Metrics) RecordAdapterBidReceived(labels AdapterLabels, bidType openrt
 			metricsForType.NurlMeter.Mark(1)
 		}
 	} else {
-		glog.Errorf("bid/adm metrics map entry does not exist for type %s. This is a bug, and should be reported.", bidType)
+		logger.Errorf("bid/adm metrics map entry does not exist for type %s. This is a bug, and should be reported.", bidType)
 	}
 	return
 }
@@ -366,7 +490,7 @@ func (me *Metrics) RecordAdapterBidReceived(labels AdapterLabels, bidType openrt
 func (me *Metrics) RecordAdapterPrice(labels AdapterLabels, cpm float64) {
 	am, ok := me.AdapterMetrics[labels.Adapter]
 	if !ok {
-		glog.Errorf("Trying to run adapter price metrics on %s: adapter metrics not found", string(labels.Adapter))
+		logger.Errorf("Trying to run adapter price metrics on %s: adapter metrics not found", string(labels.Adapter))
 		return
 	}
 	// Adapter metrics
@@ -380,7 +504,7 @@ func (me *Metrics) RecordAdapterPrice(labels AdapterLabels, cpm float64) {
 func (me *Metrics) RecordAdapterTime(labels AdapterLabels, length time.Duration) {
 	am, ok := me.AdapterMetrics[labels.Adapter]
 	if !ok {
-		glog.Errorf("Trying to run adapter latency metrics on %s: adapter metrics not found", string(labels.Adapter))
+		logger.Errorf("Trying to run adapter latency metrics on %s: adapter metrics not found", string(labels.Adapter))
 		return
 	}
 	// Adapter metrics
@@ -390,6 +514,147 @@ func (me *Metrics) RecordAdapterTime(labels AdapterLabels, length time.Duration)
 	aam.RequestTimer.Update(length)
 }
 
+// RecordAdapterBidWon implements a part of the MetricsEngine interface. Counts the bids which won their imp,
+// and generates a histogram of the winning prices.
+func (me *Metrics) RecordAdapterBidWon(labels AdapterLabels, bidType openrtb_ext.BidType, cpm float64) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		logger.Errorf("Trying to run adapter win metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	// Adapter metrics
+	am.BidsWonMeter.Mark(1)
+	am.WonPriceHistogram.Update(int64(cpm))
+	// Account-Adapter metrics
+	aam := me.getAccountMetrics(labels.PubID).adapterMetrics[labels.Adapter]
+	aam.BidsWonMeter.Mark(1)
+	aam.WonPriceHistogram.Update(int64(cpm))
+}
+
+// RecordAdapterNoBid implements a part of the MetricsEngine interface. Records the reason an adapter had no bids
+func (me *Metrics) RecordAdapterNoBid(labels AdapterLabels, reason AdapterNoBidReason) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		logger.Errorf("Trying to run adapter no-bid metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	if meter, ok := am.NoBidReasonMeters[reason]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for AdapterNoBidReason value: %s", reason)
+	}
+}
+
+// RecordAdapterRetry implements a part of the MetricsEngine interface. Records whether a retried call to an adapter succeeded
+func (me *Metrics) RecordAdapterRetry(labels AdapterLabels, result AdapterRetryResult) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		logger.Errorf("Trying to run adapter retry metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	if meter, ok := am.RetryMeters[result]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for AdapterRetryResult value: %s", result)
+	}
+}
+
+// RecordAdapterBidRejected implements a part of the MetricsEngine interface. Records a bid which was removed from the auction by the host's rules
+func (me *Metrics) RecordAdapterBidRejected(labels AdapterLabels, reason AdapterBidRejection) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		logger.Errorf("Trying to run adapter bid rejection metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	if meter, ok := am.RejectedMeters[reason]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for AdapterBidRejection value: %s", reason)
+	}
+}
+
+// RecordAdapterHTTPPhase implements a part of the MetricsEngine interface. Records how long one phase of an HTTP call to an adapter took
+func (me *Metrics) RecordAdapterHTTPPhase(labels AdapterLabels, phase AdapterHTTPPhase, length time.Duration) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		logger.Errorf("Trying to run adapter HTTP timing metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	if timer, ok := am.HTTPPhaseTimers[phase]; ok {
+		timer.Update(length)
+	} else {
+		logger.Warningf("No go-metrics logged for AdapterHTTPPhase value: %s", phase)
+	}
+}
+
+// RecordAdapterConnection implements a part of the MetricsEngine interface. Records whether an HTTP call to an adapter needed a new connection
+func (me *Metrics) RecordAdapterConnection(labels AdapterLabels, connection AdapterConnection) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		logger.Errorf("Trying to run adapter connection metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	if meter, ok := am.ConnectionMeters[connection]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for AdapterConnection value: %s", connection)
+	}
+}
+
+// RecordStoredDataFetchTime implements a part of the MetricsEngine interface. Records the time taken by a stored data backend
+func (me *Metrics) RecordStoredDataFetchTime(labels StoredDataLabels, length time.Duration) {
+	if timer, ok := me.StoredDataFetchTimers[labels.DataType][labels.Source]; ok {
+		timer.Update(length)
+	} else {
+		logger.Warningf("No go-metrics logged for stored data type %s from source %s", labels.DataType, labels.Source)
+	}
+}
+
+// RecordStoredDataError implements a part of the MetricsEngine interface. Records a failed stored data fetch
+func (me *Metrics) RecordStoredDataError(labels StoredDataLabels) {
+	if meter, ok := me.StoredDataErrorMeters[labels.DataType][labels.Source][labels.Error]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for stored data error %s (type %s, source %s)", labels.Error, labels.DataType, labels.Source)
+	}
+}
+
+// RecordStoredDataCacheResult implements a part of the MetricsEngine interface. Records cache hits and misses for stored data
+func (me *Metrics) RecordStoredDataCacheResult(dataType StoredDataType, result CacheResult, inc int) {
+	if meter, ok := me.StoredDataCacheMeters[dataType][result]; ok {
+		meter.Mark(int64(inc))
+	} else {
+		logger.Warningf("No go-metrics logged for stored data cache result %s (type %s)", result, dataType)
+	}
+}
+
+// RecordPrivacyScrubbed implements a part of the MetricsEngine interface. Records an auction scrubbed for a privacy policy
+func (me *Metrics) RecordPrivacyScrubbed(policy PrivacyPolicy) {
+	if meter, ok := me.PrivacyScrubbedMeters[policy]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for privacy policy %s", policy)
+	}
+}
+
+// RecordCurrencyConversion implements a part of the MetricsEngine interface. Records a bid price converted between currencies
+func (me *Metrics) RecordCurrencyConversion(conversion CurrencyConversion) {
+	if meter, ok := me.CurrencyConversionMeters[conversion]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for currency conversion %s", conversion)
+	}
+}
+
+// RecordEvent implements a part of the MetricsEngine interface. Records an event reported by a tracker
+func (me *Metrics) RecordEvent(event EventType) {
+	if meter, ok := me.EventMeters[event]; ok {
+		meter.Mark(1)
+	} else {
+		logger.Warningf("No go-metrics logged for event %s", event)
+	}
+}
+
 // RecordCookieSync implements a part of the MetricsEngine interface. Records a cookie sync request
 func (me *Metrics) RecordCookieSync(labels Labels) {
 	me.CookieSyncMeter.Mark(1)
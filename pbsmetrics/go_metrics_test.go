@@ -2,6 +2,7 @@ package pbsmetrics
 
 import (
 	"testing"
+	"time"
 
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/rcrowley/go-metrics"
@@ -29,12 +30,29 @@ func TestNewMetrics(t *testing.T) {
 	ensureContains(t, registry, "requests.ok.openrtb2-web", m.RequestStatuses[ReqTypeORTB2Web][RequestStatusOK])
 	ensureContains(t, registry, "requests.badinput.openrtb2-web", m.RequestStatuses[ReqTypeORTB2Web][RequestStatusBadInput])
 	ensureContains(t, registry, "requests.err.openrtb2-web", m.RequestStatuses[ReqTypeORTB2Web][RequestStatusErr])
+	ensureContains(t, registry, "requests.blocked.openrtb2-web", m.RequestStatuses[ReqTypeORTB2Web][RequestStatusBlocked])
+	ensureContains(t, registry, "requests.invalid_traffic.openrtb2-web", m.RequestStatuses[ReqTypeORTB2Web][RequestStatusInvalidTraffic])
 	ensureContains(t, registry, "requests.ok.openrtb2-app", m.RequestStatuses[ReqTypeORTB2App][RequestStatusOK])
 	ensureContains(t, registry, "requests.badinput.openrtb2-app", m.RequestStatuses[ReqTypeORTB2App][RequestStatusBadInput])
 	ensureContains(t, registry, "requests.err.openrtb2-app", m.RequestStatuses[ReqTypeORTB2App][RequestStatusErr])
 	ensureContains(t, registry, "requests.ok.amp", m.RequestStatuses[ReqTypeAMP][RequestStatusOK])
 	ensureContains(t, registry, "requests.badinput.amp", m.RequestStatuses[ReqTypeAMP][RequestStatusBadInput])
 	ensureContains(t, registry, "requests.err.amp", m.RequestStatuses[ReqTypeAMP][RequestStatusErr])
+	ensureContains(t, registry, "requests.blocked.amp", m.RequestStatuses[ReqTypeAMP][RequestStatusBlocked])
+	ensureContains(t, registry, "requests.invalid_traffic.amp", m.RequestStatuses[ReqTypeAMP][RequestStatusInvalidTraffic])
+
+	ensureContains(t, registry, "stored_data.request.postgres.fetch_time", m.StoredDataFetchTimers[StoredDataTypeRequest][StoredDataSourcePostgres])
+	ensureContains(t, registry, "stored_data.amp.http.errors.timeout", m.StoredDataErrorMeters[StoredDataTypeAMP][StoredDataSourceHTTP][StoredDataErrorTimeout])
+	ensureContains(t, registry, "stored_data.request.files.errors.not_found", m.StoredDataErrorMeters[StoredDataTypeRequest][StoredDataSourceFiles][StoredDataErrorNotFound])
+	ensureContains(t, registry, "stored_data.request.cache.hit", m.StoredDataCacheMeters[StoredDataTypeRequest][CacheHit])
+	ensureContains(t, registry, "stored_data.amp.cache.miss", m.StoredDataCacheMeters[StoredDataTypeAMP][CacheMiss])
+	ensureContains(t, registry, "privacy.coppa.scrubbed_requests", m.PrivacyScrubbedMeters[PrivacyPolicyCOPPA])
+	ensureContains(t, registry, "privacy.gdpr.scrubbed_requests", m.PrivacyScrubbedMeters[PrivacyPolicyGDPR])
+	ensureContains(t, registry, "privacy.lmt.scrubbed_requests", m.PrivacyScrubbedMeters[PrivacyPolicyLMT])
+	ensureContains(t, registry, "privacy.activities.scrubbed_requests", m.PrivacyScrubbedMeters[PrivacyPolicyActivities])
+	ensureContains(t, registry, "privacy.ccpa.scrubbed_requests", m.PrivacyScrubbedMeters[PrivacyPolicyCCPA])
+	ensureContains(t, registry, "currency.conversions.direct", m.CurrencyConversionMeters[CurrencyConversionDirect])
+	ensureContains(t, registry, "currency.conversions.derived", m.CurrencyConversionMeters[CurrencyConversionDerived])
 }
 
 func TestRecordBidType(t *testing.T) {
@@ -64,6 +82,136 @@ func TestRecordGDPRRejection(t *testing.T) {
 	VerifyMetrics(t, "GDPR sync rejects", m.userSyncGDPRPrevent[openrtb_ext.BidderAppnexus].Count(), 1)
 }
 
+func TestRecordBidWon(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := AdapterLabels{
+		Adapter: openrtb_ext.BidderAppnexus,
+		PubID:   "acct",
+	}
+
+	m.RecordAdapterBidWon(labels, openrtb_ext.BidTypeBanner, 2000)
+	m.RecordAdapterBidWon(labels, openrtb_ext.BidTypeVideo, 4000)
+	VerifyMetrics(t, "Appnexus Bids Won", m.AdapterMetrics[openrtb_ext.BidderAppnexus].BidsWonMeter.Count(), 2)
+	VerifyMetrics(t, "Appnexus Won Prices", m.AdapterMetrics[openrtb_ext.BidderAppnexus].WonPriceHistogram.Count(), 2)
+	VerifyMetrics(t, "Appnexus Won Price Max", m.AdapterMetrics[openrtb_ext.BidderAppnexus].WonPriceHistogram.Max(), 4000)
+	VerifyMetrics(t, "Account Appnexus Bids Won", m.getAccountMetrics("acct").adapterMetrics[openrtb_ext.BidderAppnexus].BidsWonMeter.Count(), 2)
+}
+
+func TestRecordNoBidReason(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := AdapterLabels{
+		Adapter: openrtb_ext.BidderAppnexus,
+	}
+
+	m.RecordAdapterNoBid(labels, AdapterNoBidTimeout)
+	m.RecordAdapterNoBid(labels, AdapterNoBidTimeout)
+	m.RecordAdapterNoBid(labels, AdapterNoBidEmpty)
+	VerifyMetrics(t, "Appnexus No-Bid Timeout", m.AdapterMetrics[openrtb_ext.BidderAppnexus].NoBidReasonMeters[AdapterNoBidTimeout].Count(), 2)
+	VerifyMetrics(t, "Appnexus No-Bid Empty", m.AdapterMetrics[openrtb_ext.BidderAppnexus].NoBidReasonMeters[AdapterNoBidEmpty].Count(), 1)
+	VerifyMetrics(t, "Appnexus No-Bid Rejected", m.AdapterMetrics[openrtb_ext.BidderAppnexus].NoBidReasonMeters[AdapterNoBidRejected].Count(), 0)
+}
+
+func TestRecordAdapterRetry(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := AdapterLabels{
+		Adapter: openrtb_ext.BidderAppnexus,
+	}
+
+	m.RecordAdapterRetry(labels, AdapterRetrySuccess)
+	m.RecordAdapterRetry(labels, AdapterRetrySuccess)
+	m.RecordAdapterRetry(labels, AdapterRetryFailure)
+	VerifyMetrics(t, "Appnexus Retry Success", m.AdapterMetrics[openrtb_ext.BidderAppnexus].RetryMeters[AdapterRetrySuccess].Count(), 2)
+	VerifyMetrics(t, "Appnexus Retry Failure", m.AdapterMetrics[openrtb_ext.BidderAppnexus].RetryMeters[AdapterRetryFailure].Count(), 1)
+}
+
+func TestRecordAdapterBidRejected(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := AdapterLabels{
+		Adapter: openrtb_ext.BidderAppnexus,
+	}
+
+	m.RecordAdapterBidRejected(labels, AdapterBidRejectedPriceCeiling)
+	m.RecordAdapterBidRejected(labels, AdapterBidRejectedPriceCeiling)
+	VerifyMetrics(t, "Appnexus Rejected Price Ceiling", m.AdapterMetrics[openrtb_ext.BidderAppnexus].RejectedMeters[AdapterBidRejectedPriceCeiling].Count(), 2)
+}
+
+func TestRecordAdapterHTTPTimings(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := AdapterLabels{
+		Adapter: openrtb_ext.BidderAppnexus,
+		PubID:   "some-publisher",
+	}
+
+	m.RecordAdapterConnection(labels, AdapterConnectionNew)
+	m.RecordAdapterHTTPPhase(labels, AdapterHTTPPhaseTLS, 30*time.Millisecond)
+	m.RecordAdapterConnection(labels, AdapterConnectionReused)
+	m.RecordAdapterConnection(labels, AdapterConnectionReused)
+	m.RecordAdapterHTTPPhase(labels, AdapterHTTPPhaseFirstByte, 80*time.Millisecond)
+	am := m.AdapterMetrics[openrtb_ext.BidderAppnexus]
+	VerifyMetrics(t, "Appnexus New Connections", am.ConnectionMeters[AdapterConnectionNew].Count(), 1)
+	VerifyMetrics(t, "Appnexus Reused Connections", am.ConnectionMeters[AdapterConnectionReused].Count(), 2)
+	VerifyMetrics(t, "Appnexus TLS Handshakes", am.HTTPPhaseTimers[AdapterHTTPPhaseTLS].Count(), 1)
+	VerifyMetrics(t, "Appnexus TLS Time", am.HTTPPhaseTimers[AdapterHTTPPhaseTLS].Max(), int64(30*time.Millisecond))
+	VerifyMetrics(t, "Appnexus DNS Lookups", am.HTTPPhaseTimers[AdapterHTTPPhaseDNS].Count(), 0)
+	// This registers the account's adapter metrics.
+	m.RecordAdapterTime(labels, 100*time.Millisecond)
+	if registry.Get("account.some-publisher.appnexus.request_time") == nil || registry.Get("account.some-publisher.appnexus.http_time.tls") != nil {
+		t.Errorf("The HTTP timings should only be registered for the adapter, not for each account.")
+	}
+}
+
+func TestRecordStoredData(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	labels := StoredDataLabels{
+		DataType: StoredDataTypeRequest,
+		Source:   StoredDataSourcePostgres,
+		Error:    StoredDataErrorTimeout,
+	}
+
+	m.RecordStoredDataFetchTime(labels, 20*time.Millisecond)
+	m.RecordStoredDataError(labels)
+	m.RecordStoredDataCacheResult(StoredDataTypeRequest, CacheHit, 3)
+	m.RecordStoredDataCacheResult(StoredDataTypeRequest, CacheMiss, 1)
+	VerifyMetrics(t, "Postgres Request Fetches", m.StoredDataFetchTimers[StoredDataTypeRequest][StoredDataSourcePostgres].Count(), 1)
+	VerifyMetrics(t, "Postgres AMP Fetches", m.StoredDataFetchTimers[StoredDataTypeAMP][StoredDataSourcePostgres].Count(), 0)
+	VerifyMetrics(t, "Postgres Request Timeouts", m.StoredDataErrorMeters[StoredDataTypeRequest][StoredDataSourcePostgres][StoredDataErrorTimeout].Count(), 1)
+	VerifyMetrics(t, "Request Cache Hits", m.StoredDataCacheMeters[StoredDataTypeRequest][CacheHit].Count(), 3)
+	VerifyMetrics(t, "Request Cache Misses", m.StoredDataCacheMeters[StoredDataTypeRequest][CacheMiss].Count(), 1)
+}
+
+func TestRecordPrivacyScrubbed(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+
+	m.RecordPrivacyScrubbed(PrivacyPolicyCOPPA)
+	m.RecordPrivacyScrubbed(PrivacyPolicyCOPPA)
+	VerifyMetrics(t, "COPPA Scrubbed Requests", m.PrivacyScrubbedMeters[PrivacyPolicyCOPPA].Count(), 2)
+}
+
+func TestRecordCurrencyConversion(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+
+	m.RecordCurrencyConversion(CurrencyConversionDerived)
+	VerifyMetrics(t, "Derived Currency Conversions", m.CurrencyConversionMeters[CurrencyConversionDerived].Count(), 1)
+	VerifyMetrics(t, "Direct Currency Conversions", m.CurrencyConversionMeters[CurrencyConversionDirect].Count(), 0)
+}
+
+func TestRecordEvent(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+
+	m.RecordEvent(EventClick)
+	VerifyMetrics(t, "Click Events", m.EventMeters[EventClick].Count(), 1)
+	VerifyMetrics(t, "Impression Events", m.EventMeters[EventImpression].Count(), 0)
+}
+
 func ensureContains(t *testing.T, registry metrics.Registry, name string, metric interface{}) {
 	t.Helper()
 	if inRegistry := registry.Get(name); inRegistry == nil {
@@ -82,9 +230,28 @@ func ensureContainsAdapterMetrics(t *testing.T, registry metrics.Registry, name
 	ensureContains(t, registry, name+".requests.badserverresponse", adapterMetrics.ErrorMeters[AdapterErrorBadServerResponse])
 	ensureContains(t, registry, name+".requests.timeout", adapterMetrics.ErrorMeters[AdapterErrorTimeout])
 	ensureContains(t, registry, name+".requests.unknown_error", adapterMetrics.ErrorMeters[AdapterErrorUnknown])
+	ensureContains(t, registry, name+".requests.panic", adapterMetrics.ErrorMeters[AdapterErrorPanic])
+	ensureContains(t, registry, name+".requests.response_too_large", adapterMetrics.ErrorMeters[AdapterErrorResponseTooLarge])
 
 	ensureContains(t, registry, name+".request_time", adapterMetrics.RequestTimer)
 	ensureContains(t, registry, name+".prices", adapterMetrics.PriceHistogram)
+	ensureContains(t, registry, name+".bids_won", adapterMetrics.BidsWonMeter)
+	ensureContains(t, registry, name+".won_prices", adapterMetrics.WonPriceHistogram)
+	for _, reason := range AdapterNoBidReasons() {
+		ensureContains(t, registry, name+".nobid_reasons."+string(reason), adapterMetrics.NoBidReasonMeters[reason])
+	}
+	for _, result := range AdapterRetryResults() {
+		ensureContains(t, registry, name+".retries."+string(result), adapterMetrics.RetryMeters[result])
+	}
+	for _, reason := range AdapterBidRejections() {
+		ensureContains(t, registry, name+".rejected_bids."+string(reason), adapterMetrics.RejectedMeters[reason])
+	}
+	for _, phase := range AdapterHTTPPhases() {
+		ensureContains(t, registry, name+".http_time."+string(phase), adapterMetrics.HTTPPhaseTimers[phase])
+	}
+	for _, connection := range AdapterConnections() {
+		ensureContains(t, registry, name+".connections."+string(connection), adapterMetrics.ConnectionMeters[connection])
+	}
 	ensureContainsBidTypeMetrics(t, registry, name, adapterMetrics.MarkupMetrics)
 }
 
@@ -51,6 +51,21 @@ type AdapterBid string
 // AdapterError : Errors which may have occurred during the adapter's execution
 type AdapterError string
 
+// AdapterRetryResult : The outcome of retrying an HTTP call to an adapter after a connection error
+type AdapterRetryResult string
+
+// AdapterNoBidReason : Why an adapter which took part in the auction returned no usable bids
+type AdapterNoBidReason string
+
+// AdapterBidRejection : Why a bid which passed validation was removed from the auction
+type AdapterBidRejection string
+
+// AdapterHTTPPhase : A part of an HTTP call to an adapter, which is timed separately
+type AdapterHTTPPhase string
+
+// AdapterConnection : Whether an HTTP call to an adapter opened a new connection, or reused an idle one
+type AdapterConnection string
+
 // The demand sources
 const (
 	DemandWeb     DemandSource = "web"
@@ -116,6 +131,10 @@ const (
 	RequestStatusOK       RequestStatus = "ok"
 	RequestStatusBadInput RequestStatus = "badinput"
 	RequestStatusErr      RequestStatus = "err"
+	// RequestStatusBlocked counts the requests from the sources on the host's blocklist.
+	RequestStatusBlocked RequestStatus = "blocked"
+	// RequestStatusInvalidTraffic counts the requests which were dropped because they looked like bot traffic.
+	RequestStatusInvalidTraffic RequestStatus = "invalid_traffic"
 )
 
 func RequestStatuses() []RequestStatus {
@@ -123,6 +142,8 @@ func RequestStatuses() []RequestStatus {
 		RequestStatusOK,
 		RequestStatusBadInput,
 		RequestStatusErr,
+		RequestStatusBlocked,
+		RequestStatusInvalidTraffic,
 	}
 }
 
@@ -145,6 +166,8 @@ const (
 	AdapterErrorBadServerResponse AdapterError = "badserverresponse"
 	AdapterErrorTimeout           AdapterError = "timeout"
 	AdapterErrorUnknown           AdapterError = "unknown_error"
+	AdapterErrorPanic             AdapterError = "panic" // The adapter's code panicked
+	AdapterErrorResponseTooLarge  AdapterError = "response_too_large"
 )
 
 func AdapterErrors() []AdapterError {
@@ -153,6 +176,89 @@ func AdapterErrors() []AdapterError {
 		AdapterErrorBadServerResponse,
 		AdapterErrorTimeout,
 		AdapterErrorUnknown,
+		AdapterErrorPanic,
+		AdapterErrorResponseTooLarge,
+	}
+}
+
+// Adapter no-bid reasons
+const (
+	AdapterNoBidNoRequest AdapterNoBidReason = "no_request" // The adapter didn't make any outgoing calls
+	AdapterNoBidEmpty     AdapterNoBidReason = "empty"      // The bidder responded, but with no bids
+	AdapterNoBidRejected  AdapterNoBidReason = "rejected"   // All the bids returned by the bidder failed validation
+	AdapterNoBidTimeout   AdapterNoBidReason = "timeout"
+	AdapterNoBidError     AdapterNoBidReason = "error"
+)
+
+func AdapterNoBidReasons() []AdapterNoBidReason {
+	return []AdapterNoBidReason{
+		AdapterNoBidNoRequest,
+		AdapterNoBidEmpty,
+		AdapterNoBidRejected,
+		AdapterNoBidTimeout,
+		AdapterNoBidError,
+	}
+}
+
+// Adapter retry results
+const (
+	AdapterRetrySuccess AdapterRetryResult = "success" // The retry reached the bidder
+	AdapterRetryFailure AdapterRetryResult = "failure" // The retry failed too, or there wasn't time left to make it
+)
+
+func AdapterRetryResults() []AdapterRetryResult {
+	return []AdapterRetryResult{
+		AdapterRetrySuccess,
+		AdapterRetryFailure,
+	}
+}
+
+// Adapter bid rejections
+const (
+	AdapterBidRejectedPriceCeiling AdapterBidRejection = "price_ceiling" // The bid's price was above the account's max CPM
+	AdapterBidRejectedSeatFloor    AdapterBidRejection = "seat_floor"    // The bid's price was below the account's floor for the bidder
+	AdapterBidRejectedInvalidVAST  AdapterBidRejection = "invalid_vast"  // The bid's VAST was broken, or had nothing to play
+	AdapterBidRejectedBlocked      AdapterBidRejection = "blocked"       // The bid used an advertiser, category or attribute which the account blocks
+	AdapterBidRejectedCreativeScan AdapterBidRejection = "creative_scan" // The creative scanning service rejected the bid's creative, or couldn't scan it
+)
+
+func AdapterBidRejections() []AdapterBidRejection {
+	return []AdapterBidRejection{
+		AdapterBidRejectedPriceCeiling,
+		AdapterBidRejectedSeatFloor,
+		AdapterBidRejectedInvalidVAST,
+		AdapterBidRejectedBlocked,
+		AdapterBidRejectedCreativeScan,
+	}
+}
+
+// Adapter HTTP phases
+const (
+	AdapterHTTPPhaseDNS       AdapterHTTPPhase = "dns"        // Looking up the bidder's host
+	AdapterHTTPPhaseConnect   AdapterHTTPPhase = "connect"    // Opening the TCP connection
+	AdapterHTTPPhaseTLS       AdapterHTTPPhase = "tls"        // The TLS handshake
+	AdapterHTTPPhaseFirstByte AdapterHTTPPhase = "first_byte" // From sending the request to the first byte of the response
+)
+
+func AdapterHTTPPhases() []AdapterHTTPPhase {
+	return []AdapterHTTPPhase{
+		AdapterHTTPPhaseDNS,
+		AdapterHTTPPhaseConnect,
+		AdapterHTTPPhaseTLS,
+		AdapterHTTPPhaseFirstByte,
+	}
+}
+
+// Adapter connections
+const (
+	AdapterConnectionNew    AdapterConnection = "new"
+	AdapterConnectionReused AdapterConnection = "reused"
+)
+
+func AdapterConnections() []AdapterConnection {
+	return []AdapterConnection{
+		AdapterConnectionNew,
+		AdapterConnectionReused,
 	}
 }
 
@@ -173,6 +279,137 @@ const (
 	RequestActionErr    RequestAction = "err"
 )
 
+// StoredDataLabels : Labels for the stored data (stored requests, AMP requests) fetch metrics
+type StoredDataLabels struct {
+	DataType StoredDataType
+	Source   StoredDataSource
+	Error    StoredDataError
+}
+
+// StoredDataType : The kind of stored data being fetched
+type StoredDataType string
+
+// StoredDataSource : The backend the stored data was fetched from
+type StoredDataSource string
+
+// StoredDataError : Errors which may occur while fetching stored data
+type StoredDataError string
+
+// CacheResult : Whether a lookup was served from the cache
+type CacheResult string
+
+// PrivacyPolicy : A privacy regulation or signal which can make PBS remove data from a request
+type PrivacyPolicy string
+
+// CurrencyConversion : How the rate used to convert a bid's price was found
+type CurrencyConversion string
+
+// EventType : The kind of event reported to the /event endpoint by a tracker
+type EventType string
+
+// Stored data types
+const (
+	StoredDataTypeRequest StoredDataType = "request"
+	StoredDataTypeAMP     StoredDataType = "amp"
+)
+
+func StoredDataTypes() []StoredDataType {
+	return []StoredDataType{
+		StoredDataTypeRequest,
+		StoredDataTypeAMP,
+	}
+}
+
+// Stored data sources
+const (
+	StoredDataSourceFiles    StoredDataSource = "files"
+	StoredDataSourcePostgres StoredDataSource = "postgres"
+	StoredDataSourceHTTP     StoredDataSource = "http"
+)
+
+func StoredDataSources() []StoredDataSource {
+	return []StoredDataSource{
+		StoredDataSourceFiles,
+		StoredDataSourcePostgres,
+		StoredDataSourceHTTP,
+	}
+}
+
+// Stored data fetch errors
+const (
+	StoredDataErrorNotFound StoredDataError = "not_found"
+	StoredDataErrorTimeout  StoredDataError = "timeout"
+	StoredDataErrorUnknown  StoredDataError = "unknown_error"
+)
+
+func StoredDataErrors() []StoredDataError {
+	return []StoredDataError{
+		StoredDataErrorNotFound,
+		StoredDataErrorTimeout,
+		StoredDataErrorUnknown,
+	}
+}
+
+// Cache lookup results
+const (
+	CacheHit  CacheResult = "hit"
+	CacheMiss CacheResult = "miss"
+)
+
+func CacheResults() []CacheResult {
+	return []CacheResult{
+		CacheHit,
+		CacheMiss,
+	}
+}
+
+// Privacy policies
+const (
+	PrivacyPolicyCOPPA      PrivacyPolicy = "coppa"
+	PrivacyPolicyGDPR       PrivacyPolicy = "gdpr"
+	PrivacyPolicyLMT        PrivacyPolicy = "lmt"
+	PrivacyPolicyActivities PrivacyPolicy = "activities"
+	PrivacyPolicyCCPA       PrivacyPolicy = "ccpa"
+)
+
+func PrivacyPolicies() []PrivacyPolicy {
+	return []PrivacyPolicy{
+		PrivacyPolicyCOPPA,
+		PrivacyPolicyGDPR,
+		PrivacyPolicyLMT,
+		PrivacyPolicyActivities,
+		PrivacyPolicyCCPA,
+	}
+}
+
+// Currency conversions
+const (
+	CurrencyConversionDirect  CurrencyConversion = "direct"
+	CurrencyConversionDerived CurrencyConversion = "derived"
+)
+
+func CurrencyConversions() []CurrencyConversion {
+	return []CurrencyConversion{
+		CurrencyConversionDirect,
+		CurrencyConversionDerived,
+	}
+}
+
+// Event types
+const (
+	EventImpression EventType = "imp"
+	EventViewable   EventType = "vimp"
+	EventClick      EventType = "click"
+)
+
+func EventTypes() []EventType {
+	return []EventType{
+		EventImpression,
+		EventViewable,
+		EventClick,
+	}
+}
+
 // MetricsEngine is a generic interface to record PBS metrics into the desired backend
 // The first three metrics function fire off once per incoming request, so total metrics
 // will equal the total numer of incoming requests. The remaining 5 fire off per outgoing
@@ -191,6 +428,34 @@ type MetricsEngine interface {
 	RecordAdapterBidReceived(labels AdapterLabels, bidType openrtb_ext.BidType, hasAdm bool)
 	RecordAdapterPrice(labels AdapterLabels, cpm float64)
 	RecordAdapterTime(labels AdapterLabels, length time.Duration)
+	// This records a bid which won its imp, and so was given the top-level targeting keys.
+	// Comparing it with RecordAdapterBidReceived gives the win rate of each bidder.
+	RecordAdapterBidWon(labels AdapterLabels, bidType openrtb_ext.BidType, cpm float64)
+	// This records why an adapter contributed no bids to the auction.
+	RecordAdapterNoBid(labels AdapterLabels, reason AdapterNoBidReason)
+	// This records an HTTP call to an adapter which was retried after a connection error. Calls which aren't
+	// recorded here reached the bidder on their first attempt, or failed without being retried.
+	RecordAdapterRetry(labels AdapterLabels, result AdapterRetryResult)
+	// This records a valid bid which was removed from the auction by one of the host's rules, such as a price ceiling.
+	RecordAdapterBidRejected(labels AdapterLabels, reason AdapterBidRejection)
+	// These record where the time went in each HTTP call to an adapter, and whether it needed a new connection.
+	// Slow DNS, connect or TLS phases point to the network, while a slow first byte points to the bidder's servers.
+	// Reused connections skip the first three phases, so they're only recorded for new ones.
+	RecordAdapterHTTPPhase(labels AdapterLabels, phase AdapterHTTPPhase, length time.Duration)
+	RecordAdapterConnection(labels AdapterLabels, connection AdapterConnection)
+	// These record the latency and failures of the backends which serve stored data. The Error label is ignored by
+	// RecordStoredDataFetchTime.
+	RecordStoredDataFetchTime(labels StoredDataLabels, length time.Duration)
+	RecordStoredDataError(labels StoredDataLabels)
+	// This records how many of the IDs looked up were found in the in-memory cache.
+	RecordStoredDataCacheResult(dataType StoredDataType, result CacheResult, inc int)
+	// This records an auction which had personal data removed from its bidder requests because of a privacy policy.
+	RecordPrivacyScrubbed(policy PrivacyPolicy)
+	// This records a bid price conversion between two different currencies, by whether the rate was known
+	// or had to be derived through an intermediate currency.
+	RecordCurrencyConversion(conversion CurrencyConversion)
+	// This records an event reported by one of the trackers which Prebid Server added to a bid.
+	RecordEvent(event EventType)
 	RecordCookieSync(labels Labels)        // May ignore all labels
 	RecordUserIDSet(userLabels UserLabels) // Function should verify bidder values
 }
@@ -23,8 +23,27 @@ type Metrics struct {
 	adaptBids     *prometheus.CounterVec
 	adaptPrices   *prometheus.HistogramVec
 	adaptErrors   *prometheus.CounterVec
+	adaptWins     *prometheus.CounterVec
+	adaptWinPrice *prometheus.HistogramVec
+	adaptNoBids   *prometheus.CounterVec
+	adaptRetries  *prometheus.CounterVec
+	adaptRejects  *prometheus.CounterVec
+	adaptPhases   *prometheus.HistogramVec
+	adaptConns    *prometheus.CounterVec
+	storedTimer   *prometheus.HistogramVec
+	storedErrors  *prometheus.CounterVec
+	storedCache   *prometheus.CounterVec
+	privacyScrubs *prometheus.CounterVec
+	currencyConvs *prometheus.CounterVec
+	events        *prometheus.CounterVec
 	cookieSync    prometheus.Counter
 	userID        *prometheus.CounterVec
+
+	// The account metrics are only recorded if there's an AccountLimiter, to keep their cardinality in check.
+	AccountLimiter  *pbsmetrics.AccountLimiter
+	accountRequests *prometheus.CounterVec
+	accountTimer    *prometheus.HistogramVec
+	accountBids     *prometheus.CounterVec
 }
 
 // NewMetrics constructs the appropriate options for the Prometheus metrics. Needs to be fed the promethus config
@@ -39,6 +58,10 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 	adapterLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter_bid", "adapter"}
 	bidLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter_bid", "adapter", "bidtype", "markup_type"}
 	errorLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter_error", "adapter"}
+	winLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter", "bidtype"}
+	noBidLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter", "reason"}
+	retryLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter", "result"}
+	rejectLabelNames := []string{"demand_source", "request_type", "browser", "cookie", "adapter", "reason"}
 
 	metrics := Metrics{}
 	metrics.Registry = prometheus.NewRegistry()
@@ -64,6 +87,21 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		standardLabelNames, timerBuckets,
 	)
 	metrics.Registry.MustRegister(metrics.reqTimer)
+	metrics.accountRequests = newCounter(cfg, "account_requests_total",
+		"Number of requests made to PBS by each account.",
+		[]string{"account", "request_type", "response_status"},
+	)
+	metrics.Registry.MustRegister(metrics.accountRequests)
+	metrics.accountTimer = newHistogram(cfg, "account_request_time_seconds",
+		"Seconds to resolve each account's PBS requests.",
+		[]string{"account"}, timerBuckets,
+	)
+	metrics.Registry.MustRegister(metrics.accountTimer)
+	metrics.accountBids = newCounter(cfg, "account_adapter_bids_received_total",
+		"Number of bids received from each bidder, for each account.",
+		[]string{"account", "adapter"},
+	)
+	metrics.Registry.MustRegister(metrics.accountBids)
 	metrics.adaptRequests = newCounter(cfg, "adapter_requests_total",
 		"Number of requests sent out to each bidder.",
 		adapterLabelNames,
@@ -89,6 +127,72 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		errorLabelNames,
 	)
 	metrics.Registry.MustRegister(metrics.adaptErrors)
+	metrics.adaptWins = newCounter(cfg, "adapter_bids_won_total",
+		"Number of bids from each bidder which won their imp.",
+		winLabelNames,
+	)
+	metrics.Registry.MustRegister(metrics.adaptWins)
+	metrics.adaptWinPrice = newHistogram(cfg, "adapter_won_prices",
+		"Values of the winning bids from each bidder.",
+		winLabelNames, prometheus.LinearBuckets(0.1, 0.1, 200),
+	)
+	metrics.Registry.MustRegister(metrics.adaptWinPrice)
+	metrics.adaptNoBids = newCounter(cfg, "adapter_nobid_reasons_total",
+		"Number of requests to each adapter which produced no bids, by reason.",
+		noBidLabelNames,
+	)
+	metrics.Registry.MustRegister(metrics.adaptNoBids)
+	metrics.adaptRetries = newCounter(cfg, "adapter_retries_total",
+		"Number of calls to each adapter which were retried after a connection error, by whether the retry succeeded.",
+		retryLabelNames,
+	)
+	metrics.Registry.MustRegister(metrics.adaptRetries)
+	metrics.adaptRejects = newCounter(cfg, "adapter_rejected_bids_total",
+		"Number of valid bids from each adapter which were removed from the auction by the host's rules, by reason.",
+		rejectLabelNames,
+	)
+	metrics.Registry.MustRegister(metrics.adaptRejects)
+	// The network timings don't depend on the request, so these are only labelled by adapter.
+	metrics.adaptPhases = newHistogram(cfg, "adapter_http_phase_time_seconds",
+		"Seconds taken by each phase of the HTTP calls to each bidder: dns, connect, tls and first_byte.",
+		[]string{"adapter", "phase"}, prometheus.ExponentialBuckets(0.001, 2, 12),
+	)
+	metrics.Registry.MustRegister(metrics.adaptPhases)
+	metrics.adaptConns = newCounter(cfg, "adapter_connections_total",
+		"Number of HTTP calls to each bidder, by whether they opened a new connection or reused an idle one.",
+		[]string{"adapter", "connection"},
+	)
+	metrics.Registry.MustRegister(metrics.adaptConns)
+	metrics.storedTimer = newHistogram(cfg, "stored_data_fetch_time_seconds",
+		"Seconds to fetch stored data from each backend.",
+		[]string{"data_type", "source"}, timerBuckets,
+	)
+	metrics.Registry.MustRegister(metrics.storedTimer)
+	metrics.storedErrors = newCounter(cfg, "stored_data_errors_total",
+		"Number of errors seen when fetching stored data from each backend.",
+		[]string{"data_type", "source", "error"},
+	)
+	metrics.Registry.MustRegister(metrics.storedErrors)
+	metrics.storedCache = newCounter(cfg, "stored_data_cache_total",
+		"Number of stored data IDs looked up in the cache, by whether they were found.",
+		[]string{"data_type", "cache_result"},
+	)
+	metrics.Registry.MustRegister(metrics.storedCache)
+	metrics.privacyScrubs = newCounter(cfg, "privacy_scrubbed_requests_total",
+		"Number of auctions which had personal data removed from their bidder requests, by privacy policy.",
+		[]string{"policy"},
+	)
+	metrics.Registry.MustRegister(metrics.privacyScrubs)
+	metrics.currencyConvs = newCounter(cfg, "currency_conversions_total",
+		"Number of bid prices converted between currencies, by whether the rate was known or derived through an intermediate currency.",
+		[]string{"conversion"},
+	)
+	metrics.Registry.MustRegister(metrics.currencyConvs)
+	metrics.events = newCounter(cfg, "events_total",
+		"Number of events reported by the trackers which Prebid Server added to bids, by type.",
+		[]string{"type"},
+	)
+	metrics.Registry.MustRegister(metrics.events)
 	metrics.cookieSync = newCookieSync(cfg)
 	metrics.Registry.MustRegister(metrics.cookieSync)
 	metrics.userID = newCounter(cfg, "usersync_total",
@@ -162,6 +266,13 @@ func (me *Metrics) RecordConnectionClose(success bool) {
 
 func (me *Metrics) RecordRequest(labels pbsmetrics.Labels) {
 	me.requests.With(resolveLabels(labels)).Inc()
+	if me.AccountLimiter != nil {
+		me.accountRequests.With(prometheus.Labels{
+			"account":         me.AccountLimiter.Label(labels.PubID),
+			"request_type":    string(labels.RType),
+			"response_status": string(labels.RequestStatus),
+		}).Inc()
+	}
 }
 
 func (me *Metrics) RecordImps(labels pbsmetrics.Labels, numImps int) {
@@ -171,6 +282,9 @@ func (me *Metrics) RecordImps(labels pbsmetrics.Labels, numImps int) {
 func (me *Metrics) RecordRequestTime(labels pbsmetrics.Labels, length time.Duration) {
 	time := float64(length) / float64(time.Second)
 	me.reqTimer.With(resolveLabels(labels)).Observe(time)
+	if me.AccountLimiter != nil {
+		me.accountTimer.With(prometheus.Labels{"account": me.AccountLimiter.Label(labels.PubID)}).Observe(time)
+	}
 }
 
 func (me *Metrics) RecordAdapterRequest(labels pbsmetrics.AdapterLabels) {
@@ -182,6 +296,12 @@ func (me *Metrics) RecordAdapterRequest(labels pbsmetrics.AdapterLabels) {
 
 func (me *Metrics) RecordAdapterBidReceived(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType, hasAdm bool) {
 	me.adaptBids.With(resolveBidLabels(labels, bidType, hasAdm)).Inc()
+	if me.AccountLimiter != nil {
+		me.accountBids.With(prometheus.Labels{
+			"account": me.AccountLimiter.Label(labels.PubID),
+			"adapter": string(labels.Adapter),
+		}).Inc()
+	}
 }
 
 func (me *Metrics) RecordAdapterPrice(labels pbsmetrics.AdapterLabels, cpm float64) {
@@ -193,6 +313,67 @@ func (me *Metrics) RecordAdapterTime(labels pbsmetrics.AdapterLabels, length tim
 	me.adaptTimer.With(resolveAdapterLabels(labels)).Observe(time)
 }
 
+func (me *Metrics) RecordAdapterBidWon(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType, cpm float64) {
+	winLabels := resolveWinLabels(labels, bidType)
+	me.adaptWins.With(winLabels).Inc()
+	me.adaptWinPrice.With(winLabels).Observe(cpm)
+}
+
+func (me *Metrics) RecordAdapterNoBid(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterNoBidReason) {
+	me.adaptNoBids.With(resolveNoBidLabels(labels, reason)).Inc()
+}
+
+func (me *Metrics) RecordAdapterRetry(labels pbsmetrics.AdapterLabels, result pbsmetrics.AdapterRetryResult) {
+	me.adaptRetries.With(resolveRetryLabels(labels, result)).Inc()
+}
+
+func (me *Metrics) RecordAdapterBidRejected(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterBidRejection) {
+	me.adaptRejects.With(resolveRejectionLabels(labels, reason)).Inc()
+}
+
+func (me *Metrics) RecordAdapterHTTPPhase(labels pbsmetrics.AdapterLabels, phase pbsmetrics.AdapterHTTPPhase, length time.Duration) {
+	time := float64(length) / float64(time.Second)
+	me.adaptPhases.With(prometheus.Labels{"adapter": string(labels.Adapter), "phase": string(phase)}).Observe(time)
+}
+
+func (me *Metrics) RecordAdapterConnection(labels pbsmetrics.AdapterLabels, connection pbsmetrics.AdapterConnection) {
+	me.adaptConns.With(prometheus.Labels{"adapter": string(labels.Adapter), "connection": string(connection)}).Inc()
+}
+
+func (me *Metrics) RecordStoredDataFetchTime(labels pbsmetrics.StoredDataLabels, length time.Duration) {
+	time := float64(length) / float64(time.Second)
+	me.storedTimer.With(resolveStoredDataLabels(labels)).Observe(time)
+}
+
+func (me *Metrics) RecordStoredDataError(labels pbsmetrics.StoredDataLabels) {
+	me.storedErrors.With(resolveStoredDataErrorLabels(labels)).Inc()
+}
+
+func (me *Metrics) RecordStoredDataCacheResult(dataType pbsmetrics.StoredDataType, result pbsmetrics.CacheResult, inc int) {
+	me.storedCache.With(prometheus.Labels{
+		"data_type":    string(dataType),
+		"cache_result": string(result),
+	}).Add(float64(inc))
+}
+
+func (me *Metrics) RecordPrivacyScrubbed(policy pbsmetrics.PrivacyPolicy) {
+	me.privacyScrubs.With(prometheus.Labels{
+		"policy": string(policy),
+	}).Inc()
+}
+
+func (me *Metrics) RecordCurrencyConversion(conversion pbsmetrics.CurrencyConversion) {
+	me.currencyConvs.With(prometheus.Labels{
+		"conversion": string(conversion),
+	}).Inc()
+}
+
+func (me *Metrics) RecordEvent(event pbsmetrics.EventType) {
+	me.events.With(prometheus.Labels{
+		"type": string(event),
+	}).Inc()
+}
+
 func (me *Metrics) RecordCookieSync(labels pbsmetrics.Labels) {
 	me.cookieSync.Inc()
 }
@@ -254,6 +435,67 @@ func resolveAdapterErrorLabels(labels pbsmetrics.AdapterLabels, errorType string
 	}
 }
 
+func resolveWinLabels(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType) prometheus.Labels {
+	return prometheus.Labels{
+		"demand_source": string(labels.Source),
+		"request_type":  string(labels.RType),
+		// "pubid":   labels.PubID,
+		"browser": string(labels.Browser),
+		"cookie":  string(labels.CookieFlag),
+		"adapter": string(labels.Adapter),
+		"bidtype": string(bidType),
+	}
+}
+
+func resolveNoBidLabels(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterNoBidReason) prometheus.Labels {
+	return prometheus.Labels{
+		"demand_source": string(labels.Source),
+		"request_type":  string(labels.RType),
+		// "pubid":   labels.PubID,
+		"browser": string(labels.Browser),
+		"cookie":  string(labels.CookieFlag),
+		"adapter": string(labels.Adapter),
+		"reason":  string(reason),
+	}
+}
+
+func resolveRetryLabels(labels pbsmetrics.AdapterLabels, result pbsmetrics.AdapterRetryResult) prometheus.Labels {
+	return prometheus.Labels{
+		"demand_source": string(labels.Source),
+		"request_type":  string(labels.RType),
+		"browser":       string(labels.Browser),
+		"cookie":        string(labels.CookieFlag),
+		"adapter":       string(labels.Adapter),
+		"result":        string(result),
+	}
+}
+
+func resolveRejectionLabels(labels pbsmetrics.AdapterLabels, reason pbsmetrics.AdapterBidRejection) prometheus.Labels {
+	return prometheus.Labels{
+		"demand_source": string(labels.Source),
+		"request_type":  string(labels.RType),
+		"browser":       string(labels.Browser),
+		"cookie":        string(labels.CookieFlag),
+		"adapter":       string(labels.Adapter),
+		"reason":        string(reason),
+	}
+}
+
+func resolveStoredDataLabels(labels pbsmetrics.StoredDataLabels) prometheus.Labels {
+	return prometheus.Labels{
+		"data_type": string(labels.DataType),
+		"source":    string(labels.Source),
+	}
+}
+
+func resolveStoredDataErrorLabels(labels pbsmetrics.StoredDataLabels) prometheus.Labels {
+	return prometheus.Labels{
+		"data_type": string(labels.DataType),
+		"source":    string(labels.Source),
+		"error":     string(labels.Error),
+	}
+}
+
 func resolveUserSyncLabels(userLabels pbsmetrics.UserLabels) prometheus.Labels {
 	return prometheus.Labels{
 		"action": string(userLabels.Action),
@@ -301,6 +543,67 @@ func initializeTimeSeries(m *Metrics) {
 	for _, l := range labels {
 		_ = m.adaptErrors.With(l)
 	}
+	labels = addDimension(errorLabels, "bidtype", bidTypesAsString())
+	for _, l := range labels {
+		_ = m.adaptWins.With(l)
+		_ = m.adaptWinPrice.With(l)
+	}
+	labels = addDimension(errorLabels, "reason", adapterNoBidReasonsAsString())
+	for _, l := range labels {
+		_ = m.adaptNoBids.With(l)
+	}
+	labels = addDimension(errorLabels, "result", adapterRetryResultsAsString())
+	for _, l := range labels {
+		_ = m.adaptRetries.With(l)
+	}
+	labels = addDimension(errorLabels, "reason", adapterBidRejectionsAsString())
+	for _, l := range labels {
+		_ = m.adaptRejects.With(l)
+	}
+	labels = addDimension([]prometheus.Labels{}, "adapter", adaptersAsString())
+	networkLabels := labels // save regenerating these dimensions for connections
+	labels = addDimension(labels, "phase", adapterHTTPPhasesAsString())
+	for _, l := range labels {
+		_ = m.adaptPhases.With(l)
+	}
+	labels = addDimension(networkLabels, "connection", adapterConnectionsAsString())
+	for _, l := range labels {
+		_ = m.adaptConns.With(l)
+	}
+
+	// Stored data labels
+	labels = addDimension([]prometheus.Labels{}, "data_type", storedDataTypesAsString())
+	cacheLabels := labels // save regenerating these dimensions for cache results
+	labels = addDimension(labels, "source", storedDataSourcesAsString())
+	for _, l := range labels {
+		_ = m.storedTimer.With(l)
+	}
+	labels = addDimension(labels, "error", storedDataErrorsAsString())
+	for _, l := range labels {
+		_ = m.storedErrors.With(l)
+	}
+	labels = addDimension(cacheLabels, "cache_result", cacheResultsAsString())
+	for _, l := range labels {
+		_ = m.storedCache.With(l)
+	}
+
+	// Privacy labels
+	labels = addDimension([]prometheus.Labels{}, "policy", privacyPoliciesAsString())
+	for _, l := range labels {
+		_ = m.privacyScrubs.With(l)
+	}
+
+	// Currency conversion labels
+	labels = addDimension([]prometheus.Labels{}, "conversion", currencyConversionsAsString())
+	for _, l := range labels {
+		_ = m.currencyConvs.With(l)
+	}
+
+	// Event labels
+	labels = addDimension([]prometheus.Labels{}, "type", eventTypesAsString())
+	for _, l := range labels {
+		_ = m.events.With(l)
+	}
 }
 
 // addDimesion will expand a slice of labels to add the dimension of a new set of values for a new label name
@@ -399,6 +702,114 @@ func adapterErrorsAsString() []string {
 	return output
 }
 
+func adapterNoBidReasonsAsString() []string {
+	list := pbsmetrics.AdapterNoBidReasons()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func adapterRetryResultsAsString() []string {
+	list := pbsmetrics.AdapterRetryResults()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func adapterBidRejectionsAsString() []string {
+	list := pbsmetrics.AdapterBidRejections()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func adapterHTTPPhasesAsString() []string {
+	list := pbsmetrics.AdapterHTTPPhases()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func adapterConnectionsAsString() []string {
+	list := pbsmetrics.AdapterConnections()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func storedDataTypesAsString() []string {
+	list := pbsmetrics.StoredDataTypes()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func storedDataSourcesAsString() []string {
+	list := pbsmetrics.StoredDataSources()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func storedDataErrorsAsString() []string {
+	list := pbsmetrics.StoredDataErrors()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func cacheResultsAsString() []string {
+	list := pbsmetrics.CacheResults()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func privacyPoliciesAsString() []string {
+	list := pbsmetrics.PrivacyPolicies()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func currencyConversionsAsString() []string {
+	list := pbsmetrics.CurrencyConversions()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
+func eventTypesAsString() []string {
+	list := pbsmetrics.EventTypes()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
 func adaptersAsString() []string {
 	list := openrtb_ext.BidderList()
 	output := make([]string, len(list))
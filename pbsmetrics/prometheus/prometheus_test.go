@@ -249,6 +249,202 @@ func TestAdapterTimeMetrics(t *testing.T) {
 
 }
 
+func TestAdapterWinMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+	metrics2 := dto.Metric{}
+	metrics3 := dto.Metric{}
+
+	proMetrics.RecordAdapterBidWon(adaptLabels[0], openrtb_ext.BidTypeBanner, 1.25)
+	proMetrics.RecordAdapterBidWon(adaptLabels[1], openrtb_ext.BidTypeBanner, 0.5)
+	proMetrics.RecordAdapterBidWon(adaptLabels[0], openrtb_ext.BidTypeBanner, 3.10)
+	proMetrics.RecordAdapterBidWon(adaptLabels[2], openrtb_ext.BidTypeVideo, 12.0)
+
+	proMetrics.adaptWins.With(resolveWinLabels(adaptLabels[0], openrtb_ext.BidTypeBanner)).Write(&metrics0)
+	proMetrics.adaptWins.With(resolveWinLabels(adaptLabels[1], openrtb_ext.BidTypeBanner)).Write(&metrics1)
+	proMetrics.adaptWins.With(resolveWinLabels(adaptLabels[2], openrtb_ext.BidTypeVideo)).Write(&metrics2)
+	proMetrics.adaptWinPrice.With(resolveWinLabels(adaptLabels[0], openrtb_ext.BidTypeBanner)).(prometheus.Histogram).Write(&metrics3)
+
+	assertCounterValue(t, "adapter_bids_won[0]", &metrics0, 2)
+	assertCounterValue(t, "adapter_bids_won[1]", &metrics1, 1)
+	assertCounterValue(t, "adapter_bids_won[2]", &metrics2, 1)
+	assertHistogramValue(t, "adapter_won_prices[0]", &metrics3, 2)
+}
+
+func TestAdapterNoBidMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+	metrics2 := dto.Metric{}
+
+	proMetrics.RecordAdapterNoBid(adaptLabels[0], pbsmetrics.AdapterNoBidTimeout)
+	proMetrics.RecordAdapterNoBid(adaptLabels[0], pbsmetrics.AdapterNoBidTimeout)
+	proMetrics.RecordAdapterNoBid(adaptLabels[1], pbsmetrics.AdapterNoBidEmpty)
+
+	proMetrics.adaptNoBids.With(resolveNoBidLabels(adaptLabels[0], pbsmetrics.AdapterNoBidTimeout)).Write(&metrics0)
+	proMetrics.adaptNoBids.With(resolveNoBidLabels(adaptLabels[1], pbsmetrics.AdapterNoBidEmpty)).Write(&metrics1)
+	proMetrics.adaptNoBids.With(resolveNoBidLabels(adaptLabels[1], pbsmetrics.AdapterNoBidRejected)).Write(&metrics2)
+
+	assertCounterValue(t, "adapter_nobid_reasons[0]", &metrics0, 2)
+	assertCounterValue(t, "adapter_nobid_reasons[1]", &metrics1, 1)
+	assertCounterValue(t, "adapter_nobid_reasons[2]", &metrics2, 0)
+}
+
+func TestAdapterRetryMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+
+	proMetrics.RecordAdapterRetry(adaptLabels[0], pbsmetrics.AdapterRetrySuccess)
+	proMetrics.RecordAdapterRetry(adaptLabels[0], pbsmetrics.AdapterRetrySuccess)
+	proMetrics.RecordAdapterRetry(adaptLabels[1], pbsmetrics.AdapterRetryFailure)
+
+	proMetrics.adaptRetries.With(resolveRetryLabels(adaptLabels[0], pbsmetrics.AdapterRetrySuccess)).Write(&metrics0)
+	proMetrics.adaptRetries.With(resolveRetryLabels(adaptLabels[1], pbsmetrics.AdapterRetryFailure)).Write(&metrics1)
+
+	assertCounterValue(t, "adapter_retries[0]", &metrics0, 2)
+	assertCounterValue(t, "adapter_retries[1]", &metrics1, 1)
+}
+
+func TestAdapterBidRejectionMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+
+	proMetrics.RecordAdapterBidRejected(adaptLabels[0], pbsmetrics.AdapterBidRejectedPriceCeiling)
+	proMetrics.RecordAdapterBidRejected(adaptLabels[0], pbsmetrics.AdapterBidRejectedPriceCeiling)
+
+	proMetrics.adaptRejects.With(resolveRejectionLabels(adaptLabels[0], pbsmetrics.AdapterBidRejectedPriceCeiling)).Write(&metrics0)
+	proMetrics.adaptRejects.With(resolveRejectionLabels(adaptLabels[1], pbsmetrics.AdapterBidRejectedPriceCeiling)).Write(&metrics1)
+
+	assertCounterValue(t, "adapter_rejected_bids[0]", &metrics0, 2)
+	assertCounterValue(t, "adapter_rejected_bids[1]", &metrics1, 0)
+}
+
+func TestAdapterHTTPTimingMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+	metrics2 := dto.Metric{}
+	metrics3 := dto.Metric{}
+
+	proMetrics.RecordAdapterConnection(adaptLabels[0], pbsmetrics.AdapterConnectionNew)
+	proMetrics.RecordAdapterConnection(adaptLabels[0], pbsmetrics.AdapterConnectionReused)
+	proMetrics.RecordAdapterConnection(adaptLabels[3], pbsmetrics.AdapterConnectionReused)
+	proMetrics.RecordAdapterHTTPPhase(adaptLabels[0], pbsmetrics.AdapterHTTPPhaseTLS, 30*time.Millisecond)
+
+	// Only the adapter labels the network timings, so adaptLabels[0] and [3] share them.
+	proMetrics.adaptConns.With(prometheus.Labels{"adapter": "appnexus", "connection": "reused"}).Write(&metrics0)
+	proMetrics.adaptConns.With(prometheus.Labels{"adapter": "appnexus", "connection": "new"}).Write(&metrics1)
+	proMetrics.adaptPhases.With(prometheus.Labels{"adapter": "appnexus", "phase": "tls"}).(prometheus.Histogram).Write(&metrics2)
+	proMetrics.adaptPhases.With(prometheus.Labels{"adapter": "appnexus", "phase": "dns"}).(prometheus.Histogram).Write(&metrics3)
+
+	assertCounterValue(t, "adapter_connections[0]", &metrics0, 2)
+	assertCounterValue(t, "adapter_connections[1]", &metrics1, 1)
+	assertHistogramValue(t, "adapter_http_phase_time[0]", &metrics2, 1)
+	assertHistogramValue(t, "adapter_http_phase_time[1]", &metrics3, 0)
+}
+
+func TestAccountMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+	pubLabels := pbsmetrics.Labels{RType: pbsmetrics.ReqTypeORTB2Web, RequestStatus: pbsmetrics.RequestStatusOK, PubID: "Pub1"}
+	otherLabels := pbsmetrics.Labels{RType: pbsmetrics.ReqTypeORTB2Web, RequestStatus: pbsmetrics.RequestStatusOK, PubID: "Pub2"}
+
+	// Without a limiter, no account metrics are recorded at all.
+	proMetrics.RecordRequest(pubLabels)
+	if count := testCollectorCount(proMetrics.accountRequests); count != 0 {
+		t.Errorf("Account metrics shouldn't be recorded without an AccountLimiter. Got %d series", count)
+	}
+
+	proMetrics.AccountLimiter = pbsmetrics.NewAccountLimiter([]string{"Pub1"}, 0)
+	proMetrics.RecordRequest(pubLabels)
+	proMetrics.RecordRequest(otherLabels)
+	proMetrics.RecordRequest(otherLabels)
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+	proMetrics.accountRequests.With(prometheus.Labels{"account": "Pub1", "request_type": string(pbsmetrics.ReqTypeORTB2Web), "response_status": string(pbsmetrics.RequestStatusOK)}).Write(&metrics0)
+	proMetrics.accountRequests.With(prometheus.Labels{"account": pbsmetrics.AccountOther, "request_type": string(pbsmetrics.ReqTypeORTB2Web), "response_status": string(pbsmetrics.RequestStatusOK)}).Write(&metrics1)
+	assertCounterValue(t, "account_requests[Pub1]", &metrics0, 1)
+	assertCounterValue(t, "account_requests[other]", &metrics1, 2)
+}
+
+func testCollectorCount(collector prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 100)
+	collector.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestStoredDataMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+	metrics1 := dto.Metric{}
+	metrics2 := dto.Metric{}
+	metrics3 := dto.Metric{}
+
+	labels := pbsmetrics.StoredDataLabels{
+		DataType: pbsmetrics.StoredDataTypeRequest,
+		Source:   pbsmetrics.StoredDataSourcePostgres,
+		Error:    pbsmetrics.StoredDataErrorNotFound,
+	}
+	proMetrics.RecordStoredDataFetchTime(labels, 25*time.Millisecond)
+	proMetrics.RecordStoredDataFetchTime(labels, 50*time.Millisecond)
+	proMetrics.RecordStoredDataError(labels)
+	proMetrics.RecordStoredDataCacheResult(pbsmetrics.StoredDataTypeRequest, pbsmetrics.CacheHit, 4)
+	proMetrics.RecordStoredDataCacheResult(pbsmetrics.StoredDataTypeRequest, pbsmetrics.CacheMiss, 1)
+
+	proMetrics.storedTimer.With(resolveStoredDataLabels(labels)).(prometheus.Histogram).Write(&metrics0)
+	proMetrics.storedErrors.With(resolveStoredDataErrorLabels(labels)).Write(&metrics1)
+	proMetrics.storedCache.With(prometheus.Labels{"data_type": "request", "cache_result": "hit"}).Write(&metrics2)
+	proMetrics.storedCache.With(prometheus.Labels{"data_type": "request", "cache_result": "miss"}).Write(&metrics3)
+
+	assertHistogramValue(t, "stored_data_fetch_time", &metrics0, 2)
+	assertCounterValue(t, "stored_data_errors", &metrics1, 1)
+	assertCounterValue(t, "stored_data_cache[hit]", &metrics2, 4)
+	assertCounterValue(t, "stored_data_cache[miss]", &metrics3, 1)
+}
+
+func TestPrivacyScrubbedMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+
+	proMetrics.RecordPrivacyScrubbed(pbsmetrics.PrivacyPolicyCOPPA)
+	proMetrics.privacyScrubs.With(prometheus.Labels{"policy": "coppa"}).Write(&metrics0)
+
+	assertCounterValue(t, "privacy_scrubbed_requests[coppa]", &metrics0, 1)
+}
+
+func TestCurrencyConversionMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+
+	proMetrics.RecordCurrencyConversion(pbsmetrics.CurrencyConversionDerived)
+	proMetrics.currencyConvs.With(prometheus.Labels{"conversion": "derived"}).Write(&metrics0)
+
+	assertCounterValue(t, "currency_conversions[derived]", &metrics0, 1)
+}
+
+func TestEventMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	metrics0 := dto.Metric{}
+
+	proMetrics.RecordEvent(pbsmetrics.EventImpression)
+	proMetrics.events.With(prometheus.Labels{"type": "imp"}).Write(&metrics0)
+
+	assertCounterValue(t, "events[imp]", &metrics0, 1)
+}
+
 func TestCookieMetrics(t *testing.T) {
 	proMetrics := newTestMetricsEngine()
 
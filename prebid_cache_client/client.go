@@ -5,11 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/buger/jsonparser"
-	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
 	"golang.org/x/net/context/ctxhttp"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 )
 
 // Client stores values in Prebid Cache. For more info, see https://github.com/prebid/prebid-cache
@@ -19,10 +20,26 @@ type Client interface {
 	// The returned string slice will always have the same number of elements as the values argument. If a
 	// value could not be saved, the element will be an empty string. Implementations are responsible for
 	// logging any relevant errors to the app logs
-	PutJson(ctx context.Context, values []json.RawMessage) []string
+	//
+	// If ttlSeconds isn't nil, it has the number of seconds each value should live, at the same index.
+	// Values with a TTL of 0, or all of them if ttlSeconds is nil, live for the cache's default TTL.
+	PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) []string
+}
+
+// KeyedClient is a Client which can also save values under IDs chosen by the caller. This lets the IDs be
+// used before the values are saved.
+type KeyedClient interface {
+	Client
+	// PutJsonWithKeys is like PutJson, but saves each value under the key at the same index.
+	// The returned slice has the key for each value which was saved, and an empty string for each one which wasn't.
+	PutJsonWithKeys(ctx context.Context, keys []string, values []json.RawMessage, ttlSeconds []int64) []string
 }
 
+// NewClient makes a Client for the configured backend.
 func NewClient(conf *config.Cache) Client {
+	if conf.Backend == config.CacheBackendRedis {
+		return newRedisClient(&conf.Redis)
+	}
 	return &clientImpl{
 		httpClient: &http.Client{
 			Transport: &http.Transport{
@@ -39,21 +56,30 @@ type clientImpl struct {
 	putUrl     string
 }
 
-func (c *clientImpl) PutJson(ctx context.Context, values []json.RawMessage) (uuids []string) {
+func (c *clientImpl) PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) (uuids []string) {
+	return c.putJson(ctx, nil, values, ttlSeconds)
+}
+
+// PutJsonWithKeys only works if Prebid Cache is configured to allow keys to be set by its callers.
+func (c *clientImpl) PutJsonWithKeys(ctx context.Context, keys []string, values []json.RawMessage, ttlSeconds []int64) []string {
+	return c.putJson(ctx, keys, values, ttlSeconds)
+}
+
+func (c *clientImpl) putJson(ctx context.Context, keys []string, values []json.RawMessage, ttlSeconds []int64) (uuids []string) {
 	if len(values) < 1 {
 		return nil
 	}
 
 	uuidsToReturn := make([]string, len(values))
 
-	postBody, err := encodeValues(values)
+	postBody, err := encodeValues(keys, values, ttlSeconds)
 	if err != nil {
-		glog.Errorf("Error creating JSON for prebid cache: %v", err)
+		logger.Errorf("Error creating JSON for prebid cache: %v", err)
 		return uuidsToReturn
 	}
 	httpReq, err := http.NewRequest("POST", c.putUrl, bytes.NewReader(postBody))
 	if err != nil {
-		glog.Errorf("Error creating POST request to prebid cache: %v", err)
+		logger.Errorf("Error creating POST request to prebid cache: %v", err)
 		return uuidsToReturn
 	}
 	httpReq.Header.Add("Content-Type", "application/json;charset=utf-8")
@@ -61,26 +87,26 @@ func (c *clientImpl) PutJson(ctx context.Context, values []json.RawMessage) (uui
 
 	anResp, err := ctxhttp.Do(ctx, c.httpClient, httpReq)
 	if err != nil {
-		glog.Errorf("Error sending the request to Prebid Cache: %v", err)
+		logger.Errorf("Error sending the request to Prebid Cache: %v", err)
 		return uuidsToReturn
 	}
 	defer anResp.Body.Close()
 
 	responseBody, err := ioutil.ReadAll(anResp.Body)
 	if anResp.StatusCode != 200 {
-		glog.Errorf("Prebid Cache call to %s returned %d: %s", putURL, anResp.StatusCode, responseBody)
+		logger.Errorf("Prebid Cache call to %s returned %d: %s", c.putUrl, anResp.StatusCode, responseBody)
 		return uuidsToReturn
 	}
 
 	currentIndex := 0
 	processResponse := func(uuidObj []byte, dataType jsonparser.ValueType, offset int, err error) {
 		if uuid, valueType, _, err := jsonparser.Get(uuidObj, "uuid"); err != nil {
-			glog.Errorf("Prebid Cache returned a bad value at index %d. Error was: %v. Response body was: %s", currentIndex, err, string(responseBody))
+			logger.Errorf("Prebid Cache returned a bad value at index %d. Error was: %v. Response body was: %s", currentIndex, err, string(responseBody))
 		} else if valueType != jsonparser.String {
-			glog.Errorf("Prebid Cache returned a %v at index %d in: %v", valueType, currentIndex, string(responseBody))
+			logger.Errorf("Prebid Cache returned a %v at index %d in: %v", valueType, currentIndex, string(responseBody))
 		} else {
 			if uuidsToReturn[currentIndex], err = jsonparser.ParseString(uuid); err != nil {
-				glog.Errorf("Prebid Cache response index %d could not be parsed as string: %v", currentIndex, err)
+				logger.Errorf("Prebid Cache response index %d could not be parsed as string: %v", currentIndex, err)
 				uuidsToReturn[currentIndex] = ""
 			}
 		}
@@ -88,20 +114,28 @@ func (c *clientImpl) PutJson(ctx context.Context, values []json.RawMessage) (uui
 	}
 
 	if _, err := jsonparser.ArrayEach(responseBody, processResponse, "responses"); err != nil {
-		glog.Errorf("Error interpreting Prebid Cache response: %v\nResponse was: %s", err, string(responseBody))
+		logger.Errorf("Error interpreting Prebid Cache response: %v\nResponse was: %s", err, string(responseBody))
 		return uuidsToReturn
 	}
 
 	return uuidsToReturn
 }
 
-func encodeValues(values []json.RawMessage) ([]byte, error) {
+func encodeValues(keys []string, values []json.RawMessage, ttlSeconds []int64) ([]byte, error) {
 	// This function assumes that m is non-nil and has at least one element.
 	// clientImp.PutBids should respect this.
 	var buf bytes.Buffer
 	buf.WriteString(`{"puts":[`)
 	for i := 0; i < len(values); i++ {
-		if err := encodeValueToBuffer(values[i], i != 0, &buf); err != nil {
+		var key string
+		if keys != nil {
+			key = keys[i]
+		}
+		var ttl int64
+		if ttlSeconds != nil {
+			ttl = ttlSeconds[i]
+		}
+		if err := encodeValueToBuffer(key, values[i], ttl, i != 0, &buf); err != nil {
 			return nil, err
 		}
 	}
@@ -109,7 +143,7 @@ func encodeValues(values []json.RawMessage) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func encodeValueToBuffer(value json.RawMessage, leadingComma bool, buffer *bytes.Buffer) error {
+func encodeValueToBuffer(key string, value json.RawMessage, ttlSeconds int64, leadingComma bool, buffer *bytes.Buffer) error {
 	if leadingComma {
 		buffer.WriteByte(',')
 	}
@@ -120,6 +154,15 @@ func encodeValueToBuffer(value json.RawMessage, leadingComma bool, buffer *bytes
 	} else {
 		buffer.WriteString(`{"type":"json","value":`)
 		buffer.Write(encodedBytes)
+		if key != "" {
+			buffer.WriteString(`,"key":`)
+			encodedKey, _ := json.Marshal(key)
+			buffer.Write(encodedKey)
+		}
+		if ttlSeconds > 0 {
+			buffer.WriteString(`,"ttlseconds":`)
+			buffer.WriteString(strconv.FormatInt(ttlSeconds, 10))
+		}
 		buffer.WriteByte('}')
 	}
 	return nil
@@ -3,6 +3,7 @@ package prebid_cache_client
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -21,9 +22,9 @@ func TestEmptyPut(t *testing.T) {
 		httpClient: server.Client(),
 		putUrl:     server.URL,
 	}
-	ids := client.PutJson(context.Background(), nil)
+	ids := client.PutJson(context.Background(), nil, nil)
 	assertIntEqual(t, len(ids), 0)
-	ids = client.PutJson(context.Background(), []json.RawMessage{})
+	ids = client.PutJson(context.Background(), []json.RawMessage{}, nil)
 	assertIntEqual(t, len(ids), 0)
 }
 
@@ -38,7 +39,7 @@ func TestBadResponse(t *testing.T) {
 		httpClient: server.Client(),
 		putUrl:     server.URL,
 	}
-	ids := client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")})
+	ids := client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")}, nil)
 	assertIntEqual(t, len(ids), 2)
 	assertStringEqual(t, ids[0], "")
 	assertStringEqual(t, ids[1], "")
@@ -58,7 +59,7 @@ func TestCancelledContext(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	ids := client.PutJson(ctx, []json.RawMessage{json.RawMessage("true")})
+	ids := client.PutJson(ctx, []json.RawMessage{json.RawMessage("true")}, nil)
 	assertIntEqual(t, len(ids), 1)
 	assertStringEqual(t, ids[0], "")
 }
@@ -72,12 +73,48 @@ func TestSuccessfulPut(t *testing.T) {
 		putUrl:     server.URL,
 	}
 
-	ids := client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")})
+	ids := client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")}, nil)
 	assertIntEqual(t, len(ids), 2)
 	assertStringEqual(t, ids[0], "0")
 	assertStringEqual(t, ids[1], "1")
 }
 
+func TestPutWithKeys(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"responses":[{"uuid":"key-1"}]}`))
+	}))
+	defer server.Close()
+
+	client := &clientImpl{
+		httpClient: server.Client(),
+		putUrl:     server.URL,
+	}
+
+	ids := client.PutJsonWithKeys(context.Background(), []string{"key-1"}, []json.RawMessage{json.RawMessage("true")}, nil)
+	assertIntEqual(t, len(ids), 1)
+	assertStringEqual(t, ids[0], "key-1")
+	assertStringEqual(t, string(body), `{"puts":[{"type":"json","value":true,"key":"key-1"}]}`)
+}
+
+func TestPutWithTTLs(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"responses":[{"uuid":"0"},{"uuid":"1"}]}`))
+	}))
+	defer server.Close()
+
+	client := &clientImpl{
+		httpClient: server.Client(),
+		putUrl:     server.URL,
+	}
+
+	client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")}, []int64{300, 0})
+	assertStringEqual(t, string(body), `{"puts":[{"type":"json","value":true,"ttlseconds":300},{"type":"json","value":false}]}`)
+}
+
 func assertIntEqual(t *testing.T, expected, actual int) {
 	t.Helper()
 	if expected != actual {
@@ -0,0 +1,96 @@
+package prebid_cache_client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/util/uuidutil"
+)
+
+// redisSetter stores values in Redis. It exists so that the redisClient can be tested without a Redis server.
+type redisSetter interface {
+	// SetAll stores each value under its key with the TTL at the same index, in a single round trip.
+	// The returned slice has one error per key.
+	SetAll(ctx context.Context, keys []string, values [][]byte, ttls []time.Duration) []error
+}
+
+// newRedisClient makes a Client which writes bids straight to the Redis server which Prebid Cache reads from.
+func newRedisClient(conf *config.CacheRedis) Client {
+	return &redisClient{
+		setter: &goRedisSetter{
+			client: redis.NewClient(&redis.Options{
+				Addr:     conf.Addr,
+				Password: conf.Password,
+				DB:       conf.DB,
+			}),
+		},
+		ttl:       time.Duration(conf.TTLSeconds) * time.Second,
+		keyPrefix: conf.KeyPrefix,
+	}
+}
+
+type redisClient struct {
+	setter    redisSetter
+	ttl       time.Duration
+	keyPrefix string
+}
+
+func (c *redisClient) PutJson(ctx context.Context, values []json.RawMessage, ttlSeconds []int64) []string {
+	uuids := make([]string, len(values))
+	for i := range uuids {
+		uuids[i] = uuidutil.NewV4()
+	}
+	return c.PutJsonWithKeys(ctx, uuids, values, ttlSeconds)
+}
+
+func (c *redisClient) PutJsonWithKeys(ctx context.Context, uuids []string, values []json.RawMessage, ttlSeconds []int64) []string {
+	if len(values) < 1 {
+		return nil
+	}
+
+	uuids = append([]string(nil), uuids...)
+	keys := make([]string, len(values))
+	data := make([][]byte, len(values))
+	ttls := make([]time.Duration, len(values))
+	for i, value := range values {
+		keys[i] = c.keyPrefix + uuids[i]
+		// Prebid Cache saves each value with its type, so that it knows what to send back.
+		data[i] = append([]byte("json"), value...)
+		ttls[i] = c.ttl
+		if ttlSeconds != nil && ttlSeconds[i] > 0 {
+			ttls[i] = time.Duration(ttlSeconds[i]) * time.Second
+		}
+	}
+
+	errs := c.setter.SetAll(ctx, keys, data, ttls)
+	for i, err := range errs {
+		if err != nil {
+			logger.Errorf("Error saving bid %d to the Redis cache: %v", i, err)
+			uuids[i] = ""
+		}
+	}
+	return uuids
+}
+
+type goRedisSetter struct {
+	client *redis.Client
+}
+
+func (s *goRedisSetter) SetAll(ctx context.Context, keys []string, values [][]byte, ttls []time.Duration) []error {
+	pipe := s.client.WithContext(ctx).Pipeline()
+	cmds := make([]*redis.StatusCmd, len(keys))
+	for i := range keys {
+		cmds[i] = pipe.Set(keys[i], values[i], ttls[i])
+	}
+	pipe.Exec()
+
+	errs := make([]error, len(keys))
+	for i, cmd := range cmds {
+		errs[i] = cmd.Err()
+	}
+	return errs
+}
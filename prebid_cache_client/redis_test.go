@@ -0,0 +1,87 @@
+package prebid_cache_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisPut(t *testing.T) {
+	setter := &mockRedisSetter{}
+	client := &redisClient{
+		setter:    setter,
+		ttl:       time.Minute,
+		keyPrefix: "pbs:",
+	}
+
+	ids := client.PutJson(context.Background(), []json.RawMessage{json.RawMessage(`{"adm":"<div>"}`), json.RawMessage("true")}, nil)
+	assertIntEqual(t, len(ids), 2)
+	if ids[0] == "" || ids[0] == ids[1] {
+		t.Errorf("Each value should get its own ID. Got %v", ids)
+	}
+	for i, ttl := range setter.ttls {
+		if ttl != time.Minute {
+			t.Errorf("Bad TTL for value %d. Expected %v, got %v", i, time.Minute, ttl)
+		}
+	}
+	for i, key := range setter.keys {
+		if !strings.HasPrefix(key, "pbs:") || strings.TrimPrefix(key, "pbs:") != ids[i] {
+			t.Errorf("Key %d should be the prefix followed by the ID. Got %s", i, key)
+		}
+	}
+	assertStringEqual(t, string(setter.values[0]), `json{"adm":"<div>"}`)
+}
+
+func TestRedisPutTTLs(t *testing.T) {
+	setter := &mockRedisSetter{}
+	client := &redisClient{
+		setter: setter,
+		ttl:    time.Minute,
+	}
+
+	client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")}, []int64{3600, 0})
+	if len(setter.ttls) != 2 || setter.ttls[0] != time.Hour || setter.ttls[1] != time.Minute {
+		t.Errorf("Each value should get its own TTL, or the default if it has none. Got %v", setter.ttls)
+	}
+}
+
+func TestRedisPutFailures(t *testing.T) {
+	client := &redisClient{
+		setter: &mockRedisSetter{
+			errs: []error{nil, errors.New("connection refused")},
+		},
+	}
+	ids := client.PutJson(context.Background(), []json.RawMessage{json.RawMessage("true"), json.RawMessage("false")}, nil)
+	assertIntEqual(t, len(ids), 2)
+	if ids[0] == "" {
+		t.Errorf("Values which were saved should have an ID.")
+	}
+	assertStringEqual(t, ids[1], "")
+}
+
+func TestRedisEmptyPut(t *testing.T) {
+	client := &redisClient{
+		setter: &mockRedisSetter{},
+	}
+	assertIntEqual(t, len(client.PutJson(context.Background(), nil, nil)), 0)
+}
+
+type mockRedisSetter struct {
+	keys   []string
+	values [][]byte
+	ttls   []time.Duration
+	errs   []error
+}
+
+func (s *mockRedisSetter) SetAll(ctx context.Context, keys []string, values [][]byte, ttls []time.Duration) []error {
+	s.keys = keys
+	s.values = values
+	s.ttls = ttls
+	if s.errs != nil {
+		return s.errs
+	}
+	return make([]error, len(keys))
+}
@@ -0,0 +1,173 @@
+package privacy
+
+import (
+	"github.com/prebid/prebid-server/config"
+)
+
+// Activity is something a component can do with the user's data. Each one can be allowed or denied
+// through the activities config, for each account.
+type Activity int
+
+const (
+	ActivitySyncUser Activity = iota
+	ActivityFetchBids
+	ActivityEnrichUFPD
+	ActivityTransmitUFPD
+	ActivityTransmitPreciseGeo
+	ActivityTransmitTIDs
+)
+
+func (a Activity) String() string {
+	switch a {
+	case ActivitySyncUser:
+		return "syncUser"
+	case ActivityFetchBids:
+		return "fetchBids"
+	case ActivityEnrichUFPD:
+		return "enrichUfpd"
+	case ActivityTransmitUFPD:
+		return "transmitUfpd"
+	case ActivityTransmitPreciseGeo:
+		return "transmitPreciseGeo"
+	case ActivityTransmitTIDs:
+		return "transmitTids"
+	}
+	return "unknown"
+}
+
+// Component is the thing which wants to perform an activity. Bidders are identified by BidderName,
+// and the other components, like the enrichers, by Name. GVLID should be 0 if it isn't known.
+type Component struct {
+	BidderName string
+	Name       string
+	GVLID      uint16
+}
+
+// Policy is a privacy law or signal, like COPPA or the user's GDPR consent, which denies activities
+// whatever the account's rules say.
+type Policy interface {
+	// Name identifies the policy. It's returned by ActivityControl.DeniedBy when the policy denies an activity.
+	Name() string
+	// Allow returns false if the policy doesn't let the component perform the activity.
+	Allow(activity Activity, component Component) bool
+}
+
+// PolicyActivityRules is returned by ActivityControl.DeniedBy when the account's rules deny an activity.
+const PolicyActivityRules = "activities"
+
+// ActivityControl decides which activities are allowed for a single account, and a single request
+// once the request's policies are added.
+type ActivityControl struct {
+	controls config.ActivityControls
+	policies []Policy
+}
+
+// NewActivityControl returns the ActivityControl for the given account.
+func NewActivityControl(cfg *config.Activities, account string) ActivityControl {
+	if cfg == nil {
+		return ActivityControl{}
+	}
+	return ActivityControl{
+		controls: cfg.ForAccount(account),
+	}
+}
+
+// WithPolicies returns a copy of the control which also checks the policies. Nil policies are skipped.
+func (c ActivityControl) WithPolicies(policies ...Policy) ActivityControl {
+	added := make([]Policy, len(c.policies), len(c.policies)+len(policies))
+	copy(added, c.policies)
+	for _, policy := range policies {
+		if policy != nil {
+			added = append(added, policy)
+		}
+	}
+	c.policies = added
+	return c
+}
+
+// Allow returns true if the component may perform the activity.
+func (c ActivityControl) Allow(activity Activity, component Component) bool {
+	return c.DeniedBy(activity, component) == ""
+}
+
+// DeniedBy returns the name of the first policy which denies the activity, PolicyActivityRules if only the
+// account's rules deny it, or an empty string if it's allowed. The policies are checked in the order they
+// were added, and before the rules, since the rules can't allow what the law doesn't.
+func (c ActivityControl) DeniedBy(activity Activity, component Component) string {
+	for _, policy := range c.policies {
+		if !policy.Allow(activity, component) {
+			return policy.Name()
+		}
+	}
+	if !c.rulesAllow(activity, component) {
+		return PolicyActivityRules
+	}
+	return ""
+}
+
+func (c *ActivityControl) rulesAllow(activity Activity, component Component) bool {
+	rules := c.rules(activity)
+	if rules == nil {
+		return true
+	}
+	for _, rule := range rules.Rules {
+		if conditionMatches(&rule.Condition, component) {
+			return rule.Allow
+		}
+	}
+	return rules.Default == nil || *rules.Default
+}
+
+// RandomizeTIDs returns true if bidders which can't get the transaction IDs should get random ones, instead of none at all.
+func (c ActivityControl) RandomizeTIDs() bool {
+	return c.controls.RandomizeTIDs != nil && *c.controls.RandomizeTIDs
+}
+
+func (c *ActivityControl) rules(activity Activity) *config.Activity {
+	switch activity {
+	case ActivitySyncUser:
+		return &c.controls.SyncUser
+	case ActivityFetchBids:
+		return &c.controls.FetchBids
+	case ActivityEnrichUFPD:
+		return &c.controls.EnrichUFPD
+	case ActivityTransmitUFPD:
+		return &c.controls.TransmitUFPD
+	case ActivityTransmitPreciseGeo:
+		return &c.controls.TransmitPreciseGeo
+	case ActivityTransmitTIDs:
+		return &c.controls.TransmitTIDs
+	}
+	return nil
+}
+
+func conditionMatches(condition *config.ActivityCondition, component Component) bool {
+	if len(condition.BidderNames) > 0 && !containsString(condition.BidderNames, component.BidderName) {
+		return false
+	}
+	if len(condition.ComponentNames) > 0 && !containsString(condition.ComponentNames, component.Name) {
+		return false
+	}
+	if len(condition.GVLIDs) > 0 && !containsInt(condition.GVLIDs, int(component.GVLID)) {
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
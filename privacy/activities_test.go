@@ -0,0 +1,125 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestActivitiesAllowedByDefault(t *testing.T) {
+	control := NewActivityControl(&config.Activities{}, "some-account")
+	if !control.Allow(ActivityFetchBids, Component{BidderName: "appnexus"}) {
+		t.Errorf("Activities with no rules should be allowed.")
+	}
+	if !NewActivityControl(nil, "").Allow(ActivitySyncUser, Component{}) {
+		t.Errorf("Activities should be allowed when there's no config.")
+	}
+}
+
+func TestActivityRules(t *testing.T) {
+	deny := false
+	cfg := &config.Activities{
+		Host: config.ActivityControls{
+			TransmitUFPD: config.Activity{
+				Default: &deny,
+				Rules: []config.ActivityRule{
+					{Allow: true, Condition: config.ActivityCondition{BidderNames: []string{"appnexus"}, GVLIDs: []int{32}}},
+					{Allow: true, Condition: config.ActivityCondition{GVLIDs: []int{52}}},
+				},
+			},
+		},
+	}
+	control := NewActivityControl(cfg, "some-account")
+
+	testCases := []struct {
+		description string
+		component   Component
+		expected    bool
+	}{
+		{"bidder and gvl id match", Component{BidderName: "appnexus", GVLID: 32}, true},
+		{"only bidder matches", Component{BidderName: "appnexus", GVLID: 1}, false},
+		{"gvl id matches", Component{BidderName: "rubicon", GVLID: 52}, true},
+		{"nothing matches", Component{BidderName: "pubmatic", GVLID: 76}, false},
+	}
+	for _, test := range testCases {
+		if allowed := control.Allow(ActivityTransmitUFPD, test.component); allowed != test.expected {
+			t.Errorf("%s: expected %t. Got %t", test.description, test.expected, allowed)
+		}
+	}
+
+	if !control.Allow(ActivityTransmitPreciseGeo, Component{BidderName: "pubmatic"}) {
+		t.Errorf("Rules for one activity should not affect the others.")
+	}
+}
+
+func TestFirstMatchingRuleWins(t *testing.T) {
+	cfg := &config.Activities{
+		Host: config.ActivityControls{
+			FetchBids: config.Activity{
+				Rules: []config.ActivityRule{
+					{Allow: false, Condition: config.ActivityCondition{BidderNames: []string{"appnexus"}}},
+					{Allow: true},
+				},
+			},
+		},
+	}
+	control := NewActivityControl(cfg, "")
+	if control.Allow(ActivityFetchBids, Component{BidderName: "appnexus"}) {
+		t.Errorf("The first matching rule should decide the activity.")
+	}
+	if !control.Allow(ActivityFetchBids, Component{BidderName: "rubicon"}) {
+		t.Errorf("A rule without conditions should match everything.")
+	}
+}
+
+func TestComponentNames(t *testing.T) {
+	deny := false
+	cfg := &config.Activities{
+		Host: config.ActivityControls{
+			EnrichUFPD: config.Activity{
+				Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{ComponentNames: []string{"topics"}}}},
+			},
+			TransmitUFPD: config.Activity{
+				Default: &deny,
+				Rules:   []config.ActivityRule{{Allow: true, Condition: config.ActivityCondition{BidderNames: []string{"appnexus"}}}},
+			},
+		},
+	}
+	control := NewActivityControl(cfg, "")
+	if control.Allow(ActivityEnrichUFPD, Component{Name: "topics"}) || !control.Allow(ActivityEnrichUFPD, Component{Name: "client_hints"}) {
+		t.Errorf("Components should be matched by name.")
+	}
+	if control.Allow(ActivityTransmitUFPD, Component{Name: "appnexus"}) {
+		t.Errorf("Bidder names shouldn't match other components.")
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	allow := true
+	cfg := &config.Activities{
+		Host: config.ActivityControls{
+			TransmitUFPD: config.Activity{Default: &allow},
+			FetchBids: config.Activity{
+				Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{BidderNames: []string{"pubmatic"}}}},
+			},
+		},
+	}
+	account := NewActivityControl(cfg, "")
+	control := account.WithPolicies(nil, COPPAPolicy, &denyPolicy{name: "other", activities: []Activity{ActivityTransmitUFPD, ActivityFetchBids}})
+
+	if policy := control.DeniedBy(ActivityTransmitUFPD, Component{BidderName: "appnexus"}); policy != "coppa" {
+		t.Errorf("The first policy which denies the activity should win over the rules. Got %q", policy)
+	}
+	if policy := control.DeniedBy(ActivityFetchBids, Component{BidderName: "appnexus"}); policy != "other" {
+		t.Errorf("Every policy should be checked. Got %q", policy)
+	}
+	if policy := account.DeniedBy(ActivityFetchBids, Component{BidderName: "pubmatic"}); policy != PolicyActivityRules {
+		t.Errorf("Activities which only the rules deny should be denied by %q. Got %q", PolicyActivityRules, policy)
+	}
+	if policy := control.DeniedBy(ActivityTransmitTIDs, Component{BidderName: "appnexus"}); policy != "" {
+		t.Errorf("Activities which nothing denies should be allowed. Got %q", policy)
+	}
+	if !account.Allow(ActivityTransmitUFPD, Component{BidderName: "appnexus"}) {
+		t.Errorf("WithPolicies shouldn't change the original control.")
+	}
+}
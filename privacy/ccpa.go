@@ -0,0 +1,26 @@
+package privacy
+
+import (
+	"encoding/json"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// USPrivacySignal returns the request's US Privacy string from regs.ext.us_privacy, or "" if it doesn't have one.
+func USPrivacySignal(req *openrtb.BidRequest) string {
+	if req == nil || req.Regs == nil || len(req.Regs.Ext) == 0 {
+		return ""
+	}
+	var regsExt openrtb_ext.ExtRegs
+	if err := json.Unmarshal(req.Regs.Ext, &regsExt); err != nil {
+		return ""
+	}
+	return regsExt.USPrivacy
+}
+
+// CCPAOptedOut returns true if a version 1 US Privacy string, like "1YYN", says that the user
+// has opted out of the sale of their personal data. Malformed strings never count as an opt out.
+func CCPAOptedOut(usPrivacy string) bool {
+	return len(usPrivacy) == 4 && usPrivacy[0] == '1' && usPrivacy[2] == 'Y'
+}
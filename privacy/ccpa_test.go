@@ -0,0 +1,34 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+func TestUSPrivacySignal(t *testing.T) {
+	req := &openrtb.BidRequest{Regs: &openrtb.Regs{Ext: openrtb.RawJSON(`{"gdpr":0,"us_privacy":"1YYN"}`)}}
+	if signal := USPrivacySignal(req); signal != "1YYN" {
+		t.Errorf("Expected the us_privacy string from regs.ext. Got %s", signal)
+	}
+	if signal := USPrivacySignal(&openrtb.BidRequest{}); signal != "" {
+		t.Errorf("Requests without regs should not have a us_privacy string. Got %s", signal)
+	}
+}
+
+func TestCCPAOptedOut(t *testing.T) {
+	tests := map[string]bool{
+		"1YYN": true,
+		"1-Y-": true,
+		"1YNN": false,
+		"1---": false,
+		"2YYN": false,
+		"1YY":  false,
+		"":     false,
+	}
+	for usPrivacy, expected := range tests {
+		if actual := CCPAOptedOut(usPrivacy); actual != expected {
+			t.Errorf("CCPAOptedOut(%q) should be %t", usPrivacy, expected)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package privacy
+
+import (
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+// DetectRegime finds the first geo privacy rule which matches the user's location, using device.geo or user.geo.
+// It returns nil if geo detection is disabled, the location is unknown, or no rules match.
+func DetectRegime(cfg *config.GeoPrivacy, req *openrtb.BidRequest) *config.GeoPrivacyRule {
+	if !cfg.Enabled {
+		return nil
+	}
+	geo := requestGeo(req)
+	if geo == nil || geo.Country == "" {
+		return nil
+	}
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if containsFold(rule.Countries, geo.Country) && (len(rule.Regions) == 0 || containsFold(rule.Regions, geo.Region)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// requestGeo prefers device.geo, since it describes where the user is right now. user.geo is usually their home.
+func requestGeo(req *openrtb.BidRequest) *openrtb.Geo {
+	if req.Device != nil && req.Device.Geo != nil && req.Device.Geo.Country != "" {
+		return req.Device.Geo
+	}
+	if req.User != nil {
+		return req.User.Geo
+	}
+	return nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestDetectRegime(t *testing.T) {
+	cfg := &config.GeoPrivacy{
+		Enabled: true,
+		Rules: []config.GeoPrivacyRule{
+			{Countries: []string{"DEU", "FRA"}, Regime: config.RegimeGDPR},
+			{Countries: []string{"USA"}, Regions: []string{"CA"}, Regime: config.RegimeUSState, GPPSID: 8},
+		},
+	}
+
+	testCases := []struct {
+		description string
+		req         *openrtb.BidRequest
+		expected    string
+	}{
+		{"device country", &openrtb.BidRequest{Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "DEU"}}}, config.RegimeGDPR},
+		{"user country", &openrtb.BidRequest{User: &openrtb.User{Geo: &openrtb.Geo{Country: "fra"}}}, config.RegimeGDPR},
+		{"matching region", &openrtb.BidRequest{Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "USA", Region: "CA"}}}, config.RegimeUSState},
+		{"other region", &openrtb.BidRequest{Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "USA", Region: "NY"}}}, ""},
+		{"no geo", &openrtb.BidRequest{}, ""},
+	}
+	for _, test := range testCases {
+		regime := ""
+		if rule := DetectRegime(cfg, test.req); rule != nil {
+			regime = rule.Regime
+		}
+		assertStrings(t, test.description, regime, test.expected)
+	}
+
+	cfg.Enabled = false
+	if DetectRegime(cfg, testCases[0].req) != nil {
+		t.Errorf("Regimes should not be detected when geo privacy is disabled.")
+	}
+}
@@ -0,0 +1,96 @@
+package privacy
+
+import (
+	"encoding/json"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// COPPAPolicy applies to children. COPPA doesn't allow us to track them or to learn more about them,
+// so nothing may sync them, add data about them, or send their IDs, demographics or precise geo to bidders.
+var COPPAPolicy Policy = &denyPolicy{
+	name:       "coppa",
+	activities: []Activity{ActivitySyncUser, ActivityEnrichUFPD, ActivityTransmitUFPD, ActivityTransmitPreciseGeo},
+}
+
+// NewCOPPAPolicy returns the COPPAPolicy for requests which are for child-directed content, via regs.coppa,
+// or nil if the request isn't.
+func NewCOPPAPolicy(req *openrtb.BidRequest) Policy {
+	if req.Regs == nil || req.Regs.COPPA != 1 {
+		return nil
+	}
+	return COPPAPolicy
+}
+
+// NewCCPAPolicy returns the policy for users who have opted out of the sale of their data through
+// regs.ext.us_privacy, or nil if they haven't. Requests without a us_privacy string are treated as
+// opted out if defaultOptOut is true. Bidders can't get the user's IDs, demographics or device IDs.
+func NewCCPAPolicy(req *openrtb.BidRequest, defaultOptOut bool) Policy {
+	usPrivacy := USPrivacySignal(req)
+	if usPrivacy == "" && !defaultOptOut {
+		return nil
+	}
+	if usPrivacy != "" && !CCPAOptedOut(usPrivacy) {
+		return nil
+	}
+	return &denyPolicy{
+		name:       "ccpa",
+		activities: []Activity{ActivityTransmitUFPD},
+	}
+}
+
+// NewLMTPolicy returns the policy for app requests which say that the user has limited ad tracking, either
+// through device.lmt or the iOS app tracking status in device.ext.atts, or nil if they don't or the account
+// doesn't enforce it. Nothing may add data about the user, or send their IDs or precise geo to bidders.
+func NewLMTPolicy(cfg *config.LMT, req *openrtb.BidRequest) Policy {
+	if req.App == nil || req.Device == nil || !isTrackingLimited(req.Device) {
+		return nil
+	}
+	account := ""
+	if req.App.Publisher != nil {
+		account = req.App.Publisher.ID
+	}
+	if !cfg.Enforced(account) {
+		return nil
+	}
+	return &denyPolicy{
+		name:       "lmt",
+		activities: []Activity{ActivityEnrichUFPD, ActivityTransmitUFPD, ActivityTransmitPreciseGeo},
+	}
+}
+
+// isTrackingLimited returns true if device.lmt is 1, or if device.ext.atts says the user hasn't allowed tracking.
+func isTrackingLimited(device *openrtb.Device) bool {
+	if device.Lmt == 1 {
+		return true
+	}
+	if len(device.Ext) == 0 {
+		return false
+	}
+	var deviceExt openrtb_ext.ExtDevice
+	if err := json.Unmarshal(device.Ext, &deviceExt); err != nil || deviceExt.ATTS == nil {
+		return false
+	}
+	return *deviceExt.ATTS == openrtb_ext.ATTSRestricted || *deviceExt.ATTS == openrtb_ext.ATTSDenied
+}
+
+// denyPolicy denies its activities to every component.
+type denyPolicy struct {
+	name       string
+	activities []Activity
+}
+
+func (p *denyPolicy) Name() string {
+	return p.name
+}
+
+func (p *denyPolicy) Allow(activity Activity, component Component) bool {
+	for _, denied := range p.activities {
+		if activity == denied {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package privacy
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestNewCOPPAPolicy(t *testing.T) {
+	if policy := NewCOPPAPolicy(&openrtb.BidRequest{Regs: &openrtb.Regs{COPPA: 1}}); policy != COPPAPolicy {
+		t.Errorf("Requests with regs.coppa=1 should get the COPPA policy. Got %v", policy)
+	}
+	if policy := NewCOPPAPolicy(&openrtb.BidRequest{}); policy != nil {
+		t.Errorf("Requests without regs.coppa shouldn't get a policy. Got %v", policy)
+	}
+	for _, activity := range []Activity{ActivitySyncUser, ActivityEnrichUFPD, ActivityTransmitUFPD, ActivityTransmitPreciseGeo} {
+		if COPPAPolicy.Allow(activity, Component{BidderName: "appnexus"}) {
+			t.Errorf("COPPA should deny %s.", activity)
+		}
+	}
+	if !COPPAPolicy.Allow(ActivityFetchBids, Component{BidderName: "appnexus"}) {
+		t.Errorf("COPPA shouldn't stop bidders from bidding.")
+	}
+}
+
+func TestNewCCPAPolicy(t *testing.T) {
+	optedOut := &openrtb.BidRequest{Regs: &openrtb.Regs{Ext: openrtb.RawJSON(`{"us_privacy":"1YYN"}`)}}
+	if NewCCPAPolicy(optedOut, false) == nil {
+		t.Errorf("Users who opted out should get the CCPA policy.")
+	}
+	optedIn := &openrtb.BidRequest{Regs: &openrtb.Regs{Ext: openrtb.RawJSON(`{"us_privacy":"1YNN"}`)}}
+	if NewCCPAPolicy(optedIn, true) != nil {
+		t.Errorf("The host's default should not override the request's us_privacy string.")
+	}
+	noSignal := &openrtb.BidRequest{}
+	if NewCCPAPolicy(noSignal, false) != nil {
+		t.Errorf("Requests without a us_privacy string should not get the policy by default.")
+	}
+	policy := NewCCPAPolicy(noSignal, true)
+	if policy == nil {
+		t.Fatalf("Requests without a us_privacy string should get the policy if the host's default is to opt out.")
+	}
+	if policy.Allow(ActivityTransmitUFPD, Component{}) || !policy.Allow(ActivityTransmitPreciseGeo, Component{}) {
+		t.Errorf("CCPA should only deny transmit_ufpd.")
+	}
+}
+
+func TestNewLMTPolicy(t *testing.T) {
+	cfg := &config.LMT{
+		Enforce: true,
+		Accounts: []config.AccountLMT{
+			{ID: "exempt-account", Enforce: false},
+		},
+	}
+	testCases := []struct {
+		description string
+		publisher   string
+		device      *openrtb.Device
+		site        bool
+		expected    bool
+	}{
+		{"lmt=1", "some-account", &openrtb.Device{Lmt: 1}, false, true},
+		{"att denied", "some-account", &openrtb.Device{Ext: openrtb.RawJSON(`{"atts":2}`)}, false, true},
+		{"att restricted", "some-account", &openrtb.Device{Ext: openrtb.RawJSON(`{"atts":1}`)}, false, true},
+		{"att authorized", "some-account", &openrtb.Device{Ext: openrtb.RawJSON(`{"atts":3}`)}, false, false},
+		{"no flags", "some-account", &openrtb.Device{}, false, false},
+		{"exempt account", "exempt-account", &openrtb.Device{Lmt: 1}, false, false},
+		{"site request", "some-account", &openrtb.Device{Lmt: 1}, true, false},
+	}
+
+	for _, test := range testCases {
+		req := &openrtb.BidRequest{Device: test.device}
+		if test.site {
+			req.Site = &openrtb.Site{Publisher: &openrtb.Publisher{ID: test.publisher}}
+		} else {
+			req.App = &openrtb.App{Publisher: &openrtb.Publisher{ID: test.publisher}}
+		}
+		policy := NewLMTPolicy(cfg, req)
+		if (policy != nil) != test.expected {
+			t.Errorf("%s: expected a policy %t. Got %v", test.description, test.expected, policy)
+			continue
+		}
+		if policy != nil && (policy.Allow(ActivityTransmitUFPD, Component{}) || policy.Allow(ActivityTransmitPreciseGeo, Component{})) {
+			t.Errorf("%s: LMT should deny the user's IDs and precise geo.", test.description)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package privacy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/util/uuidutil"
+)
+
+// This file has the building blocks for removing personal data from OpenRTB requests.
+//
+// None of these functions mutate their arguments. Anything which needs to change is copied first,
+// so the original request can still be used for the auction.
+
+var (
+	// IPv4 addresses keep their first 3 octets, which is still enough for rough geo lookups.
+	ipv4Mask = net.CIDRMask(24, 32)
+	// IPv6 addresses keep their routing prefix, and lose the interface ID.
+	ipv6Mask = net.CIDRMask(64, 128)
+)
+
+// MaskIPv4 zeroes the last octet of an IPv4 address. Anything which isn't a valid IPv4 address is removed.
+func MaskIPv4(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() == nil {
+		return ""
+	}
+	return parsed.To4().Mask(ipv4Mask).String()
+}
+
+// MaskIPv6 zeroes the last 64 bits of an IPv6 address. Anything which isn't a valid IPv6 address is removed.
+func MaskIPv6(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ""
+	}
+	return parsed.Mask(ipv6Mask).String()
+}
+
+// HashID returns the hex-encoded SHA-256 of the ID. Empty IDs stay empty.
+func HashID(id string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// ScrubDeviceForAnalytics returns a copy of the device with its IP addresses masked and its IFA hashed.
+// The hash keeps the IFA useful for counting unique devices, without logging the ID itself.
+func ScrubDeviceForAnalytics(device *openrtb.Device) *openrtb.Device {
+	if device == nil {
+		return nil
+	}
+	clone := *device
+	if clone.IP != "" {
+		clone.IP = MaskIPv4(clone.IP)
+	}
+	if clone.IPv6 != "" {
+		clone.IPv6 = MaskIPv6(clone.IPv6)
+	}
+	clone.IFA = HashID(clone.IFA)
+	return &clone
+}
+
+// ScrubDeviceIDs returns a copy of the device without the IFA or any other hardware IDs.
+func ScrubDeviceIDs(device *openrtb.Device) *openrtb.Device {
+	if device == nil {
+		return nil
+	}
+	clone := *device
+	clone.IFA = ""
+	clone.DIDSHA1 = ""
+	clone.DIDMD5 = ""
+	clone.DPIDSHA1 = ""
+	clone.DPIDMD5 = ""
+	clone.MACSHA1 = ""
+	clone.MACMD5 = ""
+	return &clone
+}
+
+// ScrubDeviceGeo returns a copy of the device with its IP addresses masked, and without precise geo.
+func ScrubDeviceGeo(device *openrtb.Device) *openrtb.Device {
+	if device == nil {
+		return nil
+	}
+	clone := *device
+	if clone.IP != "" {
+		clone.IP = MaskIPv4(clone.IP)
+	}
+	if clone.IPv6 != "" {
+		clone.IPv6 = MaskIPv6(clone.IPv6)
+	}
+	clone.Geo = scrubPreciseGeo(clone.Geo)
+	return &clone
+}
+
+// ScrubUserIDsAndDemographics returns a copy of the user without any IDs, or the year of birth and gender.
+func ScrubUserIDsAndDemographics(user *openrtb.User) *openrtb.User {
+	if user == nil {
+		return nil
+	}
+	clone := ScrubUserIDs(user)
+	clone.ID = ""
+	clone.Yob = 0
+	clone.Gender = ""
+	return clone
+}
+
+// ScrubUserGeo returns a copy of the user without precise geo.
+func ScrubUserGeo(user *openrtb.User) *openrtb.User {
+	if user == nil {
+		return nil
+	}
+	clone := *user
+	clone.Geo = scrubPreciseGeo(clone.Geo)
+	return &clone
+}
+
+// ScrubSourceTID returns a copy of the source without the transaction ID.
+func ScrubSourceTID(source *openrtb.Source) *openrtb.Source {
+	if source == nil {
+		return nil
+	}
+	clone := *source
+	clone.TID = ""
+	return &clone
+}
+
+// RandomizeSourceTID returns a copy of the source with a new, random transaction ID.
+func RandomizeSourceTID(source *openrtb.Source) *openrtb.Source {
+	if source == nil {
+		return nil
+	}
+	clone := *source
+	clone.TID = uuidutil.NewV4()
+	return &clone
+}
+
+// ScrubImpTIDs returns a copy of the imps without the imp.ext.tid transaction IDs.
+// If randomize is true, each one is replaced by a new, random transaction ID instead.
+func ScrubImpTIDs(imps []openrtb.Imp, randomize bool) []openrtb.Imp {
+	clones := make([]openrtb.Imp, len(imps))
+	copy(clones, imps)
+	for i := 0; i < len(clones); i++ {
+		var ext map[string]openrtb.RawJSON
+		if err := json.Unmarshal(clones[i].Ext, &ext); err != nil {
+			continue
+		}
+		if _, ok := ext["tid"]; !ok {
+			continue
+		}
+		if randomize {
+			ext["tid"] = openrtb.RawJSON(`"` + uuidutil.NewV4() + `"`)
+		} else {
+			delete(ext, "tid")
+		}
+		if extBytes, err := json.Marshal(ext); err == nil {
+			clones[i].Ext = extBytes
+		}
+	}
+	return clones
+}
+
+// scrubPreciseGeo returns a copy of the geo which only locates the user to their country and region.
+func scrubPreciseGeo(geo *openrtb.Geo) *openrtb.Geo {
+	if geo == nil {
+		return nil
+	}
+	clone := *geo
+	clone.Lat = 0
+	clone.Lon = 0
+	clone.Metro = ""
+	clone.City = ""
+	clone.ZIP = ""
+	return &clone
+}
+
+// ScrubUserIDs returns a copy of the user without the IDs which other platforms know them by:
+// user.buyeruid, user.ext.eids, user.ext.digitrust and user.ext.prebid.buyeruids.
+func ScrubUserIDs(user *openrtb.User) *openrtb.User {
+	if user == nil {
+		return nil
+	}
+	clone := *user
+	clone.BuyerUID = ""
+	clone.Ext = scrubUserExtIDs(clone.Ext)
+	return &clone
+}
+
+// scrubUserExtIDs removes eids, digitrust and prebid.buyeruids from user.ext. Everything else is kept as-is.
+// If the ext can't be parsed, we can't tell what's in it, so the whole thing is dropped.
+func scrubUserExtIDs(ext openrtb.RawJSON) openrtb.RawJSON {
+	if len(ext) == 0 {
+		return ext
+	}
+	var extMap map[string]json.RawMessage
+	if err := json.Unmarshal(ext, &extMap); err != nil {
+		return nil
+	}
+	delete(extMap, "eids")
+	delete(extMap, "digitrust")
+
+	if prebidJSON, ok := extMap["prebid"]; ok {
+		var prebid map[string]json.RawMessage
+		if err := json.Unmarshal(prebidJSON, &prebid); err != nil {
+			delete(extMap, "prebid")
+		} else {
+			delete(prebid, "buyeruids")
+			if len(prebid) == 0 {
+				delete(extMap, "prebid")
+			} else if extMap["prebid"], err = json.Marshal(prebid); err != nil {
+				return nil
+			}
+		}
+	}
+
+	if len(extMap) == 0 {
+		return nil
+	}
+	scrubbed, err := json.Marshal(extMap)
+	if err != nil {
+		return nil
+	}
+	return scrubbed
+}
@@ -0,0 +1,184 @@
+package privacy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+func TestMaskIPv4(t *testing.T) {
+	assertStrings(t, "valid", MaskIPv4("192.168.1.123"), "192.168.1.0")
+	assertStrings(t, "invalid", MaskIPv4("not-an-ip"), "")
+	assertStrings(t, "ipv6", MaskIPv4("2001:db8::1"), "")
+}
+
+func TestMaskIPv6(t *testing.T) {
+	assertStrings(t, "valid", MaskIPv6("2001:db8:85a3:8d3:1319:8a2e:370:7348"), "2001:db8:85a3:8d3::")
+	assertStrings(t, "invalid", MaskIPv6("not-an-ip"), "")
+	assertStrings(t, "ipv4", MaskIPv6("192.168.1.123"), "")
+}
+
+func TestHashID(t *testing.T) {
+	assertStrings(t, "empty", HashID(""), "")
+	if hashed := HashID("AEBE52E7-03EE-455A-B3C4-E57283966239"); len(hashed) != 64 || hashed == HashID("other") {
+		t.Errorf("IDs should be hashed to distinct SHA-256 hex strings. Got %s", hashed)
+	}
+}
+
+func TestScrubDeviceForAnalytics(t *testing.T) {
+	device := &openrtb.Device{
+		IP:   "1.2.3.4",
+		IPv6: "2001:db8::1",
+		IFA:  "some-ifa",
+		UA:   "some-ua",
+	}
+	scrubbed := ScrubDeviceForAnalytics(device)
+	assertStrings(t, "ip", scrubbed.IP, "1.2.3.0")
+	assertStrings(t, "ipv6", scrubbed.IPv6, "2001:db8::")
+	assertStrings(t, "ifa", scrubbed.IFA, HashID("some-ifa"))
+	assertStrings(t, "ua", scrubbed.UA, "some-ua")
+	assertStrings(t, "original ip", device.IP, "1.2.3.4")
+	assertStrings(t, "original ifa", device.IFA, "some-ifa")
+}
+
+func TestScrubUserIDs(t *testing.T) {
+	user := &openrtb.User{
+		ID:       "user-id",
+		BuyerUID: "buyer-id",
+		Ext:      openrtb.RawJSON(`{"consent":"abc","eids":[{"source":"x.com"}],"digitrust":{"id":"dt","keyv":1},"prebid":{"buyeruids":{"appnexus":"123"}}}`),
+	}
+	scrubbed := ScrubUserIDs(user)
+	assertStrings(t, "buyeruid", scrubbed.BuyerUID, "")
+	assertStrings(t, "id", scrubbed.ID, "user-id")
+	assertJSON(t, scrubbed.Ext, `{"consent":"abc"}`)
+	assertStrings(t, "original buyeruid", user.BuyerUID, "buyer-id")
+
+	if scrubbed := ScrubUserIDs(&openrtb.User{Ext: openrtb.RawJSON(`{"eids":[]}`)}); scrubbed.Ext != nil {
+		t.Errorf("An ext with nothing left in it should be removed. Got %s", string(scrubbed.Ext))
+	}
+	if scrubbed := ScrubUserIDs(&openrtb.User{Ext: openrtb.RawJSON(`malformed`)}); scrubbed.Ext != nil {
+		t.Errorf("An ext which can't be parsed should be removed. Got %s", string(scrubbed.Ext))
+	}
+	if ScrubUserIDs(nil) != nil {
+		t.Errorf("Scrubbing a nil user should return nil.")
+	}
+}
+
+func TestScrubDeviceIDsAndGeo(t *testing.T) {
+	device := &openrtb.Device{
+		IP:      "1.2.3.4",
+		IFA:     "some-ifa",
+		DIDSHA1: "some-did",
+		MACMD5:  "some-mac",
+		Geo:     &openrtb.Geo{Lat: 51.5, Lon: -0.12, Country: "GBR", City: "London", ZIP: "SW1"},
+	}
+	scrubbed := ScrubDeviceGeo(ScrubDeviceIDs(device))
+	assertStrings(t, "ip", scrubbed.IP, "1.2.3.0")
+	assertStrings(t, "ifa", scrubbed.IFA, "")
+	assertStrings(t, "didsha1", scrubbed.DIDSHA1, "")
+	assertStrings(t, "macmd5", scrubbed.MACMD5, "")
+	if scrubbed.Geo.Lat != 0 || scrubbed.Geo.Lon != 0 || scrubbed.Geo.City != "" || scrubbed.Geo.ZIP != "" {
+		t.Errorf("Precise geo should be removed. Got %#v", scrubbed.Geo)
+	}
+	assertStrings(t, "country", scrubbed.Geo.Country, "GBR")
+	if device.Geo.Lat != 51.5 || device.IFA != "some-ifa" {
+		t.Errorf("The original device should not be changed.")
+	}
+}
+
+func TestScrubUserIDsAndGeo(t *testing.T) {
+	user := &openrtb.User{
+		ID:       "user-id",
+		BuyerUID: "buyer-id",
+		Yob:      2010,
+		Gender:   "F",
+		Geo:      &openrtb.Geo{Lat: 51.5, Lon: -0.12},
+		Ext:      openrtb.RawJSON(`{"consent":"abc","eids":[{"source":"x.com"}]}`),
+	}
+	scrubbed := ScrubUserGeo(ScrubUserIDsAndDemographics(user))
+	assertStrings(t, "id", scrubbed.ID, "")
+	assertStrings(t, "buyeruid", scrubbed.BuyerUID, "")
+	assertStrings(t, "gender", scrubbed.Gender, "")
+	if scrubbed.Yob != 0 || scrubbed.Geo.Lat != 0 {
+		t.Errorf("Demographics and precise geo should be removed. Got %#v", scrubbed)
+	}
+	assertJSON(t, scrubbed.Ext, `{"consent":"abc"}`)
+	assertStrings(t, "original id", user.ID, "user-id")
+}
+
+func TestScrubIDsKeepsGeo(t *testing.T) {
+	device := ScrubDeviceIDs(&openrtb.Device{IP: "1.2.3.4", IFA: "some-ifa", Geo: &openrtb.Geo{Lat: 51.5}})
+	assertStrings(t, "ip", device.IP, "1.2.3.4")
+	assertStrings(t, "ifa", device.IFA, "")
+	if device.Geo.Lat != 51.5 {
+		t.Errorf("Scrubbing device IDs should keep precise geo. Got %#v", device.Geo)
+	}
+
+	user := ScrubUserIDsAndDemographics(&openrtb.User{ID: "user-id", Yob: 1980, Geo: &openrtb.Geo{Lat: 51.5}})
+	assertStrings(t, "id", user.ID, "")
+	if user.Yob != 0 || user.Geo.Lat != 51.5 {
+		t.Errorf("Scrubbing user IDs should remove demographics, but keep precise geo. Got %#v", user)
+	}
+}
+
+func TestScrubSourceTID(t *testing.T) {
+	source := &openrtb.Source{TID: "some-tid", PChain: "some-pchain"}
+	scrubbed := ScrubSourceTID(source)
+	assertStrings(t, "tid", scrubbed.TID, "")
+	assertStrings(t, "pchain", scrubbed.PChain, "some-pchain")
+	assertStrings(t, "original tid", source.TID, "some-tid")
+}
+
+func TestRandomizeSourceTID(t *testing.T) {
+	source := &openrtb.Source{TID: "some-tid", PChain: "some-pchain"}
+	randomized := RandomizeSourceTID(source)
+	if randomized.TID == "" || randomized.TID == "some-tid" {
+		t.Errorf("tid should be replaced by a random one. Got %q", randomized.TID)
+	}
+	assertStrings(t, "pchain", randomized.PChain, "some-pchain")
+	assertStrings(t, "original tid", source.TID, "some-tid")
+}
+
+func TestScrubImpTIDs(t *testing.T) {
+	imps := []openrtb.Imp{
+		{ID: "with-tid", Ext: openrtb.RawJSON(`{"tid":"some-tid","bidder":{"placementId":1}}`)},
+		{ID: "without-tid", Ext: openrtb.RawJSON(`{"bidder":{"placementId":1}}`)},
+	}
+
+	scrubbed := ScrubImpTIDs(imps, false)
+	assertJSON(t, scrubbed[0].Ext, `{"bidder":{"placementId":1}}`)
+	assertJSON(t, scrubbed[1].Ext, `{"bidder":{"placementId":1}}`)
+	assertJSON(t, imps[0].Ext, `{"tid":"some-tid","bidder":{"placementId":1}}`)
+
+	randomized := ScrubImpTIDs(imps, true)
+	var ext struct {
+		TID string `json:"tid"`
+	}
+	json.Unmarshal(randomized[0].Ext, &ext)
+	if ext.TID == "" || ext.TID == "some-tid" {
+		t.Errorf("imp.ext.tid should be replaced by a random one. Got %q", ext.TID)
+	}
+	assertJSON(t, randomized[1].Ext, `{"bidder":{"placementId":1}}`)
+}
+
+func assertStrings(t *testing.T, description string, actual string, expected string) {
+	t.Helper()
+	if actual != expected {
+		t.Errorf("%s: expected %q. Got %q", description, expected, actual)
+	}
+}
+
+func assertJSON(t *testing.T, actual []byte, expected string) {
+	t.Helper()
+	var actualVal, expectedVal interface{}
+	if err := json.Unmarshal(actual, &actualVal); err != nil {
+		t.Fatalf("Bad actual JSON %s: %v", string(actual), err)
+	}
+	json.Unmarshal([]byte(expected), &expectedVal)
+	actualCanonical, _ := json.Marshal(actualVal)
+	expectedCanonical, _ := json.Marshal(expectedVal)
+	if string(actualCanonical) != string(expectedCanonical) {
+		t.Errorf("Expected %s. Got %s", expected, string(actual))
+	}
+}
@@ -0,0 +1,82 @@
+package privacy
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Topics are the Privacy Sandbox topics for one taxonomy and classifier model,
+// parsed from the Sec-Browsing-Topics header.
+type Topics struct {
+	// SegTax is the IAB segment taxonomy ID of the topics taxonomy.
+	SegTax int
+	// SegClass is the version of the classifier model which picked the topics.
+	SegClass string
+	// SegIDs are the topic IDs.
+	SegIDs []int
+}
+
+// TopicsComponentName is the name which the activity controls know the topics enrichment by.
+const TopicsComponentName = "topics"
+
+// Taxonomy versions 1 through 10 of the Topics API have segment taxonomy IDs 600 through 609.
+const (
+	minTopicsTaxonomyVersion = 1
+	maxTopicsTaxonomyVersion = 10
+	topicsSegTaxOffset       = 599
+)
+
+// ParseTopicsHeader parses the Sec-Browsing-Topics header. It looks like this:
+//
+//	(1 2 3);v=chrome.1:1:2, (4);v=chrome.1:2:2, ();p=P0000000000
+//
+// where each entry has the topic IDs, and a version made of the browser version, taxonomy version and model version.
+// Padding entries, and entries which can't be parsed, are ignored.
+func ParseTopicsHeader(header string) []Topics {
+	var topics []Topics
+	for _, entry := range strings.Split(header, ",") {
+		if parsed, ok := parseTopicsEntry(strings.TrimSpace(entry)); ok {
+			topics = append(topics, parsed)
+		}
+	}
+	return topics
+}
+
+func parseTopicsEntry(entry string) (Topics, bool) {
+	params := strings.Split(entry, ";")
+	list := strings.TrimSpace(params[0])
+	if len(list) < 2 || list[0] != '(' || list[len(list)-1] != ')' {
+		return Topics{}, false
+	}
+
+	var parsed Topics
+	for _, field := range strings.Fields(list[1 : len(list)-1]) {
+		id, err := strconv.Atoi(field)
+		if err != nil || id < 0 {
+			return Topics{}, false
+		}
+		parsed.SegIDs = append(parsed.SegIDs, id)
+	}
+	if len(parsed.SegIDs) == 0 {
+		return Topics{}, false
+	}
+
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "v=") {
+			continue
+		}
+		version := strings.Split(strings.TrimPrefix(param, "v="), ":")
+		if len(version) != 3 || version[2] == "" {
+			return Topics{}, false
+		}
+		taxonomy, err := strconv.Atoi(version[1])
+		if err != nil || taxonomy < minTopicsTaxonomyVersion || taxonomy > maxTopicsTaxonomyVersion {
+			return Topics{}, false
+		}
+		parsed.SegTax = topicsSegTaxOffset + taxonomy
+		parsed.SegClass = version[2]
+		return parsed, true
+	}
+	return Topics{}, false
+}
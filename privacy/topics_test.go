@@ -0,0 +1,40 @@
+package privacy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopicsHeader(t *testing.T) {
+	testCases := []struct {
+		description string
+		header      string
+		expected    []Topics
+	}{
+		{"empty", "", nil},
+		{"padding only", "();p=P0000000000", nil},
+		{
+			"one entry",
+			"(1 2 3);v=chrome.1:1:2, ();p=P0000000000",
+			[]Topics{{SegTax: 600, SegClass: "2", SegIDs: []int{1, 2, 3}}},
+		},
+		{
+			"several taxonomies",
+			"(1);v=chrome.1:1:2, (4 5);v=chrome.1:2:3",
+			[]Topics{
+				{SegTax: 600, SegClass: "2", SegIDs: []int{1}},
+				{SegTax: 601, SegClass: "3", SegIDs: []int{4, 5}},
+			},
+		},
+		{"bad topic id", "(1 two);v=chrome.1:1:2", nil},
+		{"missing version", "(1 2)", nil},
+		{"unknown taxonomy", "(1);v=chrome.1:11:2", nil},
+		{"missing model", "(1);v=chrome.1:1:", nil},
+		{"bad entry is skipped", "(1;v=chrome.1:1:2, (7);v=chrome.1:1:2", []Topics{{SegTax: 600, SegClass: "2", SegIDs: []int{7}}}},
+	}
+	for _, test := range testCases {
+		if actual := ParseTopicsHeader(test.header); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %#v. Got %#v", test.description, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package rtd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// httpModule gets the data from a vendor's service. The bid request is POSTed to the endpoint, which responds
+// with the Segments as JSON, or with a 204 if it has nothing to add.
+type httpModule struct {
+	name     string
+	endpoint string
+	client   *http.Client
+}
+
+func newHTTPModule(cfg *config.RTDModule, client *http.Client) *httpModule {
+	return &httpModule{
+		name:     cfg.Name,
+		endpoint: cfg.Endpoint,
+		client:   client,
+	}
+}
+
+func (m *httpModule) Name() string {
+	return m.name
+}
+
+func (m *httpModule) Segments(ctx context.Context, bidRequest *openrtb.BidRequest) (*Segments, error) {
+	body, err := json.Marshal(bidRequest)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ctxhttp.Post(ctx, m.client, m.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var segments Segments
+		if err := json.NewDecoder(resp.Body).Decode(&segments); err != nil {
+			return nil, fmt.Errorf("the response wasn't valid segments: %v", err)
+		}
+		return &segments, nil
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("the service responded with status %d", resp.StatusCode)
+	}
+}
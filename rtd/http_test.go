@@ -0,0 +1,66 @@
+package rtd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+func TestHTTPModule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var bidRequest openrtb.BidRequest
+		if err := json.NewDecoder(r.Body).Decode(&bidRequest); err != nil || r.Method != "POST" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch bidRequest.ID {
+		case "known":
+			w.Write([]byte(`{"content":[{"segment":[{"id":"sunny"}]}],"user":[{"name":"weather-vendor","segment":[{"id":"umbrella"}]}]}`))
+		case "unknown":
+			w.WriteHeader(http.StatusNoContent)
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.RTD{
+		MaxTimeoutMillis: 50,
+		Modules:          []config.RTDModule{{Name: "weather", Endpoint: server.URL}},
+	}
+	enricher := NewEnricher(cfg, server.Client())
+	if enricher == nil || len(enricher.modules) != 1 || enricher.modules[0].timeout != 50*time.Millisecond {
+		t.Fatalf("The Enricher should have the configured module, with the capped timeout. Got %v", enricher)
+	}
+
+	bidRequest := &openrtb.BidRequest{ID: "known", Site: &openrtb.Site{Page: "test.somepage.com"}}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if bidRequest.Site.Content == nil || bidRequest.User == nil {
+		t.Fatalf("The service's segments should be added. Got %v and %v", bidRequest.Site.Content, bidRequest.User)
+	}
+	assertDataNames(t, "site.content.data", bidRequest.Site.Content.Data, "weather")
+	assertDataNames(t, "user.data", bidRequest.User.Data, "weather-vendor")
+
+	for _, id := range []string{"unknown", "slow", "broken"} {
+		module := newHTTPModule(&cfg.Modules[0], server.Client())
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		segments, err := module.Segments(ctx, &openrtb.BidRequest{ID: id})
+		cancel()
+		if segments != nil {
+			t.Errorf("%s: the service shouldn't return segments. Got %v", id, segments)
+		}
+		if (err == nil) != (id == "unknown") {
+			t.Errorf("%s: unexpected error: %v", id, err)
+		}
+	}
+}
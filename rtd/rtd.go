@@ -0,0 +1,159 @@
+// Package rtd adds real-time data to bid requests before they're sent to the bidders. Each module is a vendor's
+// service, such as a weather, geo segment or contextual classification service, which returns data segments for
+// the request. Bidders pay more for impressions they know more about.
+package rtd
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/prebid/prebid-server/tracing"
+)
+
+// Module returns the real-time data for a request.
+//
+// Implementations must be safe for concurrent access by multiple goroutines.
+// The request is shared with the other modules, so it must not be changed.
+type Module interface {
+	// Name identifies the module in the logs. It's also the name of its data, if the data doesn't have one.
+	Name() string
+	// Segments returns the data to add to the request. It must return by ctx's deadline, which is the module's budget.
+	Segments(ctx context.Context, bidRequest *openrtb.BidRequest) (*Segments, error)
+}
+
+// Segments is the data which a module adds to a request.
+type Segments struct {
+	// Content is added to site.content.data, or app.content.data for app requests.
+	Content []openrtb.Data `json:"content"`
+	// User is added to user.data.
+	User []openrtb.Data `json:"user"`
+}
+
+// timedModule is a module with its budget.
+type timedModule struct {
+	module  Module
+	timeout time.Duration
+}
+
+// Enricher runs the modules for each request, and adds their data to it.
+//
+// All functions on this struct are nil-safe. A nil Enricher leaves every request alone.
+type Enricher struct {
+	modules []timedModule
+}
+
+// NewEnricher returns an Enricher which calls the modules in cfg with the client, or nil if there aren't any.
+func NewEnricher(cfg *config.RTD, client *http.Client) *Enricher {
+	if len(cfg.Modules) == 0 {
+		return nil
+	}
+	enricher := &Enricher{}
+	for i := range cfg.Modules {
+		enricher.add(newHTTPModule(&cfg.Modules[i], client), cfg.TimeoutFor(&cfg.Modules[i]))
+	}
+	return enricher
+}
+
+// add adds a module, which gets up to timeout to return its data.
+func (e *Enricher) add(module Module, timeout time.Duration) {
+	e.modules = append(e.modules, timedModule{module, timeout})
+}
+
+// Enrich calls every module at once, and adds their data to the request once they've all returned or timed out.
+// The data is added in the order the modules were added, so that the requests are the same from one auction to the
+// next. Modules which fail or time out are skipped, and so are the ones which the control doesn't allow to
+// enrich_ufpd. Each module is a component named after the module.
+func (e *Enricher) Enrich(ctx context.Context, bidRequest *openrtb.BidRequest, control privacy.ActivityControl) {
+	if e == nil {
+		return
+	}
+	modules := make([]timedModule, 0, len(e.modules))
+	for _, timed := range e.modules {
+		if control.Allow(privacy.ActivityEnrichUFPD, privacy.Component{Name: timed.module.Name()}) {
+			modules = append(modules, timed)
+		}
+	}
+	if len(modules) == 0 {
+		return
+	}
+	ctx, span := tracing.StartSpan(ctx, "rtd.enrich", tracing.Int("modules", len(modules)))
+	defer span.End()
+
+	results := make([]*Segments, len(modules))
+	var wg sync.WaitGroup
+	for i, timed := range modules {
+		wg.Add(1)
+		go func(i int, timed timedModule) {
+			defer wg.Done()
+			moduleCtx, cancel := context.WithTimeout(ctx, timed.timeout)
+			defer cancel()
+			segments, err := timed.module.Segments(moduleCtx, bidRequest)
+			if err != nil {
+				logger.FromContext(ctx).With("module", timed.module.Name()).Debugf("Skipped the real-time data: %v", err)
+				return
+			}
+			results[i] = segments
+		}(i, timed)
+	}
+	wg.Wait()
+
+	for i, segments := range results {
+		if segments != nil {
+			addSegments(bidRequest, modules[i].module.Name(), segments)
+		}
+	}
+}
+
+// addSegments adds the module's data to the request. The site, app, content and user objects are copied first,
+// since the caller may share them with other requests.
+func addSegments(bidRequest *openrtb.BidRequest, moduleName string, segments *Segments) {
+	if content := withNames(segments.Content, moduleName); len(content) > 0 {
+		if bidRequest.Site != nil {
+			site := *bidRequest.Site
+			site.Content = appendContentData(site.Content, content)
+			bidRequest.Site = &site
+		} else if bidRequest.App != nil {
+			app := *bidRequest.App
+			app.Content = appendContentData(app.Content, content)
+			bidRequest.App = &app
+		}
+	}
+	if userData := withNames(segments.User, moduleName); len(userData) > 0 {
+		var user openrtb.User
+		if bidRequest.User != nil {
+			user = *bidRequest.User
+		}
+		user.Data = append(append([]openrtb.Data(nil), user.Data...), userData...)
+		bidRequest.User = &user
+	}
+}
+
+func appendContentData(content *openrtb.Content, data []openrtb.Data) *openrtb.Content {
+	var copied openrtb.Content
+	if content != nil {
+		copied = *content
+	}
+	copied.Data = append(append([]openrtb.Data(nil), copied.Data...), data...)
+	return &copied
+}
+
+// withNames returns the data which has segments, with the module's name on any data which doesn't have one.
+func withNames(data []openrtb.Data, moduleName string) []openrtb.Data {
+	named := make([]openrtb.Data, 0, len(data))
+	for _, thisData := range data {
+		if len(thisData.Segment) == 0 {
+			continue
+		}
+		if thisData.Name == "" {
+			thisData.Name = moduleName
+		}
+		named = append(named, thisData)
+	}
+	return named
+}
@@ -0,0 +1,146 @@
+package rtd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+func TestEnrich(t *testing.T) {
+	enricher := &Enricher{}
+	enricher.add(&fakeModule{name: "weather", delay: 20 * time.Millisecond, segments: &Segments{
+		Content: []openrtb.Data{{Segment: []openrtb.Segment{{ID: "sunny"}}}},
+	}}, time.Second)
+	enricher.add(&fakeModule{name: "geo", segments: &Segments{
+		Content: []openrtb.Data{{Name: "geo-vendor", Segment: []openrtb.Segment{{ID: "urban"}}}, {Name: "empty"}},
+		User:    []openrtb.Data{{Segment: []openrtb.Segment{{ID: "commuter"}}}},
+	}}, time.Second)
+
+	site := &openrtb.Site{Page: "test.somepage.com", Content: &openrtb.Content{Data: []openrtb.Data{{Name: "publisher"}}}}
+	bidRequest := &openrtb.BidRequest{Site: site}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+
+	assertDataNames(t, "site.content.data", bidRequest.Site.Content.Data, "publisher", "weather", "geo-vendor")
+	if bidRequest.User == nil {
+		t.Fatalf("request.user should be added for the user data.")
+	}
+	assertDataNames(t, "user.data", bidRequest.User.Data, "geo")
+	if len(site.Content.Data) != 1 {
+		t.Errorf("The original site shouldn't be changed. Got %v", site.Content.Data)
+	}
+}
+
+func TestEnrichApp(t *testing.T) {
+	enricher := &Enricher{}
+	enricher.add(&fakeModule{name: "contextual", segments: &Segments{
+		Content: []openrtb.Data{{Segment: []openrtb.Segment{{ID: "IAB1"}}}},
+	}}, time.Second)
+
+	bidRequest := &openrtb.BidRequest{App: &openrtb.App{Bundle: "com.example.game"}}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if bidRequest.App.Content == nil {
+		t.Fatalf("app.content should be added for app requests.")
+	}
+	assertDataNames(t, "app.content.data", bidRequest.App.Content.Data, "contextual")
+	if bidRequest.User != nil {
+		t.Errorf("request.user shouldn't be added without user data. Got %v", bidRequest.User)
+	}
+}
+
+func TestEnrichSkipsFailures(t *testing.T) {
+	enricher := &Enricher{}
+	enricher.add(&fakeModule{name: "slow", delay: time.Second, segments: &Segments{
+		User: []openrtb.Data{{Segment: []openrtb.Segment{{ID: "late"}}}},
+	}}, 10*time.Millisecond)
+	enricher.add(&fakeModule{name: "broken", err: errors.New("service unavailable")}, time.Second)
+	enricher.add(&fakeModule{name: "nothing"}, time.Second)
+
+	bidRequest := &openrtb.BidRequest{Site: &openrtb.Site{Page: "test.somepage.com"}}
+	start := time.Now()
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Modules shouldn't run past their timeout. Enrichment took %v", elapsed)
+	}
+	if bidRequest.User != nil || bidRequest.Site.Content != nil {
+		t.Errorf("Modules which fail or time out should be skipped. Got %v and %v", bidRequest.User, bidRequest.Site.Content)
+	}
+}
+
+func TestEnrichActivities(t *testing.T) {
+	enricher := &Enricher{}
+	enricher.add(&fakeModule{name: "weather", segments: &Segments{
+		Content: []openrtb.Data{{Segment: []openrtb.Segment{{ID: "sunny"}}}},
+	}}, time.Second)
+	enricher.add(&fakeModule{name: "geo", segments: &Segments{
+		User: []openrtb.Data{{Segment: []openrtb.Segment{{ID: "commuter"}}}},
+	}}, time.Second)
+
+	cfg := &config.Activities{
+		Host: config.ActivityControls{
+			EnrichUFPD: config.Activity{
+				Rules: []config.ActivityRule{{Allow: false, Condition: config.ActivityCondition{ComponentNames: []string{"geo"}}}},
+			},
+		},
+	}
+	bidRequest := &openrtb.BidRequest{Site: &openrtb.Site{Page: "test.somepage.com"}}
+	enricher.Enrich(context.Background(), bidRequest, privacy.NewActivityControl(cfg, ""))
+	if bidRequest.Site.Content == nil {
+		t.Fatalf("The modules which may enrich_ufpd should still add their data.")
+	}
+	assertDataNames(t, "site.content.data", bidRequest.Site.Content.Data, "weather")
+	if bidRequest.User != nil {
+		t.Errorf("The modules which can't enrich_ufpd should be skipped. Got %v", bidRequest.User)
+	}
+}
+
+func TestNilEnricher(t *testing.T) {
+	enricher := NewEnricher(&config.RTD{MaxTimeoutMillis: 50}, http.DefaultClient)
+	if enricher != nil {
+		t.Fatalf("An Enricher without modules should be nil.")
+	}
+	bidRequest := &openrtb.BidRequest{Site: &openrtb.Site{Page: "test.somepage.com"}}
+	enricher.Enrich(context.Background(), bidRequest, privacy.ActivityControl{})
+	if bidRequest.Site.Content != nil {
+		t.Errorf("A nil Enricher shouldn't change the request. Got %v", bidRequest.Site.Content)
+	}
+}
+
+func assertDataNames(t *testing.T, path string, data []openrtb.Data, expected ...string) {
+	t.Helper()
+	if len(data) != len(expected) {
+		t.Errorf("%s: expected %d data. Got %v", path, len(expected), data)
+		return
+	}
+	for i, name := range expected {
+		if data[i].Name != name {
+			t.Errorf("%s[%d].name: expected %s. Got %s", path, i, name, data[i].Name)
+		}
+	}
+}
+
+// fakeModule returns its segments or error after the delay, unless its context ends first.
+type fakeModule struct {
+	name     string
+	delay    time.Duration
+	segments *Segments
+	err      error
+}
+
+func (m *fakeModule) Name() string {
+	return m.name
+}
+
+func (m *fakeModule) Segments(ctx context.Context, bidRequest *openrtb.BidRequest) (*Segments, error) {
+	select {
+	case <-time.After(m.delay):
+		return m.segments, m.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -11,8 +11,8 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	metricsconfig "github.com/prebid/prebid-server/pbsmetrics/config"
 )
@@ -36,12 +36,12 @@ func Listen(cfg *config.Configuration, handler http.Handler, adminHandler http.H
 
 	mainListener, err := newListener(mainServer.Addr, metrics)
 	if err != nil {
-		glog.Errorf("Error listening for TCP connections on %s: %v", mainServer.Addr, err)
+		logger.Errorf("Error listening for TCP connections on %s: %v", mainServer.Addr, err)
 		return
 	}
 	adminListener, err := newListener(adminServer.Addr, nil)
 	if err != nil {
-		glog.Errorf("Error listening for TCP connections on %s: %v", adminServer.Addr, err)
+		logger.Errorf("Error listening for TCP connections on %s: %v", adminServer.Addr, err)
 		return
 	}
 	go runServer(mainServer, "Main", mainListener)
@@ -52,7 +52,7 @@ func Listen(cfg *config.Configuration, handler http.Handler, adminHandler http.H
 		go shutdownAfterSignals(prometheusServer, stopPrometheus, done)
 		prometheusListener, err := newListener(prometheusServer.Addr, nil)
 		if err != nil {
-			glog.Errorf("Error listening for TCP connections on %s: %v", adminServer.Addr, err)
+			logger.Errorf("Error listening for TCP connections on %s: %v", adminServer.Addr, err)
 			return
 		}
 		go runServer(prometheusServer, "Prometheus", prometheusListener)
@@ -81,9 +81,9 @@ func newMainServer(cfg *config.Configuration, handler http.Handler) *http.Server
 }
 
 func runServer(server *http.Server, name string, listener net.Listener) {
-	glog.Infof("%s server starting on: %s", name, server.Addr)
+	logger.Infof("%s server starting on: %s", name, server.Addr)
 	err := server.Serve(listener)
-	glog.Errorf("%s server quit with error: %v", name, err)
+	logger.Errorf("%s server quit with error: %v", name, err)
 }
 
 func newListener(address string, metrics pbsmetrics.MetricsEngine) (net.Listener, error) {
@@ -96,7 +96,7 @@ func newListener(address string, metrics pbsmetrics.MetricsEngine) (net.Listener
 	if casted, ok := ln.(*net.TCPListener); ok {
 		ln = &tcpKeepAliveListener{casted}
 	} else {
-		glog.Warning("net.Listen(\"tcp\", \"addr\") didn't return a TCPListener as it did in Go 1.9. Things will probably work fine... but this should be investigated.")
+		logger.Warning("net.Listen(\"tcp\", \"addr\") didn't return a TCPListener as it did in Go 1.9. Things will probably work fine... but this should be investigated.")
 	}
 
 	if metrics != nil {
@@ -125,9 +125,9 @@ func shutdownAfterSignals(server *http.Server, stopper <-chan os.Signal, done ch
 	defer cancel()
 
 	var s struct{}
-	glog.Infof("Stopping %s because of signal: %s", server.Addr, sig.String())
+	logger.Infof("Stopping %s because of signal: %s", server.Addr, sig.String())
 	if err := server.Shutdown(ctx); err != nil {
-		glog.Errorf("Failed to shutdown %s: %v", server.Addr, err)
+		logger.Errorf("Failed to shutdown %s: %v", server.Addr, err)
 	}
 	done <- s
 }
@@ -10,12 +10,18 @@ var pool *x509.CertPool
 
 func GetRootCAPool() *x509.CertPool {
 	if pool == nil {
-		pool = x509.NewCertPool()
-		pool.AppendCertsFromPEM(pemCerts)
+		pool = newRootCAPool()
 	}
 	return pool
 }
 
+// newRootCAPool returns a new pool with the built-in certificate authorities, which is safe to add more to.
+func newRootCAPool() *x509.CertPool {
+	newPool := x509.NewCertPool()
+	newPool.AppendCertsFromPEM(pemCerts)
+	return newPool
+}
+
 var pemCerts = []byte(`
 -----BEGIN CERTIFICATE-----
 MIIDzzCCAregAwIBAgIDAWweMA0GCSqGSIb3DQEBBQUAMIGNMQswCQYDVQQGEwJB
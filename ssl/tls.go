@@ -0,0 +1,86 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// NewTLSConfig returns the TLS config for the connections made to the bidders' servers.
+// The certificate authorities in cfg.RootCAFile are trusted on top of the ones in GetRootCAPool.
+func NewTLSConfig(cfg *config.AdapterTLS) (*tls.Config, error) {
+	rootCAs := GetRootCAPool()
+	if cfg.RootCAFile != "" {
+		pemCerts, err := ioutil.ReadFile(cfg.RootCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read adapter_tls.root_ca_file: %v", err)
+		}
+		// Build a separate pool, so that the extra CAs are only trusted for these connections.
+		rootCAs = newRootCAPool()
+		if !rootCAs.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("adapter_tls.root_ca_file %s doesn't contain any PEM certificates", cfg.RootCAFile)
+		}
+	}
+	return &tls.Config{
+		RootCAs:            rootCAs,
+		MinVersion:         tlsVersions[cfg.MinVersion],
+		InsecureSkipVerify: !cfg.VerifyCertificates,
+	}, nil
+}
+
+// WithClientCertificate returns a copy of the client which presents the certificate to the servers it connects to.
+// The client's Transport must be an *http.Transport.
+func WithClientCertificate(client *http.Client, cert config.ClientCertificate) (*http.Client, error) {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("client certificates need an *http.Transport. Got %T", client.Transport)
+	}
+	keyPair, err := tls.LoadX509KeyPair(cert.CertFile, cert.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	transport = CopyTransport(transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{keyPair}
+
+	withCert := *client
+	withCert.Transport = transport
+	return &withCert, nil
+}
+
+// CopyTransport returns a new Transport with the same settings, whose TLS config can be changed without affecting
+// the original. The copy doesn't share the original's idle connections.
+func CopyTransport(transport *http.Transport) *http.Transport {
+	copied := &http.Transport{
+		Proxy:                  transport.Proxy,
+		DialContext:            transport.DialContext,
+		Dial:                   transport.Dial,
+		DialTLS:                transport.DialTLS,
+		TLSHandshakeTimeout:    transport.TLSHandshakeTimeout,
+		DisableKeepAlives:      transport.DisableKeepAlives,
+		DisableCompression:     transport.DisableCompression,
+		MaxIdleConns:           transport.MaxIdleConns,
+		MaxIdleConnsPerHost:    transport.MaxIdleConnsPerHost,
+		IdleConnTimeout:        transport.IdleConnTimeout,
+		ResponseHeaderTimeout:  transport.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  transport.ExpectContinueTimeout,
+		TLSNextProto:           transport.TLSNextProto,
+		ProxyConnectHeader:     transport.ProxyConnectHeader,
+		MaxResponseHeaderBytes: transport.MaxResponseHeaderBytes,
+	}
+	if transport.TLSClientConfig != nil {
+		copied.TLSClientConfig = transport.TLSClientConfig.Clone()
+	}
+	return copied
+}
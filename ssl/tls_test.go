@@ -0,0 +1,83 @@
+package ssl
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestTLSConfigVersions(t *testing.T) {
+	tlsConfig, err := NewTLSConfig(&config.AdapterTLS{MinVersion: "1.2", VerifyCertificates: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Bad min version. Expected %d, got %d", tls.VersionTLS12, tlsConfig.MinVersion)
+	}
+	if tlsConfig.InsecureSkipVerify {
+		t.Error("Certificates should be verified unless verify_certificates is false.")
+	}
+}
+
+func TestTLSConfigRootCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "ssl")
+	if err != nil {
+		t.Fatalf("Failed to make a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write the CA file: %v", err)
+	}
+
+	tlsConfig, err := NewTLSConfig(&config.AdapterTLS{RootCAFile: caFile, VerifyCertificates: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("The certificates in root_ca_file should be trusted. Got %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := NewTLSConfig(&config.AdapterTLS{RootCAFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("A missing root_ca_file should be an error.")
+	}
+}
+
+func TestWithClientCertificate(t *testing.T) {
+	client := &http.Client{Transport: &http.Transport{}}
+	if _, err := WithClientCertificate(client, config.ClientCertificate{CertFile: "missing.crt", KeyFile: "missing.key"}); err == nil {
+		t.Error("Missing certificate files should be an error.")
+	}
+	if _, err := WithClientCertificate(&http.Client{}, config.ClientCertificate{}); err == nil {
+		t.Error("Clients without an *http.Transport should be an error.")
+	}
+}
+
+func TestCopyTransport(t *testing.T) {
+	original := &http.Transport{
+		MaxIdleConnsPerHost: 10,
+		TLSClientConfig:     &tls.Config{ServerName: "example.com"},
+	}
+	copied := CopyTransport(original)
+	if copied.MaxIdleConnsPerHost != 10 || copied.TLSClientConfig.ServerName != "example.com" {
+		t.Errorf("The copy should have the same settings. Got %#v", copied)
+	}
+	copied.TLSClientConfig.ServerName = "other.example.com"
+	if original.TLSClientConfig.ServerName != "example.com" {
+		t.Error("Changing the copy's TLS config shouldn't change the original's.")
+	}
+}
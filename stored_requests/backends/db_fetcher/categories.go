@@ -0,0 +1,59 @@
+package db_fetcher
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+// NewCategoryFetcher returns a CategoryFetcher which runs the query to fetch category mappings.
+//
+// The query is given the ad server as $1 and the publisher ID as $2. The publisher ID is an empty string
+// for the ad server's default mapping. It should return (iab_category, category) rows, like:
+//
+//   SELECT iab_category, category FROM categories WHERE ad_server = $1 AND publisher = $2
+func NewCategoryFetcher(db *sql.DB, query string) stored_requests.CategoryFetcher {
+	if db == nil {
+		logger.Fatalf("The Postgres Category Fetcher requires a database connection. Please report this as a bug.")
+	}
+	return &categoryFetcher{
+		db:    db,
+		query: query,
+	}
+}
+
+type categoryFetcher struct {
+	db    *sql.DB
+	query string
+}
+
+func (fetcher *categoryFetcher) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	rows, err := fetcher.db.QueryContext(ctx, fetcher.query, primaryAdServer, publisherID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			logger.Errorf("error closing DB connection: %v", err)
+		}
+	}()
+
+	categories := make(map[string]string)
+	for rows.Next() {
+		var iabCategory, category string
+		if err := rows.Scan(&iabCategory, &category); err != nil {
+			return nil, err
+		}
+		categories[iabCategory] = category
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	if len(categories) == 0 {
+		return nil, stored_requests.CategoryNotFound(primaryAdServer, publisherID)
+	}
+	return categories, nil
+}
@@ -0,0 +1,43 @@
+package db_fetcher
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+const categoryQuery = "SELECT iab, category FROM categories WHERE ad_server = $1 AND publisher = $2"
+
+func TestCategoryFetcher(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+	fetcher := NewCategoryFetcher(db, categoryQuery)
+
+	queryRegex := "^" + regexp.QuoteMeta(categoryQuery) + "$"
+	mock.ExpectQuery(queryRegex).WithArgs("freewheel", "pub").WillReturnRows(sqlmock.NewRows([]string{"iab", "category"}).
+		AddRow("IAB1-1", "395").
+		AddRow("IAB1-2", "396"))
+	mock.ExpectQuery(queryRegex).WithArgs("freewheel", "").WillReturnRows(sqlmock.NewRows([]string{"iab", "category"}))
+	mock.ExpectQuery(queryRegex).WithArgs("dfp", "").WillReturnError(errors.New("Connection lost"))
+
+	categories, err := fetcher.FetchCategories(context.Background(), "freewheel", "pub")
+	if err != nil || len(categories) != 2 || categories["IAB1-2"] != "396" {
+		t.Errorf("Wrong mapping. Got %v, %v", categories, err)
+	}
+	if _, err := fetcher.FetchCategories(context.Background(), "freewheel", ""); err == nil {
+		t.Errorf("Expected an error for a missing mapping.")
+	} else if _, ok := err.(stored_requests.NotFoundError); !ok {
+		t.Errorf("Empty results should return a NotFoundError. Got %v", err)
+	}
+	if _, err := fetcher.FetchCategories(context.Background(), "dfp", ""); err == nil {
+		t.Errorf("Expected an error when the query fails.")
+	}
+	assertMockExpectations(t, mock)
+}
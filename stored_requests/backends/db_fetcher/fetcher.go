@@ -7,16 +7,16 @@ import (
 
 	"github.com/lib/pq"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/stored_requests"
 )
 
 func NewFetcher(db *sql.DB, queryMaker func(int, int) string) stored_requests.Fetcher {
 	if db == nil {
-		glog.Fatalf("The Postgres Stored Request Fetcher requires a database connection. Please report this as a bug.")
+		logger.Fatalf("The Postgres Stored Request Fetcher requires a database connection. Please report this as a bug.")
 	}
 	if queryMaker == nil {
-		glog.Fatalf("The Postgres Stored Request Fetcher requires a queryMaker function. Please report this as a bug.")
+		logger.Fatalf("The Postgres Stored Request Fetcher requires a queryMaker function. Please report this as a bug.")
 	}
 	return &dbFetcher{
 		db:         db,
@@ -47,7 +47,7 @@ func (fetcher *dbFetcher) FetchRequests(ctx context.Context, requestIDs []string
 	rows, err := fetcher.db.QueryContext(ctx, query, idInterfaces...)
 	if err != nil {
 		if err != context.DeadlineExceeded && !isBadInput(err) {
-			glog.Errorf("Error reading from Stored Request DB: %s", err.Error())
+			logger.Errorf("Error reading from Stored Request DB: %s", err.Error())
 			errs := appendErrors("Request", requestIDs, nil, nil)
 			errs = appendErrors("Imp", impIDs, nil, errs)
 			return nil, nil, errs
@@ -56,7 +56,7 @@ func (fetcher *dbFetcher) FetchRequests(ctx context.Context, requestIDs []string
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			glog.Errorf("error closing DB connection: %v", err)
+			logger.Errorf("error closing DB connection: %v", err)
 		}
 	}()
 
@@ -78,7 +78,7 @@ func (fetcher *dbFetcher) FetchRequests(ctx context.Context, requestIDs []string
 		case "imp":
 			storedImpData[id] = data
 		default:
-			glog.Errorf("Postgres result set with id=%s has invalid type: %s. This will be ignored.", id, dataType)
+			logger.Errorf("Postgres result set with id=%s has invalid type: %s. This will be ignored.", id, dataType)
 		}
 	}
 
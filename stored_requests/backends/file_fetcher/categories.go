@@ -0,0 +1,44 @@
+package file_fetcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+// NewCategoryFetcher returns a CategoryFetcher which reads category mapping files from the directory.
+//
+// Unlike the Stored Requests, the files are read on every fetch, so that a mapping can be updated by
+// replacing its file. Wrap this with stored_requests.WithCategoryCache to keep them in memory.
+//
+// Each ad server's default mapping should be named "{ad_server}.json", and a publisher's own mapping
+// "{ad_server}_{publisher_id}.json". For example, "directory/freewheel.json" or "directory/freewheel_123.json".
+func NewCategoryFetcher(directory string) stored_requests.CategoryFetcher {
+	return &categoryFetcher{directory}
+}
+
+type categoryFetcher struct {
+	directory string
+}
+
+func (fetcher *categoryFetcher) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	fileName := primaryAdServer
+	if publisherID != "" {
+		fileName = primaryAdServer + "_" + publisherID
+	}
+	data, err := ioutil.ReadFile(fmt.Sprintf("%s/%s.json", fetcher.directory, fileName))
+	if os.IsNotExist(err) {
+		return nil, stored_requests.CategoryNotFound(primaryAdServer, publisherID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	categories, err := stored_requests.ParseCategories(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the category mapping file %s.json: %v", fileName, err)
+	}
+	return categories, nil
+}
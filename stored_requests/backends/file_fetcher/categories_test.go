@@ -0,0 +1,40 @@
+package file_fetcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+func TestCategoryFetcher(t *testing.T) {
+	fetcher := NewCategoryFetcher("./test/categories")
+
+	categories, err := fetcher.FetchCategories(context.Background(), "freewheel", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(categories) != 2 || categories["IAB1-1"] != "395" || categories["IAB1-2"] != "396" {
+		t.Errorf("Wrong default mapping: %v", categories)
+	}
+
+	categories, err = fetcher.FetchCategories(context.Background(), "freewheel", "pub")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(categories) != 1 || categories["IAB1-1"] != "pub-entertainment" {
+		t.Errorf("Wrong publisher mapping: %v", categories)
+	}
+
+	if _, err := fetcher.FetchCategories(context.Background(), "freewheel", "other"); err == nil {
+		t.Errorf("Expected an error for a missing mapping.")
+	} else if _, ok := err.(stored_requests.NotFoundError); !ok {
+		t.Errorf("Missing mappings should return a NotFoundError. Got %v", err)
+	}
+
+	if _, err := fetcher.FetchCategories(context.Background(), "dfp", ""); err == nil {
+		t.Errorf("Expected an error for a malformed mapping.")
+	} else if _, ok := err.(stored_requests.NotFoundError); ok {
+		t.Errorf("Malformed mappings shouldn't return a NotFoundError.")
+	}
+}
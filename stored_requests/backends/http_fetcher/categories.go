@@ -0,0 +1,69 @@
+package http_fetcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prebid/prebid-server/stored_requests"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// NewCategoryFetcher returns a CategoryFetcher which uses the Client to pull category mappings from the endpoint.
+//
+// This file expects the endpoint to satisfy the following API:
+//
+// GET {endpoint}?adserver=freewheel&publisher=123
+//
+// The publisher param is left off for the ad server's default mapping. The endpoint should return
+// the mapping in the same format as the category mapping files, or a 404 if it doesn't exist.
+func NewCategoryFetcher(client *http.Client, endpoint string) stored_requests.CategoryFetcher {
+	urlPrefix := endpoint
+	if strings.Contains(endpoint, "?") {
+		urlPrefix = urlPrefix + "&"
+	} else {
+		urlPrefix = urlPrefix + "?"
+	}
+	return &categoryFetcher{
+		client:   client,
+		endpoint: urlPrefix,
+	}
+}
+
+type categoryFetcher struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (fetcher *categoryFetcher) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	query := url.Values{}
+	query.Set("adserver", primaryAdServer)
+	if publisherID != "" {
+		query.Set("publisher", publisherID)
+	}
+	httpReq, err := http.NewRequest("GET", fetcher.endpoint+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := ctxhttp.Do(ctx, fetcher.client, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch httpResp.StatusCode {
+	case http.StatusOK:
+		return stored_requests.ParseCategories(respBytes)
+	case http.StatusNotFound:
+		return nil, stored_requests.CategoryNotFound(primaryAdServer, publisherID)
+	}
+	return nil, fmt.Errorf("Error fetching the category mapping via HTTP. Response code was %d", httpResp.StatusCode)
+}
@@ -0,0 +1,50 @@
+package http_fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+func TestCategoryFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("type") != "categories" {
+			t.Errorf("The endpoint's own query params should be kept. Got %s", r.URL.RawQuery)
+		}
+		switch query.Get("adserver") + "_" + query.Get("publisher") {
+		case "freewheel_":
+			w.Write([]byte(`{"IAB1-1":{"id":"395","name":"Entertainment"}}`))
+		case "freewheel_pub":
+			w.Write([]byte(`{"IAB1-1":{"id":"pub-entertainment"}}`))
+		case "dfp_":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	fetcher := NewCategoryFetcher(server.Client(), server.URL+"?type=categories")
+
+	categories, err := fetcher.FetchCategories(context.Background(), "freewheel", "")
+	if err != nil || categories["IAB1-1"] != "395" {
+		t.Errorf("Wrong default mapping. Got %v, %v", categories, err)
+	}
+	categories, err = fetcher.FetchCategories(context.Background(), "freewheel", "pub")
+	if err != nil || categories["IAB1-1"] != "pub-entertainment" {
+		t.Errorf("Wrong publisher mapping. Got %v, %v", categories, err)
+	}
+	if _, err := fetcher.FetchCategories(context.Background(), "freewheel", "other"); err == nil {
+		t.Errorf("Expected an error for a missing mapping.")
+	} else if _, ok := err.(stored_requests.NotFoundError); !ok {
+		t.Errorf("A 404 should return a NotFoundError. Got %v", err)
+	}
+	if _, err := fetcher.FetchCategories(context.Background(), "dfp", ""); err == nil {
+		t.Errorf("Expected an error for a failed request.")
+	} else if _, ok := err.(stored_requests.NotFoundError); ok {
+		t.Errorf("Failed requests shouldn't return a NotFoundError.")
+	}
+}
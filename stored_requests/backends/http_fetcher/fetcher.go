@@ -11,7 +11,7 @@ import (
 
 	"github.com/prebid/prebid-server/stored_requests"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 	"golang.org/x/net/context/ctxhttp"
 )
 
@@ -47,7 +47,7 @@ func NewFetcher(client *http.Client, endpoint string) *HttpFetcher {
 		urlPrefix = urlPrefix + "?"
 	}
 
-	glog.Info("Making http_fetcher which calls GET " + urlPrefix + "request-ids=%REQUEST_ID_LIST%&imp-ids=%IMP_ID_LIST%")
+	logger.Info("Making http_fetcher which calls GET " + urlPrefix + "request-ids=%REQUEST_ID_LIST%&imp-ids=%IMP_ID_LIST%")
 
 	return &HttpFetcher{
 		client:   client,
@@ -6,8 +6,8 @@ import (
 	"sync"
 
 	"github.com/coocood/freecache"
-	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/stored_requests"
 )
 
@@ -26,16 +26,16 @@ func NewCache(cfg *config.InMemoryCache) stored_requests.Cache {
 
 func newCacheForWithLimits(size int, ttl int, dataType string) mapLike {
 	if ttl > 0 && size <= 0 {
-		glog.Fatal("No in-memory caches defined with a finite TTL but unbounded size. Config validation should have caught this. Failing fast because something is buggy.")
+		logger.Fatal("No in-memory caches defined with a finite TTL but unbounded size. Config validation should have caught this. Failing fast because something is buggy.")
 	}
 	if size > 0 {
-		glog.Infof("Using a Stored %s in-memory cache. Max size: %d bytes. TTL: %d seconds.", dataType, size, ttl)
+		logger.Infof("Using a Stored %s in-memory cache. Max size: %d bytes. TTL: %d seconds.", dataType, size, ttl)
 		return &pbsLRUCache{
 			Cache:      freecache.NewCache(size),
 			ttlSeconds: ttl,
 		}
 	} else {
-		glog.Infof("Using an unbounded Stored %s in-memory cache.", dataType)
+		logger.Infof("Using an unbounded Stored %s in-memory cache.", dataType)
 		return &pbsSyncMap{&sync.Map{}}
 	}
 }
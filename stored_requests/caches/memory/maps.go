@@ -5,7 +5,7 @@ import (
 	"sync"
 
 	"github.com/coocood/freecache"
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 )
 
 // This file contains an interface and some wrapper types for various types of "map-like" structures
@@ -52,14 +52,14 @@ func (m *pbsLRUCache) Get(id string) (json.RawMessage, bool) {
 		return val, true
 	}
 	if err != freecache.ErrNotFound {
-		glog.Errorf("unexpected error from freecache: %v", err)
+		logger.Errorf("unexpected error from freecache: %v", err)
 	}
 	return val, false
 }
 
 func (m *pbsLRUCache) Set(id string, value json.RawMessage) {
 	if err := m.Cache.Set([]byte(id), value, m.ttlSeconds); err != nil {
-		glog.Errorf("error saving value in freecache: %v", err)
+		logger.Errorf("error saving value in freecache: %v", err)
 	}
 }
 
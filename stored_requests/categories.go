@@ -0,0 +1,134 @@
+package stored_requests
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/logger"
+)
+
+// CategoryFetcher knows how to fetch the files which map IAB categories onto an ad server's own categories.
+//
+// Implementations must be safe for concurrent access by multiple goroutines.
+type CategoryFetcher interface {
+	// FetchCategories fetches the category mapping for the ad server, keyed by IAB category.
+	//
+	// If publisherID is an empty string, the ad server's default mapping is returned. Otherwise, only the
+	// publisher's own mapping is. A NotFoundError is returned if the mapping doesn't exist.
+	//
+	// The returned map can only be read from. It may not be written to.
+	FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error)
+}
+
+// CategoryNotFound returns the error which CategoryFetchers should use when a mapping doesn't exist.
+func CategoryNotFound(primaryAdServer string, publisherID string) error {
+	return NotFoundError{
+		ID:       categoryKey(primaryAdServer, publisherID),
+		DataType: "Category",
+	}
+}
+
+// ParseCategories reads a category mapping file. These look like:
+//
+// {
+//   "IAB1-1": { "id": "395", "name": "Entertainment" },
+//   "IAB1-2": { "id": "396", "name": "Literature" }
+// }
+//
+// Only the IDs are kept, since they're what the ad server targets.
+func ParseCategories(data []byte) (map[string]string, error) {
+	var file map[string]struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	categories := make(map[string]string, len(file))
+	for iabCategory, category := range file {
+		categories[iabCategory] = category.ID
+	}
+	return categories, nil
+}
+
+func categoryKey(primaryAdServer string, publisherID string) string {
+	if publisherID == "" {
+		return primaryAdServer
+	}
+	return primaryAdServer + "_" + publisherID
+}
+
+// MultiCategoryFetcher is a CategoryFetcher which asks each of its CategoryFetchers in order,
+// and returns the first mapping found.
+type MultiCategoryFetcher []CategoryFetcher
+
+// FetchCategories implements the CategoryFetcher interface for MultiCategoryFetcher
+func (mf MultiCategoryFetcher) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	for _, f := range mf {
+		categories, err := f.FetchCategories(ctx, primaryAdServer, publisherID)
+		if _, notFound := err.(NotFoundError); !notFound {
+			return categories, err
+		}
+	}
+	return nil, CategoryNotFound(primaryAdServer, publisherID)
+}
+
+// WithCategoryCache returns a CategoryFetcher which keeps each mapping in memory, and fetches it again
+// once it's older than the refreshRate. Mappings which don't exist are remembered too, so that the
+// backend isn't asked for them on every auction.
+//
+// If a refresh fails, the old mapping is kept until the next refresh.
+func WithCategoryCache(fetcher CategoryFetcher, refreshRate time.Duration) CategoryFetcher {
+	return &categoryCache{
+		fetcher:     fetcher,
+		refreshRate: refreshRate,
+		now:         time.Now,
+		entries:     make(map[string]*categoryEntry),
+	}
+}
+
+type categoryCache struct {
+	fetcher     CategoryFetcher
+	refreshRate time.Duration
+	now         func() time.Time
+
+	lock    sync.RWMutex
+	entries map[string]*categoryEntry
+}
+
+type categoryEntry struct {
+	categories map[string]string
+	err        error
+	fetched    time.Time
+}
+
+func (c *categoryCache) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	key := categoryKey(primaryAdServer, publisherID)
+	c.lock.RLock()
+	entry, ok := c.entries[key]
+	c.lock.RUnlock()
+	if ok && c.now().Sub(entry.fetched) < c.refreshRate {
+		return entry.categories, entry.err
+	}
+
+	categories, err := c.fetcher.FetchCategories(ctx, primaryAdServer, publisherID)
+	if _, notFound := err.(NotFoundError); err != nil && !notFound {
+		if ok {
+			logger.Errorf("Failed to refresh the %s category mapping. The old one will be used until the next refresh: %v", key, err)
+			categories, err = entry.categories, entry.err
+		} else {
+			// Don't remember errors which the next auction might not get.
+			return nil, err
+		}
+	}
+
+	c.lock.Lock()
+	c.entries[key] = &categoryEntry{
+		categories: categories,
+		err:        err,
+		fetched:    c.now(),
+	}
+	c.lock.Unlock()
+	return categories, err
+}
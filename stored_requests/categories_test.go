@@ -0,0 +1,117 @@
+package stored_requests
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCategories(t *testing.T) {
+	categories, err := ParseCategories([]byte(`{"IAB1-1":{"id":"395","name":"Entertainment"},"IAB1-2":{"id":"396"}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{"IAB1-1": "395", "IAB1-2": "396"}
+	if !reflect.DeepEqual(categories, expected) {
+		t.Errorf("Expected %v. Got %v", expected, categories)
+	}
+	if _, err := ParseCategories([]byte(`["IAB1-1"]`)); err == nil {
+		t.Errorf("Malformed mappings should return an error.")
+	}
+}
+
+func TestMultiCategoryFetcher(t *testing.T) {
+	fetcher := MultiCategoryFetcher{
+		&mockCategoryFetcher{mappings: map[string]map[string]string{"freewheel": {"IAB1-1": "395"}}},
+		&mockCategoryFetcher{mappings: map[string]map[string]string{"freewheel": {"IAB1-1": "other"}, "dfp_pub": {"IAB1-1": "entertainment"}}},
+	}
+	categories, err := fetcher.FetchCategories(context.Background(), "freewheel", "")
+	if err != nil || categories["IAB1-1"] != "395" {
+		t.Errorf("The first fetcher with the mapping should win. Got %v, %v", categories, err)
+	}
+	categories, err = fetcher.FetchCategories(context.Background(), "dfp", "pub")
+	if err != nil || categories["IAB1-1"] != "entertainment" {
+		t.Errorf("The later fetchers should be asked if the earlier ones don't have the mapping. Got %v, %v", categories, err)
+	}
+	if _, err := fetcher.FetchCategories(context.Background(), "dfp", ""); !isNotFound(err) {
+		t.Errorf("Expected a NotFoundError. Got %v", err)
+	}
+
+	fetcher[0] = &mockCategoryFetcher{err: errors.New("Backend failure")}
+	if _, err := fetcher.FetchCategories(context.Background(), "dfp", "pub"); err == nil || isNotFound(err) {
+		t.Errorf("Other errors should be returned. Got %v", err)
+	}
+}
+
+func TestCategoryCache(t *testing.T) {
+	backend := &mockCategoryFetcher{mappings: map[string]map[string]string{"freewheel": {"IAB1-1": "395"}}}
+	cache := WithCategoryCache(backend, time.Minute).(*categoryCache)
+	now := time.Now()
+	cache.now = func() time.Time {
+		return now
+	}
+
+	assertCategory(t, cache, "freewheel", "395")
+	backend.mappings["freewheel"] = map[string]string{"IAB1-1": "400"}
+	now = now.Add(59 * time.Second)
+	assertCategory(t, cache, "freewheel", "395")
+	if backend.calls != 1 {
+		t.Errorf("Mappings shouldn't be fetched again before the refresh rate. Got %d calls", backend.calls)
+	}
+
+	now = now.Add(time.Second)
+	assertCategory(t, cache, "freewheel", "400")
+
+	backend.err = errors.New("Backend failure")
+	now = now.Add(time.Minute)
+	assertCategory(t, cache, "freewheel", "400")
+
+	if _, err := cache.FetchCategories(context.Background(), "dfp", ""); err == nil || isNotFound(err) {
+		t.Errorf("Errors should be returned if there's no old mapping. Got %v", err)
+	}
+	backend.err = nil
+	calls := backend.calls
+	for i := 0; i < 2; i++ {
+		if _, err := cache.FetchCategories(context.Background(), "dfp", ""); !isNotFound(err) {
+			t.Errorf("Expected a NotFoundError. Got %v", err)
+		}
+	}
+	if backend.calls != calls+1 {
+		t.Errorf("Missing mappings should be cached. Got %d calls", backend.calls-calls)
+	}
+}
+
+func assertCategory(t *testing.T, fetcher CategoryFetcher, adServer string, expected string) {
+	t.Helper()
+	categories, err := fetcher.FetchCategories(context.Background(), adServer, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if categories["IAB1-1"] != expected {
+		t.Errorf("Expected IAB1-1 to map to %s. Got %s", expected, categories["IAB1-1"])
+	}
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(NotFoundError)
+	return ok
+}
+
+type mockCategoryFetcher struct {
+	mappings map[string]map[string]string
+	err      error
+	calls    int
+}
+
+func (f *mockCategoryFetcher) FetchCategories(ctx context.Context, primaryAdServer string, publisherID string) (map[string]string, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if categories, ok := f.mappings[categoryKey(primaryAdServer, publisherID)]; ok {
+		return categories, nil
+	}
+	return nil, CategoryNotFound(primaryAdServer, publisherID)
+}
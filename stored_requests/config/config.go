@@ -6,9 +6,10 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/stored_requests/backends/db_fetcher"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
@@ -29,23 +30,25 @@ import (
 // 3. A DB connection, if one was created. This may be nil.
 // 4. A function which should be called on shutdown for graceful cleanups.
 //
+// Fetch times, errors and cache hit rates are reported to the metricsEngine.
+//
 // If any errors occur, the program will exit with an error message.
 // It probably means you have a bad config or networking issue.
 //
 // As a side-effect, it will add some endpoints to the router if the config calls for it.
 // In the future we should look for ways to simplify this so that it's not doing two things.
-func NewStoredRequests(cfg *config.StoredRequests, client *http.Client, router *httprouter.Router) (fetcher stored_requests.Fetcher, ampFetcher stored_requests.Fetcher, db *sql.DB, shutdown func()) {
+func NewStoredRequests(cfg *config.StoredRequests, client *http.Client, router *httprouter.Router, metricsEngine pbsmetrics.MetricsEngine) (fetcher stored_requests.Fetcher, ampFetcher stored_requests.Fetcher, db *sql.DB, shutdown func()) {
 	if cfg.Postgres.ConnectionInfo.Database != "" {
-		glog.Infof("Connecting to Postgres for Stored Requests. DB=%s, host=%s, port=%d, user=%s", cfg.Postgres.ConnectionInfo.Database, cfg.Postgres.ConnectionInfo.Host, cfg.Postgres.ConnectionInfo.Port, cfg.Postgres.ConnectionInfo.Username)
+		logger.Infof("Connecting to Postgres for Stored Requests. DB=%s, host=%s, port=%d, user=%s", cfg.Postgres.ConnectionInfo.Database, cfg.Postgres.ConnectionInfo.Host, cfg.Postgres.ConnectionInfo.Port, cfg.Postgres.ConnectionInfo.Username)
 		db = newPostgresDB(cfg.Postgres.ConnectionInfo)
 	}
 	eventProducers, ampEventProducers := newEventProducers(cfg, client, db, router)
 	cache := newCache(cfg)
 	ampCache := newCache(cfg)
-	fetcher, ampFetcher = newFetchers(cfg, client, db)
+	fetcher, ampFetcher = newFetchers(cfg, client, db, metricsEngine)
 
-	fetcher = stored_requests.WithCache(fetcher, cache)
-	ampFetcher = stored_requests.WithCache(ampFetcher, ampCache)
+	fetcher = stored_requests.WithCache(fetcher, stored_requests.WithCacheMetrics(cache, metricsEngine, pbsmetrics.StoredDataTypeRequest))
+	ampFetcher = stored_requests.WithCache(ampFetcher, stored_requests.WithCacheMetrics(ampCache, metricsEngine, pbsmetrics.StoredDataTypeAMP))
 
 	shutdown1 := addListeners(cache, eventProducers)
 	shutdown2 := addListeners(ampCache, ampEventProducers)
@@ -54,13 +57,42 @@ func NewStoredRequests(cfg *config.StoredRequests, client *http.Client, router *
 		shutdown2()
 		if db != nil {
 			if err := db.Close(); err != nil {
-				glog.Errorf("Error closing DB connection: %v", err)
+				logger.Errorf("Error closing DB connection: %v", err)
 			}
 		}
 	}
 	return
 }
 
+// NewCategoryFetcher returns a CategoryFetcher for the category mappings in each configured backend, or nil if
+// there aren't any. The db should be the connection returned by NewStoredRequests.
+//
+// Mappings are kept in memory, and fetched again every cfg.CategoryRefreshRate seconds.
+func NewCategoryFetcher(cfg *config.StoredRequests, client *http.Client, db *sql.DB) stored_requests.CategoryFetcher {
+	fetchers := make(stored_requests.MultiCategoryFetcher, 0, 3)
+	if cfg.Files {
+		logger.Infof("Loading category mappings from filesystem at path %s", categoryConfigPath)
+		fetchers = append(fetchers, file_fetcher.NewCategoryFetcher(categoryConfigPath))
+	}
+	if cfg.Postgres.FetcherQueries.CategoryQuery != "" {
+		logger.Infof("Loading category mappings via Postgres.\nQuery: %s", cfg.Postgres.FetcherQueries.CategoryQuery)
+		fetchers = append(fetchers, db_fetcher.NewCategoryFetcher(db, cfg.Postgres.FetcherQueries.CategoryQuery))
+	}
+	if cfg.HTTP.CategoryEndpoint != "" {
+		logger.Infof("Loading category mappings via HTTP. endpoint=%s", cfg.HTTP.CategoryEndpoint)
+		fetchers = append(fetchers, http_fetcher.NewCategoryFetcher(client, cfg.HTTP.CategoryEndpoint))
+	}
+
+	if len(fetchers) == 0 {
+		return nil
+	}
+	refreshRate := time.Duration(cfg.CategoryRefreshRate) * time.Second
+	if len(fetchers) == 1 {
+		return stored_requests.WithCategoryCache(fetchers[0], refreshRate)
+	}
+	return stored_requests.WithCategoryCache(fetchers, refreshRate)
+}
+
 func addListeners(cache stored_requests.Cache, eventProducers []events.EventProducer) (shutdown func()) {
 	listeners := make([]*events.EventListener, 0, len(eventProducers))
 
@@ -77,24 +109,26 @@ func addListeners(cache stored_requests.Cache, eventProducers []events.EventProd
 	}
 }
 
-func newFetchers(cfg *config.StoredRequests, client *http.Client, db *sql.DB) (fetcher stored_requests.Fetcher, ampFetcher stored_requests.Fetcher) {
+// newFetchers builds the Fetchers for each configured backend. If the metricsEngine is nil, the backends
+// will not be instrumented.
+func newFetchers(cfg *config.StoredRequests, client *http.Client, db *sql.DB, metricsEngine pbsmetrics.MetricsEngine) (fetcher stored_requests.Fetcher, ampFetcher stored_requests.Fetcher) {
 	idList := make(stored_requests.MultiFetcher, 0, 3)
 	ampIDList := make(stored_requests.MultiFetcher, 0, 3)
 
 	if cfg.Files {
 		fFetcher := newFilesystem()
-		idList = append(idList, fFetcher)
-		ampIDList = append(ampIDList, fFetcher)
+		idList = append(idList, instrument(fFetcher, metricsEngine, pbsmetrics.StoredDataTypeRequest, pbsmetrics.StoredDataSourceFiles))
+		ampIDList = append(ampIDList, instrument(fFetcher, metricsEngine, pbsmetrics.StoredDataTypeAMP, pbsmetrics.StoredDataSourceFiles))
 	}
 	if cfg.Postgres.FetcherQueries.QueryTemplate != "" {
-		glog.Infof("Loading Stored Requests via Postgres.\nQuery: %s\nAMP Query: %s", cfg.Postgres.FetcherQueries.QueryTemplate, cfg.Postgres.FetcherQueries.AmpQueryTemplate)
-		idList = append(idList, db_fetcher.NewFetcher(db, cfg.Postgres.FetcherQueries.MakeQuery))
-		ampIDList = append(ampIDList, db_fetcher.NewFetcher(db, cfg.Postgres.FetcherQueries.MakeAmpQuery))
+		logger.Infof("Loading Stored Requests via Postgres.\nQuery: %s\nAMP Query: %s", cfg.Postgres.FetcherQueries.QueryTemplate, cfg.Postgres.FetcherQueries.AmpQueryTemplate)
+		idList = append(idList, instrument(db_fetcher.NewFetcher(db, cfg.Postgres.FetcherQueries.MakeQuery), metricsEngine, pbsmetrics.StoredDataTypeRequest, pbsmetrics.StoredDataSourcePostgres))
+		ampIDList = append(ampIDList, instrument(db_fetcher.NewFetcher(db, cfg.Postgres.FetcherQueries.MakeAmpQuery), metricsEngine, pbsmetrics.StoredDataTypeAMP, pbsmetrics.StoredDataSourcePostgres))
 	}
 	if cfg.HTTP.Endpoint != "" {
-		glog.Infof("Loading Stored Requests via HTTP. endpoint=%s, amp_endpoint=%s", cfg.HTTP.Endpoint, cfg.HTTP.AmpEndpoint)
-		idList = append(idList, http_fetcher.NewFetcher(client, cfg.HTTP.Endpoint))
-		ampIDList = append(ampIDList, http_fetcher.NewFetcher(client, cfg.HTTP.AmpEndpoint))
+		logger.Infof("Loading Stored Requests via HTTP. endpoint=%s, amp_endpoint=%s", cfg.HTTP.Endpoint, cfg.HTTP.AmpEndpoint)
+		idList = append(idList, instrument(http_fetcher.NewFetcher(client, cfg.HTTP.Endpoint), metricsEngine, pbsmetrics.StoredDataTypeRequest, pbsmetrics.StoredDataSourceHTTP))
+		ampIDList = append(ampIDList, instrument(http_fetcher.NewFetcher(client, cfg.HTTP.AmpEndpoint), metricsEngine, pbsmetrics.StoredDataTypeAMP, pbsmetrics.StoredDataSourceHTTP))
 	}
 
 	fetcher = consolidate(idList)
@@ -102,9 +136,17 @@ func newFetchers(cfg *config.StoredRequests, client *http.Client, db *sql.DB) (f
 	return
 }
 
+// instrument wraps the fetcher so that its calls are recorded in the metrics and traces.
+func instrument(fetcher stored_requests.Fetcher, metricsEngine pbsmetrics.MetricsEngine, dataType pbsmetrics.StoredDataType, source pbsmetrics.StoredDataSource) stored_requests.Fetcher {
+	if metricsEngine == nil {
+		return fetcher
+	}
+	return stored_requests.WithTracing(stored_requests.WithMetrics(fetcher, metricsEngine, dataType, source), dataType, source)
+}
+
 func newCache(cfg *config.StoredRequests) stored_requests.Cache {
 	if cfg.InMemoryCache.Type == "none" {
-		glog.Info("No Stored Request cache configured. The Fetcher backend will be used for all Stored Requests.")
+		logger.Info("No Stored Request cache configured. The Fetcher backend will be used for all Stored Requests.")
 		return &nil_cache.NilCache{}
 	}
 
@@ -167,10 +209,10 @@ func newHttpEvents(client *http.Client, timeout time.Duration, refreshRate time.
 }
 
 func newFilesystem() stored_requests.Fetcher {
-	glog.Infof("Loading Stored Requests from filesystem at path %s", requestConfigPath)
+	logger.Infof("Loading Stored Requests from filesystem at path %s", requestConfigPath)
 	fetcher, err := file_fetcher.NewFileFetcher(requestConfigPath)
 	if err != nil {
-		glog.Fatalf("Failed to create a FileFetcher: %v", err)
+		logger.Fatalf("Failed to create a FileFetcher: %v", err)
 	}
 	return fetcher
 }
@@ -178,11 +220,11 @@ func newFilesystem() stored_requests.Fetcher {
 func newPostgresDB(cfg config.PostgresConnection) *sql.DB {
 	db, err := sql.Open("postgres", cfg.ConnString())
 	if err != nil {
-		glog.Fatalf("Failed to open postgres connection: %v", err)
+		logger.Fatalf("Failed to open postgres connection: %v", err)
 	}
 
 	if err := db.Ping(); err != nil {
-		glog.Fatalf("Failed to ping postgres: %v", err)
+		logger.Fatalf("Failed to ping postgres: %v", err)
 	}
 
 	return db
@@ -191,7 +233,7 @@ func newPostgresDB(cfg config.PostgresConnection) *sql.DB {
 // consolidate returns a single Fetcher from an array of fetchers of any size.
 func consolidate(fetchers []stored_requests.Fetcher) stored_requests.Fetcher {
 	if len(fetchers) == 0 {
-		glog.Warning("No Stored Request support configured. request.imp[i].ext.prebid.storedrequest will be ignored. If you need this, check your app config")
+		logger.Warning("No Stored Request support configured. request.imp[i].ext.prebid.storedrequest will be ignored. If you need this, check your app config")
 		return empty_fetcher.EmptyFetcher{}
 	} else if len(fetchers) == 1 {
 		return fetchers[0]
@@ -201,3 +243,4 @@ func consolidate(fetchers []stored_requests.Fetcher) stored_requests.Fetcher {
 }
 
 const requestConfigPath = "./stored_requests/data/by_id"
+const categoryConfigPath = "./stored_requests/data/categories"
@@ -19,7 +19,7 @@ import (
 )
 
 func TestNewEmptyFetcher(t *testing.T) {
-	fetcher, ampFetcher := newFetchers(&config.StoredRequests{}, nil, nil)
+	fetcher, ampFetcher := newFetchers(&config.StoredRequests{}, nil, nil, nil)
 	if fetcher == nil || ampFetcher == nil {
 		t.Errorf("The fetchers should be non-nil, even with an empty config.")
 	}
@@ -37,7 +37,7 @@ func TestNewHTTPFetcher(t *testing.T) {
 			Endpoint:    "stored-requests.prebid.com",
 			AmpEndpoint: "stored-requests.prebid.com?type=amp",
 		},
-	}, nil, nil)
+	}, nil, nil, nil)
 	if httpFetcher, ok := fetcher.(*http_fetcher.HttpFetcher); ok {
 		if httpFetcher.Endpoint != "stored-requests.prebid.com?" {
 			t.Errorf("The HTTP fetcher is using the wrong endpoint. Expected %s, got %s", "stored-requests.prebid.com?", httpFetcher.Endpoint)
@@ -54,6 +54,15 @@ func TestNewHTTPFetcher(t *testing.T) {
 	}
 }
 
+func TestNewCategoryFetcher(t *testing.T) {
+	if fetcher := NewCategoryFetcher(&config.StoredRequests{}, nil, nil); fetcher != nil {
+		t.Errorf("There shouldn't be a CategoryFetcher without any backends. Got %v", fetcher)
+	}
+	if fetcher := NewCategoryFetcher(&config.StoredRequests{HTTP: config.HTTPFetcherConfig{CategoryEndpoint: "stored-requests.prebid.com"}}, nil, nil); fetcher == nil {
+		t.Errorf("An HTTP category endpoint should return a CategoryFetcher.")
+	}
+}
+
 func TestNewHTTPEvents(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
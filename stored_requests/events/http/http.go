@@ -13,7 +13,7 @@ import (
 	"github.com/buger/jsonparser"
 	"github.com/prebid/prebid-server/stored_requests/events"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 )
 
 // NewHTTPEvents makes an EventProducer which creates events by pinging an external HTTP API
@@ -60,7 +60,7 @@ func NewHTTPEvents(client *httpCore.Client, endpoint string, ctxProducer func()
 		saves:         make(chan events.Save, 1),
 		invalidations: make(chan events.Invalidation, 1),
 	}
-	glog.Infof("Loading HTTP cache from GET %s", endpoint)
+	logger.Infof("Loading HTTP cache from GET %s", endpoint)
 	e.fetchAll()
 
 	go e.refresh(time.Tick(refreshRate))
@@ -123,25 +123,25 @@ func (e *HTTPEvents) refresh(ticker <-chan time.Time) {
 // It returns true if everything was successful, and false if any errors occurred.
 func (e *HTTPEvents) parse(endpoint string, resp *httpCore.Response, err error) (*responseContract, bool) {
 	if err != nil {
-		glog.Errorf("Failed call: GET %s for Stored Requests: %v", endpoint, err)
+		logger.Errorf("Failed call: GET %s for Stored Requests: %v", endpoint, err)
 		return nil, false
 	}
 	defer resp.Body.Close()
 
 	respBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		glog.Errorf("Failed to read body of GET %s for Stored Requests: %v", endpoint, err)
+		logger.Errorf("Failed to read body of GET %s for Stored Requests: %v", endpoint, err)
 		return nil, false
 	}
 
 	if resp.StatusCode != httpCore.StatusOK {
-		glog.Errorf("Got %d response from GET %s for Stored Requests. Response body was: %s", resp.StatusCode, endpoint, string(respBytes))
+		logger.Errorf("Got %d response from GET %s for Stored Requests. Response body was: %s", resp.StatusCode, endpoint, string(respBytes))
 		return nil, false
 	}
 
 	var respObj responseContract
 	if err := json.Unmarshal(respBytes, &respObj); err != nil {
-		glog.Errorf("Failed to unmarshal body of GET %s for Stored Requests: %v", endpoint, err)
+		logger.Errorf("Failed to unmarshal body of GET %s for Stored Requests: %v", endpoint, err)
 		return nil, false
 	}
 
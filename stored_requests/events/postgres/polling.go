@@ -7,7 +7,7 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/stored_requests/events"
 )
 
@@ -32,7 +32,7 @@ func PollForUpdates(ctxProducer func() (ctx context.Context, canceller func()),
 		}
 	}
 	if db == nil {
-		glog.Fatal("The Stored Request Postgres Poller needs a database connection to work.")
+		logger.Fatal("The Stored Request Postgres Poller needs a database connection to work.")
 	}
 
 	e := &PostgresPoller{
@@ -44,12 +44,12 @@ func PollForUpdates(ctxProducer func() (ctx context.Context, canceller func()),
 		saves:         make(chan events.Save, 1),
 	}
 
-	glog.Infof("Stored Requests will be refreshed from Postgres every %f seconds with: %s", refreshRate.Seconds(), query)
+	logger.Infof("Stored Requests will be refreshed from Postgres every %f seconds with: %s", refreshRate.Seconds(), query)
 
 	if refreshRate > 0 {
 		go e.refresh(time.Tick(refreshRate))
 	} else {
-		glog.Warningf("Postgres Stored Event polling refreshRate was %d. This must be positive. No updates will occur.")
+		logger.Warningf("Postgres Stored Event polling refreshRate was %d. This must be positive. No updates will occur.")
 	}
 	return e
 }
@@ -74,17 +74,17 @@ func (e *PostgresPoller) refresh(ticker <-chan time.Time) {
 			ctx, cancel := e.ctxProducer()
 			rows, err := e.db.QueryContext(ctx, e.updateQuery, e.lastUpdate)
 			if err != nil {
-				glog.Warningf("Failed to update Stored Request data: %v", err)
+				logger.Warningf("Failed to update Stored Request data: %v", err)
 				cancel()
 				continue
 			}
 			if err := sendEvents(rows, e.saves, e.invalidations); err != nil {
-				glog.Warningf("Failed to update Stored Request data: %v", err)
+				logger.Warningf("Failed to update Stored Request data: %v", err)
 			} else {
 				e.lastUpdate = thisTimeInUTC
 			}
 			if err := rows.Close(); err != nil {
-				glog.Warningf("Failed to close DB connection: %v", err)
+				logger.Warningf("Failed to close DB connection: %v", err)
 			}
 			cancel()
 		}
@@ -123,7 +123,7 @@ func sendEvents(rows *sql.Rows, saves chan<- events.Save, invalidations chan<- e
 				storedImpData[id] = data
 			}
 		default:
-			glog.Warningf("Stored Data with id=%s has invalid type: %s. This will be ignored.", id, dataType)
+			logger.Warningf("Stored Data with id=%s has invalid type: %s. This will be ignored.", id, dataType)
 		}
 	}
 
@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 
-	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/logger"
 	"github.com/prebid/prebid-server/stored_requests/events"
 )
 
@@ -19,7 +19,7 @@ import (
 //
 func LoadAll(ctx context.Context, db *sql.DB, query string) (eventProducer *PostgresLoader) {
 	if db == nil {
-		glog.Fatal("The Stored Request Postgres Startup needs a database connection to work.")
+		logger.Fatal("The Stored Request Postgres Startup needs a database connection to work.")
 	}
 	eventProducer = &PostgresLoader{
 		saves: make(chan events.Save, 1),
@@ -33,21 +33,21 @@ type PostgresLoader struct {
 }
 
 func (loader *PostgresLoader) doFetch(ctx context.Context, db *sql.DB, query string) {
-	glog.Infof("Loading all Stored Requests from Postgres with: %s", query)
+	logger.Infof("Loading all Stored Requests from Postgres with: %s", query)
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		glog.Warningf("Failed to fetch Stored Requests from Postgres on startup. The app might be a bit slow to start. Error was: %v", err)
+		logger.Warningf("Failed to fetch Stored Requests from Postgres on startup. The app might be a bit slow to start. Error was: %v", err)
 		loader.saves <- events.Save{}
 		return
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
-			glog.Warningf("Failed to close DB connection: %v", err)
+			logger.Warningf("Failed to close DB connection: %v", err)
 		}
 	}()
 
 	if err := sendEvents(rows, loader.saves, nil); err != nil {
-		glog.Warningf("Failed to fetch Stored Requests from Postgres on startup. Things might be a bit slow to start: %v", err)
+		logger.Warningf("Failed to fetch Stored Requests from Postgres on startup. Things might be a bit slow to start: %v", err)
 		loader.saves <- events.Save{}
 	}
 }
@@ -0,0 +1,89 @@
+package stored_requests
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+type fetcherWithMetrics struct {
+	fetcher       Fetcher
+	metricsEngine pbsmetrics.MetricsEngine
+	dataType      pbsmetrics.StoredDataType
+	source        pbsmetrics.StoredDataSource
+}
+
+// WithMetrics returns a Fetcher which records how long each call to the given fetcher takes, along with
+// any errors it returns. The source should describe the backend which the fetcher reads from.
+func WithMetrics(fetcher Fetcher, metricsEngine pbsmetrics.MetricsEngine, dataType pbsmetrics.StoredDataType, source pbsmetrics.StoredDataSource) Fetcher {
+	return &fetcherWithMetrics{
+		fetcher:       fetcher,
+		metricsEngine: metricsEngine,
+		dataType:      dataType,
+		source:        source,
+	}
+}
+
+func (f *fetcherWithMetrics) FetchRequests(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage, errs []error) {
+	// Don't skew the timings with calls which have nothing to look up.
+	if len(requestIDs) == 0 && len(impIDs) == 0 {
+		return f.fetcher.FetchRequests(ctx, requestIDs, impIDs)
+	}
+
+	start := time.Now()
+	requestData, impData, errs = f.fetcher.FetchRequests(ctx, requestIDs, impIDs)
+	labels := pbsmetrics.StoredDataLabels{
+		DataType: f.dataType,
+		Source:   f.source,
+	}
+	f.metricsEngine.RecordStoredDataFetchTime(labels, time.Since(start))
+	for _, err := range errs {
+		labels.Error = storedDataError(err)
+		f.metricsEngine.RecordStoredDataError(labels)
+	}
+	return
+}
+
+func storedDataError(err error) pbsmetrics.StoredDataError {
+	if err == context.DeadlineExceeded {
+		return pbsmetrics.StoredDataErrorTimeout
+	}
+	switch err.(type) {
+	case NotFoundError, *NotFoundError:
+		return pbsmetrics.StoredDataErrorNotFound
+	default:
+		return pbsmetrics.StoredDataErrorUnknown
+	}
+}
+
+type cacheWithMetrics struct {
+	Cache
+	metricsEngine pbsmetrics.MetricsEngine
+	dataType      pbsmetrics.StoredDataType
+}
+
+// WithCacheMetrics returns a Cache which records how many of the IDs passed to Get were found in the given cache.
+// Invalidate and Save are passed straight through.
+func WithCacheMetrics(cache Cache, metricsEngine pbsmetrics.MetricsEngine, dataType pbsmetrics.StoredDataType) Cache {
+	return &cacheWithMetrics{
+		Cache:         cache,
+		metricsEngine: metricsEngine,
+		dataType:      dataType,
+	}
+}
+
+func (c *cacheWithMetrics) Get(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage) {
+	requestData, impData = c.Cache.Get(ctx, requestIDs, impIDs)
+
+	hits := len(requestData) + len(impData)
+	misses := len(requestIDs) + len(impIDs) - hits
+	if hits > 0 {
+		c.metricsEngine.RecordStoredDataCacheResult(c.dataType, pbsmetrics.CacheHit, hits)
+	}
+	if misses > 0 {
+		c.metricsEngine.RecordStoredDataCacheResult(c.dataType, pbsmetrics.CacheMiss, misses)
+	}
+	return
+}
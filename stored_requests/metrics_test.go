@@ -0,0 +1,68 @@
+package stored_requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFetcherMetrics(t *testing.T) {
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), []openrtb_ext.BidderName{})
+	fetcher := &mockFetcher{
+		mockGetReqs: map[string]json.RawMessage{
+			"req-id": json.RawMessage(`{}`),
+		},
+		returnErrs: []error{
+			NotFoundError{"imp-id", "Imp"},
+			context.DeadlineExceeded,
+			errors.New("db went away"),
+		},
+	}
+	instrumented := WithMetrics(fetcher, me, pbsmetrics.StoredDataTypeRequest, pbsmetrics.StoredDataSourcePostgres)
+	instrumented.FetchRequests(context.Background(), []string{"req-id"}, []string{"imp-id"})
+	instrumented.FetchRequests(context.Background(), nil, nil)
+
+	timer := me.StoredDataFetchTimers[pbsmetrics.StoredDataTypeRequest][pbsmetrics.StoredDataSourcePostgres]
+	if timer.Count() != 1 {
+		t.Errorf("Expected 1 fetch to be timed. Got %d", timer.Count())
+	}
+	errMeters := me.StoredDataErrorMeters[pbsmetrics.StoredDataTypeRequest][pbsmetrics.StoredDataSourcePostgres]
+	assertMeterCount(t, "not_found", errMeters[pbsmetrics.StoredDataErrorNotFound], 1)
+	assertMeterCount(t, "timeout", errMeters[pbsmetrics.StoredDataErrorTimeout], 1)
+	assertMeterCount(t, "unknown_error", errMeters[pbsmetrics.StoredDataErrorUnknown], 1)
+}
+
+func TestCacheMetrics(t *testing.T) {
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), []openrtb_ext.BidderName{})
+	cache := &mockCache{
+		mockGetReqs: map[string]json.RawMessage{
+			"req-id": json.RawMessage(`{}`),
+		},
+		mockGetImps: map[string]json.RawMessage{
+			"cached": json.RawMessage(`{}`),
+		},
+	}
+	instrumented := WithCacheMetrics(cache, me, pbsmetrics.StoredDataTypeAMP)
+	instrumented.Get(context.Background(), []string{"req-id"}, []string{"cached", "uncached-1", "uncached-2"})
+
+	cacheMeters := me.StoredDataCacheMeters[pbsmetrics.StoredDataTypeAMP]
+	assertMeterCount(t, "hit", cacheMeters[pbsmetrics.CacheHit], 2)
+	assertMeterCount(t, "miss", cacheMeters[pbsmetrics.CacheMiss], 2)
+
+	instrumented.Save(context.Background(), map[string]json.RawMessage{"saved": json.RawMessage(`{}`)}, nil)
+	if _, ok := cache.gotSaveReqs["saved"]; !ok {
+		t.Errorf("Save calls should be passed through to the underlying cache.")
+	}
+}
+
+func assertMeterCount(t *testing.T, name string, meter metrics.Meter, expected int64) {
+	t.Helper()
+	if meter.Count() != expected {
+		t.Errorf("Bad count for %s meter. Expected %d, got %d", name, expected, meter.Count())
+	}
+}
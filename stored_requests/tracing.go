@@ -0,0 +1,51 @@
+package stored_requests
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/tracing"
+)
+
+type fetcherWithTracing struct {
+	fetcher  Fetcher
+	dataType pbsmetrics.StoredDataType
+	source   pbsmetrics.StoredDataSource
+}
+
+// WithTracing returns a Fetcher which records a span for each call to the given fetcher.
+// IDs which the fetcher can't find are noted on the span, but only other errors mark it as failed.
+func WithTracing(fetcher Fetcher, dataType pbsmetrics.StoredDataType, source pbsmetrics.StoredDataSource) Fetcher {
+	return &fetcherWithTracing{
+		fetcher:  fetcher,
+		dataType: dataType,
+		source:   source,
+	}
+}
+
+func (f *fetcherWithTracing) FetchRequests(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage, errs []error) {
+	if len(requestIDs) == 0 && len(impIDs) == 0 {
+		return f.fetcher.FetchRequests(ctx, requestIDs, impIDs)
+	}
+
+	ctx, span := tracing.StartSpan(ctx, "stored_requests.fetch",
+		tracing.String("stored_data.type", string(f.dataType)),
+		tracing.String("stored_data.source", string(f.source)),
+		tracing.Int("stored_data.requests", len(requestIDs)),
+		tracing.Int("stored_data.imps", len(impIDs)))
+	requestData, impData, errs = f.fetcher.FetchRequests(ctx, requestIDs, impIDs)
+
+	var failure error
+	notFound := 0
+	for _, err := range errs {
+		if storedDataError(err) == pbsmetrics.StoredDataErrorNotFound {
+			notFound++
+		} else if failure == nil {
+			failure = err
+		}
+	}
+	span.SetAttributes(tracing.Int("stored_data.not_found", notFound))
+	tracing.EndSpan(span, failure)
+	return
+}
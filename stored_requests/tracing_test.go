@@ -0,0 +1,39 @@
+package stored_requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/tracing"
+)
+
+func TestFetcherTracing(t *testing.T) {
+	recorder := tracing.Record()
+
+	fetcher := &mockFetcher{
+		mockGetReqs: map[string]json.RawMessage{
+			"req-id": json.RawMessage(`{}`),
+		},
+		returnErrs: []error{NotFoundError{"imp-id", "Imp"}},
+	}
+	traced := WithTracing(fetcher, pbsmetrics.StoredDataTypeRequest, pbsmetrics.StoredDataSourcePostgres)
+	traced.FetchRequests(context.Background(), []string{"req-id"}, []string{"imp-id"})
+	traced.FetchRequests(context.Background(), nil, nil)
+
+	fetcher.returnErrs = []error{errors.New("db went away")}
+	traced.FetchRequests(context.Background(), []string{"req-id"}, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans. Got %d", len(spans))
+	}
+	if spans[0].Failure() != "" {
+		t.Error("Missing IDs shouldn't mark the fetch as failed.")
+	}
+	if spans[1].Failure() == "" {
+		t.Error("Backend errors should mark the fetch as failed.")
+	}
+}
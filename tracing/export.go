@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+)
+
+const (
+	// scopeName names the instrumentation in the exported spans.
+	scopeName = "github.com/prebid/prebid-server"
+	// queueSize caps the spans which are waiting to be exported. Spans which end while it's full are dropped,
+	// so that a slow collector can't hold up the auctions.
+	queueSize = 4096
+	// batchSize is the most spans which are sent in one call to the collector.
+	batchSize = 512
+	// batchDelay is the longest a span waits before its batch is sent.
+	batchDelay = 5 * time.Second
+	// exportTimeout bounds each call to the collector.
+	exportTimeout = 10 * time.Second
+)
+
+// exporter sends the spans to an OTLP/HTTP collector, as JSON, in batches.
+// See https://opentelemetry.io/docs/specs/otlp/#otlphttp
+type exporter struct {
+	client      *http.Client
+	url         string
+	serviceName string
+	queue       chan *Span
+	done        chan struct{}
+
+	// lock guards closed, so that spans which end during shutdown aren't sent on the closed queue.
+	lock   sync.RWMutex
+	closed bool
+}
+
+func newExporter(cfg *config.Tracing) *exporter {
+	scheme := "https"
+	if cfg.Insecure {
+		scheme = "http"
+	}
+	e := &exporter{
+		client:      &http.Client{},
+		url:         scheme + "://" + cfg.Endpoint + "/v1/traces",
+		serviceName: cfg.ServiceName,
+		queue:       make(chan *Span, queueSize),
+		done:        make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *exporter) record(span *Span) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+// run sends the queued spans whenever a batch fills up, or batchDelay has passed. It sends whatever's left once
+// the queue is closed.
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(batchDelay)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, batchSize)
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				e.send(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		e.send(batch)
+		batch = batch[:0]
+	}
+}
+
+func (e *exporter) send(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		logger.Errorf("Failed to encode %d spans: %v", len(batch), err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		logger.Errorf("Failed to export %d spans: %v", len(batch), err)
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		logger.Warningf("Failed to export %d spans: %v", len(batch), err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Warningf("Failed to export %d spans: the collector returned status %d", len(batch), resp.StatusCode)
+	}
+}
+
+// shutdown sends the spans which are still queued. Spans which end afterwards are dropped.
+func (e *exporter) shutdown(ctx context.Context) error {
+	active.Store((*tracer)(nil))
+	e.lock.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.lock.Unlock()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Gave up waiting for the last spans to be exported: %v", ctx.Err())
+	}
+}
+
+// The types below are the JSON encoding of an OTLP ExportTraceServiceRequest. IDs are hex strings, and 64 bit
+// integers are decimal strings, as the OTLP spec requires.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              spanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	// Code 2 is STATUS_CODE_ERROR.
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *exporter) request(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, encodeSpan(span))
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: encodeAttributes([]Attribute{String("service.name", e.serviceName)})},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: spans}},
+		}},
+	}
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	encoded := otlpSpan{
+		TraceID:           hex.EncodeToString(span.traceID[:]),
+		SpanID:            hex.EncodeToString(span.spanID[:]),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        encodeAttributes(span.attrs),
+	}
+	if span.parentID != (spanID{}) {
+		encoded.ParentSpanID = hex.EncodeToString(span.parentID[:])
+	}
+	if span.failure != "" {
+		encoded.Status = &otlpStatus{Code: 2, Message: span.failure}
+	}
+	return encoded
+}
+
+func encodeAttributes(attrs []Attribute) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch typed := attr.Value.(type) {
+		case string:
+			value.StringValue = &typed
+		case int64:
+			intValue := strconv.FormatInt(typed, 10)
+			value.IntValue = &intValue
+		case float64:
+			value.DoubleValue = &typed
+		case bool:
+			value.BoolValue = &typed
+		default:
+			stringValue := fmt.Sprint(typed)
+			value.StringValue = &stringValue
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
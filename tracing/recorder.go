@@ -0,0 +1,30 @@
+package tracing
+
+import "sync"
+
+// Recorder keeps the spans which have ended, rather than exporting them, so that tests can check them.
+type Recorder struct {
+	lock  sync.Mutex
+	spans []*Span
+}
+
+// Record samples every new trace, and sends its spans to the returned Recorder rather than the collector.
+// It's meant for tests.
+func Record() *Recorder {
+	r := &Recorder{}
+	active.Store(newTracer(r, 1))
+	return r
+}
+
+func (r *Recorder) record(span *Span) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// Ended returns the spans which have ended so far, in the order they ended.
+func (r *Recorder) Ended() []*Span {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*Span(nil), r.spans...)
+}
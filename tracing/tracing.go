@@ -0,0 +1,277 @@
+// Package tracing records spans for the work done on each request, and exports them to an OpenTelemetry collector
+// over OTLP/HTTP, so that full auctions can be followed across Prebid Server and the services around it.
+//
+// It only uses the standard library, so that it builds with the same Go versions and dep constraints as the rest
+// of the server. Until Setup is called with tracing enabled, StartSpan returns nil spans, and every method on a nil
+// *Span does nothing, so instrumented code costs almost nothing when tracing is off.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/logger"
+)
+
+// Attribute is a key and value recorded on a span. The value is a string, int64, float64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// spanKind says how a span relates to the calls around it. The values are the ones OTLP uses.
+type spanKind int
+
+const (
+	kindInternal spanKind = 1
+	kindServer   spanKind = 2
+)
+
+type traceID [16]byte
+type spanID [8]byte
+
+// Span is a single piece of work within a trace. Spans which weren't sampled are still kept in the context,
+// so that their children share the sampling decision, but they're never exported.
+type Span struct {
+	traceID  traceID
+	spanID   spanID
+	parentID spanID
+	sampled  bool
+	name     string
+	kind     spanKind
+	start    time.Time
+	recorder recorder
+
+	lock    sync.Mutex
+	end     time.Time
+	attrs   []Attribute
+	failure string
+	ended   bool
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End finishes the span. Only the first call counts.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.lock.Unlock()
+	if s.sampled {
+		s.recorder.record(s)
+	}
+}
+
+// Name returns the name the span was started with.
+func (s *Span) Name() string {
+	if s == nil {
+		return ""
+	}
+	return s.name
+}
+
+// Failure returns the error which the span ended with, or "" if it succeeded.
+func (s *Span) Failure() string {
+	if s == nil {
+		return ""
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.failure
+}
+
+// TraceID returns the hex ID of the span's trace, or "" if s is nil.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// recorder receives the sampled spans once they've ended. It's the exporter, or a Recorder in the tests.
+type recorder interface {
+	record(span *Span)
+}
+
+// tracer starts the spans, once tracing is set up.
+type tracer struct {
+	recorder recorder
+	// threshold is compared with the trace IDs to decide which new traces are sampled.
+	threshold uint64
+}
+
+// active holds the *tracer, or a nil one if tracing is off. It's only replaced by Setup and Record.
+var active atomic.Value
+
+func init() {
+	active.Store((*tracer)(nil))
+}
+
+func newTracer(recorder recorder, sampleRate float64) *tracer {
+	t := &tracer{recorder: recorder}
+	// This matches OpenTelemetry's TraceIDRatioBased sampler, so the decisions agree with other services.
+	if sampleRate >= 1 {
+		t.threshold = 1 << 63
+	} else if sampleRate > 0 {
+		t.threshold = uint64(sampleRate * (1 << 63))
+	}
+	return t
+}
+
+func (t *tracer) samples(id traceID) bool {
+	return binary.BigEndian.Uint64(id[8:16])>>1 < t.threshold
+}
+
+type spanKey struct{}
+
+// spanFrom returns the span in ctx, or nil if there isn't one.
+func spanFrom(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// withSpan returns a context which carries the span, and logs its trace ID.
+func withSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return logger.WithTraceID(context.WithValue(ctx, spanKey{}, span), span.TraceID())
+}
+
+// StartSpan starts a span as a child of any span in ctx.
+// The caller must end it, usually with EndSpan.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	span := start(spanFrom(ctx), name, kindInternal, attrs)
+	return withSpan(ctx, span), span
+}
+
+// start starts a span under parent, or at the root of a new trace if parent is nil.
+func start(parent *Span, name string, kind spanKind, attrs []Attribute) *Span {
+	t := active.Load().(*tracer)
+	if t == nil {
+		return nil
+	}
+	span := &Span{
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+		recorder: t.recorder,
+		attrs:    attrs,
+	}
+	rand.Read(span.spanID[:])
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = parent.sampled
+	} else {
+		rand.Read(span.traceID[:])
+		span.sampled = t.samples(span.traceID)
+	}
+	return span
+}
+
+// EndSpan marks the span as failed if err is non-nil, and then ends it.
+func EndSpan(span *Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.lock.Lock()
+		span.failure = err.Error()
+		span.lock.Unlock()
+	}
+	span.End()
+}
+
+// Handle wraps an endpoint so that each request gets a server span. If the caller sent a traceparent header,
+// the span joins the caller's trace, and follows its sampling decision.
+func Handle(name string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		span := start(parseTraceparent(r.Header.Get("traceparent")), name, kindServer, []Attribute{
+			String("http.method", r.Method),
+			String("http.target", r.URL.Path),
+		})
+		defer span.End()
+		handle(w, r.WithContext(withSpan(r.Context(), span)), params)
+	}
+}
+
+// parseTraceparent returns the caller's span from a W3C traceparent header, or nil if the header isn't valid.
+// See https://www.w3.org/TR/trace-context/#traceparent-header
+func parseTraceparent(header string) *Span {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil
+	}
+	// Version 00 has exactly four fields. Later versions may add more, which are ignored.
+	if parts[0] == "00" && len(parts) != 4 {
+		return nil
+	}
+	remote := &Span{}
+	if _, err := hex.Decode(remote.traceID[:], []byte(parts[1])); err != nil || remote.traceID == (traceID{}) {
+		return nil
+	}
+	if _, err := hex.Decode(remote.spanID[:], []byte(parts[2])); err != nil || remote.spanID == (spanID{}) {
+		return nil
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return nil
+	}
+	remote.sampled = flags[0]&1 == 1
+	return remote
+}
+
+// Detach returns a fresh context which carries the span from ctx, but none of its deadlines or cancellation.
+//
+// Auctions deliberately run on their own timeouts, rather than stopping when the client goes away,
+// but their spans should still belong to the request's trace.
+func Detach(ctx context.Context) context.Context {
+	return withSpan(context.Background(), spanFrom(ctx))
+}
+
+// Setup starts exporting the sampled spans to the OTLP collector, if tracing is enabled.
+// The returned function flushes any spans which haven't been exported yet, and should be called on shutdown.
+func Setup(cfg *config.Tracing) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		active.Store((*tracer)(nil))
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter := newExporter(cfg)
+	active.Store(newTracer(exporter, cfg.SampleRate))
+	return exporter.shutdown, nil
+}
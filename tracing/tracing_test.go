@@ -0,0 +1,185 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestDisabledSetup(t *testing.T) {
+	shutdown, err := Setup(&config.Tracing{Enabled: false})
+	if err != nil {
+		t.Fatalf("Disabled tracing shouldn't fail to set up. Got %v", err)
+	}
+	if _, span := StartSpan(context.Background(), "request"); span != nil {
+		t.Error("Disabled tracing shouldn't start spans.")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Disabled tracing shouldn't fail to shut down. Got %v", err)
+	}
+}
+
+func TestHandleJoinsTrace(t *testing.T) {
+	recorder := Record()
+	var handlerSpan *Span
+	handle := Handle("openrtb2.auction", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		handlerSpan = spanFrom(r.Context())
+	})
+
+	req := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handle(httptest.NewRecorder(), req, nil)
+
+	if handlerSpan.TraceID() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("The handler should join the caller's trace. Got trace %s", handlerSpan.TraceID())
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "openrtb2.auction" || spans[0].kind != kindServer {
+		t.Errorf("Expected one server span named openrtb2.auction. Got %v", spans)
+	}
+}
+
+func TestHandleFollowsCallerSampling(t *testing.T) {
+	recorder := Record()
+	handle := Handle("openrtb2.auction", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		_, span := StartSpan(r.Context(), "exchange.auction")
+		span.End()
+	})
+
+	req := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handle(httptest.NewRecorder(), req, nil)
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("Spans in traces which the caller didn't sample shouldn't be recorded. Got %d", len(spans))
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	}
+	for _, header := range invalid {
+		if remote := parseTraceparent(header); remote != nil {
+			t.Errorf("%q isn't a valid traceparent, so it should be ignored.", header)
+		}
+	}
+	if remote := parseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); remote == nil || !remote.sampled {
+		t.Error("Later versions of traceparent may have more fields.")
+	}
+}
+
+func TestEndSpanWithError(t *testing.T) {
+	recorder := Record()
+	_, span := StartSpan(context.Background(), "stored_requests.fetch")
+	EndSpan(span, errors.New("db is down"))
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Failure() != "db is down" {
+		t.Errorf("Spans which end with an error should be marked as failed, and only recorded once. Got %v", spans)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	tracer := newTracer(nil, 0.5)
+	var low, high traceID
+	high[8] = 0xc0
+	if !tracer.samples(low) || tracer.samples(high) {
+		t.Error("Traces should be sampled by comparing the low half of their IDs with the sample rate.")
+	}
+	if newTracer(nil, 0).samples(low) {
+		t.Error("A sample rate of 0 shouldn't sample any traces.")
+	}
+	if !newTracer(nil, 1).samples(traceID{8: 0xff, 9: 0xff, 10: 0xff, 11: 0xff, 12: 0xff, 13: 0xff, 14: 0xff, 15: 0xff}) {
+		t.Error("A sample rate of 1 should sample every trace.")
+	}
+}
+
+func TestDetach(t *testing.T) {
+	Record()
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	parent, span := StartSpan(parent, "request")
+	defer span.End()
+
+	detached := Detach(parent)
+	if _, hasDeadline := detached.Deadline(); hasDeadline {
+		t.Error("Detached contexts shouldn't inherit the parent's deadline.")
+	}
+	if spanFrom(detached) != span {
+		t.Error("Detached contexts should keep the parent's span.")
+	}
+}
+
+func TestExport(t *testing.T) {
+	var body otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Spans should be posted as JSON to /v1/traces. Got %s with %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Bad export request: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	shutdown, err := Setup(&config.Tracing{
+		Enabled:     true,
+		Endpoint:    strings.TrimPrefix(server.URL, "http://"),
+		Insecure:    true,
+		SampleRate:  1,
+		ServiceName: "prebid-server",
+	})
+	if err != nil {
+		t.Fatalf("Failed to set up tracing: %v", err)
+	}
+	ctx, parent := StartSpan(context.Background(), "exchange.auction", Int("imps", 2))
+	_, child := StartSpan(ctx, "exchange.bidder", String("bidder", "appnexus"))
+	EndSpan(child, errors.New("timed out"))
+	parent.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Failed to flush the spans: %v", err)
+	}
+	if _, span := StartSpan(context.Background(), "late"); span != nil {
+		t.Error("Spans shouldn't be started after shutdown.")
+	}
+
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Expected one batch of spans. Got %+v", body)
+	}
+	serviceName := body.ResourceSpans[0].Resource.Attributes[0]
+	if serviceName.Key != "service.name" || *serviceName.Value.StringValue != "prebid-server" {
+		t.Errorf("The spans should be labelled with the service name. Got %+v", serviceName)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans. Got %d", len(spans))
+	}
+	exportedChild, exportedParent := spans[0], spans[1]
+	if exportedChild.TraceID != parent.TraceID() || exportedChild.ParentSpanID != exportedParent.SpanID || exportedParent.ParentSpanID != "" {
+		t.Errorf("The bidder span should be a child of the auction span. Got %+v and %+v", exportedChild, exportedParent)
+	}
+	if exportedChild.Status == nil || exportedChild.Status.Code != 2 || exportedChild.Status.Message != "timed out" {
+		t.Errorf("Failed spans should have an error status. Got %+v", exportedChild.Status)
+	}
+	if exportedParent.Status != nil || *exportedParent.Attributes[0].Value.IntValue != "2" {
+		t.Errorf("Expected a successful span with imps=2. Got %+v", exportedParent)
+	}
+}
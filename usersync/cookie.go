@@ -146,13 +146,50 @@ func (cookie *PBSCookie) GetId(bidderName openrtb_ext.BidderName) (id string, ex
 	return
 }
 
+// GetLiveId is like GetId, but ignores IDs which have expired.
+func (cookie *PBSCookie) GetLiveId(bidderName openrtb_ext.BidderName) (id string, exists bool) {
+	familyName := string(bidderName)
+	if mapped, ok := bidderToFamilyNames[bidderName]; ok {
+		familyName = mapped
+	}
+	id, _, isLive := cookie.GetUID(familyName)
+	if !isLive {
+		return "", false
+	}
+	return id, true
+}
+
 // SetCookieOnResponse is a shortcut for "ToHTTPCookie(); cookie.setDomain(domain); setCookie(w, cookie)"
 func (cookie *PBSCookie) SetCookieOnResponse(w http.ResponseWriter, domain string, ttl time.Duration) {
+	cookie.setCookie(w, domain, ttl, config.CookieAttributes{})
+}
+
+// SetHostCookieOnResponse is like SetCookieOnResponse, but takes the domain, TTL and cookie attributes from the host's config.
+func (cookie *PBSCookie) SetHostCookieOnResponse(w http.ResponseWriter, cfg *config.HostCookie) {
+	cookie.setCookie(w, cfg.Domain, cfg.TTLDuration(), cfg.Attributes)
+}
+
+func (cookie *PBSCookie) setCookie(w http.ResponseWriter, domain string, ttl time.Duration, attributes config.CookieAttributes) {
 	httpCookie := cookie.ToHTTPCookie(ttl)
 	if domain != "" {
 		httpCookie.Domain = domain
 	}
-	http.SetCookie(w, httpCookie)
+	httpCookie.Secure = attributes.Secure
+	if attributes.MaxAge {
+		httpCookie.MaxAge = int(ttl / time.Second)
+	}
+	value := httpCookie.String()
+	if value == "" {
+		return
+	}
+	// http.Cookie can't express SameSite or Partitioned, so they're added to the header by hand.
+	if attributes.SameSite != "" {
+		value += "; SameSite=" + attributes.SameSite
+	}
+	if attributes.Partitioned {
+		value += "; Partitioned"
+	}
+	w.Header().Add("Set-Cookie", value)
 }
 
 // Unsync removes the user's ID for the given family from this cookie.
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -77,6 +78,33 @@ func TestBidderNameGets(t *testing.T) {
 	}
 }
 
+func TestGetLiveId(t *testing.T) {
+	cookie := &PBSCookie{
+		uids: map[string]uidWithExpiry{
+			"adnxs": newTempId("123"),
+			"rubicon": {
+				UID:     "456",
+				Expires: time.Now().Add(-10 * time.Minute),
+			},
+		},
+		birthday: timestamp(),
+	}
+	if id, exists := cookie.GetLiveId(openrtb_ext.BidderAppnexus); !exists || id != "123" {
+		t.Errorf("Live IDs should be returned. Got %s, %t", id, exists)
+	}
+	if id, exists := cookie.GetLiveId(openrtb_ext.BidderRubicon); exists || id != "" {
+		t.Errorf("Expired IDs should not be returned. Got %s, %t", id, exists)
+	}
+	if _, exists := cookie.GetId(openrtb_ext.BidderRubicon); !exists {
+		t.Errorf("GetId should still return expired IDs.")
+	}
+
+	var nilCookie *PBSCookie
+	if _, exists := nilCookie.GetLiveId(openrtb_ext.BidderAppnexus); exists {
+		t.Error("nil cookies shouldn't claim to have a UID mapping.")
+	}
+}
+
 func TestRejectAudienceNetworkCookie(t *testing.T) {
 	raw := &PBSCookie{
 		uids: map[string]uidWithExpiry{
@@ -337,6 +365,36 @@ func ensureConsistency(t *testing.T, cookie *PBSCookie) {
 	}
 }
 
+func TestHostCookieAttributes(t *testing.T) {
+	cookie := NewPBSCookie()
+	w := httptest.NewRecorder()
+	cookie.SetHostCookieOnResponse(w, &config.HostCookie{
+		Domain: "mock-domain",
+		TTL:    90,
+		Attributes: config.CookieAttributes{
+			SameSite:    config.SameSiteNone,
+			Secure:      true,
+			Partitioned: true,
+			MaxAge:      true,
+		},
+	})
+	written := w.HeaderMap.Get("Set-Cookie")
+	for _, attribute := range []string{"Domain=mock-domain", "Max-Age=7776000", "Secure", "SameSite=None", "Partitioned"} {
+		if !strings.Contains(written, "; "+attribute) {
+			t.Errorf("The cookie should have the %s attribute. Got %s", attribute, written)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	cookie.SetHostCookieOnResponse(w, &config.HostCookie{TTL: 90})
+	written = w.HeaderMap.Get("Set-Cookie")
+	for _, attribute := range []string{"Max-Age", "Secure", "SameSite", "Partitioned"} {
+		if strings.Contains(written, attribute) {
+			t.Errorf("The cookie shouldn't have the %s attribute by default. Got %s", attribute, written)
+		}
+	}
+}
+
 func newTempId(uid string) uidWithExpiry {
 	return uidWithExpiry{
 		UID:     uid,
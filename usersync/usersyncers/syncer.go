@@ -1,6 +1,8 @@
 package usersyncers
 
 import (
+	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/prebid/prebid-server/config"
@@ -11,7 +13,7 @@ import (
 // NewSyncerMap returns a map of all the usersyncer objects.
 // The same keys should exist in this map as in the exchanges map.
 func NewSyncerMap(cfg *config.Configuration) map[openrtb_ext.BidderName]usersync.Usersyncer {
-	return map[openrtb_ext.BidderName]usersync.Usersyncer{
+	syncers := map[openrtb_ext.BidderName]usersync.Usersyncer{
 		openrtb_ext.BidderAdform:       NewAdformSyncer(cfg.Adapters["adform"].UserSyncURL, cfg.ExternalURL),
 		openrtb_ext.BidderAdtelligent:  NewAdtelligentSyncer(cfg.ExternalURL),
 		openrtb_ext.BidderAppnexus:     NewAppnexusSyncer(cfg.ExternalURL),
@@ -29,6 +31,103 @@ func NewSyncerMap(cfg *config.Configuration) map[openrtb_ext.BidderName]usersync
 		openrtb_ext.BidderSomoaudience: NewSomoaudienceSyncer(cfg.ExternalURL),
 		openrtb_ext.BidderSovrn:        NewSovrnSyncer(cfg.ExternalURL, cfg.Adapters["sovrn"].UserSyncURL),
 	}
+	addAliasSyncers(syncers, cfg)
+	return syncers
+}
+
+// addAliasSyncers adds a syncer for each of the host's aliases which has its own usersync_url.
+// The alias syncs under its own name, so that its IDs are kept apart from its core bidder's.
+func addAliasSyncers(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, cfg *config.Configuration) {
+	for _, bidderName := range openrtb_ext.BidderMap {
+		if !openrtb_ext.IsAlias(bidderName) {
+			continue
+		}
+		usersyncURL := cfg.Adapters[strings.ToLower(string(bidderName))].UserSyncURL
+		parent, ok := syncers[openrtb_ext.CoreBidderName(bidderName)]
+		if usersyncURL == "" || !ok {
+			continue
+		}
+		syncers[bidderName] = &syncer{
+			familyName:          string(bidderName),
+			gdprVendorID:        parent.GDPRVendorID(),
+			syncEndpointBuilder: resolveMacros(usersyncURL),
+			syncType:            SyncType(parent.GetUsersyncInfo("", "").Type),
+		}
+	}
+}
+
+// ValidateSyncers returns an error for each syncer whose URL is broken. Syncers without a URL are skipped, since
+// their bidders haven't been set up for syncs on this host.
+//
+// If a syncer's URL sends the user back to /setuid, the callback must save the ID under the syncer's own family,
+// and have a uid param for the bidder to fill in. Otherwise the IDs would be lost, or saved for the wrong bidder.
+func ValidateSyncers(syncers map[openrtb_ext.BidderName]usersync.Usersyncer) []error {
+	var errs []error
+	for bidderName, syncer := range syncers {
+		if err := validateSyncURL(syncer.FamilyName(), syncer.GetUsersyncInfo("1", "consent").URL); err != nil {
+			errs = append(errs, fmt.Errorf("The usersync URL for %s is invalid: %v", bidderName, err))
+		}
+	}
+	return errs
+}
+
+func validateSyncURL(family string, syncURL string) error {
+	if syncURL == "" {
+		return nil
+	}
+	if strings.Contains(syncURL, "{{") {
+		return fmt.Errorf("%s has an unknown macro. Only {{gdpr}} and {{gdpr_consent}} are supported", syncURL)
+	}
+	parsed, err := url.Parse(syncURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%s has no host", syncURL)
+	}
+
+	callback, ok := findSetUIDCallback(syncURL)
+	if !ok {
+		return nil
+	}
+	var bidder string
+	hasUID := false
+	for _, param := range strings.Split(callback, "&") {
+		key, value := param, ""
+		if index := strings.Index(param, "="); index >= 0 {
+			key, value = param[:index], param[index+1:]
+		}
+		switch key {
+		case "bidder":
+			bidder = value
+		case "uid":
+			hasUID = true
+		}
+	}
+	if bidder != family {
+		return fmt.Errorf("%s saves the ID for bidder \"%s\" instead of \"%s\"", syncURL, bidder, family)
+	}
+	if !hasUID {
+		return fmt.Errorf("%s sends the user back to /setuid without a uid param", syncURL)
+	}
+	return nil
+}
+
+// findSetUIDCallback returns the query of the /setuid URL which the sync URL sends the user back to, if it has one.
+// Bidders expect the callback to be escaped, usually once, so it's unescaped until it can be found.
+// The callback's params aren't unescaped again, since the bidders' uid macros often aren't valid escapes.
+func findSetUIDCallback(syncURL string) (string, bool) {
+	for i := 0; i < 3; i++ {
+		if index := strings.Index(syncURL, "/setuid?"); index >= 0 {
+			return syncURL[index+len("/setuid?"):], true
+		}
+		unescaped, err := url.QueryUnescape(syncURL)
+		if err != nil || unescaped == syncURL {
+			return "", false
+		}
+		syncURL = unescaped
+	}
+	return "", false
 }
 
 func GDPRAwareSyncerIDs(syncers map[openrtb_ext.BidderName]usersync.Usersyncer) map[openrtb_ext.BidderName]uint16 {
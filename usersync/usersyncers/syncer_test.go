@@ -5,6 +5,7 @@ import (
 
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/usersync"
 )
 
 func TestSyncers(t *testing.T) {
@@ -38,6 +39,36 @@ func TestVendorIDUniqueness(t *testing.T) {
 	}
 }
 
+func TestValidateSyncers(t *testing.T) {
+	syncers := map[openrtb_ext.BidderName]usersync.Usersyncer{
+		openrtb_ext.BidderAppnexus:   NewAppnexusSyncer("http://localhost:8000"),
+		openrtb_ext.BidderPulsepoint: NewPulsepointSyncer("http://localhost:8000"),
+		openrtb_ext.BidderIndex:      NewIndexSyncer("//ssum-sec.casalemedia.com/usermatchredir?s=184932&cb=https%3A%2F%2Fprebid.adnxs.com%2Fpbs%2Fv1%2Fsetuid%3Fbidder%3DindexExchange%26gdpr%3D{{gdpr}}%26gdpr_consent%3D{{gdpr_consent}}%26uid%3D"),
+		openrtb_ext.BidderRubicon:    NewRubiconSyncer("https://pixel.rubiconproject.com/exchange/sync.php?p=prebid&gdpr={{gdpr}}"),
+		openrtb_ext.BidderFacebook:   NewFacebookSyncer(""),
+	}
+	if errs := ValidateSyncers(syncers); len(errs) != 0 {
+		t.Errorf("Unexpected errors for valid usersync URLs: %v", errs)
+	}
+}
+
+func TestValidateSyncURL(t *testing.T) {
+	invalid := map[string]string{
+		"//sync.com/getuid?gdpr={{gpdr}}": "unknown macros",
+		"/getuid?gdpr={{gdpr}}":           "no host",
+		"//sync.com/getuid?redir=http%3A%2F%2Fpbs.com%2Fsetuid%3Fbidder%3Dadnxs":                "no uid param",
+		"//sync.com/getuid?redir=http%3A%2F%2Fpbs.com%2Fsetuid%3Fbidder%3Dother%26uid%3D%24UID": "the wrong bidder",
+	}
+	for syncURL, problem := range invalid {
+		if err := validateSyncURL("adnxs", syncURL); err == nil {
+			t.Errorf("Sync URLs with %s should be rejected. Got no error for %s", problem, syncURL)
+		}
+	}
+	if err := validateSyncURL("adnxs", "//sync.com/getuid?redir=http%3A%2F%2Fpbs.com%2Fsetuid%3Fbidder%3Dadnxs%26uid%3D%25%25UID%25%25"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
 func assertStringsMatch(t *testing.T, expected string, actual string) {
 	t.Helper()
 	if expected != actual {
@@ -0,0 +1,17 @@
+// Package uuidutil generates the random IDs which Prebid Server sends in requests and stores in caches.
+package uuidutil
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// NewV4 returns a random version 4 UUID, like the transaction IDs in source.tid and imp.ext.tid.
+func NewV4() string {
+	var b [16]byte
+	// crypto/rand only fails if the OS has no source of randomness, in which case nothing else would work either.
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,17 @@
+package uuidutil
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewV4(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id := NewV4()
+	if !uuidV4.MatchString(id) {
+		t.Errorf("IDs should be version 4 UUIDs. Got %s", id)
+	}
+	if id == NewV4() {
+		t.Errorf("IDs should be random.")
+	}
+}